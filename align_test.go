@@ -0,0 +1,169 @@
+package shp
+
+import (
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// Kinds of records in the alignment fixture.
+const (
+	alignPoint = iota
+	alignNull
+	alignUnknown
+	alignBadRow
+)
+
+// alignKinds describes every record of the alignment fixture.
+var alignKinds = []int{alignPoint, alignUnknown, alignNull, alignBadRow, alignPoint,
+	alignUnknown, alignUnknown, alignBadRow, alignNull, alignPoint, alignUnknown}
+
+// createAlignmentFixture writes a point for every entry of alignKinds with
+// X and the ID attribute set to its index. All records claim to be record 7,
+// and the records are then turned into NULL shapes, records of an unknown
+// shape type or records with a malformed attribute row.
+func createAlignmentFixture(t *testing.T, dir string) string {
+	filename := filepath.Join(dir, "align")
+	w, err := Create(filename, POINT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SetFields([]Field{NumberField("ID", 4)})
+	for i := range alignKinds {
+		n := w.Write(&Point{float64(i), 0})
+		w.WriteAttribute(int(n), 0, i)
+	}
+	w.Close()
+
+	shp, err := ioutil.ReadFile(filename + ".shp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbf, err := ioutil.ReadFile(filename + ".dbf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, kind := range alignKinds {
+		record := 100 + i*28
+		binary.BigEndian.PutUint32(shp[record:], 7)
+		switch kind {
+		case alignNull:
+			binary.LittleEndian.PutUint32(shp[record+8:], uint32(NULL))
+		case alignUnknown:
+			binary.LittleEndian.PutUint32(shp[record+8:], 99)
+		case alignBadRow:
+			dbf[int(w.dbfHeaderLength)+i*int(w.dbfRecordLength)] = 'X'
+		}
+	}
+	if err := ioutil.WriteFile(filename+".shp", shp, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filename+".dbf", dbf, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+// checkAlignment reads all features from r and compares them with
+// alignKinds, starting at record first.
+func checkAlignment(t *testing.T, name string, r interface{ ReadFeature() (Feature, error) }, first int) {
+	want := first
+	for {
+		f, err := r.ReadFeature()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		for want < len(alignKinds) && alignKinds[want] == alignUnknown {
+			want++
+		}
+		if want >= len(alignKinds) {
+			t.Fatalf("%s: unexpected feature %d", name, f.Index)
+		}
+		if f.Index != want {
+			t.Errorf("%s: got index %d, want %d", name, f.Index, want)
+		}
+		switch alignKinds[want] {
+		case alignNull:
+			if _, ok := f.Shape.(*Null); !ok {
+				t.Errorf("%s: feature %d: got %T, want Null", name, want, f.Shape)
+			}
+		default:
+			if p, ok := f.Shape.(*Point); !ok || p.X != float64(want) {
+				t.Errorf("%s: feature %d: got shape %v", name, want, f.Shape)
+			}
+		}
+		if alignKinds[want] == alignBadRow {
+			if !f.Attrs[0].Null {
+				t.Errorf("%s: feature %d: expected NULL attribute for malformed row", name, want)
+			}
+		} else if f.Attrs[0].Value != strconv.Itoa(want) {
+			t.Errorf("%s: feature %d is paired with attribute %q", name, want, f.Attrs[0].Value)
+		}
+		want++
+	}
+	for want < len(alignKinds) && alignKinds[want] == alignUnknown {
+		want++
+	}
+	if want != len(alignKinds) {
+		t.Errorf("%s: stopped before feature %d", name, want)
+	}
+}
+
+func TestRecordAlignment(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := createAlignmentFixture(t, dir)
+
+	r, err := Open(filename+".shp", WithLenient())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	checkAlignment(t, "reader", r, 0)
+
+	for first := 0; first <= len(alignKinds); first++ {
+		sr := SequentialReaderFromExt(openFile(filename+".shp", t), openFile(filename+".dbf", t), WithLenient()).(*seqReader)
+		// skip records like a resumed ZipReader does
+		if err := sr.skip(first); err != nil {
+			t.Fatal(err)
+		}
+		checkAlignment(t, "seqReader skipping "+strconv.Itoa(first), sr, first)
+		sr.Close()
+	}
+}
+
+func TestZeroRecordLength(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := createAlignmentFixture(t, dir)
+
+	b, err := ioutil.ReadFile(filename + ".dbf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	binary.LittleEndian.PutUint16(b[10:], 0)
+	if err := ioutil.WriteFile(filename+".dbf", b, 0666); err != nil {
+		t.Fatal(err)
+	}
+	sr := SequentialReaderFromExt(openFile(filename+".shp", t), openFile(filename+".dbf", t), WithLenient())
+	defer sr.Close()
+	if sr.Next() {
+		t.Error("expected no records for a DBF with a record length of 0")
+	}
+	if sr.Err() == nil {
+		t.Error("expected an error for a DBF with a record length of 0")
+	}
+}
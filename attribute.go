@@ -0,0 +1,397 @@
+package shp
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNullAttribute is returned by the typed attribute accessors if the
+// attribute is NULL, i.e. blank or filled with '*' in the DBF.
+var ErrNullAttribute = errors.New("Attribute is NULL")
+
+// dbfDateLayout is the layout of the values of date fields.
+const dbfDateLayout = "20060102"
+
+// checkType returns an error if a is not an attribute of one of the given
+// field types, which hold values of the given kind, or if it is NULL.
+func (a Attr) checkType(types, kind string) error {
+	if strings.IndexByte(types, a.Field.Fieldtype) < 0 {
+		return fmt.Errorf("Field %s of type %c cannot be read as %s", a.Field, a.Field.Fieldtype, kind)
+	}
+	if a.Null {
+		return ErrNullAttribute
+	}
+	return nil
+}
+
+// Int returns the value of an attribute of a numeric field as an integer.
+// Values with a fractional part are an error.
+func (a Attr) Int() (int64, error) {
+	if err := a.checkType("NF", "integer"); err != nil {
+		return 0, err
+	}
+	v := strings.TrimSpace(a.Value)
+	if i, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return i, nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || f != math.Trunc(f) || math.Abs(f) > 1<<53 {
+		return 0, fmt.Errorf("Unable to parse %q of field %s as integer", v, a.Field)
+	}
+	return int64(f), nil
+}
+
+// Float returns the value of an attribute of a numeric field.
+func (a Attr) Float() (float64, error) {
+	if err := a.checkType("NF", "number"); err != nil {
+		return 0, err
+	}
+	v := strings.TrimSpace(a.Value)
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Unable to parse %q of field %s as number", v, a.Field)
+	}
+	return f, nil
+}
+
+// Date returns the value of an attribute of a date field as midnight UTC of
+// that day.
+func (a Attr) Date() (time.Time, error) {
+	if err := a.checkType("D", "date"); err != nil {
+		return time.Time{}, err
+	}
+	v := strings.TrimSpace(a.Value)
+	t, err := time.Parse(dbfDateLayout, v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Unable to parse %q of field %s as date", v, a.Field)
+	}
+	return t, nil
+}
+
+// Bool returns the value of an attribute of a logical field. T, t, Y and y
+// are true, F, f, N and n are false.
+func (a Attr) Bool() (bool, error) {
+	if err := a.checkType("L", "logical"); err != nil {
+		return false, err
+	}
+	switch v := strings.TrimSpace(a.Value); v {
+	case "T", "t", "Y", "y":
+		return true, nil
+	case "F", "f", "N", "n":
+		return false, nil
+	default:
+		return false, fmt.Errorf("Unable to parse %q of field %s as logical", v, a.Field)
+	}
+}
+
+// currentAttr returns the n-th attribute of the shape that sr was last
+// advanced to.
+func currentAttr(sr SequentialReader, n int) (Attr, error) {
+	fields := sr.Fields()
+	if n < 0 || n >= len(fields) {
+		return Attr{}, fmt.Errorf("Field %d out of range [0, %d)", n, len(fields))
+	}
+	v := sr.Attribute(n)
+	bad := false
+	if b, ok := sr.(interface{ rowBad() bool }); ok {
+		bad = b.rowBad()
+	}
+	return Attr{Field: fields[n], Value: v, Null: bad || isNullValue(fields[n], v)}, nil
+}
+
+// AttributeIsNull reports whether the n-th attribute of the shape that sr was
+// last advanced to is NULL. Blank values and values filled with '*' are NULL
+// for all but character fields, as is '?' for logical fields. The attributes
+// of malformed rows and fields out of range are NULL as well.
+func AttributeIsNull(sr SequentialReader, n int) bool {
+	a, err := currentAttr(sr, n)
+	return err != nil || a.Null
+}
+
+// AttributeInt returns the n-th attribute of the shape that sr was last
+// advanced to as an integer. The field must be numeric. ErrNullAttribute is
+// returned for NULL values.
+func AttributeInt(sr SequentialReader, n int) (int64, error) {
+	a, err := currentAttr(sr, n)
+	if err != nil {
+		return 0, err
+	}
+	return a.Int()
+}
+
+// AttributeFloat returns the n-th attribute of the shape that sr was last
+// advanced to as a number. The field must be numeric. ErrNullAttribute is
+// returned for NULL values.
+func AttributeFloat(sr SequentialReader, n int) (float64, error) {
+	a, err := currentAttr(sr, n)
+	if err != nil {
+		return 0, err
+	}
+	return a.Float()
+}
+
+// AttributeDate returns the n-th attribute of the shape that sr was last
+// advanced to as a date. The field must be a date field. ErrNullAttribute is
+// returned for NULL values.
+func AttributeDate(sr SequentialReader, n int) (time.Time, error) {
+	a, err := currentAttr(sr, n)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return a.Date()
+}
+
+// AttributeBool returns the n-th attribute of the shape that sr was last
+// advanced to as a boolean. The field must be logical. ErrNullAttribute is
+// returned for NULL values, including '?'.
+func AttributeBool(sr SequentialReader, n int) (bool, error) {
+	a, err := currentAttr(sr, n)
+	if err != nil {
+		return false, err
+	}
+	return a.Bool()
+}
+
+// AttributeIsNull reports whether the n-th attribute of the current shape is
+// NULL. See the function AttributeIsNull.
+func (r *Reader) AttributeIsNull(n int) bool {
+	return AttributeIsNull(r, n)
+}
+
+// AttributeInt returns the n-th attribute of the current shape as an integer.
+// See the function AttributeInt.
+func (r *Reader) AttributeInt(n int) (int64, error) {
+	return AttributeInt(r, n)
+}
+
+// AttributeFloat returns the n-th attribute of the current shape as a number.
+// See the function AttributeFloat.
+func (r *Reader) AttributeFloat(n int) (float64, error) {
+	return AttributeFloat(r, n)
+}
+
+// AttributeDate returns the n-th attribute of the current shape as a date.
+// See the function AttributeDate.
+func (r *Reader) AttributeDate(n int) (time.Time, error) {
+	return AttributeDate(r, n)
+}
+
+// AttributeTime returns the n-th attribute of the current shape as a date, at
+// midnight UTC. It is the same as AttributeDate.
+func (r *Reader) AttributeTime(n int) (time.Time, error) {
+	return AttributeDate(r, n)
+}
+
+// AttributeBool returns the n-th attribute of the current shape as a boolean.
+// See the function AttributeBool.
+func (r *Reader) AttributeBool(n int) (bool, error) {
+	return AttributeBool(r, n)
+}
+
+// AttributeIsNull reports whether the n-th attribute of the current shape is
+// NULL. See the function AttributeIsNull.
+func (zr *ZipReader) AttributeIsNull(n int) bool {
+	return AttributeIsNull(zr, n)
+}
+
+// AttributeInt returns the n-th attribute of the current shape as an integer.
+// See the function AttributeInt.
+func (zr *ZipReader) AttributeInt(n int) (int64, error) {
+	return AttributeInt(zr, n)
+}
+
+// AttributeFloat returns the n-th attribute of the current shape as a number.
+// See the function AttributeFloat.
+func (zr *ZipReader) AttributeFloat(n int) (float64, error) {
+	return AttributeFloat(zr, n)
+}
+
+// AttributeDate returns the n-th attribute of the current shape as a date.
+// See the function AttributeDate.
+func (zr *ZipReader) AttributeDate(n int) (time.Time, error) {
+	return AttributeDate(zr, n)
+}
+
+// AttributeTime returns the n-th attribute of the current shape as a date, at
+// midnight UTC. It is the same as AttributeDate.
+func (zr *ZipReader) AttributeTime(n int) (time.Time, error) {
+	return AttributeDate(zr, n)
+}
+
+// AttributeBool returns the n-th attribute of the current shape as a boolean.
+// See the function AttributeBool.
+func (zr *ZipReader) AttributeBool(n int) (bool, error) {
+	return AttributeBool(zr, n)
+}
+
+// fieldNumbers returns a map of the names of fields in lower case to their
+// index. Of fields whose names only differ in case, the first one is mapped.
+func fieldNumbers(fields []Field) map[string]int {
+	m := make(map[string]int, len(fields))
+	for i, f := range fields {
+		name := strings.ToLower(f.String())
+		if _, ok := m[name]; !ok {
+			m[name] = i
+		}
+	}
+	return m
+}
+
+// fieldNumber returns the index of the field called name, ignoring case.
+func (r *Reader) fieldNumber(name string) (int, bool) {
+	if r.dbfFieldNumbers == nil {
+		r.dbfFieldNumbers = fieldNumbers(r.Fields())
+	}
+	n, ok := r.dbfFieldNumbers[strings.ToLower(name)]
+	return n, ok
+}
+
+// fieldNumber returns the index of the field called name, ignoring case.
+func (sr *seqReader) fieldNumber(name string) (int, bool) {
+	if sr.dbfFieldNumbers == nil {
+		sr.dbfFieldNumbers = fieldNumbers(sr.Fields())
+	}
+	n, ok := sr.dbfFieldNumbers[strings.ToLower(name)]
+	return n, ok
+}
+
+// fieldNumber returns the index of the field called name, ignoring case.
+func (zr *ZipReader) fieldNumber(name string) (int, bool) {
+	if fn, ok := zr.sr.(interface{ fieldNumber(string) (int, bool) }); ok {
+		return fn.fieldNumber(name)
+	}
+	n := fieldIndex(zr.Fields(), name)
+	return n, n >= 0
+}
+
+// fieldNumberOf returns the index of the field of sr called name, ignoring
+// case, or an error wrapping ErrNoSuchField.
+func fieldNumberOf(sr SequentialReader, name string) (int, error) {
+	var n int
+	ok := false
+	if fn, cached := sr.(interface{ fieldNumber(string) (int, bool) }); cached {
+		n, ok = fn.fieldNumber(name)
+	} else if n = fieldIndex(sr.Fields(), name); n >= 0 {
+		ok = true
+	}
+	if !ok {
+		return -1, fmt.Errorf("Unable to read attribute %s: %w", name, ErrNoSuchField)
+	}
+	return n, nil
+}
+
+// AttributeByName returns the attribute of the field called name, ignoring
+// case, of the shape that sr was last advanced to. Unlike indexes, names keep
+// referring to the same field if the fields of the data change. It returns
+// an error wrapping ErrNoSuchField if there is no such field.
+func AttributeByName(sr SequentialReader, name string) (string, error) {
+	n, err := fieldNumberOf(sr, name)
+	if err != nil {
+		return "", err
+	}
+	return sr.Attribute(n), nil
+}
+
+// AttributeIntByName returns the attribute of the field called name as an
+// integer, see AttributeByName and AttributeInt.
+func AttributeIntByName(sr SequentialReader, name string) (int64, error) {
+	n, err := fieldNumberOf(sr, name)
+	if err != nil {
+		return 0, err
+	}
+	return AttributeInt(sr, n)
+}
+
+// AttributeFloatByName returns the attribute of the field called name as a
+// number, see AttributeByName and AttributeFloat.
+func AttributeFloatByName(sr SequentialReader, name string) (float64, error) {
+	n, err := fieldNumberOf(sr, name)
+	if err != nil {
+		return 0, err
+	}
+	return AttributeFloat(sr, n)
+}
+
+// AttributeDateByName returns the attribute of the field called name as a
+// date, see AttributeByName and AttributeDate.
+func AttributeDateByName(sr SequentialReader, name string) (time.Time, error) {
+	n, err := fieldNumberOf(sr, name)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return AttributeDate(sr, n)
+}
+
+// AttributeBoolByName returns the attribute of the field called name as a
+// boolean, see AttributeByName and AttributeBool.
+func AttributeBoolByName(sr SequentialReader, name string) (bool, error) {
+	n, err := fieldNumberOf(sr, name)
+	if err != nil {
+		return false, err
+	}
+	return AttributeBool(sr, n)
+}
+
+// AttributeByName returns the attribute of the field called name of the
+// current shape. See the function AttributeByName.
+func (r *Reader) AttributeByName(name string) (string, error) {
+	return AttributeByName(r, name)
+}
+
+// AttributeIntByName returns the attribute of the field called name of the
+// current shape as an integer. See the function AttributeIntByName.
+func (r *Reader) AttributeIntByName(name string) (int64, error) {
+	return AttributeIntByName(r, name)
+}
+
+// AttributeFloatByName returns the attribute of the field called name of the
+// current shape as a number. See the function AttributeFloatByName.
+func (r *Reader) AttributeFloatByName(name string) (float64, error) {
+	return AttributeFloatByName(r, name)
+}
+
+// AttributeDateByName returns the attribute of the field called name of the
+// current shape as a date. See the function AttributeDateByName.
+func (r *Reader) AttributeDateByName(name string) (time.Time, error) {
+	return AttributeDateByName(r, name)
+}
+
+// AttributeBoolByName returns the attribute of the field called name of the
+// current shape as a boolean. See the function AttributeBoolByName.
+func (r *Reader) AttributeBoolByName(name string) (bool, error) {
+	return AttributeBoolByName(r, name)
+}
+
+// AttributeByName returns the attribute of the field called name of the
+// current shape. See the function AttributeByName.
+func (zr *ZipReader) AttributeByName(name string) (string, error) {
+	return AttributeByName(zr, name)
+}
+
+// AttributeIntByName returns the attribute of the field called name of the
+// current shape as an integer. See the function AttributeIntByName.
+func (zr *ZipReader) AttributeIntByName(name string) (int64, error) {
+	return AttributeIntByName(zr, name)
+}
+
+// AttributeFloatByName returns the attribute of the field called name of the
+// current shape as a number. See the function AttributeFloatByName.
+func (zr *ZipReader) AttributeFloatByName(name string) (float64, error) {
+	return AttributeFloatByName(zr, name)
+}
+
+// AttributeDateByName returns the attribute of the field called name of the
+// current shape as a date. See the function AttributeDateByName.
+func (zr *ZipReader) AttributeDateByName(name string) (time.Time, error) {
+	return AttributeDateByName(zr, name)
+}
+
+// AttributeBoolByName returns the attribute of the field called name of the
+// current shape as a boolean. See the function AttributeBoolByName.
+func (zr *ZipReader) AttributeBoolByName(name string) (bool, error) {
+	return AttributeBoolByName(zr, name)
+}
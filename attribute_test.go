@@ -0,0 +1,238 @@
+package shp
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// writeTyped writes a shapefile with one field of every type and two
+// records, the second of which has only NULL attributes.
+func writeTyped(t *testing.T, dir string) string {
+	filename := filepath.Join(dir, "typed.shp")
+	w, err := Create(filename, POINT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SetFields([]Field{NumberField("ID", 5), FloatField("AREA", 8, 2), DateField("BUILT"), LogicalField("OPEN"), StringField("NAME", 10)})
+	w.Write(&Point{1, 1})
+	w.WriteAttribute(0, 0, 42)
+	w.WriteAttribute(0, 1, 12.5)
+	w.WriteAttributeDate(0, 2, 1999, 12, 31)
+	w.WriteAttribute(0, 3, "T")
+	w.WriteAttribute(0, 4, "x")
+	w.Write(&Point{2, 2})
+	w.WriteAttribute(1, 3, "?")
+	w.Close()
+	return filename
+}
+
+func TestTypedAttributes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	r, err := Open(writeTyped(t, dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	r.Next()
+	if v, err := r.AttributeInt(0); v != 42 || err != nil {
+		t.Errorf("AttributeInt: got %v, %v", v, err)
+	}
+	if v, err := r.AttributeFloat(1); v != 12.5 || err != nil {
+		t.Errorf("AttributeFloat: got %v, %v", v, err)
+	}
+	if v, err := r.AttributeDate(2); !v.Equal(time.Date(1999, 12, 31, 0, 0, 0, 0, time.UTC)) || err != nil {
+		t.Errorf("AttributeDate: got %v, %v", v, err)
+	}
+	if v, err := r.AttributeTime(2); !v.Equal(time.Date(1999, 12, 31, 0, 0, 0, 0, time.UTC)) || err != nil {
+		t.Errorf("AttributeTime: got %v, %v", v, err)
+	}
+	if v, err := r.AttributeBool(3); !v || err != nil {
+		t.Errorf("AttributeBool: got %v, %v", v, err)
+	}
+	if _, err := r.AttributeInt(1); err == nil {
+		t.Error("expected an error for a fractional integer")
+	}
+	if _, err := r.AttributeInt(4); err == nil {
+		t.Error("expected an error for a character field")
+	}
+	if _, err := r.AttributeDate(5); err == nil {
+		t.Error("expected an error for a field out of range")
+	}
+
+	r.Next()
+	for n, get := range []func(int) error{
+		func(n int) error { _, err := r.AttributeInt(n); return err },
+		func(n int) error { _, err := r.AttributeFloat(n); return err },
+		func(n int) error { _, err := r.AttributeDate(n); return err },
+		func(n int) error { _, err := r.AttributeBool(n); return err },
+	} {
+		if err := get(n); err != ErrNullAttribute {
+			t.Errorf("field %d: got %v, want ErrNullAttribute", n, err)
+		}
+	}
+}
+
+func TestTypedAttributesSequential(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sr, err := OpenDataset(writeTyped(t, dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sr.Close()
+	sr.Next()
+	if v, err := AttributeFloat(sr, 0); v != 42 || err != nil {
+		t.Errorf("AttributeFloat: got %v, %v", v, err)
+	}
+	if v, err := AttributeBool(sr, 3); !v || err != nil {
+		t.Errorf("AttributeBool: got %v, %v", v, err)
+	}
+}
+
+func TestAttributeNull(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "null.shp")
+	w, err := Create(filename, POINT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SetFields([]Field{NumberField("ID", 5), LogicalField("OPEN"), StringField("NAME", 10)})
+	w.Write(&Point{})
+	w.WriteAttribute(0, 0, 12345)
+	w.WriteAttribute(0, 1, "T")
+	w.WriteAttribute(0, 2, "x")
+	// overwrite the values written before
+	w.WriteAttribute(0, 0, nil)
+	if err := w.WriteAttributeNull(0, 1); err != nil {
+		t.Fatal(err)
+	}
+	w.Write(&Point{})
+	w.WriteAttribute(1, 0, 0)
+	w.WriteAttribute(1, 1, "F")
+	if err := w.WriteAttributeNull(1, 3); err == nil {
+		t.Error("expected an error for a field out of range")
+	}
+	w.Close()
+
+	r, err := Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	want := [][]bool{{true, true, false}, {false, false, false}}
+	for i := 0; r.Next(); i++ {
+		for n := range want[i] {
+			if got := r.AttributeIsNull(n); got != want[i][n] {
+				t.Errorf("row %d field %d: got null %v, want %v", i, n, got, want[i][n])
+			}
+		}
+	}
+	if got := r.ReadAttribute(0, 1); got != "?" {
+		t.Errorf("got logical NULL %q, want ?", got)
+	}
+}
+
+func TestAttributeByName(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := writeTyped(t, dir)
+
+	r, err := Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	r.Next()
+	if v, err := r.AttributeByName("name"); v != "x" || err != nil {
+		t.Errorf("AttributeByName: got %q, %v", v, err)
+	}
+	if v, err := r.AttributeIntByName("Id"); v != 42 || err != nil {
+		t.Errorf("AttributeIntByName: got %v, %v", v, err)
+	}
+	if v, err := r.AttributeFloatByName("AREA"); v != 12.5 || err != nil {
+		t.Errorf("AttributeFloatByName: got %v, %v", v, err)
+	}
+	if v, err := r.AttributeDateByName("built"); v != time.Date(1999, 12, 31, 0, 0, 0, 0, time.UTC) || err != nil {
+		t.Errorf("AttributeDateByName: got %v, %v", v, err)
+	}
+	if v, err := r.AttributeBoolByName("open"); !v || err != nil {
+		t.Errorf("AttributeBoolByName: got %v, %v", v, err)
+	}
+	if _, err := r.AttributeByName("missing"); !errors.Is(err, ErrNoSuchField) {
+		t.Errorf("AttributeByName of a missing field: got %v", err)
+	}
+
+	sr, err := OpenDataset(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sr.Close()
+	sr.Next()
+	sr.Next()
+	if v, err := AttributeBoolByName(sr, "OPEN"); err != ErrNullAttribute {
+		t.Errorf("AttributeBoolByName: got %v, %v", v, err)
+	}
+	if v, err := AttributeByName(sr, "Name"); v != "" || err != nil {
+		t.Errorf("AttributeByName: got %q, %v", v, err)
+	}
+}
+
+func TestAttributesRow(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := writeTyped(t, dir)
+	want := []string{"42", "12.50", "19991231", "T", "x"}
+
+	r, err := Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	r.Next()
+	if got := r.Attributes(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Attributes() = %q, want %q", got, want)
+	}
+	if got := r.AttributesMap(); got["NAME"] != "x" || got["ID"] != "42" || len(got) != 5 {
+		t.Errorf("AttributesMap() = %v", got)
+	}
+	r.Next()
+	if got := r.Attributes(); !reflect.DeepEqual(got, []string{"", "", "", "?", ""}) {
+		t.Errorf("Attributes() = %q", got)
+	}
+
+	sr, err := OpenDataset(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sr.Close()
+	sr.Next()
+	if got := Attributes(sr); !reflect.DeepEqual(got, want) {
+		t.Errorf("Attributes(sr) = %q, want %q", got, want)
+	}
+	if got := AttributesMap(sr); got["AREA"] != "12.50" {
+		t.Errorf("AttributesMap(sr) = %v", got)
+	}
+}
@@ -0,0 +1,354 @@
+package shp
+
+import (
+	"bufio"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"os"
+)
+
+// cacheMagic starts every cache file, followed by the cache version.
+const cacheMagic = "GOSHPCACHE"
+
+// cacheVersion is incremented whenever the layout of cacheFile changes.
+const cacheVersion uint16 = 1
+
+// cacheFile is the gob encoded body of a cache file. The shapes are stored
+// column by column: every slice holds the values of all shapes one after
+// another, with the Count slices holding the number of values per shape.
+// This keeps the number of values gob has to handle individually small and
+// allows the decoded shapes to share a few large slices.
+type cacheFile struct {
+	Source       sourceFingerprint
+	GeometryType ShapeType
+	BBox         Box
+	Fields       []Field
+	NumShapes    int
+
+	Types       []ShapeType
+	Boxes       []Box
+	PartCounts  []int32
+	Parts       []int32
+	PartTypes   []int32
+	PointCounts []int32
+	Coords      []float64
+	ZRanges     [][2]float64
+	ZCounts     []int32
+	Z           []float64
+	MRanges     [][2]float64
+	MCounts     []int32
+	M           []float64
+
+	// Attributes holds the attribute rows of all shapes one after another.
+	Attributes []string
+}
+
+// shapeColumns holds the values of a single shape in the form in which they
+// are stored in a cacheFile.
+type shapeColumns struct {
+	t              ShapeType
+	box            Box
+	parts          []int32
+	partTypes      []int32
+	points         []Point
+	zRange, mRange [2]float64
+	z, m           []float64
+}
+
+// columnsOf returns the values of s as stored in a cacheFile.
+func columnsOf(s Shape) (shapeColumns, error) {
+	switch s := s.(type) {
+	case *Null:
+		return shapeColumns{t: NULL}, nil
+	case *Point:
+		return shapeColumns{t: POINT, points: []Point{*s}}, nil
+	case *PointZ:
+		return shapeColumns{t: POINTZ, points: []Point{{s.X, s.Y}}, z: []float64{s.Z}, m: []float64{s.M}}, nil
+	case *PointM:
+		return shapeColumns{t: POINTM, points: []Point{{s.X, s.Y}}, m: []float64{s.M}}, nil
+	case *PolyLine:
+		return shapeColumns{t: POLYLINE, box: s.Box, parts: s.Parts, points: s.Points}, nil
+	case *Polygon:
+		return shapeColumns{t: POLYGON, box: s.Box, parts: s.Parts, points: s.Points}, nil
+	case *MultiPoint:
+		return shapeColumns{t: MULTIPOINT, box: s.Box, points: s.Points}, nil
+	case *PolyLineZ:
+		return shapeColumns{t: POLYLINEZ, box: s.Box, parts: s.Parts, points: s.Points,
+			zRange: s.ZRange, z: s.ZArray, mRange: s.MRange, m: s.MArray}, nil
+	case *PolygonZ:
+		return shapeColumns{t: POLYGONZ, box: s.Box, parts: s.Parts, points: s.Points,
+			zRange: s.ZRange, z: s.ZArray, mRange: s.MRange, m: s.MArray}, nil
+	case *MultiPointZ:
+		return shapeColumns{t: MULTIPOINTZ, box: s.Box, points: s.Points,
+			zRange: s.ZRange, z: s.ZArray, mRange: s.MRange, m: s.MArray}, nil
+	case *PolyLineM:
+		return shapeColumns{t: POLYLINEM, box: s.Box, parts: s.Parts, points: s.Points, mRange: s.MRange, m: s.MArray}, nil
+	case *PolygonM:
+		return shapeColumns{t: POLYGONM, box: s.Box, parts: s.Parts, points: s.Points,
+			zRange: s.ZRange, z: s.ZArray, mRange: s.MRange, m: s.MArray}, nil
+	case *MultiPointM:
+		return shapeColumns{t: MULTIPOINTM, box: s.Box, points: s.Points, mRange: s.MRange, m: s.MArray}, nil
+	case *MultiPatch:
+		return shapeColumns{t: MULTIPATCH, box: s.Box, parts: s.Parts, partTypes: s.PartTypes, points: s.Points,
+			zRange: s.ZRange, z: s.ZArray, mRange: s.MRange, m: s.MArray}, nil
+	default:
+		return shapeColumns{}, fmt.Errorf("Unable to cache shape of type %T", s)
+	}
+}
+
+// add appends the values of a shape to c.
+func (c *cacheFile) add(s shapeColumns) {
+	c.Types = append(c.Types, s.t)
+	c.Boxes = append(c.Boxes, s.box)
+	c.PartCounts = append(c.PartCounts, int32(len(s.parts)))
+	c.Parts = append(c.Parts, s.parts...)
+	if s.t == MULTIPATCH {
+		c.PartTypes = append(c.PartTypes, s.partTypes...)
+	}
+	c.PointCounts = append(c.PointCounts, int32(len(s.points)))
+	for _, p := range s.points {
+		c.Coords = append(c.Coords, p.X, p.Y)
+	}
+	c.ZRanges = append(c.ZRanges, s.zRange)
+	c.ZCounts = append(c.ZCounts, int32(len(s.z)))
+	c.Z = append(c.Z, s.z...)
+	c.MRanges = append(c.MRanges, s.mRange)
+	c.MCounts = append(c.MCounts, int32(len(s.m)))
+	c.M = append(c.M, s.m...)
+}
+
+// cacheCursor walks over the columns of a cacheFile.
+type cacheCursor struct {
+	c                             *cacheFile
+	points                        []Point
+	parts, partTypes, point, z, m int
+	err                           error
+}
+
+// ints returns the next n values of values, which start at *offset.
+func (cc *cacheCursor) ints(values []int32, offset *int, n int32) []int32 {
+	if n < 0 || *offset+int(n) > len(values) {
+		cc.err = fmt.Errorf("Error decoding cache: column too short")
+		return nil
+	}
+	r := values[*offset : *offset+int(n) : *offset+int(n)]
+	*offset += int(n)
+	return r
+}
+
+// floats returns the next n values of values, which start at *offset.
+func (cc *cacheCursor) floats(values []float64, offset *int, n int32) []float64 {
+	if n < 0 || *offset+int(n) > len(values) {
+		cc.err = fmt.Errorf("Error decoding cache: column too short")
+		return nil
+	}
+	r := values[*offset : *offset+int(n) : *offset+int(n)]
+	*offset += int(n)
+	return r
+}
+
+// shape returns the i-th shape of the cache file. The slices of the shape
+// share their backing arrays with the columns but are capped, so appending
+// to them never affects other shapes.
+func (cc *cacheCursor) shape(i int) (Shape, error) {
+	c := cc.c
+	var s shapeColumns
+	s.box = c.Boxes[i]
+	s.parts = cc.ints(c.Parts, &cc.parts, c.PartCounts[i])
+	if c.Types[i] == MULTIPATCH {
+		s.partTypes = cc.ints(c.PartTypes, &cc.partTypes, c.PartCounts[i])
+	}
+	if n := c.PointCounts[i]; n < 0 || cc.point+int(n) > len(cc.points) {
+		cc.err = fmt.Errorf("Error decoding cache: column too short")
+	} else {
+		s.points = cc.points[cc.point : cc.point+int(n) : cc.point+int(n)]
+		cc.point += int(n)
+	}
+	s.zRange, s.mRange = c.ZRanges[i], c.MRanges[i]
+	s.z = cc.floats(c.Z, &cc.z, c.ZCounts[i])
+	s.m = cc.floats(c.M, &cc.m, c.MCounts[i])
+	if cc.err != nil {
+		return nil, cc.err
+	}
+
+	numParts, numPoints := int32(len(s.parts)), int32(len(s.points))
+	first := func(values []float64) float64 {
+		if len(values) > 0 {
+			return values[0]
+		}
+		return 0
+	}
+	switch t := c.Types[i]; t {
+	case NULL:
+		return &Null{}, nil
+	case POINT, POINTZ, POINTM:
+		if len(s.points) != 1 {
+			return nil, fmt.Errorf("Error decoding cache: %v with %d points", t, len(s.points))
+		}
+		p := s.points[0]
+		switch t {
+		case POINTZ:
+			return &PointZ{p.X, p.Y, first(s.z), first(s.m)}, nil
+		case POINTM:
+			return &PointM{p.X, p.Y, first(s.m)}, nil
+		}
+		return &p, nil
+	case POLYLINE:
+		return &PolyLine{s.box, numParts, numPoints, s.parts, s.points}, nil
+	case POLYGON:
+		return &Polygon{s.box, numParts, numPoints, s.parts, s.points}, nil
+	case MULTIPOINT:
+		return &MultiPoint{s.box, numPoints, s.points}, nil
+	case POLYLINEZ:
+		return &PolyLineZ{s.box, numParts, numPoints, s.parts, s.points, s.zRange, s.z, s.mRange, s.m}, nil
+	case POLYGONZ:
+		return &PolygonZ{s.box, numParts, numPoints, s.parts, s.points, s.zRange, s.z, s.mRange, s.m}, nil
+	case MULTIPOINTZ:
+		return &MultiPointZ{s.box, numPoints, s.points, s.zRange, s.z, s.mRange, s.m}, nil
+	case POLYLINEM:
+		return &PolyLineM{s.box, numParts, numPoints, s.parts, s.points, s.mRange, s.m}, nil
+	case POLYGONM:
+		return &PolygonM{s.box, numParts, numPoints, s.parts, s.points, s.zRange, s.z, s.mRange, s.m}, nil
+	case MULTIPOINTM:
+		return &MultiPointM{s.box, numPoints, s.points, s.mRange, s.m}, nil
+	case MULTIPATCH:
+		return &MultiPatch{s.box, numParts, numPoints, s.parts, s.partTypes, s.points, s.zRange, s.z, s.mRange, s.m}, nil
+	default:
+		return nil, fmt.Errorf("Error decoding cache: invalid shape type %v", t)
+	}
+}
+
+// SaveCache writes d to a cache file at path, which can be loaded with
+// LoadCache much faster than the shapefile can be read again. Only datasets
+// read with ReadDataset or LoadCache can be cached, since the cache records
+// the shapefile it belongs to.
+func (d *Dataset) SaveCache(path string) error {
+	if d.source.Path == "" {
+		return fmt.Errorf("Unable to save cache: dataset has no source file")
+	}
+	if len(d.Attributes) != len(d.Shapes) {
+		return fmt.Errorf("Unable to save cache: %d attribute rows for %d shapes", len(d.Attributes), len(d.Shapes))
+	}
+	c := cacheFile{
+		Source:       d.source,
+		GeometryType: d.GeometryType,
+		BBox:         d.BBox,
+		Fields:       d.Fields,
+		NumShapes:    len(d.Shapes),
+		Attributes:   make([]string, 0, len(d.Shapes)*len(d.Fields)),
+	}
+	for i, s := range d.Shapes {
+		cols, err := columnsOf(s)
+		if err != nil {
+			return err
+		}
+		c.add(cols)
+		if len(d.Attributes[i]) != len(d.Fields) {
+			return fmt.Errorf("Unable to save cache: row %d has %d attributes for %d fields", i, len(d.Attributes[i]), len(d.Fields))
+		}
+		c.Attributes = append(c.Attributes, d.Attributes[i]...)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	w.WriteString(cacheMagic)
+	w.Write([]byte{byte(cacheVersion >> 8), byte(cacheVersion)})
+	if err := gob.NewEncoder(w).Encode(&c); err != nil {
+		f.Close()
+		return fmt.Errorf("Error encoding cache: %v", err)
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// LoadCache loads a dataset from a cache file written by SaveCache. It
+// returns an error if the cache file is corrupt, was written by an
+// incompatible version of this package, or if the shapefile it was created
+// from has changed since; callers should then fall back to ReadDataset.
+func LoadCache(path string) (d *Dataset, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+
+	header := make([]byte, len(cacheMagic)+2)
+	if _, err := io.ReadFull(r, header); err != nil || string(header[:len(cacheMagic)]) != cacheMagic {
+		return nil, fmt.Errorf("Error loading cache: %s is not a cache file", path)
+	}
+	if v := uint16(header[len(cacheMagic)])<<8 | uint16(header[len(cacheMagic)+1]); v != cacheVersion {
+		return nil, fmt.Errorf("Error loading cache: unsupported version %d", v)
+	}
+
+	// gob reports malformed input as errors, but guard against panics on
+	// inputs it does not anticipate so that a corrupt cache never takes the
+	// caller down.
+	defer func() {
+		if p := recover(); p != nil {
+			d, err = nil, fmt.Errorf("Error decoding cache: %v", p)
+		}
+	}()
+	var c cacheFile
+	if err := gob.NewDecoder(r).Decode(&c); err != nil {
+		return nil, fmt.Errorf("Error decoding cache: %v", err)
+	}
+
+	source, err := fingerprintSource(c.Source.Path)
+	if err != nil {
+		return nil, fmt.Errorf("Error loading cache: %v", err)
+	}
+	if !source.equal(c.Source) {
+		return nil, fmt.Errorf("Error loading cache: %s has changed", c.Source.Path)
+	}
+	n := c.NumShapes
+	if n < 0 || len(c.Types) != n || len(c.Boxes) != n || len(c.PartCounts) != n || len(c.PointCounts) != n ||
+		len(c.ZRanges) != n || len(c.ZCounts) != n || len(c.MRanges) != n || len(c.MCounts) != n ||
+		len(c.Coords)%2 != 0 || len(c.Attributes) != n*len(c.Fields) {
+		return nil, fmt.Errorf("Error decoding cache: inconsistent columns")
+	}
+
+	d = &Dataset{
+		GeometryType: c.GeometryType,
+		BBox:         c.BBox,
+		Fields:       c.Fields,
+		Shapes:       make([]Shape, n),
+		Attributes:   make([][]string, n),
+		source:       c.Source,
+	}
+	cc := cacheCursor{c: &c, points: make([]Point, len(c.Coords)/2)}
+	for i := range cc.points {
+		cc.points[i] = Point{c.Coords[2*i], c.Coords[2*i+1]}
+	}
+	nf := len(c.Fields)
+	for i := 0; i < n; i++ {
+		if d.Shapes[i], err = cc.shape(i); err != nil {
+			return nil, err
+		}
+		d.Attributes[i] = c.Attributes[i*nf : (i+1)*nf : (i+1)*nf]
+	}
+	return d, nil
+}
+
+// OpenCached loads the shapefile at filename from the cache file at
+// cachePath if it is up to date. Otherwise it reads the shapefile and
+// replaces the cache file.
+func OpenCached(filename, cachePath string) (*Dataset, error) {
+	if d, err := LoadCache(cachePath); err == nil {
+		if source, err := fingerprintSource(filename); err == nil && source.Path == d.source.Path {
+			return d, nil
+		}
+	}
+	d, err := ReadDataset(filename)
+	if err != nil {
+		return nil, err
+	}
+	return d, d.SaveCache(cachePath)
+}
@@ -0,0 +1,169 @@
+package shp
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCacheRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, prefix := range []string{"point", "polyline", "polygon", "multipoint", "pointz", "polylinez",
+		"polygonz", "multipointz", "pointm", "polylinem", "polygonm", "multipointm", "multipatch"} {
+		name := copyShapefile(t, "test_files/"+prefix, dir)
+		d, err := ReadDataset(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cache := filepath.Join(dir, prefix+".cache")
+		if err := d.SaveCache(cache); err != nil {
+			t.Fatalf("%s: %v", prefix, err)
+		}
+		c, err := LoadCache(cache)
+		if err != nil {
+			t.Fatalf("%s: %v", prefix, err)
+		}
+		if !reflect.DeepEqual(d, c) {
+			t.Errorf("%s: cached dataset differs from original", prefix)
+		}
+	}
+}
+
+func TestCacheRejectsChangedSource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := copyShapefile(t, "test_files/point", dir)
+	d, err := ReadDataset(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache := filepath.Join(dir, "point.cache")
+	if err := d.SaveCache(cache); err != nil {
+		t.Fatal(err)
+	}
+
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, "point.dbf"), later, later); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadCache(cache); err == nil {
+		t.Error("expected an error for a changed DBF")
+	}
+	// OpenCached falls back to the shapefile and refreshes the cache.
+	if _, err := OpenCached(name, cache); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadCache(cache); err != nil {
+		t.Errorf("expected refreshed cache to load, got %v", err)
+	}
+}
+
+func TestCacheCorrupt(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := copyShapefile(t, "test_files/polygon", dir)
+	d, err := ReadDataset(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache := filepath.Join(dir, "polygon.cache")
+	if err := d.SaveCache(cache); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	corrupt := map[string][]byte{
+		"empty":     nil,
+		"magic":     append([]byte("NOTACACHE!"), b[len(cacheMagic):]...),
+		"version":   append(append([]byte(cacheMagic), 0xff, 0xff), b[len(cacheMagic)+2:]...),
+		"truncated": b[:len(b)/2],
+	}
+	flipped := append([]byte(nil), b...)
+	for i := len(cacheMagic) + 2; i < len(flipped); i += 7 {
+		flipped[i] ^= 0x5a
+	}
+	corrupt["flipped"] = flipped
+	for n, data := range corrupt {
+		if err := ioutil.WriteFile(cache, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadCache(cache); err == nil {
+			t.Errorf("%s: expected an error", n)
+		}
+		c, err := OpenCached(name, cache)
+		if err != nil {
+			t.Fatalf("%s: %v", n, err)
+		}
+		if !reflect.DeepEqual(d, c) {
+			t.Errorf("%s: dataset read after fallback differs", n)
+		}
+	}
+}
+
+// createBenchmarkDataset writes a shapefile with n polylines to dir.
+func createBenchmarkDataset(b *testing.B, dir string, n int) string {
+	name := filepath.Join(dir, "bench.shp")
+	w, err := Create(name, POLYLINE)
+	if err != nil {
+		b.Fatal(err)
+	}
+	w.SetFields([]Field{StringField("NAME", 16), NumberField("ID", 10)})
+	for i := 0; i < n; i++ {
+		f := float64(i)
+		w.Write(NewPolyLine([][]Point{{{f, f}, {f + 1, f}, {f + 1, f + 1}, {f, f + 1}}}))
+		w.WriteAttribute(i, 0, fmt.Sprintf("line %d", i))
+		w.WriteAttribute(i, 1, i)
+	}
+	w.Close()
+	return name
+}
+
+func BenchmarkDatasetLoad(b *testing.B) {
+	dir, err := ioutil.TempDir("", "go-shp-bench")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := createBenchmarkDataset(b, dir, 20000)
+	cache := filepath.Join(dir, "bench.cache")
+	d, err := ReadDataset(name)
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := d.SaveCache(cache); err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+
+	b.Run("ReadDataset", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := ReadDataset(name); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("LoadCache", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := LoadCache(cache); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
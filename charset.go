@@ -0,0 +1,245 @@
+package shp
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/korean"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// codePages are the code pages that are known by their number in .cpg files
+// and by their language driver ID (LDID), which is stored at offset 29 of the
+// DBF header.
+var codePages = []struct {
+	page int
+	ldid byte
+	enc  encoding.Encoding
+}{
+	{437, 0x01, charmap.CodePage437},
+	{850, 0x02, charmap.CodePage850},
+	{1252, 0x03, charmap.Windows1252},
+	{1252, 0x57, charmap.Windows1252},
+	{932, 0x13, japanese.ShiftJIS},
+	{936, 0x4d, simplifiedchinese.GBK},
+	{949, 0x4e, korean.EUCKR},
+	{950, 0x4f, traditionalchinese.Big5},
+	{852, 0x64, charmap.CodePage852},
+	{866, 0x65, charmap.CodePage866},
+	{865, 0x66, charmap.CodePage865},
+	{874, 0x7c, charmap.Windows874},
+	{1255, 0x7d, charmap.Windows1255},
+	{1256, 0x7e, charmap.Windows1256},
+	{1250, 0xc8, charmap.Windows1250},
+	{1251, 0xc9, charmap.Windows1251},
+	{1254, 0xca, charmap.Windows1254},
+	{1253, 0xcb, charmap.Windows1253},
+	{1257, 0xcc, charmap.Windows1257},
+	{855, 0, charmap.CodePage855},
+	{858, 0, charmap.CodePage858},
+	{860, 0, charmap.CodePage860},
+	{862, 0, charmap.CodePage862},
+	{863, 0, charmap.CodePage863},
+	{1258, 0, charmap.Windows1258},
+	{37, 0, charmap.CodePage037},
+	{1047, 0, charmap.CodePage1047},
+	{1140, 0, charmap.CodePage1140},
+	{65001, 0, unicode.UTF8},
+}
+
+// isoCharsets are the ISO 8859 charsets that the WHATWG encoding names of
+// htmlindex map to Windows code pages, so that they cannot be named by it.
+var isoCharsets = []struct {
+	part int
+	enc  encoding.Encoding
+}{
+	{1, charmap.ISO8859_1},
+	{9, charmap.ISO8859_9},
+	{6, charmap.ISO8859_6E},
+	{6, charmap.ISO8859_6I},
+	{8, charmap.ISO8859_8E},
+}
+
+// charsetFromLDID returns the encoding for the language driver ID of a DBF,
+// or nil if it is unknown.
+func charsetFromLDID(ldid byte) encoding.Encoding {
+	if ldid == 0 {
+		return nil
+	}
+	for _, cp := range codePages {
+		if cp.ldid == ldid {
+			return cp.enc
+		}
+	}
+	return nil
+}
+
+// ParseCPG returns the encoding named by the content of a .cpg file, which is
+// either a code page number such as 1252 or 932, optionally prefixed by
+// "ANSI" or "CP", or the name of an encoding such as UTF-8, ISO-8859-1 or
+// Shift_JIS.
+func ParseCPG(cpg string) (encoding.Encoding, error) {
+	name := strings.TrimSpace(strings.TrimPrefix(cpg, "\ufeff"))
+	number := strings.ToUpper(name)
+	for _, prefix := range []string{"ANSI", "CP", "WINDOWS-"} {
+		number = strings.TrimSpace(strings.TrimPrefix(number, prefix))
+	}
+	if strings.HasPrefix(number, "8859") {
+		// ESRI writes e.g. 88591 for ISO-8859-1
+		name = "iso-8859-" + strings.TrimLeft(number[4:], "_-")
+	} else if page, err := strconv.Atoi(number); err == nil {
+		for _, cp := range codePages {
+			if cp.page == page {
+				return cp.enc, nil
+			}
+		}
+	}
+	enc, err := htmlindex.Get(name)
+	if err != nil {
+		return nil, fmt.Errorf("Unknown code page %q", name)
+	}
+	return enc, nil
+}
+
+// cpgName returns the content of a .cpg file for enc: its code page number
+// if it has one, UTF-8, or else its name, e.g. ISO-8859-1.
+func cpgName(enc encoding.Encoding) (string, error) {
+	if enc == unicode.UTF8 {
+		return "UTF-8", nil
+	}
+	for _, cp := range codePages {
+		if cp.enc == enc {
+			return strconv.Itoa(cp.page), nil
+		}
+	}
+	for _, iso := range isoCharsets {
+		if iso.enc == enc {
+			return fmt.Sprintf("ISO-8859-%d", iso.part), nil
+		}
+	}
+	name, err := htmlindex.Name(enc)
+	if err != nil {
+		return "", fmt.Errorf("Unable to name encoding for .cpg file: %v", err)
+	}
+	return name, nil
+}
+
+// ldidFor returns the language driver ID of enc, or 0 if it has none.
+func ldidFor(enc encoding.Encoding) byte {
+	for _, cp := range codePages {
+		if cp.enc == enc {
+			return cp.ldid
+		}
+	}
+	return 0
+}
+
+// detectCharset returns the encoding of the attributes of a DBF, as declared
+// by the content of its .cpg file or else by its language driver ID. It
+// returns nil if neither is known, in which case attributes are not
+// transcoded.
+func detectCharset(cpg string, ldid byte) encoding.Encoding {
+	if strings.TrimSpace(cpg) != "" {
+		if enc, err := ParseCPG(cpg); err == nil {
+			return enc
+		}
+	}
+	return charsetFromLDID(ldid)
+}
+
+// decodeAttr converts the attribute value s from enc to UTF-8. Values are
+// returned unchanged if enc is nil or UTF-8.
+func decodeAttr(enc encoding.Encoding, s string) string {
+	if enc == nil || enc == unicode.UTF8 {
+		return s
+	}
+	if d, err := enc.NewDecoder().String(s); err == nil {
+		return d
+	}
+	return s
+}
+
+// readCompanion returns the content of the file of the dataset base with the
+// given extension, which is found regardless of its case, or the empty string
+// if there is no such file.
+func readCompanion(base, ext string) string {
+	b, err := ioutil.ReadFile(base + ext)
+	if os.IsNotExist(err) {
+		if files, derr := datasetFiles(base); derr == nil && files[ext] != "" {
+			b, err = ioutil.ReadFile(files[ext])
+		}
+	}
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+// SetCharset makes the reader convert attributes from enc to UTF-8, which
+// overrides the encoding declared by the .cpg file or the DBF header. A nil
+// enc returns the attributes as they are stored.
+func (r *Reader) SetCharset(enc encoding.Encoding) {
+	r.openDbf()
+	r.charset = enc
+}
+
+// Charset returns the encoding that attributes are converted from, or nil if
+// they are returned as they are stored.
+func (r *Reader) Charset() encoding.Encoding {
+	r.openDbf()
+	return r.charset
+}
+
+// SetCharset makes the reader convert attributes from enc to UTF-8, which
+// overrides the encoding declared by the .cpg file or the DBF header. A nil
+// enc returns the attributes as they are stored.
+func (zr *ZipReader) SetCharset(enc encoding.Encoding) {
+	if sr, ok := zr.sr.(*seqReader); ok {
+		sr.charset = enc
+	}
+}
+
+// Charset returns the encoding that attributes are converted from, or nil if
+// they are returned as they are stored.
+func (zr *ZipReader) Charset() encoding.Encoding {
+	if sr, ok := zr.sr.(*seqReader); ok {
+		return sr.charset
+	}
+	return nil
+}
+
+// SetCharset makes the writer convert string attributes from UTF-8 to enc,
+// writes the .cpg file that declares enc and records it in the DBF header. It
+// must be called before SetFields.
+func (w *Writer) SetCharset(enc encoding.Encoding) error {
+	if w.dbf != nil {
+		return errors.New("Cannot set charset after SetFields")
+	}
+	name, err := cpgName(enc)
+	if err != nil {
+		return err
+	}
+	f, err := w.createFile(".cpg")
+	if err != nil {
+		return fmt.Errorf("Unable to create code page file: %v", err)
+	}
+	_, err = f.Write([]byte(name))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	w.charset = enc
+	return nil
+}
@@ -0,0 +1,147 @@
+package shp
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+func TestParseCPG(t *testing.T) {
+	for cpg, want := range map[string]encoding.Encoding{
+		"UTF-8":         unicode.UTF8,
+		"\ufeffutf-8\n": unicode.UTF8,
+		"1252":          charmap.Windows1252,
+		"ANSI 1252":     charmap.Windows1252,
+		"CP936":         simplifiedchinese.GBK,
+		"932":           japanese.ShiftJIS,
+		"Shift_JIS":     japanese.ShiftJIS,
+		"88591":         charmap.Windows1252,
+		"8859_5":        charmap.ISO8859_5,
+	} {
+		got, err := ParseCPG(cpg)
+		if err != nil {
+			t.Errorf("%q: %v", cpg, err)
+		} else if got != want {
+			t.Errorf("%q: got %v, want %v", cpg, got, want)
+		}
+	}
+	if _, err := ParseCPG("no such code page"); err == nil {
+		t.Error("expected an error for an unknown code page")
+	}
+}
+
+func TestCharset(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "tokyo.shp")
+	w, err := Create(filename, POINT, WithCharset(japanese.ShiftJIS))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SetFields([]Field{StringField("NAME", 10)})
+	w.Write(&Point{})
+	if err := w.WriteAttribute(0, 0, "東京都"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteAttribute(0, 0, "Ω €"); err == nil {
+		t.Error("expected an error for characters that cannot be encoded")
+	}
+	w.Close()
+
+	if b, _ := ioutil.ReadFile(filepath.Join(dir, "tokyo.cpg")); string(b) != "932" {
+		t.Errorf("got .cpg %q, want 932", b)
+	}
+	read := func(opts ...Option) string {
+		r, err := Open(filename, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+		return r.ReadAttribute(0, 0)
+	}
+	if got := read(); got != "東京都" {
+		t.Errorf("got %q from .cpg", got)
+	}
+	if got := read(WithoutCharsetDetection()); len(got) != 6 {
+		t.Errorf("got %q without detection, want 6 bytes of Shift-JIS", got)
+	}
+	// the language driver ID is used without a .cpg file
+	os.Remove(filepath.Join(dir, "tokyo.cpg"))
+	if got := read(); got != "東京都" {
+		t.Errorf("got %q from language driver ID", got)
+	}
+	r, err := Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	r.SetCharset(nil)
+	if got := r.ReadAttribute(0, 0); got == "東京都" {
+		t.Error("expected raw bytes after SetCharset(nil)")
+	}
+}
+
+func TestCharsetZip(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := CreateZipWriter(&buf, "cities", POINT, WithCharset(charmap.Windows1252))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SetFields([]Field{StringField("NAME", 10)})
+	w.Write(&Point{})
+	w.WriteAttribute(0, 0, "Zürich")
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := OpenZipReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+	if zr.Charset() != charmap.Windows1252 {
+		t.Errorf("got charset %v, want Windows 1252", zr.Charset())
+	}
+	zr.Next()
+	if got := zr.Attribute(0); got != "Zürich" {
+		t.Errorf("got %q, want Zürich", got)
+	}
+}
+
+func TestCPGNames(t *testing.T) {
+	for _, enc := range charmap.All {
+		name, err := cpgName(enc)
+		if err != nil {
+			t.Errorf("%v: %v", enc, err)
+			continue
+		}
+		if _, err := ParseCPG(name); err != nil {
+			t.Errorf("%v: %v", enc, err)
+		}
+	}
+
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	w, err := Create(filepath.Join(dir, "latin1.shp"), POINT, WithCharset(charmap.ISO8859_1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	if cpg, err := ioutil.ReadFile(filepath.Join(dir, "latin1.cpg")); err != nil || string(cpg) != "ISO-8859-1" {
+		t.Errorf("got .cpg %q, %v", cpg, err)
+	}
+}
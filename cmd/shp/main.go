@@ -0,0 +1,481 @@
+// Command shp inspects and converts shapefiles. Every subcommand is a thin
+// wrapper around the corresponding feature of the shp package.
+//
+// Usage:
+//
+//	shp info [-crscheck] [-lenient] file.shp
+//	shp validate [-swapxy] file.shp
+//	shp cat [-format geojson|csv] [-geometry wkt|geojson|none] [-xy] [-lenient] [-swapxy] file.shp
+//	shp create [-x column] [-y column] [-comma c] src.geojson|src.csv dst.shp
+//	shp zip file.shp dst.zip
+//	shp convert [-sort field] [-numeric] [-swapxy] [-lenient] [-nom] src.shp dst.shp
+//	shp merge [-lenient] [-swapxy] dst.shp src.shp...
+//	shp split -field name|-grid rowsxcols [-lenient] [-swapxy] src.shp dstdir
+//	shp reindex [-qix] file.shp
+//
+// tojson and fromjson are the former names of cat and create for GeoJSON.
+package main
+
+import (
+	"archive/zip"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	shp "github.com/silbinarywolf/go-shp"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// commands maps the name of every subcommand to its implementation.
+var commands = map[string]func(args []string, stdout io.Writer) error{
+	"info":     info,
+	"cat":      cat,
+	"create":   create,
+	"zip":      zipFiles,
+	"tojson":   toJSON,
+	"fromjson": fromJSON,
+	"convert":  convert,
+	"validate": validate,
+	"merge":    merge,
+	"split":    split,
+	"reindex":  reindex,
+}
+
+// run executes the subcommand given by args and returns the exit code.
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 || commands[args[0]] == nil {
+		fmt.Fprintln(stderr, "usage: shp info|validate|cat|create|zip|convert|merge|split|reindex [flags] file...")
+		return 2
+	}
+	if err := commands[args[0]](args[1:], stdout); err != nil {
+		fmt.Fprintf(stderr, "shp %s: %v\n", args[0], err)
+		return 1
+	}
+	return 0
+}
+
+// readerFlags registers the flags that map to reader options.
+type readerFlags struct {
+	lenient, swapXY, crsCheck *bool
+}
+
+func newReaderFlags(fs *flag.FlagSet, crsCheck bool) *readerFlags {
+	f := &readerFlags{
+		lenient: fs.Bool("lenient", false, "skip records that cannot be decoded"),
+		swapXY:  fs.Bool("swapxy", false, "swap X and Y coordinates"),
+	}
+	if crsCheck {
+		f.crsCheck = fs.Bool("crscheck", false, "compare the extent with the units of the .prj file")
+	}
+	return f
+}
+
+func (f *readerFlags) options() []shp.Option {
+	var opts []shp.Option
+	if *f.lenient {
+		opts = append(opts, shp.WithLenient())
+	}
+	if *f.swapXY {
+		opts = append(opts, shp.WithSwapXY())
+	}
+	if f.crsCheck != nil && *f.crsCheck {
+		opts = append(opts, shp.WithCRSCheck())
+	}
+	return opts
+}
+
+// parse parses args with fs and returns the n positional arguments.
+func parse(fs *flag.FlagSet, args []string, n int) ([]string, error) {
+	fs.SetOutput(ioutil.Discard)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() != n {
+		return nil, fmt.Errorf("expected %d file arguments, got %d", n, fs.NArg())
+	}
+	return fs.Args(), nil
+}
+
+// info prints the header, the schema and the number of records.
+func info(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("info", flag.ContinueOnError)
+	rf := newReaderFlags(fs, true)
+	files, err := parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	r, err := shp.Open(files[0], rf.options()...)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	h := r.Header()
+	fmt.Fprintf(stdout, "Geometry type: %v\n", h.GeometryType)
+	fmt.Fprintf(stdout, "File length: %d\n", h.FileLength)
+	fmt.Fprintf(stdout, "Bounding box: %v %v %v %v\n", h.BBox.MinX, h.BBox.MinY, h.BBox.MaxX, h.BBox.MaxY)
+	fmt.Fprintf(stdout, "Z range: %v %v\n", h.ZRange[0], h.ZRange[1])
+	fmt.Fprintf(stdout, "M range: %v %v\n", h.MRange[0], h.MRange[1])
+	fields := r.Fields()
+	fmt.Fprintf(stdout, "Fields: %d\n", len(fields))
+	for _, f := range fields {
+		fmt.Fprintf(stdout, "  %s %c %d %d\n", f, f.Fieldtype, f.Size, f.Precision)
+	}
+	n := 0
+	for r.Next() {
+		n++
+	}
+	if err := r.Err(); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Records: %d\n", n)
+	fmt.Fprintf(stdout, "Attribute rows: %d\n", r.AttributeCount())
+	for _, w := range r.Warnings() {
+		fmt.Fprintf(stdout, "Warning: %v\n", w)
+	}
+	for _, e := range r.RecordErrors() {
+		fmt.Fprintf(stdout, "Record error: %v\n", e)
+	}
+	return nil
+}
+
+// toJSON streams the features as a GeoJSON FeatureCollection.
+func toJSON(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("tojson", flag.ContinueOnError)
+	rf := newReaderFlags(fs, false)
+	files, err := parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	r, err := shp.Open(files[0], rf.options()...)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return shp.NewGeoJSONEncoder(stdout).Encode(r)
+}
+
+// cat writes the features as a GeoJSON FeatureCollection or as CSV.
+func cat(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("cat", flag.ContinueOnError)
+	rf := newReaderFlags(fs, false)
+	format := fs.String("format", "geojson", "output format, geojson or csv")
+	geometry := fs.String("geometry", "wkt", "geometry column of CSV, wkt, geojson or none")
+	xy := fs.Bool("xy", false, "add X and Y columns with the coordinates of points to CSV")
+	files, err := parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	csvOpts := shp.CSVOptions{XY: *xy}
+	switch *format {
+	case "geojson":
+	case "csv":
+		geometries := map[string]shp.CSVGeometry{"wkt": shp.CSVWKT, "geojson": shp.CSVGeoJSON, "none": shp.CSVNoGeometry}
+		g, ok := geometries[*geometry]
+		if !ok {
+			return fmt.Errorf("unknown geometry column format %q", *geometry)
+		}
+		csvOpts.Geometry = g
+	default:
+		return fmt.Errorf("unknown output format %q", *format)
+	}
+	r, err := shp.Open(files[0], rf.options()...)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	if *format == "csv" {
+		return shp.WriteCSV(stdout, r, csvOpts)
+	}
+	return shp.NewGeoJSONEncoder(stdout).Encode(r)
+}
+
+// create writes the features of a GeoJSON file or the points of a CSV file
+// as a shapefile. CSV files are recognized by their extension.
+func create(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("create", flag.ContinueOnError)
+	x := fs.String("x", "x", "column of CSV with the X coordinates")
+	y := fs.String("y", "y", "column of CSV with the Y coordinates")
+	comma := fs.String("comma", ",", "field delimiter of CSV")
+	files, err := parse(fs, args, 2)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(filepath.Ext(files[0]), ".csv") {
+		return fromJSON(files, stdout)
+	}
+	c, size := utf8.DecodeRuneInString(*comma)
+	if size == 0 || size != len(*comma) {
+		return fmt.Errorf("invalid field delimiter %q", *comma)
+	}
+	f, err := os.Open(files[0])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	d, err := shp.FromCSV(f, *x, *y, shp.CSVOptions{Comma: c})
+	if err != nil {
+		return err
+	}
+	return d.Save(files[1])
+}
+
+// zipFiles packages a shapefile and all files next to it with the same base
+// name, such as the .shx, .dbf, .prj and .cpg files, into a ZIP archive.
+func zipFiles(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("zip", flag.ContinueOnError)
+	files, err := parse(fs, args, 2)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(files[0])
+	base := strings.TrimSuffix(filepath.Base(files[0]), filepath.Ext(files[0]))
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	var names []string
+	for _, fi := range infos {
+		name := fi.Name()
+		if fi.Mode().IsRegular() && strings.HasPrefix(name, base+".") && filepath.Join(dir, name) != filepath.Clean(files[1]) {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("no files called %s.*", filepath.Join(dir, base))
+	}
+
+	out, err := os.Create(files[1])
+	if err != nil {
+		return err
+	}
+	zw := zip.NewWriter(out)
+	for _, name := range names {
+		if err = addToZip(zw, filepath.Join(dir, name), name); err != nil {
+			break
+		}
+	}
+	if cerr := zw.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(files[1])
+	}
+	return err
+}
+
+// addToZip copies the file at path into zw as name.
+func addToZip(zw *zip.Writer, path, name string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// fromJSON writes the features of a GeoJSON file as a shapefile.
+func fromJSON(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("fromjson", flag.ContinueOnError)
+	files, err := parse(fs, args, 2)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(files[0])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	d, err := shp.FromGeoJSON(f)
+	if err != nil {
+		return err
+	}
+	return d.Save(files[1])
+}
+
+// convert copies a shapefile, optionally sorted by a field.
+func convert(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("convert", flag.ContinueOnError)
+	rf := newReaderFlags(fs, false)
+	sortBy := fs.String("sort", "", "sort the records by this field")
+	numeric := fs.Bool("numeric", false, "sort numerically")
+	noM := fs.Bool("nom", false, "omit the measures of Z shapes")
+	files, err := parse(fs, args, 2)
+	if err != nil {
+		return err
+	}
+	opts := rf.options()
+	if *sortBy != "" {
+		opts = append(opts, shp.WithSortBy(*sortBy, *numeric))
+	}
+	if *noM {
+		opts = append(opts, shp.WithoutMeasures())
+	}
+	return shp.Convert(files[0], files[1], opts...)
+}
+
+// validate prints the issues that shp.Validate finds and fails if there are
+// any.
+func validate(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	swapXY := fs.Bool("swapxy", false, "swap X and Y coordinates")
+	files, err := parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	var opts []shp.Option
+	if *swapXY {
+		opts = append(opts, shp.WithSwapXY())
+	}
+	r, err := shp.Open(files[0], opts...)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	issues, err := shp.Validate(r)
+	if err != nil {
+		return err
+	}
+	for _, issue := range issues {
+		if issue.RecordNum > 0 {
+			fmt.Fprintf(stdout, "record %d: %v\n", issue.RecordNum, issue)
+		} else {
+			fmt.Fprintln(stdout, issue)
+		}
+	}
+	if len(issues) > 0 {
+		return fmt.Errorf("%d issues", len(issues))
+	}
+	return nil
+}
+
+// merge writes the records of all sources to a new shapefile with the shape
+// type of the first source, see shp.Merge.
+func merge(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("merge", flag.ContinueOnError)
+	rf := newReaderFlags(fs, false)
+	fs.SetOutput(ioutil.Discard)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() < 2 {
+		return fmt.Errorf("expected a destination and at least one source, got %d file arguments", fs.NArg())
+	}
+	var srcs []shp.SequentialReader
+	var t shp.ShapeType
+	for i, name := range fs.Args()[1:] {
+		r, err := shp.Open(name, rf.options()...)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		if i == 0 {
+			t = r.GeometryType
+		}
+		srcs = append(srcs, r)
+	}
+	w, err := shp.Create(fs.Arg(0), t)
+	if err != nil {
+		return err
+	}
+	err = shp.Merge(w, srcs...)
+	w.Close()
+	return err
+}
+
+// split writes the records into one shapefile in a directory for every value
+// of a field or for every cell of a grid, see shp.SplitByAttribute and
+// shp.SplitByGrid. The shapefiles are named after the values or cells.
+func split(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("split", flag.ContinueOnError)
+	rf := newReaderFlags(fs, false)
+	field := fs.String("field", "", "split by the values of this field")
+	grid := fs.String("grid", "", "split by a grid of rows by columns cells, e.g. 2x3")
+	files, err := parse(fs, args, 2)
+	if err != nil {
+		return err
+	}
+	if (*field == "") == (*grid == "") {
+		return fmt.Errorf("expected either -field or -grid")
+	}
+	r, err := shp.Open(files[0], rf.options()...)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	if err := os.MkdirAll(files[1], 0755); err != nil {
+		return err
+	}
+	create := func(key string) (*shp.Writer, error) {
+		name := strings.NewReplacer("/", "_", "\\", "_").Replace(key)
+		if name == "" {
+			name = "_"
+		}
+		return shp.Create(filepath.Join(files[1], name+".shp"), r.GeometryType)
+	}
+	var writers map[string]*shp.Writer
+	if *field != "" {
+		writers, err = shp.SplitByAttribute(r, *field, create)
+	} else {
+		rows, cols, ok := parseGrid(*grid)
+		if !ok {
+			return fmt.Errorf("invalid grid %q", *grid)
+		}
+		writers, err = shp.SplitByGrid(r, rows, cols, create)
+	}
+	for _, w := range writers {
+		w.Close()
+	}
+	return err
+}
+
+// parseGrid parses a grid of rows by columns cells such as 2x3.
+func parseGrid(s string) (rows, cols int, ok bool) {
+	i := strings.IndexByte(s, 'x')
+	if i < 0 {
+		return 0, 0, false
+	}
+	rows, err1 := strconv.Atoi(s[:i])
+	cols, err2 := strconv.Atoi(s[i+1:])
+	return rows, cols, err1 == nil && err2 == nil
+}
+
+// reindex rebuilds the .shx of a shapefile from its records, see
+// shp.RebuildSHX, and with -qix also writes its .qix spatial index.
+func reindex(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("reindex", flag.ContinueOnError)
+	qix := fs.Bool("qix", false, "also write a .qix spatial index")
+	files, err := parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	if err := shp.RebuildSHX(files[0]); err != nil {
+		return err
+	}
+	if !*qix {
+		return nil
+	}
+	r, err := shp.Open(files[0])
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return shp.WriteQIX(strings.TrimSuffix(files[0], filepath.Ext(files[0]))+".qix", r)
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	shp "github.com/silbinarywolf/go-shp"
+)
+
+func runCommand(t *testing.T, args ...string) (string, int) {
+	var stdout, stderr bytes.Buffer
+	code := run(args, &stdout, &stderr)
+	if code != 0 {
+		t.Logf("stderr: %s", stderr.String())
+	}
+	return stdout.String(), code
+}
+
+func TestInfo(t *testing.T) {
+	out, code := runCommand(t, "info", "../../test_files/polygon.shp")
+	if code != 0 {
+		t.Fatalf("exit code %d", code)
+	}
+	for _, want := range []string{"Geometry type: POLYGON\n", "Records: 1\n", "Attribute rows: 1\n", "polygon_ID N 5 0\n"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output does not contain %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestToJSON(t *testing.T) {
+	out, code := runCommand(t, "tojson", "../../test_files/point.shp")
+	if code != 0 {
+		t.Fatalf("exit code %d", code)
+	}
+	var fc struct {
+		Type     string
+		Features []struct {
+			Geometry struct {
+				Type        string
+				Coordinates []float64
+			}
+		}
+	}
+	if err := json.Unmarshal([]byte(out), &fc); err != nil {
+		t.Fatal(err)
+	}
+	if fc.Type != "FeatureCollection" || len(fc.Features) != 3 {
+		t.Fatalf("got %s with %d features", fc.Type, len(fc.Features))
+	}
+	if g := fc.Features[1].Geometry; g.Type != "Point" || len(g.Coordinates) != 2 || g.Coordinates[0] != 5 {
+		t.Errorf("unexpected geometry %+v", g)
+	}
+}
+
+func TestFromJSON(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-cmd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	src := filepath.Join(dir, "in.geojson")
+	dst := filepath.Join(dir, "out.shp")
+	ioutil.WriteFile(src, []byte(`{"type": "FeatureCollection", "features": [
+		{"type": "Feature", "geometry": {"type": "Point", "coordinates": [1, 2]}, "properties": {"NAME": "Main"}}]}`), 0644)
+	if _, code := runCommand(t, "fromjson", src, dst); code != 0 {
+		t.Fatalf("exit code %d", code)
+	}
+	r, err := shp.Open(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if !r.Next() || r.Attribute(0) != "Main" {
+		t.Errorf("unexpected output")
+	}
+}
+
+func TestConvert(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-cmd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	dst := filepath.Join(dir, "out.shp")
+	if _, code := runCommand(t, "convert", "-swapxy", "../../test_files/polyline.shp", dst); code != 0 {
+		t.Fatalf("exit code %d", code)
+	}
+	r, err := shp.Open(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	src, err := shp.Open("../../test_files/polyline.shp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+	for src.Next() {
+		if !r.Next() {
+			t.Fatal("missing records")
+		}
+		_, a := src.Shape()
+		_, b := r.Shape()
+		if b.BBox() != shp.SwapXY(a).BBox() {
+			t.Errorf("got %v, want swapped %v", b.BBox(), a.BBox())
+		}
+	}
+}
+
+func TestCatCSV(t *testing.T) {
+	out, code := runCommand(t, "cat", "-format", "csv", "-xy", "../../test_files/point.shp")
+	if code != 0 {
+		t.Fatalf("exit code %d", code)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 4 || !strings.HasSuffix(lines[0], ",X,Y,geometry") || !strings.HasSuffix(lines[2], ",5,5,POINT (5 5)") {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+}
+
+func TestCreateCSV(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-cmd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	src := filepath.Join(dir, "in.csv")
+	dst := filepath.Join(dir, "out.shp")
+	ioutil.WriteFile(src, []byte("NAME;lon;lat\nMain;1;2\n"), 0644)
+	if _, code := runCommand(t, "create", "-x", "lon", "-y", "lat", "-comma", ";", src, dst); code != 0 {
+		t.Fatalf("exit code %d", code)
+	}
+	r, err := shp.Open(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if !r.Next() || r.Attribute(0) != "Main" {
+		t.Fatalf("unexpected output")
+	}
+	if _, s := r.Shape(); s.BBox() != (shp.Box{MinX: 1, MinY: 2, MaxX: 1, MaxY: 2}) {
+		t.Errorf("got %v", s.BBox())
+	}
+}
+
+func TestZip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-cmd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	dst := filepath.Join(dir, "point.zip")
+	if _, code := runCommand(t, "zip", "../../test_files/point.shp", dst); code != 0 {
+		t.Fatalf("exit code %d", code)
+	}
+	zr, err := shp.OpenZip(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+	n := 0
+	for zr.Next() {
+		n++
+	}
+	if n != 3 || zr.Err() != nil {
+		t.Errorf("got %d records, error %v", n, zr.Err())
+	}
+}
+
+func TestUsage(t *testing.T) {
+	for _, args := range [][]string{nil, {"bogus"}, {"info"}, {"info", "-nope", "x.shp"}} {
+		if _, code := runCommand(t, args...); code == 0 {
+			t.Errorf("%q: expected non-zero exit code", args)
+		}
+	}
+	if _, code := runCommand(t, "info", "does-not-exist.shp"); code != 1 {
+		t.Errorf("expected exit code 1 for a missing file, got %d", code)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if out, code := runCommand(t, "validate", "../../test_files/polygon.shp"); code != 0 || out != "" {
+		t.Errorf("got exit code %d and output %q for a valid file", code, out)
+	}
+
+	dir, err := ioutil.TempDir("", "go-shp-cmd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "ccw.shp")
+	w, err := shp.Create(name, shp.POLYGON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a counterclockwise outer ring
+	ring := []shp.Point{{X: 0, Y: 0}, {X: 1, Y: 0}, {X: 1, Y: 1}, {X: 0, Y: 0}}
+	w.Write(&shp.Polygon{Box: shp.BBoxFromPoints(ring), NumParts: 1, NumPoints: 4, Parts: []int32{0}, Points: ring})
+	w.Close()
+	out, code := runCommand(t, "validate", name)
+	if code != 1 || !strings.HasPrefix(out, "record 1: ") {
+		t.Errorf("got exit code %d and output %q", code, out)
+	}
+}
+
+// countRecords returns the number of records of the shapefile at name.
+func countRecords(t *testing.T, name string) int {
+	r, err := shp.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	n := 0
+	for r.Next() {
+		n++
+	}
+	if err := r.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestMerge(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-cmd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	dst := filepath.Join(dir, "merged.shp")
+	if _, code := runCommand(t, "merge", dst, "../../test_files/point.shp", "../../test_files/point.shp"); code != 0 {
+		t.Fatalf("exit code %d", code)
+	}
+	if n := countRecords(t, dst); n != 6 {
+		t.Errorf("got %d records, want 6", n)
+	}
+	if _, code := runCommand(t, "merge", dst); code != 1 {
+		t.Errorf("expected exit code 1 without sources, got %d", code)
+	}
+}
+
+func TestSplit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-cmd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	grid := filepath.Join(dir, "grid")
+	if _, code := runCommand(t, "split", "-grid", "2x2", "../../test_files/point.shp", grid); code != 0 {
+		t.Fatalf("exit code %d", code)
+	}
+	for _, key := range []string{"0_0", "0_1", "1_1"} {
+		if n := countRecords(t, filepath.Join(grid, key+".shp")); n != 1 {
+			t.Errorf("%s: got %d records, want 1", key, n)
+		}
+	}
+
+	src := filepath.Join(dir, "in.csv")
+	ioutil.WriteFile(src, []byte("NAME,x,y\nA,1,2\nB,3,4\nA,5,6\n"), 0644)
+	if _, code := runCommand(t, "create", src, filepath.Join(dir, "in.shp")); code != 0 {
+		t.Fatalf("exit code %d", code)
+	}
+	byName := filepath.Join(dir, "names")
+	if _, code := runCommand(t, "split", "-field", "NAME", filepath.Join(dir, "in.shp"), byName); code != 0 {
+		t.Fatalf("exit code %d", code)
+	}
+	if a, b := countRecords(t, filepath.Join(byName, "A.shp")), countRecords(t, filepath.Join(byName, "B.shp")); a != 2 || b != 1 {
+		t.Errorf("got %d and %d records, want 2 and 1", a, b)
+	}
+	for _, flags := range [][]string{nil, {"-field", "NAME", "-grid", "2x2"}, {"-grid", "2"}} {
+		args := append(append([]string{"split"}, flags...), filepath.Join(dir, "in.shp"), byName)
+		if _, code := runCommand(t, args...); code != 1 {
+			t.Errorf("%q: expected exit code 1, got %d", flags, code)
+		}
+	}
+}
+
+func TestReindex(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-cmd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "point.shp")
+	for _, ext := range []string{".shp", ".dbf"} {
+		b, err := ioutil.ReadFile("../../test_files/point" + ext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ioutil.WriteFile(filepath.Join(dir, "point"+ext), b, 0644)
+	}
+	if _, code := runCommand(t, "reindex", "-qix", name); code != 0 {
+		t.Fatalf("exit code %d", code)
+	}
+	want, _ := ioutil.ReadFile("../../test_files/point.shx")
+	if got, err := ioutil.ReadFile(filepath.Join(dir, "point.shx")); err != nil || !bytes.Equal(got, want) {
+		t.Errorf("rebuilt .shx differs from the original: %v", err)
+	}
+	if _, err := shp.OpenQIX(filepath.Join(dir, "point.qix")); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,404 @@
+package shp
+
+import (
+	"errors"
+	"fmt"
+)
+
+// partOffsets returns the index of the first point of every part.
+func partOffsets(parts [][]Point) []int32 {
+	offsets := make([]int32, len(parts))
+	var marker int32
+	for i, part := range parts {
+		offsets[i] = marker
+		marker += int32(len(part))
+	}
+	return offsets
+}
+
+// checkParts returns an error if there are no parts or if a part has less
+// than min points.
+func checkParts(parts [][]Point, min int, kind string) error {
+	if len(parts) == 0 {
+		return fmt.Errorf("%s has no parts", kind)
+	}
+	for i, part := range parts {
+		if len(part) < min {
+			return fmt.Errorf("%s part %d has %d points, need at least %d", kind, i, len(part), min)
+		}
+	}
+	return nil
+}
+
+// flattenValues flattens the Z or M values of parts. The values must have the
+// same structure as the points of the parts. If values is nil, zeros are
+// returned.
+func flattenValues(parts [][]Point, values [][]float64, name string) ([]float64, error) {
+	var n int
+	for _, part := range parts {
+		n += len(part)
+	}
+	r := make([]float64, 0, n)
+	if values == nil {
+		return r[:n], nil
+	}
+	if len(values) != len(parts) {
+		return nil, fmt.Errorf("got %s values for %d parts, want %d", name, len(values), len(parts))
+	}
+	for i := range parts {
+		if len(values[i]) != len(parts[i]) {
+			return nil, fmt.Errorf("got %d %s values for part %d with %d points", len(values[i]), name, i, len(parts[i]))
+		}
+		r = append(r, values[i]...)
+	}
+	return r, nil
+}
+
+// valueRange returns the minimum and maximum of values.
+func valueRange(values []float64) [2]float64 {
+	var r [2]float64
+	for i, v := range values {
+		if i == 0 || v < r[0] {
+			r[0] = v
+		}
+		if i == 0 || v > r[1] {
+			r[1] = v
+		}
+	}
+	return r
+}
+
+// pointInRing reports whether p lies inside ring using the even-odd rule.
+func pointInRing(p Point, ring []Point) bool {
+	in := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[i], ring[j]
+		if (a.Y > p.Y) != (b.Y > p.Y) && p.X < (b.X-a.X)*(p.Y-a.Y)/(b.Y-a.Y)+a.X {
+			in = !in
+		}
+	}
+	return in
+}
+
+// isHole reports whether the i-th of the rings lies inside an odd number of
+// the other rings.
+func isHole(rings [][]Point, i int) bool {
+	depth := 0
+	for j := range rings {
+		if i != j && len(rings[i]) > 0 && pointInRing(rings[i][0], rings[j]) {
+			depth++
+		}
+	}
+	return depth%2 == 1
+}
+
+// normalizeRings closes every ring and orients it according to its nesting
+// depth: rings that are contained in an even number of other rings are outer
+// rings and become clockwise, the others are holes and become
+// counterclockwise. The Z and M values of the rings, which may be nil, are
+// adjusted along with the points.
+func normalizeRings(rings [][]Point, zs, ms [][]float64) ([][]Point, [][]float64, [][]float64) {
+	closed := make([][]Point, len(rings))
+	closeValues := func(values [][]float64, i int) []float64 {
+		v := append([]float64(nil), values[i]...)
+		if len(closed[i]) > len(rings[i]) {
+			v = append(v, v[0])
+		}
+		return v
+	}
+	var cz, cm [][]float64
+	for i, ring := range rings {
+		closed[i] = append([]Point(nil), ring...)
+		if ring[0] != ring[len(ring)-1] {
+			closed[i] = append(closed[i], ring[0])
+		}
+	}
+	if zs != nil {
+		cz = make([][]float64, len(rings))
+	}
+	if ms != nil {
+		cm = make([][]float64, len(rings))
+	}
+	for i := range closed {
+		if zs != nil {
+			cz[i] = closeValues(zs, i)
+		}
+		if ms != nil {
+			cm[i] = closeValues(ms, i)
+		}
+		if hole := isHole(closed, i); (ringArea(closed[i]) > 0) != hole {
+			reversePoints(closed[i])
+			if cz != nil {
+				reverseFloats(cz[i])
+			}
+			if cm != nil {
+				reverseFloats(cm[i])
+			}
+		}
+	}
+	return closed, cz, cm
+}
+
+func reversePoints(p []Point) {
+	for i, j := 0, len(p)-1; i < j; i, j = i+1, j-1 {
+		p[i], p[j] = p[j], p[i]
+	}
+}
+
+func reverseFloats(f []float64) {
+	for i, j := 0, len(f)-1; i < j; i, j = i+1, j-1 {
+		f[i], f[j] = f[j], f[i]
+	}
+}
+
+// NewPolygon returns a Polygon with the given rings. Rings are closed if
+// necessary and oriented as required by the specification: rings that lie
+// inside an odd number of other rings are holes and become counterclockwise,
+// all others become clockwise. Every ring needs at least three points.
+func NewPolygon(rings [][]Point) (*Polygon, error) {
+	if err := checkParts(rings, 3, "Polygon"); err != nil {
+		return nil, err
+	}
+	closed, _, _ := normalizeRings(rings, nil, nil)
+	return (*Polygon)(NewPolyLine(closed)), nil
+}
+
+// NewPolyLineChecked returns a PolyLine with the given parts like NewPolyLine,
+// but returns an error if there are no parts or a part has less than two
+// points.
+func NewPolyLineChecked(parts [][]Point) (*PolyLine, error) {
+	if err := checkParts(parts, 2, "PolyLine"); err != nil {
+		return nil, err
+	}
+	return NewPolyLine(parts), nil
+}
+
+// NewMultiPoint returns a MultiPoint with the given points.
+func NewMultiPoint(points []Point) (*MultiPoint, error) {
+	if len(points) == 0 {
+		return nil, errors.New("MultiPoint has no points")
+	}
+	return &MultiPoint{
+		Box:       BBoxFromPoints(points),
+		NumPoints: int32(len(points)),
+		Points:    append([]Point(nil), points...),
+	}, nil
+}
+
+// NewPolyLineZ returns a PolyLineZ with the given parts. The Z and M values
+// must have the same structure as parts. m may be nil, in which case all
+// measures are NoData.
+func NewPolyLineZ(parts [][]Point, z, m [][]float64) (*PolyLineZ, error) {
+	if err := checkParts(parts, 2, "PolyLineZ"); err != nil {
+		return nil, err
+	}
+	return newPolyLineZ(parts, z, m)
+}
+
+func newPolyLineZ(parts [][]Point, z, m [][]float64) (*PolyLineZ, error) {
+	if z == nil {
+		return nil, errors.New("missing Z values")
+	}
+	zs, err := flattenValues(parts, z, "Z")
+	if err != nil {
+		return nil, err
+	}
+	ms, err := flattenValues(parts, m, "M")
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		ms = nodataArray(len(ms))
+	}
+	points := flatten(parts)
+	return &PolyLineZ{
+		Box:       BBoxFromPoints(points),
+		NumParts:  int32(len(parts)),
+		NumPoints: int32(len(points)),
+		Parts:     partOffsets(parts),
+		Points:    points,
+		ZRange:    valueRange(zs),
+		ZArray:    zs,
+		MRange:    measureRange(ms),
+		MArray:    ms,
+	}, nil
+}
+
+// NewPolygonZ returns a PolygonZ with the given rings, which are closed and
+// oriented like in NewPolygon. The Z and M values must have the same
+// structure as rings. m may be nil, in which case all measures are NoData.
+func NewPolygonZ(rings [][]Point, z, m [][]float64) (*PolygonZ, error) {
+	if err := checkParts(rings, 3, "PolygonZ"); err != nil {
+		return nil, err
+	}
+	if z == nil {
+		return nil, errors.New("missing Z values")
+	}
+	if _, err := flattenValues(rings, z, "Z"); err != nil {
+		return nil, err
+	}
+	if _, err := flattenValues(rings, m, "M"); err != nil {
+		return nil, err
+	}
+	closed, cz, cm := normalizeRings(rings, z, m)
+	p, err := newPolyLineZ(closed, cz, cm)
+	return (*PolygonZ)(p), err
+}
+
+// NewMultiPointZ returns a MultiPointZ with the given points and their Z and M
+// values. m may be nil, in which case all measures are NoData.
+func NewMultiPointZ(points []Point, z, m []float64) (*MultiPointZ, error) {
+	if len(points) == 0 {
+		return nil, errors.New("MultiPointZ has no points")
+	}
+	if len(z) != len(points) {
+		return nil, fmt.Errorf("got %d Z values for %d points", len(z), len(points))
+	}
+	if m == nil {
+		m = nodataArray(len(points))
+	}
+	if len(m) != len(points) {
+		return nil, fmt.Errorf("got %d M values for %d points", len(m), len(points))
+	}
+	return &MultiPointZ{
+		Box:       BBoxFromPoints(points),
+		NumPoints: int32(len(points)),
+		Points:    append([]Point(nil), points...),
+		ZRange:    valueRange(z),
+		ZArray:    append([]float64(nil), z...),
+		MRange:    measureRange(m),
+		MArray:    append([]float64(nil), m...),
+	}, nil
+}
+
+// NewPolyLineM returns a PolyLineM with the given parts. The M values must
+// have the same structure as parts.
+func NewPolyLineM(parts [][]Point, m [][]float64) (*PolyLineM, error) {
+	if err := checkParts(parts, 2, "PolyLineM"); err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return nil, errors.New("missing M values")
+	}
+	ms, err := flattenValues(parts, m, "M")
+	if err != nil {
+		return nil, err
+	}
+	points := flatten(parts)
+	return &PolyLineM{
+		Box:       BBoxFromPoints(points),
+		NumParts:  int32(len(parts)),
+		NumPoints: int32(len(points)),
+		Parts:     partOffsets(parts),
+		Points:    points,
+		MRange:    measureRange(ms),
+		MArray:    ms,
+	}, nil
+}
+
+// NewPolygonM returns a PolygonM with the given rings, which are closed and
+// oriented like in NewPolygon. The M values must have the same structure as
+// rings.
+func NewPolygonM(rings [][]Point, m [][]float64) (*PolygonM, error) {
+	if err := checkParts(rings, 3, "PolygonM"); err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return nil, errors.New("missing M values")
+	}
+	if _, err := flattenValues(rings, m, "M"); err != nil {
+		return nil, err
+	}
+	closed, _, cm := normalizeRings(rings, nil, m)
+	ms, _ := flattenValues(closed, cm, "M")
+	points := flatten(closed)
+	return &PolygonM{
+		Box:       BBoxFromPoints(points),
+		NumParts:  int32(len(closed)),
+		NumPoints: int32(len(points)),
+		Parts:     partOffsets(closed),
+		Points:    points,
+		MRange:    measureRange(ms),
+		MArray:    ms,
+	}, nil
+}
+
+// NewMultiPointM returns a MultiPointM with the given points and their M
+// values.
+func NewMultiPointM(points []Point, m []float64) (*MultiPointM, error) {
+	if len(points) == 0 {
+		return nil, errors.New("MultiPointM has no points")
+	}
+	if len(m) != len(points) {
+		return nil, fmt.Errorf("got %d M values for %d points", len(m), len(points))
+	}
+	return &MultiPointM{
+		Box:       BBoxFromPoints(points),
+		NumPoints: int32(len(points)),
+		Points:    append([]Point(nil), points...),
+		MRange:    measureRange(m),
+		MArray:    append([]float64(nil), m...),
+	}, nil
+}
+
+// NewMultiPatch returns a MultiPatch with the given parts, whose types are
+// given by partTypes, e.g. TriangleStrip or OuterRing. Triangle strips and
+// fans need at least three points. Rings are closed if necessary but keep
+// their orientation, as it determines which side of a surface is the front.
+// The Z and M values must have the same structure as parts. m may be nil, in
+// which case all measures are NoData.
+func NewMultiPatch(parts [][]Point, partTypes []int32, z, m [][]float64) (*MultiPatch, error) {
+	if err := checkParts(parts, 3, "MultiPatch"); err != nil {
+		return nil, err
+	}
+	if len(partTypes) != len(parts) {
+		return nil, fmt.Errorf("got %d part types for %d parts", len(partTypes), len(parts))
+	}
+	if z == nil {
+		return nil, errors.New("missing Z values")
+	}
+	if _, err := flattenValues(parts, z, "Z"); err != nil {
+		return nil, err
+	}
+	if _, err := flattenValues(parts, m, "M"); err != nil {
+		return nil, err
+	}
+	closed := make([][]Point, len(parts))
+	cz := make([][]float64, len(parts))
+	cm := make([][]float64, len(parts))
+	for i, part := range parts {
+		closed[i] = append([]Point(nil), part...)
+		cz[i] = append([]float64(nil), z[i]...)
+		if m != nil {
+			cm[i] = append([]float64(nil), m[i]...)
+		} else {
+			cm[i] = nodataArray(len(part))
+		}
+		switch partTypes[i] {
+		case TriangleStrip, TriangleFan:
+		case OuterRing, InnerRing, FirstRing, Ring:
+			if part[0] != part[len(part)-1] {
+				closed[i] = append(closed[i], part[0])
+				cz[i] = append(cz[i], cz[i][0])
+				cm[i] = append(cm[i], cm[i][0])
+			}
+		default:
+			return nil, fmt.Errorf("MultiPatch part %d has unknown type %d", i, partTypes[i])
+		}
+	}
+	zs, _ := flattenValues(closed, cz, "Z")
+	ms, _ := flattenValues(closed, cm, "M")
+	points := flatten(closed)
+	return &MultiPatch{
+		Box:       BBoxFromPoints(points),
+		NumParts:  int32(len(closed)),
+		NumPoints: int32(len(points)),
+		Parts:     partOffsets(closed),
+		PartTypes: append([]int32(nil), partTypes...),
+		Points:    points,
+		ZRange:    valueRange(zs),
+		ZArray:    zs,
+		MRange:    measureRange(ms),
+		MArray:    ms,
+	}, nil
+}
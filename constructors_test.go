@@ -0,0 +1,129 @@
+package shp
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestNewPolygon(t *testing.T) {
+	// counterclockwise outer ring without closing point, clockwise hole
+	p, err := NewPolygon([][]Point{
+		{{0, 0}, {10, 0}, {10, 10}, {0, 10}},
+		{{2, 2}, {2, 4}, {4, 4}, {4, 2}, {2, 2}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Polygon{
+		Box:       Box{0, 0, 10, 10},
+		NumParts:  2,
+		NumPoints: 10,
+		Parts:     []int32{0, 5},
+		Points: []Point{
+			{0, 0}, {0, 10}, {10, 10}, {10, 0}, {0, 0},
+			{2, 2}, {4, 2}, {4, 4}, {2, 4}, {2, 2},
+		},
+	}
+	if !reflect.DeepEqual(p, want) {
+		t.Errorf("got %+v, want %+v", p, want)
+	}
+
+	for _, rings := range [][][]Point{nil, {{{0, 0}, {1, 1}}}} {
+		if _, err := NewPolygon(rings); err == nil {
+			t.Errorf("created Polygon from %v without error", rings)
+		}
+	}
+}
+
+func TestNewPolyLineChecked(t *testing.T) {
+	parts := [][]Point{{{0, 0}, {1, 1}}, {{2, 2}, {3, 1}, {4, 2}}}
+	p, err := NewPolyLineChecked(parts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := NewPolyLine(parts); !reflect.DeepEqual(p, want) {
+		t.Errorf("got %+v, want %+v", p, want)
+	}
+	for _, parts := range [][][]Point{nil, {{}}, {{{0, 0}, {1, 1}}, {{2, 2}}}} {
+		if _, err := NewPolyLineChecked(parts); err == nil {
+			t.Errorf("created PolyLine from %v without error", parts)
+		}
+	}
+}
+
+func TestNewPolygonZ(t *testing.T) {
+	p, err := NewPolygonZ(
+		[][]Point{{{0, 0}, {10, 0}, {10, 10}}},
+		[][]float64{{1, 2, 3}},
+		nil,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []float64{1, 3, 2, 1}; !reflect.DeepEqual(p.ZArray, want) {
+		t.Errorf("got Z values %v, want %v", p.ZArray, want)
+	}
+	if p.ZRange != [2]float64{1, 3} {
+		t.Errorf("got Z range %v", p.ZRange)
+	}
+	if len(p.MArray) != 4 {
+		t.Errorf("got %d M values, want 4", len(p.MArray))
+	}
+
+	if _, err := NewPolygonZ([][]Point{{{0, 0}, {10, 0}, {10, 10}}}, [][]float64{{1, 2}}, nil); err == nil {
+		t.Error("created PolygonZ with mismatched Z values without error")
+	}
+	if _, err := NewPolygonZ([][]Point{{{0, 0}, {10, 0}, {10, 10}}}, nil, nil); err == nil {
+		t.Error("created PolygonZ without Z values without error")
+	}
+}
+
+func TestNewMultiPointAndLines(t *testing.T) {
+	if _, err := NewMultiPoint(nil); err == nil {
+		t.Error("created empty MultiPoint without error")
+	}
+	if _, err := NewMultiPointZ([]Point{{1, 1}}, []float64{1, 2}, nil); err == nil {
+		t.Error("created MultiPointZ with mismatched Z values without error")
+	}
+	if _, err := NewMultiPointM([]Point{{1, 1}}, nil); err == nil {
+		t.Error("created MultiPointM without M values without error")
+	}
+	if _, err := NewPolyLineZ([][]Point{{{1, 1}}}, [][]float64{{1}}, nil); err == nil {
+		t.Error("created PolyLineZ with a single point part without error")
+	}
+	l, err := NewPolyLineM([][]Point{{{0, 0}, {1, 1}}, {{5, 5}, {6, 6}, {7, 7}}}, [][]float64{{0, 1}, {2, 3, 4}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(l.Parts, []int32{0, 2}) || l.MRange != [2]float64{0, 4} || l.Box != (Box{0, 0, 7, 7}) {
+		t.Errorf("got %+v", l)
+	}
+}
+
+func TestWriteConstructedShapes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "polygonz")
+
+	p, err := NewPolygonZ(
+		[][]Point{{{0, 0}, {0, 5}, {5, 5}, {5, 0}}},
+		[][]float64{{0, 5, 10, 15}},
+		nil,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := Create(filename, POLYGONZ)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(p)
+	w.Close()
+	testPolygonZ(t, dataForReadTests["test_files/polygonz"].points, getShapesFromFile(filename, t))
+}
@@ -0,0 +1,46 @@
+package shp
+
+import "context"
+
+// WithContext makes readers stop once ctx is done, e.g. when the client of an
+// HTTP handler goes away. Next then returns false and Err returns the error
+// of ctx. The context is checked before every record, so a read that is in
+// progress is not interrupted. Readers must still be closed.
+func WithContext(ctx context.Context) Option {
+	return func(o *options) {
+		o.ctx = ctx
+	}
+}
+
+// contextErr returns the error of the context set by WithContext, or nil if
+// there is none or it is not done.
+func (o *options) contextErr() error {
+	if o.ctx == nil {
+		return nil
+	}
+	return o.ctx.Err()
+}
+
+// NextContext is like Next, but returns false once ctx is done, in which case
+// Err returns the error of ctx.
+func (r *Reader) NextContext(ctx context.Context) bool {
+	if err := ctx.Err(); err != nil {
+		if r.err == nil {
+			r.err = err
+		}
+		return false
+	}
+	return r.Next()
+}
+
+// NextContext is like Next, but returns false once ctx is done, in which case
+// Err returns the error of ctx.
+func (zr *ZipReader) NextContext(ctx context.Context) bool {
+	if err := ctx.Err(); err != nil {
+		if sr, ok := zr.sr.(*seqReader); ok && sr.err == nil {
+			sr.err = err
+		}
+		return false
+	}
+	return zr.Next()
+}
@@ -0,0 +1,71 @@
+package shp
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNextContext(t *testing.T) {
+	r, err := Open("test_files/point.shp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	if !r.NextContext(ctx) {
+		t.Fatalf("got no shape before cancelling: %v", r.Err())
+	}
+	cancel()
+	if r.NextContext(ctx) {
+		t.Error("got a shape after cancelling")
+	}
+	if r.Err() != context.Canceled {
+		t.Errorf("got error %v, want %v", r.Err(), context.Canceled)
+	}
+	if err := r.Close(); err != nil {
+		t.Errorf("got error %v when closing after cancelling", err)
+	}
+
+	dir, filename := createTempZIP("test_files/point", t)
+	defer os.RemoveAll(dir)
+	zr, err := OpenZip(filepath.Join(dir, filename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+	if zr.NextContext(ctx) || zr.Err() != context.Canceled {
+		t.Errorf("got error %v from ZipReader, want %v", zr.Err(), context.Canceled)
+	}
+}
+
+func TestWithContext(t *testing.T) {
+	dir, filename := createTempZIP("test_files/point", t)
+	defer os.RemoveAll(dir)
+	open := map[string]func(opt Option) (SequentialReader, error){
+		"Reader": func(opt Option) (SequentialReader, error) {
+			return Open("test_files/point.shp", opt)
+		},
+		"ZipReader": func(opt Option) (SequentialReader, error) {
+			return OpenZip(filepath.Join(dir, filename), opt)
+		},
+	}
+	for name, open := range open {
+		ctx, cancel := context.WithCancel(context.Background())
+		sr, err := open(WithContext(ctx))
+		if err != nil {
+			t.Fatal(err)
+		}
+		n := 0
+		for sr.Next() {
+			if n++; n == 2 {
+				cancel()
+			}
+		}
+		if n != 2 || sr.Err() != context.Canceled {
+			t.Errorf("%s: read %d shapes with error %v, want 2 and %v", name, n, sr.Err(), context.Canceled)
+		}
+		sr.Close()
+		cancel()
+	}
+}
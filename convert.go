@@ -0,0 +1,462 @@
+package shp
+
+import (
+	"bufio"
+	"bytes"
+	"container/heap"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// sortBufferSize is the number of records WithSortBy keeps in memory before
+// it spills them to a temporary file.
+var sortBufferSize = 100000
+
+// WithSortBy makes Convert write the records ordered by the value of the
+// field called field instead of in source order. If numeric is set, values
+// are compared as numbers, otherwise as strings. NULL and blank values, and
+// values that are not numbers if numeric is set, come last. Records with equal
+// values keep their source order. Records that do not fit into memory are
+// sorted with an external merge sort using temporary files.
+func WithSortBy(field string, numeric bool) Option {
+	return func(o *options) {
+		o.sortBy = field
+		o.sortNumeric = numeric
+	}
+}
+
+// Convert reads the shapefile src and writes its records to a new shapefile
+// dst. Records are written exactly in the order in which they are stored in
+// src unless WithSortBy is given. The options apply to reading src and to
+// writing dst; WithSwapXY swaps the coordinates once. With WithAddZ or
+// WithAddM, 2D shapes are written with Z values or measures and dst is
+// created with the corresponding shape type. WithCharset applies to dst only:
+// the character fields are converted from the charset declared by src, and
+// an error is returned if that is unknown and they are not plain ASCII.
+func Convert(src, dst string, opts ...Option) error {
+	r, err := Open(src, append(append([]Option(nil), opts...), withSourceCharset())...)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	t := r.GeometryType
+	if r.opts.addZ != nil || r.opts.addM {
+		if t, err = upgradedType(t, r.opts.addZ != nil, r.opts.addM); err != nil {
+			return err
+		}
+	}
+	w, err := Create(dst, t, opts...)
+	if err != nil {
+		return err
+	}
+	w.opts.swapXY = false
+	// the progress is reported by the reader alone
+	w.opts.progress = nil
+	if err := convert(r, w); err != nil {
+		w.Abort()
+		return err
+	}
+	w.Close()
+	return nil
+}
+
+// convertRecord is a record in transit between a Reader and a Writer. The
+// row holds the raw DBF row, which is copied without reformatting. Records
+// that are sorted hold the record content as read, starting with the shape
+// type, instead of the shape if it is not changed on the way, so that it is
+// only decoded once it is written.
+type convertRecord struct {
+	shape Shape
+	raw   []byte
+	row   []byte
+	key   sortKey
+}
+
+func convert(r *Reader, w *Writer) error {
+	fields := r.Fields()
+	if r.dbf != nil {
+		if err := w.SetFields(fields); err != nil {
+			return err
+		}
+	}
+	var u *upgrader
+	if r.opts.addZ != nil || r.opts.addM {
+		t, err := upgradedType(r.GeometryType, r.opts.addZ != nil, r.opts.addM)
+		if err != nil {
+			return err
+		}
+		if w.GeometryType != t {
+			return fmt.Errorf("Unable to add Z or measures: writer has shape type %v, want %v", w.GeometryType, t)
+		}
+		u = &upgrader{sample: r.opts.addZ, addM: r.opts.addM}
+	}
+	var s *recordSorter
+	if r.opts.sortBy != "" {
+		var err error
+		if s, err = newRecordSorter(r, w); err != nil {
+			return err
+		}
+		defer s.close()
+	}
+	for r.Next() {
+		n, shape := r.Shape()
+		if u != nil {
+			var err error
+			if shape, err = u.upgrade(n, shape); err != nil {
+				return err
+			}
+		}
+		rec := convertRecord{shape: shape}
+		if s != nil && u == nil && !r.opts.swapXY && r.transform == nil {
+			rec = convertRecord{raw: append([]byte(nil), r.raw...)}
+		}
+		if r.readRow(n) && !r.dbfRowBad {
+			rec.row = append([]byte(nil), r.dbfRow...)
+			if w.charset != nil && w.charset != r.charset {
+				if err := transcodeRow(rec.row, fields, r.dbfOffsets, r.charset, w.charset); err != nil {
+					return fmt.Errorf("Unable to convert row %d: %v", n, err)
+				}
+			}
+		}
+		if s != nil {
+			if err := s.add(rec); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := w.writeRecord(rec); err != nil {
+			return err
+		}
+	}
+	if err := r.Err(); err != nil {
+		return err
+	}
+	if s != nil {
+		return s.flush()
+	}
+	return nil
+}
+
+// transcodeRow converts the character fields of the raw DBF row from the
+// charset from to the charset to in place. A nil from is only accepted for
+// fields that are plain ASCII, which are the same in every charset.
+func transcodeRow(row []byte, fields []Field, offsets []int, from, to encoding.Encoding) error {
+	for i, f := range fields {
+		if f.Fieldtype != 'C' {
+			continue
+		}
+		value := row[offsets[i] : offsets[i]+int(f.Size)]
+		text := bytes.TrimRight(value, " ")
+		if from == nil {
+			for _, c := range text {
+				if c >= 0x80 {
+					return fmt.Errorf("field %s is not ASCII and the charset of the source is unknown", f)
+				}
+			}
+			continue
+		}
+		var err error
+		if from != unicode.UTF8 {
+			if text, err = from.NewDecoder().Bytes(text); err != nil {
+				return fmt.Errorf("field %s cannot be decoded: %v", f, err)
+			}
+		}
+		if to != unicode.UTF8 {
+			if text, err = to.NewEncoder().Bytes(text); err != nil {
+				return fmt.Errorf("field %s cannot be encoded: %v", f, err)
+			}
+		}
+		if len(text) > len(value) {
+			return fmt.Errorf("%q exceeds field length %d", text, len(value))
+		}
+		n := copy(value, text)
+		for j := n; j < len(value); j++ {
+			value[j] = ' '
+		}
+	}
+	return nil
+}
+
+// writeRecord writes the shape and the raw row of rec.
+func (w *Writer) writeRecord(rec convertRecord) error {
+	shape := rec.shape
+	if shape == nil {
+		var err error
+		if shape, err = readShape(ShapeType(binary.LittleEndian.Uint32(rec.raw)), rec.raw[4:]); err != nil {
+			return err
+		}
+	}
+	row := w.Write(shape)
+	if row < 0 {
+		return w.err
+	}
+	if rec.row == nil || w.dbf == nil {
+		return nil
+	}
+	if len(rec.row) != int(w.dbfRecordLength) {
+		return fmt.Errorf("Unable to write row %d: got %d bytes, want %d", row, len(rec.row), w.dbfRecordLength)
+	}
+	w.dbf.Seek(int64(w.dbfHeaderLength)+int64(row)*int64(w.dbfRecordLength), io.SeekStart)
+	_, err := w.dbf.Write(rec.row)
+	return err
+}
+
+// sortKey is the typed value of the field a Convert sorts by.
+type sortKey struct {
+	null bool
+	num  float64
+	str  string
+}
+
+func (a sortKey) less(b sortKey) bool {
+	if a.null || b.null {
+		return !a.null && b.null
+	}
+	if a.str != b.str {
+		return a.str < b.str
+	}
+	return a.num < b.num
+}
+
+// recordSorter buffers records and writes them ordered by a field.
+type recordSorter struct {
+	w       *Writer
+	field   Field
+	start   int
+	numeric bool
+	buf     []convertRecord
+	runs    []*os.File
+}
+
+func newRecordSorter(r *Reader, w *Writer) (*recordSorter, error) {
+	s := &recordSorter{w: w, numeric: r.opts.sortNumeric}
+	offsets := r.FieldOffsets()
+	for i, f := range r.Fields() {
+		if f.String() == r.opts.sortBy {
+			s.field, s.start = f, offsets[i]
+			return s, nil
+		}
+	}
+	return nil, fmt.Errorf("Unable to sort: no field %q", r.opts.sortBy)
+}
+
+// key returns the sort key of the raw row.
+func (s *recordSorter) key(row []byte) sortKey {
+	if row == nil {
+		return sortKey{null: true}
+	}
+	v := string(row[s.start : s.start+int(s.field.Size)])
+	if isNullValue(s.field, v) {
+		return sortKey{null: true}
+	}
+	v = strings.TrimSpace(v)
+	if s.numeric {
+		f, err := strconv.ParseFloat(v, 64)
+		return sortKey{null: err != nil, num: f}
+	}
+	return sortKey{null: v == "", str: v}
+}
+
+func (s *recordSorter) add(rec convertRecord) error {
+	rec.key = s.key(rec.row)
+	s.buf = append(s.buf, rec)
+	if len(s.buf) >= sortBufferSize {
+		return s.spill()
+	}
+	return nil
+}
+
+// spill sorts the buffered records and writes them to a temporary file.
+func (s *recordSorter) spill() error {
+	sort.SliceStable(s.buf, func(i, j int) bool { return s.buf[i].key.less(s.buf[j].key) })
+	f, err := ioutil.TempFile("", "go-shp-sort")
+	if err != nil {
+		return err
+	}
+	s.runs = append(s.runs, f)
+	bw := bufio.NewWriter(f)
+	for _, rec := range s.buf {
+		if err := s.encode(bw, rec); err != nil {
+			return err
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	s.buf = s.buf[:0]
+	_, err = f.Seek(0, io.SeekStart)
+	return err
+}
+
+// encode writes rec to a run file as the length and the record content,
+// starting with the shape type, and the length and content of the row. Only
+// shapes that were changed since they were read are encoded again.
+func (s *recordSorter) encode(wr io.Writer, rec convertRecord) error {
+	raw := rec.raw
+	if raw == nil {
+		t := shapeTypeOf(rec.shape, s.w.GeometryType)
+		content, err := encodeShape(t, rec.shape)
+		if err != nil {
+			return err
+		}
+		raw = make([]byte, 4, 4+len(content))
+		binary.LittleEndian.PutUint32(raw, uint32(t))
+		raw = append(raw, content...)
+	}
+	binary.Write(wr, binary.LittleEndian, int32(len(raw)))
+	wr.Write(raw)
+	binary.Write(wr, binary.LittleEndian, int32(len(rec.row)))
+	_, err := wr.Write(rec.row)
+	return err
+}
+
+// decode reads the next record from a run file.
+func (s *recordSorter) decode(rd io.Reader) (convertRecord, error) {
+	var n int32
+	if err := binary.Read(rd, binary.LittleEndian, &n); err != nil {
+		return convertRecord{}, err
+	}
+	if n < 4 {
+		return convertRecord{}, fmt.Errorf("Invalid record content of %d bytes", n)
+	}
+	rec := convertRecord{raw: make([]byte, n)}
+	if _, err := io.ReadFull(rd, rec.raw); err != nil {
+		return convertRecord{}, err
+	}
+	if err := binary.Read(rd, binary.LittleEndian, &n); err != nil {
+		return convertRecord{}, err
+	}
+	if n > 0 {
+		rec.row = make([]byte, n)
+		if _, err := io.ReadFull(rd, rec.row); err != nil {
+			return convertRecord{}, err
+		}
+	}
+	rec.key = s.key(rec.row)
+	return rec, nil
+}
+
+// flush writes all records in order.
+func (s *recordSorter) flush() error {
+	if len(s.runs) == 0 {
+		sort.SliceStable(s.buf, func(i, j int) bool { return s.buf[i].key.less(s.buf[j].key) })
+		for _, rec := range s.buf {
+			if err := s.w.writeRecord(rec); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if len(s.buf) > 0 {
+		if err := s.spill(); err != nil {
+			return err
+		}
+	}
+	h := &runHeap{}
+	readers := make([]*bufio.Reader, len(s.runs))
+	for i, f := range s.runs {
+		readers[i] = bufio.NewReader(f)
+		rec, err := s.decode(readers[i])
+		if err != nil {
+			return fmt.Errorf("Error reading sort run: %v", err)
+		}
+		heap.Push(h, runHead{rec: rec, run: i})
+	}
+	for h.Len() > 0 {
+		head := heap.Pop(h).(runHead)
+		if err := s.w.writeRecord(head.rec); err != nil {
+			return err
+		}
+		rec, err := s.decode(readers[head.run])
+		if err == io.EOF {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("Error reading sort run: %v", err)
+		}
+		heap.Push(h, runHead{rec: rec, run: head.run})
+	}
+	return nil
+}
+
+// close removes the temporary files.
+func (s *recordSorter) close() {
+	for _, f := range s.runs {
+		f.Close()
+		os.Remove(f.Name())
+	}
+}
+
+// runHead is the next record of a sorted run.
+type runHead struct {
+	rec convertRecord
+	run int
+}
+
+// runHeap orders the heads of the runs by key and, for equal keys, by run,
+// so that the merge is stable.
+type runHeap []runHead
+
+func (h runHeap) Len() int { return len(h) }
+func (h runHeap) Less(i, j int) bool {
+	if h[i].rec.key.less(h[j].rec.key) {
+		return true
+	}
+	if h[j].rec.key.less(h[i].rec.key) {
+		return false
+	}
+	return h[i].run < h[j].run
+}
+func (h runHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *runHeap) Push(x interface{}) { *h = append(*h, x.(runHead)) }
+func (h *runHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// shapeTypeOf returns the type of the built-in shape s, or fallback for
+// shapes of registered types.
+func shapeTypeOf(s Shape, fallback ShapeType) ShapeType {
+	switch s.(type) {
+	case *Null:
+		return NULL
+	case *Point:
+		return POINT
+	case *PolyLine:
+		return POLYLINE
+	case *Polygon:
+		return POLYGON
+	case *MultiPoint:
+		return MULTIPOINT
+	case *PointZ:
+		return POINTZ
+	case *PolyLineZ:
+		return POLYLINEZ
+	case *PolygonZ:
+		return POLYGONZ
+	case *MultiPointZ:
+		return MULTIPOINTZ
+	case *PointM:
+		return POINTM
+	case *PolyLineM:
+		return POLYLINEM
+	case *PolygonM:
+		return POLYGONM
+	case *MultiPointM:
+		return MULTIPOINTM
+	case *MultiPatch:
+		return MULTIPATCH
+	}
+	return fallback
+}
@@ -0,0 +1,129 @@
+package shp
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// createSortInput writes points whose X coordinate is the index of the
+// record, with the index as ID and val as VAL. Empty values are left NULL.
+func createSortInput(t *testing.T, name string, vals []string) {
+	w, err := Create(name, POINT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SetFields([]Field{NumberField("ID", 5), StringField("VAL", 8)})
+	for i, v := range vals {
+		w.Write(&Point{float64(i), 0})
+		w.WriteAttribute(i, 0, i)
+		if v != "" {
+			w.WriteAttribute(i, 1, v)
+		}
+	}
+	w.Close()
+}
+
+// readConverted returns the IDs and VAL attributes of the records of name
+// and checks that every shape is still paired with its attributes.
+func readConverted(t *testing.T, name string) (ids []int, vals []string) {
+	r, err := Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	for r.Next() {
+		n, s := r.Shape()
+		id, err := strconv.Atoi(r.ReadAttribute(n, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if x := s.(*Point).X; x != float64(id) {
+			t.Errorf("record %d: shape %v is paired with ID %d", n, x, id)
+		}
+		ids = append(ids, id)
+		vals = append(vals, r.ReadAttribute(n, 1))
+	}
+	return ids, vals
+}
+
+func TestConvertPreservesOrder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	src, dst := filepath.Join(dir, "src.shp"), filepath.Join(dir, "dst.shp")
+	createSortInput(t, src, []string{"c", "a", "", "b"})
+	if err := Convert(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	ids, _ := readConverted(t, dst)
+	if !reflect.DeepEqual(ids, []int{0, 1, 2, 3}) {
+		t.Errorf("got order %v", ids)
+	}
+	for _, ext := range []string{".shp", ".shx", ".dbf"} {
+		a, _ := ioutil.ReadFile(filepath.Join(dir, "src"+ext))
+		b, _ := ioutil.ReadFile(filepath.Join(dir, "dst"+ext))
+		if !reflect.DeepEqual(a, b) {
+			t.Errorf("%s differs from source", ext)
+		}
+	}
+}
+
+func TestConvertSortBy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	src := filepath.Join(dir, "src.shp")
+	createSortInput(t, src, []string{"10", "", "9", "b", "10", "-1", "a", "", "2.5"})
+
+	defer func(n int) { sortBufferSize = n }(sortBufferSize)
+	for _, n := range []int{100, 2} { // in memory, then spilling
+		sortBufferSize = n
+
+		dst := filepath.Join(dir, "num.shp")
+		if err := Convert(src, dst, WithSortBy("VAL", true)); err != nil {
+			t.Fatal(err)
+		}
+		ids, vals := readConverted(t, dst)
+		// non-numeric values count as NULL and keep their source order
+		if want := []int{5, 8, 2, 0, 4, 1, 3, 6, 7}; !reflect.DeepEqual(ids, want) {
+			t.Errorf("buffer %d: got numeric order %v (%q), want %v", n, ids, vals, want)
+		}
+
+		dst = filepath.Join(dir, "str.shp")
+		if err := Convert(src, dst, WithSortBy("VAL", false)); err != nil {
+			t.Fatal(err)
+		}
+		ids, vals = readConverted(t, dst)
+		if want := []int{5, 0, 4, 8, 2, 6, 3, 1, 7}; !reflect.DeepEqual(ids, want) {
+			t.Errorf("buffer %d: got string order %v (%q), want %v", n, ids, vals, want)
+		}
+
+		// shapes that are changed on the way are encoded again
+		swapped := filepath.Join(dir, "swapped.shp")
+		if err := Convert(src, swapped, WithSortBy("VAL", false), WithSwapXY()); err != nil {
+			t.Fatal(err)
+		}
+		if err := Convert(swapped, dst, WithSwapXY()); err != nil {
+			t.Fatal(err)
+		}
+		ids, vals = readConverted(t, dst)
+		if want := []int{5, 0, 4, 8, 2, 6, 3, 1, 7}; !reflect.DeepEqual(ids, want) {
+			t.Errorf("buffer %d: got swapped order %v (%q), want %v", n, ids, vals, want)
+		}
+	}
+
+	if err := Convert(src, filepath.Join(dir, "bad.shp"), WithSortBy("NOPE", false)); err == nil {
+		t.Error("expected an error for an unknown field")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "bad.shp")); !os.IsNotExist(err) {
+		t.Error("expected output of failed Convert to be removed")
+	}
+}
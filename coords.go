@@ -0,0 +1,130 @@
+package shp
+
+// cloneShape returns a deep copy of s. Shapes of registered types are returned
+// unchanged.
+func cloneShape(s Shape) Shape {
+	points := func(p []Point) []Point { return append([]Point(nil), p...) }
+	floats := func(f []float64) []float64 { return append([]float64(nil), f...) }
+	ints := func(i []int32) []int32 { return append([]int32(nil), i...) }
+	switch s := s.(type) {
+	case *Null:
+		c := *s
+		return &c
+	case *Point:
+		c := *s
+		return &c
+	case *PointZ:
+		c := *s
+		return &c
+	case *PointM:
+		c := *s
+		return &c
+	case *PolyLine:
+		c := *s
+		c.Parts, c.Points = ints(s.Parts), points(s.Points)
+		return &c
+	case *Polygon:
+		c := *s
+		c.Parts, c.Points = ints(s.Parts), points(s.Points)
+		return &c
+	case *MultiPoint:
+		c := *s
+		c.Points = points(s.Points)
+		return &c
+	case *PolyLineZ:
+		c := *s
+		c.Parts, c.Points = ints(s.Parts), points(s.Points)
+		c.ZArray, c.MArray = floats(s.ZArray), floats(s.MArray)
+		return &c
+	case *PolygonZ:
+		c := *s
+		c.Parts, c.Points = ints(s.Parts), points(s.Points)
+		c.ZArray, c.MArray = floats(s.ZArray), floats(s.MArray)
+		return &c
+	case *MultiPointZ:
+		c := *s
+		c.Points = points(s.Points)
+		c.ZArray, c.MArray = floats(s.ZArray), floats(s.MArray)
+		return &c
+	case *PolyLineM:
+		c := *s
+		c.Parts, c.Points = ints(s.Parts), points(s.Points)
+		c.MArray = floats(s.MArray)
+		return &c
+	case *PolygonM:
+		c := *s
+		c.Parts, c.Points = ints(s.Parts), points(s.Points)
+		c.ZArray, c.MArray = floats(s.ZArray), floats(s.MArray)
+		return &c
+	case *MultiPointM:
+		c := *s
+		c.Points = points(s.Points)
+		c.MArray = floats(s.MArray)
+		return &c
+	case *MultiPatch:
+		c := *s
+		c.Parts, c.PartTypes, c.Points = ints(s.Parts), ints(s.PartTypes), points(s.Points)
+		c.ZArray, c.MArray = floats(s.ZArray), floats(s.MArray)
+		return &c
+	default:
+		return s
+	}
+}
+
+// transformPoints applies f to every point of s in place and recomputes the
+// bounding box of s. Shapes of registered types are left unchanged.
+func transformPoints(s Shape, f func(Point) Point) {
+	apply := func(points []Point) Box {
+		for i := range points {
+			points[i] = f(points[i])
+		}
+		return BBoxFromPoints(points)
+	}
+	switch s := s.(type) {
+	case *Point:
+		*s = f(*s)
+	case *PointZ:
+		p := f(Point{s.X, s.Y})
+		s.X, s.Y = p.X, p.Y
+	case *PointM:
+		p := f(Point{s.X, s.Y})
+		s.X, s.Y = p.X, p.Y
+	case *PolyLine:
+		s.Box = apply(s.Points)
+	case *Polygon:
+		s.Box = apply(s.Points)
+	case *MultiPoint:
+		s.Box = apply(s.Points)
+	case *PolyLineZ:
+		s.Box = apply(s.Points)
+	case *PolygonZ:
+		s.Box = apply(s.Points)
+	case *MultiPointZ:
+		s.Box = apply(s.Points)
+	case *PolyLineM:
+		s.Box = apply(s.Points)
+	case *PolygonM:
+		s.Box = apply(s.Points)
+	case *MultiPointM:
+		s.Box = apply(s.Points)
+	case *MultiPatch:
+		s.Box = apply(s.Points)
+	}
+}
+
+func swapPoint(p Point) Point {
+	return Point{p.Y, p.X}
+}
+
+// SwapXY returns a copy of s in which the X and Y coordinate of every point
+// and of the bounding box are swapped.
+func SwapXY(s Shape) Shape {
+	c := cloneShape(s)
+	transformPoints(c, swapPoint)
+	return c
+}
+
+// swapBox swaps the X and Y coordinates of b.
+func swapBox(b Box) Box {
+	return Box{b.MinY, b.MinX, b.MaxY, b.MaxX}
+}
@@ -0,0 +1,66 @@
+package shp
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSwapXY(t *testing.T) {
+	l := NewPolyLine([][]Point{{{1, 2}, {3, 4}}, {{5, 6}, {7, 8}}})
+	s := SwapXY(l).(*PolyLine)
+	if l.Points[0] != (Point{1, 2}) {
+		t.Errorf("SwapXY modified its argument: %v", l.Points)
+	}
+	want := NewPolyLine([][]Point{{{2, 1}, {4, 3}}, {{6, 5}, {8, 7}}})
+	if !reflect.DeepEqual(s, want) {
+		t.Errorf("got %+v, want %+v", s, want)
+	}
+}
+
+func TestReadWriteSwapXY(t *testing.T) {
+	r, err := Open("test_files/polylinez.shp", WithSwapXY())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Box{0, 0, 25, 25}); r.BBox() != want {
+		t.Errorf("got bbox %v, want %v", r.BBox(), want)
+	}
+	var swapped []Shape
+	for r.Next() {
+		_, s := r.Shape()
+		swapped = append(swapped, s)
+	}
+	r.Close()
+	shapes := getShapesFromFile("test_files/polylinez", t)
+	for i := range shapes {
+		if !reflect.DeepEqual(SwapXY(shapes[i]), swapped[i]) {
+			t.Errorf("shape %d: got %+v, want swapped %+v", i, swapped[i], shapes[i])
+		}
+	}
+
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "swapped")
+	w, err := Create(filename, POINT, WithSwapXY())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &Point{10, 20}
+	w.Write(p)
+	w.Write(&Point{30, 40})
+	w.Close()
+	if *p != (Point{10, 20}) {
+		t.Errorf("Write modified its argument: %v", p)
+	}
+	if want := (Box{20, 10, 40, 30}); w.BBox() != want {
+		t.Errorf("got writer bbox %v, want %v", w.BBox(), want)
+	}
+	testPoint(t, [][]float64{{20, 10}, {40, 30}}, getShapesFromFile(filename, t))
+	testPoint(t, [][]float64{{10, 20}, {30, 40}}, getShapesSequentiallyWith(filename, t, WithSwapXY()))
+}
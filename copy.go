@@ -0,0 +1,155 @@
+package shp
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// datasetExtensions are the extensions of the files that make up a shapefile
+// dataset.
+var datasetExtensions = []string{".shp", ".shx", ".dbf", ".dbt", ".prj", ".cpg", ".qix", ".sbn", ".sbx", ".shp.xml"}
+
+// spatialIndexExtensions are the extensions of spatial indexes, which refer
+// to the order and coordinates of the records.
+var spatialIndexExtensions = map[string]bool{".qix": true, ".sbn": true, ".sbx": true}
+
+// datasetFiles returns the files that make up the dataset base, keyed by
+// their extension in lower case. Names are matched case-insensitively.
+func datasetFiles(base string) (map[string]string, error) {
+	dir, name := filepath.Split(base)
+	if dir == "" {
+		dir = "."
+	}
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]string)
+	for _, fi := range infos {
+		if fi.IsDir() {
+			continue
+		}
+		for _, ext := range datasetExtensions {
+			if strings.EqualFold(fi.Name(), name+ext) {
+				files[ext] = filepath.Join(dir, fi.Name())
+			}
+		}
+	}
+	return files, nil
+}
+
+// CopyDataset copies all files of the shapefile dataset srcBase, the path of
+// the shapefile without extension, to dstBase. The companions (.shx, .dbf,
+// .dbt, .prj, .cpg, .qix, .sbn, .sbx and .shp.xml) are found regardless of the case
+// of their names and are written with the extension in lower case. It returns
+// the names of the files that were written.
+//
+// If opts change the data, e.g. WithSwapXY, WithoutMeasures, WithSortBy,
+// WithAddZ, WithSimplify or WithSnapToGrid, the .shp, .shx and .dbf are
+// written by Convert instead of being copied and spatial indexes, which would
+// no longer match the records, are left out. With WithCharset, the character
+// fields are converted to the charset like in Convert and the .cpg that
+// declares it is written instead of the one of srcBase.
+//
+// The files are written under temporary names in the directory of dstBase and
+// only renamed to their final names once all of them were written, so that
+// either all files are copied or none.
+func CopyDataset(srcBase, dstBase string, opts ...Option) ([]string, error) {
+	if ext := filepath.Ext(srcBase); strings.EqualFold(ext, ".shp") {
+		srcBase = strings.TrimSuffix(srcBase, ext)
+	}
+	if ext := filepath.Ext(dstBase); strings.EqualFold(ext, ".shp") {
+		dstBase = strings.TrimSuffix(dstBase, ext)
+	}
+	files, err := datasetFiles(srcBase)
+	if err != nil {
+		return nil, err
+	}
+	if files[".shp"] == "" {
+		return nil, fmt.Errorf("No shapefile at %s", srcBase)
+	}
+
+	tmp, err := ioutil.TempDir(filepath.Dir(dstBase), ".shpcopy")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+	tmpBase := filepath.Join(tmp, filepath.Base(dstBase))
+
+	o := newOptions(opts)
+	transform := o.changesData()
+	var exts []string
+	if transform {
+		// Convert expects the companions under lower case extensions
+		if err := os.Mkdir(filepath.Join(tmp, "src"), 0755); err != nil {
+			return nil, err
+		}
+		srcCopy := filepath.Join(tmp, "src", "source")
+		// the .cpg tells Convert which charset to convert from
+		for _, ext := range []string{".shp", ".shx", ".dbf", ".cpg"} {
+			if files[ext] == "" {
+				continue
+			}
+			if err := copyFile(files[ext], srcCopy+ext); err != nil {
+				return nil, err
+			}
+		}
+		if err := Convert(srcCopy+".shp", tmpBase+".shp", opts...); err != nil {
+			return nil, err
+		}
+		exts = append(exts, ".shp", ".shx")
+		if files[".dbf"] != "" {
+			exts = append(exts, ".dbf")
+		}
+		if o.charset != nil {
+			exts = append(exts, ".cpg")
+		}
+	}
+	for _, ext := range datasetExtensions {
+		src := files[ext]
+		if src == "" || transform && (ext == ".shp" || ext == ".shx" || ext == ".dbf" || spatialIndexExtensions[ext]) {
+			continue
+		}
+		if o.charset != nil && ext == ".cpg" {
+			continue
+		}
+		if err := copyFile(src, tmpBase+ext); err != nil {
+			return nil, err
+		}
+		exts = append(exts, ext)
+	}
+
+	var written []string
+	for _, ext := range exts {
+		if err := os.Rename(tmpBase+ext, dstBase+ext); err != nil {
+			for _, name := range written {
+				os.Remove(name)
+			}
+			return nil, err
+		}
+		written = append(written, dstBase+ext)
+	}
+	return written, nil
+}
+
+// copyFile copies the file src to dst.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,274 @@
+package shp
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// createCopySource writes the point test files to dir with companions whose
+// names differ in case.
+func createCopySource(t *testing.T, dir string) string {
+	names := map[string]string{
+		"point.shp":     "test_files/point.shp",
+		"POINT.SHX":     "test_files/point.shx",
+		"point.Dbf":     "test_files/point.dbf",
+		"point.prj":     "",
+		"point.cpg":     "",
+		"point.qix":     "",
+		"Point.shp.xml": "",
+		"other.prj":     "",
+	}
+	for name, src := range names {
+		data := []byte(name)
+		if src != "" {
+			var err error
+			if data, err = ioutil.ReadFile(src); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return filepath.Join(dir, "point")
+}
+
+func TestCopyDataset(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	src := createCopySource(t, dir)
+	dst := filepath.Join(dir, "copy")
+
+	written, err := CopyDataset(src, dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want []string
+	for _, ext := range []string{".shp", ".shx", ".dbf", ".prj", ".cpg", ".qix", ".shp.xml"} {
+		want = append(want, dst+ext)
+	}
+	if !reflect.DeepEqual(written, want) {
+		t.Errorf("got written files %v, want %v", written, want)
+	}
+	for from, to := range map[string]string{"point.shp": "copy.shp", "POINT.SHX": "copy.shx", "point.Dbf": "copy.dbf", "Point.shp.xml": "copy.shp.xml"} {
+		a, _ := ioutil.ReadFile(filepath.Join(dir, from))
+		b, err := ioutil.ReadFile(filepath.Join(dir, to))
+		if err != nil || !bytes.Equal(a, b) {
+			t.Errorf("%s was not copied to %s: %v", from, to, err)
+		}
+	}
+	infos, _ := ioutil.ReadDir(dir)
+	if len(infos) != 8+len(want) {
+		t.Errorf("got %d files, temporary files were left behind", len(infos))
+	}
+}
+
+func TestCopyDatasetTransform(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	src := createCopySource(t, dir)
+	dst := filepath.Join(dir, "swapped")
+
+	written, err := CopyDataset(src+".shp", dst, WithSwapXY())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want []string
+	for _, ext := range []string{".shp", ".shx", ".dbf", ".prj", ".cpg", ".shp.xml"} {
+		want = append(want, dst+ext)
+	}
+	if !reflect.DeepEqual(written, want) {
+		t.Errorf("got written files %v, want %v", written, want)
+	}
+
+	orig, err := Open("test_files/point.shp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer orig.Close()
+	r, err := Open(dst + ".shp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	for orig.Next() && r.Next() {
+		_, a := orig.Shape()
+		n, b := r.Shape()
+		p, q := a.(*Point), b.(*Point)
+		if p.X != q.Y || p.Y != q.X {
+			t.Errorf("record %d: got %v, want %v swapped", n, q, p)
+		}
+		if orig.Attribute(0) != r.Attribute(0) {
+			t.Errorf("record %d: got attribute %q, want %q", n, r.Attribute(0), orig.Attribute(0))
+		}
+	}
+}
+
+func TestCopyDatasetOptions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	src := filepath.Join(dir, "ring")
+	w, err := Create(src, POLYGON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a counterclockwise ring with fractional coordinates and a point that
+	// simplification removes
+	ring := []Point{{0.2, 0.2}, {10.2, 0.2}, {10.2, 5.1}, {10.2, 10.2}, {0.2, 10.2}, {0.2, 0.2}}
+	w.Write(&Polygon{Box: BBoxFromPoints(ring), NumParts: 1, NumPoints: int32(len(ring)), Parts: []int32{0}, Points: ring})
+	w.SetFields([]Field{StringField("NAME", 10)})
+	w.WriteAttribute(0, 0, "ring")
+	w.Close()
+
+	read := func(t *testing.T, base string) (*Reader, Shape) {
+		r, err := Open(base + ".shp")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !r.Next() {
+			t.Fatalf("no record: %v", r.Err())
+		}
+		_, s := r.Shape()
+		return r, s
+	}
+	for _, test := range []struct {
+		name  string
+		opt   Option
+		check func(t *testing.T, r *Reader, s Shape)
+	}{
+		{"add Z", WithAddZ(func(x, y float64) (float64, error) { return 7, nil }), func(t *testing.T, r *Reader, s Shape) {
+			if p, ok := s.(*PolygonZ); !ok || p.ZArray[0] != 7 {
+				t.Errorf("got %#v, want a PolygonZ at Z 7", s)
+			}
+		}},
+		{"add M", WithAddM(), func(t *testing.T, r *Reader, s Shape) {
+			if p, ok := s.(*PolygonM); !ok || p.MArray[1] != 10 {
+				t.Errorf("got %#v, want a PolygonM with measures", s)
+			}
+		}},
+		{"simplify", WithSimplify(0.5), func(t *testing.T, r *Reader, s Shape) {
+			if p := s.(*Polygon); len(p.Points) != 5 {
+				t.Errorf("got %v, want 5 points", p.Points)
+			}
+		}},
+		{"grid", WithSnapToGrid(1), func(t *testing.T, r *Reader, s Shape) {
+			if p := s.(*Polygon); p.Points[0] != (Point{0, 0}) || p.Points[1] != (Point{10, 0}) {
+				t.Errorf("got %v, want points on the grid", p.Points)
+			}
+		}},
+		{"orient", WithOrientedRings(), func(t *testing.T, r *Reader, s Shape) {
+			if o := s.(*Polygon).RingOrientations(); o[0] != Clockwise {
+				t.Errorf("got orientation %v, want clockwise", o[0])
+			}
+		}},
+		{"charset", WithCharset(charmap.Windows1252), func(t *testing.T, r *Reader, s Shape) {
+			if r.Charset() != charmap.Windows1252 {
+				t.Errorf("got charset %v, want Windows-1252", r.Charset())
+			}
+		}},
+	} {
+		dst := filepath.Join(dir, "copy")
+		if _, err := CopyDataset(src, dst, test.opt); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		r, s := read(t, dst)
+		test.check(t, r, s)
+		if r.Attribute(0) != "ring" {
+			t.Errorf("%s: got attribute %q", test.name, r.Attribute(0))
+		}
+		r.Close()
+	}
+}
+
+func TestCopyDatasetAllOrNothing(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	src := createCopySource(t, dir)
+	before, _ := ioutil.ReadDir(dir)
+
+	if _, err := CopyDataset(src, filepath.Join(dir, "failed"), WithSortBy("NOPE", false)); err == nil {
+		t.Fatal("expected an error")
+	}
+	if _, err := CopyDataset(filepath.Join(dir, "missing"), filepath.Join(dir, "failed")); err == nil {
+		t.Fatal("expected an error")
+	}
+	after, _ := ioutil.ReadDir(dir)
+	if len(after) != len(before) {
+		t.Errorf("got %d files after failed copies, want %d", len(after), len(before))
+	}
+}
+
+func TestCopyDatasetCharset(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	src := filepath.Join(dir, "latin")
+	w, err := Create(src, POINT, WithCharset(charmap.Windows1252))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SetFields([]Field{StringField("NAME", 10), NumberField("ID", 4)})
+	w.Write(&Point{1, 2})
+	w.WriteAttribute(0, 0, "Zürich")
+	w.WriteAttribute(0, 1, 8)
+	w.Close()
+
+	dst := filepath.Join(dir, "utf8")
+	if _, err := CopyDataset(src, dst, WithCharset(unicode.UTF8)); err != nil {
+		t.Fatal(err)
+	}
+	if cpg, err := ioutil.ReadFile(dst + ".cpg"); err != nil || string(cpg) != "UTF-8" {
+		t.Errorf("got .cpg %q, %v", cpg, err)
+	}
+	dbf, err := ioutil.ReadFile(dst + ".dbf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(dbf, []byte(" Zürich   8")) {
+		t.Errorf("the row was not converted to UTF-8: %q", dbf)
+	}
+	r, err := Open(dst + ".shp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if !r.Next() {
+		t.Fatal(r.Err())
+	}
+	if got := r.Attribute(0); got != "Zürich" {
+		t.Errorf("got %q, want Zürich", got)
+	}
+
+	// without a .cpg or LDID the charset of the source is unknown
+	os.Remove(src + ".cpg")
+	f, err := os.OpenFile(src+".dbf", os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteAt([]byte{0}, 29)
+	f.Close()
+	if _, err := CopyDataset(src, filepath.Join(dir, "unknown"), WithCharset(unicode.UTF8)); err == nil {
+		t.Error("expected an error for a source of unknown charset")
+	}
+}
@@ -0,0 +1,96 @@
+package shp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// shxCount returns the number of records that an index of size bytes refers
+// to and false if the size is not that of a valid index.
+func shxCount(size int64) (int, bool) {
+	if size < 100 || (size-100)%8 != 0 {
+		return 0, false
+	}
+	return int((size - 100) / 8), true
+}
+
+// scanCount counts the records of the .shp in r by skipping from one record
+// header to the next, without reading their content.
+func scanCount(r io.Reader) (int, error) {
+	if _, err := io.CopyN(ioutil.Discard, r, 100); err != nil {
+		return 0, fmt.Errorf("Error reading SHP header: %v", err)
+	}
+	n := 0
+	for {
+		var header [8]byte
+		if _, err := io.ReadFull(r, header[:]); err == io.EOF {
+			return n, nil
+		} else if err != nil {
+			return n, fmt.Errorf("Error reading header of record %d: %v", n+1, err)
+		}
+		length := 2 * int64(int32(binary.BigEndian.Uint32(header[4:])))
+		if length < 0 {
+			return n, fmt.Errorf("Invalid content length %d of record %d", length, n+1)
+		}
+		if _, err := io.CopyN(ioutil.Discard, r, length); err != nil {
+			return n, fmt.Errorf("Error skipping record %d: %v", n+1, err)
+		}
+		n++
+	}
+}
+
+// Count returns the number of records of the shapefile without reading them.
+// It is taken from the size of the .shx if there is one, from the DBF header
+// otherwise, and only if neither exists are the record headers of the .shp
+// scanned. The position of the reader is not changed.
+func (r *Reader) Count() (int, error) {
+	if r.recovered != nil {
+		return len(r.recovered), nil
+	}
+	shx := r.filename + ".shx"
+	if _, err := os.Stat(shx); os.IsNotExist(err) {
+		if files, err := datasetFiles(r.filename); err == nil && files[".shx"] != "" {
+			shx = files[".shx"]
+		}
+	}
+	if fi, err := os.Stat(shx); err == nil {
+		if n, ok := shxCount(fi.Size()); ok {
+			return n, nil
+		}
+	}
+	if r.openDbf() == nil {
+		return int(r.dbfNumRecords), nil
+	}
+	ra, ok := r.shp.(io.ReaderAt)
+	if !ok {
+		return 0, fmt.Errorf("Cannot count the records of %s.shp", r.filename)
+	}
+	return scanCount(io.NewSectionReader(ra, 0, r.filelength))
+}
+
+// Count returns the number of records of the shapefile without reading them,
+// see Reader.Count. The .shx and the DBF header are used if the archive has
+// them, and the .shp entry is scanned otherwise.
+func (zr *ZipReader) Count() (int, error) {
+	if e := zr.entries[".shx"]; e != nil {
+		if n, ok := shxCount(int64(e.UncompressedSize)); ok {
+			return n, nil
+		}
+	}
+	if sr, ok := zr.sr.(*seqReader); ok && sr.dbf != nil {
+		return int(sr.dbfNumRecords), nil
+	}
+	f := findInZIP(zr.z, zr.name, true)
+	if f == nil {
+		return 0, fmt.Errorf("%w: %s", ErrFileNotInArchive, zr.name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return 0, err
+	}
+	defer rc.Close()
+	return scanCount(rc)
+}
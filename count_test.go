@@ -0,0 +1,58 @@
+package shp
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReaderCount(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, ext := range []string{".shp", ".shx", ".dbf"} {
+		if err := copyFile("test_files/point"+ext, filepath.Join(dir, "point"+ext)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	base := filepath.Join(dir, "point")
+	for _, remove := range []string{"", ".shx", ".dbf"} {
+		if remove != "" {
+			if err := os.Remove(base + remove); err != nil {
+				t.Fatal(err)
+			}
+		}
+		r, err := Open(base + ".shp")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for r.Next() {
+		}
+		if n, err := r.Count(); n != 3 || err != nil {
+			t.Errorf("without %s: Count() = %d, %v, want 3", remove, n, err)
+		}
+		// the position of the reader is unchanged
+		if r.Next() {
+			t.Errorf("without %s: Next() after Count succeeded", remove)
+		}
+		r.Close()
+	}
+}
+
+func TestZipReaderCount(t *testing.T) {
+	for _, suffixes := range [][]string{{".shp", ".shx", ".dbf"}, {".shp", ".dbf"}, {".shp"}} {
+		dir, filename := createTempZIPWith("test_files/point", suffixes, t)
+		defer os.RemoveAll(dir)
+		zr, err := OpenZip(filepath.Join(dir, filename))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n, err := zr.Count(); n != 3 || err != nil {
+			t.Errorf("%v: Count() = %d, %v, want 3", suffixes, n, err)
+		}
+		zr.Close()
+	}
+}
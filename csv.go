@@ -0,0 +1,127 @@
+package shp
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+)
+
+// CSVGeometry selects the format of the geometry column written by WriteCSV.
+type CSVGeometry int
+
+// These are the formats of the geometry column.
+const (
+	// CSVNoGeometry writes no geometry column.
+	CSVNoGeometry CSVGeometry = iota
+	// CSVWKT writes the geometry as Well-Known Text.
+	CSVWKT
+	// CSVGeoJSON writes the geometry as a GeoJSON geometry object.
+	CSVGeoJSON
+)
+
+// CSVOptions configures WriteCSV.
+type CSVOptions struct {
+	// Geometry adds a column called "geometry" in the given format after
+	// the attributes. It is empty for Null shapes.
+	Geometry CSVGeometry
+	// XY adds the columns "X" and "Y" with the coordinates of points after
+	// the attributes. They are empty for shapes other than points.
+	XY bool
+	// Comma is the field delimiter. It is ',' if it is zero.
+	Comma rune
+}
+
+// WriteCSV writes the attribute table of r to w as CSV with a header row of
+// the field names, optionally followed by columns with the geometry of every
+// record. NULL attributes, including those of malformed rows, are empty.
+// Records are written as they are read, so the table is never held in
+// memory.
+func WriteCSV(w io.Writer, r SequentialReader, opts CSVOptions) error {
+	cw := csv.NewWriter(w)
+	if opts.Comma != 0 {
+		cw.Comma = opts.Comma
+	}
+	fields := r.Fields()
+	header := make([]string, 0, len(fields)+3)
+	for _, f := range fields {
+		header = append(header, f.String())
+	}
+	if opts.XY {
+		header = append(header, "X", "Y")
+	}
+	if opts.Geometry != CSVNoGeometry {
+		header = append(header, "geometry")
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	row := make([]string, len(header))
+	for {
+		f, err := readFeature(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		for i, a := range f.Attrs {
+			row[i] = a.Value
+			if a.Null {
+				row[i] = ""
+			}
+		}
+		s := f.Shape
+		col := len(fields)
+		if opts.XY {
+			row[col], row[col+1] = "", ""
+			if x, y, ok := pointXY(s); ok {
+				row[col] = strconv.FormatFloat(x, 'f', -1, 64)
+				row[col+1] = strconv.FormatFloat(y, 'f', -1, 64)
+			}
+			col += 2
+		}
+		if opts.Geometry != CSVNoGeometry {
+			g, err := csvGeometry(s, opts.Geometry)
+			if err != nil {
+				return err
+			}
+			row[col] = g
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// pointXY returns the coordinates of s if it is a point.
+func pointXY(s Shape) (x, y float64, ok bool) {
+	switch p := s.(type) {
+	case *Point:
+		return p.X, p.Y, true
+	case *PointZ:
+		return p.X, p.Y, true
+	case *PointM:
+		return p.X, p.Y, true
+	}
+	return 0, 0, false
+}
+
+// csvGeometry returns the geometry of s in the format g.
+func csvGeometry(s Shape, g CSVGeometry) (string, error) {
+	if _, ok := s.(*Null); ok || s == nil {
+		return "", nil
+	}
+	if g == CSVWKT {
+		return MarshalWKT(s)
+	}
+	geometry, err := geoJSONGeometryOf(s)
+	if err != nil {
+		return "", err
+	}
+	b, err := json.Marshal(geometry)
+	return string(b), err
+}
@@ -0,0 +1,83 @@
+package shp
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteCSV(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "points.shp")
+	w, err := Create(filename, POINT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SetFields([]Field{StringField("NAME", 20), NumberField("ID", 4)})
+	w.Write(&Point{1.5, 2})
+	w.WriteAttribute(0, 0, "a, b")
+	w.WriteAttribute(0, 1, 1)
+	w.Write(&Null{})
+	w.WriteAttribute(1, 0, `say "hi"`)
+	w.Close()
+
+	for _, test := range []struct {
+		name string
+		opts CSVOptions
+		want string
+	}{
+		{"attributes", CSVOptions{}, "NAME,ID\n\"a, b\",1\n\"say \"\"hi\"\"\",\n"},
+		{"WKT", CSVOptions{Geometry: CSVWKT, XY: true}, "NAME,ID,X,Y,geometry\n\"a, b\",1,1.5,2,POINT (1.5 2)\n\"say \"\"hi\"\"\",,,,\n"},
+		{"GeoJSON", CSVOptions{Geometry: CSVGeoJSON, Comma: ';'}, "NAME;ID;geometry\na, b;1;\"{\"\"type\"\":\"\"Point\"\",\"\"coordinates\"\":[1.5,2]}\"\n\"say \"\"hi\"\"\";;\n"},
+	} {
+		r, err := Open(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err := WriteCSV(&buf, r, test.opts); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		r.Close()
+		if got := buf.String(); got != test.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", test.name, got, test.want)
+		}
+	}
+}
+
+func TestWriteCSVNull(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "points.shp")
+	w, err := Create(filename, POINT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SetFields([]Field{NumberField("ID", 4), LogicalField("OK")})
+	w.Write(&Point{1, 2})
+	w.WriteAttribute(0, 0, "****")
+	w.WriteAttribute(0, 1, "?")
+	w.Close()
+
+	r, err := Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, r, CSVOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "ID,OK\n,\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
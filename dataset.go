@@ -0,0 +1,211 @@
+package shp
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Dataset is a shapefile that has been decoded entirely into memory. It can
+// be read from a file or any SequentialReader, built with NewDataset and Add,
+// filtered and sorted, and written with Save.
+type Dataset struct {
+	GeometryType ShapeType
+	BBox         Box
+	Fields       []Field
+	Shapes       []Shape
+	// Attributes holds one row per shape with one value per field.
+	Attributes [][]string
+
+	source sourceFingerprint
+}
+
+// sourceFingerprint identifies the files a Dataset was read from.
+type sourceFingerprint struct {
+	Path    string
+	SHPSize int64
+	SHPTime time.Time
+	DBFSize int64
+	DBFTime time.Time
+}
+
+func (f sourceFingerprint) equal(g sourceFingerprint) bool {
+	return f.Path == g.Path &&
+		f.SHPSize == g.SHPSize && f.SHPTime.Equal(g.SHPTime) &&
+		f.DBFSize == g.DBFSize && f.DBFTime.Equal(g.DBFTime)
+}
+
+// fingerprintSource returns the fingerprint of the shapefile at filename.
+func fingerprintSource(filename string) (sourceFingerprint, error) {
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return sourceFingerprint{}, err
+	}
+	fi, err := os.Stat(abs)
+	if err != nil {
+		return sourceFingerprint{}, err
+	}
+	f := sourceFingerprint{Path: abs, SHPSize: fi.Size(), SHPTime: fi.ModTime().UTC()}
+	if fi, err := os.Stat(strings.TrimSuffix(abs, filepath.Ext(abs)) + ".dbf"); err == nil {
+		f.DBFSize, f.DBFTime = fi.Size(), fi.ModTime().UTC()
+	}
+	return f, nil
+}
+
+// ReadDataset reads all shapes and attributes of the shapefile at filename.
+func ReadDataset(filename string, opts ...Option) (*Dataset, error) {
+	source, err := fingerprintSource(filename)
+	if err != nil {
+		return nil, err
+	}
+	r, err := Open(filename, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	d, err := ReadDatasetFrom(r)
+	if err != nil {
+		return nil, err
+	}
+	d.BBox = r.BBox()
+	d.source = source
+	return d, nil
+}
+
+// ReadDatasetFrom reads all remaining shapes and attributes from sr, e.g. a
+// ZipReader. The bounding box is computed from the shapes. The reader is not
+// closed.
+func ReadDatasetFrom(sr SequentialReader) (*Dataset, error) {
+	d := &Dataset{Fields: sr.Fields()}
+	switch r := sr.(type) {
+	case *Reader:
+		d.GeometryType = r.GeometryType
+	case *ZipReader:
+		if s, ok := r.sr.(*seqReader); ok {
+			d.GeometryType = s.geometryType
+		}
+	case *seqReader:
+		d.GeometryType = r.geometryType
+	}
+	for sr.Next() {
+		_, s := sr.Shape()
+		if d.GeometryType == NULL {
+			d.GeometryType = shapeTypeOf(s, NULL)
+		}
+		d.extendBBox(s)
+		d.Shapes = append(d.Shapes, s)
+		row := make([]string, len(d.Fields))
+		for i := range row {
+			row[i] = sr.Attribute(i)
+		}
+		d.Attributes = append(d.Attributes, row)
+	}
+	if err := sr.Err(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// NewDataset returns an empty dataset for shapes of type t with the given
+// fields. Shapes are added with Add.
+func NewDataset(t ShapeType, fields []Field) *Dataset {
+	return &Dataset{GeometryType: t, Fields: fields}
+}
+
+// Add appends the shape s with the given attributes, one per field in the
+// order of Fields, and extends the bounding box. Missing attributes are left
+// empty, which Save writes as NULL.
+func (d *Dataset) Add(s Shape, attrs ...string) error {
+	if len(attrs) > len(d.Fields) {
+		return fmt.Errorf("Unable to add shape: %d attributes for %d fields", len(attrs), len(d.Fields))
+	}
+	if t := shapeTypeOf(s, d.GeometryType); t != NULL && t != d.GeometryType {
+		return fmt.Errorf("Unable to add shape of type %v to dataset of type %v: %w", t, d.GeometryType, ErrShapeTypeMismatch)
+	}
+	row := make([]string, len(d.Fields))
+	copy(row, attrs)
+	d.extendBBox(s)
+	d.Shapes = append(d.Shapes, s)
+	d.Attributes = append(d.Attributes, row)
+	return nil
+}
+
+// extendBBox extends the bounding box by the shape s, which is about to be
+// added. Null shapes have no extent.
+func (d *Dataset) extendBBox(s Shape) {
+	if _, ok := s.(*Null); ok {
+		return
+	}
+	for _, prev := range d.Shapes {
+		if _, ok := prev.(*Null); !ok {
+			d.BBox.Extend(s.BBox())
+			return
+		}
+	}
+	d.BBox = s.BBox()
+}
+
+// Filter removes the shapes for which keep returns false, along with their
+// attributes, and shrinks the bounding box accordingly. keep is called with
+// the index of every shape.
+func (d *Dataset) Filter(keep func(i int) bool) {
+	kept := &Dataset{}
+	for i, s := range d.Shapes {
+		if keep(i) {
+			kept.extendBBox(s)
+			kept.Shapes = append(kept.Shapes, s)
+			kept.Attributes = append(kept.Attributes, d.Attributes[i])
+		}
+	}
+	d.Shapes, d.Attributes, d.BBox = kept.Shapes, kept.Attributes, kept.BBox
+}
+
+// Sort orders the shapes and their attributes by less, which compares the
+// shapes at the indices i and j. The sort is stable.
+func (d *Dataset) Sort(less func(i, j int) bool) {
+	order := make([]int, len(d.Shapes))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return less(order[a], order[b]) })
+	shapes := make([]Shape, len(order))
+	attrs := make([][]string, len(order))
+	for i, j := range order {
+		shapes[i], attrs[i] = d.Shapes[j], d.Attributes[j]
+	}
+	d.Shapes, d.Attributes = shapes, attrs
+}
+
+// SortBy orders the shapes by the values of the named field, numerically if
+// numeric is set, like WithSortBy. NULL values come last.
+func (d *Dataset) SortBy(field string, numeric bool) error {
+	n := -1
+	for i, f := range d.Fields {
+		if f.String() == field {
+			n = i
+			break
+		}
+	}
+	if n < 0 {
+		return fmt.Errorf("Unable to sort: no field %q", field)
+	}
+	keys := make([]sortKey, len(d.Shapes))
+	for i, row := range d.Attributes {
+		v := strings.TrimSpace(row[n])
+		switch {
+		case isNullValue(d.Fields[n], v):
+			keys[i] = sortKey{null: true}
+		case numeric:
+			f, err := strconv.ParseFloat(v, 64)
+			keys[i] = sortKey{null: err != nil, num: f}
+		default:
+			keys[i] = sortKey{str: v}
+		}
+	}
+	d.Sort(func(i, j int) bool { return keys[i].less(keys[j]) })
+	return nil
+}
@@ -0,0 +1,98 @@
+package shp
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDataset(t *testing.T) {
+	d := NewDataset(POINT, []Field{StringField("NAME", 10), NumberField("POP", 8)})
+	for _, add := range []struct {
+		s     Shape
+		attrs []string
+	}{
+		{&Point{5, 5}, []string{"b", "200"}},
+		{&Null{}, []string{"null"}},
+		{&Point{1, 9}, []string{"c", "30"}},
+		{&Point{3, 1}, []string{"a", "1000"}},
+	} {
+		if err := d.Add(add.s, add.attrs...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.Add(&PolyLine{}); err == nil {
+		t.Error("expected an error for a shape of another type")
+	}
+	if err := d.Add(&Point{}, "a", "1", "x"); err == nil {
+		t.Error("expected an error for too many attributes")
+	}
+	if want := (Box{1, 1, 5, 9}); d.BBox != want {
+		t.Errorf("got box %v, want %v", d.BBox, want)
+	}
+
+	if err := d.SortBy("POP", true); err != nil {
+		t.Fatal(err)
+	}
+	names := func() (s []string) {
+		for _, row := range d.Attributes {
+			s = append(s, row[0])
+		}
+		return s
+	}
+	if got := names(); !equalStrings(got, []string{"c", "b", "a", "null"}) {
+		t.Errorf("got order %v after sorting by POP", got)
+	}
+	if err := d.SortBy("NAME", false); err != nil {
+		t.Fatal(err)
+	}
+	if got := names(); !equalStrings(got, []string{"a", "b", "c", "null"}) {
+		t.Errorf("got order %v after sorting by NAME", got)
+	}
+	if err := d.SortBy("MISSING", false); err == nil {
+		t.Error("expected an error for a missing field")
+	}
+
+	d.Filter(func(i int) bool { return d.Attributes[i][0] != "c" })
+	if got := names(); !equalStrings(got, []string{"a", "b", "null"}) {
+		t.Errorf("got %v after filtering", got)
+	}
+	if want := (Box{3, 1, 5, 5}); d.BBox != want {
+		t.Errorf("got box %v after filtering, want %v", d.BBox, want)
+	}
+
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "dataset.shp")
+	if err := d.Save(filename); err != nil {
+		t.Fatal(err)
+	}
+	sr, err := OpenDataset(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sr.Close()
+	read, err := ReadDatasetFrom(sr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read.GeometryType != POINT || len(read.Shapes) != 3 || read.Attributes[1][1] != "200" {
+		t.Errorf("got %v dataset with shapes %v and attributes %v", read.GeometryType, read.Shapes, read.Attributes)
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
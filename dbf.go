@@ -0,0 +1,174 @@
+package shp
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/encoding"
+)
+
+// DBFReader reads a DBF table on its own, without a shapefile. Rows can be
+// read one after another with Next and Attribute, or in any order with
+// ReadAttribute.
+type DBFReader struct {
+	r *Reader
+}
+
+// OpenDBF opens a DBF table for reading. The charset of the attributes is
+// taken from the .cpg file next to it or the DBF header, unless it is set
+// with WithCharset.
+func OpenDBF(filename string, opts ...Option) (*DBFReader, error) {
+	r := &Reader{filename: trimExt(filename, ".dbf"), opts: newOptions(opts)}
+	if err := r.openDbf(); err != nil {
+		return nil, fmt.Errorf("Unable to open DBF file: %v", err)
+	}
+	return &DBFReader{r: r}, nil
+}
+
+// trimExt returns filename without ext, if it ends on it.
+func trimExt(filename, ext string) string {
+	if strings.HasSuffix(strings.ToLower(filename), ext) {
+		return filename[:len(filename)-len(ext)]
+	}
+	return filename
+}
+
+// Fields returns the fields of the table.
+func (d *DBFReader) Fields() []Field {
+	return d.r.Fields()
+}
+
+// AttributeCount returns the number of rows in the table.
+func (d *DBFReader) AttributeCount() int {
+	return d.r.AttributeCount()
+}
+
+// ReadAttribute returns the value of field in row as a string. Both start
+// at 0.
+func (d *DBFReader) ReadAttribute(row int, field int) string {
+	return d.r.ReadAttribute(row, field)
+}
+
+// Next advances to the next row, whose values are then available through
+// Attribute. It returns false after the last row.
+func (d *DBFReader) Next() bool {
+	return d.r.nextRow()
+}
+
+// Row returns the index of the row that was read last by Next, starting at 0.
+func (d *DBFReader) Row() int {
+	return d.r.count - 1
+}
+
+// Attribute returns the value of the n-th field of the row that was read last
+// by Next.
+func (d *DBFReader) Attribute(n int) string {
+	return d.r.Attribute(n)
+}
+
+// SetCharset makes the reader convert attributes from enc to UTF-8, like
+// Reader.SetCharset.
+func (d *DBFReader) SetCharset(enc encoding.Encoding) {
+	d.r.SetCharset(enc)
+}
+
+// Charset returns the encoding that attributes are converted from.
+func (d *DBFReader) Charset() encoding.Encoding {
+	return d.r.Charset()
+}
+
+// RecordErrors returns the malformed rows that were encountered so far.
+func (d *DBFReader) RecordErrors() []*RecordError {
+	return d.r.RecordErrors()
+}
+
+// Err returns the last error that was encountered.
+func (d *DBFReader) Err() error {
+	return d.r.Err()
+}
+
+// Close closes the table.
+func (d *DBFReader) Close() error {
+	if d.r.closed {
+		return nil
+	}
+	d.r.closed = true
+	return d.r.dbf.Close()
+}
+
+// DBFWriter writes a DBF table on its own, without a shapefile. Every row is
+// added with AddRow and then filled with the WriteAttribute methods.
+type DBFWriter struct {
+	w *Writer
+}
+
+// CreateDBF creates a DBF table with the given fields. The charset of the
+// attributes can be set with WithCharset.
+func CreateDBF(filename string, fields []Field, opts ...Option) (*DBFWriter, error) {
+	w := &Writer{filename: trimExt(filename, ".dbf"), opts: newOptions(opts)}
+	if w.opts.charset != nil {
+		if err := w.SetCharset(w.opts.charset); err != nil {
+			w.Abort()
+			return nil, err
+		}
+	}
+	if err := w.SetFields(fields); err != nil {
+		w.Abort()
+		return nil, err
+	}
+	return &DBFWriter{w: w}, nil
+}
+
+// AddRow appends a row of NULL values and returns its index, which can be used
+// in WriteAttribute.
+func (d *DBFWriter) AddRow() int {
+	d.w.writeEmptyRecord()
+	d.w.num++
+	return int(d.w.num) - 1
+}
+
+// WriteAttribute writes value into field of row, like Writer.WriteAttribute.
+func (d *DBFWriter) WriteAttribute(row int, field int, value interface{}) error {
+	if err := d.checkRow(row); err != nil {
+		return err
+	}
+	return d.w.WriteAttribute(row, field, value)
+}
+
+// WriteAttributeNull writes NULL into field of row, like
+// Writer.WriteAttributeNull.
+func (d *DBFWriter) WriteAttributeNull(row int, field int) error {
+	if err := d.checkRow(row); err != nil {
+		return err
+	}
+	return d.w.WriteAttributeNull(row, field)
+}
+
+// WriteAttributeDate writes the date y-m-d into field of row, like
+// Writer.WriteAttributeDate.
+func (d *DBFWriter) WriteAttributeDate(row int, field int, y, m, day int) error {
+	if err := d.checkRow(row); err != nil {
+		return err
+	}
+	return d.w.WriteAttributeDate(row, field, y, m, day)
+}
+
+// checkRow returns an error unless row was added with AddRow, since writing
+// past the last row would leave a gap in the table.
+func (d *DBFWriter) checkRow(row int) error {
+	if row < 0 || row >= int(d.w.num) {
+		return fmt.Errorf("Unable to write row %d: out of range [0, %d)", row, d.w.num)
+	}
+	return nil
+}
+
+// Close writes the header of the table and closes it.
+func (d *DBFWriter) Close() error {
+	d.w.writeDbfHeader(d.w.dbf)
+	return d.w.dbf.Close()
+}
+
+// Abort closes the table and removes the files that CreateDBF created.
+func (d *DBFWriter) Abort() error {
+	return d.w.Abort()
+}
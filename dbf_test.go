@@ -0,0 +1,77 @@
+package shp
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestDBF(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "table.dbf")
+	fields := []Field{StringField("NAME", 10), NumberField("ID", 4), DateField("DAY")}
+	w, err := CreateDBF(filename, fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		row := w.AddRow()
+		if row != i {
+			t.Errorf("got row %d, want %d", row, i)
+		}
+		w.WriteAttribute(row, 0, "row "+strconv.Itoa(i))
+		w.WriteAttribute(row, 1, i)
+		if err := w.WriteAttributeDate(row, 2, 2020, 1, i+1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.WriteAttribute(3, 1, 3); err == nil {
+		t.Error("expected an error for a row that was not added")
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "table.shp")); !os.IsNotExist(err) {
+		t.Errorf("CreateDBF created a .shp: %v", err)
+	}
+
+	r, err := OpenDBF(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if n := r.AttributeCount(); n != 3 {
+		t.Fatalf("got %d rows, want 3", n)
+	}
+	if len(r.Fields()) != len(fields) || r.Fields()[1].String() != "ID" {
+		t.Errorf("got fields %v", r.Fields())
+	}
+	for r.Next() {
+		i := r.Row()
+		if got, want := r.Attribute(0), "row "+strconv.Itoa(i); got != want {
+			t.Errorf("row %d: got name %q, want %q", i, got, want)
+		}
+		if got := r.Attribute(1); got != strconv.Itoa(i) {
+			t.Errorf("row %d: got id %q", i, got)
+		}
+		if got, want := r.ReadAttribute(i, 2), "2020010"+strconv.Itoa(i+1); got != want {
+			t.Errorf("row %d: got date %q, want %q", i, got, want)
+		}
+	}
+	if err := r.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if r.Row() != 2 {
+		t.Errorf("stopped at row %d, want 2", r.Row())
+	}
+
+	if _, err := OpenDBF(filepath.Join(dir, "missing")); err == nil {
+		t.Error("expected an error for a missing table")
+	}
+}
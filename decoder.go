@@ -0,0 +1,247 @@
+package shp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// ShapeDecoder decodes the content of a record, excluding the leading shape
+// type, into a Shape.
+type ShapeDecoder func(content []byte) (Shape, error)
+
+// ShapeEncoder encodes a Shape into the content of a record, excluding the
+// leading shape type.
+type ShapeEncoder func(Shape) ([]byte, error)
+
+type shapeCodec struct {
+	decode ShapeDecoder
+	encode ShapeEncoder
+}
+
+var codecs = struct {
+	sync.RWMutex
+	m map[ShapeType]shapeCodec
+}{m: make(map[ShapeType]shapeCodec)}
+
+// RegisterShapeDecoder registers functions that handle records of a shape type
+// that is not part of the ESRI specification, e.g. vendor-specific extensions.
+// Readers use decode for records with the given code and Writers whose
+// GeometryType is code use encode. Shapes returned by decode must embed
+// CustomShape. It is an error to register a code twice or to register one of
+// the built-in codes. RegisterShapeDecoder is safe for concurrent use.
+func RegisterShapeDecoder(code int32, decode ShapeDecoder, encode ShapeEncoder) error {
+	t := ShapeType(code)
+	if _, err := newShape(t); err == nil {
+		return fmt.Errorf("Cannot register built-in shape type %v", t)
+	}
+	if decode == nil || encode == nil {
+		return fmt.Errorf("Cannot register shape type %d without decoder and encoder", code)
+	}
+	codecs.Lock()
+	defer codecs.Unlock()
+	if _, ok := codecs.m[t]; ok {
+		return fmt.Errorf("Shape type %d is already registered", code)
+	}
+	codecs.m[t] = shapeCodec{decode: decode, encode: encode}
+	return nil
+}
+
+func lookupShapeCodec(t ShapeType) (shapeCodec, bool) {
+	codecs.RLock()
+	defer codecs.RUnlock()
+	c, ok := codecs.m[t]
+	return c, ok
+}
+
+// knownShapeType reports whether records of type t can be decoded.
+func knownShapeType(t ShapeType) bool {
+	if _, err := newShape(t); err == nil {
+		return true
+	}
+	_, ok := lookupShapeCodec(t)
+	return ok
+}
+
+// readShape decodes a shape of type t from content, the record content
+// following the shape type. Z shapes without measures are completed with
+// NoData measures. Content that is too short for the counts it declares is
+// rejected rather than allocating for them.
+func readShape(t ShapeType, content []byte) (Shape, error) {
+	if c, ok := lookupShapeCodec(t); ok {
+		return c.decode(content)
+	}
+	s, err := newShape(t)
+	if err != nil {
+		return nil, err
+	}
+	if err := decodeShape(s, t, content); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// decodeShape decodes content into s, a built-in shape of type t, reusing
+// its slices where they have the capacity. It is shared by readShape and
+// RawShape.DecodeInto.
+func decodeShape(s Shape, t ShapeType, content []byte) error {
+	// the counts are checked before they are used to allocate the points
+	if !plausibleContent(t, content) {
+		return fmt.Errorf("Invalid record content: %d bytes are too short for the counts of the %v", len(content), t)
+	}
+	if err := checkPartOffsets(t, content); err != nil {
+		return err
+	}
+	if m := missingMeasures(t, content); m != nil {
+		content = append(append([]byte(nil), content...), m...)
+	}
+	er := &errReader{Reader: bytes.NewReader(content)}
+	s.read(er)
+	return er.e
+}
+
+// checkPartOffsets checks that the part offsets in content, the record
+// content following the shape type, are non-negative, non-decreasing and at
+// most the number of points, so that the parts of a decoded shape can be
+// sliced from its points. The counts must have been checked by
+// plausibleContent.
+func checkPartOffsets(t ShapeType, content []byte) error {
+	switch t {
+	case POLYLINE, POLYGON, POLYLINEM, POLYGONM, POLYLINEZ, POLYGONZ, MULTIPATCH:
+	default:
+		return nil
+	}
+	count := func(offset int) int32 {
+		return int32(binary.LittleEndian.Uint32(content[offset:]))
+	}
+	parts, n := count(32), count(36)
+	prev := int32(0)
+	for i := int32(0); i < parts; i++ {
+		p := count(40 + 4*int(i))
+		if p < prev || p > n {
+			return fmt.Errorf("Invalid record content: part %d starts at point %d of %d", i, p, n)
+		}
+		prev = p
+	}
+	return nil
+}
+
+// decodedRecord is a record that decodeRecord decoded or skipped.
+type decodedRecord struct {
+	shape   Shape
+	skipped bool
+	// warning explains why a record was skipped in lenient mode.
+	warning *Warning
+	// err stops the reader unless the record was skipped in lenient mode.
+	err *RecordError
+}
+
+// decodeRecord decodes content, the content of the num-th record of a file,
+// which starts at offset, with the options of a reader. Records outside of
+// filter are skipped, and so are records that cannot be decoded in lenient
+// mode. The shape is transformed by transform if it is not nil.
+func decodeRecord(content []byte, num int, offset int64, opts *options, filter *Box, transform Transform) decodedRecord {
+	var d decodedRecord
+	shapetype := ShapeType(binary.LittleEndian.Uint32(content[0:4]))
+	if !knownShapeType(shapetype) {
+		d.err = &RecordError{RecordNum: num, Offset: offset,
+			Err: fmt.Errorf("Error decoding shape type: %w: %v", ErrUnsupportedShapeType, shapetype)}
+		if opts.lenient {
+			d.skipped = true
+			d.warning = &Warning{
+				Kind:    WarnSkippedRecord,
+				Message: fmt.Sprintf("skipped record %d of unsupported shape type %v", num, shapetype),
+			}
+		}
+		return d
+	}
+	if filtered(filter, shapetype, content[4:], opts.swapXY) {
+		d.skipped = true
+		return d
+	}
+	shape, err := readShape(shapetype, content[4:])
+	if err != nil {
+		d.err = &RecordError{RecordNum: num, Offset: offset, Err: fmt.Errorf("Error while reading next shape: %v", err)}
+		d.skipped = opts.lenient
+		return d
+	}
+	if opts.swapXY {
+		transformPoints(shape, swapPoint)
+	}
+	if transform != nil {
+		transformShape(shape, transform)
+	}
+	d.shape = shape
+	return d
+}
+
+// encodeShape encodes s as the content of a record of type t, excluding the
+// leading shape type.
+func encodeShape(t ShapeType, s Shape) ([]byte, error) {
+	if c, ok := lookupShapeCodec(t); ok {
+		content, err := c.encode(s)
+		if err != nil {
+			return nil, fmt.Errorf("Error encoding shape of type %v: %v", t, err)
+		}
+		return content, nil
+	}
+	var buf bytes.Buffer
+	s.write(&buf)
+	return buf.Bytes(), nil
+}
+
+// EncodeRecordContent encodes s as the content of a .shp record, which
+// starts with the shape type and excludes the record header. Shapes of
+// registered types must have a method ShapeType() ShapeType that returns their
+// type.
+func EncodeRecordContent(s Shape) ([]byte, error) {
+	var t ShapeType
+	switch v := s.(type) {
+	case nil:
+		return nil, errors.New("Cannot encode nil shape")
+	case interface{ ShapeType() ShapeType }:
+		t = v.ShapeType()
+	default:
+		t = shapeTypeOf(s, NULL)
+		if _, ok := s.(*Null); t == NULL && !ok {
+			return nil, fmt.Errorf("Cannot determine shape type of %T", s)
+		}
+	}
+	content, err := encodeShape(t, s)
+	if err != nil {
+		return nil, err
+	}
+	b := make([]byte, 4, 4+len(content))
+	binary.LittleEndian.PutUint32(b, uint32(t))
+	return append(b, content...), nil
+}
+
+// DecodeRecordContent decodes the content of a .shp record, which starts with
+// the shape type and excludes the record header. shapeType is the type of the
+// shapefile the record belongs to; the record must be of that type or NULL.
+// Z shapes without measures are completed with NoData measures.
+func DecodeRecordContent(shapeType ShapeType, b []byte) (Shape, error) {
+	if len(b) < 4 {
+		return nil, fmt.Errorf("Record content of %d bytes is too short", len(b))
+	}
+	t := ShapeType(binary.LittleEndian.Uint32(b))
+	if t != shapeType && t != NULL {
+		return nil, fmt.Errorf("Record of shape type %v in shapefile of type %v", t, shapeType)
+	}
+	return readShape(t, b[4:])
+}
+
+// CustomShape must be embedded by shapes that are returned by a registered
+// ShapeDecoder. The embedding type is expected to provide its own BBox.
+type CustomShape struct{}
+
+// BBox returns an empty box. Embedding types should override it.
+func (CustomShape) BBox() Box {
+	return Box{}
+}
+
+func (CustomShape) read(io.Reader)  {}
+func (CustomShape) write(io.Writer) {}
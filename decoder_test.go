@@ -0,0 +1,385 @@
+package shp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// circle is a vendor-specific shape used to test registered decoders.
+type circle struct {
+	CustomShape
+	X, Y, R float64
+}
+
+func (c *circle) BBox() Box {
+	return Box{c.X - c.R, c.Y - c.R, c.X + c.R, c.Y + c.R}
+}
+
+func (c *circle) ShapeType() ShapeType {
+	return circleType
+}
+
+const circleType = 1001
+
+func init() {
+	err := RegisterShapeDecoder(circleType, func(b []byte) (Shape, error) {
+		var v [3]float64
+		if err := binary.Read(bytes.NewReader(b), binary.LittleEndian, &v); err != nil {
+			return nil, err
+		}
+		return &circle{X: v[0], Y: v[1], R: v[2]}, nil
+	}, func(s Shape) ([]byte, error) {
+		c, ok := s.(*circle)
+		if !ok {
+			return nil, errors.New("not a circle")
+		}
+		buf := new(bytes.Buffer)
+		binary.Write(buf, binary.LittleEndian, []float64{c.X, c.Y, c.R})
+		return buf.Bytes(), nil
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+func TestRegisterShapeDecoderRejected(t *testing.T) {
+	decode := func([]byte) (Shape, error) { return nil, nil }
+	encode := func(Shape) ([]byte, error) { return nil, nil }
+	if err := RegisterShapeDecoder(int32(POINT), decode, encode); err == nil {
+		t.Error("registered built-in shape type")
+	}
+	if err := RegisterShapeDecoder(circleType, decode, encode); err == nil {
+		t.Error("registered shape type twice")
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- RegisterShapeDecoder(2000, decode, encode)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	n := 0
+	for err := range errs {
+		if err == nil {
+			n++
+		}
+	}
+	if n != 1 {
+		t.Errorf("concurrent registration succeeded %d times, want 1", n)
+	}
+}
+
+func TestRegisteredShapeRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "circles")
+
+	circles := []*circle{{X: 1, Y: 2, R: 3}, {X: -5, Y: 5, R: 0.5}}
+	w, err := Create(filename, circleType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range circles {
+		w.Write(c)
+	}
+	if n := w.Write(&Point{}); n != -1 || w.Err() == nil {
+		t.Errorf("wrote unencodable shape as %d with error %v", n, w.Err())
+	}
+	w.Close()
+
+	for name, getter := range map[string]shapeGetterFunc{
+		"reader":    getShapesFromFile,
+		"seqReader": getShapesSequentially,
+	} {
+		shapes := getter(filename, t)
+		if len(shapes) != len(circles) {
+			t.Fatalf("%s: got %d shapes, want %d", name, len(shapes), len(circles))
+		}
+		for i, s := range shapes {
+			c, ok := s.(*circle)
+			if !ok {
+				t.Fatalf("%s: got %T, want *circle", name, s)
+			}
+			if c.X != circles[i].X || c.Y != circles[i].Y || c.R != circles[i].R {
+				t.Errorf("%s: got %+v, want %+v", name, c, circles[i])
+			}
+		}
+	}
+}
+
+// recordBytes encodes a record with the given number, shape type and content.
+func recordBytes(num int32, t ShapeType, content []byte) []byte {
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.BigEndian, []int32{num, int32(len(content)+4) / 2})
+	binary.Write(buf, binary.LittleEndian, t)
+	buf.Write(content)
+	return buf.Bytes()
+}
+
+func TestLenientSkipsUnknownShapeType(t *testing.T) {
+	point := func(x, y float64) []byte {
+		buf := new(bytes.Buffer)
+		binary.Write(buf, binary.LittleEndian, Point{x, y})
+		return buf.Bytes()
+	}
+	var records []byte
+	records = append(records, recordBytes(1, POINT, point(1, 1))...)
+	records = append(records, recordBytes(2, 77, make([]byte, 12))...)
+	records = append(records, recordBytes(3, POINT, point(3, 3))...)
+
+	for _, lenient := range []bool{false, true} {
+		var opts options
+		if lenient {
+			WithLenient()(&opts)
+		}
+		readers := map[string]interface {
+			Next() bool
+			Shape() (int, Shape)
+			Err() error
+			Warnings() []Warning
+		}{
+			"reader":    &Reader{shp: newReadSeekCloser(records), filelength: int64(len(records)), opts: opts},
+			"seqReader": &seqReader{shp: newReadSeekCloser(records), opts: opts},
+		}
+		for name, r := range readers {
+			var got []int
+			for r.Next() {
+				n, _ := r.Shape()
+				got = append(got, n)
+			}
+			if !lenient {
+				if r.Err() == nil || len(got) != 1 {
+					t.Errorf("%s: read %v with error %v, want stop after first record", name, got, r.Err())
+				}
+				continue
+			}
+			if r.Err() != nil {
+				t.Errorf("%s: got error %v in lenient mode", name, r.Err())
+			}
+			if len(got) != 2 || got[0] != 0 || got[1] != 2 {
+				t.Errorf("%s: read records %v, want [0 2]", name, got)
+			}
+			if ws := r.Warnings(); len(ws) != 1 || ws[0].Kind != WarnSkippedRecord {
+				t.Errorf("%s: got warnings %v, want one skipped record", name, ws)
+			}
+		}
+	}
+}
+
+// recordContents returns the content of every record of the shapefile name,
+// read directly from the file.
+func recordContents(t *testing.T, name string) [][]byte {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var contents [][]byte
+	for offset := 100; offset+8 <= len(data); {
+		size := int(binary.BigEndian.Uint32(data[offset+4:])) * 2
+		contents = append(contents, data[offset+8:offset+8+size])
+		offset += 8 + size
+	}
+	return contents
+}
+
+func TestRecordContentRoundTrip(t *testing.T) {
+	for _, name := range []string{
+		"point", "polyline", "polygon", "multipoint",
+		"pointz", "polylinez", "polygonz", "multipointz",
+		"pointm", "polylinem", "polygonm", "multipointm",
+		"multipatch",
+	} {
+		filename := "test_files/" + name + ".shp"
+		typ, shapes := readAll(t, filename)
+		contents := recordContents(t, filename)
+		if len(contents) != len(shapes) {
+			t.Fatalf("%s: got %d records, want %d", name, len(contents), len(shapes))
+		}
+		for i, content := range contents {
+			s, err := DecodeRecordContent(typ, content)
+			if err != nil {
+				t.Fatalf("%s: record %d: %v", name, i, err)
+			}
+			if !reflect.DeepEqual(s, shapes[i]) {
+				t.Errorf("%s: record %d: decoded %#v, want %#v", name, i, s, shapes[i])
+			}
+			b, err := EncodeRecordContent(s)
+			if err != nil {
+				t.Fatalf("%s: record %d: %v", name, i, err)
+			}
+			if !bytes.Equal(b, content) {
+				t.Errorf("%s: record %d: encoded % x, want % x", name, i, b, content)
+			}
+		}
+	}
+}
+
+func TestRecordContentGolden(t *testing.T) {
+	tests := []struct {
+		shape Shape
+		want  string
+	}{
+		{&Null{}, "00000000"},
+		{&Point{1, 2}, "01000000" + "000000000000f03f" + "0000000000000040"},
+		{&PointM{1, 2, -1}, "15000000" + "000000000000f03f" + "0000000000000040" + "000000000000f0bf"},
+		{&MultiPoint{Box: Box{1, 2, 1, 2}, NumPoints: 1, Points: []Point{{1, 2}}},
+			"08000000" + "000000000000f03f" + "0000000000000040" + "000000000000f03f" + "0000000000000040" +
+				"01000000" + "000000000000f03f" + "0000000000000040"},
+		{&circle{X: 1, Y: 2, R: 1}, "e9030000" + "000000000000f03f" + "0000000000000040" + "000000000000f03f"},
+	}
+	for _, test := range tests {
+		b, err := EncodeRecordContent(test.shape)
+		if err != nil {
+			t.Fatalf("%T: %v", test.shape, err)
+		}
+		if got := hex.EncodeToString(b); got != test.want {
+			t.Errorf("%T: got %s, want %s", test.shape, got, test.want)
+		}
+		typ := ShapeType(binary.LittleEndian.Uint32(b))
+		s, err := DecodeRecordContent(typ, b)
+		if err != nil {
+			t.Fatalf("%T: %v", test.shape, err)
+		}
+		if !reflect.DeepEqual(s, test.shape) {
+			t.Errorf("%T: decoded %#v, want %#v", test.shape, s, test.shape)
+		}
+	}
+}
+
+func TestRecordContentErrors(t *testing.T) {
+	if _, err := EncodeRecordContent(nil); err == nil {
+		t.Error("expected an error for a nil shape")
+	}
+	if _, err := DecodeRecordContent(POINT, []byte{1, 0}); err == nil {
+		t.Error("expected an error for short content")
+	}
+	b, _ := EncodeRecordContent(&Point{1, 2})
+	if _, err := DecodeRecordContent(POLYLINE, b); err == nil {
+		t.Error("expected an error for a record of another type")
+	}
+	if s, err := DecodeRecordContent(POLYLINE, []byte{0, 0, 0, 0}); err != nil || !reflect.DeepEqual(s, &Null{}) {
+		t.Errorf("got %v, %v for a NULL record", s, err)
+	}
+	if _, err := DecodeRecordContent(POINT, b[:12]); err == nil {
+		t.Error("expected an error for truncated content")
+	}
+}
+
+func TestCorruptCountsAreRejected(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "corrupt.shp")
+	w, err := Create(filename, POLYLINE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(NewPolyLine([][]Point{{{0, 0}, {1, 1}}}))
+	w.Close()
+	valid, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the counts follow the record header, the shape type and the box
+	for _, c := range []struct {
+		name   string
+		offset int
+		count  int32
+	}{{"negative parts", 144, -1}, {"huge parts", 144, 1 << 30}, {"negative points", 148, -1}, {"huge points", 148, 1 << 30}} {
+		data := append([]byte(nil), valid...)
+		binary.LittleEndian.PutUint32(data[c.offset:], uint32(c.count))
+		if err := ioutil.WriteFile(filename, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := DecodeRecordContent(POLYLINE, data[108:]); err == nil {
+			t.Errorf("%s: DecodeRecordContent returned no error", c.name)
+		}
+		readers := map[string]func() (SequentialReader, error){
+			"Reader":         func() (SequentialReader, error) { return Open(filename) },
+			"mapped Reader":  func() (SequentialReader, error) { return OpenMmap(filename) },
+			"seqReader":      func() (SequentialReader, error) { return OpenDataset(filename) },
+			"ParallelReader": func() (SequentialReader, error) { return OpenParallel(filename, 2) },
+		}
+		for name, open := range readers {
+			r, err := open()
+			if err != nil {
+				t.Fatalf("%s: %s: %v", c.name, name, err)
+			}
+			if r.Next() || r.Err() == nil {
+				t.Errorf("%s: %s: got no error", c.name, name)
+			}
+			r.Close()
+		}
+		ir, err := OpenIndexed(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ir.ShapeAt(0); err == nil {
+			t.Errorf("%s: IndexedReader: got no error", c.name)
+		}
+		ir.Close()
+	}
+}
+
+func TestCorruptPartOffsetsAreRejected(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "parts.shp")
+	w, err := Create(filename, POLYLINE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(NewPolyLine([][]Point{{{0, 0}, {1, 1}}, {{2, 2}, {3, 3}}}))
+	w.Close()
+	valid, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the part offsets follow the counts at 144 and 148
+	for _, c := range []struct {
+		name  string
+		parts [2]int32
+	}{{"negative", [2]int32{-1, 2}}, {"decreasing", [2]int32{2, 1}}, {"beyond the points", [2]int32{0, 5}}} {
+		data := append([]byte(nil), valid...)
+		binary.LittleEndian.PutUint32(data[152:], uint32(c.parts[0]))
+		binary.LittleEndian.PutUint32(data[156:], uint32(c.parts[1]))
+		if err := ioutil.WriteFile(filename, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := DecodeRecordContent(POLYLINE, data[108:]); err == nil {
+			t.Errorf("%s: DecodeRecordContent returned no error", c.name)
+		}
+		r, err := Open(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Next() || r.Err() == nil {
+			t.Errorf("%s: got no error", c.name)
+		}
+		r.Close()
+	}
+}
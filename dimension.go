@@ -0,0 +1,160 @@
+package shp
+
+import "fmt"
+
+// dimensions describes a shape type by its 2D type and whether it has Z values
+// and measures.
+type dimensions struct {
+	base ShapeType
+	z, m bool
+}
+
+var shapeDimensions = map[ShapeType]dimensions{
+	POINT:       {POINT, false, false},
+	POLYLINE:    {POLYLINE, false, false},
+	POLYGON:     {POLYGON, false, false},
+	MULTIPOINT:  {MULTIPOINT, false, false},
+	POINTZ:      {POINT, true, true},
+	POLYLINEZ:   {POLYLINE, true, true},
+	POLYGONZ:    {POLYGON, true, true},
+	MULTIPOINTZ: {MULTIPOINT, true, true},
+	POINTM:      {POINT, false, true},
+	POLYLINEM:   {POLYLINE, false, true},
+	POLYGONM:    {POLYGON, false, true},
+	MULTIPOINTM: {MULTIPOINT, false, true},
+}
+
+// Promote converts s into a shape of type t with the same 2D geometry and
+// more dimensions, e.g. a PolyLine into a PolyLineM or PolyLineZ, or a
+// PointM into a PointZ. Z values that s does not have are 0 and measures are
+// NoData. Null shapes are returned as they are, and it is an error if t
+// would drop the Z values or measures of s.
+func Promote(s Shape, t ShapeType) (Shape, error) {
+	return convertDimensions(s, t, true)
+}
+
+// Demote converts s into a shape of type t with the same 2D geometry and
+// fewer dimensions, e.g. a PolyLineZ into a PolyLineM or PolyLine, dropping
+// the Z values and/or measures of s. Null shapes are returned as they are,
+// and it is an error if t has dimensions that s does not have.
+func Demote(s Shape, t ShapeType) (Shape, error) {
+	return convertDimensions(s, t, false)
+}
+
+// convertDimensions converts s into a shape of type t, which must have at
+// least the dimensions of s if promote is set and at most those otherwise.
+func convertDimensions(s Shape, t ShapeType, promote bool) (Shape, error) {
+	if _, ok := s.(*Null); ok {
+		return s, nil
+	}
+	from, ok := shapeDimensions[shapeTypeOf(s, 0)]
+	if !ok {
+		return nil, fmt.Errorf("Unable to convert %T: unsupported shape type", s)
+	}
+	to, ok := shapeDimensions[t]
+	if !ok || to.base != from.base {
+		return nil, fmt.Errorf("Unable to convert %T to %v", s, t)
+	}
+	if promote && (from.z && !to.z || from.m && !to.m) {
+		return nil, fmt.Errorf("Unable to promote %T to %v: it has fewer dimensions", s, t)
+	}
+	if !promote && (to.z && !from.z || to.m && !from.m) {
+		return nil, fmt.Errorf("Unable to demote %T to %v: it has more dimensions", s, t)
+	}
+
+	c := coordsOf(s)
+	if !to.z {
+		c.z = nil
+	} else if c.z == nil {
+		c.z = make([]float64, len(c.points))
+	}
+	if !to.m {
+		c.m = nil
+	} else if c.m == nil {
+		c.m = nodataArray(len(c.points))
+	}
+	return c.shape(t), nil
+}
+
+// shapeCoords holds the coordinates of a shape of any dimension. z and m are
+// nil if the shape has no Z values or measures.
+type shapeCoords struct {
+	box    Box
+	parts  []int32
+	points []Point
+	z, m   []float64
+}
+
+// coordsOf returns the coordinates of s, which must be one of the types in
+// shapeDimensions. The slices are copied.
+func coordsOf(s Shape) shapeCoords {
+	var c shapeCoords
+	switch s := s.(type) {
+	case *Point:
+		c.points = []Point{*s}
+	case *PointZ:
+		c.points, c.z, c.m = []Point{{s.X, s.Y}}, []float64{s.Z}, []float64{s.M}
+	case *PointM:
+		c.points, c.m = []Point{{s.X, s.Y}}, []float64{s.M}
+	case *MultiPoint:
+		c.box, c.points = s.Box, s.Points
+	case *MultiPointZ:
+		c.box, c.points, c.z, c.m = s.Box, s.Points, s.ZArray, s.MArray
+	case *MultiPointM:
+		c.box, c.points, c.m = s.Box, s.Points, s.MArray
+	case *PolyLine:
+		c.box, c.parts, c.points = s.Box, s.Parts, s.Points
+	case *Polygon:
+		c.box, c.parts, c.points = s.Box, s.Parts, s.Points
+	case *PolyLineZ:
+		c.box, c.parts, c.points, c.z, c.m = s.Box, s.Parts, s.Points, s.ZArray, s.MArray
+	case *PolygonZ:
+		c.box, c.parts, c.points, c.z, c.m = s.Box, s.Parts, s.Points, s.ZArray, s.MArray
+	case *PolyLineM:
+		c.box, c.parts, c.points, c.m = s.Box, s.Parts, s.Points, s.MArray
+	case *PolygonM:
+		c.box, c.parts, c.points, c.m = s.Box, s.Parts, s.Points, s.MArray
+	}
+	c.parts = append([]int32(nil), c.parts...)
+	c.points = append([]Point(nil), c.points...)
+	if c.z != nil {
+		c.z = append([]float64(nil), c.z...)
+	}
+	if c.m != nil {
+		c.m = append([]float64(nil), c.m...)
+	}
+	return c
+}
+
+// shape returns the shape of type t with the coordinates of c.
+func (c shapeCoords) shape(t ShapeType) Shape {
+	numParts, numPoints := int32(len(c.parts)), int32(len(c.points))
+	zr, mr := valueRange(c.z), measureRange(c.m)
+	switch t {
+	case POINT:
+		p := c.points[0]
+		return &p
+	case POINTZ:
+		return &PointZ{c.points[0].X, c.points[0].Y, c.z[0], c.m[0]}
+	case POINTM:
+		return &PointM{c.points[0].X, c.points[0].Y, c.m[0]}
+	case MULTIPOINT:
+		return &MultiPoint{Box: c.box, NumPoints: numPoints, Points: c.points}
+	case MULTIPOINTZ:
+		return &MultiPointZ{Box: c.box, NumPoints: numPoints, Points: c.points, ZRange: zr, ZArray: c.z, MRange: mr, MArray: c.m}
+	case MULTIPOINTM:
+		return &MultiPointM{Box: c.box, NumPoints: numPoints, Points: c.points, MRange: mr, MArray: c.m}
+	case POLYLINE:
+		return &PolyLine{Box: c.box, NumParts: numParts, NumPoints: numPoints, Parts: c.parts, Points: c.points}
+	case POLYGON:
+		return &Polygon{Box: c.box, NumParts: numParts, NumPoints: numPoints, Parts: c.parts, Points: c.points}
+	case POLYLINEZ:
+		return &PolyLineZ{Box: c.box, NumParts: numParts, NumPoints: numPoints, Parts: c.parts, Points: c.points, ZRange: zr, ZArray: c.z, MRange: mr, MArray: c.m}
+	case POLYGONZ:
+		return &PolygonZ{Box: c.box, NumParts: numParts, NumPoints: numPoints, Parts: c.parts, Points: c.points, ZRange: zr, ZArray: c.z, MRange: mr, MArray: c.m}
+	case POLYLINEM:
+		return &PolyLineM{Box: c.box, NumParts: numParts, NumPoints: numPoints, Parts: c.parts, Points: c.points, MRange: mr, MArray: c.m}
+	default:
+		return &PolygonM{Box: c.box, NumParts: numParts, NumPoints: numPoints, Parts: c.parts, Points: c.points, MRange: mr, MArray: c.m}
+	}
+}
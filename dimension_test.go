@@ -0,0 +1,67 @@
+package shp
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPromoteDemote(t *testing.T) {
+	line := NewPolyLine([][]Point{{{0, 0}, {1, 1}}, {{2, 2}, {3, 0}}})
+	s, err := Promote(line, POLYLINEZ)
+	if err != nil {
+		t.Fatal(err)
+	}
+	z, ok := s.(*PolyLineZ)
+	if !ok {
+		t.Fatalf("got %T, want *PolyLineZ", s)
+	}
+	if !reflect.DeepEqual(z.Points, line.Points) || !reflect.DeepEqual(z.Parts, line.Parts) || z.Box != line.Box {
+		t.Errorf("got %#v, want the geometry of %#v", z, line)
+	}
+	if !reflect.DeepEqual(z.ZArray, []float64{0, 0, 0, 0}) || !reflect.DeepEqual(z.MArray, nodataArray(4)) {
+		t.Errorf("got Z %v and M %v, want 0 and NoData", z.ZArray, z.MArray)
+	}
+
+	z.MArray = []float64{1, 2, 3, 4}
+	s, err = Demote(z, POLYLINEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m, ok := s.(*PolyLineM); !ok || !reflect.DeepEqual(m.MArray, z.MArray) || m.MRange != [2]float64{1, 4} {
+		t.Errorf("got %#v, want the measures of %#v", s, z)
+	}
+	s, err = Demote(z, POLYLINE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(s, line) {
+		t.Errorf("got %#v, want %#v", s, line)
+	}
+
+	s, err = Promote(&PointM{1, 2, 3}, POINTZ)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (&PointZ{1, 2, 0, 3}); !reflect.DeepEqual(s, want) {
+		t.Errorf("got %#v, want %#v", s, want)
+	}
+	if s, err := Promote(&Null{}, POLYGONZ); err != nil || !reflect.DeepEqual(s, &Null{}) {
+		t.Errorf("got %#v, %v for a Null shape", s, err)
+	}
+
+	for _, test := range []struct {
+		name    string
+		s       Shape
+		t       ShapeType
+		promote bool
+	}{
+		{"promote to fewer dimensions", &PointZ{}, POINTM, true},
+		{"demote to more dimensions", &Point{}, POINTZ, false},
+		{"other geometry", line, POLYGONZ, true},
+		{"MultiPatch", &MultiPatch{}, MULTIPATCH, true},
+	} {
+		if _, err := convertDimensions(test.s, test.t, test.promote); err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+}
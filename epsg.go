@@ -0,0 +1,87 @@
+package shp
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	wktDegree = `UNIT["Degree",0.0174532925199433]`
+
+	wktGCSWGS84 = `GEOGCS["GCS_WGS_1984",DATUM["D_WGS_1984",SPHEROID["WGS_1984",6378137.0,298.257223563]],PRIMEM["Greenwich",0.0],` + wktDegree + `]`
+	wktGCSNAD83 = `GEOGCS["GCS_North_American_1983",DATUM["D_North_American_1983",SPHEROID["GRS_1980",6378137.0,298.257222101]],PRIMEM["Greenwich",0.0],` + wktDegree + `]`
+	wktGCSETRS  = `GEOGCS["GCS_ETRS_1989",DATUM["D_ETRS_1989",SPHEROID["GRS_1980",6378137.0,298.257222101]],PRIMEM["Greenwich",0.0],` + wktDegree + `]`
+)
+
+// epsgWKT holds the ESRI Well-Known Text of the EPSG codes that are not
+// computed by epsgUTM.
+var epsgWKT = map[int]string{
+	4326: wktGCSWGS84,
+	4269: wktGCSNAD83,
+	4258: wktGCSETRS,
+	3857: `PROJCS["WGS_1984_Web_Mercator_Auxiliary_Sphere",` + wktGCSWGS84 + `,PROJECTION["Mercator_Auxiliary_Sphere"],` +
+		`PARAMETER["False_Easting",0.0],PARAMETER["False_Northing",0.0],PARAMETER["Central_Meridian",0.0],` +
+		`PARAMETER["Standard_Parallel_1",0.0],PARAMETER["Auxiliary_Sphere_Type",0.0],UNIT["Meter",1.0]]`,
+}
+
+// epsgUTM returns the ESRI Well-Known Text of the UTM zones of WGS 84 (EPSG
+// 32601 to 32660 and 32701 to 32760) and ETRS89 (EPSG 25828 to 25838).
+func epsgUTM(code int) (string, bool) {
+	var name, gcs string
+	var zone int
+	south := false
+	switch {
+	case code >= 32601 && code <= 32660:
+		name, gcs, zone = "WGS_1984", wktGCSWGS84, code-32600
+	case code >= 32701 && code <= 32760:
+		name, gcs, zone, south = "WGS_1984", wktGCSWGS84, code-32700, true
+	case code >= 25828 && code <= 25838:
+		name, gcs, zone = "ETRS_1989", wktGCSETRS, code-25800
+	default:
+		return "", false
+	}
+	hemisphere, northing := "N", "0.0"
+	if south {
+		hemisphere, northing = "S", "10000000.0"
+	}
+	meridian := strconv.Itoa(-183+6*zone) + ".0"
+	return `PROJCS["` + name + `_UTM_Zone_` + strconv.Itoa(zone) + hemisphere + `",` + gcs + `,PROJECTION["Transverse_Mercator"],` +
+		`PARAMETER["False_Easting",500000.0],PARAMETER["False_Northing",` + northing + `],` +
+		`PARAMETER["Central_Meridian",` + meridian + `],PARAMETER["Scale_Factor",0.9996],` +
+		`PARAMETER["Latitude_Of_Origin",0.0],UNIT["Meter",1.0]]`, true
+}
+
+// EPSGToWKT returns the ESRI Well-Known Text of the coordinate reference
+// system with the given EPSG code, as written to .prj files. Only common
+// codes are known: WGS 84 (4326), NAD83 (4269), ETRS89 (4258), Web Mercator
+// (3857) and the UTM zones of WGS 84 (326xx, 327xx) and ETRS89 (25828 to
+// 25838).
+func EPSGToWKT(code int) (string, error) {
+	if wkt, ok := epsgWKT[code]; ok {
+		return wkt, nil
+	}
+	if wkt, ok := epsgUTM(code); ok {
+		return wkt, nil
+	}
+	return "", fmt.Errorf("Unknown EPSG code: %d", code)
+}
+
+// epsgOf returns the EPSG code of the ESRI Well-Known Text of a coordinate
+// reference system if it is one of the codes known to EPSGToWKT.
+func epsgOf(wkt string) (int, bool) {
+	wkt = strings.TrimSpace(strings.TrimPrefix(wkt, "\ufeff"))
+	for code, known := range epsgWKT {
+		if wkt == known {
+			return code, true
+		}
+	}
+	for _, zones := range [][2]int{{32601, 32660}, {32701, 32760}, {25828, 25838}} {
+		for code := zones[0]; code <= zones[1]; code++ {
+			if known, _ := epsgUTM(code); wkt == known {
+				return code, true
+			}
+		}
+	}
+	return 0, false
+}
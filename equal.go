@@ -0,0 +1,83 @@
+package shp
+
+import "math"
+
+// Equal reports whether a and b are shapes of the same type with the same
+// parts and exactly the same coordinates, Z values and measures. Measures
+// that are both NoData are equal. The bounding boxes and ranges stored in the
+// shapes are not compared, since they follow from the coordinates.
+func Equal(a, b Shape) bool {
+	return EqualWithin(a, b, 0)
+}
+
+// EqualWithin reports whether a and b are equal like Equal, except that their
+// coordinates, Z values and measures may differ by up to tolerance.
+func EqualWithin(a, b Shape, tolerance float64) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	t := shapeTypeOf(a, -1)
+	if t == -1 || t != shapeTypeOf(b, -1) {
+		return false
+	}
+	switch t {
+	case NULL:
+		return true
+	case MULTIPATCH:
+		pa, pb := a.(*MultiPatch), b.(*MultiPatch)
+		return int32sEqual(pa.Parts, pb.Parts) && int32sEqual(pa.PartTypes, pb.PartTypes) &&
+			pointsWithin(pa.Points, pb.Points, tolerance) &&
+			valuesWithin(pa.ZArray, pb.ZArray, tolerance) &&
+			valuesWithin(pa.MArray, pb.MArray, tolerance)
+	}
+	ca, cb := coordsOf(a), coordsOf(b)
+	return int32sEqual(ca.parts, cb.parts) &&
+		pointsWithin(ca.points, cb.points, tolerance) &&
+		valuesWithin(ca.z, cb.z, tolerance) &&
+		valuesWithin(ca.m, cb.m, tolerance)
+}
+
+func int32sEqual(a, b []int32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func pointsWithin(a, b []Point, tolerance float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !within(a[i].X, b[i].X, tolerance) || !within(a[i].Y, b[i].Y, tolerance) {
+			return false
+		}
+	}
+	return true
+}
+
+// valuesWithin compares Z values or measures, treating NoData measures as
+// equal to each other.
+func valuesWithin(a, b []float64, tolerance float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if IsNoData(a[i]) && IsNoData(b[i]) {
+			continue
+		}
+		if !within(a[i], b[i], tolerance) {
+			return false
+		}
+	}
+	return true
+}
+
+func within(a, b, tolerance float64) bool {
+	return a == b || math.Abs(a-b) <= tolerance
+}
@@ -0,0 +1,36 @@
+package shp
+
+import "testing"
+
+func TestEqual(t *testing.T) {
+	square := func(d float64) *Polygon {
+		return (*Polygon)(NewPolyLine([][]Point{{{0, 0}, {0, 1 + d}, {1, 1}, {1, 0}, {0, 0}}}))
+	}
+	tests := []struct {
+		a, b      Shape
+		tolerance float64
+		equal     bool
+	}{
+		{&Point{1, 2}, &Point{1, 2}, 0, true},
+		{&Point{1, 2}, &Point{1, 2.001}, 0, false},
+		{&Point{1, 2}, &Point{1, 2.001}, 0.01, true},
+		{&Point{1, 2}, &PointM{1, 2, 0}, 1, false},
+		{&PointM{1, 2, -1e39}, &PointM{1, 2, -2e39}, 0, true},
+		{&PointZ{1, 2, 3, 0}, &PointZ{1, 2, 4, 0}, 0.5, false},
+		{square(0), square(0), 0, true},
+		{square(0), square(1e-9), 0, false},
+		{square(0), square(1e-9), 1e-6, true},
+		{square(0), (*Polygon)(NewPolyLine([][]Point{{{0, 0}, {0, 1}}, {{1, 1}, {1, 0}, {0, 0}}})), 0, false},
+		{&Null{}, &Null{}, 0, true},
+		{&Null{}, nil, 0, false},
+		{nil, nil, 0, true},
+	}
+	for i, test := range tests {
+		if got := EqualWithin(test.a, test.b, test.tolerance); got != test.equal {
+			t.Errorf("%d: EqualWithin(%v, %v, %g) = %v, want %v", i, test.a, test.b, test.tolerance, got, test.equal)
+		}
+	}
+	if !Equal(square(0), square(0)) || Equal(square(0), square(0.5)) {
+		t.Error("Equal of squares failed")
+	}
+}
@@ -0,0 +1,69 @@
+package shp
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// These errors are returned, possibly wrapped, for the failures that callers
+// may want to handle. They can be tested for with errors.Is.
+var (
+	// ErrNoSHPInArchive is returned when a ZIP archive does not contain a
+	// shapefile.
+	ErrNoSHPInArchive = errors.New("archive does not contain a .shp file")
+	// ErrNoSHPInDirectory is returned by OpenDataset when a directory does
+	// not contain a shapefile.
+	ErrNoSHPInDirectory = errors.New("directory does not contain a .shp file")
+	// ErrMultipleSHP is returned when an archive or a directory that is
+	// expected to hold a single shapefile contains several.
+	ErrMultipleSHP = errors.New("multiple .shp files")
+	// ErrFileNotInArchive is returned when a file that is asked for by name is
+	// not in a ZIP archive.
+	ErrFileNotInArchive = errors.New("archive does not contain the file")
+	// ErrUnsupportedShapeType is returned for records of a shape type that is
+	// neither defined by the specification nor registered.
+	ErrUnsupportedShapeType = errors.New("Unsupported shape type")
+	// ErrShapeTypeMismatch is returned when a shape is written to or added to
+	// a shapefile or dataset of another type.
+	ErrShapeTypeMismatch = errors.New("mismatched shape type")
+	// ErrNoSuchField is returned when an attribute is asked for by the name
+	// of a field that the DBF does not have.
+	ErrNoSuchField = errors.New("no such field")
+	// ErrInvalidShape is returned by a strict Writer for shapes that violate
+	// the specification, see Writer.SetStrict.
+	ErrInvalidShape = errors.New("invalid shape")
+)
+
+// ErrFileTooLarge is the error of a Writer that cannot write a record because
+// the .shp or .dbf would grow beyond the size that the 32-bit offsets and
+// lengths of the format can address. Writers created WithAutoSplit continue
+// in a new part instead.
+var ErrFileTooLarge = errors.New("Shapefile exceeds the maximum file size of the format")
+
+// maxFileSize is the size in bytes of the largest .shp that the format can
+// address, as offsets and lengths are 32-bit counts of 16-bit words. It is
+// also applied to the .dbf, which many readers address with 32-bit offsets.
+// It is changed by tests.
+var maxFileSize int64 = 2 * math.MaxInt32
+
+// RecordError describes a problem with a single record. It is reported for
+// records that did not stop the reader from continuing with the next record,
+// and wraps the error of a reader that stopped at a damaged record, which
+// can be found with errors.As.
+type RecordError struct {
+	// RecordNum is the number of the record, starting at 1.
+	RecordNum int
+	// Offset is the byte offset of the record in the file it was read from.
+	Offset int64
+	Err    error
+}
+
+func (e *RecordError) Error() string {
+	return fmt.Sprintf("record %d at offset %d: %v", e.RecordNum, e.Offset, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *RecordError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,190 @@
+package shp
+
+import (
+	"archive/zip"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// createMangledDBF writes four points with the attribute NUM = 1..4 and
+// replaces the deletion indicator of the second row with an end-of-file
+// marker, as left behind by some broken exports.
+func createMangledDBF(t *testing.T, dir string) string {
+	filename := filepath.Join(dir, "mangled")
+	w, err := Create(filename, POINT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SetFields([]Field{NumberField("NUM", 4)})
+	for i := 1; i <= 4; i++ {
+		n := w.Write(&Point{float64(i), float64(i)})
+		w.WriteAttribute(int(n), 0, i)
+	}
+	w.Close()
+
+	b, err := ioutil.ReadFile(filename + ".dbf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b[int(w.dbfHeaderLength)+int(w.dbfRecordLength)] = 0x1a
+	if err := ioutil.WriteFile(filename+".dbf", b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func TestMangledDBFRow(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := createMangledDBF(t, dir)
+
+	r, err := Open(filename + ".shp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	sr := SequentialReaderFromExt(openFile(filename+".shp", t), openFile(filename+".dbf", t)).(*seqReader)
+	defer sr.Close()
+
+	readers := map[string]interface {
+		ReadFeature() (Feature, error)
+		RecordErrors() []*RecordError
+	}{"reader": r, "seqReader": sr}
+	for name, r := range readers {
+		for i := 1; i <= 4; i++ {
+			f, err := r.ReadFeature()
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			a := f.Attrs[0]
+			if i == 2 {
+				if !a.Null || a.Value != "" {
+					t.Errorf("%s: got %+v for mangled row, want NULL", name, a)
+				}
+				continue
+			}
+			if a.Null || a.Value != strconv.Itoa(i) {
+				t.Errorf("%s: got %+v for row %d, want %d", name, a, i, i)
+			}
+		}
+		errs := r.RecordErrors()
+		if len(errs) != 1 || errs[0].RecordNum != 2 || errs[0].Offset != 70 {
+			t.Fatalf("%s: got record errors %v, want one for record 2", name, errs)
+		}
+	}
+}
+
+func TestRecordErrorOfReaders(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "point")
+	for _, ext := range []string{".shp", ".shx", ".dbf"} {
+		b, err := ioutil.ReadFile("test_files/point" + ext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ext == ".shp" {
+			// the shape type of the second record, which follows the
+			// first record of 28 bytes
+			b[128+8] = 99
+		}
+		if err := ioutil.WriteFile(filename+ext, b, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r, err := Open(filename + ".shp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	pr, err := OpenParallel(filename+".shp", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pr.Close()
+	sr := SequentialReaderFromExt(openFile(filename+".shp", t), openFile(filename+".dbf", t))
+	defer sr.Close()
+
+	for name, r := range map[string]SequentialReader{"reader": r, "parallel": pr, "seqReader": sr} {
+		for r.Next() {
+		}
+		err := r.Err()
+		if !errors.Is(err, ErrUnsupportedShapeType) {
+			t.Errorf("%s: got %v, want ErrUnsupportedShapeType", name, err)
+		}
+		var re *RecordError
+		if !errors.As(err, &re) || re.RecordNum != 2 || re.Offset != 128 {
+			t.Errorf("%s: got %#v, want record 2 at offset 128", name, re)
+		}
+	}
+}
+
+func TestSentinelErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	w, err := Create(filepath.Join(dir, "points.shp"), POINT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := w.Write(&PolyLine{}); n != -1 || !errors.Is(w.Err(), ErrShapeTypeMismatch) {
+		t.Errorf("got %d and %v, want ErrShapeTypeMismatch", n, w.Err())
+	}
+	if n := w.Write(&Null{}); n != 0 {
+		t.Errorf("got %d for Null shape: %v", n, w.Err())
+	}
+	w.Close()
+
+	if err := NewDataset(POINT, nil).Add(&Polygon{}); !errors.Is(err, ErrShapeTypeMismatch) {
+		t.Errorf("got %v, want ErrShapeTypeMismatch", err)
+	}
+
+	empty := filepath.Join(dir, "empty")
+	os.Mkdir(empty, 0755)
+	if _, err := OpenDataset(empty); !errors.Is(err, ErrNoSHPInDirectory) {
+		t.Errorf("got %v, want ErrNoSHPInDirectory", err)
+	}
+	ioutil.WriteFile(filepath.Join(empty, "a.shp"), nil, 0644)
+	ioutil.WriteFile(filepath.Join(empty, "b.shp"), nil, 0644)
+	if _, err := OpenDataset(empty); !errors.Is(err, ErrMultipleSHP) {
+		t.Errorf("got %v, want ErrMultipleSHP", err)
+	}
+
+	zipDir, archive := createTempZIP("test_files/point", t)
+	defer os.RemoveAll(zipDir)
+	if _, err := OpenShapeFromZip(filepath.Join(zipDir, archive), "missing.shp"); !errors.Is(err, ErrFileNotInArchive) {
+		t.Errorf("got %v, want ErrFileNotInArchive", err)
+	}
+
+	// an archive without a shapefile is closed again
+	noSHP := filepath.Join(dir, "noshp.zip")
+	f, err := os.Create(noSHP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	zw.Create("readme.txt")
+	zw.Close()
+	f.Close()
+	fds := openFiles()
+	if _, err := OpenZip(noSHP); !errors.Is(err, ErrNoSHPInArchive) {
+		t.Errorf("got %v, want ErrNoSHPInArchive", err)
+	}
+	if n := openFiles(); n != fds {
+		t.Errorf("%d files were left open by OpenZip", n-fds)
+	}
+}
@@ -0,0 +1,146 @@
+package shp
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Feature bundles a shape with its attributes and its index in the
+// shapefile. Encoding a Feature as JSON produces a GeoJSON Feature object.
+type Feature struct {
+	Index int
+	Shape Shape
+	Attrs []Attr
+}
+
+// Attr is the value of a single attribute of a Feature.
+type Attr struct {
+	Field Field
+	Value string
+	Null  bool
+}
+
+// Attr returns the attribute for the field called name and false if there is
+// no such field.
+func (f Feature) Attr(name string) (Attr, bool) {
+	for _, a := range f.Attrs {
+		if a.Field.String() == name {
+			return a, true
+		}
+	}
+	return Attr{}, false
+}
+
+// isNullValue reports whether value is the encoding of NULL for field. Blank
+// values and values filled with '*' are NULL for every type except character
+// fields, as is '?' for logical fields.
+func isNullValue(field Field, value string) bool {
+	if field.Fieldtype == 'C' {
+		return false
+	}
+	v := strings.Trim(value, " \x00")
+	return v == "" || strings.Trim(v, "*") == "" || (field.Fieldtype == 'L' && v == "?")
+}
+
+// jsonValue returns the value of a as used in GeoJSON properties.
+func (a Attr) jsonValue() interface{} {
+	if a.Null {
+		return nil
+	}
+	switch a.Field.Fieldtype {
+	case 'N', 'F':
+		if v, err := strconv.ParseFloat(strings.TrimSpace(a.Value), 64); err == nil {
+			return v
+		}
+	case 'L':
+		switch strings.ToUpper(strings.TrimSpace(a.Value)) {
+		case "T", "Y":
+			return true
+		case "F", "N":
+			return false
+		}
+	}
+	return a.Value
+}
+
+// readFeature advances sr and returns the feature it was advanced to. It
+// returns io.EOF if there are no more features.
+func readFeature(sr SequentialReader) (Feature, error) {
+	if !sr.Next() {
+		if err := sr.Err(); err != nil {
+			return Feature{}, err
+		}
+		return Feature{}, io.EOF
+	}
+	n, s := sr.Shape()
+	fields := sr.Fields()
+	f := Feature{Index: n, Shape: s, Attrs: make([]Attr, len(fields))}
+	bad := false
+	if b, ok := sr.(interface{ rowBad() bool }); ok {
+		bad = b.rowBad()
+	}
+	for i, field := range fields {
+		v := sr.Attribute(i)
+		f.Attrs[i] = Attr{Field: field, Value: v, Null: bad || isNullValue(field, v)}
+	}
+	return f, nil
+}
+
+// ReadFeature reads the next shape and its attributes. It returns io.EOF if
+// there are no more shapes.
+func (r *Reader) ReadFeature() (Feature, error) {
+	return readFeature(r)
+}
+
+// ReadFeature reads the next shape and its attributes. It returns io.EOF if
+// there are no more shapes.
+func (sr *seqReader) ReadFeature() (Feature, error) {
+	return readFeature(sr)
+}
+
+// ReadFeature reads the next shape and its attributes. It returns io.EOF if
+// there are no more shapes.
+func (zr *ZipReader) ReadFeature() (Feature, error) {
+	return readFeature(zr)
+}
+
+// WriteFeature writes the shape and the attributes of f. Attributes are
+// matched to the fields of the DBF by name. If the fields have not been set
+// yet, they are set to the fields of the attributes of f. NULL attributes are
+// left blank.
+func (w *Writer) WriteFeature(f Feature) error {
+	if w.dbf == nil && len(f.Attrs) > 0 {
+		fields := make([]Field, len(f.Attrs))
+		for i, a := range f.Attrs {
+			fields[i] = a.Field
+		}
+		if err := w.SetFields(fields); err != nil {
+			return err
+		}
+	}
+	row := w.Write(f.Shape)
+	if row < 0 {
+		return w.err
+	}
+	for _, a := range f.Attrs {
+		if a.Null {
+			continue
+		}
+		field := -1
+		for i := range w.dbfFields {
+			if w.dbfFields[i].String() == a.Field.String() {
+				field = i
+				break
+			}
+		}
+		if field < 0 {
+			return fmt.Errorf("Unable to write attribute: no field %q", a.Field)
+		}
+		if err := w.WriteAttribute(int(row), field, a.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
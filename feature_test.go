@@ -0,0 +1,128 @@
+package shp
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadWriteFeature(t *testing.T) {
+	r, err := Open("test_files/point.shp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	var features []Feature
+	for {
+		f, err := r.ReadFeature()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		features = append(features, f)
+	}
+	if len(features) != 3 {
+		t.Fatalf("got %d features, want 3", len(features))
+	}
+	for i, f := range features {
+		if f.Index != i {
+			t.Errorf("got index %d, want %d", f.Index, i)
+		}
+		if len(f.Attrs) != 1 || f.Attrs[0].Field.String() != "point_ID" {
+			t.Errorf("got attributes %+v", f.Attrs)
+		}
+	}
+
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "features")
+	w, err := Create(filename, POINT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range features {
+		if err := w.WriteFeature(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w.Close()
+
+	zr := SequentialReaderFromExt(openFile(filename+".shp", t), openFile(filename+".dbf", t))
+	defer zr.Close()
+	for i := 0; ; i++ {
+		f, err := zr.(*seqReader).ReadFeature()
+		if err == io.EOF {
+			if i != len(features) {
+				t.Errorf("read %d features back, want %d", i, len(features))
+			}
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(f, features[i]) {
+			t.Errorf("got %+v, want %+v", f, features[i])
+		}
+	}
+}
+
+func TestFeatureMarshalJSON(t *testing.T) {
+	polygon, err := NewPolygon([][]Point{
+		{{0, 0}, {0, 10}, {10, 10}, {10, 0}},
+		{{2, 2}, {4, 2}, {4, 4}, {2, 4}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := Feature{
+		Index: 7,
+		Shape: polygon,
+		Attrs: []Attr{
+			{Field: StringField("NAME", 10), Value: "square"},
+			{Field: FloatField("AREA", 10, 2), Value: "96.00"},
+			{Field: NumberField("POP", 5), Null: true},
+		},
+	}
+	b, err := json.Marshal(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"type": "Feature",
+		"id":   7.0,
+		"geometry": map[string]interface{}{
+			"type": "Polygon",
+			"coordinates": []interface{}{
+				[]interface{}{
+					[]interface{}{0.0, 0.0}, []interface{}{10.0, 0.0}, []interface{}{10.0, 10.0},
+					[]interface{}{0.0, 10.0}, []interface{}{0.0, 0.0},
+				},
+				[]interface{}{
+					[]interface{}{2.0, 2.0}, []interface{}{2.0, 4.0}, []interface{}{4.0, 4.0},
+					[]interface{}{4.0, 2.0}, []interface{}{2.0, 2.0},
+				},
+			},
+		},
+		"properties": map[string]interface{}{
+			"NAME": "square",
+			"AREA": 96.0,
+			"POP":  nil,
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %s", b)
+	}
+}
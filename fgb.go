@@ -0,0 +1,787 @@
+package shp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// fgbMagic starts FlatGeobuf files of version 3.
+var fgbMagic = []byte{'f', 'g', 'b', 3, 'f', 'g', 'b', 1}
+
+// These are the geometry types of FlatGeobuf, which equal those of WKB.
+const (
+	fgbUnknown         = 0
+	fgbPoint           = 1
+	fgbLineString      = 2
+	fgbPolygon         = 3
+	fgbMultiPoint      = 4
+	fgbMultiLineString = 5
+	fgbMultiPolygon    = 6
+)
+
+// These are the column types of FlatGeobuf.
+const (
+	fgbByte = iota
+	fgbUByte
+	fgbBool
+	fgbShort
+	fgbUShort
+	fgbInt
+	fgbUInt
+	fgbLong
+	fgbULong
+	fgbFloat
+	fgbDouble
+	fgbString
+	fgbJSON
+	fgbDateTime
+	fgbBinary
+)
+
+// fgbDefaultNodeSize is the number of children of the nodes of the spatial
+// index unless the header says otherwise.
+const fgbDefaultNodeSize = 16
+
+// fgbGeometryTypes maps shape types to the geometry types of FlatGeobuf.
+// PolyLines and Polygons are always multi-geometries, as shapefile records
+// may have any number of parts.
+var fgbGeometryTypes = map[ShapeType]uint8{
+	POINT:       fgbPoint,
+	POINTZ:      fgbPoint,
+	POINTM:      fgbPoint,
+	MULTIPOINT:  fgbMultiPoint,
+	MULTIPOINTZ: fgbMultiPoint,
+	MULTIPOINTM: fgbMultiPoint,
+	POLYLINE:    fgbMultiLineString,
+	POLYLINEZ:   fgbMultiLineString,
+	POLYLINEM:   fgbMultiLineString,
+	POLYGON:     fgbMultiPolygon,
+	POLYGONZ:    fgbMultiPolygon,
+	POLYGONM:    fgbMultiPolygon,
+}
+
+// FlatGeobufOptions configures WriteFlatGeobuf.
+type FlatGeobufOptions struct {
+	// Name is the name of the layer.
+	Name string
+	// NoIndex leaves out the packed Hilbert R-tree, which keeps the features
+	// in the order they are read.
+	NoIndex bool
+	// NodeSize is the number of children of the nodes of the index. It is
+	// 16 if it is zero.
+	NodeSize uint16
+}
+
+// WriteFlatGeobuf reads all records of r and writes them to w as FlatGeobuf.
+// Unless opts.NoIndex is set, the features are sorted along a Hilbert curve
+// and preceded by a packed Hilbert R-tree of their bounding boxes, so the
+// records are held in memory until all of them are read. The fields become
+// columns, the .prj of readers with a Projection method the CRS and Null
+// shapes features without geometry. NoData measures are written as NaN.
+// MultiPatches are not supported.
+func WriteFlatGeobuf(w io.Writer, r SequentialReader, opts FlatGeobufOptions) error {
+	nodeSize := int(opts.NodeSize)
+	if nodeSize == 0 {
+		nodeSize = fgbDefaultNodeSize
+	}
+	if nodeSize < 2 {
+		return fmt.Errorf("Invalid node size for FlatGeobuf: %d", nodeSize)
+	}
+	fields := r.Fields()
+	type record struct {
+		shape Shape
+		box   Box
+		props []byte
+	}
+	var records []record
+	extent, extended := emptyBox, false
+	t, mixed := NULL, false
+	hasZ, hasM := false, false
+	for r.Next() {
+		_, s := r.Shape()
+		st := shapeTypeOf(s, NULL)
+		box := emptyBox
+		if st != NULL {
+			if _, ok := fgbGeometryTypes[st]; !ok {
+				return fmt.Errorf("Unsupported shape for FlatGeobuf: %T", s)
+			}
+			if t != NULL && st != t {
+				mixed = true
+			}
+			t = st
+			box = s.BBox()
+			if extended {
+				extent.Extend(box)
+			} else {
+				extent, extended = box, true
+			}
+			switch st {
+			case POINTZ, MULTIPOINTZ, POLYLINEZ, POLYGONZ:
+				hasZ = true
+				hasM = hasM || hasShapeMeasures(s)
+			case POINTM, MULTIPOINTM, POLYLINEM, POLYGONM:
+				hasM = true
+			}
+		}
+		records = append(records, record{s, box, fgbProperties(fields, r)})
+	}
+	if err := r.Err(); err != nil {
+		return err
+	}
+	geometryType := uint8(fgbUnknown)
+	if !mixed && t != NULL {
+		geometryType = fgbGeometryTypes[t]
+	}
+	index := !opts.NoIndex && len(records) > 0
+	if index {
+		sort.SliceStable(records, func(i, j int) bool {
+			return hilbertOf(records[i].box, extent) > hilbertOf(records[j].box, extent)
+		})
+	}
+
+	var features bytes.Buffer
+	leaves := make([]fgbNode, len(records))
+	b := &fbBuilder{}
+	for i, rec := range records {
+		leaves[i] = fgbNode{rec.box, uint64(features.Len())}
+		feature := []fbField{{}, fbBytes(rec.props)}
+		if g := fgbGeometry(rec.shape, hasZ, hasM); g != nil {
+			feature[0] = fbTable(g)
+		}
+		buf := b.finish(feature)
+		var size [4]byte
+		binary.LittleEndian.PutUint32(size[:], uint32(len(buf)))
+		features.Write(size[:])
+		features.Write(buf)
+		records[i] = record{}
+	}
+
+	columns := make([][]fbField, len(fields))
+	for i, f := range fields {
+		columns[i] = []fbField{fbString(f.String()), fbUint8(fgbColumnType(f)), {}, {}, fbInt32(int32(f.Size))}
+		if typ := fgbColumnType(f); typ == fgbDouble || typ == fgbInt || typ == fgbLong {
+			columns[i] = append(columns[i], fbInt32(int32(f.Size)), fbInt32(int32(f.Precision)))
+		}
+	}
+	header := []fbField{fbString(opts.Name), {}, fbUint8(geometryType), fbBool(hasZ), fbBool(hasM), {}, {},
+		fbTables(columns), fbUint64(uint64(len(leaves))), fbUint16(0)}
+	if extended {
+		header[1] = fbFloat64s([]float64{extent.MinX, extent.MinY, extent.MaxX, extent.MaxY})
+	}
+	if index {
+		header[9] = fbUint16(uint16(nodeSize))
+	}
+	if srs := gpkgSRS(r); srs.definition != "" {
+		crs := []fbField{{}, {}, fbString(srs.name), {}, fbString(srs.definition)}
+		if srs.organization == "EPSG" {
+			crs[0], crs[1] = fbString("EPSG"), fbInt32(int32(srs.id))
+		}
+		header = append(header, fbTable(crs))
+	}
+
+	bw := bufio.NewWriter(w)
+	bw.Write(fgbMagic)
+	buf := b.finish(header)
+	var size [4]byte
+	binary.LittleEndian.PutUint32(size[:], uint32(len(buf)))
+	bw.Write(size[:])
+	bw.Write(buf)
+	if index {
+		bw.Write(encodeFGBTree(fgbBuildTree(leaves, nodeSize)))
+	}
+	features.WriteTo(bw)
+	return bw.Flush()
+}
+
+// fgbColumnType returns the column type of FlatGeobuf for a field.
+func fgbColumnType(f Field) uint8 {
+	switch f.Fieldtype {
+	case 'N':
+		switch {
+		case f.Precision > 0:
+			return fgbDouble
+		case f.Size < 10:
+			return fgbInt
+		}
+		return fgbLong
+	case 'F', 'O':
+		return fgbDouble
+	case 'I':
+		return fgbInt
+	case 'L':
+		return fgbBool
+	case 'D':
+		return fgbDateTime
+	}
+	return fgbString
+}
+
+// fgbProperties returns the encoded attributes of the record that r was last
+// advanced to. NULL attributes and numbers that cannot be parsed are left
+// out.
+func fgbProperties(fields []Field, r SequentialReader) []byte {
+	var b []byte
+	for i, f := range fields {
+		if AttributeIsNull(r, i) {
+			continue
+		}
+		v := r.Attribute(i)
+		s := strings.TrimSpace(v)
+		var value []byte
+		switch fgbColumnType(f) {
+		case fgbInt:
+			if n, err := strconv.ParseInt(s, 10, 32); err == nil {
+				value = appendUint32(nil, uint32(n))
+			}
+		case fgbLong:
+			if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+				value = appendUint64(nil, uint64(n))
+			}
+		case fgbDouble:
+			if d, err := strconv.ParseFloat(s, 64); err == nil {
+				value = appendUint64(nil, math.Float64bits(d))
+			}
+		case fgbBool:
+			value = []byte{0}
+			if strings.ContainsAny(s, "TtYy") {
+				value[0] = 1
+			}
+		case fgbDateTime:
+			if len(s) == 8 {
+				s = s[:4] + "-" + s[4:6] + "-" + s[6:]
+			}
+			value = append(appendUint32(nil, uint32(len(s))), s...)
+		default:
+			value = append(appendUint32(nil, uint32(len(v))), v...)
+		}
+		if value != nil {
+			b = append(appendUint16(b, uint16(i)), value...)
+		}
+	}
+	return b
+}
+
+// fgbGeometry returns the fields of the Geometry table of s, or nil for Null
+// shapes. Z values and measures are written if the layer has them.
+func fgbGeometry(s Shape, hasZ, hasM bool) []fbField {
+	st := shapeTypeOf(s, NULL)
+	if st == NULL {
+		return nil
+	}
+	c := coordsOf(s)
+	coords := func(start, end int) []fbField {
+		xy := make([]float64, 0, 2*(end-start))
+		for _, p := range c.points[start:end] {
+			xy = append(xy, p.X, p.Y)
+		}
+		g := make([]fbField, 8)
+		g[1] = fbFloat64s(xy)
+		if hasZ && c.z != nil {
+			g[2] = fbFloat64s(c.z[start:end])
+		}
+		if hasM && c.m != nil {
+			m := append([]float64(nil), c.m[start:end]...)
+			for i := range m {
+				if IsNoData(m[i]) {
+					m[i] = math.NaN()
+				}
+			}
+			g[3] = fbFloat64s(m)
+		}
+		return g
+	}
+	// ends are the end indices of the parts relative to start, which are
+	// only written for several parts
+	ends := func(ranges [][2]int, start int) fbField {
+		if len(ranges) < 2 {
+			return fbField{}
+		}
+		e := make([]uint32, len(ranges))
+		for i, pr := range ranges {
+			e[i] = uint32(pr[1] - start)
+		}
+		return fbUint32s(e)
+	}
+
+	typ := fgbGeometryTypes[st]
+	var g []fbField
+	switch typ {
+	case fgbMultiPolygon:
+		g = make([]fbField, 8)
+		var polygons [][]fbField
+		for _, rings := range polygonGroups(c.parts, c.points) {
+			start, end := rings[0][0], rings[len(rings)-1][1]
+			polygon := coords(start, end)
+			polygon[0] = ends(rings, start)
+			polygon[6] = fbUint8(fgbPolygon)
+			polygons = append(polygons, polygon)
+		}
+		g[7] = fbTables(polygons)
+	case fgbMultiLineString:
+		g = coords(0, len(c.points))
+		g[0] = ends(partRanges(c.parts, len(c.points)), 0)
+	default:
+		g = coords(0, len(c.points))
+	}
+	g[6] = fbUint8(typ)
+	return g
+}
+
+// FlatGeobufReader reads the features of a FlatGeobuf file one after another
+// with the same Shape and Field model as shapefiles. It implements
+// SequentialReader, so it can take the place of a shapefile reader, e.g. to
+// convert FlatGeobuf into shapefiles with Pipe.
+//
+// Geometries become the shapes that ShapeFromWKB returns for them, columns
+// become fields and values become attributes as they are stored in DBF
+// files: numbers in decimal, logical values as "T" and "F" and dates as
+// YYYYMMDD. Missing values are empty.
+type FlatGeobufReader struct {
+	// GeometryType is the shape type of the layer, or NULL if its features
+	// are of several types.
+	GeometryType ShapeType
+
+	r      *bufio.Reader
+	closer io.Closer
+
+	name         string
+	prj          string
+	geometryType uint8
+	hasZ, hasM   bool
+	fields       []Field
+	columnTypes  []uint8
+	count        int
+	nodeSize     int
+	index        []byte
+
+	// offset is the offset of the next feature from the first, and next its
+	// number.
+	offset uint64
+	next   int
+	// filter restricts the features to those intersecting it. wanted are
+	// the offsets of those features from the index.
+	filter *Box
+	wanted []uint64
+
+	num   int
+	shape Shape
+	attrs []string
+	err   error
+}
+
+// OpenFlatGeobuf opens a FlatGeobuf file for reading.
+func OpenFlatGeobuf(filename string) (*FlatGeobufReader, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	r, err := NewFlatGeobufReader(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	r.closer = f
+	return r, nil
+}
+
+// NewFlatGeobufReader returns a FlatGeobufReader that reads from r, which is
+// read as far as the header and the index. Close does not close r.
+func NewFlatGeobufReader(r io.Reader) (*FlatGeobufReader, error) {
+	fr := &FlatGeobufReader{r: bufio.NewReader(r), num: -1}
+	magic := make([]byte, len(fgbMagic))
+	if _, err := io.ReadFull(fr.r, magic); err != nil {
+		return nil, fmt.Errorf("Unable to read FlatGeobuf: %v", err)
+	}
+	if !bytes.Equal(magic[:3], fgbMagic[:3]) || !bytes.Equal(magic[4:7], fgbMagic[4:7]) || magic[3] != fgbMagic[3] {
+		return nil, errors.New("Invalid FlatGeobuf: unexpected magic bytes")
+	}
+	buf, err := fr.readSized()
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read FlatGeobuf header: %v", err)
+	}
+	d := &fbDecoder{buf: buf}
+	h := d.root()
+	fr.name = h.string(0)
+	fr.geometryType = h.uint8(2, fgbUnknown)
+	fr.hasZ, fr.hasM = h.uint8(3, 0) != 0, h.uint8(4, 0) != 0
+	for _, col := range h.tables(7) {
+		fr.fields = append(fr.fields, fgbField(col))
+		fr.columnTypes = append(fr.columnTypes, col.uint8(1, fgbByte))
+	}
+	count := h.uint64(8)
+	fr.nodeSize = int(h.uint16(9, fgbDefaultNodeSize))
+	if crs, ok := h.table(10); ok {
+		fr.prj = crs.string(4)
+		if code := crs.int32(1, 0); fr.prj == "" && code != 0 && strings.EqualFold(crs.string(0), "EPSG") {
+			fr.prj, _ = EPSGToWKT(int(code))
+		}
+	}
+	if d.err != nil {
+		return nil, fmt.Errorf("Unable to read FlatGeobuf header: %v", d.err)
+	}
+	if count > math.MaxInt32 {
+		return nil, fmt.Errorf("Invalid FlatGeobuf: %d features", count)
+	}
+	fr.count = int(count)
+	fr.GeometryType = fgbShapeType(fr.geometryType, fr.hasZ, fr.hasM)
+
+	if fr.count > 0 && fr.nodeSize > 0 {
+		if fr.nodeSize < 2 {
+			return nil, fmt.Errorf("Invalid FlatGeobuf: node size %d", fr.nodeSize)
+		}
+		bounds := fgbLevelBounds(fr.count, fr.nodeSize)
+		size := int64(bounds[0][1]) * fgbNodeSize
+		fr.index, err = ioutil.ReadAll(io.LimitReader(fr.r, size))
+		if err == nil && int64(len(fr.index)) != size {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read FlatGeobuf index: %v", err)
+		}
+	}
+	return fr, nil
+}
+
+// readSized reads a size-prefixed FlatBuffer.
+func (fr *FlatGeobufReader) readSized() ([]byte, error) {
+	var size [4]byte
+	if _, err := io.ReadFull(fr.r, size[:]); err != nil {
+		return nil, err
+	}
+	n := int64(binary.LittleEndian.Uint32(size[:]))
+	buf, err := ioutil.ReadAll(io.LimitReader(fr.r, n))
+	if err == nil && int64(len(buf)) != n {
+		err = io.ErrUnexpectedEOF
+	}
+	return buf, err
+}
+
+// fgbShapeType returns the shape type of geometries of a FlatGeobuf type.
+func fgbShapeType(t uint8, hasZ, hasM bool) ShapeType {
+	types := map[uint8][3]ShapeType{
+		fgbPoint:           {POINT, POINTZ, POINTM},
+		fgbMultiPoint:      {MULTIPOINT, MULTIPOINTZ, MULTIPOINTM},
+		fgbLineString:      {POLYLINE, POLYLINEZ, POLYLINEM},
+		fgbMultiLineString: {POLYLINE, POLYLINEZ, POLYLINEM},
+		fgbPolygon:         {POLYGON, POLYGONZ, POLYGONM},
+		fgbMultiPolygon:    {POLYGON, POLYGONZ, POLYGONM},
+	}
+	st, ok := types[t]
+	switch {
+	case !ok:
+		return NULL
+	case hasZ:
+		return st[1]
+	case hasM:
+		return st[2]
+	}
+	return st[0]
+}
+
+// fgbField returns the field of a column.
+func fgbField(col fbTableReader) Field {
+	width, scale := int(col.int32(4, -1)), int(col.int32(6, -1))
+	size := func(def int) uint8 {
+		if width <= 0 || width > 254 {
+			return uint8(def)
+		}
+		return uint8(width)
+	}
+	f := Field{Fieldtype: 'N'}
+	copy(f.Name[:10], col.string(0))
+	switch col.uint8(1, fgbByte) {
+	case fgbByte, fgbUByte, fgbShort, fgbUShort, fgbInt, fgbUInt:
+		f.Size = size(11)
+	case fgbLong, fgbULong:
+		f.Size = size(20)
+	case fgbFloat, fgbDouble:
+		f.Size, f.Precision = size(24), 15
+		if scale >= 0 && scale < int(f.Size) {
+			f.Precision = uint8(scale)
+		}
+	case fgbBool:
+		f.Fieldtype, f.Size = 'L', 1
+	case fgbDateTime:
+		f.Fieldtype, f.Size = 'D', 8
+	default:
+		f.Fieldtype, f.Size = 'C', size(254)
+	}
+	return f
+}
+
+// Next reads the next feature that passes the filter. It returns false at
+// the end of the file or on errors.
+func (fr *FlatGeobufReader) Next() bool {
+	for fr.err == nil {
+		var size [4]byte
+		if _, err := io.ReadFull(fr.r, size[:]); err != nil {
+			if err != io.EOF {
+				fr.err = fmt.Errorf("Unable to read FlatGeobuf feature: %v", err)
+			}
+			return false
+		}
+		n := int64(binary.LittleEndian.Uint32(size[:]))
+		offset, num := fr.offset, fr.next
+		fr.offset += 4 + uint64(n)
+		fr.next++
+		if fr.wanted != nil {
+			for len(fr.wanted) > 0 && fr.wanted[0] < offset {
+				fr.wanted = fr.wanted[1:]
+			}
+			if len(fr.wanted) == 0 || fr.wanted[0] != offset {
+				if _, err := io.CopyN(ioutil.Discard, fr.r, n); err != nil {
+					fr.err = fmt.Errorf("Unable to read FlatGeobuf feature: %v", err)
+				}
+				continue
+			}
+		}
+		buf, err := ioutil.ReadAll(io.LimitReader(fr.r, n))
+		if err == nil && int64(len(buf)) != n {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			fr.err = fmt.Errorf("Unable to read FlatGeobuf feature: %v", err)
+			return false
+		}
+		if err := fr.decode(buf); err != nil {
+			fr.err = &RecordError{RecordNum: num + 1, Offset: int64(offset), Err: err}
+			return false
+		}
+		if fr.filter != nil {
+			if _, ok := fr.shape.(*Null); ok || !fr.filter.Intersects(fr.shape.BBox()) {
+				continue
+			}
+		}
+		fr.num = num
+		return true
+	}
+	return false
+}
+
+// decode decodes a feature into the shape and the attributes.
+func (fr *FlatGeobufReader) decode(buf []byte) error {
+	d := &fbDecoder{buf: buf}
+	f := d.root()
+	fr.shape = &Null{}
+	if g, ok := f.table(0); ok {
+		s, err := fr.geometry(g)
+		if err != nil {
+			return err
+		}
+		fr.shape = s
+	}
+	fr.attrs = make([]string, len(fr.fields))
+	props := f.bytes(1)
+	if d.err != nil {
+		return d.err
+	}
+	for len(props) > 0 {
+		if len(props) < 2 {
+			return errors.New("Invalid FlatGeobuf properties: unexpected end")
+		}
+		col := int(binary.LittleEndian.Uint16(props))
+		props = props[2:]
+		if col >= len(fr.fields) {
+			return fmt.Errorf("Invalid FlatGeobuf properties: column %d out of range", col)
+		}
+		v, n, err := fgbValue(fr.columnTypes[col], fr.fields[col], props)
+		if err != nil {
+			return err
+		}
+		fr.attrs[col] = v
+		props = props[n:]
+	}
+	return nil
+}
+
+// fgbValue decodes a value of the given column type at the start of b into
+// an attribute of field f and returns its size.
+func fgbValue(typ uint8, f Field, b []byte) (string, int, error) {
+	sizes := map[uint8]int{fgbByte: 1, fgbUByte: 1, fgbBool: 1, fgbShort: 2, fgbUShort: 2,
+		fgbInt: 4, fgbUInt: 4, fgbFloat: 4, fgbLong: 8, fgbULong: 8, fgbDouble: 8}
+	n, fixed := sizes[typ]
+	if !fixed {
+		if len(b) < 4 {
+			return "", 0, errors.New("Invalid FlatGeobuf properties: unexpected end")
+		}
+		n = int(binary.LittleEndian.Uint32(b))
+		if n > len(b)-4 {
+			return "", 0, errors.New("Invalid FlatGeobuf properties: unexpected end")
+		}
+		v := string(b[4 : 4+n])
+		switch typ {
+		case fgbDateTime:
+			if len(v) >= 10 && v[4] == '-' && v[7] == '-' {
+				v = v[:4] + v[5:7] + v[8:10]
+			} else {
+				v = ""
+			}
+		case fgbBinary:
+			v = fmt.Sprintf("%x", v)
+		}
+		return v, 4 + n, nil
+	}
+	if len(b) < n {
+		return "", 0, errors.New("Invalid FlatGeobuf properties: unexpected end")
+	}
+	var u uint64
+	for i := n - 1; i >= 0; i-- {
+		u = u<<8 | uint64(b[i])
+	}
+	signed := int64(u<<(64-8*uint(n))) >> (64 - 8*uint(n))
+	decimals := -1
+	if f.Precision > 0 {
+		decimals = int(f.Precision)
+	}
+	switch typ {
+	case fgbBool:
+		if u != 0 {
+			return "T", n, nil
+		}
+		return "F", n, nil
+	case fgbUByte, fgbUShort, fgbUInt, fgbULong:
+		return strconv.FormatUint(u, 10), n, nil
+	case fgbFloat:
+		return strconv.FormatFloat(float64(math.Float32frombits(uint32(u))), 'f', decimals, 32), n, nil
+	case fgbDouble:
+		return strconv.FormatFloat(math.Float64frombits(u), 'f', decimals, 64), n, nil
+	}
+	return strconv.FormatInt(signed, 10), n, nil
+}
+
+// geometry decodes a Geometry table into a shape.
+func (fr *FlatGeobufReader) geometry(g fbTableReader) (Shape, error) {
+	typ := g.uint8(6, fr.geometryType)
+	c := &wkbCoords{hasZ: fr.hasZ, hasM: fr.hasM}
+	add := func(g fbTableReader, split bool) error {
+		xy, z, m := g.float64s(1), g.float64s(2), g.float64s(3)
+		n := len(xy) / 2
+		if len(xy)%2 != 0 || len(z) != 0 && len(z) != n || len(m) != 0 && len(m) != n {
+			return errors.New("Invalid FlatGeobuf geometry: coordinates of different lengths")
+		}
+		ends := []uint32{uint32(n)}
+		if split {
+			if e := g.uint32s(0); len(e) > 0 {
+				ends = e
+			}
+		}
+		start := 0
+		for _, end := range ends {
+			if int(end) < start || int(end) > n {
+				return errors.New("Invalid FlatGeobuf geometry: ends out of range")
+			}
+			c.addPart()
+			for i := start; i < int(end); i++ {
+				j := len(c.parts) - 1
+				c.parts[j] = append(c.parts[j], Point{xy[2*i], xy[2*i+1]})
+				zv, mv := 0.0, NoData
+				if len(z) > 0 {
+					zv = z[i]
+				}
+				if len(m) > 0 && !math.IsNaN(m[i]) {
+					mv = m[i]
+				}
+				c.z[j] = append(c.z[j], zv)
+				c.m[j] = append(c.m[j], mv)
+			}
+			start = int(end)
+		}
+		return nil
+	}
+
+	var err error
+	switch typ {
+	case fgbPoint, fgbMultiPoint:
+		err = add(g, false)
+	case fgbLineString, fgbMultiLineString, fgbPolygon:
+		err = add(g, true)
+	case fgbMultiPolygon:
+		for _, part := range g.tables(7) {
+			if err = add(part, true); err != nil {
+				break
+			}
+		}
+	default:
+		err = fmt.Errorf("Unsupported FlatGeobuf geometry type %d", typ)
+	}
+	if err == nil && g.d.err != nil {
+		err = g.d.err
+	}
+	if err != nil {
+		return nil, err
+	}
+	return c.shape(uint32(typ))
+}
+
+// Shape returns the index of the feature that Next advanced to, counted from
+// the first feature of the file, and its shape.
+func (fr *FlatGeobufReader) Shape() (int, Shape) {
+	if fr.err != nil {
+		return fr.num, nil
+	}
+	return fr.num, fr.shape
+}
+
+// Attribute returns the n-th attribute of the feature that Next advanced to.
+func (fr *FlatGeobufReader) Attribute(n int) string {
+	if fr.err != nil || n < 0 || n >= len(fr.attrs) {
+		return ""
+	}
+	return fr.attrs[n]
+}
+
+// Fields returns the fields of the columns of the layer.
+func (fr *FlatGeobufReader) Fields() []Field {
+	if fr.err != nil {
+		return nil
+	}
+	return fr.fields
+}
+
+// Err returns the last non-EOF error encountered.
+func (fr *FlatGeobufReader) Err() error {
+	return fr.err
+}
+
+// Close closes the file if the reader was created by OpenFlatGeobuf.
+func (fr *FlatGeobufReader) Close() error {
+	if fr.closer != nil {
+		return fr.closer.Close()
+	}
+	return nil
+}
+
+// Name returns the name of the layer.
+func (fr *FlatGeobufReader) Name() string {
+	return fr.name
+}
+
+// Projection returns the Well-Known Text of the CRS of the layer, or of its
+// EPSG code if that is known to EPSGToWKT, or the empty string.
+func (fr *FlatGeobufReader) Projection() string {
+	return fr.prj
+}
+
+// SetFilterBBox restricts Next to the features whose bounding box intersects
+// box. If the file has a spatial index, the features that it rules out are
+// skipped without decoding them. It must be called before the first call to
+// Next.
+func (fr *FlatGeobufReader) SetFilterBBox(box Box) {
+	fr.filter = &box
+	if fr.index != nil {
+		fr.wanted = searchFGBTree(fr.index, fr.count, fr.nodeSize, box)
+		if fr.wanted == nil {
+			fr.wanted = []uint64{}
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package shp
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestFlatGeobufRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "polygons.shp")
+	w, err := Create(filename, POLYGONZ)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SetProjectionEPSG(4326)
+	w.SetFields([]Field{StringField("NAME", 20), NumberField("ID", 4), FloatField("AREA", 12, 2),
+		{Name: [11]byte{'O', 'K'}, Fieldtype: 'L', Size: 1}, DateField("SINCE")})
+	square := func(x, y, size float64) []Point {
+		return []Point{{x, y}, {x, y + size}, {x + size, y + size}, {x + size, y}, {x, y}}
+	}
+	hole := func(x, y, size float64) []Point {
+		return []Point{{x, y}, {x + size, y}, {x + size, y + size}, {x, y + size}, {x, y}}
+	}
+	var shapes []Shape
+	for i := 0; i < 50; i++ {
+		x := float64(i % 10 * 10)
+		y := float64(i / 10 * 10)
+		rings := [][]Point{square(x, y, 5), hole(x+1, y+1, 2), square(x+6, y+6, 3)}
+		z := make([][]float64, len(rings))
+		for j, ring := range rings {
+			z[j] = make([]float64, len(ring))
+			for k := range z[j] {
+				z[j][k] = float64(i)
+			}
+		}
+		s, err := NewPolygonZ(rings, z, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		shapes = append(shapes, s)
+		if i == 7 {
+			shapes[i] = &Null{}
+		}
+		w.Write(shapes[i])
+		w.WriteAttribute(i, 0, "area "+string(rune('A'+i%26)))
+		w.WriteAttribute(i, 1, i)
+		w.WriteAttribute(i, 2, float64(i)/4)
+		w.WriteAttribute(i, 3, i%2 == 0)
+		w.WriteAttributeDate(i, 4, 2000+i, 1, 31)
+	}
+	w.Close()
+
+	for _, opts := range []FlatGeobufOptions{{Name: "polygons"}, {Name: "polygons", NoIndex: true}, {NodeSize: 2}} {
+		r, err := Open(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		err = WriteFlatGeobuf(&buf, r, opts)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		fr, err := NewFlatGeobufReader(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fr.GeometryType != POLYGONZ || fr.Name() != opts.Name {
+			t.Errorf("%+v: geometry type %v and name %q", opts, fr.GeometryType, fr.Name())
+		}
+		if prj, _ := EPSGToWKT(4326); fr.Projection() != prj {
+			t.Errorf("%+v: projection = %q", opts, fr.Projection())
+		}
+		wantFields := []Field{StringField("NAME", 20), NumberField("ID", 4), {Fieldtype: 'N', Size: 12, Precision: 2},
+			{Fieldtype: 'L', Size: 1}, DateField("SINCE")}
+		copy(wantFields[2].Name[:], "AREA")
+		copy(wantFields[3].Name[:], "OK")
+		if !reflect.DeepEqual(fr.Fields(), wantFields) {
+			t.Errorf("%+v: fields = %v, want %v", opts, fr.Fields(), wantFields)
+		}
+		seen := 0
+		for fr.Next() {
+			seen++
+			_, s := fr.Shape()
+			id, err := AttributeInt(fr, 1)
+			if err != nil {
+				t.Fatalf("%+v: %v", opts, err)
+			}
+			i := int(id)
+			if !Equal(s, shapes[i]) {
+				t.Errorf("%+v: shape %d = %#v, want %#v", opts, i, s, shapes[i])
+			}
+			want := []string{"area " + string(rune('A'+i%26)), strconv.Itoa(i),
+				strconv.FormatFloat(float64(i)/4, 'f', 2, 64), "F", fmt.Sprintf("%d0131", 2000+i)}
+			if i%2 == 0 {
+				want[3] = "T"
+			}
+			if got := Attributes(fr); !reflect.DeepEqual(got, want) {
+				t.Errorf("%+v: attributes of %d = %q, want %q", opts, i, got, want)
+			}
+		}
+		if err := fr.Err(); err != nil || seen != 50 {
+			t.Errorf("%+v: read %d features, %v", opts, seen, err)
+		}
+	}
+}
+
+func TestFlatGeobufFilter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "points.shp")
+	w, err := Create(filename, POINT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SetFields([]Field{NumberField("ID", 6)})
+	rnd := rand.New(rand.NewSource(1))
+	var points []Point
+	for i := 0; i < 1000; i++ {
+		p := Point{rnd.Float64() * 100, rnd.Float64() * 50}
+		points = append(points, p)
+		w.Write(&p)
+		w.WriteAttribute(i, 0, i)
+	}
+	w.Close()
+
+	r, err := Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fgb := filepath.Join(dir, "points.fgb")
+	f, err := os.Create(fgb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = WriteFlatGeobuf(f, r, FlatGeobufOptions{})
+	r.Close()
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 20; i++ {
+		x, y := rnd.Float64()*100, rnd.Float64()*50
+		box := Box{x, y, x + rnd.Float64()*30, y + rnd.Float64()*30}
+		want := map[int]bool{}
+		for j, p := range points {
+			if box.ContainsPoint(p) {
+				want[j] = true
+			}
+		}
+		fr, err := OpenFlatGeobuf(fgb)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fr.SetFilterBBox(box)
+		got := map[int]bool{}
+		for fr.Next() {
+			id, _ := AttributeInt(fr, 0)
+			got[int(id)] = true
+		}
+		if err := fr.Err(); err != nil {
+			t.Fatal(err)
+		}
+		fr.Close()
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("box %v: got %d points, want %d", box, len(got), len(want))
+		}
+	}
+}
+
+func TestFlatGeobufToShapefile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	r, err := Open("test_files/polyline.shp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	var buf bytes.Buffer
+	if err := WriteFlatGeobuf(&buf, r, FlatGeobufOptions{NoIndex: true}); err != nil {
+		t.Fatal(err)
+	}
+	fr, err := NewFlatGeobufReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := Create(filepath.Join(dir, "copy.shp"), fr.GeometryType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.SetFieldsFrom(fr); err != nil {
+		t.Fatal(err)
+	}
+	if err := Pipe(fr, w, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	orig, err := Open("test_files/polyline.shp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer orig.Close()
+	copied, err := Open(filepath.Join(dir, "copy.shp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer copied.Close()
+	for orig.Next() {
+		if !copied.Next() {
+			t.Fatal("copy has fewer shapes")
+		}
+		_, a := orig.Shape()
+		_, b := copied.Shape()
+		if !Equal(a, b) {
+			t.Errorf("got %#v, want %#v", b, a)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package shp
+
+import (
+	"encoding/binary"
+	"math"
+	"sort"
+)
+
+// fgbNodeSize is the size in bytes of a node of the packed Hilbert R-tree
+// of FlatGeobuf: its box followed by the offset of a feature, for leaves, or
+// the index of its first child.
+const fgbNodeSize = 40
+
+// fgbNode is a node of the packed Hilbert R-tree.
+type fgbNode struct {
+	box    Box
+	offset uint64
+}
+
+// emptyBox is the box of features without geometry, which intersects
+// nothing.
+var emptyBox = Box{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+
+func (n *fgbNode) expand(b Box) {
+	n.box.MinX, n.box.MinY = math.Min(n.box.MinX, b.MinX), math.Min(n.box.MinY, b.MinY)
+	n.box.MaxX, n.box.MaxY = math.Max(n.box.MaxX, b.MaxX), math.Max(n.box.MaxY, b.MaxY)
+}
+
+// fgbLevelBounds returns the [start, end) indices of the nodes of every level
+// of a tree of numItems leaves, starting with the leaves, which are stored
+// last. The root is the first node.
+func fgbLevelBounds(numItems, nodeSize int) [][2]int {
+	counts := []int{numItems}
+	n, numNodes := numItems, numItems
+	for {
+		n = (n + nodeSize - 1) / nodeSize
+		numNodes += n
+		counts = append(counts, n)
+		if n <= 1 {
+			break
+		}
+	}
+	bounds := make([][2]int, len(counts))
+	end := numNodes
+	for i, c := range counts {
+		bounds[i] = [2]int{end - c, end}
+		end -= c
+	}
+	return bounds
+}
+
+// fgbBuildTree returns the nodes of the tree whose leaves are given, which
+// must be sorted already.
+func fgbBuildTree(leaves []fgbNode, nodeSize int) []fgbNode {
+	bounds := fgbLevelBounds(len(leaves), nodeSize)
+	nodes := make([]fgbNode, bounds[0][1])
+	copy(nodes[bounds[0][0]:], leaves)
+	for level := 0; level < len(bounds)-1; level++ {
+		parent := bounds[level+1][0]
+		for pos := bounds[level][0]; pos < bounds[level][1]; parent++ {
+			node := fgbNode{box: emptyBox, offset: uint64(pos)}
+			for j := 0; j < nodeSize && pos < bounds[level][1]; j, pos = j+1, pos+1 {
+				node.expand(nodes[pos].box)
+			}
+			nodes[parent] = node
+		}
+	}
+	return nodes
+}
+
+// encodeFGBTree returns the nodes in the format of FlatGeobuf.
+func encodeFGBTree(nodes []fgbNode) []byte {
+	b := make([]byte, 0, len(nodes)*fgbNodeSize)
+	for _, n := range nodes {
+		for _, v := range []float64{n.box.MinX, n.box.MinY, n.box.MaxX, n.box.MaxY} {
+			b = appendUint64(b, math.Float64bits(v))
+		}
+		b = appendUint64(b, n.offset)
+	}
+	return b
+}
+
+// searchFGBTree returns the offsets of the features whose box intersects
+// box, in the order of the features, from a tree of numItems leaves in the
+// format of FlatGeobuf.
+func searchFGBTree(tree []byte, numItems, nodeSize int, box Box) []uint64 {
+	bounds := fgbLevelBounds(numItems, nodeSize)
+	node := func(i int) fgbNode {
+		b := tree[i*fgbNodeSize:]
+		f := func(j int) float64 {
+			return math.Float64frombits(binary.LittleEndian.Uint64(b[8*j:]))
+		}
+		return fgbNode{Box{f(0), f(1), f(2), f(3)}, binary.LittleEndian.Uint64(b[32:])}
+	}
+	var offsets []uint64
+	type item struct{ start, level int }
+	stack := []item{{0, len(bounds) - 1}}
+	for len(stack) > 0 {
+		it := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		end := it.start + nodeSize
+		if end > bounds[it.level][1] {
+			end = bounds[it.level][1]
+		}
+		for i := it.start; i < end; i++ {
+			n := node(i)
+			if !box.Intersects(n.box) {
+				continue
+			}
+			if it.level == 0 {
+				offsets = append(offsets, n.offset)
+			} else if int(n.offset) >= bounds[it.level-1][0] && int(n.offset) < bounds[it.level-1][1] {
+				stack = append(stack, item{int(n.offset), it.level - 1})
+			}
+		}
+	}
+	sort.Slice(offsets, func(i, j int) bool { return offsets[i] < offsets[j] })
+	return offsets
+}
+
+// hilbert returns the position of x and y, which are 16-bit values, on the
+// Hilbert curve.
+func hilbert(x, y uint32) uint32 {
+	a := x ^ y
+	b := 0xFFFF ^ a
+	c := 0xFFFF ^ (x | y)
+	d := x & (y ^ 0xFFFF)
+
+	A := a | (b >> 1)
+	B := (a >> 1) ^ a
+	C := ((c >> 1) ^ (b & (d >> 1))) ^ c
+	D := ((a & (c >> 1)) ^ (d >> 1)) ^ d
+
+	a, b, c, d = A, B, C, D
+	A = (a & (a >> 2)) ^ (b & (b >> 2))
+	B = (a & (b >> 2)) ^ (b & ((a ^ b) >> 2))
+	C ^= (a & (c >> 2)) ^ (b & (d >> 2))
+	D ^= (b & (c >> 2)) ^ ((a ^ b) & (d >> 2))
+
+	a, b, c, d = A, B, C, D
+	A = (a & (a >> 4)) ^ (b & (b >> 4))
+	B = (a & (b >> 4)) ^ (b & ((a ^ b) >> 4))
+	C ^= (a & (c >> 4)) ^ (b & (d >> 4))
+	D ^= (b & (c >> 4)) ^ ((a ^ b) & (d >> 4))
+
+	a, b, c, d = A, B, C, D
+	C ^= (a & (c >> 8)) ^ (b & (d >> 8))
+	D ^= (b & (c >> 8)) ^ ((a ^ b) & (d >> 8))
+
+	a = C ^ (C >> 1)
+	b = D ^ (D >> 1)
+
+	i0 := x ^ y
+	i1 := b | (0xFFFF ^ (i0 | a))
+	spread := func(v uint32) uint32 {
+		v = (v | (v << 8)) & 0x00FF00FF
+		v = (v | (v << 4)) & 0x0F0F0F0F
+		v = (v | (v << 2)) & 0x33333333
+		return (v | (v << 1)) & 0x55555555
+	}
+	return (spread(i1) << 1) | spread(i0)
+}
+
+// hilbertOf returns the position of the center of b on the Hilbert curve
+// over extent. Empty boxes are at 0.
+func hilbertOf(b, extent Box) uint32 {
+	if b.MinX > b.MaxX {
+		return 0
+	}
+	const max = 1<<16 - 1
+	scale := func(v, min, size float64) uint32 {
+		if size == 0 {
+			return 0
+		}
+		return uint32(math.Floor(max * (v - min) / size))
+	}
+	x := scale((b.MinX+b.MaxX)/2, extent.MinX, extent.MaxX-extent.MinX)
+	y := scale((b.MinY+b.MaxY)/2, extent.MinY, extent.MaxY-extent.MinY)
+	return hilbert(x, y)
+}
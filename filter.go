@@ -0,0 +1,86 @@
+package shp
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// recordBox returns the extent of the record with the given shape type from
+// its content, which does not include the shape type. Only the extent is
+// decoded: the coordinates of points and the bounding box that precedes the
+// points of all other shapes. It returns false for Null shapes and if the
+// content is too short to hold the extent.
+func recordBox(t ShapeType, content []byte) (Box, bool) {
+	f := func(i int) float64 {
+		return math.Float64frombits(binary.LittleEndian.Uint64(content[8*i:]))
+	}
+	switch t {
+	case NULL:
+		return Box{}, false
+	case POINT, POINTZ, POINTM:
+		if len(content) < 16 {
+			return Box{}, false
+		}
+		return Box{f(0), f(1), f(0), f(1)}, true
+	}
+	if len(content) < 32 {
+		return Box{}, false
+	}
+	return Box{f(0), f(1), f(2), f(3)}, true
+}
+
+// filtered reports whether the record with the given shape type and content
+// lies outside of filter and is to be skipped without decoding it. Records
+// whose extent cannot be determined are never filtered, so that decoding them
+// reports the problem. Null shapes have no extent and are always filtered.
+func filtered(filter *Box, t ShapeType, content []byte, swapXY bool) bool {
+	if filter == nil {
+		return false
+	}
+	if t == NULL {
+		return true
+	}
+	b, ok := recordBox(t, content)
+	if !ok {
+		return false
+	}
+	if swapXY {
+		b = swapBox(b)
+	}
+	return !filter.Intersects(b)
+}
+
+// SetFilterBBox restricts Next to the shapes whose bounding box intersects
+// box. The other records, including Null shapes, are skipped without decoding
+// their points, but they still advance the attribute rows, so Shape and
+// Attribute keep referring to the same record. The box is in the coordinates
+// that are returned, i.e. after swapping X and Y if WithSwapXY is used. If the
+// shapefile has a .qix or an .sbn index, the records that it rules out are
+// skipped without reading them.
+func (r *Reader) SetFilterBBox(box Box) {
+	r.filter = &box
+	r.candidates = nil
+	if q := r.loadSpatialIndex(); q != nil && r.recovered == nil {
+		if r.opts.swapXY {
+			box = swapBox(box)
+		}
+		r.candidates = make(map[int]bool)
+		for _, n := range q.Search(box) {
+			r.candidates[n] = true
+		}
+	}
+}
+
+// SetFilterBBox restricts Next to the shapes whose bounding box intersects
+// box, like Reader.SetFilterBBox.
+func (sr *seqReader) SetFilterBBox(box Box) {
+	sr.filter = &box
+}
+
+// SetFilterBBox restricts Next to the shapes whose bounding box intersects
+// box, like Reader.SetFilterBBox.
+func (zr *ZipReader) SetFilterBBox(box Box) {
+	if f, ok := zr.sr.(interface{ SetFilterBBox(Box) }); ok {
+		f.SetFilterBBox(box)
+	}
+}
@@ -0,0 +1,74 @@
+package shp
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestSetFilterBBox(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "grid.shp")
+	w, err := Create(filename, POLYLINE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SetFields([]Field{NumberField("ID", 4)})
+	for i := 0; i < 100; i++ {
+		x, y := float64(i%10), float64(i/10)
+		w.Write(NewPolyLine([][]Point{{{x, y}, {x + 0.5, y + 0.2}}}))
+		w.WriteAttribute(i, 0, i)
+	}
+	w.Write(&Null{})
+	w.WriteAttribute(100, 0, 100)
+	w.Close()
+
+	r, err := Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	sr, err := OpenDataset(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sr.Close()
+	swapped, err := Open(filename, WithSwapXY())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer swapped.Close()
+
+	// the filter touches the boxes of records 34, 35, 44 and 45
+	filter := Box{MinX: 4.5, MinY: 3.1, MaxX: 5.2, MaxY: 4.2}
+	r.SetFilterBBox(filter)
+	sr.(*seqReader).SetFilterBBox(filter)
+	swapped.SetFilterBBox(swapBox(filter))
+	want := []int{34, 35, 44, 45}
+	for _, test := range []struct {
+		name string
+		sr   SequentialReader
+	}{{"Reader", r}, {"seqReader", sr}, {"swapped", swapped}} {
+		var got []int
+		for test.sr.Next() {
+			n, _ := test.sr.Shape()
+			if id := test.sr.Attribute(0); id != strconv.Itoa(n) {
+				t.Errorf("%s: record %d has attribute %s", test.name, n, id)
+			}
+			got = append(got, n)
+		}
+		if err := test.sr.Err(); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got records %v, want %v", test.name, got, want)
+		}
+	}
+}
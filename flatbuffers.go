@@ -0,0 +1,375 @@
+package shp
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// fbBuilder builds FlatBuffers front to back: every table is followed by
+// the strings, vectors and tables that it refers to, so that all offsets
+// point forward as the format requires. It only supports what FlatGeobuf
+// needs.
+type fbBuilder struct {
+	buf []byte
+}
+
+// fbField is a field of a table, which is either a little-endian scalar or a
+// reference to an object written by child, which returns its position.
+type fbField struct {
+	scalar []byte
+	child  func(b *fbBuilder) int
+}
+
+func (b *fbBuilder) pad(align, offset int) {
+	for (len(b.buf)+offset)%align != 0 {
+		b.buf = append(b.buf, 0)
+	}
+}
+
+// finish writes the root table and returns the buffer.
+func (b *fbBuilder) finish(root []fbField) []byte {
+	b.buf = append(b.buf[:0], 0, 0, 0, 0)
+	pos := b.table(root)
+	binary.LittleEndian.PutUint32(b.buf, uint32(pos))
+	return b.buf
+}
+
+// table writes a table with the fields, which are absent if they are the zero
+// fbField, preceded by its vtable and returns its position.
+func (b *fbBuilder) table(fields []fbField) int {
+	n := 0
+	for i, f := range fields {
+		if f.scalar != nil || f.child != nil {
+			n = i + 1
+		}
+	}
+	// lay out the fields after the offset of the vtable, aligned to their
+	// size relative to the table, which is aligned to 8 bytes
+	offsets := make([]int, n)
+	size := 4
+	for i, f := range fields[:n] {
+		width := len(f.scalar)
+		if f.child != nil {
+			width = 4
+		} else if width == 0 {
+			continue
+		}
+		for size%width != 0 {
+			size++
+		}
+		offsets[i] = size
+		size += width
+	}
+
+	b.pad(2, 0)
+	vtable := len(b.buf)
+	b.buf = appendUint16(b.buf, uint16(4+2*n))
+	b.buf = appendUint16(b.buf, uint16(size))
+	for _, off := range offsets {
+		b.buf = appendUint16(b.buf, uint16(off))
+	}
+	b.pad(8, 0)
+	pos := len(b.buf)
+	b.buf = append(b.buf, make([]byte, size)...)
+	binary.LittleEndian.PutUint32(b.buf[pos:], uint32(int32(pos-vtable)))
+	for i, f := range fields[:n] {
+		if f.scalar != nil {
+			copy(b.buf[pos+offsets[i]:], f.scalar)
+		}
+	}
+	for i, f := range fields[:n] {
+		if f.child != nil {
+			at := pos + offsets[i]
+			child := f.child(b)
+			binary.LittleEndian.PutUint32(b.buf[at:], uint32(child-at))
+		}
+	}
+	return pos
+}
+
+// vector writes a vector of n elements of the given size, encoded in body,
+// and returns its position.
+func (b *fbBuilder) vector(n, size int, body []byte) int {
+	b.pad(4, 0)
+	if size > 4 {
+		b.pad(size, 4)
+	}
+	pos := len(b.buf)
+	b.buf = appendUint32(b.buf, uint32(n))
+	b.buf = append(b.buf, body...)
+	return pos
+}
+
+// tables writes a vector of tables and returns its position.
+func (b *fbBuilder) tables(tables [][]fbField) int {
+	pos := b.vector(len(tables), 4, make([]byte, 4*len(tables)))
+	for i, fields := range tables {
+		at := pos + 4 + 4*i
+		table := b.table(fields)
+		binary.LittleEndian.PutUint32(b.buf[at:], uint32(table-at))
+	}
+	return pos
+}
+
+// fbString returns a field with the string s, which is absent if s is empty.
+func fbString(s string) fbField {
+	if s == "" {
+		return fbField{}
+	}
+	return fbField{child: func(b *fbBuilder) int {
+		b.pad(4, 0)
+		pos := len(b.buf)
+		b.buf = appendUint32(b.buf, uint32(len(s)))
+		b.buf = append(append(b.buf, s...), 0)
+		return pos
+	}}
+}
+
+// fbFloat64s returns a field with a vector of doubles, which is absent if v
+// is empty.
+func fbFloat64s(v []float64) fbField {
+	if len(v) == 0 {
+		return fbField{}
+	}
+	return fbField{child: func(b *fbBuilder) int {
+		body := make([]byte, 0, 8*len(v))
+		for _, f := range v {
+			body = appendUint64(body, math.Float64bits(f))
+		}
+		return b.vector(len(v), 8, body)
+	}}
+}
+
+// fbUint32s returns a field with a vector of uint32, which is absent if v is
+// empty.
+func fbUint32s(v []uint32) fbField {
+	if len(v) == 0 {
+		return fbField{}
+	}
+	return fbField{child: func(b *fbBuilder) int {
+		body := make([]byte, 0, 4*len(v))
+		for _, u := range v {
+			body = appendUint32(body, u)
+		}
+		return b.vector(len(v), 4, body)
+	}}
+}
+
+// fbBytes returns a field with a vector of bytes, which is absent if v is
+// empty.
+func fbBytes(v []byte) fbField {
+	if len(v) == 0 {
+		return fbField{}
+	}
+	return fbField{child: func(b *fbBuilder) int {
+		return b.vector(len(v), 1, v)
+	}}
+}
+
+// fbTables returns a field with a vector of tables, which is absent if there
+// are none.
+func fbTables(tables [][]fbField) fbField {
+	if len(tables) == 0 {
+		return fbField{}
+	}
+	return fbField{child: func(b *fbBuilder) int { return b.tables(tables) }}
+}
+
+// fbTable returns a field with a table.
+func fbTable(fields []fbField) fbField {
+	return fbField{child: func(b *fbBuilder) int { return b.table(fields) }}
+}
+
+func fbUint8(v uint8) fbField   { return fbField{scalar: []byte{v}} }
+func fbUint16(v uint16) fbField { return fbField{scalar: appendUint16(nil, v)} }
+func fbInt32(v int32) fbField   { return fbField{scalar: appendUint32(nil, uint32(v))} }
+func fbUint64(v uint64) fbField { return fbField{scalar: appendUint64(nil, v)} }
+
+func fbBool(v bool) fbField {
+	if v {
+		return fbUint8(1)
+	}
+	return fbUint8(0)
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v), byte(v>>8))
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	return appendUint32(appendUint32(b, uint32(v)), uint32(v>>32))
+}
+
+// errFlatBuffer is the error of a FlatBuffer that refers beyond its end.
+var errFlatBuffer = errors.New("Invalid FlatBuffer: offset out of range")
+
+// fbDecoder reads tables from a FlatBuffer. Reads out of range return zero
+// values and set err.
+type fbDecoder struct {
+	buf []byte
+	err error
+}
+
+// fbTableReader is a table in a FlatBuffer.
+type fbTableReader struct {
+	d      *fbDecoder
+	pos    int
+	vtable int
+	fields int
+}
+
+func (d *fbDecoder) check(pos, n int) bool {
+	if pos < 0 || n < 0 || pos > len(d.buf)-n {
+		if d.err == nil {
+			d.err = errFlatBuffer
+		}
+		return false
+	}
+	return true
+}
+
+func (d *fbDecoder) uint32(pos int) uint32 {
+	if !d.check(pos, 4) {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(d.buf[pos:])
+}
+
+// root returns the root table.
+func (d *fbDecoder) root() fbTableReader {
+	return d.tableAt(int(d.uint32(0)))
+}
+
+func (d *fbDecoder) tableAt(pos int) fbTableReader {
+	t := fbTableReader{d: d, pos: pos}
+	t.vtable = pos - int(int32(d.uint32(pos)))
+	if d.check(t.vtable, 4) {
+		t.fields = (int(binary.LittleEndian.Uint16(d.buf[t.vtable:])) - 4) / 2
+	}
+	return t
+}
+
+// field returns the position of field i, or 0 if it is absent.
+func (t fbTableReader) field(i int) int {
+	if t.d.err != nil || i >= t.fields || !t.d.check(t.vtable+4+2*i, 2) {
+		return 0
+	}
+	off := int(binary.LittleEndian.Uint16(t.d.buf[t.vtable+4+2*i:]))
+	if off == 0 {
+		return 0
+	}
+	return t.pos + off
+}
+
+func (t fbTableReader) uint8(i int, def uint8) uint8 {
+	pos := t.field(i)
+	if pos == 0 || !t.d.check(pos, 1) {
+		return def
+	}
+	return t.d.buf[pos]
+}
+
+func (t fbTableReader) uint16(i int, def uint16) uint16 {
+	pos := t.field(i)
+	if pos == 0 || !t.d.check(pos, 2) {
+		return def
+	}
+	return binary.LittleEndian.Uint16(t.d.buf[pos:])
+}
+
+func (t fbTableReader) int32(i int, def int32) int32 {
+	pos := t.field(i)
+	if pos == 0 {
+		return def
+	}
+	return int32(t.d.uint32(pos))
+}
+
+func (t fbTableReader) uint64(i int) uint64 {
+	pos := t.field(i)
+	if pos == 0 || !t.d.check(pos, 8) {
+		return 0
+	}
+	return binary.LittleEndian.Uint64(t.d.buf[pos:])
+}
+
+// ref returns the position of the object that field i refers to, or 0.
+func (t fbTableReader) ref(i int) int {
+	pos := t.field(i)
+	if pos == 0 {
+		return 0
+	}
+	return pos + int(t.d.uint32(pos))
+}
+
+// vector returns the position of the first element of the vector in field i
+// and its length, checking that it lies within the buffer.
+func (t fbTableReader) vector(i, size int) (int, int) {
+	pos := t.ref(i)
+	if pos == 0 {
+		return 0, 0
+	}
+	n := int(t.d.uint32(pos))
+	if n > (len(t.d.buf)-pos-4)/size || !t.d.check(pos+4, n*size) {
+		if t.d.err == nil {
+			t.d.err = errFlatBuffer
+		}
+		return 0, 0
+	}
+	return pos + 4, n
+}
+
+func (t fbTableReader) bytes(i int) []byte {
+	pos, n := t.vector(i, 1)
+	if n == 0 {
+		return nil
+	}
+	return t.d.buf[pos : pos+n]
+}
+
+func (t fbTableReader) string(i int) string {
+	return string(t.bytes(i))
+}
+
+func (t fbTableReader) float64s(i int) []float64 {
+	pos, n := t.vector(i, 8)
+	v := make([]float64, n)
+	for j := range v {
+		v[j] = math.Float64frombits(binary.LittleEndian.Uint64(t.d.buf[pos+8*j:]))
+	}
+	return v
+}
+
+func (t fbTableReader) uint32s(i int) []uint32 {
+	pos, n := t.vector(i, 4)
+	v := make([]uint32, n)
+	for j := range v {
+		v[j] = binary.LittleEndian.Uint32(t.d.buf[pos+4*j:])
+	}
+	return v
+}
+
+// table returns the table in field i and whether it is present.
+func (t fbTableReader) table(i int) (fbTableReader, bool) {
+	pos := t.ref(i)
+	if pos == 0 {
+		return fbTableReader{}, false
+	}
+	return t.d.tableAt(pos), true
+}
+
+// tables returns the tables of the vector in field i.
+func (t fbTableReader) tables(i int) []fbTableReader {
+	pos, n := t.vector(i, 4)
+	tables := make([]fbTableReader, n)
+	for j := range tables {
+		at := pos + 4*j
+		tables[j] = t.d.tableAt(at + int(t.d.uint32(at)))
+	}
+	return tables
+}
@@ -0,0 +1,123 @@
+package shp
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// csvNumber matches the values of CSV columns that are stored in numeric
+// fields. Integers with leading zeros, such as postal codes, are kept as text.
+var csvNumber = regexp.MustCompile(`^[-+]?(0|[1-9]\d*)?(\.\d*)?([eE][-+]?\d+)?$`)
+
+// FromCSV reads a CSV table with a header row from r into a Dataset of points
+// that can be written as a shapefile with Dataset.Save. The coordinates are
+// read from the columns called xCol and yCol, ignoring case, and rows where
+// both are empty become Null shapes. Only the Comma of opts is used.
+//
+// The other columns become fields in the order of the table, with the types
+// inferred like the properties by FromGeoJSON: true and false become logical
+// fields, integers numeric fields, other numbers float fields, values of the
+// form YYYY-MM-DD date fields and everything else character fields. Empty
+// values are stored as blank values.
+func FromCSV(r io.Reader, xCol, yCol string, opts CSVOptions) (*Dataset, error) {
+	cr := csv.NewReader(r)
+	if opts.Comma != 0 {
+		cr.Comma = opts.Comma
+	}
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("Error reading CSV: no header row")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error reading CSV: %v", err)
+	}
+	x, y := -1, -1
+	var columns []int
+	for i, name := range header {
+		switch {
+		case strings.EqualFold(name, xCol) && x < 0:
+			x = i
+		case strings.EqualFold(name, yCol) && y < 0:
+			y = i
+		default:
+			columns = append(columns, i)
+		}
+	}
+	if x < 0 || y < 0 {
+		return nil, fmt.Errorf("Unable to read points from CSV: no columns called %s and %s", xCol, yCol)
+	}
+
+	d := &Dataset{GeometryType: POINT}
+	var values [][]interface{}
+	for line := 2; ; line++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Error reading CSV: %v", err)
+		}
+		var s Shape = &Null{}
+		if record[x] != "" || record[y] != "" {
+			p := &Point{}
+			if p.X, err = strconv.ParseFloat(strings.TrimSpace(record[x]), 64); err != nil {
+				return nil, fmt.Errorf("Unable to read point in line %d: invalid X %q", line, record[x])
+			}
+			if p.Y, err = strconv.ParseFloat(strings.TrimSpace(record[y]), 64); err != nil {
+				return nil, fmt.Errorf("Unable to read point in line %d: invalid Y %q", line, record[y])
+			}
+			s = p
+		}
+		d.extendBBox(s)
+		d.Shapes = append(d.Shapes, s)
+		row := make([]interface{}, len(columns))
+		for j, c := range columns {
+			row[j] = csvValue(record[c])
+		}
+		values = append(values, row)
+	}
+
+	used := make(map[string]bool)
+	d.Fields = make([]Field, len(columns))
+	d.Attributes = make([][]string, len(values))
+	for i := range d.Attributes {
+		d.Attributes[i] = make([]string, len(columns))
+	}
+	for j, c := range columns {
+		column := make([]interface{}, len(values))
+		for i := range values {
+			column[i] = values[i][j]
+		}
+		field, strs := inferField(column)
+		copy(field.Name[:], fieldName(header[c], used))
+		d.Fields[j] = field
+		for i := range strs {
+			d.Attributes[i][j] = strs[i]
+		}
+	}
+	return d, nil
+}
+
+// csvValue returns the value of a CSV cell as it would be decoded from JSON,
+// so that the field can be inferred by inferField.
+func csvValue(v string) interface{} {
+	t := strings.TrimSpace(v)
+	switch {
+	case t == "":
+		return nil
+	case strings.EqualFold(t, "true"):
+		return true
+	case strings.EqualFold(t, "false"):
+		return false
+	case csvNumber.MatchString(t) && strings.ContainsAny(t, "0123456789"):
+		if _, err := strconv.ParseFloat(t, 64); err == nil {
+			return json.Number(t)
+		}
+	}
+	return v
+}
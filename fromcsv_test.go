@@ -0,0 +1,100 @@
+package shp
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFromCSV(t *testing.T) {
+	const input = "name;lon;lat;zip;pop;area;open;since\n" +
+		"a;1.5;2;01234;10;0.5;true;2001-02-03\n" +
+		"b;;;10115;;2;FALSE;\n" +
+		"c;-3;4.25;;200;1.25;;2010-01-01\n"
+	d, err := FromCSV(strings.NewReader(input), "LON", "Lat", CSVOptions{Comma: ';'})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.GeometryType != POINT || len(d.Shapes) != 3 {
+		t.Fatalf("got %v with %d shapes", d.GeometryType, len(d.Shapes))
+	}
+	if p, ok := d.Shapes[0].(*Point); !ok || *p != (Point{1.5, 2}) {
+		t.Errorf("shape 0: got %#v", d.Shapes[0])
+	}
+	if _, ok := d.Shapes[1].(*Null); !ok {
+		t.Errorf("shape 1: got %#v, want Null", d.Shapes[1])
+	}
+	if want := (Box{-3, 2, 1.5, 4.25}); d.BBox != want {
+		t.Errorf("got box %v, want %v", d.BBox, want)
+	}
+
+	wantFields := []struct {
+		name      string
+		fieldtype byte
+		size      uint8
+		precision uint8
+	}{
+		{"name", 'C', 1, 0},
+		{"zip", 'C', 5, 0},
+		{"pop", 'N', 3, 0},
+		{"area", 'F', 4, 2},
+		{"open", 'L', 1, 0},
+		{"since", 'D', 8, 0},
+	}
+	if len(d.Fields) != len(wantFields) {
+		t.Fatalf("got %d fields, want %d", len(d.Fields), len(wantFields))
+	}
+	for i, want := range wantFields {
+		f := d.Fields[i]
+		if f.String() != want.name || f.Fieldtype != want.fieldtype || f.Size != want.size || f.Precision != want.precision {
+			t.Errorf("field %d: got %s %c %d %d, want %v", i, f, f.Fieldtype, f.Size, f.Precision, want)
+		}
+	}
+	wantAttrs := [][]string{
+		{"a", "01234", "10", "0.50", "T", "20010203"},
+		{"b", "10115", "", "2.00", "F", ""},
+		{"c", "", "200", "1.25", "", "20100101"},
+	}
+	for i, want := range wantAttrs {
+		if strings.Join(d.Attributes[i], ",") != strings.Join(want, ",") {
+			t.Errorf("row %d: got %q, want %q", i, d.Attributes[i], want)
+		}
+	}
+
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "points.shp")
+	if err := d.Save(filename); err != nil {
+		t.Fatal(err)
+	}
+	r, err := Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	n := 0
+	for r.Next() {
+		n++
+	}
+	if n != 3 || r.ReadAttribute(2, 3) != "1.25" {
+		t.Errorf("got %d records with area %q", n, r.ReadAttribute(2, 3))
+	}
+}
+
+func TestFromCSVErrors(t *testing.T) {
+	for _, input := range []string{
+		"",
+		"x,z\n1,2\n",
+		"x,y\n1,a\n",
+		"x,y\n1,2,3\n",
+	} {
+		if _, err := FromCSV(strings.NewReader(input), "x", "y", CSVOptions{}); err == nil {
+			t.Errorf("%q: expected an error", input)
+		}
+	}
+}
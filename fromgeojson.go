@@ -0,0 +1,474 @@
+package shp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// FromGeoJSON reads a GeoJSON FeatureCollection, a single Feature or a bare
+// geometry from r into a Dataset that can be written as a shapefile with
+// Dataset.Save.
+//
+// The shape type is derived from the geometries: Point becomes POINT,
+// MultiPoint MULTIPOINT, LineString and MultiLineString POLYLINE, Polygon and
+// MultiPolygon POLYGON. If any position has a third coordinate, the Z variant
+// of the type is used and missing Z values are 0. Null geometries become Null
+// shapes. All other geometries must map to the same shape type.
+//
+// The fields are the union of the properties of all features in the order in
+// which they first appear, with names truncated to the 10 characters allowed
+// by DBF. Their types are inferred from the values: booleans become logical
+// fields, integers numeric fields, other numbers float fields, strings of
+// the form YYYY-MM-DD date fields and everything else character fields. JSON
+// null is stored as a blank value.
+func FromGeoJSON(r io.Reader) (*Dataset, error) {
+	var doc struct {
+		Type       string
+		Features   []geoJSONInput
+		Geometry   json.RawMessage
+		Properties geoJSONProperties
+	}
+	raw, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, fmt.Errorf("Error decoding GeoJSON: %v", err)
+	}
+	var features []geoJSONInput
+	switch doc.Type {
+	case "FeatureCollection":
+		features = doc.Features
+	case "Feature":
+		features = []geoJSONInput{{Geometry: doc.Geometry, Properties: doc.Properties}}
+	default:
+		features = []geoJSONInput{{Geometry: raw}}
+	}
+
+	geoms := make([]*geoJSONShape, len(features))
+	kind, hasZ := "", false
+	for i, f := range features {
+		g, err := decodeGeoJSONGeometry(f.Geometry)
+		if err != nil {
+			return nil, fmt.Errorf("Error decoding geometry of feature %d: %v", i, err)
+		}
+		if g != nil {
+			if kind != "" && g.kind != kind {
+				return nil, fmt.Errorf("Unable to store feature %d: mixed geometry types %s and %s", i, kind, g.kind)
+			}
+			kind, hasZ = g.kind, hasZ || g.hasZ
+		}
+		geoms[i] = g
+	}
+
+	d := &Dataset{GeometryType: geoJSONShapeType(kind, hasZ)}
+	empty := true
+	for i, g := range geoms {
+		s, err := g.shape(hasZ)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to store feature %d: %v", i, err)
+		}
+		// the box of a Null shape would extend the box to the origin
+		if g != nil && empty {
+			d.BBox, empty = s.BBox(), false
+		} else if g != nil {
+			d.BBox.Extend(s.BBox())
+		}
+		d.Shapes = append(d.Shapes, s)
+	}
+	d.Fields, d.Attributes = inferFields(features)
+	return d, nil
+}
+
+// Save writes the dataset as a shapefile to filename.
+func (d *Dataset) Save(filename string, opts ...Option) error {
+	w, err := Create(filename, d.GeometryType, opts...)
+	if err != nil {
+		return err
+	}
+	if len(d.Fields) > 0 {
+		if err := w.SetFields(d.Fields); err != nil {
+			w.Abort()
+			return err
+		}
+	}
+	for i, s := range d.Shapes {
+		row := w.Write(s)
+		if row < 0 {
+			w.Abort()
+			return w.Err()
+		}
+		for j, v := range d.Attributes[i] {
+			if v == "" {
+				continue
+			}
+			if err := w.WriteAttribute(int(row), j, v); err != nil {
+				w.Abort()
+				return err
+			}
+		}
+	}
+	w.Close()
+	return w.Err()
+}
+
+// geoJSONInput is a GeoJSON Feature as it is decoded.
+type geoJSONInput struct {
+	Geometry   json.RawMessage
+	Properties geoJSONProperties
+}
+
+// geoJSONProperties holds the properties of a feature in the order in which
+// they appear in the document.
+type geoJSONProperties struct {
+	names  []string
+	values []json.RawMessage
+}
+
+func (p *geoJSONProperties) UnmarshalJSON(b []byte) error {
+	if bytes.Equal(bytes.TrimSpace(b), []byte("null")) {
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	if t, err := dec.Token(); err != nil || t != json.Delim('{') {
+		return fmt.Errorf("properties are not an object")
+	}
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		var v json.RawMessage
+		if err := dec.Decode(&v); err != nil {
+			return err
+		}
+		p.names = append(p.names, t.(string))
+		p.values = append(p.values, v)
+	}
+	return nil
+}
+
+// geoJSONShape is a decoded GeoJSON geometry. kind is the geometry type
+// without the Multi prefix, except for MultiPoint. Polygons have one entry in
+// parts per ring.
+type geoJSONShape struct {
+	kind  string
+	parts [][]Point
+	z     [][]float64
+	hasZ  bool
+}
+
+// decodeGeoJSONGeometry decodes a GeoJSON geometry. It returns nil for a null
+// geometry.
+func decodeGeoJSONGeometry(b json.RawMessage) (*geoJSONShape, error) {
+	if len(b) == 0 || bytes.Equal(bytes.TrimSpace(b), []byte("null")) {
+		return nil, nil
+	}
+	var g struct {
+		Type        string
+		Coordinates json.RawMessage
+	}
+	if err := json.Unmarshal(b, &g); err != nil {
+		return nil, err
+	}
+	// depth is the nesting of the coordinates below a single position
+	depth := map[string]int{
+		"Point": 0, "MultiPoint": 1, "LineString": 1,
+		"MultiLineString": 2, "Polygon": 2, "MultiPolygon": 3,
+	}
+	n, ok := depth[g.Type]
+	if !ok {
+		return nil, fmt.Errorf("Unsupported GeoJSON geometry type: %q", g.Type)
+	}
+	s := &geoJSONShape{kind: g.Type}
+	if g.Type != "MultiPoint" {
+		s.kind = strings.TrimPrefix(g.Type, "Multi")
+	}
+	var lines [][][]float64
+	switch n {
+	case 0:
+		var p []float64
+		if err := json.Unmarshal(g.Coordinates, &p); err != nil {
+			return nil, err
+		}
+		lines = [][][]float64{{p}}
+	case 1:
+		var l [][]float64
+		if err := json.Unmarshal(g.Coordinates, &l); err != nil {
+			return nil, err
+		}
+		lines = [][][]float64{l}
+	case 2:
+		if err := json.Unmarshal(g.Coordinates, &lines); err != nil {
+			return nil, err
+		}
+	case 3:
+		var polygons [][][][]float64
+		if err := json.Unmarshal(g.Coordinates, &polygons); err != nil {
+			return nil, err
+		}
+		for _, p := range polygons {
+			lines = append(lines, p...)
+		}
+	}
+	for _, l := range lines {
+		points := make([]Point, len(l))
+		z := make([]float64, len(l))
+		for i, pos := range l {
+			if len(pos) < 2 {
+				return nil, fmt.Errorf("position with %d coordinates", len(pos))
+			}
+			points[i] = Point{pos[0], pos[1]}
+			if len(pos) > 2 {
+				z[i], s.hasZ = pos[2], true
+			}
+		}
+		s.parts = append(s.parts, points)
+		s.z = append(s.z, z)
+	}
+	return s, nil
+}
+
+// geoJSONShapeType returns the shape type of geometries of the given kind.
+func geoJSONShapeType(kind string, z bool) ShapeType {
+	types := map[string][2]ShapeType{
+		"":           {NULL, NULL},
+		"Point":      {POINT, POINTZ},
+		"MultiPoint": {MULTIPOINT, MULTIPOINTZ},
+		"LineString": {POLYLINE, POLYLINEZ},
+		"Polygon":    {POLYGON, POLYGONZ},
+	}
+	t := types[kind]
+	if z {
+		return t[1]
+	}
+	return t[0]
+}
+
+// shape returns the shape of g, which is a Z shape if z is set.
+func (g *geoJSONShape) shape(z bool) (Shape, error) {
+	if g == nil {
+		return &Null{}, nil
+	}
+	switch g.kind {
+	case "Point":
+		p := g.parts[0][0]
+		if z {
+			return &PointZ{X: p.X, Y: p.Y, Z: g.z[0][0]}, nil
+		}
+		return &Point{X: p.X, Y: p.Y}, nil
+	case "MultiPoint":
+		if z {
+			return NewMultiPointZ(flatten(g.parts), flattenFloats(g.z), nil)
+		}
+		return NewMultiPoint(flatten(g.parts))
+	case "LineString":
+		if z {
+			return NewPolyLineZ(g.parts, g.z, nil)
+		}
+		if err := checkParts(g.parts, 2, "PolyLine"); err != nil {
+			return nil, err
+		}
+		return NewPolyLine(g.parts), nil
+	default:
+		if z {
+			return NewPolygonZ(g.parts, g.z, nil)
+		}
+		return NewPolygon(g.parts)
+	}
+}
+
+func flattenFloats(f [][]float64) []float64 {
+	var r []float64
+	for _, v := range f {
+		r = append(r, v...)
+	}
+	return r
+}
+
+// isoDate matches the dates that are stored in date fields.
+var isoDate = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+
+// inferFields returns the fields for the properties of the features and the
+// attributes of every feature.
+func inferFields(features []geoJSONInput) ([]Field, [][]string) {
+	var names []string
+	index := make(map[string]int)
+	for _, f := range features {
+		for _, name := range f.Properties.names {
+			if _, ok := index[name]; !ok {
+				index[name] = len(names)
+				names = append(names, name)
+			}
+		}
+	}
+
+	// values[i][j] is the value of property j of feature i, nil if missing
+	values := make([][]interface{}, len(features))
+	for i, f := range features {
+		values[i] = make([]interface{}, len(names))
+		for k, name := range f.Properties.names {
+			dec := json.NewDecoder(bytes.NewReader(f.Properties.values[k]))
+			dec.UseNumber()
+			var v interface{}
+			if dec.Decode(&v) == nil {
+				values[i][index[name]] = v
+			}
+		}
+	}
+
+	fields := make([]Field, len(names))
+	used := make(map[string]bool)
+	attrs := make([][]string, len(features))
+	for i := range attrs {
+		attrs[i] = make([]string, len(names))
+	}
+	for j, name := range names {
+		column := make([]interface{}, len(features))
+		for i := range features {
+			column[i] = values[i][j]
+		}
+		field, strs := inferField(column)
+		copy(field.Name[:], fieldName(name, used))
+		fields[j] = field
+		for i := range attrs {
+			attrs[i][j] = strs[i]
+		}
+	}
+	return fields, attrs
+}
+
+// fieldName returns name truncated to the length allowed by DBF and made
+// unique among the names in used by replacing its end with a number.
+func fieldName(name string, used map[string]bool) string {
+	const max = 10
+	short := name
+	if len(short) > max {
+		short = short[:max]
+	}
+	for n := 1; used[strings.ToUpper(short)]; n++ {
+		suffix := "_" + strconv.Itoa(n)
+		short = name
+		if len(short) > max-len(suffix) {
+			short = short[:max-len(suffix)]
+		}
+		short += suffix
+	}
+	used[strings.ToUpper(short)] = true
+	return short
+}
+
+// inferField returns the field that can hold all values of a column and the
+// values as they are stored in the DBF.
+func inferField(column []interface{}) (Field, []string) {
+	kind := byte(0)
+	for _, v := range column {
+		var k byte
+		switch v := v.(type) {
+		case nil:
+			continue
+		case bool:
+			k = 'L'
+		case json.Number:
+			k = 'N'
+			if strings.ContainsAny(string(v), ".eE") {
+				k = 'F'
+			}
+		case string:
+			k = 'C'
+			if isoDate.MatchString(v) {
+				k = 'D'
+			}
+		default:
+			k = 'C'
+		}
+		switch {
+		case kind == 0 || kind == k:
+			kind = k
+		case (kind == 'N' && k == 'F') || (kind == 'F' && k == 'N'):
+			kind = 'F'
+		default:
+			kind = 'C'
+		}
+	}
+
+	strs := make([]string, len(column))
+	switch kind {
+	case 'L':
+		for i, v := range column {
+			if v == true {
+				strs[i] = "T"
+			} else if v == false {
+				strs[i] = "F"
+			}
+		}
+		return LogicalField(""), strs
+	case 'D':
+		for i, v := range column {
+			if v != nil {
+				strs[i] = strings.Replace(v.(string), "-", "", -1)
+			}
+		}
+		return Field{Fieldtype: 'D', Size: 8}, strs
+	case 'N', 'F':
+		precision := 0
+		floats := make([]float64, len(column))
+		for i, v := range column {
+			if v == nil {
+				continue
+			}
+			floats[i], _ = strconv.ParseFloat(string(v.(json.Number)), 64)
+			s := strconv.FormatFloat(floats[i], 'f', -1, 64)
+			if dot := strings.IndexByte(s, '.'); dot >= 0 && len(s)-dot-1 > precision {
+				precision = len(s) - dot - 1
+			}
+		}
+		if precision > 15 {
+			precision = 15
+		}
+		size := 1
+		for i, v := range column {
+			if v != nil {
+				strs[i] = strconv.FormatFloat(floats[i], 'f', precision, 64)
+				if len(strs[i]) > size {
+					size = len(strs[i])
+				}
+			}
+		}
+		if size > 254 {
+			size = 254
+		}
+		if kind == 'N' && precision == 0 {
+			return Field{Fieldtype: 'N', Size: uint8(size)}, strs
+		}
+		return Field{Fieldtype: 'F', Size: uint8(size), Precision: uint8(precision)}, strs
+	}
+
+	size := 1
+	for i, v := range column {
+		switch v := v.(type) {
+		case nil:
+			continue
+		case string:
+			strs[i] = v
+		case json.Number:
+			strs[i] = string(v)
+		default:
+			b, _ := json.Marshal(v)
+			strs[i] = string(b)
+		}
+		if len(strs[i]) > 254 {
+			strs[i] = strs[i][:254]
+		}
+		if len(strs[i]) > size {
+			size = len(strs[i])
+		}
+	}
+	return Field{Fieldtype: 'C', Size: uint8(size)}, strs
+}
@@ -0,0 +1,137 @@
+package shp
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFromGeoJSON(t *testing.T) {
+	d, err := FromGeoJSON(strings.NewReader(`{
+		"type": "FeatureCollection",
+		"features": [
+			{"type": "Feature", "geometry": {"type": "Point", "coordinates": [1, 2]},
+			 "properties": {"name": "Main", "lanes": 2, "speed": 50.5, "paved": true, "opened": "1999-12-31", "a_very_long_name": 1}},
+			{"type": "Feature", "geometry": null,
+			 "properties": {"name": null, "lanes": 4, "speed": 30, "paved": false, "opened": null, "a_very_long_other": "x"}},
+			{"type": "Feature", "geometry": {"type": "Point", "coordinates": [-3, 5]},
+			 "properties": {"name": "High", "extra": {"k": 1}}}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.GeometryType != POINT {
+		t.Errorf("got type %v, want POINT", d.GeometryType)
+	}
+	if want := (Box{-3, 2, 1, 5}); d.BBox != want {
+		t.Errorf("got box %v, want %v", d.BBox, want)
+	}
+	wantShapes := []Shape{&Point{1, 2}, &Null{}, &Point{-3, 5}}
+	if !reflect.DeepEqual(d.Shapes, wantShapes) {
+		t.Errorf("got shapes %v", d.Shapes)
+	}
+	wantFields := []Field{
+		StringField("name", 4),
+		NumberField("lanes", 1),
+		FloatField("speed", 4, 1),
+		LogicalField("paved"),
+		DateField("opened"),
+		NumberField("a_very_lon", 1),
+		StringField("a_very_l_1", 1),
+		StringField("extra", 7),
+	}
+	if !reflect.DeepEqual(d.Fields, wantFields) {
+		t.Errorf("got fields %v, want %v", d.Fields, wantFields)
+	}
+	wantAttrs := [][]string{
+		{"Main", "2", "50.5", "T", "19991231", "1", "", ""},
+		{"", "4", "30.0", "F", "", "", "x", ""},
+		{"High", "", "", "", "", "", "", `{"k":1}`},
+	}
+	if !reflect.DeepEqual(d.Attributes, wantAttrs) {
+		t.Errorf("got attributes %q", d.Attributes)
+	}
+}
+
+func TestFromGeoJSONGeometries(t *testing.T) {
+	tests := []struct {
+		geojson string
+		want    ShapeType
+	}{
+		{`{"type": "MultiPoint", "coordinates": [[0, 0], [1, 1]]}`, MULTIPOINT},
+		{`{"type": "LineString", "coordinates": [[0, 0], [1, 1, 5]]}`, POLYLINEZ},
+		{`{"type": "Feature", "properties": null,
+		   "geometry": {"type": "MultiLineString", "coordinates": [[[0, 0], [1, 1]], [[2, 2], [3, 3]]]}}`, POLYLINE},
+		{`{"type": "MultiPolygon", "coordinates": [[[[0, 0], [1, 0], [1, 1], [0, 0]]], [[[5, 5], [6, 5], [6, 6], [5, 5]]]]}`, POLYGON},
+	}
+	for _, test := range tests {
+		d, err := FromGeoJSON(strings.NewReader(test.geojson))
+		if err != nil {
+			t.Errorf("%s: %v", test.geojson, err)
+			continue
+		}
+		if d.GeometryType != test.want {
+			t.Errorf("%s: got type %v, want %v", test.geojson, d.GeometryType, test.want)
+		}
+	}
+
+	// GeoJSON exteriors are counterclockwise, shapefile exteriors clockwise
+	d, err := FromGeoJSON(strings.NewReader(`{"type": "Polygon", "coordinates": [[[0, 0], [1, 0], [1, 1], [0, 1], [0, 0]]]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Point{{0, 0}, {0, 1}, {1, 1}, {1, 0}, {0, 0}}
+	if got := d.Shapes[0].(*Polygon).Points; !reflect.DeepEqual(got, want) {
+		t.Errorf("got ring %v, want %v", got, want)
+	}
+
+	for _, bad := range []string{
+		`{"type": "GeometryCollection", "geometries": []}`,
+		`{"type": "FeatureCollection", "features": [
+			{"type": "Feature", "geometry": {"type": "Point", "coordinates": [0, 0]}},
+			{"type": "Feature", "geometry": {"type": "LineString", "coordinates": [[0, 0], [1, 1]]}}]}`,
+		`{"type": "LineString", "coordinates": [[0, 0]]}`,
+		`not json`,
+	} {
+		if _, err := FromGeoJSON(strings.NewReader(bad)); err == nil {
+			t.Errorf("%s: expected an error", bad)
+		}
+	}
+}
+
+func TestFromGeoJSONRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	r, err := Open("test_files/polyline.shp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	err = NewGeoJSONEncoder(&buf).Encode(r)
+	r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := FromGeoJSON(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(dir, "roundtrip.shp")
+	if err := d.Save(filename); err != nil {
+		t.Fatal(err)
+	}
+	_, want := readAll(t, "test_files/polyline.shp")
+	typ, got := readAll(t, filename)
+	if typ != POLYLINE || !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v %v, want %v", typ, got, want)
+	}
+}
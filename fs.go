@@ -0,0 +1,59 @@
+package shp
+
+import (
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// OpenFS opens the shapefile called name in fsys for sequential reading, e.g.
+// one that is embedded with go:embed, held in memory or served by a virtual
+// file system. The companion files are paired with the .shp regardless of the
+// case of their names, and the .cpg is honoured like by Open. A zip.Reader is
+// an fs.FS as well, but OpenZip also reports the metadata of the archive.
+func OpenFS(fsys fs.FS, name string, opts ...Option) (SequentialReader, error) {
+	shp, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	companions := fsCompanions(fsys, name)
+	if cpg := companions[".cpg"]; cpg != "" {
+		if b, err := fs.ReadFile(fsys, cpg); err == nil {
+			opts = append(opts[:len(opts):len(opts)], withCPG(string(b)))
+		}
+	}
+	// dbf is optional, so no error checking here
+	var dbf io.ReadCloser
+	if companions[".dbf"] != "" {
+		if f, err := fsys.Open(companions[".dbf"]); err == nil {
+			dbf = f
+		}
+	}
+	sr := SequentialReaderFromExt(shp, dbf, opts...)
+	if err := sr.Err(); err != nil {
+		sr.Close()
+		return nil, err
+	}
+	return sr, nil
+}
+
+// fsCompanions returns the names of the files in fsys that belong to the
+// shapefile called name, keyed by their lower-case extension.
+func fsCompanions(fsys fs.FS, name string) map[string]string {
+	files := make(map[string]string)
+	dir := path.Dir(name)
+	base := strings.TrimSuffix(path.Base(name), path.Ext(name))
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return files
+	}
+	for _, e := range entries {
+		ext := path.Ext(e.Name())
+		if e.IsDir() || !strings.EqualFold(strings.TrimSuffix(e.Name(), ext), base) {
+			continue
+		}
+		files[strings.ToLower(ext)] = path.Join(dir, e.Name())
+	}
+	return files
+}
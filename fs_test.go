@@ -0,0 +1,42 @@
+package shp
+
+import (
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestOpenFS(t *testing.T) {
+	_, want := readAll(t, "test_files/polyline.shp")
+	fsys := fstest.MapFS{
+		"layers/roads.shp": {Data: readTestFile(t, "test_files/polyline.shp")},
+		"layers/ROADS.DBF": {Data: readTestFile(t, "test_files/polyline.dbf")},
+		"layers/roads.cpg": {Data: []byte("ISO-8859-1")},
+	}
+	sr, err := OpenFS(fsys, "layers/roads.shp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sr.Close()
+	var shapes []Shape
+	for sr.Next() {
+		_, s := sr.Shape()
+		shapes = append(shapes, s)
+	}
+	if err := sr.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(shapes, want) {
+		t.Errorf("got %v, want %v", shapes, want)
+	}
+	if len(sr.Fields()) == 0 {
+		t.Error("the DBF was not found")
+	}
+	if sr.(*seqReader).charset == nil {
+		t.Error("the .cpg was not honoured")
+	}
+
+	if _, err := OpenFS(fsys, "layers/missing.shp"); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
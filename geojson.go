@@ -0,0 +1,235 @@
+package shp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// geoJSONGeometry is the GeoJSON representation of a geometry.
+type geoJSONGeometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+// geoJSONPosition returns the GeoJSON position of the i-th point, including
+// the Z value if there is one.
+func geoJSONPosition(points []Point, z []float64, i int) []float64 {
+	if i < len(z) {
+		return []float64{points[i].X, points[i].Y, z[i]}
+	}
+	return []float64{points[i].X, points[i].Y}
+}
+
+// partRanges returns the [start, end) index of every part.
+func partRanges(parts []int32, numPoints int) [][2]int {
+	r := make([][2]int, len(parts))
+	for i := range parts {
+		end := numPoints
+		if i+1 < len(parts) {
+			end = int(parts[i+1])
+		}
+		r[i] = [2]int{int(parts[i]), end}
+	}
+	return r
+}
+
+// geoJSONParts returns the positions of every part.
+func geoJSONParts(parts []int32, points []Point, z []float64) [][][]float64 {
+	var r [][][]float64
+	for _, pr := range partRanges(parts, len(points)) {
+		var line [][]float64
+		for i := pr[0]; i < pr[1]; i++ {
+			line = append(line, geoJSONPosition(points, z, i))
+		}
+		r = append(r, line)
+	}
+	return r
+}
+
+// geoJSONPolygons groups the rings of a shapefile polygon into GeoJSON
+// polygons. Clockwise rings start a new polygon and counterclockwise rings are
+// holes of the preceding polygon. The rings are reversed because GeoJSON
+// expects counterclockwise exterior rings.
+func geoJSONPolygons(parts []int32, points []Point, z []float64) [][][][]float64 {
+	var polygons [][][][]float64
+	for _, ring := range geoJSONParts(parts, points, z) {
+		hole := signedArea(ring) > 0
+		for i, j := 0, len(ring)-1; i < j; i, j = i+1, j-1 {
+			ring[i], ring[j] = ring[j], ring[i]
+		}
+		if hole && len(polygons) > 0 {
+			polygons[len(polygons)-1] = append(polygons[len(polygons)-1], ring)
+		} else {
+			polygons = append(polygons, [][][]float64{ring})
+		}
+	}
+	return polygons
+}
+
+// signedArea returns the signed area of the ring, which is positive if the
+// ring is counterclockwise.
+func signedArea(ring [][]float64) float64 {
+	var a float64
+	for i := 0; i+1 < len(ring); i++ {
+		a += ring[i][0]*ring[i+1][1] - ring[i+1][0]*ring[i][1]
+	}
+	return a / 2
+}
+
+// geoJSONGeometryOf returns the GeoJSON geometry of s. It returns nil for Null
+// shapes.
+func geoJSONGeometryOf(s Shape) (*geoJSONGeometry, error) {
+	lines := func(parts []int32, points []Point, z []float64) *geoJSONGeometry {
+		ls := geoJSONParts(parts, points, z)
+		if len(ls) == 1 {
+			return &geoJSONGeometry{"LineString", ls[0]}
+		}
+		return &geoJSONGeometry{"MultiLineString", ls}
+	}
+	polygons := func(parts []int32, points []Point, z []float64) *geoJSONGeometry {
+		ps := geoJSONPolygons(parts, points, z)
+		if len(ps) == 1 {
+			return &geoJSONGeometry{"Polygon", ps[0]}
+		}
+		return &geoJSONGeometry{"MultiPolygon", ps}
+	}
+	multiPoint := func(points []Point, z []float64) *geoJSONGeometry {
+		ps := make([][]float64, len(points))
+		for i := range points {
+			ps[i] = geoJSONPosition(points, z, i)
+		}
+		return &geoJSONGeometry{"MultiPoint", ps}
+	}
+
+	switch s := s.(type) {
+	case nil, *Null:
+		return nil, nil
+	case *Point:
+		return &geoJSONGeometry{"Point", []float64{s.X, s.Y}}, nil
+	case *PointZ:
+		return &geoJSONGeometry{"Point", []float64{s.X, s.Y, s.Z}}, nil
+	case *PointM:
+		return &geoJSONGeometry{"Point", []float64{s.X, s.Y}}, nil
+	case *MultiPoint:
+		return multiPoint(s.Points, nil), nil
+	case *MultiPointZ:
+		return multiPoint(s.Points, s.ZArray), nil
+	case *MultiPointM:
+		return multiPoint(s.Points, nil), nil
+	case *PolyLine:
+		return lines(s.Parts, s.Points, nil), nil
+	case *PolyLineZ:
+		return lines(s.Parts, s.Points, s.ZArray), nil
+	case *PolyLineM:
+		return lines(s.Parts, s.Points, nil), nil
+	case *Polygon:
+		return polygons(s.Parts, s.Points, nil), nil
+	case *PolygonZ:
+		return polygons(s.Parts, s.Points, s.ZArray), nil
+	case *PolygonM:
+		return polygons(s.Parts, s.Points, nil), nil
+	default:
+		return nil, fmt.Errorf("Unsupported shape for GeoJSON: %T", s)
+	}
+}
+
+// geoJSONFeature is the GeoJSON representation of a Feature.
+type geoJSONFeature struct {
+	Type       string                 `json:"type"`
+	ID         *int                   `json:"id,omitempty"`
+	Geometry   *geoJSONGeometry       `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// MarshalJSON encodes the feature as a GeoJSON Feature object. Attributes of
+// numeric and logical fields are encoded as JSON numbers and booleans, NULL
+// attributes as JSON null.
+func (f Feature) MarshalJSON() ([]byte, error) {
+	index := f.Index
+	return marshalFeature(&index, f.Shape, f.Attrs)
+}
+
+func marshalFeature(id *int, s Shape, attrs []Attr) ([]byte, error) {
+	g, err := geoJSONGeometryOf(s)
+	if err != nil {
+		return nil, err
+	}
+	props := make(map[string]interface{}, len(attrs))
+	for _, a := range attrs {
+		props[a.Field.String()] = a.jsonValue()
+	}
+	return json.Marshal(geoJSONFeature{
+		Type:       "Feature",
+		ID:         id,
+		Geometry:   g,
+		Properties: props,
+	})
+}
+
+// ToGeoJSON encodes shape as a GeoJSON Feature object whose properties are
+// the attributes attrs of the fields. The feature has no id.
+func ToGeoJSON(shape Shape, fields []Field, attrs []string) ([]byte, error) {
+	if len(attrs) != len(fields) {
+		return nil, fmt.Errorf("Got %d attributes for %d fields", len(attrs), len(fields))
+	}
+	as := make([]Attr, len(fields))
+	for i, field := range fields {
+		as[i] = Attr{Field: field, Value: attrs[i], Null: isNullValue(field, attrs[i])}
+	}
+	return marshalFeature(nil, shape, as)
+}
+
+// GeoJSONEncoder writes the features of shapefiles as GeoJSON
+// FeatureCollections to an output stream.
+type GeoJSONEncoder struct {
+	w      *bufio.Writer
+	swapXY bool
+}
+
+// NewGeoJSONEncoder returns a GeoJSONEncoder that writes to w.
+func NewGeoJSONEncoder(w io.Writer) *GeoJSONEncoder {
+	return &GeoJSONEncoder{w: bufio.NewWriter(w)}
+}
+
+// SetSwapXY makes the encoder swap the X and Y coordinate of every shape
+// before it is written. GeoJSON requires longitudes before latitudes, so this
+// is for shapefiles that hold latitudes in X. Like with WithSwapXY, the swap
+// happens before the transformation set on the reader by SetTransform.
+func (e *GeoJSONEncoder) SetSwapXY(swap bool) {
+	e.swapXY = swap
+}
+
+// Encode reads all features from sr and writes them as a FeatureCollection,
+// followed by a newline. Features are written as they are read, so the
+// collection is never held in memory.
+func (e *GeoJSONEncoder) Encode(sr SequentialReader) error {
+	prepare := func(s Shape) Shape { return s }
+	if e.swapXY {
+		var restore func()
+		prepare, restore = swapThenTransform(sr)
+		defer restore()
+	}
+	io.WriteString(e.w, `{"type":"FeatureCollection","features":[`)
+	for i := 0; ; i++ {
+		f, err := readFeature(sr)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		f.Shape = prepare(f.Shape)
+		b, err := json.Marshal(f)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			e.w.WriteByte(',')
+		}
+		e.w.Write(b)
+	}
+	io.WriteString(e.w, "]}\n")
+	return e.w.Flush()
+}
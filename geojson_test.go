@@ -0,0 +1,69 @@
+package shp
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestToGeoJSON(t *testing.T) {
+	fields := []Field{StringField("NAME", 10), NumberField("POP", 5)}
+	b, err := ToGeoJSON(&Point{1, 2}, fields, []string{"town", ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"type": "Feature",
+		"geometry": map[string]interface{}{
+			"type":        "Point",
+			"coordinates": []interface{}{1.0, 2.0},
+		},
+		"properties": map[string]interface{}{
+			"NAME": "town",
+			"POP":  nil,
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %s", b)
+	}
+	if _, err := ToGeoJSON(&Point{}, fields, []string{"town"}); err == nil {
+		t.Error("expected an error for a missing attribute")
+	}
+}
+
+func TestGeoJSONEncoder(t *testing.T) {
+	r, err := Open("test_files/polyline.shp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	var buf bytes.Buffer
+	if err := NewGeoJSONEncoder(&buf).Encode(r); err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Type     string
+		Features []struct {
+			ID       int
+			Geometry struct {
+				Type string
+			}
+		}
+	}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("%v: %s", err, buf.Bytes())
+	}
+	if got.Type != "FeatureCollection" || len(got.Features) != 2 {
+		t.Fatalf("got %s", buf.Bytes())
+	}
+	for i, f := range got.Features {
+		if f.ID != i || f.Geometry.Type != "LineString" {
+			t.Errorf("feature %d: got id %d and geometry %s", i, f.ID, f.Geometry.Type)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+// Package geomconv converts shapes to and from the geometry types of the
+// go-geom and orb packages, so that spatial operations of those packages can
+// be used on shapefiles.
+//
+// Shapes are mapped to geometries like in shp.MarshalWKB: polygons with
+// several outer rings become MultiPolygons, Z values and measures are kept
+// where the target type supports them and Null shapes become empty
+// GeometryCollections.
+package geomconv
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/paulmach/orb"
+	shp "github.com/silbinarywolf/go-shp"
+	"github.com/twpayne/go-geom"
+	"github.com/twpayne/go-geom/encoding/wkb"
+)
+
+// ToGeom converts s into a go-geom geometry.
+func ToGeom(s shp.Shape) (geom.T, error) {
+	b, err := shp.MarshalWKB(s)
+	if err != nil {
+		return nil, err
+	}
+	return wkb.Unmarshal(b)
+}
+
+// FromGeom converts g into a shape. GeometryCollections are only supported if
+// they are empty.
+func FromGeom(g geom.T) (shp.Shape, error) {
+	b, err := wkb.Marshal(g, binary.LittleEndian)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to convert %T: %v", g, err)
+	}
+	return shp.ShapeFromWKB(b)
+}
+
+// ToOrb converts s into an orb geometry. Since orb is two-dimensional, Z
+// values and measures are dropped.
+func ToOrb(s shp.Shape) (orb.Geometry, error) {
+	g, err := ToGeom(s)
+	if err != nil {
+		return nil, err
+	}
+	flat, stride := g.FlatCoords(), g.Stride()
+	switch g := g.(type) {
+	case *geom.Point:
+		return toOrbPoints(flat, stride)[0], nil
+	case *geom.LineString:
+		return orb.LineString(toOrbPoints(flat, stride)), nil
+	case *geom.Polygon:
+		return toOrbPolygon(flat, stride, 0, g.Ends()), nil
+	case *geom.MultiPoint:
+		return orb.MultiPoint(toOrbPoints(flat, stride)), nil
+	case *geom.MultiLineString:
+		mls := orb.MultiLineString{}
+		start := 0
+		for _, end := range g.Ends() {
+			mls = append(mls, toOrbPoints(flat[start:end], stride))
+			start = end
+		}
+		return mls, nil
+	case *geom.MultiPolygon:
+		mp := orb.MultiPolygon{}
+		start := 0
+		for _, ends := range g.Endss() {
+			mp = append(mp, toOrbPolygon(flat, stride, start, ends))
+			if len(ends) > 0 {
+				start = ends[len(ends)-1]
+			}
+		}
+		return mp, nil
+	case *geom.GeometryCollection:
+		return orb.Collection{}, nil
+	}
+	return nil, fmt.Errorf("Unable to convert %T to orb", g)
+}
+
+// toOrbPoints returns the points of the flat coordinates with the given
+// stride.
+func toOrbPoints(flat []float64, stride int) []orb.Point {
+	points := make([]orb.Point, 0, len(flat)/stride)
+	for i := 0; i+1 < len(flat); i += stride {
+		points = append(points, orb.Point{flat[i], flat[i+1]})
+	}
+	return points
+}
+
+// toOrbPolygon returns the polygon whose rings start at start and end at the
+// offsets ends of the flat coordinates.
+func toOrbPolygon(flat []float64, stride, start int, ends []int) orb.Polygon {
+	p := orb.Polygon{}
+	for _, end := range ends {
+		p = append(p, orb.Ring(toOrbPoints(flat[start:end], stride)))
+		start = end
+	}
+	return p
+}
+
+// FromOrb converts g into a shape. A Ring is converted like a Polygon with a
+// single ring and a Bound like the Polygon of its corners. Collections are
+// only supported if they are empty.
+func FromOrb(g orb.Geometry) (shp.Shape, error) {
+	var t geom.T
+	switch g := g.(type) {
+	case orb.Point:
+		t = geom.NewPointFlat(geom.XY, fromOrbPoints([]orb.Point{g}))
+	case orb.MultiPoint:
+		t = geom.NewMultiPointFlat(geom.XY, fromOrbPoints(g))
+	case orb.LineString:
+		t = geom.NewLineStringFlat(geom.XY, fromOrbPoints(g))
+	case orb.MultiLineString:
+		mls := geom.NewMultiLineString(geom.XY)
+		for _, ls := range g {
+			mls.Push(geom.NewLineStringFlat(geom.XY, fromOrbPoints(ls)))
+		}
+		t = mls
+	case orb.Ring:
+		t = fromOrbPolygon(orb.Polygon{g})
+	case orb.Polygon:
+		t = fromOrbPolygon(g)
+	case orb.Bound:
+		t = fromOrbPolygon(g.ToPolygon())
+	case orb.MultiPolygon:
+		mp := geom.NewMultiPolygon(geom.XY)
+		for _, p := range g {
+			mp.Push(fromOrbPolygon(p))
+		}
+		t = mp
+	case orb.Collection:
+		if len(g) > 0 {
+			return nil, fmt.Errorf("Unable to convert a Collection with %d geometries", len(g))
+		}
+		t = geom.NewGeometryCollection()
+	default:
+		return nil, fmt.Errorf("Unable to convert %T", g)
+	}
+	return FromGeom(t)
+}
+
+func fromOrbPoints(points []orb.Point) []float64 {
+	flat := make([]float64, 0, 2*len(points))
+	for _, p := range points {
+		flat = append(flat, p[0], p[1])
+	}
+	return flat
+}
+
+func fromOrbPolygon(p orb.Polygon) *geom.Polygon {
+	var flat []float64
+	var ends []int
+	for _, r := range p {
+		flat = append(flat, fromOrbPoints(r)...)
+		ends = append(ends, len(flat))
+	}
+	return geom.NewPolygonFlat(geom.XY, flat, ends)
+}
@@ -0,0 +1,92 @@
+package geomconv
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/paulmach/orb"
+	shp "github.com/silbinarywolf/go-shp"
+	"github.com/twpayne/go-geom"
+)
+
+func square(x, y, size float64) []shp.Point {
+	return []shp.Point{{X: x, Y: y}, {X: x, Y: y + size}, {X: x + size, Y: y + size}, {X: x + size, Y: y}, {X: x, Y: y}}
+}
+
+func TestGeom(t *testing.T) {
+	polygon, err := shp.NewPolygon([][]shp.Point{square(0, 0, 10), square(20, 20, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := ToGeom(polygon)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mp, ok := g.(*geom.MultiPolygon); !ok || mp.NumPolygons() != 2 {
+		t.Fatalf("got %#v, want a MultiPolygon with two polygons", g)
+	}
+	s, err := FromGeom(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(s, polygon) {
+		t.Errorf("got %#v, want %#v", s, polygon)
+	}
+
+	point := &shp.PointZ{X: 1, Y: 2, Z: 3, M: 4}
+	g, err = ToGeom(point)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Layout() != geom.XYZM {
+		t.Errorf("got layout %v, want XYZM", g.Layout())
+	}
+	if s, err := FromGeom(g); err != nil || !reflect.DeepEqual(s, point) {
+		t.Errorf("got %#v, %v, want %#v", s, err, point)
+	}
+
+	if _, err := FromGeom(geom.NewLinearRing(geom.XY)); err == nil {
+		t.Error("expected an error for a LinearRing")
+	}
+}
+
+func TestOrb(t *testing.T) {
+	line := shp.NewPolyLine([][]shp.Point{{{X: 0, Y: 0}, {X: 1, Y: 1}}, {{X: 2, Y: 2}, {X: 3, Y: 3}}})
+	g, err := ToOrb(line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := orb.MultiLineString{{{0, 0}, {1, 1}}, {{2, 2}, {3, 3}}}
+	if !reflect.DeepEqual(g, want) {
+		t.Errorf("got %#v, want %#v", g, want)
+	}
+	s, err := FromOrb(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(s, line) {
+		t.Errorf("got %#v, want %#v", s, line)
+	}
+
+	s, err = FromOrb(orb.Bound{Min: orb.Point{0, 0}, Max: orb.Point{2, 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p, ok := s.(*shp.Polygon); !ok || p.NumParts != 1 || p.BBox() != (shp.Box{MinX: 0, MinY: 0, MaxX: 2, MaxY: 1}) {
+		t.Errorf("got %#v for a Bound", s)
+	}
+
+	g, err = ToOrb(&shp.PointM{X: 1, Y: 2, M: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g != (orb.Point{1, 2}) {
+		t.Errorf("got %#v, want the point without measure", g)
+	}
+	if s, err := FromOrb(orb.Collection{}); err != nil || s.BBox() != (shp.Box{}) {
+		t.Errorf("got %#v, %v for an empty Collection", s, err)
+	}
+	if _, err := FromOrb(orb.Collection{orb.Point{}}); err == nil {
+		t.Error("expected an error for a Collection")
+	}
+}
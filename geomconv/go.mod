@@ -0,0 +1,11 @@
+module github.com/silbinarywolf/go-shp/geomconv
+
+go 1.16
+
+require (
+	github.com/paulmach/orb v0.2.2
+	github.com/silbinarywolf/go-shp v0.0.0-00010101000000-000000000000
+	github.com/twpayne/go-geom v1.4.1
+)
+
+replace github.com/silbinarywolf/go-shp => ../
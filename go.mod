@@ -1,3 +1,5 @@
 module github.com/silbinarywolf/go-shp
 
 go 1.16
+
+require golang.org/x/text v0.3.6
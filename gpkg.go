@@ -0,0 +1,331 @@
+package shp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// gpkgGeometryColumn is the name of the geometry column of the feature
+// tables written by WriteGeoPackage.
+const gpkgGeometryColumn = "geom"
+
+// These are the statements that create the tables of a GeoPackage that are
+// written by WriteGeoPackage, as given by the specification.
+const (
+	gpkgSpatialRefSys = `CREATE TABLE gpkg_spatial_ref_sys (srs_name TEXT NOT NULL, srs_id INTEGER NOT NULL PRIMARY KEY, ` +
+		`organization TEXT NOT NULL, organization_coordsys_id INTEGER NOT NULL, definition TEXT NOT NULL, description TEXT)`
+	gpkgContents = `CREATE TABLE gpkg_contents (table_name TEXT NOT NULL PRIMARY KEY, data_type TEXT NOT NULL, ` +
+		`identifier TEXT UNIQUE, description TEXT DEFAULT '', ` +
+		`last_change DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ','now')), ` +
+		`min_x DOUBLE, min_y DOUBLE, max_x DOUBLE, max_y DOUBLE, srs_id INTEGER, ` +
+		`CONSTRAINT fk_gc_r_srs_id FOREIGN KEY (srs_id) REFERENCES gpkg_spatial_ref_sys(srs_id))`
+	gpkgGeometryColumns = `CREATE TABLE gpkg_geometry_columns (table_name TEXT NOT NULL, column_name TEXT NOT NULL, ` +
+		`geometry_type_name TEXT NOT NULL, srs_id INTEGER NOT NULL, z TINYINT NOT NULL, m TINYINT NOT NULL, ` +
+		`CONSTRAINT pk_geom_cols PRIMARY KEY (table_name, column_name), ` +
+		`CONSTRAINT uk_gc_table_name UNIQUE (table_name), ` +
+		`CONSTRAINT fk_gc_tn FOREIGN KEY (table_name) REFERENCES gpkg_contents(table_name), ` +
+		`CONSTRAINT fk_gc_srs FOREIGN KEY (srs_id) REFERENCES gpkg_spatial_ref_sys (srs_id))`
+	sqliteSequence = `CREATE TABLE sqlite_sequence(name,seq)`
+)
+
+// WriteGeoPackage reads all records of r and writes them to a new GeoPackage
+// at path, which holds a single feature table called layerName. The table
+// has an integer primary key fid, which is the index of the record plus 1,
+// a geometry column geom and a column for every field. The spatial reference
+// system is taken from the .prj of readers that have a Projection method and
+// recognized if it is one of the EPSG codes known to EPSGToWKT.
+//
+// PolyLines and Polygons are written as MultiLineStrings and MultiPolygons.
+// The geometry column is typed after the shapes, or GEOMETRY if they are of
+// several types. Null shapes are written as NULL. MultiPatches are not
+// supported. The file is a minimal SQLite database without spatial index.
+func WriteGeoPackage(path string, layerName string, r SequentialReader) error {
+	if layerName == "" {
+		return fmt.Errorf("Invalid layer name for GeoPackage: %q", layerName)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = writeGeoPackage(f, layerName, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+	}
+	return err
+}
+
+func writeGeoPackage(f *os.File, layerName string, r SequentialReader) error {
+	sw := newSQLiteWriter(f)
+	sw.applicationID = 0x47504b47 // "GPKG"
+	sw.userVersion = 10200
+
+	srs := gpkgSRS(r)
+	fields := r.Fields()
+	features := &sqliteTable{sw: sw}
+	var box Box
+	boxed := false
+	t, mixed := NULL, false
+	var fid int64
+	values := make([]interface{}, len(fields)+2)
+	for r.Next() {
+		n, s := r.Shape()
+		if st := shapeTypeOf(s, NULL); st != NULL {
+			if t != NULL && st != t {
+				mixed = true
+			}
+			t = st
+		}
+		geom, err := gpkgGeometry(s, srs.id)
+		if err != nil {
+			return err
+		}
+		// the fid is an alias of the rowid, which is stored as NULL
+		values[0], values[1] = nil, nil
+		if geom != nil {
+			if b := s.BBox(); boxed {
+				box.Extend(b)
+			} else {
+				box, boxed = b, true
+			}
+			values[1] = geom
+		}
+		for i, field := range fields {
+			values[i+2] = nil
+			if !AttributeIsNull(r, i) {
+				values[i+2] = gpkgValue(field, r.Attribute(i))
+			}
+		}
+		fid = int64(n) + 1
+		features.add(fid, sqliteRecord(values...))
+	}
+	if err := r.Err(); err != nil {
+		return err
+	}
+	geometryType, z, m := "GEOMETRY", int64(0), int64(0)
+	if !mixed && t != NULL {
+		var ok bool
+		if geometryType, ok = gpkgGeometryTypes[t]; !ok {
+			return fmt.Errorf("Unsupported shape type for GeoPackage: %v", t)
+		}
+		switch t {
+		case POINTZ, MULTIPOINTZ, POLYLINEZ, POLYGONZ:
+			z, m = 1, 2
+		case POINTM, MULTIPOINTM, POLYLINEM, POLYGONM:
+			m = 1
+		}
+	}
+
+	var minX, minY, maxX, maxY interface{}
+	if boxed {
+		minX, minY, maxX, maxY = box.MinX, box.MinY, box.MaxX, box.MaxY
+	}
+	now := time.Now().UTC().Format("2006-01-02T15:04:05.000Z")
+	var schema [][]byte
+	addTable := func(name, sql string, rows ...[]interface{}) uint32 {
+		t := &sqliteTable{sw: sw}
+		for i, row := range rows {
+			t.add(int64(i+1), sqliteRecord(row...))
+		}
+		root := t.finish()
+		schema = append(schema, sqliteSchema("table", name, name, root, sql))
+		return root
+	}
+	addIndex := func(name, table string, records ...[]byte) error {
+		root, err := sw.writeIndex(records...)
+		schema = append(schema, sqliteSchema("index", name, table, root, nil))
+		return err
+	}
+
+	srsRows := [][]interface{}{
+		{"Undefined cartesian SRS", int64(-1), "NONE", int64(-1), "undefined", "undefined cartesian coordinate reference system"},
+		{"Undefined geographic SRS", int64(0), "NONE", int64(0), "undefined", "undefined geographic coordinate reference system"},
+		{"WGS 84 geodetic", int64(4326), "EPSG", int64(4326), wktGCSWGS84, "longitude/latitude coordinates in decimal degrees on the WGS 84 spheroid"},
+	}
+	if srs.id > 0 && srs.id != 4326 {
+		srsRows = append(srsRows, []interface{}{srs.name, srs.id, srs.organization, srs.id, srs.definition, nil})
+	}
+	// rows are sorted by rowid, which is the srs_id
+	for i := len(srsRows) - 1; i > 0 && srsRows[i][1].(int64) < srsRows[i-1][1].(int64); i-- {
+		srsRows[i], srsRows[i-1] = srsRows[i-1], srsRows[i]
+	}
+	srsTable := &sqliteTable{sw: sw}
+	for _, row := range srsRows {
+		srsTable.add(row[1].(int64), sqliteRecord(append([]interface{}{row[0], nil}, row[2:]...)...))
+	}
+	schema = append(schema, sqliteSchema("table", "gpkg_spatial_ref_sys", "gpkg_spatial_ref_sys", srsTable.finish(), gpkgSpatialRefSys))
+
+	addTable("gpkg_contents", gpkgContents,
+		[]interface{}{layerName, "features", layerName, "", now, minX, minY, maxX, maxY, srs.id})
+	if err := addIndex("sqlite_autoindex_gpkg_contents_1", "gpkg_contents", sqliteRecord(layerName, int64(1))); err != nil {
+		return err
+	}
+	if err := addIndex("sqlite_autoindex_gpkg_contents_2", "gpkg_contents", sqliteRecord(layerName, int64(1))); err != nil {
+		return err
+	}
+	addTable("gpkg_geometry_columns", gpkgGeometryColumns,
+		[]interface{}{layerName, gpkgGeometryColumn, geometryType, srs.id, z, m})
+	if err := addIndex("sqlite_autoindex_gpkg_geometry_columns_1", "gpkg_geometry_columns",
+		sqliteRecord(layerName, gpkgGeometryColumn, int64(1))); err != nil {
+		return err
+	}
+	if err := addIndex("sqlite_autoindex_gpkg_geometry_columns_2", "gpkg_geometry_columns", sqliteRecord(layerName, int64(1))); err != nil {
+		return err
+	}
+
+	columns := []string{"fid INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL", quoteSQLIdent(gpkgGeometryColumn) + " " + geometryType}
+	for _, field := range fields {
+		columns = append(columns, quoteSQLIdent(field.String())+" "+gpkgColumnType(field))
+	}
+	schema = append(schema, sqliteSchema("table", layerName, layerName, features.finish(),
+		fmt.Sprintf("CREATE TABLE %s (%s)", quoteSQLIdent(layerName), strings.Join(columns, ", "))))
+	var sequence [][]interface{}
+	if fid > 0 {
+		sequence = append(sequence, []interface{}{layerName, fid})
+	}
+	addTable("sqlite_sequence", sqliteSequence, sequence...)
+	return sw.finish(schema)
+}
+
+// gpkgGeometryTypes maps shape types to the geometry types of GeoPackages.
+var gpkgGeometryTypes = map[ShapeType]string{
+	POINT:       "POINT",
+	POINTZ:      "POINT",
+	POINTM:      "POINT",
+	MULTIPOINT:  "MULTIPOINT",
+	MULTIPOINTZ: "MULTIPOINT",
+	MULTIPOINTM: "MULTIPOINT",
+	POLYLINE:    "MULTILINESTRING",
+	POLYLINEZ:   "MULTILINESTRING",
+	POLYLINEM:   "MULTILINESTRING",
+	POLYGON:     "MULTIPOLYGON",
+	POLYGONZ:    "MULTIPOLYGON",
+	POLYGONM:    "MULTIPOLYGON",
+}
+
+// gpkgSpatialRef is a row of gpkg_spatial_ref_sys.
+type gpkgSpatialRef struct {
+	id                             int64
+	name, organization, definition string
+}
+
+// gpkgSRS returns the spatial reference system of the shapefile read by r.
+// It is the undefined cartesian system if there is no .prj, and a system
+// with an id above the EPSG codes if the .prj is not a known EPSG code.
+func gpkgSRS(r SequentialReader) gpkgSpatialRef {
+	undefined := gpkgSpatialRef{id: -1}
+	p, ok := r.(interface{ Projection() string })
+	if !ok {
+		return undefined
+	}
+	wkt := strings.TrimSpace(strings.TrimPrefix(p.Projection(), "\ufeff"))
+	if wkt == "" {
+		return undefined
+	}
+	srs := gpkgSpatialRef{id: 100000, name: "Unknown", organization: "NONE", definition: wkt}
+	if crs, err := ParseCRS(wkt); err == nil && crs.Name != "" {
+		srs.name = crs.Name
+	}
+	if code, ok := epsgOf(wkt); ok {
+		srs.id, srs.organization = int64(code), "EPSG"
+	}
+	return srs
+}
+
+// gpkgGeometry returns s in the geometry format of GeoPackages, which is a
+// header followed by the WKB of s, or nil for Null shapes. Lines and
+// polygons with a single part are written as multi-geometries.
+func gpkgGeometry(s Shape, srsID int64) ([]byte, error) {
+	if _, ok := s.(*Null); ok || s == nil {
+		return nil, nil
+	}
+	wkb, err := MarshalWKB(s)
+	if err != nil {
+		return nil, err
+	}
+	b := []byte{'G', 'P', 0, 1} // little-endian without envelope
+	switch s.(type) {
+	case *Point, *PointZ, *PointM:
+	default:
+		b[3] |= 1 << 1 // envelope of X and Y
+	}
+	var buf [8]byte
+	binary.LittleEndian.PutUint32(buf[:], uint32(int32(srsID)))
+	b = append(b, buf[:4]...)
+	if b[3]&(1<<1) != 0 {
+		box := s.BBox()
+		for _, v := range []float64{box.MinX, box.MaxX, box.MinY, box.MaxY} {
+			binary.LittleEndian.PutUint64(buf[:], math.Float64bits(v))
+			b = append(b, buf[:]...)
+		}
+	}
+	// WKB types of lines and polygons are 2 and 3 with an offset of 1000 for
+	// each of the dimensions, and those of their multi-geometries are 3 more
+	t := binary.LittleEndian.Uint32(wkb[1:5])
+	if base := t % 1000; base == wkbLineString || base == wkbPolygon {
+		binary.LittleEndian.PutUint32(buf[:], t+3)
+		b = append(b, 1)
+		b = append(b, buf[:4]...)
+		b = append(b, 1, 0, 0, 0)
+	}
+	return append(b, wkb...), nil
+}
+
+// gpkgColumnType returns the GeoPackage type of the column of a field.
+func gpkgColumnType(f Field) string {
+	switch f.Fieldtype {
+	case 'C':
+		return fmt.Sprintf("TEXT(%d)", f.Size)
+	case 'N':
+		if f.Precision == 0 {
+			return "INTEGER"
+		}
+		return "DOUBLE"
+	case 'F', 'O':
+		return "DOUBLE"
+	case 'I':
+		return "INTEGER"
+	case 'L':
+		return "BOOLEAN"
+	case 'D':
+		return "DATE"
+	}
+	return "TEXT"
+}
+
+// gpkgValue returns the attribute v of field f as the value of its column.
+// Numbers that cannot be parsed are kept as text.
+func gpkgValue(f Field, v string) interface{} {
+	switch f.Fieldtype {
+	case 'N', 'F', 'O', 'I':
+		s := strings.TrimSpace(v)
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil && gpkgColumnType(f) == "INTEGER" {
+			return i
+		}
+		if d, err := strconv.ParseFloat(s, 64); err == nil {
+			return d
+		}
+	case 'L':
+		if strings.ContainsAny(v, "TtYy") {
+			return int64(1)
+		}
+		return int64(0)
+	case 'D':
+		if len(v) == 8 {
+			return v[:4] + "-" + v[4:6] + "-" + v[6:]
+		}
+	}
+	return v
+}
+
+// quoteSQLIdent quotes name as an SQL identifier.
+func quoteSQLIdent(name string) string {
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
@@ -0,0 +1,209 @@
+package shp
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// readSQLiteVarint decodes a varint of SQLite.
+func readSQLiteVarint(b []byte) (uint64, int) {
+	var v uint64
+	for i := 0; i < 8; i++ {
+		v = v<<7 | uint64(b[i]&0x7f)
+		if b[i] < 0x80 {
+			return v, i + 1
+		}
+	}
+	return v<<8 | uint64(b[8]), 9
+}
+
+// readSQLiteTable returns the records of the table b-tree at page root of the
+// database db, decoded into nil, int64, float64, string and []byte values.
+func readSQLiteTable(t *testing.T, db []byte, root uint32) [][]interface{} {
+	page := db[int(root-1)*sqlitePageSize : int(root)*sqlitePageSize]
+	header := page
+	if root == 1 {
+		header = page[sqliteHeaderSize:]
+	}
+	n := int(binary.BigEndian.Uint16(header[3:]))
+	var rows [][]interface{}
+	switch header[0] {
+	case sqliteTableInterior:
+		for i := 0; i < n; i++ {
+			cell := page[binary.BigEndian.Uint16(header[12+2*i:]):]
+			rows = append(rows, readSQLiteTable(t, db, binary.BigEndian.Uint32(cell))...)
+		}
+		return append(rows, readSQLiteTable(t, db, binary.BigEndian.Uint32(header[8:]))...)
+	case sqliteTableLeaf:
+		for i := 0; i < n; i++ {
+			cell := page[binary.BigEndian.Uint16(header[8+2*i:]):]
+			size, k := readSQLiteVarint(cell)
+			cell = cell[k:]
+			_, k = readSQLiteVarint(cell)
+			cell = cell[k:]
+			payload := cell
+			if local := int(size); local > sqlitePageSize-35 {
+				// the local part has the size computed by sqliteWriter.payload
+				minLocal := (sqlitePageSize-12)*32/255 - 23
+				if local = minLocal + (int(size)-minLocal)%(sqlitePageSize-4); local > sqlitePageSize-35 {
+					local = minLocal
+				}
+				payload = append([]byte(nil), cell[:local]...)
+				for next := binary.BigEndian.Uint32(cell[local:]); next != 0; {
+					overflow := db[int(next-1)*sqlitePageSize : int(next)*sqlitePageSize]
+					payload = append(payload, overflow[4:]...)
+					next = binary.BigEndian.Uint32(overflow)
+				}
+			}
+			rows = append(rows, decodeSQLiteRecord(payload[:size]))
+		}
+		return rows
+	}
+	t.Fatalf("page %d has type %d", root, header[0])
+	return nil
+}
+
+func decodeSQLiteRecord(b []byte) []interface{} {
+	size, k := readSQLiteVarint(b)
+	types, body := b[k:size], b[size:]
+	var values []interface{}
+	for len(types) > 0 {
+		st, k := readSQLiteVarint(types)
+		types = types[k:]
+		switch {
+		case st == 0:
+			values = append(values, nil)
+		case st == 8 || st == 9:
+			values = append(values, int64(st-8))
+		case st == 7:
+			values = append(values, math.Float64frombits(binary.BigEndian.Uint64(body)))
+			body = body[8:]
+		case st <= 6:
+			n := []int{0, 1, 2, 3, 4, 6, 8}[st]
+			var v int64
+			for _, c := range body[:n] {
+				v = v<<8 | int64(c)
+			}
+			v = v << (64 - 8*uint(n)) >> (64 - 8*uint(n))
+			values = append(values, v)
+			body = body[n:]
+		case st%2 == 0:
+			n := int(st-12) / 2
+			values = append(values, append([]byte(nil), body[:n]...))
+			body = body[n:]
+		default:
+			n := int(st-13) / 2
+			values = append(values, string(body[:n]))
+			body = body[n:]
+		}
+	}
+	return values
+}
+
+func TestWriteGeoPackage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "roads.shp")
+	w, err := Create(filename, POLYLINE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SetProjectionEPSG(4326)
+	w.SetFields([]Field{StringField("NAME", 20), NumberField("LANES", 4), DateField("BUILT")})
+	// long lines need overflow pages and many records interior pages
+	var lines []*PolyLine
+	for i := 0; i < 200; i++ {
+		var points []Point
+		for j := 0; j <= i*3; j++ {
+			points = append(points, Point{float64(i), float64(j)})
+		}
+		points = append(points, Point{-1, -1})
+		lines = append(lines, NewPolyLine([][]Point{points}))
+		if i == 5 {
+			w.Write(&Null{})
+		} else {
+			w.Write(lines[i])
+		}
+		w.WriteAttribute(i, 0, "road")
+		w.WriteAttribute(i, 1, i)
+		w.WriteAttributeDate(i, 2, 2001, 2, 3)
+	}
+	w.Close()
+
+	r, err := Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	gpkg := filepath.Join(dir, "roads.gpkg")
+	if err := WriteGeoPackage(gpkg, "roads", r); err != nil {
+		t.Fatal(err)
+	}
+	db, err := ioutil.ReadFile(gpkg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(db)%sqlitePageSize != 0 || int(binary.BigEndian.Uint32(db[28:]))*sqlitePageSize != len(db) {
+		t.Errorf("size of %d bytes does not match %d pages", len(db), binary.BigEndian.Uint32(db[28:]))
+	}
+	if id := string(db[68:72]); id != "GPKG" {
+		t.Errorf("application id = %q, want GPKG", id)
+	}
+
+	roots := map[string]uint32{}
+	for _, row := range readSQLiteTable(t, db, 1) {
+		roots[row[1].(string)] = uint32(row[3].(int64))
+	}
+	for _, name := range []string{"gpkg_spatial_ref_sys", "gpkg_contents", "gpkg_geometry_columns", "roads", "sqlite_sequence",
+		"sqlite_autoindex_gpkg_contents_1", "sqlite_autoindex_gpkg_geometry_columns_2"} {
+		if roots[name] == 0 {
+			t.Errorf("schema has no %s", name)
+		}
+	}
+	columns := readSQLiteTable(t, db, roots["gpkg_geometry_columns"])
+	if want := []interface{}{"roads", "geom", "MULTILINESTRING", int64(4326), int64(0), int64(0)}; !reflect.DeepEqual(columns[0], want) {
+		t.Errorf("geometry column = %v, want %v", columns[0], want)
+	}
+	contents := readSQLiteTable(t, db, roots["gpkg_contents"])[0]
+	if box := contents[5:10]; !reflect.DeepEqual(box, []interface{}{-1.0, -1.0, 199.0, 597.0, int64(4326)}) {
+		t.Errorf("extent and srs_id = %v", box)
+	}
+
+	rows := readSQLiteTable(t, db, roots["roads"])
+	if len(rows) != 200 {
+		t.Fatalf("%d rows, want 200", len(rows))
+	}
+	for i, row := range rows {
+		if want := []interface{}{nil, "road", int64(i), "2001-02-03"}; !reflect.DeepEqual([]interface{}{row[0], row[2], row[3], row[4]}, want) {
+			t.Errorf("row %d: got %v, want %v", i, row, want)
+		}
+		if i == 5 {
+			if row[1] != nil {
+				t.Errorf("row 5: geometry of Null shape is not NULL")
+			}
+			continue
+		}
+		geom := row[1].([]byte)
+		if string(geom[:4]) != "GP\x00\x03" || binary.LittleEndian.Uint32(geom[4:]) != 4326 {
+			t.Fatalf("row %d: header % x", i, geom[:8])
+		}
+		s, err := ShapeFromWKB(geom[40:])
+		if err != nil {
+			t.Fatalf("row %d: %v", i, err)
+		}
+		if !Equal(s, lines[i]) {
+			t.Errorf("row %d: geometry differs", i)
+		}
+	}
+	if seq := readSQLiteTable(t, db, roots["sqlite_sequence"]); !reflect.DeepEqual(seq, [][]interface{}{{"roads", int64(200)}}) {
+		t.Errorf("sqlite_sequence = %v", seq)
+	}
+}
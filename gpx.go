@@ -0,0 +1,127 @@
+package shp
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// GPXOptions configures WriteGPX.
+type GPXOptions struct {
+	// NameField is the field whose attribute names every waypoint, track or
+	// route. They have no name if it is empty.
+	NameField string
+	// DescriptionField is the field whose attribute describes every
+	// waypoint, track or route. They have no description if it is empty.
+	DescriptionField string
+	// Routes writes the parts of PolyLines as routes instead of as the
+	// segments of a track.
+	Routes bool
+	// Creator is the creator of the GPX document. It is "go-shp" if it is
+	// empty.
+	Creator string
+}
+
+// WriteGPX writes the records of r to w as a GPX 1.1 document. Points and the
+// points of MultiPoints are written as waypoints, PolyLines as tracks with a
+// segment for every part, or as a route for every part if opts.Routes is set.
+// Z values are written as elevations and Null shapes are skipped. Other
+// shapes cannot be written. Coordinates are written as they are, so the
+// shapefile should be in longitudes and latitudes in WGS 84.
+func WriteGPX(w io.Writer, r SequentialReader, opts GPXOptions) error {
+	fields := r.Fields()
+	nameField, descField := -1, -1
+	if opts.NameField != "" {
+		if nameField = fieldIndex(fields, opts.NameField); nameField < 0 {
+			return fmt.Errorf("%w: %s", ErrNoSuchField, opts.NameField)
+		}
+	}
+	if opts.DescriptionField != "" {
+		if descField = fieldIndex(fields, opts.DescriptionField); descField < 0 {
+			return fmt.Errorf("%w: %s", ErrNoSuchField, opts.DescriptionField)
+		}
+	}
+	creator := opts.Creator
+	if creator == "" {
+		creator = "go-shp"
+	}
+
+	bw := bufio.NewWriter(w)
+	io.WriteString(bw, `<?xml version="1.0" encoding="UTF-8"?>`+"\n")
+	io.WriteString(bw, `<gpx version="1.1" creator="`)
+	xml.EscapeText(bw, []byte(creator))
+	io.WriteString(bw, `" xmlns="http://www.topografix.com/GPX/1/1">`)
+	for r.Next() {
+		_, s := r.Shape()
+		var name, desc string
+		if nameField >= 0 {
+			name = r.Attribute(nameField)
+		}
+		if descField >= 0 {
+			desc = r.Attribute(descField)
+		}
+		c := coordsOf(s)
+		switch s.(type) {
+		case nil, *Null:
+		case *Point, *PointZ, *PointM, *MultiPoint, *MultiPointZ, *MultiPointM:
+			for i := range c.points {
+				writeGPXPoint(bw, "wpt", c, i, name, desc)
+			}
+		case *PolyLine, *PolyLineZ, *PolyLineM:
+			if opts.Routes {
+				for _, pr := range partRanges(c.parts, len(c.points)) {
+					io.WriteString(bw, "<rte>")
+					writeGPXNames(bw, name, desc)
+					for i := pr[0]; i < pr[1]; i++ {
+						writeGPXPoint(bw, "rtept", c, i, "", "")
+					}
+					io.WriteString(bw, "</rte>")
+				}
+				break
+			}
+			io.WriteString(bw, "<trk>")
+			writeGPXNames(bw, name, desc)
+			for _, pr := range partRanges(c.parts, len(c.points)) {
+				io.WriteString(bw, "<trkseg>")
+				for i := pr[0]; i < pr[1]; i++ {
+					writeGPXPoint(bw, "trkpt", c, i, "", "")
+				}
+				io.WriteString(bw, "</trkseg>")
+			}
+			io.WriteString(bw, "</trk>")
+		default:
+			return fmt.Errorf("Unsupported shape for GPX: %T", s)
+		}
+	}
+	if err := r.Err(); err != nil {
+		return err
+	}
+	io.WriteString(bw, "</gpx>\n")
+	return bw.Flush()
+}
+
+// writeGPXPoint writes the i-th point of c as an element called name, with
+// its Z value as the elevation.
+func writeGPXPoint(w *bufio.Writer, element string, c shapeCoords, i int, name, desc string) {
+	p := c.points[i]
+	fmt.Fprintf(w, `<%s lat="%s" lon="%s">`, element,
+		strconv.FormatFloat(p.Y, 'f', -1, 64), strconv.FormatFloat(p.X, 'f', -1, 64))
+	if i < len(c.z) {
+		fmt.Fprintf(w, "<ele>%s</ele>", strconv.FormatFloat(c.z[i], 'f', -1, 64))
+	}
+	writeGPXNames(w, name, desc)
+	fmt.Fprintf(w, "</%s>", element)
+}
+
+// writeGPXNames writes the name and description elements if they are not
+// empty.
+func writeGPXNames(w *bufio.Writer, name, desc string) {
+	if name != "" {
+		writeXMLElement(w, "name", name)
+	}
+	if desc != "" {
+		writeXMLElement(w, "desc", desc)
+	}
+}
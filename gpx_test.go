@@ -0,0 +1,91 @@
+package shp
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteGPX(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	points := filepath.Join(dir, "points.shp")
+	w, err := Create(points, POINTZ)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SetFields([]Field{StringField("NAME", 20), StringField("NOTE", 20)})
+	w.Write(&PointZ{X: 8.5, Y: 47.25, Z: 410})
+	w.WriteAttribute(0, 0, "Hut")
+	w.WriteAttribute(0, 1, "a < b")
+	w.Close()
+
+	lines := filepath.Join(dir, "lines.shp")
+	w, err = Create(lines, POLYLINE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SetFields([]Field{StringField("NAME", 20)})
+	w.Write(NewPolyLine([][]Point{{{0, 0}, {1, 1}}, {{2, 2}, {3, 3}}}))
+	w.WriteAttribute(0, 0, "Trail")
+	w.Close()
+
+	const header = `<?xml version="1.0" encoding="UTF-8"?>` + "\n" + `<gpx version="1.1" creator="go-shp" xmlns="http://www.topografix.com/GPX/1/1">`
+	for _, test := range []struct {
+		name     string
+		filename string
+		opts     GPXOptions
+		want     string
+	}{
+		{"waypoints", points, GPXOptions{NameField: "NAME", DescriptionField: "NOTE"},
+			`<wpt lat="47.25" lon="8.5"><ele>410</ele><name>Hut</name><desc>a &lt; b</desc></wpt>`},
+		{"track", lines, GPXOptions{NameField: "NAME"},
+			`<trk><name>Trail</name><trkseg><trkpt lat="0" lon="0"></trkpt><trkpt lat="1" lon="1"></trkpt></trkseg>` +
+				`<trkseg><trkpt lat="2" lon="2"></trkpt><trkpt lat="3" lon="3"></trkpt></trkseg></trk>`},
+		{"routes", lines, GPXOptions{Routes: true},
+			`<rte><rtept lat="0" lon="0"></rtept><rtept lat="1" lon="1"></rtept></rte>` +
+				`<rte><rtept lat="2" lon="2"></rtept><rtept lat="3" lon="3"></rtept></rte>`},
+	} {
+		r, err := Open(test.filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err := WriteGPX(&buf, r, test.opts); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		r.Close()
+		if got, want := buf.String(), header+test.want+"</gpx>\n"; got != want {
+			t.Errorf("%s: got\n%s\nwant\n%s", test.name, got, want)
+		}
+	}
+}
+
+func TestWriteGPXPolygon(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "polygons.shp")
+	w, err := Create(filename, POLYGON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write((*Polygon)(NewPolyLine([][]Point{{{0, 0}, {0, 1}, {1, 1}, {0, 0}}})))
+	w.Close()
+
+	r, err := Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if err := WriteGPX(ioutil.Discard, r, GPXOptions{}); err == nil {
+		t.Errorf("WriteGPX of polygons succeeded")
+	}
+}
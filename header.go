@@ -0,0 +1,94 @@
+package shp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DatasetHeader holds the information from the headers of a shapefile and
+// its DBF, which can be read without reading any records, e.g. to triage many
+// files quickly.
+type DatasetHeader struct {
+	Header
+	// NumRecords is the number of records of the DBF, or -1 if there is no
+	// DBF.
+	NumRecords int
+	// Fields are the fields of the DBF, or nil if there is no DBF.
+	Fields []Field
+}
+
+// ReadHeader reads the headers of the shapefile at path and of its DBF, if
+// there is one. The DBF is found regardless of the case of its extension.
+// Like Reader.Header, the file length is the actual size of the .shp.
+func ReadHeader(path string) (DatasetHeader, error) {
+	shp, err := os.Open(path)
+	if err != nil {
+		return DatasetHeader{}, err
+	}
+	defer shp.Close()
+	base := strings.TrimSuffix(path, filepath.Ext(path))
+	var dbf io.Reader
+	if f, err := os.Open(base + ".dbf"); err == nil {
+		defer f.Close()
+		dbf = f
+	} else if files, derr := datasetFiles(base); derr == nil && files[".dbf"] != "" {
+		if f, err := os.Open(files[".dbf"]); err == nil {
+			defer f.Close()
+			dbf = f
+		}
+	}
+	h, err := ReadHeaderFrom(shp, dbf)
+	if err != nil {
+		return h, fmt.Errorf("Unable to read header of %s: %v", path, err)
+	}
+	if fi, err := shp.Stat(); err == nil {
+		h.FileLength = fi.Size()
+	}
+	return h, nil
+}
+
+// ReadHeaderFrom reads the headers of a shapefile from the start of the .shp
+// and of the DBF, which may be nil. Only the headers are read from the
+// readers, so the file length is the one declared in the header.
+func ReadHeaderFrom(shp, dbf io.Reader) (DatasetHeader, error) {
+	h := DatasetHeader{NumRecords: -1}
+	var b [100]byte
+	if _, err := io.ReadFull(shp, b[:]); err != nil {
+		return h, fmt.Errorf("Error reading SHP header: %v", err)
+	}
+	if code := binary.BigEndian.Uint32(b[:]); code != 9994 {
+		return h, fmt.Errorf("Invalid SHP file code %d", code)
+	}
+	f := func(offset int) float64 {
+		return math.Float64frombits(binary.LittleEndian.Uint64(b[offset:]))
+	}
+	h.FileLength = 2 * int64(binary.BigEndian.Uint32(b[24:]))
+	h.GeometryType = ShapeType(binary.LittleEndian.Uint32(b[32:]))
+	h.BBox = Box{f(36), f(44), f(52), f(60)}
+	h.ZRange = [2]float64{f(68), f(76)}
+	h.MRange = [2]float64{f(84), f(92)}
+	if dbf == nil {
+		return h, nil
+	}
+
+	var d [32]byte
+	if _, err := io.ReadFull(dbf, d[:]); err != nil {
+		return h, fmt.Errorf("Error reading DBF header: %v", err)
+	}
+	numRecords := int32(binary.LittleEndian.Uint32(d[4:]))
+	headerLength := int16(binary.LittleEndian.Uint16(d[8:]))
+	if numRecords < 0 || headerLength < 33 {
+		return h, fmt.Errorf("Invalid DBF header with %d records and a header length of %d", numRecords, headerLength)
+	}
+	fields := make([]Field, (headerLength-33)/32)
+	if err := binary.Read(dbf, binary.LittleEndian, fields); err != nil {
+		return h, fmt.Errorf("Error reading DBF fields: %v", err)
+	}
+	h.NumRecords, h.Fields = int(numRecords), fields
+	return h, nil
+}
@@ -0,0 +1,55 @@
+package shp
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadHeader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "header.shp")
+	w, err := Create(filename, POINTZ)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SetFields([]Field{StringField("NAME", 10), NumberField("ID", 4)})
+	w.Write(&PointZ{1, 2, 3, 4})
+	w.Write(&PointZ{5, 6, 7, 8})
+	w.Close()
+
+	h, err := ReadHeader(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.GeometryType != POINTZ || h.BBox != (Box{1, 2, 5, 6}) || h.ZRange != [2]float64{3, 7} || h.MRange != [2]float64{4, 8} {
+		t.Errorf("header = %+v", h)
+	}
+	if h.FileLength != 100+2*(8+36) {
+		t.Errorf("FileLength = %d", h.FileLength)
+	}
+	if h.NumRecords != 2 || len(h.Fields) != 2 || h.Fields[1].String() != "ID" {
+		t.Errorf("NumRecords = %d, Fields = %v", h.NumRecords, h.Fields)
+	}
+
+	// without a DBF
+	if err := os.Rename(filepath.Join(dir, "header.dbf"), filepath.Join(dir, "other.dbf")); err != nil {
+		t.Fatal(err)
+	}
+	if h, err := ReadHeader(filename); err != nil || h.NumRecords != -1 || h.Fields != nil {
+		t.Errorf("header without DBF = %+v, %v", h, err)
+	}
+
+	if _, err := ReadHeaderFrom(bytes.NewReader(make([]byte, 100)), nil); err == nil {
+		t.Error("ReadHeaderFrom of zeros succeeded")
+	}
+	if _, err := ReadHeader(filepath.Join(dir, "missing.shp")); err == nil {
+		t.Error("ReadHeader of a missing file succeeded")
+	}
+}
@@ -0,0 +1,228 @@
+package shp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// IndexedReader provides random access to the shapes of a shapefile through
+// its .shx index. Unlike a Reader, it does not have to read the records
+// before the one that is requested.
+//
+// An IndexedReader is safe for concurrent use by multiple goroutines, e.g. by
+// the handlers of a tile server that share one open shapefile. Records and
+// attribute rows are read with ReadAt rather than by seeking, so that the
+// reads do not interfere with each other.
+type IndexedReader struct {
+	GeometryType ShapeType
+
+	// mu serializes ReadAttribute if the DBF cannot be read at random
+	// offsets.
+	mu  sync.Mutex
+	r   *Reader
+	shp io.ReaderAt
+	// shx is the .shx file, or the index built by scanning the records if
+	// the file is missing and the reader was opened WithRebuiltIndex.
+	shx   io.ReaderAt
+	count int
+}
+
+// OpenIndexed opens the shapefile at filename and its .shx index for random
+// access. If the .shx is missing and the reader is opened WithRebuiltIndex,
+// the index is built in memory instead.
+func OpenIndexed(filename string, opts ...Option) (*IndexedReader, error) {
+	r, err := Open(filename, opts...)
+	if err != nil {
+		return nil, err
+	}
+	shp, ok := r.shp.(io.ReaderAt)
+	if !ok {
+		r.Close()
+		return nil, fmt.Errorf("Cannot read %s at random offsets", filename)
+	}
+	ir := &IndexedReader{
+		GeometryType: r.GeometryType,
+		r:            r,
+		shp:          shp,
+	}
+	var size int64
+	shx, err := os.Open(r.filename + ".shx")
+	switch {
+	case err == nil:
+		ir.shx = shx
+		fi, err := shx.Stat()
+		if err != nil {
+			ir.Close()
+			return nil, err
+		}
+		size = fi.Size()
+	case os.IsNotExist(err) && r.opts.rebuildIndex:
+		index, err := buildIndex(shp, r.filelength)
+		if err != nil {
+			r.Close()
+			return nil, err
+		}
+		ir.shx = bytes.NewReader(index)
+		size = int64(len(index))
+	default:
+		r.Close()
+		return nil, fmt.Errorf("Error opening shapefile index: %v", err)
+	}
+	if size > 100 {
+		ir.count = int((size - 100) / 8)
+	}
+	// the header of the DBF is read now so that ReadAttribute only reads
+	// rows; a shapefile without a DBF has no attributes
+	r.openDbf()
+	return ir, nil
+}
+
+// WithRebuiltIndex makes OpenIndexed build the index of a shapefile without
+// a .shx in memory by scanning the record headers of the .shp.
+func WithRebuiltIndex() Option {
+	return func(o *options) {
+		o.rebuildIndex = true
+	}
+}
+
+// buildIndex returns the content of the .shx for the .shp of filelength bytes
+// read by shp. The header is copied from the .shp, with the file length of
+// the index.
+func buildIndex(shp io.ReaderAt, filelength int64) ([]byte, error) {
+	header := make([]byte, 100)
+	if _, err := shp.ReadAt(header, 0); err != nil {
+		return nil, fmt.Errorf("Unable to rebuild index: %v", err)
+	}
+	var entries []byte
+	var record [8]byte
+	for offset := int64(100); offset+8 <= filelength; {
+		if _, err := shp.ReadAt(record[:], offset); err != nil {
+			return nil, fmt.Errorf("Unable to rebuild index: %v", err)
+		}
+		length := int32(binary.BigEndian.Uint32(record[4:8]))
+		if size := int64(length) * 2; size < 4 || offset+8+size > filelength {
+			return nil, fmt.Errorf("Unable to rebuild index: record %d at offset %d has an invalid content length of %d bytes",
+				len(entries)/8+1, offset, size)
+		}
+		var entry [8]byte
+		binary.BigEndian.PutUint32(entry[0:4], uint32(offset/2))
+		binary.BigEndian.PutUint32(entry[4:8], uint32(length))
+		entries = append(entries, entry[:]...)
+		offset += 8 + int64(length)*2
+	}
+	binary.BigEndian.PutUint32(header[24:28], uint32((100+len(entries))/2))
+	return append(header, entries...), nil
+}
+
+// RebuildSHX writes the .shx of the shapefile at filename by scanning the
+// record headers of the .shp. An existing .shx is replaced.
+func RebuildSHX(filename string) error {
+	base := strings.TrimSuffix(filename, filepath.Ext(filename))
+	shp, err := os.Open(base + ".shp")
+	if err != nil {
+		return err
+	}
+	defer shp.Close()
+	fi, err := shp.Stat()
+	if err != nil {
+		return err
+	}
+	index, err := buildIndex(shp, fi.Size())
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(base+".shx", index, 0644)
+}
+
+// Count returns the number of records in the index.
+func (ir *IndexedReader) Count() int {
+	return ir.count
+}
+
+// ShapeAt returns the shape of the n-th record, starting at 0. ShapeAt is
+// safe for concurrent use.
+func (ir *IndexedReader) ShapeAt(n int) (Shape, error) {
+	if n < 0 || n >= ir.count {
+		return nil, fmt.Errorf("Record %d out of range [0, %d)", n, ir.count)
+	}
+	var entry [8]byte
+	if _, err := ir.shx.ReadAt(entry[:], 100+8*int64(n)); err != nil {
+		return nil, fmt.Errorf("Error reading index of record %d: %v", n, err)
+	}
+	offset := int64(int32(binary.BigEndian.Uint32(entry[0:4]))) * 2
+	size := int64(int32(binary.BigEndian.Uint32(entry[4:8]))) * 2
+	// the content length in the record header is preferred, as some
+	// writers put a different one into the index
+	var header [8]byte
+	if _, err := ir.shp.ReadAt(header[:], offset); err == nil {
+		if h := int64(int32(binary.BigEndian.Uint32(header[4:8]))) * 2; h >= 4 && offset+8+h <= ir.r.filelength {
+			size = h
+		}
+	}
+	if offset < 100 || size < 4 || offset+8+size > ir.r.filelength {
+		return nil, fmt.Errorf("Index of record %d points outside of the shapefile", n)
+	}
+	if ir.r.opts.maxRecordSize > 0 && size > ir.r.opts.maxRecordSize {
+		return nil, fmt.Errorf("Record %d exceeds maximum record size of %d bytes", n, ir.r.opts.maxRecordSize)
+	}
+	content := make([]byte, size)
+	if _, err := ir.shp.ReadAt(content, offset+8); err != nil {
+		return nil, fmt.Errorf("Error while reading record %d: %v", n, err)
+	}
+	s, err := readShape(ShapeType(binary.LittleEndian.Uint32(content)), content[4:])
+	if err != nil {
+		return nil, fmt.Errorf("Error while reading record %d: %v", n, err)
+	}
+	if ir.r.opts.swapXY {
+		transformPoints(s, swapPoint)
+	}
+	return s, nil
+}
+
+// BBox returns the bounding box of the shapefile.
+func (ir *IndexedReader) BBox() Box {
+	return ir.r.BBox()
+}
+
+// Fields returns the fields of the DBF table.
+func (ir *IndexedReader) Fields() []Field {
+	return ir.r.Fields()
+}
+
+// ReadAttribute returns the value of field of the n-th record, starting at
+// 0, as a string. It returns the empty string for rows that are missing or
+// malformed. ReadAttribute is safe for concurrent use.
+func (ir *IndexedReader) ReadAttribute(n int, field int) string {
+	r := ir.r
+	dbf, ok := r.dbf.(io.ReaderAt)
+	if !ok {
+		ir.mu.Lock()
+		defer ir.mu.Unlock()
+		return r.ReadAttribute(n, field)
+	}
+	if n < 0 || n >= int(r.dbfNumRecords) {
+		return ""
+	}
+	row := make([]byte, r.dbfRecordLength)
+	offset := int64(r.dbfHeaderLength) + int64(n)*int64(r.dbfRecordLength)
+	if _, err := dbf.ReadAt(row, offset); err != nil || checkRow(row) != nil {
+		return ""
+	}
+	start := r.dbfOffsets[field]
+	return decodeAttr(r.charset, strings.Trim(string(row[start:start+int(r.dbfFields[field].Size)]), " "))
+}
+
+// Close closes the shapefile and its index.
+func (ir *IndexedReader) Close() error {
+	if c, ok := ir.shx.(io.Closer); ok {
+		c.Close()
+	}
+	return ir.r.Close()
+}
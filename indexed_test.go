@@ -0,0 +1,185 @@
+package shp
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestIndexedReader(t *testing.T) {
+	for _, name := range []string{"point", "polyline", "polygonz", "multipatch"} {
+		filename := "test_files/" + name + ".shp"
+		_, shapes := readAll(t, filename)
+		ir, err := OpenIndexed(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ir.Count() != len(shapes) {
+			t.Errorf("%s: got count %d, want %d", name, ir.Count(), len(shapes))
+		}
+		// backwards, so that no record is read in order
+		for n := ir.Count() - 1; n >= 0; n-- {
+			s, err := ir.ShapeAt(n)
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			if !reflect.DeepEqual(s, shapes[n]) {
+				t.Errorf("%s: record %d: got %v, want %v", name, n, s, shapes[n])
+			}
+		}
+		for _, n := range []int{-1, ir.Count()} {
+			if _, err := ir.ShapeAt(n); err == nil {
+				t.Errorf("%s: expected an error for record %d", name, n)
+			}
+		}
+		ir.Close()
+	}
+}
+
+func TestIndexedReaderLarge(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "large.shp")
+	w, err := Create(filename, POINT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SetFields([]Field{NumberField("ID", 6)})
+	const count = 5000
+	for i := 0; i < count; i++ {
+		w.Write(&Point{float64(i), float64(-i)})
+		w.WriteAttribute(i, 0, i)
+	}
+	w.Close()
+
+	ir, err := OpenIndexed(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ir.Close()
+	if ir.Count() != count {
+		t.Fatalf("got count %d, want %d", ir.Count(), count)
+	}
+	if got := ir.ReadAttribute(4321, 0); got != "4321" {
+		t.Errorf("got attribute %q, want 4321", got)
+	}
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for n := g; n < count; n += 97 {
+				s, err := ir.ShapeAt(n)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if p := s.(*Point); p.X != float64(n) || p.Y != float64(-n) {
+					t.Errorf("record %d: got %v", n, p)
+				}
+				if got, want := ir.ReadAttribute(n, 0), strconv.Itoa(n); got != want {
+					t.Errorf("record %d: got attribute %q, want %q", n, got, want)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+func BenchmarkIndexedReader(b *testing.B) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "bench.shp")
+	w, _ := Create(filename, POINT)
+	for i := 0; i < 100000; i++ {
+		w.Write(&Point{float64(i), 0})
+	}
+	w.Close()
+
+	b.Run("ShapeAt", func(b *testing.B) {
+		ir, _ := OpenIndexed(filename)
+		defer ir.Close()
+		for i := 0; i < b.N; i++ {
+			ir.ShapeAt(99999)
+		}
+	})
+	b.Run("Next", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			r, _ := Open(filename)
+			for r.Next() {
+				if n, _ := r.Shape(); n == 99999 {
+					break
+				}
+			}
+			r.Close()
+		}
+	})
+}
+
+func TestRebuildSHX(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"point", "polyline", "polygonz", "pointz_nom"} {
+		data, _ := ioutil.ReadFile("test_files/" + name + ".shp")
+		filename := filepath.Join(dir, name+".shp")
+		ioutil.WriteFile(filename, data, 0644)
+		if err := RebuildSHX(filename); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		got, _ := ioutil.ReadFile(filepath.Join(dir, name+".shx"))
+		want, _ := ioutil.ReadFile("test_files/" + name + ".shx")
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: rebuilt index differs from the original", name)
+		}
+	}
+
+	data, _ := ioutil.ReadFile("test_files/damaged_header.shp")
+	filename := filepath.Join(dir, "damaged.shp")
+	ioutil.WriteFile(filename, data, 0644)
+	if err := RebuildSHX(filename); err == nil {
+		t.Error("expected an error for a damaged record header")
+	}
+}
+
+func TestIndexedReaderRebuiltIndex(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	data, _ := ioutil.ReadFile("test_files/polyline.shp")
+	filename := filepath.Join(dir, "noshx.shp")
+	ioutil.WriteFile(filename, data, 0644)
+
+	if _, err := OpenIndexed(filename); err == nil {
+		t.Error("expected an error without .shx")
+	}
+	ir, err := OpenIndexed(filename, WithRebuiltIndex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ir.Close()
+	_, shapes := readAll(t, "test_files/polyline.shp")
+	if ir.Count() != len(shapes) {
+		t.Fatalf("got count %d, want %d", ir.Count(), len(shapes))
+	}
+	if s, err := ir.ShapeAt(len(shapes) - 1); err != nil || !reflect.DeepEqual(s, shapes[len(shapes)-1]) {
+		t.Errorf("got %v, %v, want %v", s, err, shapes[len(shapes)-1])
+	}
+	if _, err := os.Stat(filepath.Join(dir, "noshx.shx")); err == nil {
+		t.Error("a .shx was written")
+	}
+}
@@ -0,0 +1,201 @@
+package shp
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// KMLOptions configures WriteKML and WriteKMZ.
+type KMLOptions struct {
+	// Name is the name of the KML Document.
+	Name string
+	// NameField is the field whose attribute names every Placemark.
+	// Placemarks have no name if it is empty.
+	NameField string
+	// DescriptionField is the field whose attribute describes every
+	// Placemark. Placemarks have no description if it is empty.
+	DescriptionField string
+	// SwapXY swaps the X and Y coordinate of every shape before it is
+	// written, for shapefiles that hold latitudes in X. Like with
+	// WithSwapXY, the swap happens before the transformation set on the
+	// reader by SetTransform.
+	SwapXY bool
+}
+
+// WriteKML writes the records of r to w as a KML Document with a Placemark
+// for every record. The attributes of every record are added to its
+// Placemark as ExtendedData, leaving out NULL attributes. Coordinates are
+// written as they are, so the shapefile should be in longitudes and
+// latitudes in WGS 84, or in latitudes and longitudes with opts.SwapXY. Records are written as they are read, so the
+// document is never held in memory.
+func WriteKML(w io.Writer, r SequentialReader, opts KMLOptions) error {
+	fields := r.Fields()
+	nameField, descField := -1, -1
+	if opts.NameField != "" {
+		if nameField = fieldIndex(fields, opts.NameField); nameField < 0 {
+			return fmt.Errorf("%w: %s", ErrNoSuchField, opts.NameField)
+		}
+	}
+	if opts.DescriptionField != "" {
+		if descField = fieldIndex(fields, opts.DescriptionField); descField < 0 {
+			return fmt.Errorf("%w: %s", ErrNoSuchField, opts.DescriptionField)
+		}
+	}
+
+	prepare := func(s Shape) Shape { return s }
+	if opts.SwapXY {
+		var restore func()
+		prepare, restore = swapThenTransform(r)
+		defer restore()
+	}
+
+	bw := bufio.NewWriter(w)
+	io.WriteString(bw, xml.Header)
+	io.WriteString(bw, `<kml xmlns="http://www.opengis.net/kml/2.2"><Document>`)
+	if opts.Name != "" {
+		writeXMLElement(bw, "name", opts.Name)
+	}
+	for r.Next() {
+		n, s := r.Shape()
+		fmt.Fprintf(bw, `<Placemark id="%d">`, n)
+		if nameField >= 0 {
+			writeXMLElement(bw, "name", r.Attribute(nameField))
+		}
+		if descField >= 0 {
+			writeXMLElement(bw, "description", r.Attribute(descField))
+		}
+		if len(fields) > 0 {
+			io.WriteString(bw, "<ExtendedData>")
+			for i, f := range fields {
+				if AttributeIsNull(r, i) {
+					continue
+				}
+				io.WriteString(bw, `<Data name="`)
+				xml.EscapeText(bw, []byte(f.String()))
+				io.WriteString(bw, `">`)
+				writeXMLElement(bw, "value", r.Attribute(i))
+				io.WriteString(bw, "</Data>")
+			}
+			io.WriteString(bw, "</ExtendedData>")
+		}
+		if err := writeKMLGeometry(bw, prepare(s)); err != nil {
+			return err
+		}
+		io.WriteString(bw, "</Placemark>")
+	}
+	if err := r.Err(); err != nil {
+		return err
+	}
+	io.WriteString(bw, "</Document></kml>\n")
+	return bw.Flush()
+}
+
+// WriteKMZ writes the records of r to w as a KMZ archive, which is a ZIP
+// archive holding the KML document written by WriteKML as doc.kml.
+func WriteKMZ(w io.Writer, r SequentialReader, opts KMLOptions) error {
+	zw := zip.NewWriter(w)
+	doc, err := zw.Create("doc.kml")
+	if err != nil {
+		return err
+	}
+	if err := WriteKML(doc, r, opts); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// writeXMLElement writes an element called name with the escaped text.
+func writeXMLElement(w *bufio.Writer, name, text string) {
+	fmt.Fprintf(w, "<%s>", name)
+	xml.EscapeText(w, []byte(text))
+	fmt.Fprintf(w, "</%s>", name)
+}
+
+// writeKMLGeometry writes the KML geometry of s. Nothing is written for Null
+// shapes.
+func writeKMLGeometry(w *bufio.Writer, s Shape) error {
+	g, err := geoJSONGeometryOf(s)
+	if err != nil {
+		return fmt.Errorf("Unsupported shape for KML: %T", s)
+	}
+	if g == nil {
+		return nil
+	}
+	switch c := g.Coordinates.(type) {
+	case []float64:
+		writeKMLPoint(w, c)
+	case [][]float64:
+		if g.Type == "LineString" {
+			writeKMLCoordinates(w, "LineString", c)
+			break
+		}
+		io.WriteString(w, "<MultiGeometry>")
+		for _, p := range c {
+			writeKMLPoint(w, p)
+		}
+		io.WriteString(w, "</MultiGeometry>")
+	case [][][]float64:
+		if g.Type == "Polygon" {
+			writeKMLPolygon(w, c)
+			break
+		}
+		io.WriteString(w, "<MultiGeometry>")
+		for _, line := range c {
+			writeKMLCoordinates(w, "LineString", line)
+		}
+		io.WriteString(w, "</MultiGeometry>")
+	case [][][][]float64:
+		io.WriteString(w, "<MultiGeometry>")
+		for _, polygon := range c {
+			writeKMLPolygon(w, polygon)
+		}
+		io.WriteString(w, "</MultiGeometry>")
+	}
+	return nil
+}
+
+func writeKMLPoint(w *bufio.Writer, p []float64) {
+	writeKMLCoordinates(w, "Point", [][]float64{p})
+}
+
+// writeKMLPolygon writes a Polygon whose first ring is the outer boundary and
+// whose other rings are holes.
+func writeKMLPolygon(w *bufio.Writer, rings [][][]float64) {
+	io.WriteString(w, "<Polygon>")
+	for i, ring := range rings {
+		boundary := "innerBoundaryIs"
+		if i == 0 {
+			boundary = "outerBoundaryIs"
+		}
+		fmt.Fprintf(w, "<%s>", boundary)
+		writeKMLCoordinates(w, "LinearRing", ring)
+		fmt.Fprintf(w, "</%s>", boundary)
+	}
+	io.WriteString(w, "</Polygon>")
+}
+
+// writeKMLCoordinates writes an element called name holding the positions.
+// Positions with a Z value are written with an absolute altitude mode.
+func writeKMLCoordinates(w *bufio.Writer, name string, positions [][]float64) {
+	fmt.Fprintf(w, "<%s>", name)
+	if len(positions) > 0 && len(positions[0]) > 2 {
+		io.WriteString(w, "<altitudeMode>absolute</altitudeMode>")
+	}
+	io.WriteString(w, "<coordinates>")
+	for i, p := range positions {
+		if i > 0 {
+			w.WriteByte(' ')
+		}
+		for j, c := range p {
+			if j > 0 {
+				w.WriteByte(',')
+			}
+			w.WriteString(strconv.FormatFloat(c, 'f', -1, 64))
+		}
+	}
+	fmt.Fprintf(w, "</coordinates></%s>", name)
+}
@@ -0,0 +1,111 @@
+package shp
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteKML(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "points.shp")
+	w, err := Create(filename, POINT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SetFields([]Field{StringField("NAME", 20), NumberField("ID", 4)})
+	w.Write(&Point{1.5, 2})
+	w.WriteAttribute(0, 0, "Tom & Jerry")
+	w.WriteAttribute(0, 1, 1)
+	w.Write(&Null{})
+	w.WriteAttribute(1, 0, "empty")
+	w.Close()
+
+	r, err := Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	var buf bytes.Buffer
+	if err := WriteKML(&buf, r, KMLOptions{Name: "points", NameField: "name"}); err != nil {
+		t.Fatal(err)
+	}
+	want := `<?xml version="1.0" encoding="UTF-8"?>` + "\n" +
+		`<kml xmlns="http://www.opengis.net/kml/2.2"><Document><name>points</name>` +
+		`<Placemark id="0"><name>Tom &amp; Jerry</name><ExtendedData>` +
+		`<Data name="NAME"><value>Tom &amp; Jerry</value></Data><Data name="ID"><value>1</value></Data>` +
+		`</ExtendedData><Point><coordinates>1.5,2</coordinates></Point></Placemark>` +
+		`<Placemark id="1"><name>empty</name><ExtendedData>` +
+		`<Data name="NAME"><value>empty</value></Data>` +
+		`</ExtendedData></Placemark></Document></kml>` + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	r2, err := Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r2.Close()
+	if err := WriteKML(ioutil.Discard, r2, KMLOptions{DescriptionField: "NOPE"}); !errors.Is(err, ErrNoSuchField) {
+		t.Errorf("WriteKML with unknown field = %v, want ErrNoSuchField", err)
+	}
+}
+
+func TestWriteKMLPolygon(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "polygons.shp")
+	w, err := Create(filename, POLYGON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write((*Polygon)(NewPolyLine([][]Point{
+		{{0, 0}, {0, 10}, {10, 10}, {10, 0}, {0, 0}},
+		{{2, 2}, {8, 2}, {8, 8}, {2, 8}, {2, 2}},
+	})))
+	w.Close()
+
+	r, err := Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	var buf bytes.Buffer
+	if err := WriteKMZ(&buf, r, KMLOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(zr.File) != 1 || zr.File[0].Name != "doc.kml" {
+		t.Fatalf("KMZ holds %v, want doc.kml", zr.File)
+	}
+	f, err := zr.File[0].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := ioutil.ReadAll(f)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<Polygon><outerBoundaryIs><LinearRing><coordinates>0,0 10,0 10,10 0,10 0,0</coordinates></LinearRing></outerBoundaryIs>` +
+		`<innerBoundaryIs><LinearRing><coordinates>2,2 2,8 8,8 8,2 2,2</coordinates></LinearRing></innerBoundaryIs></Polygon>`
+	if !strings.Contains(string(doc), want) {
+		t.Errorf("got\n%s\nwant it to contain\n%s", doc, want)
+	}
+}
@@ -0,0 +1,62 @@
+package shp
+
+import (
+	"errors"
+	"io"
+)
+
+// SetLenient makes Next skip damaged records instead of stopping with an
+// error, like the WithLenient option. A record is damaged if its content
+// length is invalid, its content is too short for its points or its shape
+// type is unknown. Every skipped record is reported through RecordErrors and
+// counts as one record, so the attribute rows stay aligned. If the content
+// length of a damaged record cannot be trusted, the reader resynchronizes on
+// the next intact record whose header carries the expected record number or
+// a higher one, and reports the records in between as lost.
+func (r *Reader) SetLenient(lenient bool) {
+	r.opts.lenient = lenient
+}
+
+// skipDamagedHeader reports whether the record header that readContent
+// failed on was skipped in lenient mode.
+func (r *Reader) skipDamagedHeader() bool {
+	re, ok := r.err.(*RecordError)
+	if !ok || !r.opts.lenient || r.recovered != nil {
+		return false
+	}
+	r.err = nil
+	r.recordErrors = append(r.recordErrors, re)
+	r.count++
+	r.resync(re.Offset)
+	return true
+}
+
+// skipDamagedContent records err for the record that was read last, whose
+// content could not be decoded, and reports that it was skipped. The next
+// record is read where the content length of the damaged record says; if
+// that is no record header, skipDamagedHeader resynchronizes.
+func (r *Reader) skipDamagedContent(err *RecordError) bool {
+	r.recordErrors = append(r.recordErrors, err)
+	return true
+}
+
+// resync moves the reader to the first intact record after the damaged one
+// at offset whose header carries the number of the record that follows the
+// last one that was read or a higher one, or to the end of the file if there
+// is none. The records whose numbers were skipped are reported as lost in
+// the damaged region and advance the attribute rows.
+func (r *Reader) resync(damaged int64) {
+	want := int64(r.count + 1)
+	o, num, ok := r.nextIntactRecord(damaged, want)
+	if !ok {
+		r.offset = r.filelength
+		return
+	}
+	for ; want < num; want++ {
+		r.count++
+		r.recordErrors = append(r.recordErrors, &RecordError{RecordNum: r.count, Offset: damaged,
+			Err: errors.New("record is lost in a damaged region")})
+	}
+	r.offset = o
+	r.shp.Seek(o, io.SeekStart)
+}
@@ -0,0 +1,138 @@
+package shp
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestSetLenient(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "lines")
+	w, err := Create(filename+".shp", POLYLINE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SetFields([]Field{NumberField("ID", 4)})
+	for i := 0; i < 8; i++ {
+		n := w.Write(NewPolyLine([][]Point{{{float64(i), 0}, {float64(i), 1}}}))
+		w.WriteAttribute(int(n), 0, i)
+	}
+	w.Close()
+
+	shp, err := ioutil.ReadFile(filename + ".shp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// every record has a header of 8 bytes and 80 bytes of content
+	record := func(i int) []byte { return shp[100+88*i:] }
+	binary.BigEndian.PutUint32(record(1)[4:], 0x7fffffff) // content length
+	binary.LittleEndian.PutUint32(record(3)[8+40:], 1000) // number of points
+	binary.LittleEndian.PutUint32(record(5)[8:], 42)      // shape type
+	if err := ioutil.WriteFile(filename+".shp", shp, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, lenient := range []bool{false, true} {
+		r, err := Open(filename + ".shp")
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.SetLenient(lenient)
+		var got []int
+		for r.Next() {
+			n, s := r.Shape()
+			got = append(got, n)
+			if p := s.(*PolyLine); p.Points[0].X != float64(n) {
+				t.Errorf("lenient=%v: record %d has shape %v", lenient, n, s)
+			}
+			if a := r.Attribute(0); a != strconv.Itoa(n) {
+				t.Errorf("lenient=%v: record %d is paired with attribute %q", lenient, n, a)
+			}
+		}
+		if !lenient {
+			if r.Err() == nil || len(got) != 1 {
+				t.Errorf("read %v with error %v, want stop after first record", got, r.Err())
+			}
+			r.Close()
+			continue
+		}
+		if r.Err() != nil {
+			t.Errorf("got error %v in lenient mode", r.Err())
+		}
+		if want := []int{0, 2, 4, 6, 7}; len(got) != len(want) || got[1] != 2 || got[2] != 4 || got[3] != 6 {
+			t.Errorf("read records %v, want %v", got, want)
+		}
+		errs := r.RecordErrors()
+		if len(errs) != 3 {
+			t.Fatalf("got record errors %v, want 3", errs)
+		}
+		for i, num := range []int{2, 4, 6} {
+			if errs[i].RecordNum != num || errs[i].Offset != int64(100+88*(num-1)) {
+				t.Errorf("got record error %v, want record %d", errs[i], num)
+			}
+		}
+		r.Close()
+	}
+}
+
+func TestSetLenientAdjacentDamage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "points")
+	w, err := Create(filename+".shp", POINT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SetFields([]Field{NumberField("ID", 4)})
+	for i := 0; i < 100; i++ {
+		n := w.Write(&Point{float64(i), 0})
+		w.WriteAttribute(int(n), 0, i)
+	}
+	w.Close()
+
+	shp, err := ioutil.ReadFile(filename + ".shp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// every record has a header of 8 bytes and 20 bytes of content, and
+	// records 10 and 11 get invalid content lengths
+	for _, i := range []int{9, 10} {
+		binary.BigEndian.PutUint32(shp[100+28*i+4:], 0x7fffffff)
+	}
+	if err := ioutil.WriteFile(filename+".shp", shp, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := Open(filename + ".shp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	r.SetLenient(true)
+	read := 0
+	for r.Next() {
+		n, s := r.Shape()
+		if p := s.(*Point); p.X != float64(n) || r.Attribute(0) != strconv.Itoa(n) {
+			t.Errorf("record %d has shape %v and attribute %q", n, s, r.Attribute(0))
+		}
+		read++
+	}
+	if r.Err() != nil || read != 98 {
+		t.Errorf("read %d records with error %v, want 98", read, r.Err())
+	}
+	errs := r.RecordErrors()
+	if len(errs) != 2 || errs[0].RecordNum != 10 || errs[1].RecordNum != 11 {
+		t.Errorf("got record errors %v, want records 10 and 11", errs)
+	}
+}
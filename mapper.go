@@ -0,0 +1,202 @@
+package shp
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// structFields returns, for every exported field of the struct type t, the
+// index of the DBF field it is mapped to, or -1 if there is none. A field is
+// mapped to the DBF field named in its `shp:"NAME"` tag, or to the DBF field
+// with its own name if it has no tag. Names are compared regardless of case.
+// Fields tagged with `shp:"-"` are not mapped.
+func structFields(t reflect.Type, fields []Field) []int {
+	index := make([]int, t.NumField())
+	for i := range index {
+		index[i] = -1
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		name := f.Name
+		if tag, ok := f.Tag.Lookup("shp"); ok {
+			if tag == "-" {
+				continue
+			}
+			name = tag
+		}
+		for j, field := range fields {
+			if strings.EqualFold(field.String(), name) {
+				index[i] = j
+				break
+			}
+		}
+	}
+	return index
+}
+
+// structValue returns the struct that v points to.
+func structValue(v interface{}) (reflect.Value, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return reflect.Value{}, fmt.Errorf("Expected a non-nil pointer to a struct, got %T", v)
+	}
+	return rv.Elem(), nil
+}
+
+// setAttr sets v, which is of one of the supported kinds or a pointer to one,
+// to the value of a. NULL attributes leave v at its zero value, i.e. pointers
+// stay nil.
+func setAttr(v reflect.Value, a Attr) error {
+	if a.Null {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	if v.Kind() == reflect.Ptr {
+		p := reflect.New(v.Type().Elem())
+		if err := setAttr(p.Elem(), a); err != nil {
+			return err
+		}
+		v.Set(p)
+		return nil
+	}
+	if v.Type() == timeType {
+		t, err := a.Date()
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(a.Value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := a.Int()
+		if err != nil {
+			return err
+		}
+		if v.OverflowInt(i) {
+			return fmt.Errorf("%d overflows %v", i, v.Type())
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		i, err := a.Int()
+		if err != nil {
+			return err
+		}
+		if i < 0 || v.OverflowUint(uint64(i)) {
+			return fmt.Errorf("%d overflows %v", i, v.Type())
+		}
+		v.SetUint(uint64(i))
+	case reflect.Float32, reflect.Float64:
+		f, err := a.Float()
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Bool:
+		b, err := a.Bool()
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	default:
+		return fmt.Errorf("Unsupported type %v", v.Type())
+	}
+	return nil
+}
+
+// ReadAttributesInto stores the attributes of the shape that sr was last
+// advanced to in the struct that v points to. Struct fields are mapped to DBF
+// fields by their `shp:"NAME"` tag or by their name, regardless of case, and
+// can be strings, integers, floats, bools, time.Time for date fields or
+// pointers to these. NULL attributes set the struct field to its zero value,
+// so pointers can be used to tell NULL apart from 0. DBF fields without a
+// struct field and struct fields without a DBF field are ignored.
+func ReadAttributesInto(sr SequentialReader, v interface{}) error {
+	s, err := structValue(v)
+	if err != nil {
+		return err
+	}
+	for i, n := range structFields(s.Type(), sr.Fields()) {
+		if n < 0 {
+			continue
+		}
+		a, err := currentAttr(sr, n)
+		if err != nil {
+			return err
+		}
+		if err := setAttr(s.Field(i), a); err != nil {
+			return fmt.Errorf("Unable to read field %s into %s: %v", a.Field, s.Type().Field(i).Name, err)
+		}
+	}
+	return nil
+}
+
+// ReadAttributesInto stores the attributes of the current shape in the struct
+// that v points to. See the function ReadAttributesInto.
+func (r *Reader) ReadAttributesInto(v interface{}) error {
+	return ReadAttributesInto(r, v)
+}
+
+// ReadAttributesInto stores the attributes of the current shape in the struct
+// that v points to. See the function ReadAttributesInto.
+func (zr *ZipReader) ReadAttributesInto(v interface{}) error {
+	return ReadAttributesInto(zr, v)
+}
+
+// WriteAttributesFrom writes the fields of the struct v, or the struct that v
+// points to, into the given row of the DBF. Struct fields are mapped to DBF
+// fields like in ReadAttributesInto. Nil pointers are written as NULL.
+func (w *Writer) WriteAttributesFrom(row int, v interface{}) error {
+	s := reflect.ValueOf(v)
+	if s.Kind() == reflect.Ptr && !s.IsNil() {
+		s = s.Elem()
+	}
+	if s.Kind() != reflect.Struct {
+		return fmt.Errorf("Expected a struct, got %T", v)
+	}
+	if w.dbf == nil {
+		return errors.New("Initialize DBF by using SetFields first")
+	}
+	for i, n := range structFields(s.Type(), w.dbfFields) {
+		if n < 0 {
+			continue
+		}
+		f := s.Field(i)
+		if f.Kind() == reflect.Ptr {
+			if f.IsNil() {
+				if err := w.WriteAttributeNull(row, n); err != nil {
+					return err
+				}
+				continue
+			}
+			f = f.Elem()
+		}
+		var value interface{}
+		switch f.Kind() {
+		case reflect.String:
+			value = f.String()
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			value = int(f.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			value = int(f.Uint())
+		case reflect.Float32, reflect.Float64:
+			value = f.Float()
+		case reflect.Bool:
+			value = f.Bool()
+		default:
+			value = f.Interface()
+		}
+		if err := w.WriteAttribute(row, n, value); err != nil {
+			return fmt.Errorf("Unable to write %s into field %s: %v", s.Type().Field(i).Name, w.dbfFields[n], err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package shp
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type building struct {
+	ID      int
+	Area    *float64 `shp:"AREA"`
+	Built   time.Time
+	Open    bool
+	Name    string `shp:"NAME"`
+	Ignored string `shp:"-"`
+	Extra   string
+	secret  string
+}
+
+func TestReadAttributesInto(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	r, err := Open(writeTyped(t, dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	area := 12.5
+	var got []building
+	for r.Next() {
+		b := building{Ignored: "keep"}
+		if err := r.ReadAttributesInto(&b); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, b)
+	}
+	want := []building{
+		{ID: 42, Area: &area, Built: time.Date(1999, 12, 31, 0, 0, 0, 0, time.UTC), Open: true, Name: "x", Ignored: "keep"},
+		{Ignored: "keep"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	if err := r.ReadAttributesInto(building{}); err == nil {
+		t.Error("expected an error for a non-pointer")
+	}
+	var wrong struct{ Name int }
+	if err := r.ReadAttributesInto(&wrong); err == nil {
+		t.Error("expected an error for a character field read into an int")
+	}
+}
+
+func TestWriteAttributesFrom(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "mapped.shp")
+	w, err := Create(filename, POINT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SetFields([]Field{NumberField("ID", 5), FloatField("AREA", 8, 2), DateField("BUILT"), LogicalField("OPEN"), StringField("NAME", 10)})
+	area := 7.25
+	want := []building{
+		{ID: 7, Area: &area, Built: time.Date(2020, 2, 29, 0, 0, 0, 0, time.UTC), Open: true, Name: "Town hall"},
+		{ID: 8, Name: "Shed"},
+	}
+	for i := range want {
+		w.Write(&Point{})
+		if err := w.WriteAttributesFrom(i, &want[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w.Close()
+
+	r, err := Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	for i := 0; r.Next(); i++ {
+		var b building
+		if err := r.ReadAttributesInto(&b); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(b, want[i]) {
+			t.Errorf("row %d: got %+v, want %+v", i, b, want[i])
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package shp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+)
+
+// NoData is the measure used for Z shapes whose records omit the optional
+// measures. The specification treats every value below -10^38 as "no data".
+const NoData = -1e39
+
+// IsNoData reports whether the measure m is "no data", which is every value
+// below -10^38 and not only NoData itself.
+func IsNoData(m float64) bool {
+	return m < -1e38
+}
+
+// Measured is implemented by the shapes that have measures: the M and Z
+// shapes and MultiPatch.
+type Measured interface {
+	Shape
+	// Measures returns the measure of every point.
+	Measures() []float64
+	// MeasureRange returns the smallest and largest measure that is not
+	// NoData. ok is false if all measures are NoData. Unlike the MRange
+	// field, it is computed from the measures.
+	MeasureRange() (min, max float64, ok bool)
+}
+
+// Measures returns the measure of the point.
+func (p PointM) Measures() []float64 {
+	return []float64{p.M}
+}
+
+// Measures returns the measure of the point.
+func (p PointZ) Measures() []float64 {
+	return []float64{p.M}
+}
+
+// Measures returns the measure of every point.
+func (p MultiPointM) Measures() []float64 {
+	return p.MArray
+}
+
+// Measures returns the measure of every point.
+func (p MultiPointZ) Measures() []float64 {
+	return p.MArray
+}
+
+// Measures returns the measure of every point.
+func (p PolyLineM) Measures() []float64 {
+	return p.MArray
+}
+
+// Measures returns the measure of every point.
+func (p PolyLineZ) Measures() []float64 {
+	return p.MArray
+}
+
+// Measures returns the measure of every point.
+func (p PolygonM) Measures() []float64 {
+	return p.MArray
+}
+
+// Measures returns the measure of every point.
+func (p PolygonZ) Measures() []float64 {
+	return p.MArray
+}
+
+// Measures returns the measure of every point.
+func (p MultiPatch) Measures() []float64 {
+	return p.MArray
+}
+
+// MeasureRange returns the measure of the point, unless it is NoData.
+func (p PointM) MeasureRange() (min, max float64, ok bool) {
+	return measureBounds(p.Measures())
+}
+
+// MeasureRange returns the measure of the point, unless it is NoData.
+func (p PointZ) MeasureRange() (min, max float64, ok bool) {
+	return measureBounds(p.Measures())
+}
+
+// MeasureRange returns the range of the measures that are not NoData.
+func (p MultiPointM) MeasureRange() (min, max float64, ok bool) {
+	return measureBounds(p.MArray)
+}
+
+// MeasureRange returns the range of the measures that are not NoData.
+func (p MultiPointZ) MeasureRange() (min, max float64, ok bool) {
+	return measureBounds(p.MArray)
+}
+
+// MeasureRange returns the range of the measures that are not NoData.
+func (p PolyLineM) MeasureRange() (min, max float64, ok bool) {
+	return measureBounds(p.MArray)
+}
+
+// MeasureRange returns the range of the measures that are not NoData.
+func (p PolyLineZ) MeasureRange() (min, max float64, ok bool) {
+	return measureBounds(p.MArray)
+}
+
+// MeasureRange returns the range of the measures that are not NoData.
+func (p PolygonM) MeasureRange() (min, max float64, ok bool) {
+	return measureBounds(p.MArray)
+}
+
+// MeasureRange returns the range of the measures that are not NoData.
+func (p PolygonZ) MeasureRange() (min, max float64, ok bool) {
+	return measureBounds(p.MArray)
+}
+
+// MeasureRange returns the range of the measures that are not NoData.
+func (p MultiPatch) MeasureRange() (min, max float64, ok bool) {
+	return measureBounds(p.MArray)
+}
+
+// measureBounds returns the smallest and largest of the measures m that are
+// not NoData.
+func measureBounds(m []float64) (min, max float64, ok bool) {
+	for _, v := range m {
+		if IsNoData(v) {
+			continue
+		}
+		if !ok || v < min {
+			min = v
+		}
+		if !ok || v > max {
+			max = v
+		}
+		ok = true
+	}
+	return min, max, ok
+}
+
+// measureRange returns the range of the measures m to store in a shape, which
+// leaves out NoData values. It is NoData if m holds nothing but NoData.
+func measureRange(m []float64) [2]float64 {
+	if min, max, ok := measureBounds(m); ok {
+		return [2]float64{min, max}
+	}
+	if len(m) > 0 {
+		return [2]float64{NoData, NoData}
+	}
+	return [2]float64{}
+}
+
+// measureLayout returns the number of points of the Z shape of type t in
+// content, the record content following the shape type, and the size of
+// content without the optional measures. ok is false if t is not a Z shape
+// or content is too short to contain the counts.
+func measureLayout(t ShapeType, content []byte) (numPoints, sizeWithoutM int, ok bool) {
+	count := func(offset int) int {
+		return int(int32(binary.LittleEndian.Uint32(content[offset:])))
+	}
+	switch t {
+	case POINTZ:
+		return 1, 24, true
+	case MULTIPOINTZ:
+		if len(content) < 36 {
+			return 0, 0, false
+		}
+		n := count(32)
+		return n, 36 + 16*n + 16 + 8*n, true
+	case POLYLINEZ, POLYGONZ, MULTIPATCH:
+		if len(content) < 40 {
+			return 0, 0, false
+		}
+		parts, n := count(32), count(36)
+		partsSize := 4 * parts
+		if t == MULTIPATCH {
+			partsSize *= 2
+		}
+		return n, 40 + partsSize + 16*n + 16 + 8*n, true
+	}
+	return 0, 0, false
+}
+
+// missingMeasures returns the encoded measures of a Z shape whose record
+// content omits them, with NoData as range and values, or nil if content
+// includes the measures or is not the content of a Z shape.
+func missingMeasures(t ShapeType, content []byte) []byte {
+	n, size, ok := measureLayout(t, content)
+	if !ok || n < 0 || len(content) < size {
+		return nil
+	}
+	mSize := 16 + 8*n
+	if t == POINTZ {
+		mSize = 8
+	}
+	if len(content) >= size+mSize {
+		return nil
+	}
+	nodata := make([]byte, 8)
+	binary.LittleEndian.PutUint64(nodata, math.Float64bits(NoData))
+	return bytes.Repeat(nodata, mSize/8)
+}
+
+// stripMeasures returns content, the encoded content of a Z shape of type t,
+// without the optional measures.
+func stripMeasures(t ShapeType, content []byte) []byte {
+	if _, size, ok := measureLayout(t, content); ok && size <= len(content) {
+		return content[:size]
+	}
+	return content
+}
@@ -0,0 +1,229 @@
+package shp
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadZWithoutMeasures(t *testing.T) {
+	shapes := getShapesFromFile("test_files/pointz_nom", t)
+	if len(shapes) != 3 {
+		t.Fatalf("got %d shapes, want 3", len(shapes))
+	}
+	testPointZ(t, [][]float64{{0, 0, 0}, {5, 5, 5}, {10, 10, 10}}, shapes)
+	for _, s := range shapes {
+		if m := s.(*PointZ).M; m != NoData {
+			t.Errorf("got M %v, want NoData", m)
+		}
+	}
+
+	shapes = getShapesFromFile("test_files/pointz_withm", t)
+	for i, s := range shapes {
+		if m := s.(*PointZ).M; m != float64(i) {
+			t.Errorf("got M %v, want %v", m, i)
+		}
+	}
+
+	shapes = getShapesFromFile("test_files/polylinez_nom", t)
+	if len(shapes) != 2 {
+		t.Fatalf("got %d shapes, want 2", len(shapes))
+	}
+	testPolyLineZ(t, [][]float64{{0, 0, 0}, {5, 5, 5}, {10, 10, 10}, {15, 15, 15}, {20, 20, 20}, {25, 25, 25}}, shapes)
+	for _, s := range shapes {
+		p := s.(*PolyLineZ)
+		if p.MRange != [2]float64{NoData, NoData} || len(p.MArray) != 3 || p.MArray[2] != NoData {
+			t.Errorf("got MRange %v and MArray %v, want NoData", p.MRange, p.MArray)
+		}
+	}
+}
+
+func TestWriteZWithoutMeasures(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, tc := range []struct {
+		fixture string
+		t       ShapeType
+		shapes  []Shape
+	}{
+		{"test_files/pointz_nom", POINTZ, []Shape{&PointZ{0, 0, 0, 0}, &PointZ{5, 5, 5, 0}, &PointZ{10, 10, 10, 0}}},
+		{"test_files/polylinez_nom", POLYLINEZ, func() []Shape {
+			var shapes []Shape
+			for _, v := range []float64{0, 15} {
+				p, err := NewPolyLineZ([][]Point{{{v, v}, {v + 5, v + 5}, {v + 10, v + 10}}}, [][]float64{{v, v + 5, v + 10}}, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				shapes = append(shapes, p)
+			}
+			return shapes
+		}()},
+	} {
+		base := filepath.Join(dir, filepath.Base(tc.fixture))
+		w, err := Create(base+".shp", tc.t, WithoutMeasures())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range tc.shapes {
+			w.Write(s)
+		}
+		w.Close()
+		for _, ext := range []string{".shp", ".shx"} {
+			got, err := ioutil.ReadFile(base + ext)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := ioutil.ReadFile(tc.fixture + ext)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("%s%s differs from fixture", tc.fixture, ext)
+			}
+		}
+	}
+}
+
+// TestGDALReadsZWithoutMeasures checks that GDAL accepts the output of
+// WithoutMeasures. It is skipped if ogrinfo is not installed.
+func TestGDALReadsZWithoutMeasures(t *testing.T) {
+	ogrinfo, err := exec.LookPath("ogrinfo")
+	if err != nil {
+		t.Skip("ogrinfo not installed")
+	}
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "pointz.shp")
+	w, err := Create(name, POINTZ, WithoutMeasures())
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(&PointZ{1, 2, 3, 0})
+	w.Close()
+	out, err := exec.Command(ogrinfo, "-ro", "-al", name).CombinedOutput()
+	if err != nil {
+		t.Fatalf("ogrinfo failed: %v\n%s", err, out)
+	}
+	if !strings.Contains(string(out), "POINT Z (1 2 3)") {
+		t.Errorf("unexpected ogrinfo output:\n%s", out)
+	}
+}
+
+func TestMeasureAccessors(t *testing.T) {
+	p, err := NewPolyLineM([][]Point{{{0, 0}, {1, 1}, {2, 2}}}, [][]float64{{NoData, 5, -1e300}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.MRange != [2]float64{5, 5} {
+		t.Errorf("got MRange %v, want the range without NoData", p.MRange)
+	}
+	var m Measured = p
+	if min, max, ok := m.MeasureRange(); !ok || min != 5 || max != 5 {
+		t.Errorf("got range %v, %v, %v, want 5, 5", min, max, ok)
+	}
+	if got := m.Measures(); len(got) != 3 || !IsNoData(got[0]) || !IsNoData(got[2]) {
+		t.Errorf("got measures %v", got)
+	}
+	if _, _, ok := (&PointZ{M: NoData}).MeasureRange(); ok {
+		t.Error("expected no range for a NoData measure")
+	}
+	if _, ok := Shape(&PolyLine{}).(Measured); ok {
+		t.Error("PolyLine should not have measures")
+	}
+}
+
+func TestWriteWithoutNoDataMeasures(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "pointz.shp")
+	w, err := Create(name, POINTZ, WithoutNoDataMeasures())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Shape{&PointZ{1, 2, 3, NoData}, &PointZ{4, 5, 6, 7}}
+	for _, s := range want {
+		w.Write(s)
+	}
+	w.Close()
+
+	r, err := Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	// the first record omits its measure and the second keeps it
+	for i, size := range []int{28, 36} {
+		if !r.Next() {
+			t.Fatal(r.Err())
+		}
+		if _, raw := r.RawShape(); len(raw.Content)+4 != size {
+			t.Errorf("record %d is %d bytes long, want %d", i, len(raw.Content)+4, size)
+		}
+		if _, s := r.Shape(); *s.(*PointZ) != *want[i].(*PointZ) {
+			t.Errorf("got %v, want %v", s, want[i])
+		}
+	}
+}
+
+func TestConstructorsWithoutNoDataMeasures(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	square := []Point{{0, 0}, {0, 1}, {1, 1}, {1, 0}, {0, 0}}
+	z := [][]float64{{1, 2, 3, 4, 1}}
+	line, err := NewPolyLineZ([][]Point{square}, z, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	polygon, err := NewPolygonZ([][]Point{square}, z, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	points, err := NewMultiPointZ(square, z[0], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	patch, err := NewMultiPatch([][]Point{square}, []int32{OuterRing}, z, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shapes := map[ShapeType]Shape{POLYLINEZ: line, POLYGONZ: polygon, MULTIPOINTZ: points, MULTIPATCH: patch}
+	for typ, s := range shapes {
+		name := filepath.Join(dir, typ.String()+".shp")
+		w, err := Create(name, typ, WithoutNoDataMeasures())
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(s)
+		w.Close()
+
+		r, err := Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !r.Next() {
+			t.Fatal(r.Err())
+		}
+		_, raw := r.RawShape()
+		if _, size, _ := measureLayout(raw.Type, raw.Content); len(raw.Content) != size {
+			t.Errorf("%v: record is %d bytes long, want %d without measures", typ, len(raw.Content), size)
+		}
+		r.Close()
+	}
+}
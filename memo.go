@@ -0,0 +1,130 @@
+package shp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// memoFile is a .dbt file, which holds the values of the memo fields of a DBF
+// in blocks. The DBF stores the number of the first block of a value.
+type memoFile struct {
+	r         io.ReaderAt
+	blockSize int64
+}
+
+// newMemoFile reads the header of the .dbt file r. dBase IV files declare
+// their block size in the header, dBase III files always use 512 bytes.
+func newMemoFile(r io.ReaderAt) (*memoFile, error) {
+	var header [22]byte
+	if _, err := r.ReadAt(header[:], 0); err != nil {
+		return nil, fmt.Errorf("Error reading memo file header: %v", err)
+	}
+	m := &memoFile{r: r, blockSize: 512}
+	if bs := int64(binary.LittleEndian.Uint16(header[20:22])); bs > 0 && header[16] != 0x03 {
+		m.blockSize = bs
+	}
+	return m, nil
+}
+
+// read returns the value that starts at the given block. dBase IV values
+// start with a marker and their length, dBase III values end with 0x1a.
+func (m *memoFile) read(block int64) (string, error) {
+	offset := block * m.blockSize
+	var head [8]byte
+	n, err := m.r.ReadAt(head[:], offset)
+	if n < len(head) && err != nil && err != io.EOF {
+		return "", err
+	}
+	if n == len(head) && bytes.Equal(head[:4], []byte{0xff, 0xff, 0x08, 0x00}) {
+		length := int64(binary.LittleEndian.Uint32(head[4:8])) - 8
+		if length < 0 {
+			return "", fmt.Errorf("Invalid length of memo in block %d", block)
+		}
+		b := make([]byte, length)
+		if _, err := m.r.ReadAt(b, offset+8); err != nil && err != io.EOF {
+			return "", err
+		}
+		return strings.TrimRight(string(b), "\x00\x1a"), nil
+	}
+
+	var value []byte
+	chunk := make([]byte, 512)
+	for {
+		n, err := m.r.ReadAt(chunk, offset)
+		if end := bytes.IndexByte(chunk[:n], 0x1a); end >= 0 {
+			return string(append(value, chunk[:end]...)), nil
+		}
+		value = append(value, chunk[:n]...)
+		if err == io.EOF {
+			return string(value), nil
+		}
+		if err != nil {
+			return "", err
+		}
+		offset += int64(n)
+	}
+}
+
+// memo returns the memo value that the attribute a refers to.
+func (m *memoFile) memo(a Attr) (string, error) {
+	if a.Field.Fieldtype != 'M' {
+		return "", fmt.Errorf("Field %s of type %c is not a memo field", a.Field, a.Field.Fieldtype)
+	}
+	v := strings.TrimSpace(a.Value)
+	if v == "" {
+		return "", nil
+	}
+	block, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || block < 0 {
+		return "", fmt.Errorf("Invalid memo block %q of field %s", v, a.Field)
+	}
+	if block == 0 {
+		return "", nil
+	}
+	if m == nil {
+		return "", errors.New("Unable to read memo: there is no .dbt file")
+	}
+	return m.read(block)
+}
+
+// Memo returns the value of the memo field n of the current shape, which is
+// read from the .dbt file next to the shapefile. Empty memos are returned as
+// the empty string.
+func (r *Reader) Memo(n int) (string, error) {
+	a, err := currentAttr(r, n)
+	if err != nil {
+		return "", err
+	}
+	if r.memo == nil && r.dbt == nil {
+		name := r.filename + ".dbt"
+		if files, err := datasetFiles(r.filename); err == nil && files[".dbt"] != "" {
+			name = files[".dbt"]
+		}
+		if f, err := os.Open(name); err == nil {
+			r.dbt = f
+			if r.memo, err = newMemoFile(f); err != nil {
+				return "", err
+			}
+		}
+	}
+	m, err := r.memo.memo(a)
+	return decodeAttr(r.charset, m), err
+}
+
+// Memo returns the value of the memo field n of the current shape, which is
+// read from the .dbt file in the archive. Empty memos are returned as the
+// empty string.
+func (zr *ZipReader) Memo(n int) (string, error) {
+	a, err := currentAttr(zr, n)
+	if err != nil {
+		return "", err
+	}
+	m, err := zr.memo.memo(a)
+	return decodeAttr(zr.Charset(), m), err
+}
@@ -0,0 +1,131 @@
+package shp
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// dBase3Memo returns a dBase III .dbt file with the given values, each of
+// which starts in a new block of 512 bytes, and the number of their first
+// blocks.
+func dBase3Memo(values ...string) ([]byte, []int) {
+	buf := make([]byte, 512)
+	buf[16] = 0x03
+	var blocks []int
+	for _, v := range values {
+		blocks = append(blocks, len(buf)/512)
+		buf = append(buf, v...)
+		buf = append(buf, 0x1a, 0x1a)
+		buf = append(buf, make([]byte, 511-(len(buf)+511)%512)...)
+	}
+	binary.LittleEndian.PutUint32(buf, uint32(len(buf)/512))
+	return buf, blocks
+}
+
+// writeMemoShapefile writes a shapefile with a memo field whose rows point to
+// the given blocks, 0 meaning no memo.
+func writeMemoShapefile(t *testing.T, filename string, blocks []int) {
+	w, err := Create(filename, POINT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	memo := Field{Fieldtype: 'M', Size: 10}
+	copy(memo.Name[:], "NOTES")
+	w.SetFields([]Field{StringField("NAME", 5), memo})
+	for i, b := range blocks {
+		w.Write(&Point{})
+		w.WriteAttribute(i, 0, "p"+string(rune('a'+i)))
+		if b > 0 {
+			w.WriteAttribute(i, 1, b)
+		}
+	}
+	w.Close()
+}
+
+func TestMemo(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	long := strings.Repeat("a long description ", 50)
+	values := []string{"short", long}
+	dbt, blocks := dBase3Memo(values...)
+	filename := filepath.Join(dir, "memo.shp")
+	writeMemoShapefile(t, filename, append(blocks, 0))
+	if err := ioutil.WriteFile(filepath.Join(dir, "memo.DBT"), dbt, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	want := append(values, "")
+	for i := 0; r.Next(); i++ {
+		got, err := r.Memo(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want[i] {
+			t.Errorf("row %d: got memo %q, want %q", i, got, want[i])
+		}
+		if _, err := r.Memo(0); err == nil {
+			t.Error("expected an error for a character field")
+		}
+	}
+
+	// the .dbt is read from the archive as well
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, ext := range []string{".shp", ".shx", ".dbf", ".DBT"} {
+		b, _ := ioutil.ReadFile(filepath.Join(dir, "memo"+ext))
+		w, _ := zw.Create("memo" + ext)
+		w.Write(b)
+	}
+	zw.Close()
+	zr, err := OpenZipReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+	zr.Next()
+	zr.Next()
+	if got, err := zr.Memo(1); got != long || err != nil {
+		t.Errorf("got memo %q, %v from archive", got, err)
+	}
+}
+
+func TestMemoDBase4(t *testing.T) {
+	const blockSize = 64
+	dbt := make([]byte, blockSize)
+	binary.LittleEndian.PutUint16(dbt[20:], blockSize)
+	value := strings.Repeat("x", 100)
+	dbt = append(dbt, 0xff, 0xff, 0x08, 0x00, 0, 0, 0, 0)
+	binary.LittleEndian.PutUint32(dbt[blockSize+4:], uint32(8+len(value)))
+	dbt = append(dbt, value...)
+
+	m, err := newMemoFile(bytes.NewReader(dbt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	memo := Field{Fieldtype: 'M', Size: 10}
+	if got, err := m.memo(Attr{Field: memo, Value: "         1"}); got != value || err != nil {
+		t.Errorf("got %q, %v", got, err)
+	}
+	if _, err := m.memo(Attr{Field: memo, Value: "x"}); err == nil {
+		t.Error("expected an error for an invalid block number")
+	}
+	var none *memoFile
+	if _, err := none.memo(Attr{Field: memo, Value: "1"}); err == nil {
+		t.Error("expected an error without a .dbt file")
+	}
+}
@@ -0,0 +1,134 @@
+package shp
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Merge writes the records of all srcs, one after another, to dst. Records
+// are streamed, so the sources can be of any size. Shapes with fewer
+// dimensions than the shape type of dst are promoted to it, see Promote, and
+// other shapes of a different type are an error. The extent of dst is the
+// union of the extents of the shapes.
+//
+// If no fields have been set on dst, its fields are the union of the fields
+// of all srcs: fields are matched by name, ignoring case, and fields of the
+// same name but a different type or size are widened so that they can hold
+// the values of every source. Numbers of different precision become numbers
+// with the larger precision, and fields of different types other than
+// numbers become character fields. If dst has fields already, the attributes
+// of fields that it does not have are dropped. Merge does not close dst or
+// the sources.
+func Merge(dst *Writer, srcs ...SequentialReader) error {
+	if dst.dbf == nil {
+		var fields []Field
+		for _, src := range srcs {
+			fields = mergeFields(fields, src.Fields())
+		}
+		if err := dst.SetFields(fields); err != nil {
+			return err
+		}
+	}
+	for i, src := range srcs {
+		columns := fieldColumns(dst.dbfFields, src.Fields())
+		for src.Next() {
+			n, s := src.Shape()
+			if t := shapeTypeOf(s, NULL); t != NULL && t != dst.GeometryType {
+				promoted, err := Promote(s, dst.GeometryType)
+				if err != nil {
+					return fmt.Errorf("Unable to merge record %d of source %d: %v", n+1, i+1, err)
+				}
+				s = promoted
+			}
+			row := dst.Write(s)
+			if row < 0 {
+				return fmt.Errorf("Unable to merge record %d of source %d: %v", n+1, i+1, dst.Err())
+			}
+			for field, column := range columns {
+				if column < 0 {
+					continue
+				}
+				if v := src.Attribute(field); v != "" {
+					if err := dst.WriteAttribute(int(row), column, v); err != nil {
+						return fmt.Errorf("Unable to merge record %d of source %d: %v", n+1, i+1, err)
+					}
+				}
+			}
+		}
+		if err := src.Err(); err != nil {
+			return fmt.Errorf("Unable to read source %d: %v", i+1, err)
+		}
+	}
+	return nil
+}
+
+// fieldColumns returns the index in fields of every field of src with the same
+// name, or -1 if there is none.
+func fieldColumns(fields, src []Field) []int {
+	columns := make([]int, len(src))
+	for i, f := range src {
+		columns[i] = fieldIndex(fields, f.String())
+	}
+	return columns
+}
+
+// fieldIndex returns the index of the field called name, ignoring case, or -1.
+func fieldIndex(fields []Field, name string) int {
+	for i, f := range fields {
+		if strings.EqualFold(f.String(), name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// mergeFields adds the fields src to fields, widening the fields that both
+// have.
+func mergeFields(fields, src []Field) []Field {
+	for _, f := range src {
+		i := fieldIndex(fields, f.String())
+		if i < 0 {
+			fields = append(fields, f)
+			continue
+		}
+		fields[i] = widenField(fields[i], f)
+	}
+	return fields
+}
+
+// widenField returns a field that can hold the values of both a and b, which
+// has the name of a.
+func widenField(a, b Field) Field {
+	numeric := func(f Field) bool {
+		return f.Fieldtype == 'N' || f.Fieldtype == 'F'
+	}
+	w := a
+	switch {
+	case numeric(a) && numeric(b):
+		if b.Fieldtype == 'F' {
+			w.Fieldtype = 'F'
+		}
+		digits := maxInt(int(a.Size)-int(a.Precision), int(b.Size)-int(b.Precision))
+		w.Precision = uint8(maxInt(int(a.Precision), int(b.Precision)))
+		w.Size = uint8(minInt(digits+int(w.Precision), 254))
+		return w
+	case a.Fieldtype != b.Fieldtype:
+		w.Fieldtype, w.Precision = 'C', 0
+	}
+	w.Size = uint8(maxInt(int(a.Size), int(b.Size)))
+	return w
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
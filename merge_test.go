@@ -0,0 +1,101 @@
+package shp
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	a, err := Create(filepath.Join(dir, "a.shp"), POINT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.SetFields([]Field{StringField("NAME", 5), NumberField("VAL", 4)})
+	a.Write(&Point{0, 0})
+	a.WriteAttribute(0, 0, "first")
+	a.WriteAttribute(0, 1, 1234)
+	a.Write(&Point{1, 1})
+	a.WriteAttribute(1, 0, "two")
+	a.Close()
+
+	b, err := Create(filepath.Join(dir, "b.shp"), POINTM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.SetFields([]Field{FloatField("val", 8, 2), DateField("DAY"), StringField("NAME", 10)})
+	b.Write(&PointM{5, -2, 3})
+	b.WriteAttribute(0, 0, 12.5)
+	b.WriteAttributeDate(0, 1, 2020, 2, 29)
+	b.WriteAttribute(0, 2, "the third")
+	b.Close()
+
+	var srcs []SequentialReader
+	for _, name := range []string{"a.shp", "b.shp"} {
+		r, err := Open(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+		srcs = append(srcs, r)
+	}
+	merged := filepath.Join(dir, "merged.shp")
+	w, err := Create(merged, POINTM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Merge(w, srcs...); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	r, err := Open(merged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	fields := r.Fields()
+	want := []Field{StringField("NAME", 10), FloatField("VAL", 8, 2), DateField("DAY")}
+	if !reflect.DeepEqual(fields, want) {
+		t.Errorf("got fields %v, want %v", fields, want)
+	}
+	if box := r.BBox(); box != (Box{0, -2, 5, 1}) {
+		t.Errorf("got extent %v", box)
+	}
+	wantRows := [][]string{{"first", "1234", ""}, {"two", "", ""}, {"the third", "12.50", "20200229"}}
+	wantShapes := []Shape{&PointM{0, 0, NoData}, &PointM{1, 1, NoData}, &PointM{5, -2, 3}}
+	for i := 0; r.Next(); i++ {
+		_, s := r.Shape()
+		if !reflect.DeepEqual(s, wantShapes[i]) {
+			t.Errorf("record %d: got %v, want %v", i, s, wantShapes[i])
+		}
+		for j := range fields {
+			if got := r.Attribute(j); got != wantRows[i][j] {
+				t.Errorf("record %d field %d: got %q, want %q", i, j, got, wantRows[i][j])
+			}
+		}
+	}
+
+	// shapes that cannot be promoted are an error
+	src, err := Open(filepath.Join(dir, "b.shp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+	w, err = Create(filepath.Join(dir, "points.shp"), POINT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if err := Merge(w, src); err == nil {
+		t.Error("expected an error for PointM records in a Point file")
+	}
+}
@@ -0,0 +1,138 @@
+package shp
+
+import "math"
+
+// polygonArea returns the area of the rings of a polygon, in which clockwise
+// outer rings add to the area and counterclockwise holes subtract from it.
+func polygonArea(parts []int32, points []Point) float64 {
+	var a float64
+	for _, ring := range splitRings(parts, points) {
+		a -= ringArea(ring)
+	}
+	return a
+}
+
+// Area returns the planar area of the Polygon, which is the area of its
+// clockwise outer rings minus that of its counterclockwise holes.
+func (p *Polygon) Area() float64 {
+	return polygonArea(p.Parts, p.Points)
+}
+
+// Area returns the planar area of the PolygonZ, ignoring the Z values, see
+// Polygon.Area.
+func (p *PolygonZ) Area() float64 {
+	return polygonArea(p.Parts, p.Points)
+}
+
+// Area returns the planar area of the PolygonM, see Polygon.Area.
+func (p *PolygonM) Area() float64 {
+	return polygonArea(p.Parts, p.Points)
+}
+
+// lineLength returns the total length of the parts of a polyline.
+func lineLength(parts []int32, points []Point) float64 {
+	var l float64
+	for _, part := range splitRings(parts, points) {
+		for i := 1; i < len(part); i++ {
+			l += math.Hypot(part[i].X-part[i-1].X, part[i].Y-part[i-1].Y)
+		}
+	}
+	return l
+}
+
+// Length returns the planar length of all parts of the PolyLine.
+func (p *PolyLine) Length() float64 {
+	return lineLength(p.Parts, p.Points)
+}
+
+// Length returns the planar length of all parts of the PolyLineZ, ignoring
+// the Z values.
+func (p *PolyLineZ) Length() float64 {
+	return lineLength(p.Parts, p.Points)
+}
+
+// Length returns the planar length of all parts of the PolyLineM.
+func (p *PolyLineM) Length() float64 {
+	return lineLength(p.Parts, p.Points)
+}
+
+// Centroid returns the planar center of mass of s and false if s is a Null
+// shape or has no points. The centroid of a polygon is weighted by area, with
+// holes subtracted by their orientation, that of a polyline by length and
+// that of points by their number. Degenerate polygons without area fall back
+// to the centroid of their outline and lines without length to that of
+// their points. Z values are ignored.
+func Centroid(s Shape) (Point, bool) {
+	var parts []int32
+	var points []Point
+	base := NULL
+	switch s := s.(type) {
+	case nil, *Null:
+		return Point{}, false
+	case *MultiPatch:
+		points = s.Points
+	default:
+		c := coordsOf(s)
+		parts, points = c.parts, c.points
+		base = shapeDimensions[shapeTypeOf(s, NULL)].base
+	}
+	if len(points) == 0 {
+		return Point{}, false
+	}
+	if base == POLYGON {
+		if c, ok := areaCentroid(splitRings(parts, points)); ok {
+			return c, true
+		}
+	}
+	if base == POLYGON || base == POLYLINE {
+		if c, ok := lineCentroid(splitRings(parts, points)); ok {
+			return c, true
+		}
+	}
+	var c Point
+	for _, p := range points {
+		c.X += p.X
+		c.Y += p.Y
+	}
+	c.X /= float64(len(points))
+	c.Y /= float64(len(points))
+	return c, true
+}
+
+// areaCentroid returns the centroid of the area of rings, in which rings of
+// opposite orientation subtract from each other.
+func areaCentroid(rings [][]Point) (Point, bool) {
+	var a, cx, cy float64
+	for _, ring := range rings {
+		for i := range ring {
+			p, q := ring[i], ring[(i+1)%len(ring)]
+			cross := p.X*q.Y - q.X*p.Y
+			a += cross
+			cx += (p.X + q.X) * cross
+			cy += (p.Y + q.Y) * cross
+		}
+	}
+	if a == 0 {
+		return Point{}, false
+	}
+	return Point{cx / (3 * a), cy / (3 * a)}, true
+}
+
+// lineCentroid returns the centroid of the segments of parts weighted by
+// their length.
+func lineCentroid(parts [][]Point) (Point, bool) {
+	var l, cx, cy float64
+	for _, part := range parts {
+		for i := 1; i < len(part); i++ {
+			p, q := part[i-1], part[i]
+			d := math.Hypot(q.X-p.X, q.Y-p.Y)
+			l += d
+			cx += (p.X + q.X) / 2 * d
+			cy += (p.Y + q.Y) / 2 * d
+		}
+	}
+	if l == 0 {
+		return Point{}, false
+	}
+	return Point{cx / l, cy / l}, true
+}
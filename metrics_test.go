@@ -0,0 +1,52 @@
+package shp
+
+import (
+	"math"
+	"testing"
+)
+
+func TestArea(t *testing.T) {
+	// a 4x4 square with a 2x2 hole
+	p := (*Polygon)(NewPolyLine([][]Point{
+		{{0, 0}, {0, 4}, {4, 4}, {4, 0}, {0, 0}},
+		{{1, 1}, {3, 1}, {3, 3}, {1, 3}, {1, 1}},
+	}))
+	if a := p.Area(); a != 12 {
+		t.Errorf("Area() = %v, want 12", a)
+	}
+	c, ok := Centroid(p)
+	if !ok || c != (Point{2, 2}) {
+		t.Errorf("Centroid() = %v, %v, want {2 2}", c, ok)
+	}
+
+	// an L shape made of a 2x1 and a 1x1 square
+	l := (*Polygon)(NewPolyLine([][]Point{{{0, 0}, {0, 2}, {1, 2}, {1, 1}, {2, 1}, {2, 0}, {0, 0}}}))
+	c, _ = Centroid(l)
+	if math.Abs(c.X-5.0/6) > 1e-12 || math.Abs(c.Y-5.0/6) > 1e-12 {
+		t.Errorf("Centroid() = %v, want {5/6 5/6}", c)
+	}
+}
+
+func TestLength(t *testing.T) {
+	l := NewPolyLine([][]Point{{{0, 0}, {3, 4}}, {{10, 0}, {10, 2}}})
+	if got := l.Length(); got != 7 {
+		t.Errorf("Length() = %v, want 7", got)
+	}
+	c, ok := Centroid(l)
+	if want := (Point{(1.5*5 + 10*2) / 7, (2*5 + 1*2) / 7.0}); !ok || math.Abs(c.X-want.X) > 1e-12 || math.Abs(c.Y-want.Y) > 1e-12 {
+		t.Errorf("Centroid() = %v, want %v", c, want)
+	}
+}
+
+func TestCentroidOfPoints(t *testing.T) {
+	mp := &MultiPoint{Points: []Point{{0, 0}, {2, 0}, {4, 6}}, NumPoints: 3}
+	if c, ok := Centroid(mp); !ok || c != (Point{2, 2}) {
+		t.Errorf("Centroid() = %v, %v, want {2 2}", c, ok)
+	}
+	if c, ok := Centroid(&PointZ{1, 2, 3, 4}); !ok || c != (Point{1, 2}) {
+		t.Errorf("Centroid() = %v, %v, want {1 2}", c, ok)
+	}
+	if _, ok := Centroid(&Null{}); ok {
+		t.Error("Centroid of Null shape succeeded")
+	}
+}
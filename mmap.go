@@ -0,0 +1,71 @@
+package shp
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// mappedFile is a file that is mapped into memory and read from the mapping.
+type mappedFile struct {
+	*bytes.Reader
+	data []byte
+}
+
+// mapFile maps the file at filename into memory.
+func mapFile(filename string) (*mappedFile, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	var data []byte
+	if fi.Size() > 0 {
+		if data, err = mmap(f, int(fi.Size())); err != nil {
+			return nil, fmt.Errorf("Unable to map %s: %v", filename, err)
+		}
+	}
+	return &mappedFile{Reader: bytes.NewReader(data), data: data}, nil
+}
+
+// Close unmaps the file. The data must not be used afterwards.
+func (m *mappedFile) Close() error {
+	if m == nil || m.data == nil {
+		return nil
+	}
+	data := m.data
+	m.data = nil
+	m.Reader = bytes.NewReader(nil)
+	return munmap(data)
+}
+
+// OpenMmap opens a shapefile for reading like Open, but maps the .shp and
+// the .dbf into memory instead of reading them. Records are decoded directly
+// from the mapping, so reading large files needs neither system calls nor
+// buffers for every record. The shapes that are returned do not refer to the
+// mapping and remain valid after Close. On systems without memory mapping,
+// the files are read into memory instead.
+func OpenMmap(filename string, opts ...Option) (*Reader, error) {
+	ext := filepath.Ext(filename)
+	if strings.ToLower(ext) != ".shp" {
+		if err := sniffFile(filename); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("Invalid file extension: %s", filename)
+	}
+	shp, err := mapFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if err := sniffReaderAt(shp, shp.Size()); err != nil {
+		shp.Close()
+		return nil, err
+	}
+	return openReader(&Reader{filename: strings.TrimSuffix(filename, ext), shp: shp, opts: newOptions(opts), mapped: true})
+}
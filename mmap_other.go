@@ -0,0 +1,18 @@
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package shp
+
+import (
+	"io/ioutil"
+	"os"
+)
+
+// mmap reads the file into memory on systems without memory mapping.
+func mmap(f *os.File, size int) ([]byte, error) {
+	return ioutil.ReadAll(f)
+}
+
+func munmap(data []byte) error {
+	return nil
+}
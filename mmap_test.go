@@ -0,0 +1,49 @@
+package shp
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOpenMmap(t *testing.T) {
+	for _, name := range []string{"point", "polyline", "polygonz", "multipatch"} {
+		filename := "test_files/" + name + ".shp"
+		_, shapes := readAll(t, filename)
+		r, err := OpenMmap(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []Shape
+		var attrs [][]string
+		for r.Next() {
+			_, s := r.Shape()
+			got = append(got, s)
+			attrs = append(attrs, Attributes(r))
+		}
+		if err := r.Err(); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+		if err := r.Close(); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+		// the shapes must not refer to the mapping after Close
+		if !reflect.DeepEqual(got, shapes) {
+			t.Errorf("%s: got %v, want %v", name, got, shapes)
+		}
+
+		want, err := Open(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; want.Next(); i++ {
+			if a := Attributes(want); !reflect.DeepEqual(a, attrs[i]) {
+				t.Errorf("%s: record %d: got attributes %v, want %v", name, i, attrs[i], a)
+			}
+		}
+		want.Close()
+	}
+
+	if _, err := OpenMmap("test_files/missing.shp"); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
@@ -0,0 +1,367 @@
+package shp
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// These are the types of the parts of a MultiPatch.
+const (
+	TriangleStrip int32 = 0
+	TriangleFan   int32 = 1
+	OuterRing     int32 = 2
+	InnerRing     int32 = 3
+	FirstRing     int32 = 4
+	Ring          int32 = 5
+)
+
+// ringArea returns the signed area of the ring, which is positive if the ring
+// is counterclockwise and negative if it is clockwise.
+func ringArea(ring []Point) float64 {
+	var a float64
+	for i := 0; i+1 < len(ring); i++ {
+		a += ring[i].X*ring[i+1].Y - ring[i+1].X*ring[i].Y
+	}
+	if n := len(ring); n > 0 && ring[0] != ring[n-1] {
+		a += ring[n-1].X*ring[0].Y - ring[0].X*ring[n-1].Y
+	}
+	return a / 2
+}
+
+// orientRing returns ring closed and oriented clockwise, or counterclockwise
+// if ccw is set.
+func orientRing(ring []Point, ccw bool) []Point {
+	r := append([]Point(nil), ring...)
+	if len(r) > 0 && r[0] != r[len(r)-1] {
+		r = append(r, r[0])
+	}
+	if (ringArea(r) > 0) != ccw {
+		for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+			r[i], r[j] = r[j], r[i]
+		}
+	}
+	return r
+}
+
+// sameRing reports whether the closed rings a and b consist of the same
+// vertices in the same order, possibly starting at a different vertex.
+func sameRing(a, b []Point) bool {
+	if len(a) != len(b) || len(a) < 2 {
+		return false
+	}
+	n := len(a) - 1
+	for offset := 0; offset < n; offset++ {
+		same := true
+		for i := 0; i < n && same; i++ {
+			same = a[i] == b[(i+offset)%n]
+		}
+		if same {
+			return true
+		}
+	}
+	return false
+}
+
+// onRing reports whether p lies on an edge of the closed ring.
+func onRing(p Point, ring []Point) bool {
+	for i := 0; i+1 < len(ring); i++ {
+		a, b := ring[i], ring[i+1]
+		if (b.X-a.X)*(p.Y-a.Y) != (b.Y-a.Y)*(p.X-a.X) {
+			continue
+		}
+		if p.X >= math.Min(a.X, b.X) && p.X <= math.Max(a.X, b.X) &&
+			p.Y >= math.Min(a.Y, b.Y) && p.Y <= math.Max(a.Y, b.Y) {
+			return true
+		}
+	}
+	return false
+}
+
+// edgeMidpoints cuts the edges of the closed ring where they meet the edges
+// of other and returns the midpoints of the pieces. Every piece lies either
+// inside, outside or on the boundary of other, so its midpoint tells which.
+func edgeMidpoints(ring, other []Point) []Point {
+	cross := func(ax, ay, bx, by float64) float64 { return ax*by - ay*bx }
+	var r []Point
+	for i := 0; i+1 < len(ring); i++ {
+		a, b := ring[i], ring[i+1]
+		dx, dy := b.X-a.X, b.Y-a.Y
+		cuts := []float64{0, 1}
+		for j := 0; j+1 < len(other); j++ {
+			c, d := other[j], other[j+1]
+			ex, ey := d.X-c.X, d.Y-c.Y
+			cx, cy := c.X-a.X, c.Y-a.Y
+			if den := cross(dx, dy, ex, ey); den != 0 {
+				t, u := cross(cx, cy, ex, ey)/den, cross(cx, cy, dx, dy)/den
+				if t > 0 && t < 1 && u >= 0 && u <= 1 {
+					cuts = append(cuts, t)
+				}
+			} else if cross(cx, cy, dx, dy) == 0 {
+				// collinear edges are cut where the other edge starts and ends
+				for _, q := range []Point{c, d} {
+					if t := ((q.X-a.X)*dx + (q.Y-a.Y)*dy) / (dx*dx + dy*dy); t > 0 && t < 1 {
+						cuts = append(cuts, t)
+					}
+				}
+			}
+		}
+		sort.Float64s(cuts)
+		for k := 1; k < len(cuts); k++ {
+			if cuts[k] > cuts[k-1] {
+				t := (cuts[k-1] + cuts[k]) / 2
+				r = append(r, Point{a.X + t*dx, a.Y + t*dy})
+			}
+		}
+	}
+	return r
+}
+
+// footprint holds the rings of MultiPatchToPolygon while they are combined.
+type footprint struct {
+	rings [][]Point
+	holes []bool
+	parts []int
+}
+
+// strictlyInside reports whether p lies inside the exterior ring and not
+// inside or on any of the holes of f.
+func (f *footprint) strictlyInside(p Point, ring []Point) bool {
+	return pointInRing(p, ring) && !onRing(p, ring) && !f.inHole(p, true)
+}
+
+// within reports whether p lies inside or on the exterior ring and not inside
+// or on any of the holes of f.
+func (f *footprint) within(p Point, ring []Point) bool {
+	return (onRing(p, ring) || pointInRing(p, ring)) && !f.inHole(p, true)
+}
+
+// inHole reports whether p lies inside one of the holes of f, or on one of
+// them if boundary is set.
+func (f *footprint) inHole(p Point, boundary bool) bool {
+	for i, h := range f.rings {
+		if f.holes[i] && (boundary && onRing(p, h) || pointInRing(p, h) && !onRing(p, h)) {
+			return true
+		}
+	}
+	return false
+}
+
+// covers reports whether the exterior ring a covers the exterior ring b, so
+// that b can be dropped. An error is returned if b overlaps a hole that a
+// covers, since dropping b would then lose the part of b over the hole.
+func (f *footprint) covers(a, b []Point) (bool, error) {
+	for _, p := range edgeMidpoints(b, a) {
+		if !f.within(p, a) {
+			return false, nil
+		}
+	}
+	for i, h := range f.rings {
+		if !f.holes[i] {
+			continue
+		}
+		for _, p := range edgeMidpoints(h, b) {
+			if pointInRing(p, b) && !onRing(p, b) {
+				return false, errors.New("overlaps a hole")
+			}
+		}
+	}
+	return true, nil
+}
+
+// overlap reports whether the interiors of the exterior rings a and b
+// overlap outside of the holes of f.
+func (f *footprint) overlap(a, b []Point) bool {
+	for _, p := range edgeMidpoints(a, b) {
+		if f.strictlyInside(p, b) {
+			return true
+		}
+	}
+	for _, p := range edgeMidpoints(b, a) {
+		if f.strictlyInside(p, a) {
+			return true
+		}
+	}
+	return false
+}
+
+// dissolve drops the exterior rings of f that are covered by another one. It
+// returns an error if exterior rings overlap only partly, since their union
+// is not computed.
+func (f *footprint) dissolve() error {
+	dropped := make([]bool, len(f.rings))
+	for i, a := range f.rings {
+		for j := i + 1; j < len(f.rings) && !dropped[i]; j++ {
+			b := f.rings[j]
+			if f.holes[i] || f.holes[j] || dropped[j] {
+				continue
+			}
+			if ok, err := f.covers(a, b); err != nil {
+				return fmt.Errorf("MultiPatch part %d %v of part %d", f.parts[j], err, f.parts[i])
+			} else if ok {
+				dropped[j] = true
+				continue
+			}
+			if ok, err := f.covers(b, a); err != nil {
+				return fmt.Errorf("MultiPatch part %d %v of part %d", f.parts[i], err, f.parts[j])
+			} else if ok {
+				dropped[i] = true
+				continue
+			}
+			if f.overlap(a, b) {
+				return fmt.Errorf("MultiPatch parts %d and %d overlap partly", f.parts[i], f.parts[j])
+			}
+		}
+	}
+	var rings [][]Point
+	for i, r := range f.rings {
+		if !dropped[i] {
+			rings = append(rings, r)
+		}
+	}
+	f.rings = rings
+	return nil
+}
+
+// polygonFromRings returns a Polygon with the given rings as parts.
+func polygonFromRings(rings [][]Point) *Polygon {
+	return (*Polygon)(NewPolyLine(rings))
+}
+
+// stripOutline returns the outline of a triangle strip: every other vertex
+// forwards, the remaining ones backwards.
+func stripOutline(points []Point) []Point {
+	var r []Point
+	for i := 0; i < len(points); i += 2 {
+		r = append(r, points[i])
+	}
+	start := len(points) - 1
+	if start%2 == 0 {
+		start--
+	}
+	for i := start; i > 0; i -= 2 {
+		r = append(r, points[i])
+	}
+	return r
+}
+
+// ApproximatedParts returns the indices of the parts of mp whose footprint is
+// only approximated by MultiPatchToPolygon, i.e. triangle strips and fans.
+func ApproximatedParts(mp *MultiPatch) []int {
+	var r []int
+	for i, t := range mp.PartTypes {
+		if t == TriangleStrip || t == TriangleFan {
+			r = append(r, i)
+		}
+	}
+	return r
+}
+
+// MultiPatchToPolygon returns the 2D footprint of mp as a Polygon. Ring parts
+// are converted directly: outer and first rings become exterior rings, inner
+// rings and rings that follow a first ring become holes. Triangle strips and
+// fans are replaced by their outline, which is exact for planar, non
+// self-overlapping triangulations; ApproximatedParts reports these parts. Z
+// and M values are dropped, rings without area (e.g. vertical walls) and
+// duplicate rings (e.g. a roof above its floor) are omitted, and all rings
+// are oriented as required by the specification. The footprint of a part
+// that lies within the footprint of another one, e.g. a roof within the
+// outline of its floor, is dropped. The union of parts that overlap only
+// partly is not computed; an error is returned for them instead.
+func MultiPatchToPolygon(mp *MultiPatch) (*Polygon, error) {
+	if mp == nil || len(mp.Parts) == 0 {
+		return nil, errors.New("MultiPatch has no parts")
+	}
+	if len(mp.PartTypes) != len(mp.Parts) {
+		return nil, fmt.Errorf("MultiPatch has %d parts but %d part types", len(mp.Parts), len(mp.PartTypes))
+	}
+	var f footprint
+	var part int
+	add := func(ring []Point, hole bool) {
+		r := orientRing(ring, hole)
+		if len(r) < 4 || ringArea(r) == 0 {
+			return
+		}
+		for _, other := range f.rings {
+			if sameRing(r, other) {
+				return
+			}
+		}
+		f.rings = append(f.rings, r)
+		f.holes = append(f.holes, hole)
+		f.parts = append(f.parts, part)
+	}
+
+	inFirst := false
+	for i, pr := range partRanges(mp.Parts, len(mp.Points)) {
+		if pr[0] < 0 || pr[0] > pr[1] || pr[1] > len(mp.Points) {
+			return nil, fmt.Errorf("MultiPatch part %d is out of range", i)
+		}
+		points := mp.Points[pr[0]:pr[1]]
+		part = i
+		switch t := mp.PartTypes[i]; t {
+		case TriangleStrip:
+			inFirst = false
+			add(stripOutline(points), false)
+		case TriangleFan:
+			inFirst = false
+			add(points, false)
+		case OuterRing:
+			inFirst = false
+			add(points, false)
+		case FirstRing:
+			inFirst = true
+			add(points, false)
+		case InnerRing:
+			add(points, true)
+		case Ring:
+			add(points, inFirst)
+		default:
+			return nil, fmt.Errorf("MultiPatch part %d has unknown type %d", i, t)
+		}
+	}
+	if len(f.rings) == 0 {
+		return nil, errors.New("MultiPatch has no footprint")
+	}
+	if err := f.dissolve(); err != nil {
+		return nil, err
+	}
+	return polygonFromRings(f.rings), nil
+}
+
+// PolygonToMultiPatch returns a MultiPatch with the rings of p as outer and
+// inner rings, depending on their orientation. The Z value of every vertex is
+// taken from z, or 0 if z is nil, and all measures are NoData. It is the inverse of MultiPatchToPolygon
+// for footprints that consist of rings only.
+func PolygonToMultiPatch(p *Polygon, z func(x, y float64) float64) *MultiPatch {
+	mp := &MultiPatch{
+		Box:       BBoxFromPoints(p.Points),
+		NumParts:  int32(len(p.Parts)),
+		NumPoints: int32(len(p.Points)),
+		Parts:     append([]int32(nil), p.Parts...),
+		PartTypes: make([]int32, len(p.Parts)),
+		Points:    append([]Point(nil), p.Points...),
+		ZArray:    make([]float64, len(p.Points)),
+		MArray:    nodataArray(len(p.Points)),
+	}
+	mp.MRange = measureRange(mp.MArray)
+	for i, pr := range partRanges(p.Parts, len(p.Points)) {
+		mp.PartTypes[i] = OuterRing
+		if ringArea(p.Points[pr[0]:pr[1]]) > 0 {
+			mp.PartTypes[i] = InnerRing
+		}
+	}
+	for i, pt := range mp.Points {
+		if z != nil {
+			mp.ZArray[i] = z(pt.X, pt.Y)
+		}
+		if i == 0 || mp.ZArray[i] < mp.ZRange[0] {
+			mp.ZRange[0] = mp.ZArray[i]
+		}
+		if i == 0 || mp.ZArray[i] > mp.ZRange[1] {
+			mp.ZRange[1] = mp.ZArray[i]
+		}
+	}
+	return mp
+}
@@ -0,0 +1,205 @@
+package shp
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMultiPatchToPolygonCube(t *testing.T) {
+	shapes := getShapesFromFile("test_files/multipatch", t)
+	p, err := MultiPatchToPolygon(shapes[0].(*MultiPatch))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.NumParts != 1 {
+		t.Fatalf("got %d parts, want the single floor ring: %+v", p.NumParts, p)
+	}
+	if a := ringArea(p.Points); a != -100 {
+		t.Errorf("got signed area %v, want -100", a)
+	}
+	if p.Box != (Box{0, 0, 10, 10}) {
+		t.Errorf("got box %v", p.Box)
+	}
+}
+
+func TestMultiPatchToPolygonTriangles(t *testing.T) {
+	mp := &MultiPatch{
+		NumParts:  2,
+		NumPoints: 9,
+		Parts:     []int32{0, 4},
+		PartTypes: []int32{TriangleStrip, TriangleFan},
+		Points: []Point{
+			{0, 0}, {0, 1}, {1, 0}, {1, 1},
+			{5, 5}, {6, 5}, {7, 6}, {6, 7}, {5, 6},
+		},
+	}
+	p, err := MultiPatchToPolygon(mp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.NumParts != 2 {
+		t.Fatalf("got %d parts, want 2", p.NumParts)
+	}
+	for i, pr := range partRanges(p.Parts, len(p.Points)) {
+		if a := ringArea(p.Points[pr[0]:pr[1]]); a >= 0 {
+			t.Errorf("ring %d has area %v, want clockwise ring", i, a)
+		}
+	}
+	if a := ringArea(p.Points[:p.Parts[1]]); a != -1 {
+		t.Errorf("got strip outline area %v, want -1", a)
+	}
+	if got := ApproximatedParts(mp); !reflect.DeepEqual(got, []int{0, 1}) {
+		t.Errorf("got approximated parts %v, want [0 1]", got)
+	}
+}
+
+func TestMultiPatchToPolygonErrors(t *testing.T) {
+	tests := []*MultiPatch{
+		nil,
+		{},
+		{Parts: []int32{0}, PartTypes: []int32{42}, Points: []Point{{0, 0}, {1, 0}, {1, 1}, {0, 0}}},
+		{Parts: []int32{0}, PartTypes: []int32{OuterRing}, Points: []Point{{0, 0}, {1, 1}, {0, 0}}},
+		{Parts: []int32{0, 1}, PartTypes: []int32{OuterRing}, Points: []Point{{0, 0}}},
+	}
+	for i, mp := range tests {
+		if _, err := MultiPatchToPolygon(mp); err == nil {
+			t.Errorf("%d: converted invalid MultiPatch without error", i)
+		}
+	}
+}
+
+// ringPatch returns a MultiPatch with the given rings as parts of the given
+// types.
+func ringPatch(types []int32, rings ...[]Point) *MultiPatch {
+	mp := &MultiPatch{PartTypes: types}
+	for _, r := range rings {
+		mp.Parts = append(mp.Parts, int32(len(mp.Points)))
+		mp.Points = append(mp.Points, r...)
+	}
+	mp.NumParts, mp.NumPoints = int32(len(mp.Parts)), int32(len(mp.Points))
+	return mp
+}
+
+func square(x0, y0, x1, y1 float64) []Point {
+	return []Point{{x0, y0}, {x0, y1}, {x1, y1}, {x1, y0}, {x0, y0}}
+}
+
+func TestMultiPatchToPolygonOverlap(t *testing.T) {
+	tests := []struct {
+		name  string
+		mp    *MultiPatch
+		parts int32
+	}{
+		{"roof within floor", ringPatch([]int32{OuterRing, OuterRing}, square(0, 0, 10, 10), square(2, 2, 8, 8)), 1},
+		{"floor within roof", ringPatch([]int32{OuterRing, OuterRing}, square(2, 2, 8, 8), square(0, 0, 10, 10)), 1},
+		{"same outline", ringPatch([]int32{OuterRing, OuterRing}, square(0, 0, 2, 2), []Point{{0, 0}, {0, 1}, {0, 2}, {2, 2}, {2, 0}, {0, 0}}), 1},
+		{"touching", ringPatch([]int32{OuterRing, OuterRing}, square(0, 0, 1, 1), square(1, 0, 2, 1)), 2},
+		{"island in hole", ringPatch([]int32{OuterRing, InnerRing, OuterRing}, square(0, 0, 10, 10), square(2, 2, 8, 8), square(4, 4, 6, 6)), 3},
+		{"partly overlapping", ringPatch([]int32{OuterRing, OuterRing}, square(0, 0, 2, 2), square(1, 0, 3, 2)), 0},
+		{"crossing", ringPatch([]int32{OuterRing, OuterRing}, square(0, 1, 3, 2), square(1, 0, 2, 3)), 0},
+		{"over a hole", ringPatch([]int32{OuterRing, InnerRing, OuterRing}, square(0, 0, 10, 10), square(2, 2, 8, 8), square(1, 1, 5, 5)), 0},
+		{"covering a hole", ringPatch([]int32{OuterRing, InnerRing, OuterRing}, square(0, 0, 10, 10), square(4, 4, 6, 6), square(2, 2, 8, 8)), 0},
+	}
+	for _, test := range tests {
+		p, err := MultiPatchToPolygon(test.mp)
+		if test.parts == 0 {
+			if err == nil {
+				t.Errorf("%s: got %+v, want error", test.name, p)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		} else if p.NumParts != test.parts {
+			t.Errorf("%s: got %d parts, want %d", test.name, p.NumParts, test.parts)
+		}
+	}
+}
+
+func TestPolygonToMultiPatchRoundTrip(t *testing.T) {
+	p, err := NewPolygon([][]Point{
+		{{0, 0}, {0, 10}, {10, 10}, {10, 0}},
+		{{2, 2}, {4, 2}, {4, 4}, {2, 4}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mp := PolygonToMultiPatch(p, func(x, y float64) float64 { return math.Hypot(x, y) })
+	if !reflect.DeepEqual(mp.PartTypes, []int32{OuterRing, InnerRing}) {
+		t.Errorf("got part types %v", mp.PartTypes)
+	}
+	if mp.ZRange != [2]float64{0, math.Hypot(10, 10)} {
+		t.Errorf("got z range %v", mp.ZRange)
+	}
+	for i, m := range mp.MArray {
+		if m != NoData {
+			t.Fatalf("measure %d is %v, want NoData", i, m)
+		}
+	}
+	if mp.MRange != [2]float64{NoData, NoData} {
+		t.Errorf("got m range %v", mp.MRange)
+	}
+	back, err := MultiPatchToPolygon(mp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(back, p) {
+		t.Errorf("got %+v, want %+v", back, p)
+	}
+}
+
+func TestNewMultiPatch(t *testing.T) {
+	// a roof made of a triangle fan on top of an open outer ring
+	parts := [][]Point{
+		{{0, 0}, {10, 0}, {10, 10}, {0, 10}},
+		{{5, 5}, {0, 0}, {10, 0}, {10, 10}, {0, 10}, {0, 0}},
+	}
+	z := [][]float64{{0, 0, 0, 0}, {8, 5, 5, 5, 5, 5}}
+	mp, err := NewMultiPatch(parts, []int32{OuterRing, TriangleFan}, z, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mp.NumParts != 2 || mp.NumPoints != 11 || !reflect.DeepEqual(mp.Parts, []int32{0, 5}) {
+		t.Errorf("got %d parts at %v with %d points", mp.NumParts, mp.Parts, mp.NumPoints)
+	}
+	if mp.Points[4] != (Point{0, 0}) || mp.ZArray[4] != 0 {
+		t.Errorf("ring was not closed: %v", mp.Points[:5])
+	}
+	if mp.Box != (Box{0, 0, 10, 10}) || mp.ZRange != [2]float64{0, 8} {
+		t.Errorf("got box %v and Z range %v", mp.Box, mp.ZRange)
+	}
+
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "roof.shp")
+	w, err := Create(filename, MULTIPATCH)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(mp)
+	w.Close()
+	if typ, shapes := readAll(t, filename); typ != MULTIPATCH || !reflect.DeepEqual(shapes, []Shape{mp}) {
+		t.Errorf("got %v %v, want %v", typ, shapes, mp)
+	}
+
+	for _, test := range []struct {
+		types []int32
+		z     [][]float64
+	}{
+		{[]int32{OuterRing}, z},
+		{[]int32{OuterRing, 9}, z},
+		{[]int32{OuterRing, TriangleFan}, nil},
+		{[]int32{OuterRing, TriangleFan}, [][]float64{{0}, {0}}},
+	} {
+		if _, err := NewMultiPatch(parts, test.types, test.z, nil); err == nil {
+			t.Errorf("expected an error for types %v and Z values %v", test.types, test.z)
+		}
+	}
+}
@@ -0,0 +1,358 @@
+package mvt
+
+import (
+	"math"
+
+	shp "github.com/silbinarywolf/go-shp"
+)
+
+// tiler projects, clips and encodes shapes for a tile.
+type tiler struct {
+	opts Options
+	// minX and maxY are the Web Mercator coordinates of the top left corner
+	// of the tile and scale the number of units per metre.
+	minX, maxY, scale float64
+	// clip is the box of the tile and its buffer in units.
+	clip shp.Box
+}
+
+// project returns p in the units of the tile, with Y growing downwards.
+func (t *tiler) project(p shp.Point) shp.Point {
+	x, y := p.X, p.Y
+	if !t.opts.WebMercator {
+		lat := math.Max(-maxLatitude, math.Min(maxLatitude, y))
+		x = x * math.Pi / 180 * earthRadius
+		y = math.Log(math.Tan(math.Pi/4+lat*math.Pi/360)) * earthRadius
+	}
+	return shp.Point{X: (x - t.minX) * t.scale, Y: (t.maxY - y) * t.scale}
+}
+
+// projectAll returns the points in the units of the tile.
+func (t *tiler) projectAll(points []shp.Point) []shp.Point {
+	projected := make([]shp.Point, len(points))
+	for i, p := range points {
+		projected[i] = t.project(p)
+	}
+	return projected
+}
+
+// encode returns the geometry type and the encoded geometry of s within the
+// tile, which is nil if nothing of s lies in it.
+func (t *tiler) encode(s shp.Shape) (uint64, []uint64) {
+	kind, parts, points := shapeParts(s)
+	if kind == 0 || len(points) == 0 {
+		return 0, nil
+	}
+	box := s.BBox()
+	min, max := t.project(shp.Point{X: box.MinX, Y: box.MaxY}), t.project(shp.Point{X: box.MaxX, Y: box.MinY})
+	if !t.clip.Intersects(shp.Box{MinX: min.X, MinY: min.Y, MaxX: max.X, MaxY: max.Y}) {
+		return 0, nil
+	}
+
+	e := &geomEncoder{}
+	switch kind {
+	case typePoint:
+		var kept []tilePoint
+		for _, p := range t.projectAll(points) {
+			if t.clip.ContainsPoint(p) {
+				kept = append(kept, quantize(p))
+			}
+		}
+		e.moveTo(kept)
+	case typeLineString:
+		for _, part := range splitParts(parts, points) {
+			for _, line := range clipLine(t.projectAll(part), t.clip) {
+				if q := quantizeAll(line); len(q) >= 2 {
+					e.moveTo(q[:1])
+					e.lineTo(q[1:])
+				}
+			}
+		}
+	case typePolygon:
+		rings := splitParts(parts, points)
+		for _, i := range ringOrder(rings) {
+			hole := i < 0
+			if hole {
+				i = -i - 1
+			}
+			ring := quantizeAll(clipRing(t.projectAll(rings[i]), t.clip))
+			if len(ring) > 1 && ring[0] == ring[len(ring)-1] {
+				ring = ring[:len(ring)-1]
+			}
+			area := ringArea(ring)
+			if len(ring) < 3 || area == 0 {
+				continue
+			}
+			// exterior rings have a positive area in tile units
+			if (area < 0) != hole {
+				for i, j := 0, len(ring)-1; i < j; i, j = i+1, j-1 {
+					ring[i], ring[j] = ring[j], ring[i]
+				}
+			}
+			e.moveTo(ring[:1])
+			e.lineTo(ring[1:])
+			e.closePath()
+		}
+	}
+	return kind, e.cmds
+}
+
+// shapeParts returns the geometry type of features for s along with its
+// parts and points, or 0 if s cannot be encoded.
+func shapeParts(s shp.Shape) (kind uint64, parts []int32, points []shp.Point) {
+	switch s := s.(type) {
+	case *shp.Point:
+		return typePoint, nil, []shp.Point{*s}
+	case *shp.PointZ:
+		return typePoint, nil, []shp.Point{{X: s.X, Y: s.Y}}
+	case *shp.PointM:
+		return typePoint, nil, []shp.Point{{X: s.X, Y: s.Y}}
+	case *shp.MultiPoint:
+		return typePoint, nil, s.Points
+	case *shp.MultiPointZ:
+		return typePoint, nil, s.Points
+	case *shp.MultiPointM:
+		return typePoint, nil, s.Points
+	case *shp.PolyLine:
+		return typeLineString, s.Parts, s.Points
+	case *shp.PolyLineZ:
+		return typeLineString, s.Parts, s.Points
+	case *shp.PolyLineM:
+		return typeLineString, s.Parts, s.Points
+	case *shp.Polygon:
+		return typePolygon, s.Parts, s.Points
+	case *shp.PolygonZ:
+		return typePolygon, s.Parts, s.Points
+	case *shp.PolygonM:
+		return typePolygon, s.Parts, s.Points
+	}
+	return 0, nil, nil
+}
+
+// splitParts returns the points of every part.
+func splitParts(parts []int32, points []shp.Point) [][]shp.Point {
+	var split [][]shp.Point
+	for i, start := range parts {
+		end := int32(len(points))
+		if i+1 < len(parts) {
+			end = parts[i+1]
+		}
+		if start < 0 || start > end || int(end) > len(points) {
+			return split
+		}
+		split = append(split, points[start:end])
+	}
+	return split
+}
+
+// ringOrder returns the order in which the rings of a polygon are encoded:
+// every outer ring is followed by the holes inside of it. Holes, which lie
+// inside an odd number of other rings, are returned as -i-1.
+func ringOrder(rings [][]shp.Point) []int {
+	parent := make([]int, len(rings))
+	for i, ring := range rings {
+		parent[i] = -1
+		depth := 0
+		for j, other := range rings {
+			if i != j && len(ring) > 0 && pointInRing(ring[0], other) {
+				depth++
+				if parent[i] < 0 {
+					parent[i] = j
+				}
+			}
+		}
+		if depth%2 == 0 {
+			parent[i] = -1
+		}
+	}
+	var order []int
+	for i := range rings {
+		if parent[i] >= 0 {
+			continue
+		}
+		order = append(order, i)
+		for j := range rings {
+			if parent[j] == i {
+				order = append(order, -j-1)
+			}
+		}
+	}
+	return order
+}
+
+// pointInRing reports whether p lies inside ring using the even-odd rule.
+func pointInRing(p shp.Point, ring []shp.Point) bool {
+	in := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[i], ring[j]
+		if (a.Y > p.Y) != (b.Y > p.Y) && p.X < (b.X-a.X)*(p.Y-a.Y)/(b.Y-a.Y)+a.X {
+			in = !in
+		}
+	}
+	return in
+}
+
+// clipLine returns the pieces of line that lie inside of box.
+func clipLine(line []shp.Point, box shp.Box) [][]shp.Point {
+	var lines [][]shp.Point
+	var current []shp.Point
+	for i := 1; i < len(line); i++ {
+		a, b, ok := clipSegment(line[i-1], line[i], box)
+		if !ok {
+			continue
+		}
+		if len(current) == 0 || current[len(current)-1] != a {
+			if len(current) >= 2 {
+				lines = append(lines, current)
+			}
+			current = []shp.Point{a}
+		}
+		current = append(current, b)
+	}
+	if len(current) >= 2 {
+		lines = append(lines, current)
+	}
+	return lines
+}
+
+// clipSegment returns the part of the segment from a to b inside of box with
+// the Liang-Barsky algorithm and false if there is none.
+func clipSegment(a, b shp.Point, box shp.Box) (shp.Point, shp.Point, bool) {
+	t0, t1 := 0.0, 1.0
+	dx, dy := b.X-a.X, b.Y-a.Y
+	for _, edge := range [4][2]float64{
+		{-dx, a.X - box.MinX},
+		{dx, box.MaxX - a.X},
+		{-dy, a.Y - box.MinY},
+		{dy, box.MaxY - a.Y},
+	} {
+		p, q := edge[0], edge[1]
+		if p == 0 {
+			if q < 0 {
+				return a, b, false
+			}
+			continue
+		}
+		r := q / p
+		if p < 0 && r > t0 {
+			t0 = r
+		} else if p > 0 && r < t1 {
+			t1 = r
+		}
+		if t0 > t1 {
+			return a, b, false
+		}
+	}
+	clipped := func(t float64) shp.Point {
+		return shp.Point{X: a.X + t*dx, Y: a.Y + t*dy}
+	}
+	return clipped(t0), clipped(t1), true
+}
+
+// clipRing returns the part of the ring inside of box with the
+// Sutherland-Hodgman algorithm. The result is closed unless it is empty.
+func clipRing(ring []shp.Point, box shp.Box) []shp.Point {
+	if len(ring) > 1 && ring[0] == ring[len(ring)-1] {
+		ring = ring[:len(ring)-1]
+	}
+	edges := []struct {
+		inside    func(p shp.Point) bool
+		intersect func(a, b shp.Point) shp.Point
+	}{
+		{func(p shp.Point) bool { return p.X >= box.MinX }, func(a, b shp.Point) shp.Point { return atX(a, b, box.MinX) }},
+		{func(p shp.Point) bool { return p.X <= box.MaxX }, func(a, b shp.Point) shp.Point { return atX(a, b, box.MaxX) }},
+		{func(p shp.Point) bool { return p.Y >= box.MinY }, func(a, b shp.Point) shp.Point { return atY(a, b, box.MinY) }},
+		{func(p shp.Point) bool { return p.Y <= box.MaxY }, func(a, b shp.Point) shp.Point { return atY(a, b, box.MaxY) }},
+	}
+	for _, edge := range edges {
+		var out []shp.Point
+		for i, b := range ring {
+			a := ring[(i+len(ring)-1)%len(ring)]
+			switch {
+			case edge.inside(b):
+				if !edge.inside(a) {
+					out = append(out, edge.intersect(a, b))
+				}
+				out = append(out, b)
+			case edge.inside(a):
+				out = append(out, edge.intersect(a, b))
+			}
+		}
+		if ring = out; len(ring) == 0 {
+			return nil
+		}
+	}
+	return append(ring, ring[0])
+}
+
+// atX returns the point of the line through a and b at x.
+func atX(a, b shp.Point, x float64) shp.Point {
+	return shp.Point{X: x, Y: a.Y + (b.Y-a.Y)*(x-a.X)/(b.X-a.X)}
+}
+
+// atY returns the point of the line through a and b at y.
+func atY(a, b shp.Point, y float64) shp.Point {
+	return shp.Point{X: a.X + (b.X-a.X)*(y-a.Y)/(b.Y-a.Y), Y: y}
+}
+
+// tilePoint is a point in integer units of the tile.
+type tilePoint struct {
+	x, y int64
+}
+
+func quantize(p shp.Point) tilePoint {
+	return tilePoint{int64(math.Round(p.X)), int64(math.Round(p.Y))}
+}
+
+// quantizeAll quantizes points and drops the ones that repeat the point
+// before them.
+func quantizeAll(points []shp.Point) []tilePoint {
+	var q []tilePoint
+	for _, p := range points {
+		if t := quantize(p); len(q) == 0 || q[len(q)-1] != t {
+			q = append(q, t)
+		}
+	}
+	return q
+}
+
+// ringArea returns twice the signed area of the ring, which is positive for
+// rings that are clockwise with Y growing downwards.
+func ringArea(ring []tilePoint) int64 {
+	var a int64
+	for i, p := range ring {
+		q := ring[(i+1)%len(ring)]
+		a += p.x*q.y - q.x*p.y
+	}
+	return a
+}
+
+// geomEncoder encodes the commands of a geometry, whose coordinates are
+// relative to the position that the previous command moved to.
+type geomEncoder struct {
+	cmds []uint64
+	x, y int64
+}
+
+func (e *geomEncoder) command(id int, points []tilePoint) {
+	if len(points) == 0 {
+		return
+	}
+	e.cmds = append(e.cmds, uint64(id)|uint64(len(points))<<3)
+	for _, p := range points {
+		e.cmds = append(e.cmds, zigzag(p.x-e.x), zigzag(p.y-e.y))
+		e.x, e.y = p.x, p.y
+	}
+}
+
+func (e *geomEncoder) moveTo(points []tilePoint) {
+	e.command(cmdMoveTo, points)
+}
+
+func (e *geomEncoder) lineTo(points []tilePoint) {
+	e.command(cmdLineTo, points)
+}
+
+func (e *geomEncoder) closePath() {
+	e.cmds = append(e.cmds, cmdClosePath|1<<3)
+}
@@ -0,0 +1,156 @@
+// Package mvt encodes the records of shapefiles as layers of Mapbox Vector
+// Tiles, so that shapefiles can be served as vector tiles directly.
+//
+// Shapes are projected to Web Mercator, clipped to the tile with a buffer,
+// quantized to the extent of the tile and encoded along with their attributes
+// in the protocol buffer format of version 2.1 of the specification.
+package mvt
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	shp "github.com/silbinarywolf/go-shp"
+)
+
+// Options configures EncodeLayer.
+type Options struct {
+	// Extent is the number of units along each side of the tile. It is 4096
+	// if it is zero.
+	Extent uint32
+	// Buffer is the number of units outside of the tile on each side that
+	// shapes are kept in, so that lines and polygons join seamlessly with
+	// those of neighbouring tiles.
+	Buffer uint32
+	// WebMercator states that the coordinates of the shapefile are in Web
+	// Mercator (EPSG:3857) already. They are taken to be longitudes and
+	// latitudes in WGS 84 otherwise.
+	WebMercator bool
+}
+
+// earthRadius is the radius of the sphere of Web Mercator in metres.
+const earthRadius = 6378137
+
+// maxLatitude is the latitude at which Web Mercator is cut off.
+const maxLatitude = 85.05112877980659
+
+// These are the geometry types of features.
+const (
+	typePoint      = 1
+	typeLineString = 2
+	typePolygon    = 3
+)
+
+// These are the commands of encoded geometries.
+const (
+	cmdMoveTo    = 1
+	cmdLineTo    = 2
+	cmdClosePath = 7
+)
+
+// EncodeLayer reads all records of r and returns the encoded vector tile at
+// zoom z, column x and row y with a single layer called name, which holds the
+// records that intersect the tile. The id of every feature is the index of
+// its record and its tags are the attributes of the record that are not NULL.
+// Since tiles are sequences of layers, the results of several calls can be
+// concatenated into a tile with several layers. MultiPatches are skipped.
+func EncodeLayer(r shp.SequentialReader, name string, z, x, y int, opts Options) ([]byte, error) {
+	if z < 0 || z > 30 || x < 0 || y < 0 || x >= 1<<uint(z) || y >= 1<<uint(z) {
+		return nil, fmt.Errorf("Invalid tile %d/%d/%d", z, x, y)
+	}
+	if opts.Extent == 0 {
+		opts.Extent = 4096
+	}
+	size := 2 * math.Pi * earthRadius / float64(int(1)<<uint(z))
+	t := &tiler{
+		opts:  opts,
+		minX:  -math.Pi*earthRadius + float64(x)*size,
+		maxY:  math.Pi*earthRadius - float64(y)*size,
+		scale: float64(opts.Extent) / size,
+	}
+	buffer := float64(opts.Buffer)
+	t.clip = shp.Box{MinX: -buffer, MinY: -buffer, MaxX: float64(opts.Extent) + buffer, MaxY: float64(opts.Extent) + buffer}
+
+	var layer []byte
+	layer = appendUint(layer, 15, 2)
+	layer = appendString(layer, 1, name)
+	fields := r.Fields()
+	keys := make([]uint64, len(fields))
+	for i, f := range fields {
+		keys[i] = uint64(i)
+		layer = appendString(layer, 3, f.String())
+	}
+	values := &valueTable{index: make(map[string]uint64)}
+	for r.Next() {
+		n, s := r.Shape()
+		if s == nil {
+			continue
+		}
+		geomType, geometry := t.encode(s)
+		if geometry == nil {
+			continue
+		}
+		var tags []uint64
+		for i, f := range fields {
+			if shp.AttributeIsNull(r, i) {
+				continue
+			}
+			tags = append(tags, keys[i], values.add(f, r.Attribute(i)))
+		}
+		var feature []byte
+		feature = appendUint(feature, 1, uint64(n))
+		if len(tags) > 0 {
+			feature = appendPacked(feature, 2, tags)
+		}
+		feature = appendUint(feature, 3, geomType)
+		feature = appendPacked(feature, 4, geometry)
+		layer = appendBytes(layer, 2, feature)
+	}
+	if err := r.Err(); err != nil {
+		return nil, err
+	}
+	layer = append(layer, values.encoded...)
+	layer = appendUint(layer, 5, uint64(opts.Extent))
+	return appendBytes(nil, 3, layer), nil
+}
+
+// valueTable collects the distinct values of a layer.
+type valueTable struct {
+	index   map[string]uint64
+	encoded []byte
+}
+
+// add returns the index of the value of an attribute of field f, adding it
+// to the table if it is new. Numbers and logical values are typed.
+func (vt *valueTable) add(f shp.Field, v string) uint64 {
+	var value []byte
+	switch f.Fieldtype {
+	case 'N', 'F':
+		if i, err := strconv.ParseInt(v, 10, 64); err == nil && f.Precision == 0 {
+			value = appendSint(value, 6, i)
+		} else if d, err := strconv.ParseFloat(v, 64); err == nil {
+			value = appendDouble(value, 3, d)
+		}
+	case 'L':
+		value = appendUint(value, 7, boolValue(strings.ContainsAny(v, "TtYy")))
+	}
+	if value == nil {
+		value = appendString(value, 1, v)
+	}
+	if i, ok := vt.index[string(value)]; ok {
+		return i
+	}
+	i := uint64(len(vt.index))
+	vt.index[string(value)] = i
+	vt.encoded = appendBytes(vt.encoded, 4, value)
+	return i
+}
+
+func boolValue(b bool) uint64 {
+	if b {
+		return 1
+	}
+	return 0
+}
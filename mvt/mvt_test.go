@@ -0,0 +1,223 @@
+package mvt
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	shp "github.com/silbinarywolf/go-shp"
+)
+
+// message is a decoded protocol buffer message. Varints are held as uint64
+// and length-delimited fields as []byte.
+type message map[int][]interface{}
+
+func decode(t *testing.T, b []byte) message {
+	m := message{}
+	for len(b) > 0 {
+		key, n := readVarint(b)
+		b = b[n:]
+		switch key & 7 {
+		case 0:
+			v, n := readVarint(b)
+			m[int(key>>3)] = append(m[int(key>>3)], v)
+			b = b[n:]
+		case 1:
+			m[int(key>>3)] = append(m[int(key>>3)], b[:8])
+			b = b[8:]
+		case 2:
+			l, n := readVarint(b)
+			b = b[n:]
+			m[int(key>>3)] = append(m[int(key>>3)], b[:l])
+			b = b[l:]
+		default:
+			t.Fatalf("unexpected wire type %d", key&7)
+		}
+	}
+	return m
+}
+
+func readVarint(b []byte) (uint64, int) {
+	var v uint64
+	for i, c := range b {
+		v |= uint64(c&0x7f) << (7 * uint(i))
+		if c < 0x80 {
+			return v, i + 1
+		}
+	}
+	return v, len(b)
+}
+
+func packed(b []byte) []uint64 {
+	var values []uint64
+	for len(b) > 0 {
+		v, n := readVarint(b)
+		values = append(values, v)
+		b = b[n:]
+	}
+	return values
+}
+
+func unzigzag(v uint64) int64 {
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+// writeShapes writes a shapefile with a NAME and a COUNT field and returns a
+// reader for it.
+func writeShapes(t *testing.T, dir string, st shp.ShapeType, shapes ...shp.Shape) *shp.Reader {
+	filename := filepath.Join(dir, "layer.shp")
+	w, err := shp.Create(filename, st)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SetFields([]shp.Field{shp.StringField("NAME", 10), shp.NumberField("COUNT", 5)})
+	for i, s := range shapes {
+		n := int(w.Write(s))
+		w.WriteAttribute(n, 0, "shape")
+		w.WriteAttribute(n, 1, i+1)
+	}
+	w.Close()
+	r, err := shp.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func encodeLayer(t *testing.T, r shp.SequentialReader, z, x, y int, opts Options) message {
+	tile, err := EncodeLayer(r, "shapes", z, x, y, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	layers := decode(t, tile)[3]
+	if len(layers) != 1 {
+		t.Fatalf("tile has %d layers, want 1", len(layers))
+	}
+	return decode(t, layers[0].([]byte))
+}
+
+func TestEncodeLayerPoint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	r := writeShapes(t, dir, shp.POINT, &shp.Point{X: 0, Y: 0}, &shp.Point{X: 90, Y: 0})
+	defer r.Close()
+
+	layer := encodeLayer(t, r, 1, 1, 1, Options{})
+	if name := string(layer[1][0].([]byte)); name != "shapes" {
+		t.Errorf("name = %q, want shapes", name)
+	}
+	if v := layer[15][0].(uint64); v != 2 {
+		t.Errorf("version = %d, want 2", v)
+	}
+	if e := layer[5][0].(uint64); e != 4096 {
+		t.Errorf("extent = %d, want 4096", e)
+	}
+	if len(layer[3]) != 2 || string(layer[3][0].([]byte)) != "NAME" || string(layer[3][1].([]byte)) != "COUNT" {
+		t.Errorf("keys = %q, want NAME and COUNT", layer[3])
+	}
+	// the point at 0,0 lies in the top left corner of the bottom right tile
+	// and the point at 90,0 in the middle of its top edge
+	features := layer[2]
+	if len(features) != 2 {
+		t.Fatalf("%d features, want 2", len(features))
+	}
+	want := [][2]int64{{0, 0}, {2048, 0}}
+	for i, f := range features {
+		feature := decode(t, f.([]byte))
+		if id := feature[1][0].(uint64); id != uint64(i) {
+			t.Errorf("feature %d: id = %d", i, id)
+		}
+		if typ := feature[3][0].(uint64); typ != typePoint {
+			t.Errorf("feature %d: type = %d, want %d", i, typ, typePoint)
+		}
+		geometry := packed(feature[4][0].([]byte))
+		if len(geometry) != 3 || geometry[0] != cmdMoveTo|1<<3 {
+			t.Fatalf("feature %d: geometry = %v", i, geometry)
+		}
+		if x, y := unzigzag(geometry[1]), unzigzag(geometry[2]); x != want[i][0] || y != want[i][1] {
+			t.Errorf("feature %d: point = %d,%d, want %v", i, x, y, want[i])
+		}
+		tags := packed(feature[2][0].([]byte))
+		if len(tags) != 4 || tags[0] != 0 || tags[2] != 1 {
+			t.Fatalf("feature %d: tags = %v", i, tags)
+		}
+		value := decode(t, layer[4][tags[3]].([]byte))
+		if n := unzigzag(value[6][0].(uint64)); n != int64(i+1) {
+			t.Errorf("feature %d: COUNT = %d, want %d", i, n, i+1)
+		}
+	}
+	// both features share the string value
+	if len(layer[4]) != 3 {
+		t.Errorf("%d values, want 3", len(layer[4]))
+	}
+}
+
+func TestEncodeLayerClipsPolygon(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	square, err := shp.NewPolygon([][]shp.Point{
+		{{X: -90, Y: -60}, {X: -90, Y: 60}, {X: 90, Y: 60}, {X: 90, Y: -60}, {X: -90, Y: -60}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	outside, err := shp.NewPolygon([][]shp.Point{
+		{{X: 10, Y: -60}, {X: 10, Y: -10}, {X: 90, Y: -10}, {X: 90, Y: -60}, {X: 10, Y: -60}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := writeShapes(t, dir, shp.POLYGON, square, outside)
+	defer r.Close()
+
+	// the top left tile holds the quarter of the square from its corner at
+	// -90,60 to the middle of the tile
+	layer := encodeLayer(t, r, 1, 0, 0, Options{Buffer: 64})
+	if len(layer[2]) != 1 {
+		t.Fatalf("%d features, want 1", len(layer[2]))
+	}
+	feature := decode(t, layer[2][0].([]byte))
+	if typ := feature[3][0].(uint64); typ != typePolygon {
+		t.Errorf("type = %d, want %d", typ, typePolygon)
+	}
+	geometry := packed(feature[4][0].([]byte))
+	if len(geometry) < 3 || geometry[0] != cmdMoveTo|1<<3 || geometry[len(geometry)-1] != cmdClosePath|1<<3 {
+		t.Fatalf("geometry = %v", geometry)
+	}
+	count := int(geometry[3] >> 3)
+	if geometry[3]&7 != cmdLineTo || len(geometry) != 4+2*count+1 {
+		t.Fatalf("geometry = %v", geometry)
+	}
+	var x, y int64
+	var ring []tilePoint
+	for i := 0; i <= count; i++ {
+		j := 1 + 2*i
+		if i > 0 {
+			j++
+		}
+		x += unzigzag(geometry[j])
+		y += unzigzag(geometry[j+1])
+		ring = append(ring, tilePoint{x, y})
+	}
+	if area := ringArea(ring); area <= 0 {
+		t.Errorf("area of exterior ring = %d, want positive", area)
+	}
+	for _, p := range ring {
+		if p.x < 2048 || p.x > 4096+64 || p.y < 1500 || p.y > 4096+64 {
+			t.Errorf("point %v outside of clipped square", p)
+		}
+	}
+}
+
+func TestEncodeLayerInvalidTile(t *testing.T) {
+	if _, err := EncodeLayer(nil, "shapes", 1, 2, 0, Options{}); err == nil {
+		t.Errorf("EncodeLayer of tile 1/2/0 succeeded")
+	}
+}
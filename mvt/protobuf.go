@@ -0,0 +1,62 @@
+package mvt
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// These are the wire types of protocol buffers that tiles use.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+)
+
+func appendVarint(b []byte, v uint64) []byte {
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+func appendKey(b []byte, field, wire int) []byte {
+	return appendVarint(b, uint64(field<<3|wire))
+}
+
+func appendUint(b []byte, field int, v uint64) []byte {
+	return appendVarint(appendKey(b, field, wireVarint), v)
+}
+
+func appendSint(b []byte, field int, v int64) []byte {
+	return appendUint(b, field, zigzag(v))
+}
+
+func appendDouble(b []byte, field int, v float64) []byte {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], math.Float64bits(v))
+	return append(appendKey(b, field, wireFixed64), buf[:]...)
+}
+
+func appendBytes(b []byte, field int, v []byte) []byte {
+	b = appendVarint(appendKey(b, field, wireBytes), uint64(len(v)))
+	return append(b, v...)
+}
+
+func appendString(b []byte, field int, v string) []byte {
+	return appendBytes(b, field, []byte(v))
+}
+
+// appendPacked appends a packed repeated field of varints.
+func appendPacked(b []byte, field int, v []uint64) []byte {
+	var packed []byte
+	for _, x := range v {
+		packed = appendVarint(packed, x)
+	}
+	return appendBytes(b, field, packed)
+}
+
+// zigzag encodes v so that numbers of small magnitude have short varints.
+func zigzag(v int64) uint64 {
+	return uint64((v << 1) ^ (v >> 63))
+}
@@ -0,0 +1,97 @@
+package shp
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// OpenDataset opens the shapefile at path for sequential reading. path is
+// either a .shp file, a ZIP archive that contains a single shapefile, or a
+// directory that contains a single shapefile. In all three cases the
+// companion files are paired with the .shp file regardless of the case of
+// their names, so that e.g. ROADS.SHP is read with roads.dbf.
+func OpenDataset(path string, opts ...Option) (SequentialReader, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if fi.IsDir() {
+		name, err := shapeInDir(path)
+		if err != nil {
+			return nil, err
+		}
+		return openSequential(name, opts)
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".zip":
+		return OpenZip(path, opts...)
+	case ".shp":
+		return openSequential(path, opts)
+	}
+	if err := sniffFile(path); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("Invalid file extension: %s", path)
+}
+
+// shapeInDir returns the name of the only .shp file in dir.
+func shapeInDir(dir string) (string, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	var names []string
+	for _, fi := range infos {
+		if !fi.IsDir() && strings.EqualFold(filepath.Ext(fi.Name()), ".shp") {
+			names = append(names, fi.Name())
+		}
+	}
+	if len(names) == 0 {
+		return "", ErrNoSHPInDirectory
+	}
+	if len(names) > 1 {
+		return "", fmt.Errorf("directory contains %w", ErrMultipleSHP)
+	}
+	return filepath.Join(dir, names[0]), nil
+}
+
+// openSequential opens the .shp file name and its DBF for sequential
+// reading.
+func openSequential(name string, opts []Option) (SequentialReader, error) {
+	files, err := datasetFiles(strings.TrimSuffix(name, filepath.Ext(name)))
+	if err != nil {
+		return nil, err
+	}
+	shp, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if fi, err := shp.Stat(); err == nil {
+		if err := sniffReaderAt(shp, fi.Size()); err != nil {
+			shp.Close()
+			return nil, err
+		}
+	}
+	if files[".cpg"] != "" {
+		if b, err := ioutil.ReadFile(files[".cpg"]); err == nil {
+			opts = append(opts[:len(opts):len(opts)], withCPG(string(b)))
+		}
+	}
+	// dbf is optional, so no error checking here
+	var dbf io.ReadCloser
+	if files[".dbf"] != "" {
+		if f, err := os.Open(files[".dbf"]); err == nil {
+			dbf = f
+		}
+	}
+	sr := SequentialReaderFromExt(shp, dbf, opts...)
+	if err := sr.Err(); err != nil {
+		sr.Close()
+		return nil, err
+	}
+	return sr, nil
+}
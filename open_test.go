@@ -0,0 +1,142 @@
+package shp
+
+import (
+	"archive/zip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// readSequential returns the X coordinates of the points and the attribute
+// rows read from sr.
+func readSequential(t *testing.T, sr SequentialReader) ([]float64, [][]string) {
+	defer sr.Close()
+	var xs []float64
+	var rows [][]string
+	for sr.Next() {
+		_, s := sr.Shape()
+		xs = append(xs, s.(*Point).X)
+		rows = append(rows, Attributes(sr))
+	}
+	if err := sr.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return xs, rows
+}
+
+func TestOpenDataset(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	src := filepath.Join(dir, "src.shp")
+	createSortInput(t, src, []string{"a", "b", "c"})
+
+	// the same shapefile with names in different case, extracted to a
+	// directory and in an archive that uses backslashes
+	names := map[string]string{".shp": "ROADS.SHP", ".shx": "Roads.shx", ".dbf": "roads.DBF"}
+	extracted := filepath.Join(dir, "extracted")
+	os.Mkdir(extracted, 0755)
+	archive := filepath.Join(dir, "roads.zip")
+	f, err := os.Create(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for ext, name := range names {
+		data, err := ioutil.ReadFile(filepath.Join(dir, "src"+ext))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ioutil.WriteFile(filepath.Join(extracted, name), data, 0644)
+		w, _ := zw.Create(`data\` + name)
+		w.Write(data)
+	}
+	zw.Close()
+	f.Close()
+
+	wantXs := []float64{0, 1, 2}
+	wantRows := [][]string{{"0", "a"}, {"1", "b"}, {"2", "c"}}
+	for _, path := range []string{src, extracted, filepath.Join(extracted, "ROADS.SHP"), archive} {
+		sr, err := OpenDataset(path)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		xs, rows := readSequential(t, sr)
+		if !reflect.DeepEqual(xs, wantXs) || !reflect.DeepEqual(rows, wantRows) {
+			t.Errorf("%s: got %v %q, want %v %q", path, xs, rows, wantXs, wantRows)
+		}
+	}
+}
+
+func TestOpenDatasetErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if _, err := OpenDataset(dir); err == nil || !strings.Contains(err.Error(), "does not contain a .shp") {
+		t.Errorf("got error %v for an empty directory", err)
+	}
+	createSortInput(t, filepath.Join(dir, "a.shp"), nil)
+	createSortInput(t, filepath.Join(dir, "b.shp"), nil)
+	if _, err := OpenDataset(dir); err == nil || !strings.Contains(err.Error(), "multiple .shp") {
+		t.Errorf("got error %v for a directory with several shapefiles", err)
+	}
+
+	archive := filepath.Join(dir, "multiple.zip")
+	writeZip(t, archive, "a.shp", "b.shp")
+	if _, err := OpenDataset(archive); err == nil || !strings.Contains(err.Error(), "multiple .shp") {
+		t.Errorf("got error %v for an archive with several shapefiles", err)
+	}
+
+	gpkg := filepath.Join(dir, "roads.gpkg")
+	ioutil.WriteFile(gpkg, []byte("SQLite format 3\x00"), 0644)
+	if _, err := OpenDataset(gpkg); err == nil {
+		t.Error("expected an error for a GeoPackage")
+	} else if _, ok := err.(*ErrUnsupportedFormat); !ok {
+		t.Errorf("got error %v for a GeoPackage", err)
+	}
+}
+
+func TestOpenDatasetCPG(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "latin.shp")
+	w, err := Create(name, POINT, WithCharset(charmap.Windows1252))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SetFields([]Field{StringField("NAME", 10)})
+	w.Write(&Point{1, 2})
+	w.WriteAttribute(0, 0, "Zürich")
+	w.Close()
+	// only the .cpg declares the charset
+	f, err := os.OpenFile(filepath.Join(dir, "latin.dbf"), os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteAt([]byte{0}, 29)
+	f.Close()
+
+	for _, path := range []string{name, dir} {
+		sr, err := OpenDataset(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, rows := readSequential(t, sr)
+		if len(rows) != 1 || rows[0][0] != "Zürich" {
+			t.Errorf("%s: got %q, want Zürich", path, rows)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package shp
+
+import (
+	"context"
+
+	"golang.org/x/text/encoding"
+)
+
+// Option configures optional behaviour of a Reader or Writer. Options that do
+// not apply to the type they are passed to are ignored.
+type Option func(*options)
+
+type options struct {
+	checkCRS bool
+	lenient  bool
+	swapXY   bool
+	omitM    bool
+	orient   bool
+	// omitNoDataM is set by WithoutNoDataMeasures.
+	omitNoDataM bool
+	// simplify is set by WithSimplify.
+	simplify float64
+	// grid is set by WithSnapToGrid.
+	grid float64
+
+	maxRecordSize int64
+	autoSplit     bool
+	rebuildIndex  bool
+	suppressed    map[WarningKind]bool
+
+	sortBy      string
+	sortNumeric bool
+	addZ        func(x, y float64) (float64, error)
+	addM        bool
+
+	progress   func(done, total int64)
+	sizeFactor float64
+	// exactZipNames is set by WithExactZipNames.
+	exactZipNames bool
+
+	charset    encoding.Encoding
+	rawCharset bool
+	// cpg is the content of the .cpg file for readers of streams.
+	cpg string
+
+	ctx context.Context
+}
+
+func newOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// changesData reports whether the options change the records or attributes
+// that Convert writes, so that a shapefile cannot be copied as it is.
+func (o *options) changesData() bool {
+	return o.swapXY || o.omitM || o.omitNoDataM || o.orient || o.simplify > 0 || o.grid > 0 ||
+		o.sortBy != "" || o.addZ != nil || o.addM || o.charset != nil
+}
+
+// WithCRSCheck makes Open compare the extent in the file header with the
+// units declared in the .prj file. The findings are available through
+// Reader.Warnings.
+func WithCRSCheck() Option {
+	return func(o *options) {
+		o.checkCRS = true
+	}
+}
+
+// WithLenient makes readers skip records they cannot decode, such as records
+// of an unknown shape type, instead of stopping with an error. Records of an
+// unknown shape type are reported as a warning. See Reader.SetLenient for
+// how a Reader handles other damaged records.
+func WithLenient() Option {
+	return func(o *options) {
+		o.lenient = true
+	}
+}
+
+// WithSwapXY swaps the X and Y coordinate of every point and bounding box that
+// is read or written, e.g. for data that stores latitude before longitude. The
+// swap is applied before any other processing of the coordinates.
+func WithSwapXY() Option {
+	return func(o *options) {
+		o.swapXY = true
+	}
+}
+
+// WithoutMeasures makes a Writer omit the optional measures of Z shapes, so
+// that e.g. PointZ records are 28 instead of 36 bytes long. Readers return
+// NoData for measures that are omitted.
+func WithoutMeasures() Option {
+	return func(o *options) {
+		o.omitM = true
+	}
+}
+
+// WithoutNoDataMeasures makes a Writer omit the optional measures of Z shapes
+// like WithoutMeasures, but only for the records whose measures are all
+// NoData, so that measures are kept where there are any.
+func WithoutNoDataMeasures() Option {
+	return func(o *options) {
+		o.omitNoDataM = true
+	}
+}
+
+// WithAutoSplit makes a Writer that would grow beyond the maximum file size of
+// the format continue in a new shapefile with the same type, fields, charset
+// and projection instead of failing with ErrFileTooLarge. The parts are named
+// after the shapefile with _2, _3 and so on appended, see Writer.Parts.
+// Writers of ZIP archives cannot be split.
+func WithAutoSplit() Option {
+	return func(o *options) {
+		o.autoSplit = true
+	}
+}
+
+// WithSimplify makes writers simplify every polyline and polygon they write
+// with the given tolerance, in the coordinates that are written, see
+// Simplify.
+func WithSimplify(tolerance float64) Option {
+	return func(o *options) {
+		o.simplify = tolerance
+	}
+}
+
+// WithSnapToGrid makes writers move every point they write to the nearest
+// point of a grid with cells of the given size, e.g. 0.5 or 100, in the
+// coordinates that are written. See Writer.SetPrecision for rounding to
+// decimals.
+func WithSnapToGrid(size float64) Option {
+	return func(o *options) {
+		o.grid = size
+	}
+}
+
+// WithExactZipNames makes ZIP readers only open the files of a shapefile whose
+// names match exactly: the .shp must have the given name, and its companions
+// the same name with the extension replaced, in the case of the extension of
+// the .shp. By default, names are matched regardless of case, path separators
+// and a leading ./, so that e.g. ROADS.DBF is paired with roads.shp.
+func WithExactZipNames() Option {
+	return func(o *options) {
+		o.exactZipNames = true
+	}
+}
+
+// WithMaxRecordSize limits the content length of records that readers accept
+// to n bytes. Larger records are treated as corrupt instead of allocating a
+// buffer for them.
+func WithMaxRecordSize(n int64) Option {
+	return func(o *options) {
+		o.maxRecordSize = n
+	}
+}
+
+// WithProgress makes readers call f as they consume their input, with the
+// number of bytes consumed so far and the expected total. For a ZipReader,
+// both are uncompressed bytes of the .shp and .dbf entries. See
+// Reader.SetProgress and Writer.SetProgress for the other readers and
+// writers.
+func WithProgress(f func(done, total int64)) Option {
+	return func(o *options) {
+		o.progress = f
+	}
+}
+
+// WithZipSizeFactor makes a ZipReader fail when an entry of the archive
+// yields more than factor times the uncompressed size that is declared in the
+// ZIP directory, which guards against archives that misstate their sizes to
+// avoid memory limits.
+func WithZipSizeFactor(factor float64) Option {
+	return func(o *options) {
+		o.sizeFactor = factor
+	}
+}
+
+// WithCharset makes readers convert attributes from enc to UTF-8 instead of
+// the encoding declared by the .cpg file or the DBF header, and makes writers
+// convert string attributes to enc and declare it in a .cpg file.
+func WithCharset(enc encoding.Encoding) Option {
+	return func(o *options) {
+		o.charset = enc
+	}
+}
+
+// WithoutCharsetDetection makes readers ignore the encoding declared by the
+// .cpg file and the DBF header and return attributes as they are stored,
+// unless a charset is set explicitly.
+func WithoutCharsetDetection() Option {
+	return func(o *options) {
+		o.rawCharset = true
+	}
+}
+
+// withSourceCharset makes a reader detect the charset of the DBF even if
+// WithCharset is given, for Convert, which applies WithCharset to dst only.
+func withSourceCharset() Option {
+	return func(o *options) {
+		o.charset = nil
+	}
+}
+
+// withCPG passes the content of the .cpg file to a reader of streams.
+func withCPG(cpg string) Option {
+	return func(o *options) {
+		o.cpg = cpg
+	}
+}
+
+// WithSuppressedWarnings discards warnings of the given kinds instead of
+// reporting them.
+func WithSuppressedWarnings(kinds ...WarningKind) Option {
+	return func(o *options) {
+		if o.suppressed == nil {
+			o.suppressed = make(map[WarningKind]bool)
+		}
+		for _, k := range kinds {
+			o.suppressed[k] = true
+		}
+	}
+}
+
+// readCharset returns the encoding of the attributes of a DBF with the given
+// .cpg file and language driver ID.
+func (o *options) readCharset(cpg string, ldid byte) encoding.Encoding {
+	if o.charset != nil {
+		return o.charset
+	}
+	if o.rawCharset {
+		return nil
+	}
+	return detectCharset(cpg, ldid)
+}
+
+// addWarning appends w to ws unless its kind is suppressed.
+func (o *options) addWarning(ws []Warning, w Warning) []Warning {
+	if o.suppressed[w.Kind] {
+		return ws
+	}
+	return append(ws, w)
+}
@@ -0,0 +1,92 @@
+package shp
+
+// Orientation is the winding order of a ring.
+type Orientation int
+
+// These are the orientations of rings. The specification requires outer rings
+// of polygons to be clockwise and holes to be counterclockwise.
+const (
+	Clockwise Orientation = iota + 1
+	Counterclockwise
+)
+
+func (o Orientation) String() string {
+	switch o {
+	case Clockwise:
+		return "clockwise"
+	case Counterclockwise:
+		return "counterclockwise"
+	}
+	return "unknown"
+}
+
+// ringOrientations returns the orientation of every ring of a polygon. Rings
+// without area are considered clockwise.
+func ringOrientations(parts []int32, points []Point) []Orientation {
+	rings := splitRings(parts, points)
+	o := make([]Orientation, len(rings))
+	for i, ring := range rings {
+		o[i] = Clockwise
+		if ringArea(ring) > 0 {
+			o[i] = Counterclockwise
+		}
+	}
+	return o
+}
+
+// RingOrientations returns the orientation of every ring of the Polygon.
+func (p *Polygon) RingOrientations() []Orientation {
+	return ringOrientations(p.Parts, p.Points)
+}
+
+// FixOrientation reverses the rings of the Polygon whose orientation does not
+// match their nesting, so that rings inside an odd number of other rings are
+// counterclockwise holes and all others clockwise outer rings. It returns the
+// number of rings it reversed.
+func (p *Polygon) FixOrientation() int {
+	return orientRings(p.Parts, p.Points)
+}
+
+// RingOrientations returns the orientation of every ring of the PolygonZ.
+func (p *PolygonZ) RingOrientations() []Orientation {
+	return ringOrientations(p.Parts, p.Points)
+}
+
+// FixOrientation reverses misoriented rings like Polygon.FixOrientation,
+// along with their Z values and measures.
+func (p *PolygonZ) FixOrientation() int {
+	return orientRings(p.Parts, p.Points, p.ZArray, p.MArray)
+}
+
+// RingOrientations returns the orientation of every ring of the PolygonM.
+func (p *PolygonM) RingOrientations() []Orientation {
+	return ringOrientations(p.Parts, p.Points)
+}
+
+// FixOrientation reverses misoriented rings like Polygon.FixOrientation,
+// along with their measures.
+func (p *PolygonM) FixOrientation() int {
+	return orientRings(p.Parts, p.Points, p.MArray)
+}
+
+// fixOrientation fixes the orientation of the rings of s if it is a polygon.
+func fixOrientation(s Shape) int {
+	switch p := s.(type) {
+	case *Polygon:
+		return p.FixOrientation()
+	case *PolygonZ:
+		return p.FixOrientation()
+	case *PolygonM:
+		return p.FixOrientation()
+	}
+	return 0
+}
+
+// WithOrientedRings makes a Writer reverse the rings of polygons whose
+// orientation does not match their nesting before writing them, as
+// Polygon.FixOrientation does. The shapes passed to Write are not modified.
+func WithOrientedRings() Option {
+	return func(o *options) {
+		o.orient = true
+	}
+}
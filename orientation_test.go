@@ -0,0 +1,68 @@
+package shp
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFixOrientation(t *testing.T) {
+	// a counterclockwise outer ring with a clockwise hole
+	outer := []Point{{0, 0}, {10, 0}, {10, 10}, {0, 10}, {0, 0}}
+	hole := []Point{{2, 2}, {2, 4}, {4, 4}, {4, 2}, {2, 2}}
+	p := &PolygonM{
+		NumParts:  2,
+		NumPoints: 10,
+		Parts:     []int32{0, 5},
+		Points:    append(append([]Point(nil), outer...), hole...),
+		MArray:    []float64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9},
+	}
+	if got, want := p.RingOrientations(), []Orientation{Counterclockwise, Clockwise}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got orientations %v, want %v", got, want)
+	}
+	if n := p.FixOrientation(); n != 2 {
+		t.Errorf("reversed %d rings, want 2", n)
+	}
+	if got, want := p.RingOrientations(), []Orientation{Clockwise, Counterclockwise}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got orientations %v after fixing, want %v", got, want)
+	}
+	if want := []float64{4, 3, 2, 1, 0, 9, 8, 7, 6, 5}; !reflect.DeepEqual(p.MArray, want) {
+		t.Errorf("got measures %v, want %v", p.MArray, want)
+	}
+	if n := p.FixOrientation(); n != 0 {
+		t.Errorf("reversed %d rings of a fixed polygon", n)
+	}
+}
+
+func TestWithOrientedRings(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "oriented.shp")
+	w, err := Create(filename, POLYGON, WithOrientedRings())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ccw := []Point{{0, 0}, {1, 0}, {1, 1}, {0, 1}, {0, 0}}
+	p := &Polygon{Box: BBoxFromPoints(ccw), NumParts: 1, NumPoints: 5, Parts: []int32{0}, Points: ccw}
+	w.Write(p)
+	w.Close()
+	if p.Points[1] != (Point{1, 0}) {
+		t.Error("the written shape was modified")
+	}
+
+	r, err := Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	r.Next()
+	_, s := r.Shape()
+	if got := s.(*Polygon).RingOrientations(); !reflect.DeepEqual(got, []Orientation{Clockwise}) {
+		t.Errorf("got orientations %v, want clockwise", got)
+	}
+}
@@ -0,0 +1,245 @@
+package shp
+
+import (
+	"io"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// ParallelReader reads the records of a shapefile sequentially but decodes
+// them on several goroutines, which speeds up reading files with many large
+// shapes. The shapes are returned in the order of the file. It implements
+// SequentialReader.
+type ParallelReader struct {
+	GeometryType ShapeType
+
+	// r reads the attributes and records is read by the goroutine that
+	// reads the records, so that they do not share state.
+	r       *Reader
+	records *Reader
+	// results holds the channel of every record that was read, in order,
+	// on which its decoded shape is delivered.
+	results chan chan parallelResult
+	done    chan struct{}
+	// reading is done when the goroutine that reads the records returns.
+	reading sync.WaitGroup
+	closed  bool
+	// workers is the number of decoding goroutines, which are started by
+	// the first call to Next.
+	workers int
+	started bool
+	// transform holds the parallelTransform set by SetTransform, which the
+	// decoding goroutines load.
+	transform atomic.Value
+
+	shape Shape
+	num   int
+	err   error
+}
+
+type parallelJob struct {
+	num     int
+	offset  int64
+	content []byte
+	result  chan parallelResult
+}
+
+// parallelResult is a decoded record, or the error that stopped reading.
+type parallelResult struct {
+	num int
+	decodedRecord
+	err error
+}
+
+// parallelTransform wraps the transform of a ParallelReader, which may be
+// nil, for storing it in an atomic.Value.
+type parallelTransform struct {
+	t Transform
+}
+
+// OpenParallel opens a shapefile for reading with the given number of
+// goroutines decoding the records, or as many as there are CPUs if workers is
+// less than 1. The options are applied like in Open.
+func OpenParallel(filename string, workers int, opts ...Option) (*ParallelReader, error) {
+	r, err := Open(filename, opts...)
+	if err != nil {
+		if r != nil {
+			r.Close()
+		}
+		return nil, err
+	}
+	records, err := Open(filename, opts...)
+	if err != nil {
+		r.Close()
+		if records != nil {
+			records.Close()
+		}
+		return nil, err
+	}
+	if workers < 1 {
+		workers = runtime.NumCPU()
+	}
+	pr := &ParallelReader{
+		GeometryType: r.GeometryType,
+		r:            r,
+		records:      records,
+		results:      make(chan chan parallelResult, 4*workers),
+		done:         make(chan struct{}),
+		workers:      workers,
+	}
+	return pr, nil
+}
+
+// start starts the goroutines that read and decode the records.
+func (pr *ParallelReader) start() {
+	pr.started = true
+	jobs := make(chan parallelJob, 4*pr.workers)
+	for i := 0; i < pr.workers; i++ {
+		go pr.decode(jobs)
+	}
+	pr.reading.Add(1)
+	go pr.read(jobs)
+}
+
+// read reads the records and passes them to the decoding goroutines. An
+// error that stops reading is delivered as the result of a last record.
+func (pr *ParallelReader) read(jobs chan<- parallelJob) {
+	defer pr.reading.Done()
+	defer close(jobs)
+	defer close(pr.results)
+	r := pr.records
+	for {
+		var job parallelJob
+		if err := r.opts.contextErr(); err != nil {
+			r.err = err
+		} else if content, ok := r.readContent(); ok {
+			job = parallelJob{num: r.count - 1, offset: r.offset - 8 - int64(len(content)), content: append([]byte(nil), content...)}
+		}
+		job.result = make(chan parallelResult, 1)
+		select {
+		case pr.results <- job.result:
+		case <-pr.done:
+			return
+		}
+		if job.content == nil {
+			err := r.err
+			if err == nil {
+				err = io.EOF
+			}
+			job.result <- parallelResult{err: err}
+			return
+		}
+		select {
+		case jobs <- job:
+		case <-pr.done:
+			return
+		}
+	}
+}
+
+// decode decodes the records it receives until jobs is closed.
+func (pr *ParallelReader) decode(jobs <-chan parallelJob) {
+	opts := &pr.records.opts
+	for job := range jobs {
+		t, _ := pr.transform.Load().(parallelTransform)
+		res := parallelResult{num: job.num}
+		res.decodedRecord = decodeRecord(job.content, job.num+1, job.offset, opts, nil, t.t)
+		job.result <- res
+	}
+}
+
+// Next advances to the next shape, which is then available through the
+// Shape method. It returns false at the end of the file or after an error.
+func (pr *ParallelReader) Next() bool {
+	if pr.err != nil || pr.closed {
+		return false
+	}
+	if !pr.started {
+		pr.start()
+	}
+	for result := range pr.results {
+		res := <-result
+		if res.err != nil {
+			pr.err = res.err
+			return false
+		}
+		if res.warning != nil {
+			pr.r.warnings = pr.r.opts.addWarning(pr.r.warnings, *res.warning)
+		}
+		if res.decodedRecord.err != nil {
+			if !res.skipped {
+				pr.err = res.decodedRecord.err
+				return false
+			}
+			pr.r.recordErrors = append(pr.r.recordErrors, res.decodedRecord.err)
+		}
+		if res.skipped {
+			continue
+		}
+		pr.shape, pr.num = res.shape, res.num
+		return true
+	}
+	return false
+}
+
+// Shape returns the index of the current record, starting at 0, and its
+// shape.
+func (pr *ParallelReader) Shape() (int, Shape) {
+	return pr.num, pr.shape
+}
+
+// Attribute returns the value of the n-th attribute of the current record.
+func (pr *ParallelReader) Attribute(n int) string {
+	return pr.r.ReadAttribute(pr.num, n)
+}
+
+// Fields returns the fields of the DBF table.
+func (pr *ParallelReader) Fields() []Field {
+	return pr.r.Fields()
+}
+
+// BBox returns the bounding box of the shapefile.
+func (pr *ParallelReader) BBox() Box {
+	return pr.r.BBox()
+}
+
+// Warnings returns the advisory warnings that were collected so far.
+func (pr *ParallelReader) Warnings() []Warning {
+	return pr.r.Warnings()
+}
+
+// RecordErrors returns the records that were skipped in lenient mode and the
+// malformed attribute rows that were encountered so far.
+func (pr *ParallelReader) RecordErrors() []*RecordError {
+	return pr.r.RecordErrors()
+}
+
+// SetTransform makes the reader transform the points of every shape it
+// returns by t, like Reader.SetTransform. The records are decoded ahead once
+// Next was called, and those that were decoded before SetTransform is called
+// are not transformed, so it should be called before the first call to Next.
+func (pr *ParallelReader) SetTransform(t Transform) {
+	pr.r.SetTransform(t)
+	pr.transform.Store(parallelTransform{t})
+}
+
+// Err returns the last non-EOF error encountered.
+func (pr *ParallelReader) Err() error {
+	if pr.err == io.EOF {
+		return nil
+	}
+	return pr.err
+}
+
+// Close stops reading and closes the shapefile.
+func (pr *ParallelReader) Close() error {
+	if pr.closed {
+		return nil
+	}
+	pr.closed = true
+	close(pr.done)
+	pr.reading.Wait()
+	pr.records.Close()
+	return pr.r.Close()
+}
@@ -0,0 +1,132 @@
+package shp
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestParallelReader(t *testing.T) {
+	for _, name := range []string{"point", "polyline", "polygonz", "multipatch"} {
+		filename := "test_files/" + name + ".shp"
+		_, shapes := readAll(t, filename)
+		for _, workers := range []int{1, 3, 0} {
+			pr, err := OpenParallel(filename, workers)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []Shape
+			for pr.Next() {
+				n, s := pr.Shape()
+				if n != len(got) {
+					t.Errorf("%s: got index %d, want %d", name, n, len(got))
+				}
+				got = append(got, s)
+			}
+			if err := pr.Err(); err != nil {
+				t.Errorf("%s: %v", name, err)
+			}
+			if !reflect.DeepEqual(got, shapes) {
+				t.Errorf("%s with %d workers: got %v, want %v", name, workers, got, shapes)
+			}
+			pr.Close()
+		}
+	}
+}
+
+func TestParallelReaderClose(t *testing.T) {
+	pr, err := OpenParallel("test_files/point.shp", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var _ SequentialReader = pr
+	if !pr.Next() {
+		t.Fatal(pr.Err())
+	}
+	if got := Attributes(pr); len(got) != len(pr.Fields()) {
+		t.Errorf("got attributes %v", got)
+	}
+	// closing while records are still being read must not block
+	if err := pr.Close(); err != nil {
+		t.Error(err)
+	}
+	if err := pr.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestParallelReaderLenient(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "lines.shp")
+	w, err := Create(filename, POLYLINE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SetFields([]Field{NumberField("ID", 4)})
+	for i := 0; i < 6; i++ {
+		n := w.Write(NewPolyLine([][]Point{{{float64(i), 0}, {float64(i), 1}}}))
+		w.WriteAttribute(int(n), 0, i)
+	}
+	w.Close()
+	shp, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// every record has a header of 8 bytes and 80 bytes of content; record 2
+	// gets an unknown shape type and record 4 too many points
+	binary.LittleEndian.PutUint32(shp[100+88*1+8:], 42)
+	binary.LittleEndian.PutUint32(shp[100+88*3+8+40:], 1000)
+	if err := ioutil.WriteFile(filename, shp, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, lenient := range []bool{false, true} {
+		var opts []Option
+		if lenient {
+			opts = append(opts, WithLenient())
+		}
+		pr, err := OpenParallel(filename, 2, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the transform applies to all records if it is set before Next
+		pr.SetTransform(TransformFunc(func(x, y float64) (float64, float64) { return x, y + 10 }))
+		var got []int
+		for pr.Next() {
+			n, s := pr.Shape()
+			got = append(got, n)
+			if p := s.(*PolyLine); p.Points[0] != (Point{float64(n), 10}) {
+				t.Errorf("lenient=%v: record %d has shape %v", lenient, n, s)
+			}
+			if a := pr.Attribute(0); a != strconv.Itoa(n) {
+				t.Errorf("lenient=%v: record %d is paired with attribute %q", lenient, n, a)
+			}
+		}
+		if !lenient {
+			if pr.Err() == nil || len(got) != 1 {
+				t.Errorf("read %v with error %v, want stop after first record", got, pr.Err())
+			}
+			pr.Close()
+			continue
+		}
+		if pr.Err() != nil || !reflect.DeepEqual(got, []int{0, 2, 4, 5}) {
+			t.Errorf("read %v with error %v, want [0 2 4 5]", got, pr.Err())
+		}
+		errs := pr.RecordErrors()
+		if len(errs) != 2 || errs[0].RecordNum != 2 || errs[1].RecordNum != 4 {
+			t.Errorf("got record errors %v, want records 2 and 4", errs)
+		}
+		if ws := pr.Warnings(); len(ws) != 1 || ws[0].Kind != WarnSkippedRecord {
+			t.Errorf("got warnings %v, want one skipped record", ws)
+		}
+		pr.Close()
+	}
+}
@@ -0,0 +1,318 @@
+// Package pgcopy loads shapefiles into PostgreSQL tables with a PostGIS
+// geometry column, without shelling out to shp2pgsql.
+//
+// Records are streamed in the text format of COPY ... FROM STDIN, with the
+// geometry as hex-encoded EWKB followed by the attribute columns, so that
+// they can be piped into psql or handed to the COPY support of a driver.
+// Load inserts them through database/sql instead, which works with any
+// PostgreSQL driver.
+package pgcopy
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+
+	shp "github.com/silbinarywolf/go-shp"
+)
+
+// Options describes the table that records are loaded into.
+type Options struct {
+	// Table is the name of the table, which may be qualified by a schema,
+	// e.g. "public.roads".
+	Table string
+	// GeometryColumn is the name of the geometry column. It is "geom" if it
+	// is empty.
+	GeometryColumn string
+	// GeometryType is the shape type of the shapefile, which sets the type
+	// of the geometry column. The column takes any geometry if it is NULL.
+	GeometryType shp.ShapeType
+	// SRID is the spatial reference system of the geometries, e.g. 4326. It
+	// is unknown if it is 0.
+	SRID int
+}
+
+func (opts Options) geometryColumn() string {
+	if opts.GeometryColumn == "" {
+		return "geom"
+	}
+	return opts.GeometryColumn
+}
+
+// quoteIdent quotes a name, which may be qualified, as an SQL identifier.
+func quoteIdent(name string) string {
+	parts := strings.Split(name, ".")
+	for i, p := range parts {
+		parts[i] = `"` + strings.Replace(p, `"`, `""`, -1) + `"`
+	}
+	return strings.Join(parts, ".")
+}
+
+// columnName returns the name of the column of a field, which is the name of
+// the field in lower case like shp2pgsql uses.
+func columnName(f shp.Field) string {
+	return strings.ToLower(f.String())
+}
+
+// columnType returns the PostgreSQL type of the column of a field.
+func columnType(f shp.Field) string {
+	switch f.Fieldtype {
+	case 'C':
+		if f.Size == 0 {
+			return "text"
+		}
+		return fmt.Sprintf("varchar(%d)", f.Size)
+	case 'N':
+		switch {
+		case f.Precision > 0:
+			return fmt.Sprintf("numeric(%d,%d)", f.Size, f.Precision)
+		case f.Size < 10:
+			return "integer"
+		case f.Size < 19:
+			return "bigint"
+		}
+		return fmt.Sprintf("numeric(%d,0)", f.Size)
+	case 'F', 'O':
+		return "double precision"
+	case 'I':
+		return "integer"
+	case 'L':
+		return "boolean"
+	case 'D':
+		return "date"
+	}
+	return "text"
+}
+
+// geometryTypes maps shape types to PostGIS geometry types. Lines and
+// polygons are always multi-geometries, as shapefile records may have any
+// number of parts.
+var geometryTypes = map[shp.ShapeType]string{
+	shp.POINT:       "Point",
+	shp.POINTZ:      "PointZM",
+	shp.POINTM:      "PointM",
+	shp.MULTIPOINT:  "MultiPoint",
+	shp.MULTIPOINTZ: "MultiPointZM",
+	shp.MULTIPOINTM: "MultiPointM",
+	shp.POLYLINE:    "MultiLineString",
+	shp.POLYLINEZ:   "MultiLineStringZM",
+	shp.POLYLINEM:   "MultiLineStringM",
+	shp.POLYGON:     "MultiPolygon",
+	shp.POLYGONZ:    "MultiPolygonZM",
+	shp.POLYGONM:    "MultiPolygonM",
+}
+
+// CreateTable returns the CREATE TABLE statement of the table for records
+// with the given fields. The geometry column comes first, followed by a
+// column for every field.
+func CreateTable(fields []shp.Field, opts Options) (string, error) {
+	geometry := "geometry"
+	if opts.GeometryType != shp.NULL {
+		t, ok := geometryTypes[opts.GeometryType]
+		if !ok {
+			return "", fmt.Errorf("Unsupported shape type for PostGIS: %v", opts.GeometryType)
+		}
+		geometry = fmt.Sprintf("geometry(%s,%d)", t, opts.SRID)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "CREATE TABLE %s (%s %s", quoteIdent(opts.Table), quoteIdent(opts.geometryColumn()), geometry)
+	for _, f := range fields {
+		fmt.Fprintf(&b, ", %s %s", quoteIdent(columnName(f)), columnType(f))
+	}
+	b.WriteString(")")
+	return b.String(), nil
+}
+
+// CopyStatement returns the COPY ... FROM STDIN statement that reads the
+// output of WriteCopy for records with the given fields.
+func CopyStatement(fields []shp.Field, opts Options) string {
+	return fmt.Sprintf("COPY %s (%s) FROM STDIN", quoteIdent(opts.Table), strings.Join(columns(fields, opts), ", "))
+}
+
+func columns(fields []shp.Field, opts Options) []string {
+	cols := []string{quoteIdent(opts.geometryColumn())}
+	for _, f := range fields {
+		cols = append(cols, quoteIdent(columnName(f)))
+	}
+	return cols
+}
+
+// WriteCopy reads all records of r and writes them to w as rows in the text
+// format of COPY, which are read by the statement of CopyStatement.
+func WriteCopy(w io.Writer, r shp.SequentialReader, opts Options) error {
+	bw := bufio.NewWriter(w)
+	err := eachRow(r, opts, func(row []interface{}) error {
+		for i, v := range row {
+			if i > 0 {
+				bw.WriteByte('\t')
+			}
+			if v == nil {
+				bw.WriteString(`\N`)
+				continue
+			}
+			bw.WriteString(copyEscaper.Replace(v.(string)))
+		}
+		return bw.WriteByte('\n')
+	})
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// copyEscaper escapes the characters that are special in the text format of
+// COPY.
+var copyEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+
+// WriteSQL reads all records of r and writes them to w as an SQL script for
+// psql, which creates the table and loads the records with COPY.
+func WriteSQL(w io.Writer, r shp.SequentialReader, opts Options) error {
+	create, err := CreateTable(r.Fields(), opts)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "BEGIN;\n%s;\n%s;\n", create, CopyStatement(r.Fields(), opts)); err != nil {
+		return err
+	}
+	if err := WriteCopy(w, r, opts); err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "\\.\nCOMMIT;\n")
+	return err
+}
+
+// Load reads all records of r and inserts them into the table in a single
+// transaction, creating the table first if create is set. Since COPY is not
+// part of database/sql, the records are inserted with a prepared statement
+// using the placeholders of PostgreSQL. Drivers that support COPY load
+// faster with CopyStatement and WriteCopy.
+func Load(ctx context.Context, db *sql.DB, r shp.SequentialReader, opts Options, create bool) error {
+	fields := r.Fields()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if create {
+		stmt, err := CreateTable(fields, opts)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	cols := columns(fields, opts)
+	placeholders := make([]string, len(cols))
+	for i := range placeholders {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+	}
+	insert, err := tx.PrepareContext(ctx, fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		quoteIdent(opts.Table), strings.Join(cols, ", "), strings.Join(placeholders, ", ")))
+	if err != nil {
+		return err
+	}
+	defer insert.Close()
+	err = eachRow(r, opts, func(row []interface{}) error {
+		_, err := insert.ExecContext(ctx, row...)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// eachRow calls fn with the values of the columns of every record, which are
+// strings or nil for NULL.
+func eachRow(r shp.SequentialReader, opts Options, fn func(row []interface{}) error) error {
+	fields := r.Fields()
+	row := make([]interface{}, len(fields)+1)
+	for r.Next() {
+		_, s := r.Shape()
+		g, err := geometry(s, opts.SRID)
+		if err != nil {
+			return err
+		}
+		row[0] = g
+		for i, f := range fields {
+			row[i+1] = nil
+			if !shp.AttributeIsNull(r, i) {
+				row[i+1] = value(f, r.Attribute(i))
+			}
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	return r.Err()
+}
+
+// value returns the attribute v of field f as PostgreSQL reads it.
+func value(f shp.Field, v string) string {
+	switch f.Fieldtype {
+	case 'L':
+		if strings.ContainsAny(v, "TtYy") {
+			return "t"
+		}
+		return "f"
+	case 'D':
+		if len(v) == 8 {
+			return v[:4] + "-" + v[4:6] + "-" + v[6:]
+		}
+	}
+	return v
+}
+
+// geometry returns the hex-encoded EWKB of s, or nil for Null shapes. Lines
+// and polygons with a single part are wrapped in multi-geometries.
+func geometry(s shp.Shape, srid int) (interface{}, error) {
+	if _, ok := s.(*shp.Null); ok || s == nil {
+		return nil, nil
+	}
+	b, err := shp.MarshalEWKB(s, 0)
+	if err != nil {
+		return nil, err
+	}
+	t := binary.LittleEndian.Uint32(b[1:5])
+	switch base := t & 0xff; base {
+	case wkbLineString, wkbPolygon:
+		t = t&^0xff | (base + 3)
+		multi := make([]byte, 0, len(b)+13)
+		multi = appendHeader(multi, t, srid)
+		multi = append(multi, 1, 0, 0, 0)
+		b = append(multi, b...)
+	default:
+		b = append(appendHeader(nil, t, srid), b[5:]...)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// These are the WKB geometry types that are wrapped in multi-geometries and
+// the EWKB flag of an embedded SRID.
+const (
+	wkbLineString = 2
+	wkbPolygon    = 3
+	ewkbSRID      = 0x20000000
+)
+
+// appendHeader appends the byte order and the type t of an EWKB geometry,
+// with the SRID unless it is 0.
+func appendHeader(b []byte, t uint32, srid int) []byte {
+	b = append(b, 1)
+	if srid == 0 {
+		return appendUint32(b, t)
+	}
+	return appendUint32(appendUint32(b, t|ewkbSRID), uint32(srid))
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	var buf [4]byte
+	binary.LittleEndian.PutUint32(buf[:], v)
+	return append(b, buf[:]...)
+}
@@ -0,0 +1,146 @@
+package pgcopy
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	shp "github.com/silbinarywolf/go-shp"
+)
+
+func writeLines(t *testing.T, dir string) string {
+	filename := filepath.Join(dir, "roads.shp")
+	w, err := shp.Create(filename, shp.POLYLINE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SetFields([]shp.Field{shp.StringField("NAME", 20), shp.NumberField("LANES", 2), shp.DateField("BUILT")})
+	w.Write(shp.NewPolyLine([][]shp.Point{{{X: 1, Y: 2}, {X: 3, Y: 4}}}))
+	w.WriteAttribute(0, 0, "Main\tStreet")
+	w.WriteAttribute(0, 1, 2)
+	w.WriteAttributeDate(0, 2, 1999, 12, 31)
+	w.Write(&shp.Null{})
+	w.WriteAttribute(1, 0, `back\slash`)
+	w.Close()
+	return filename
+}
+
+const (
+	// EWKB of SRID=4326;MULTILINESTRING((1 2,3 4))
+	lineEWKB = "0105000020e610000001000000" +
+		"010200000002000000000000000000f03f000000000000004000000000000008400000000000001040"
+)
+
+func TestWriteSQL(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	r, err := shp.Open(writeLines(t, dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	var buf bytes.Buffer
+	opts := Options{Table: "public.roads", GeometryType: shp.POLYLINE, SRID: 4326}
+	if err := WriteSQL(&buf, r, opts); err != nil {
+		t.Fatal(err)
+	}
+	want := "BEGIN;\n" +
+		`CREATE TABLE "public"."roads" ("geom" geometry(MultiLineString,4326), "name" varchar(20), "lanes" integer, "built" date);` + "\n" +
+		`COPY "public"."roads" ("geom", "name", "lanes", "built") FROM STDIN;` + "\n" +
+		lineEWKB + "\tMain\\tStreet\t2\t1999-12-31\n" +
+		"\\N\tback\\\\slash\t\\N\t\\N\n" +
+		"\\.\nCOMMIT;\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestCreateTableMultiPatch(t *testing.T) {
+	if _, err := CreateTable(nil, Options{Table: "patches", GeometryType: shp.MULTIPATCH}); err == nil {
+		t.Errorf("CreateTable of MultiPatches succeeded")
+	}
+}
+
+// recorder is a database/sql driver that records the statements that are
+// executed along with their arguments.
+type recorder struct {
+	execs     [][]interface{}
+	committed bool
+}
+
+func (d *recorder) Open(name string) (driver.Conn, error)     { return d, nil }
+func (d *recorder) Prepare(query string) (driver.Stmt, error) { return &recorderStmt{d, query}, nil }
+func (d *recorder) Close() error                              { return nil }
+func (d *recorder) Begin() (driver.Tx, error)                 { return d, nil }
+func (d *recorder) Commit() error                             { d.committed = true; return nil }
+func (d *recorder) Rollback() error                           { return nil }
+func (d *recorder) Exec(query string, args []driver.Value) (driver.Result, error) {
+	exec := []interface{}{query}
+	for _, a := range args {
+		exec = append(exec, a)
+	}
+	d.execs = append(d.execs, exec)
+	return driver.RowsAffected(1), nil
+}
+
+type recorderStmt struct {
+	d     *recorder
+	query string
+}
+
+func (s *recorderStmt) Close() error  { return nil }
+func (s *recorderStmt) NumInput() int { return -1 }
+func (s *recorderStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.d.Exec(s.query, args)
+}
+func (s *recorderStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return nil, errors.New("not supported")
+}
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	r, err := shp.Open(writeLines(t, dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	d := &recorder{}
+	sql.Register("pgcopy-recorder", d)
+	db, err := sql.Open("pgcopy-recorder", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	opts := Options{Table: "roads", GeometryColumn: "shape", GeometryType: shp.POLYLINE, SRID: 4326}
+	if err := Load(context.Background(), db, r, opts, true); err != nil {
+		t.Fatal(err)
+	}
+	const insert = `INSERT INTO "roads" ("shape", "name", "lanes", "built") VALUES ($1, $2, $3, $4)`
+	want := [][]interface{}{
+		{`CREATE TABLE "roads" ("shape" geometry(MultiLineString,4326), "name" varchar(20), "lanes" integer, "built" date)`},
+		{insert, lineEWKB, "Main\tStreet", "2", "1999-12-31"},
+		{insert, nil, `back\slash`, nil, nil},
+	}
+	if !reflect.DeepEqual(d.execs, want) {
+		t.Errorf("got %q, want %q", d.execs, want)
+	}
+	if !d.committed {
+		t.Errorf("transaction was not committed")
+	}
+}
@@ -0,0 +1,60 @@
+package shp
+
+import "fmt"
+
+// FilterFunc decides whether a record with the shape s and the attributes
+// attrs, in the order of the fields, is passed on by Pipe.
+type FilterFunc func(s Shape, attrs []string) bool
+
+// MapFunc rewrites a record passed on by Pipe. It returns the shape and the
+// attributes to write, which may be s and attrs after modifying them in
+// place. A nil shape drops the record.
+type MapFunc func(s Shape, attrs []string) (Shape, []string, error)
+
+// Pipe writes the records of src that pass filter, after rewriting them with
+// m, to dst. Either of filter and m may be nil to pass on all records or to
+// write them unchanged. If no fields have been set on dst, its fields are
+// those of src, see CopySchema. The extent of dst is that of the written
+// shapes. Records are streamed and Pipe does not close dst or src.
+func Pipe(src SequentialReader, dst *Writer, filter FilterFunc, m MapFunc) error {
+	if dst.dbf == nil {
+		if err := CopySchema(dst, src); err != nil {
+			return err
+		}
+	}
+	attrs := make([]string, len(src.Fields()))
+	for src.Next() {
+		n, s := src.Shape()
+		for i := range attrs {
+			attrs[i] = src.Attribute(i)
+		}
+		if filter != nil && !filter(s, attrs) {
+			continue
+		}
+		values := attrs
+		if m != nil {
+			var err error
+			if s, values, err = m(s, attrs); err != nil {
+				return fmt.Errorf("Unable to map record %d: %v", n+1, err)
+			}
+			if s == nil {
+				continue
+			}
+		}
+		if len(values) > len(dst.dbfFields) {
+			return fmt.Errorf("Unable to write record %d: %d attributes for %d fields", n+1, len(values), len(dst.dbfFields))
+		}
+		row := dst.Write(s)
+		if row < 0 {
+			return fmt.Errorf("Unable to write record %d: %v", n+1, dst.Err())
+		}
+		for i, v := range values {
+			if v != "" {
+				if err := dst.WriteAttribute(int(row), i, v); err != nil {
+					return fmt.Errorf("Unable to write record %d: %v", n+1, err)
+				}
+			}
+		}
+	}
+	return src.Err()
+}
@@ -0,0 +1,98 @@
+package shp
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPipe(t *testing.T) {
+	dir, err := ioutil.TempDir("", "shp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	src := filepath.Join(dir, "src.shp")
+	dst := filepath.Join(dir, "dst.shp")
+
+	w, err := Create(src, POINT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SetFields([]Field{StringField("NAME", 10), NumberField("KEEP", 1)})
+	for i, name := range []string{"a", "b", "c"} {
+		w.Write(&Point{float64(i), float64(i)})
+		w.WriteAttribute(i, 0, name)
+		w.WriteAttribute(i, 1, i%2)
+	}
+	w.Close()
+
+	r, err := Open(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	w, err = Create(dst, POINT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = Pipe(r, w, func(s Shape, attrs []string) bool {
+		return attrs[1] == "0"
+	}, func(s Shape, attrs []string) (Shape, []string, error) {
+		p := s.(*Point)
+		p.X += 10
+		attrs[0] += "!"
+		return p, attrs, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	piped, err := Open(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer piped.Close()
+	if len(piped.Fields()) != 2 {
+		t.Errorf("got %d fields, want 2", len(piped.Fields()))
+	}
+	if want := (Box{10, 0, 12, 2}); piped.BBox() != want {
+		t.Errorf("bbox = %v, want %v", piped.BBox(), want)
+	}
+	var names []string
+	for piped.Next() {
+		n, _ := piped.Shape()
+		names = append(names, piped.ReadAttribute(n, 0))
+	}
+	if len(names) != 2 || names[0] != "a!" || names[1] != "c!" {
+		t.Errorf("names = %v", names)
+	}
+}
+
+func TestPipeMapError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "shp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	r, err := Open("test_files/point.shp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	w, err := Create(filepath.Join(dir, "dst.shp"), POINT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	failed := errors.New("failed")
+	err = Pipe(r, w, nil, func(s Shape, attrs []string) (Shape, []string, error) {
+		return nil, nil, failed
+	})
+	if err == nil {
+		t.Error("Pipe succeeded with a failing map")
+	}
+}
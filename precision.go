@@ -0,0 +1,30 @@
+package shp
+
+import "math"
+
+// SetPrecision makes the writer round the X and Y coordinates of every shape
+// to the given number of decimals before it is written, which removes noise
+// beyond the accuracy of the data so that the files compress and compare
+// better. Negative decimals round to tens, hundreds and so on. The shapes
+// that are passed to Write are not modified, and the extent of the file is
+// computed from the rounded shapes. It takes precedence over WithSnapToGrid.
+func (w *Writer) SetPrecision(decimals int) {
+	scale := math.Pow(10, float64(decimals))
+	w.round = func(v float64) float64 {
+		return math.Round(v*scale) / scale
+	}
+}
+
+// rounding returns the function that rounds the coordinates that are written,
+// or nil if they are written as they are.
+func (w *Writer) rounding() func(float64) float64 {
+	if w.round != nil {
+		return w.round
+	}
+	if grid := w.opts.grid; grid > 0 {
+		return func(v float64) float64 {
+			return math.Round(v/grid) * grid
+		}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package shp
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriterPrecision(t *testing.T) {
+	dir, err := ioutil.TempDir("", "shp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	line := NewPolyLine([][]Point{{{1.23456789, 2.00000000001}, {7.98765, -3.3333333}}})
+	tests := []struct {
+		name  string
+		opts  []Option
+		setup func(w *Writer)
+		want  []Point
+	}{
+		{"decimals", nil, func(w *Writer) { w.SetPrecision(2) }, []Point{{1.23, 2}, {7.99, -3.33}}},
+		{"grid", []Option{WithSnapToGrid(0.5)}, nil, []Point{{1, 2}, {8, -3.5}}},
+		{"both", []Option{WithSnapToGrid(0.5)}, func(w *Writer) { w.SetPrecision(0) }, []Point{{1, 2}, {8, -3}}},
+	}
+	for _, test := range tests {
+		name := filepath.Join(dir, test.name+".shp")
+		w, err := Create(name, POLYLINE, test.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if test.setup != nil {
+			test.setup(w)
+		}
+		w.Write(line)
+		w.Close()
+
+		r, err := Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Next()
+		_, s := r.Shape()
+		p := s.(*PolyLine)
+		for i, want := range test.want {
+			if p.Points[i] != want {
+				t.Errorf("%s: point %d = %v, want %v", test.name, i, p.Points[i], want)
+			}
+		}
+		if box := BBoxFromPoints(test.want); r.BBox() != box || p.Box != box {
+			t.Errorf("%s: bbox = %v and %v, want %v", test.name, r.BBox(), p.Box, box)
+		}
+		r.Close()
+	}
+	if line.Points[0].X != 1.23456789 {
+		t.Error("SetPrecision modified the written shape")
+	}
+}
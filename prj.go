@@ -0,0 +1,248 @@
+package shp
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// CRS is the coordinate reference system that is declared in the .prj file of
+// a shapefile. The .prj file contains the ESRI flavour of Well-Known Text.
+type CRS struct {
+	// WKT is the unmodified content of the .prj file.
+	WKT string
+	// Name is the name of the outermost coordinate system.
+	Name string
+	// Geographic is true if the coordinates are longitudes and latitudes
+	// rather than projected coordinates.
+	Geographic bool
+	// Datum is the name of the geodetic datum.
+	Datum string
+	// Unit is the name of the unit of the coordinates, e.g. "Degree" or
+	// "Meter".
+	Unit string
+	// UnitFactor converts the unit to radians for geographic systems and
+	// to meters for projected systems.
+	UnitFactor float64
+}
+
+// wktNode is a single KEYWORD[...] element of a WKT string. Quoted strings and
+// numbers are kept in values, nested elements in children.
+type wktNode struct {
+	keyword  string
+	values   []string
+	children []*wktNode
+}
+
+// child returns the first direct child with the given keyword or nil.
+func (n *wktNode) child(keyword string) *wktNode {
+	for _, c := range n.children {
+		if strings.EqualFold(c.keyword, keyword) {
+			return c
+		}
+	}
+	return nil
+}
+
+// value returns the i-th value of n or the empty string.
+func (n *wktNode) value(i int) string {
+	if n == nil || i >= len(n.values) {
+		return ""
+	}
+	return n.values[i]
+}
+
+type wktParser struct {
+	s   string
+	pos int
+}
+
+func (p *wktParser) skipSpace() {
+	for p.pos < len(p.s) && strings.ContainsRune(" \t\r\n", rune(p.s[p.pos])) {
+		p.pos++
+	}
+}
+
+func (p *wktParser) node() (*wktNode, error) {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.s) && (isWKTLetter(p.s[p.pos]) || p.s[p.pos] == '_') {
+		p.pos++
+	}
+	if start == p.pos {
+		return nil, fmt.Errorf("expected keyword at offset %d", p.pos)
+	}
+	n := &wktNode{keyword: strings.ToUpper(p.s[start:p.pos])}
+	p.skipSpace()
+	if p.pos >= len(p.s) || (p.s[p.pos] != '[' && p.s[p.pos] != '(') {
+		// keywords without brackets, e.g. axis directions
+		return n, nil
+	}
+	p.pos++
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.s) {
+			return nil, errors.New("unexpected end of WKT")
+		}
+		switch c := p.s[p.pos]; {
+		case c == '"':
+			end := strings.IndexByte(p.s[p.pos+1:], '"')
+			if end < 0 {
+				return nil, errors.New("unterminated string in WKT")
+			}
+			n.values = append(n.values, p.s[p.pos+1:p.pos+1+end])
+			p.pos += end + 2
+		case c == '-' || c == '+' || c == '.' || (c >= '0' && c <= '9'):
+			start := p.pos
+			for p.pos < len(p.s) && strings.ContainsRune("+-.eE0123456789", rune(p.s[p.pos])) {
+				p.pos++
+			}
+			n.values = append(n.values, p.s[start:p.pos])
+		default:
+			child, err := p.node()
+			if err != nil {
+				return nil, err
+			}
+			n.children = append(n.children, child)
+		}
+		p.skipSpace()
+		if p.pos >= len(p.s) {
+			return nil, errors.New("unexpected end of WKT")
+		}
+		switch p.s[p.pos] {
+		case ',':
+			p.pos++
+		case ']', ')':
+			p.pos++
+			return n, nil
+		default:
+			return nil, fmt.Errorf("unexpected %q at offset %d", p.s[p.pos], p.pos)
+		}
+	}
+}
+
+func isWKTLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// ParseCRS parses the Well-Known Text of a .prj file.
+func ParseCRS(wkt string) (*CRS, error) {
+	p := &wktParser{s: wkt}
+	root, err := p.node()
+	if err != nil {
+		return nil, fmt.Errorf("Invalid projection: %v", err)
+	}
+	crs := &CRS{WKT: wkt, Name: root.value(0)}
+	geogcs := root
+	switch root.keyword {
+	case "GEOGCS":
+		crs.Geographic = true
+	case "PROJCS":
+		geogcs = root.child("GEOGCS")
+	default:
+		return nil, fmt.Errorf("Unsupported coordinate system: %s", root.keyword)
+	}
+	if geogcs != nil {
+		crs.Datum = geogcs.child("DATUM").value(0)
+	}
+	if unit := root.child("UNIT"); unit != nil {
+		crs.Unit = unit.value(0)
+		crs.UnitFactor, _ = strconv.ParseFloat(unit.value(1), 64)
+	}
+	return crs, nil
+}
+
+// readProjection returns the content of the .prj file of the shapefile base,
+// the path of the shapefile without extension, or the empty string if there
+// is none. The extension is matched regardless of case.
+func readProjection(base string) string {
+	return readCompanion(base, ".prj")
+}
+
+// parseProjection parses the content of a .prj file. It returns nil if wkt is
+// empty.
+func parseProjection(wkt string) (*CRS, error) {
+	wkt = strings.TrimSpace(strings.TrimPrefix(wkt, "\ufeff"))
+	if wkt == "" {
+		return nil, nil
+	}
+	return ParseCRS(wkt)
+}
+
+// Projection returns the content of the .prj file next to the shapefile,
+// which is the Well-Known Text of its coordinate reference system, or the
+// empty string if there is no .prj file.
+func (r *Reader) Projection() string {
+	return r.prj
+}
+
+// ProjectionWKT parses the .prj file next to the shapefile. It returns nil
+// and no error if there is no .prj file.
+func (r *Reader) ProjectionWKT() (*CRS, error) {
+	return parseProjection(r.prj)
+}
+
+// Projection returns the content of the .prj file in the archive, which is
+// the Well-Known Text of the coordinate reference system of the shapefile, or
+// the empty string if there is no .prj file.
+func (zr *ZipReader) Projection() string {
+	return zr.prj
+}
+
+// ProjectionWKT parses the .prj file in the archive. It returns nil and no
+// error if there is no .prj file.
+func (zr *ZipReader) ProjectionWKT() (*CRS, error) {
+	return parseProjection(zr.prj)
+}
+
+// metersPerDegree is the length of one degree along the equator.
+const metersPerDegree = 111319.49079327357
+
+// earthCircumference is the length of the equator in meters.
+const earthCircumference = 360 * metersPerDegree
+
+// SanityCheckCRS compares the extent in header with the range of coordinates
+// that is plausible for the units declared in crs. A CRS with geographic
+// coordinates must stay within ±180/±90 degrees and projected coordinates must
+// stay within one earth circumference of the origin. Projected data whose extent fits
+// entirely within a few degrees of the origin is also reported, as it is most
+// likely not projected at all. The returned warnings are advisory.
+func SanityCheckCRS(header Header, crs *CRS) []Warning {
+	if crs == nil {
+		return nil
+	}
+	b := header.BBox
+	var ws []Warning
+	if crs.Geographic {
+		if b.MinX < -180 || b.MaxX > 180 || b.MinY < -90 || b.MaxY > 90 {
+			ws = append(ws, Warning{
+				Kind:    WarnCoordinatesOutOfRange,
+				Message: fmt.Sprintf("extent %v exceeds ±180/±90 degrees of %q", b, crs.Name),
+			})
+		}
+		return ws
+	}
+
+	factor := crs.UnitFactor
+	if factor <= 0 {
+		factor = 1
+	}
+	// projections reach half of the circumference of the earth, e.g. Web
+	// Mercator; a whole circumference leaves room for false eastings and
+	// northings
+	limit := earthCircumference / factor
+	if b.MinX < -limit || b.MaxX > limit || b.MinY < -limit || b.MaxY > limit {
+		ws = append(ws, Warning{
+			Kind:    WarnCoordinatesOutOfRange,
+			Message: fmt.Sprintf("extent %v is implausibly large for %q", b, crs.Name),
+		})
+	}
+	if b != (Box{}) && b.MinX >= -180 && b.MaxX <= 180 && b.MinY >= -90 && b.MaxY <= 90 {
+		ws = append(ws, Warning{
+			Kind:    WarnLooksLikeDegrees,
+			Message: fmt.Sprintf("extent %v looks like degrees but %q is projected", b, crs.Name),
+		})
+	}
+	return ws
+}
@@ -0,0 +1,198 @@
+package shp
+
+import (
+	"archive/zip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const (
+	wgs84WKT = `GEOGCS["GCS_WGS_1984",DATUM["D_WGS_1984",SPHEROID["WGS_1984",6378137.0,298.257223563]],PRIMEM["Greenwich",0.0],UNIT["Degree",0.0174532925199433]]`
+	utm32WKT = `PROJCS["WGS_1984_UTM_Zone_32N",GEOGCS["GCS_WGS_1984",DATUM["D_WGS_1984",SPHEROID["WGS_1984",6378137.0,298.257223563]],PRIMEM["Greenwich",0.0],UNIT["Degree",0.0174532925199433]],PROJECTION["Transverse_Mercator"],PARAMETER["False_Easting",500000.0],PARAMETER["False_Northing",0.0],PARAMETER["Central_Meridian",9.0],PARAMETER["Scale_Factor",0.9996],PARAMETER["Latitude_Of_Origin",0.0],UNIT["Meter",1.0]]`
+)
+
+func TestParseCRS(t *testing.T) {
+	tests := []struct {
+		wkt  string
+		want CRS
+	}{
+		{wgs84WKT, CRS{Name: "GCS_WGS_1984", Geographic: true, Datum: "D_WGS_1984", Unit: "Degree", UnitFactor: 0.0174532925199433}},
+		{utm32WKT, CRS{Name: "WGS_1984_UTM_Zone_32N", Datum: "D_WGS_1984", Unit: "Meter", UnitFactor: 1}},
+	}
+	for _, test := range tests {
+		crs, err := ParseCRS(test.wkt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		test.want.WKT = test.wkt
+		if *crs != test.want {
+			t.Errorf("got %+v, want %+v", *crs, test.want)
+		}
+	}
+
+	for _, wkt := range []string{"", "GEOGCS[", `GEOGCS["x"`, `LOCAL_CS["x"]`} {
+		if _, err := ParseCRS(wkt); err == nil {
+			t.Errorf("parsed invalid WKT %q without error", wkt)
+		}
+	}
+}
+
+func TestSanityCheckCRS(t *testing.T) {
+	wgs84, _ := ParseCRS(wgs84WKT)
+	utm32, _ := ParseCRS(utm32WKT)
+	tests := []struct {
+		name string
+		box  Box
+		crs  *CRS
+		want []WarningKind
+	}{
+		{"degrees-ok", Box{5.8, 47.2, 15.1, 55.1}, wgs84, nil},
+		{"degrees-with-meters", Box{280000, 5230000, 920000, 6110000}, wgs84, []WarningKind{WarnCoordinatesOutOfRange}},
+		{"meters-ok", Box{280000, 5230000, 920000, 6110000}, utm32, nil},
+		{"meters-with-degrees", Box{5.8, 47.2, 15.1, 55.1}, utm32, []WarningKind{WarnLooksLikeDegrees}},
+		{"meters-too-large", Box{0, 0, 1e12, 1e12}, utm32, []WarningKind{WarnCoordinatesOutOfRange}},
+		{"meters-whole-world", Box{-20037508.34, -20037508.34, 20037508.34, 20037508.34}, utm32, nil},
+		{"meters-beyond-circumference", Box{0, 0, 40100000, 1000}, utm32, []WarningKind{WarnCoordinatesOutOfRange}},
+		{"no-crs", Box{0, 0, 1e12, 1e12}, nil, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ws := SanityCheckCRS(Header{BBox: test.box}, test.crs)
+			if len(ws) != len(test.want) {
+				t.Fatalf("got warnings %v, want %v", ws, test.want)
+			}
+			for i := range ws {
+				if ws[i].Kind != test.want[i] {
+					t.Errorf("got warning %v, want kind %v", ws[i], test.want[i])
+				}
+			}
+		})
+	}
+}
+
+// copyShapefile copies the SHP, SHX and DBF of prefix into dir and returns the
+// path of the copied SHP.
+func copyShapefile(t *testing.T, prefix, dir string) string {
+	base := filepath.Join(dir, filepath.Base(prefix))
+	for _, ext := range []string{".shp", ".shx", ".dbf"} {
+		b, err := ioutil.ReadFile(prefix + ext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(base+ext, b, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return base + ".shp"
+}
+
+func TestOpenWithCRSCheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := copyShapefile(t, "test_files/point", dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "point.prj"), []byte(utm32WKT), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ws := r.Warnings(); len(ws) != 0 {
+		t.Errorf("got warnings %v without CRS check", ws)
+	}
+	r.Close()
+
+	r, err = Open(name, WithCRSCheck())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ws := r.Warnings(); len(ws) != 1 || ws[0].Kind != WarnLooksLikeDegrees {
+		t.Errorf("got warnings %v, want one of kind %v", ws, WarnLooksLikeDegrees)
+	}
+	r.Close()
+
+	r, err = Open(name, WithCRSCheck(), WithSuppressedWarnings(WarnLooksLikeDegrees))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ws := r.Warnings(); len(ws) != 0 {
+		t.Errorf("got suppressed warnings %v", ws)
+	}
+	r.Close()
+}
+
+func TestProjection(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := copyShapefile(t, "test_files/point", dir)
+
+	r, err := Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crs, err := r.ProjectionWKT()
+	if r.Projection() != "" || crs != nil || err != nil {
+		t.Errorf("got projection %q, %v, %v without .prj file", r.Projection(), crs, err)
+	}
+	r.Close()
+
+	// the extension is matched regardless of case and a byte order mark is
+	// ignored
+	prj := "\ufeff" + wgs84WKT + "\r\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "point.PRJ"), []byte(prj), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r, err = Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if r.Projection() != prj {
+		t.Errorf("got projection %q", r.Projection())
+	}
+	crs, err = r.ProjectionWKT()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if crs.Name != "GCS_WGS_1984" || crs.Datum != "D_WGS_1984" || crs.Unit != "Degree" {
+		t.Errorf("got %+v", crs)
+	}
+
+	zipName := filepath.Join(dir, "point.zip")
+	f, err := os.Create(zipName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for _, ext := range []string{".shp", ".shx", ".dbf", ".prj"} {
+		b, _ := ioutil.ReadFile("test_files/point" + ext)
+		if ext == ".prj" {
+			b = []byte(utm32WKT)
+		}
+		w, _ := zw.Create("data/point" + ext)
+		w.Write(b)
+	}
+	zw.Close()
+	f.Close()
+	zr, err := OpenZip(zipName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+	crs, err = zr.ProjectionWKT()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if zr.Projection() != utm32WKT || crs.Name != "WGS_1984_UTM_Zone_32N" || crs.Unit != "Meter" {
+		t.Errorf("got %q, %+v", zr.Projection(), crs)
+	}
+}
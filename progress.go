@@ -0,0 +1,25 @@
+package shp
+
+// SetProgress makes Next call f after every record, with the number of bytes
+// of the .shp that have been read and its total size, so that progress bars
+// can be shown for large files. With SetSkipGeometry, f is called with the
+// number of attribute rows read and the number of rows instead. It replaces
+// the function set with WithProgress, and a nil f stops the reports.
+func (r *Reader) SetProgress(f func(done, total int64)) {
+	r.opts.progress = f
+}
+
+// SetProgress makes the reader call f as it consumes the archive, like
+// WithProgress.
+func (zr *ZipReader) SetProgress(f func(done, total int64)) {
+	zr.progress = f
+}
+
+// SetProgress makes Write call f after every shape with the number of records
+// written and -1, since the total is not known in advance. Close calls f with
+// the number of records as both done and total once the headers and the
+// extent have been written. It replaces the function set with WithProgress,
+// and a nil f stops the reports.
+func (w *Writer) SetProgress(f func(done, total int64)) {
+	w.opts.progress = f
+}
@@ -0,0 +1,72 @@
+package shp
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReaderProgress(t *testing.T) {
+	r, err := Open("test_files/polyline.shp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	var calls int
+	var done, total int64
+	r.SetProgress(func(d, t int64) {
+		calls++
+		done, total = d, t
+	})
+	n := 0
+	for r.Next() {
+		n++
+	}
+	info, _ := os.Stat("test_files/polyline.shp")
+	if calls != n || done != info.Size() || total != info.Size() {
+		t.Errorf("got %d calls for %d records, %d of %d bytes, want %d", calls, n, done, total, info.Size())
+	}
+
+	r2, err := Open("test_files/polyline.shp", WithProgress(func(d, t int64) { done, total = d, t }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r2.Close()
+	r2.SetSkipGeometry(true)
+	done, total = 0, 0
+	for r2.Next() {
+	}
+	if done != int64(n) || total != int64(n) {
+		t.Errorf("got %d of %d rows, want %d", done, total, n)
+	}
+}
+
+func TestWriterProgress(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	w, err := Create(filepath.Join(dir, "points.shp"), POINT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reports [][2]int64
+	w.SetProgress(func(done, total int64) {
+		reports = append(reports, [2]int64{done, total})
+	})
+	w.Write(&Point{1, 2})
+	w.Write(&Point{3, 4})
+	w.Close()
+	want := [][2]int64{{1, -1}, {2, -1}, {2, 2}}
+	if len(reports) != len(want) {
+		t.Fatalf("got reports %v, want %v", reports, want)
+	}
+	for i := range want {
+		if reports[i] != want[i] {
+			t.Errorf("got reports %v, want %v", reports, want)
+			break
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package shp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"sort"
+)
+
+// QIX is the quadtree spatial index of a shapefile in the .qix format of
+// MapServer and GDAL. Readers load the .qix next to a shapefile, if there is
+// one, to skip the records outside of the box set by SetFilterBBox without
+// reading them. The .sbn index of ESRI is read by OpenSBN.
+type QIX struct {
+	// Count is the number of records of the shapefile.
+	Count int
+	root  *qixNode
+}
+
+type qixNode struct {
+	box      Box
+	ids      []int32
+	children []*qixNode
+}
+
+// qixSplitRatio is the share of the extent of a node that each of its halves
+// covers, so that shapes near the middle fit into one of them.
+const qixSplitRatio = 0.55
+
+// OpenQIX reads the .qix file at path.
+func OpenQIX(path string) (*QIX, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < 16 || string(b[:3]) != "SQT" {
+		return nil, fmt.Errorf("Unable to read %s: not a .qix file", path)
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	if b[3] == 2 {
+		order = binary.BigEndian
+	}
+	q := &QIX{Count: int(int32(order.Uint32(b[8:])))}
+	d := &qixDecoder{b: b[16:], order: order}
+	if q.root = d.node(); d.err != nil {
+		return nil, fmt.Errorf("Unable to read %s: %v", path, d.err)
+	}
+	return q, nil
+}
+
+type qixDecoder struct {
+	b     []byte
+	order binary.ByteOrder
+	err   error
+}
+
+func (d *qixDecoder) need(n int) bool {
+	if d.err == nil && len(d.b) < n {
+		d.err = errors.New("unexpected end of file")
+	}
+	return d.err == nil
+}
+
+func (d *qixDecoder) int32() int32 {
+	if !d.need(4) {
+		return 0
+	}
+	v := int32(d.order.Uint32(d.b))
+	d.b = d.b[4:]
+	return v
+}
+
+func (d *qixDecoder) float64() float64 {
+	if !d.need(8) {
+		return 0
+	}
+	v := math.Float64frombits(d.order.Uint64(d.b))
+	d.b = d.b[8:]
+	return v
+}
+
+// node reads a node and its subnodes.
+func (d *qixDecoder) node() *qixNode {
+	n := &qixNode{}
+	n.box = Box{d.float64(), d.float64(), d.float64(), d.float64()}
+	d.int32() // offset of the next sibling
+	count := d.int32()
+	if d.err == nil && (count < 0 || int(count) > len(d.b)/4) {
+		d.err = fmt.Errorf("invalid number of shapes %d", count)
+	}
+	for i := int32(0); i < count && d.err == nil; i++ {
+		n.ids = append(n.ids, d.int32())
+	}
+	children := d.int32()
+	if d.err == nil && (children < 0 || children > 4) {
+		d.err = fmt.Errorf("invalid number of subnodes %d", children)
+	}
+	for i := int32(0); i < children && d.err == nil; i++ {
+		n.children = append(n.children, d.node())
+	}
+	return n
+}
+
+// Search returns the indexes of the records that may intersect box, in
+// ascending order. The index only holds the records whose bounding box lies
+// in the nodes that intersect box, so the records still need to be checked.
+func (q *QIX) Search(box Box) []int {
+	var found []int
+	var search func(n *qixNode)
+	search = func(n *qixNode) {
+		if !n.box.Intersects(box) {
+			return
+		}
+		for _, id := range n.ids {
+			found = append(found, int(id))
+		}
+		for _, c := range n.children {
+			search(c)
+		}
+	}
+	if q.root != nil {
+		search(q.root)
+	}
+	sort.Ints(found)
+	return found
+}
+
+// WriteQIX writes a .qix file at path for the records of r, so that MapServer,
+// GDAL and the readers of this package can skip the records outside of a box.
+// The index is keyed by the index of the records as returned by Shape, so r
+// must read all records of the shapefile, starting with the first.
+func WriteQIX(path string, r SequentialReader) error {
+	var ids []int32
+	var boxes []Box
+	count := 0
+	for r.Next() {
+		n, s := r.Shape()
+		count = n + 1
+		if _, ok := s.(*Null); ok || s == nil {
+			continue
+		}
+		ids = append(ids, int32(n))
+		boxes = append(boxes, s.BBox())
+	}
+	if err := r.Err(); err != nil {
+		return err
+	}
+
+	var extent Box
+	for i, b := range boxes {
+		if i == 0 {
+			extent = b
+		} else {
+			extent.Extend(b)
+		}
+	}
+	depth := 0
+	for nodes := 1; nodes*4 < count; nodes *= 2 {
+		depth++
+	}
+	if depth > 12 {
+		depth = 12
+	}
+	root := &qixNode{box: extent}
+	for i, id := range ids {
+		root.insert(id, boxes[i], depth)
+	}
+	root.trim()
+
+	var buf bytes.Buffer
+	buf.Write([]byte{'S', 'Q', 'T', 1, 1, 0, 0, 0})
+	binary.Write(&buf, binary.LittleEndian, []int32{int32(count), int32(depth)})
+	root.write(&buf)
+	return ioutil.WriteFile(path, buf.Bytes(), 0666)
+}
+
+// insert adds the record id with the bounding box b to the node or the
+// subnode that b fits into, down to the given depth.
+func (n *qixNode) insert(id int32, b Box, depth int) {
+	if depth > 1 {
+		if n.children == nil {
+			var quarters []Box
+			for _, half := range splitBox(n.box) {
+				quarters = append(quarters, splitBox(half)...)
+			}
+			for _, q := range quarters {
+				if q.Contains(b) {
+					for _, q := range quarters {
+						n.children = append(n.children, &qixNode{box: q})
+					}
+					break
+				}
+			}
+		}
+		for _, c := range n.children {
+			if c.box.Contains(b) {
+				c.insert(id, b, depth-1)
+				return
+			}
+		}
+	}
+	n.ids = append(n.ids, id)
+}
+
+// splitBox returns two halves of b along its longer side, which overlap by
+// qixSplitRatio.
+func splitBox(b Box) []Box {
+	b1, b2 := b, b
+	if b.MaxX-b.MinX > b.MaxY-b.MinY {
+		w := (b.MaxX - b.MinX) * qixSplitRatio
+		b1.MaxX, b2.MinX = b.MinX+w, b.MaxX-w
+	} else {
+		h := (b.MaxY - b.MinY) * qixSplitRatio
+		b1.MaxY, b2.MinY = b.MinY+h, b.MaxY-h
+	}
+	return []Box{b1, b2}
+}
+
+// trim removes the subnodes without records and reports whether the node is
+// empty.
+func (n *qixNode) trim() bool {
+	var children []*qixNode
+	for _, c := range n.children {
+		if !c.trim() {
+			children = append(children, c)
+		}
+	}
+	n.children = children
+	return len(n.ids) == 0 && len(n.children) == 0
+}
+
+// size returns the number of bytes that the subnodes of n take up.
+func (n *qixNode) size() int32 {
+	var size int32
+	for _, c := range n.children {
+		size += 4*8 + (int32(len(c.ids))+3)*4 + c.size()
+	}
+	return size
+}
+
+func (n *qixNode) write(buf *bytes.Buffer) {
+	binary.Write(buf, binary.LittleEndian, n.box)
+	binary.Write(buf, binary.LittleEndian, []int32{n.size(), int32(len(n.ids))})
+	binary.Write(buf, binary.LittleEndian, n.ids)
+	binary.Write(buf, binary.LittleEndian, int32(len(n.children)))
+	for _, c := range n.children {
+		c.write(buf)
+	}
+}
+
+// loadQIX returns the index of the shapefile of r if it has a .qix file.
+func (r *Reader) loadQIX() *QIX {
+	for _, ext := range []string{".qix", ".QIX"} {
+		if _, err := os.Stat(r.filename + ext); err != nil {
+			continue
+		}
+		if q, err := OpenQIX(r.filename + ext); err == nil {
+			return q
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package shp
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestQIX(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "grid.shp")
+	w, err := Create(filename, POLYLINE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SetFields([]Field{NumberField("ID", 4)})
+	for i := 0; i < 400; i++ {
+		x, y := float64(i%20), float64(i/20)
+		w.Write(NewPolyLine([][]Point{{{x, y}, {x + 0.5, y + 0.2}}}))
+		w.WriteAttribute(i, 0, i)
+	}
+	w.Write(&Null{})
+	w.Close()
+
+	r, err := Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	qixname := filepath.Join(dir, "grid.qix")
+	if err := WriteQIX(qixname, r); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(qixname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b[:4]) != "SQT\x01" {
+		t.Errorf("got signature %q", b[:4])
+	}
+
+	q, err := OpenQIX(qixname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Count != 401 {
+		t.Errorf("got count %d, want 401", q.Count)
+	}
+	filter := Box{MinX: 4.5, MinY: 3.1, MaxX: 5.2, MaxY: 4.2}
+	found := q.Search(filter)
+	if len(found) >= 400 {
+		t.Errorf("the index found %d records", len(found))
+	}
+	want := []int{64, 65, 84, 85}
+	for _, n := range want {
+		if !containsInt(found, n) {
+			t.Errorf("the index did not find record %d in %v", n, found)
+		}
+	}
+	if all := q.Search(Box{-1, -1, 100, 100}); len(all) != 400 {
+		t.Errorf("got %d records for the whole extent, want 400", len(all))
+	}
+
+	r, err = Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	r.SetFilterBBox(filter)
+	if r.candidates == nil {
+		t.Fatal("the .qix was not loaded")
+	}
+	var got []int
+	for r.Next() {
+		n, _ := r.Shape()
+		if id := r.Attribute(0); id != strconv.Itoa(n) {
+			t.Errorf("record %d has attribute %s", n, id)
+		}
+		got = append(got, n)
+	}
+	if err := r.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got records %v, want %v", got, want)
+	}
+
+	if err := ioutil.WriteFile(qixname, []byte("SQT\x01\x01\x00\x00\x00\x01\x00\x00\x00\x01\x00\x00\x00\x00"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenQIX(qixname); err == nil {
+		t.Error("expected an error for a truncated .qix")
+	}
+}
+
+func containsInt(s []int, n int) bool {
+	for _, v := range s {
+		if v == n {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,85 @@
+package shp
+
+import "math"
+
+// shapeValues returns the Z values and measures of s along with pointers to
+// the ranges that s stores for them, which are nil for points and for shapes
+// without Z values or measures.
+func shapeValues(s Shape) (z, m []float64, zr, mr *[2]float64) {
+	switch s := s.(type) {
+	case *PointZ:
+		return []float64{s.Z}, []float64{s.M}, nil, nil
+	case *PointM:
+		return nil, []float64{s.M}, nil, nil
+	case *MultiPointZ:
+		return s.ZArray, s.MArray, &s.ZRange, &s.MRange
+	case *MultiPointM:
+		return nil, s.MArray, nil, &s.MRange
+	case *PolyLineZ:
+		return s.ZArray, s.MArray, &s.ZRange, &s.MRange
+	case *PolygonZ:
+		return s.ZArray, s.MArray, &s.ZRange, &s.MRange
+	case *PolyLineM:
+		return nil, s.MArray, nil, &s.MRange
+	case *PolygonM:
+		return nil, s.MArray, nil, &s.MRange
+	case *MultiPatch:
+		return s.ZArray, s.MArray, &s.ZRange, &s.MRange
+	}
+	return nil, nil, nil, nil
+}
+
+// withRanges returns s, or a copy of s if the Z and measure ranges that it
+// stores do not match its values, with the ranges corrected, so that records
+// are written with correct ranges even if they were built as struct literals.
+func withRanges(s Shape) Shape {
+	z, m, zr, mr := shapeValues(s)
+	if (zr == nil || *zr == valueRange(z)) && (mr == nil || *mr == measureRange(m)) {
+		return s
+	}
+	c := cloneShape(s)
+	z, m, zr, mr = shapeValues(c)
+	if zr != nil {
+		*zr = valueRange(z)
+	}
+	if mr != nil {
+		*mr = measureRange(m)
+	}
+	return c
+}
+
+// extendRanges adds the Z values and measures of s to the ranges of the file.
+// Measures are left out if they are not written.
+func (w *Writer) extendRanges(s Shape, measures bool) {
+	z, m, _, _ := shapeValues(s)
+	d := shapeDimensions[w.GeometryType]
+	if w.GeometryType == MULTIPATCH {
+		d.z, d.m = true, true
+	}
+	if d.z && len(z) > 0 {
+		r := valueRange(z)
+		if w.zRanged {
+			r[0], r[1] = math.Min(r[0], w.zRange[0]), math.Max(r[1], w.zRange[1])
+		}
+		w.zRange, w.zRanged = r, true
+	}
+	if min, max, ok := measureBounds(m); d.m && measures && ok {
+		if w.mRanged {
+			min, max = math.Min(min, w.mRange[0]), math.Max(max, w.mRange[1])
+		}
+		w.mRange, w.mRanged = [2]float64{min, max}, true
+	}
+}
+
+// ZRange returns the range of the Z values of the shapes written so far and
+// false if none of them had Z values. It is written to the file headers.
+func (w *Writer) ZRange() (min, max float64, ok bool) {
+	return w.zRange[0], w.zRange[1], w.zRanged
+}
+
+// MRange returns the range of the measures of the shapes written so far,
+// ignoring NoData, and false if none of them had measures. It is written to
+// the file headers.
+func (w *Writer) MRange() (min, max float64, ok bool) {
+	return w.mRange[0], w.mRange[1], w.mRanged
+}
@@ -0,0 +1,121 @@
+package shp
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// headerRanges returns the Z and measure ranges in the header of the file at
+// filename.
+func headerRanges(t *testing.T, filename string) [4]float64 {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var r [4]float64
+	for i := range r {
+		r[i] = math.Float64frombits(binary.LittleEndian.Uint64(b[68+8*i:]))
+	}
+	return r
+}
+
+func TestWriterRanges(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "ranges.shp")
+
+	w, err := Create(filename, POLYLINEZ)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the ranges of the struct literal are left empty
+	w.Write(&PolyLineZ{
+		Box:       Box{0, 0, 1, 1},
+		NumParts:  1,
+		NumPoints: 2,
+		Parts:     []int32{0},
+		Points:    []Point{{0, 0}, {1, 1}},
+		ZArray:    []float64{3, 7},
+		MArray:    []float64{NoData, 2},
+	})
+	w.Write(&Null{})
+	w.Write(&PolyLineZ{
+		Box:       Box{0, 0, 1, 1},
+		NumParts:  1,
+		NumPoints: 2,
+		Parts:     []int32{0},
+		Points:    []Point{{0, 0}, {1, 1}},
+		ZArray:    []float64{-1, 4},
+		MArray:    []float64{5, NoData},
+	})
+	if min, max, ok := w.ZRange(); !ok || min != -1 || max != 7 {
+		t.Errorf("ZRange() = %v, %v, %v", min, max, ok)
+	}
+	if min, max, ok := w.MRange(); !ok || min != 2 || max != 5 {
+		t.Errorf("MRange() = %v, %v, %v", min, max, ok)
+	}
+	w.Close()
+
+	want := [4]float64{-1, 7, 2, 5}
+	for _, ext := range []string{".shp", ".shx"} {
+		if got := headerRanges(t, filepath.Join(dir, "ranges"+ext)); got != want {
+			t.Errorf("%s header ranges = %v, want %v", ext, got, want)
+		}
+	}
+	r, err := Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Next()
+	_, s := r.Shape()
+	if p := s.(*PolyLineZ); p.ZRange != [2]float64{3, 7} || p.MRange != [2]float64{2, 2} {
+		t.Errorf("record ranges = %v and %v", p.ZRange, p.MRange)
+	}
+	r.Close()
+
+	w, err = Append(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(&PointZ{}) // rejected, since it is of another type
+	// measures are NoData without m, so they leave the M range alone
+	p, err := NewPolyLineZ([][]Point{{{0, 0}, {1, 1}}}, [][]float64{{10, 0}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(p)
+	w.Close()
+	if got := headerRanges(t, filename); got != [4]float64{-1, 10, 2, 5} {
+		t.Errorf("header ranges after Append = %v", got)
+	}
+}
+
+func TestWriterRangesOfPoints(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "points.shp")
+
+	w, err := Create(filename, POINTM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(&PointM{0, 0, 4})
+	w.Write(&PointM{1, 1, 1.5})
+	if _, _, ok := w.ZRange(); ok {
+		t.Error("ZRange of a PointM file is set")
+	}
+	w.Close()
+	if got := headerRanges(t, filename); got != [4]float64{0, 0, 1.5, 4} {
+		t.Errorf("header ranges = %v", got)
+	}
+}
@@ -0,0 +1,74 @@
+package shp
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// RawShape is the undecoded content of a record.
+type RawShape struct {
+	Type ShapeType
+	// Content is the content of the record following the shape type. It is
+	// only valid until the reader advances to the next record.
+	Content []byte
+}
+
+// BBox returns the extent of the record without decoding its points. It
+// returns false for Null shapes and records that are too short.
+func (rs RawShape) BBox() (Box, bool) {
+	return recordBox(rs.Type, rs.Content)
+}
+
+// Decode decodes the record into a new shape.
+func (rs RawShape) Decode() (Shape, error) {
+	return readShape(rs.Type, rs.Content)
+}
+
+// DecodeInto decodes the record into s, which must be a shape of the
+// built-in type that matches the type of the record. The slices of s are
+// reused if they have enough capacity, so that decoding many records into
+// the same shape does not allocate their points again.
+func (rs RawShape) DecodeInto(s Shape) error {
+	if t := shapeTypeOf(s, 0); t != rs.Type {
+		return fmt.Errorf("Unable to decode record of type %v into %T", rs.Type, s)
+	}
+	if err := decodeShape(s, rs.Type, rs.Content); err != nil {
+		return fmt.Errorf("Unable to decode record of type %v: %v", rs.Type, err)
+	}
+	return nil
+}
+
+// RawShape returns the index of the record that was read last by Next or
+// NextRaw, starting at 0, and its undecoded content.
+func (r *Reader) RawShape() (int, RawShape) {
+	if len(r.raw) < 4 {
+		return r.count - 1, RawShape{}
+	}
+	return r.count - 1, RawShape{Type: ShapeType(binary.LittleEndian.Uint32(r.raw)), Content: r.raw[4:]}
+}
+
+// NextRaw advances to the next record like Next, but does not decode it. The
+// record is available through RawShape, while Shape returns nil. Records of
+// all types are returned, but records outside the box set by SetFilterBBox
+// are skipped.
+func (r *Reader) NextRaw() bool {
+	for {
+		if err := r.opts.contextErr(); err != nil {
+			r.err = err
+			return false
+		}
+		var ok bool
+		if r.recovered != nil {
+			ok = r.nextRecoveredContent()
+		} else {
+			r.raw, ok = r.readContent()
+		}
+		if !ok {
+			return false
+		}
+		r.shape = nil
+		if _, rs := r.RawShape(); !filtered(r.filter, rs.Type, rs.Content, r.opts.swapXY) {
+			return true
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package shp
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNextRaw(t *testing.T) {
+	for _, name := range []string{"point", "pointz", "multipointm", "polyline", "polygonz", "polylinem", "multipatch"} {
+		filename := "test_files/" + name + ".shp"
+		typ, shapes := readAll(t, filename)
+		r, err := Open(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reused, _ := newShape(typ)
+		n := 0
+		for r.NextRaw() {
+			i, rs := r.RawShape()
+			if i != n || rs.Type != typ {
+				t.Errorf("%s: got record %d of type %v, want %d of type %v", name, i, rs.Type, n, typ)
+			}
+			if b, ok := rs.BBox(); !ok || b != shapes[n].BBox() {
+				t.Errorf("%s: record %d: got box %v, want %v", name, n, b, shapes[n].BBox())
+			}
+			if err := rs.DecodeInto(reused); err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			if !reflect.DeepEqual(reused, shapes[n]) {
+				t.Errorf("%s: record %d: got %v, want %v", name, n, reused, shapes[n])
+			}
+			n++
+		}
+		if err := r.Err(); err != nil || n != len(shapes) {
+			t.Errorf("%s: read %d records with error %v, want %d", name, n, err, len(shapes))
+		}
+		r.Close()
+	}
+}
+
+func TestDecodeIntoReuse(t *testing.T) {
+	r, err := Open("test_files/polyline.shp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if !r.NextRaw() {
+		t.Fatal(r.Err())
+	}
+	_, rs := r.RawShape()
+	p := &PolyLine{}
+	if err := rs.DecodeInto(p); err != nil {
+		t.Fatal(err)
+	}
+	points, parts := &p.Points[0], &p.Parts[0]
+	if err := rs.DecodeInto(p); err != nil {
+		t.Fatal(err)
+	}
+	if &p.Points[0] != points || &p.Parts[0] != parts {
+		t.Error("the slices of the shape were not reused")
+	}
+	if err := rs.DecodeInto(&Point{}); err == nil {
+		t.Error("expected an error for a shape of another type")
+	}
+	rs.Content = rs.Content[:50]
+	if err := rs.DecodeInto(p); err == nil {
+		t.Error("expected an error for truncated content")
+	}
+}
@@ -1,13 +1,17 @@
 package shp
 
 import (
+	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"math"
 	"os"
 	"path/filepath"
 	"strings"
+
+	"golang.org/x/text/encoding"
 )
 
 // Reader provides a interface for reading Shapefiles. Calls
@@ -17,19 +21,59 @@ import (
 type Reader struct {
 	GeometryType ShapeType
 	bbox         Box
+	zrange       [2]float64
+	mrange       [2]float64
 	err          error
+	opts         options
+	warnings     []Warning
+	// prj is the content of the .prj file, if there is one.
+	prj string
+	// filter is the box set by SetFilterBBox.
+	filter *Box
+	// dbt and memo are the .dbt file and its reader, opened by Memo.
+	dbt  *os.File
+	memo *memoFile
+	// closed is set by Close.
+	closed bool
+	// charset is the encoding that attributes are converted from.
+	charset encoding.Encoding
+	// mapped is set by OpenMmap.
+	mapped bool
+	// skipGeometry is set by SetSkipGeometry.
+	skipGeometry bool
+	// transform is set by SetTransform.
+	transform Transform
+	// candidates holds the records that the .qix or .sbn finds for the box
+	// set by SetFilterBBox, if there is one.
+	candidates map[int]bool
 
 	shp        readSeekCloser
 	shape      Shape
 	num        int32
+	count      int
 	filename   string
 	filelength int64
+	offset     int64
+	buf        []byte
+	// raw is the content of the record that was read last.
+	raw []byte
 
 	dbf             readSeekCloser
 	dbfFields       []Field
+	dbfFieldNumbers map[string]int
+	dbfOffsets      []int
 	dbfNumRecords   int32
 	dbfHeaderLength int16
 	dbfRecordLength int16
+	dbfRow          []byte
+	dbfRowNum       int
+	dbfRowBad       bool
+	dbfBadRows      map[int]bool
+	recordErrors    []*RecordError
+
+	// recovered is set by OpenRecover and limits the reader to the records
+	// that could be recovered.
+	recovered []recoveredRecord
 }
 
 type readSeekCloser interface {
@@ -39,24 +83,88 @@ type readSeekCloser interface {
 }
 
 // Open opens a Shapefile for reading.
-func Open(filename string) (*Reader, error) {
+func Open(filename string, opts ...Option) (*Reader, error) {
 	ext := filepath.Ext(filename)
 	if strings.ToLower(ext) != ".shp" {
+		if err := sniffFile(filename); err != nil {
+			return nil, err
+		}
 		return nil, fmt.Errorf("Invalid file extension: %s", filename)
 	}
 	shp, err := os.Open(filename)
 	if err != nil {
 		return nil, err
 	}
-	s := &Reader{filename: strings.TrimSuffix(filename, ext), shp: shp}
-	return s, s.readHeaders()
+	if fi, err := shp.Stat(); err == nil {
+		if err := sniffReaderAt(shp, fi.Size()); err != nil {
+			shp.Close()
+			return nil, err
+		}
+	}
+	return openReader(&Reader{filename: strings.TrimSuffix(filename, ext), shp: shp, opts: newOptions(opts)})
+}
+
+// openReader reads the headers of the shapefile of s and its projection.
+func openReader(s *Reader) (*Reader, error) {
+	if err := s.readHeaders(); err != nil {
+		return s, err
+	}
+	s.prj = readProjection(s.filename)
+	if s.opts.swapXY {
+		s.bbox = swapBox(s.bbox)
+	}
+	if s.opts.checkCRS {
+		s.checkCRS()
+	}
+	return s, nil
+}
+
+// checkCRS compares the header extent with the units of the .prj file, if
+// there is one, and records the findings as warnings.
+func (r *Reader) checkCRS() {
+	crs, err := r.ProjectionWKT()
+	if crs == nil || err != nil {
+		return
+	}
+	for _, w := range SanityCheckCRS(r.Header(), crs) {
+		r.warnings = r.opts.addWarning(r.warnings, w)
+	}
+}
+
+// Warnings returns the advisory warnings that were collected so far.
+func (r *Reader) Warnings() []Warning {
+	return r.warnings
 }
 
 // BBox returns the bounding box of the shapefile.
 func (r *Reader) BBox() Box {
+	if r.transform != nil {
+		return transformBox(r.bbox, r.transform)
+	}
 	return r.bbox
 }
 
+// Header holds the information from the main file header of a shapefile.
+type Header struct {
+	FileLength   int64
+	GeometryType ShapeType
+	BBox         Box
+	ZRange       [2]float64
+	MRange       [2]float64
+}
+
+// Header returns the main file header of the shapefile. The file length is
+// the actual size of the file rather than the one declared in the header.
+func (r *Reader) Header() Header {
+	return Header{
+		FileLength:   r.filelength,
+		GeometryType: r.GeometryType,
+		BBox:         r.bbox,
+		ZRange:       r.zrange,
+		MRange:       r.mrange,
+	}
+}
+
 // Read and parse headers in the Shapefile. This will
 // fill out GeometryType, filelength and bbox.
 func (r *Reader) readHeaders() error {
@@ -74,7 +182,12 @@ func (r *Reader) readHeaders() error {
 	r.bbox.MinY = readFloat64(er)
 	r.bbox.MaxX = readFloat64(er)
 	r.bbox.MaxY = readFloat64(er)
+	r.zrange[0] = readFloat64(er)
+	r.zrange[1] = readFloat64(er)
+	r.mrange[0] = readFloat64(er)
+	r.mrange[1] = readFloat64(er)
 	r.shp.Seek(100, 0)
+	r.offset = 100
 	return er.e
 }
 
@@ -84,15 +197,22 @@ func readFloat64(r io.Reader) float64 {
 	return math.Float64frombits(bits)
 }
 
-// Close closes the Shapefile.
+// Close closes the Shapefile. The files are closed even if reading stopped
+// with an error, e.g. because the context of NextContext was cancelled.
 func (r *Reader) Close() error {
-	if r.err == nil {
-		r.err = r.shp.Close()
-		if r.dbf != nil {
-			r.dbf.Close()
-		}
+	if r.dbt != nil {
+		r.dbt.Close()
+		r.dbt = nil
 	}
-	return r.err
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	err := r.shp.Close()
+	if r.dbf != nil {
+		r.dbf.Close()
+	}
+	return err
 }
 
 // Shape returns the most recent feature that was read by
@@ -100,13 +220,13 @@ func (r *Reader) Close() error {
 // object index starting from zero in the shapefile which
 // can be used as row in ReadAttribute, and the Shape is the object.
 func (r *Reader) Shape() (int, Shape) {
-	return int(r.num) - 1, r.shape
+	return r.count - 1, r.shape
 }
 
 // Attribute returns value of the n-th attribute of the most recent feature
 // that was read by a call to Next.
 func (r *Reader) Attribute(n int) string {
-	return r.ReadAttribute(int(r.num)-1, n)
+	return r.ReadAttribute(r.count-1, n)
 }
 
 // newShape creates a new shape with a given type.
@@ -141,7 +261,7 @@ func newShape(shapetype ShapeType) (Shape, error) {
 	case MULTIPATCH:
 		return new(MultiPatch), nil
 	default:
-		return nil, fmt.Errorf("Unsupported shape type: %v", shapetype)
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedShapeType, shapetype)
 	}
 }
 
@@ -150,41 +270,141 @@ func newShape(shapetype ShapeType) (Shape, error) {
 // returns false when the reader has reached the end of the
 // file or encounters an error.
 func (r *Reader) Next() bool {
-	cur, _ := r.shp.Seek(0, io.SeekCurrent)
-	if cur >= r.filelength {
-		return false
+	if r.skipGeometry {
+		ok := r.nextRow()
+		if ok && r.opts.progress != nil {
+			r.opts.progress(int64(r.count), int64(r.dbfNumRecords))
+		}
+		return ok
+	}
+	for {
+		ok, skipped := r.next()
+		if !skipped {
+			if ok && r.opts.progress != nil {
+				r.opts.progress(r.offset, r.filelength)
+			}
+			return ok
+		}
+	}
+}
+
+// next reads the next record. It reports skipped = true if the record was
+// skipped in lenient mode. Records are identified by their position in the
+// file rather than by the record number in their header, which is not
+// reliable, and the attribute row of a record is the row at that position.
+// Every record that is read, whether it is returned or skipped, therefore
+// advances both the shapes and the attribute rows by one.
+func (r *Reader) next() (ok, skipped bool) {
+	if err := r.opts.contextErr(); err != nil {
+		r.err = err
+		return false, false
+	}
+	if r.recovered != nil {
+		return r.nextRecovered()
+	}
+	if r.raw, ok = r.readContent(); !ok {
+		return false, r.skipDamagedHeader()
 	}
+	return r.decode(r.raw)
+}
 
-	var size int32
-	var shapetype ShapeType
-	er := &errReader{Reader: r.shp}
-	binary.Read(er, binary.BigEndian, &r.num)
-	binary.Read(er, binary.BigEndian, &size)
-	binary.Read(er, binary.LittleEndian, &shapetype)
-	if er.e != nil {
-		if er.e != io.EOF {
-			r.err = fmt.Errorf("Error when reading metadata of next shape: %v", er.e)
+// readContent reads the next record and returns its content, starting with
+// the shape type, in the buffer of the reader. It returns false at the end of
+// the file or after setting r.err.
+func (r *Reader) readContent() ([]byte, bool) {
+	// records that the spatial index rules out for the filter are skipped
+	// unread
+	for r.candidates != nil && !r.candidates[r.count] && r.offset < r.filelength {
+		size, ok := r.readHeader()
+		if !ok {
+			return nil, false
+		}
+		if _, err := r.shp.Seek(size, io.SeekCurrent); err != nil {
+			r.err = fmt.Errorf("Error when skipping record %d: %v", r.num, err)
+			return nil, false
+		}
+		r.offset += 8 + size
+		r.count++
+	}
+	if r.offset >= r.filelength {
+		return nil, false
+	}
+	size, ok := r.readHeader()
+	if !ok {
+		return nil, false
+	}
+
+	// the whole content is read at once into a buffer that is reused
+	// across records, or taken from the mapping of a mapped file; the next
+	// record then starts right after the content length that is declared
+	// in the header, regardless of what the decoder consumed
+	var content []byte
+	if m, ok := r.shp.(*mappedFile); ok {
+		content = m.data[r.offset+8 : r.offset+8+size]
+		m.Seek(size, io.SeekCurrent)
+	} else {
+		if int64(cap(r.buf)) < size {
+			r.buf = make([]byte, size)
+		}
+		content = r.buf[:size]
+		if _, err := io.ReadFull(r.shp, content); err != nil {
+			r.err = fmt.Errorf("Error while reading next shape: %v", err)
+			return nil, false
+		}
+	}
+	r.offset += 8 + size
+	r.count++
+	return content, true
+}
+
+// readHeader reads the header of the next record and returns its content
+// length. It returns false after setting r.err.
+func (r *Reader) readHeader() (int64, bool) {
+	var header [8]byte
+	if _, err := io.ReadFull(r.shp, header[:]); err != nil {
+		if err != io.EOF {
+			r.err = fmt.Errorf("Error when reading metadata of next shape: %v", err)
 		} else {
 			r.err = io.EOF
 		}
-		return false
+		return 0, false
 	}
-
-	var err error
-	r.shape, err = newShape(shapetype)
-	if err != nil {
-		r.err = fmt.Errorf("Error decoding shape type: %v", err)
-		return false
+	r.num = int32(binary.BigEndian.Uint32(header[0:4]))
+	size := int64(int32(binary.BigEndian.Uint32(header[4:8]))) * 2
+	if size < 4 || size > r.filelength-r.offset-8 {
+		r.err = &RecordError{RecordNum: r.count + 1, Offset: r.offset,
+			Err: fmt.Errorf("Error when reading metadata of next shape: invalid content length %d of record %d", size, r.num)}
+		return 0, false
 	}
-	r.shape.read(er)
-	if er.e != nil {
-		r.err = fmt.Errorf("Error while reading next shape: %v", er.e)
-		return false
+	if r.opts.maxRecordSize > 0 && size > r.opts.maxRecordSize {
+		r.err = &RecordError{RecordNum: r.count + 1, Offset: r.offset,
+			Err: fmt.Errorf("Error when reading metadata of next shape: record %d exceeds maximum record size of %d bytes", r.num, r.opts.maxRecordSize)}
+		return 0, false
 	}
+	return size, true
+}
 
-	// move to next object
-	r.shp.Seek(int64(size)*2+cur+8, 0)
-	return true
+// recordError returns err for the record that was read last, whose content
+// is given.
+func (r *Reader) recordError(content []byte, err error) *RecordError {
+	return &RecordError{RecordNum: r.count, Offset: r.offset - 8 - int64(len(content)), Err: err}
+}
+
+// decode decodes the content of the current record into r.shape.
+func (r *Reader) decode(content []byte) (ok, skipped bool) {
+	d := decodeRecord(content, r.count, r.offset-8-int64(len(content)), &r.opts, r.filter, r.transform)
+	r.shape = d.shape
+	if d.warning != nil {
+		r.warnings = r.opts.addWarning(r.warnings, *d.warning)
+	}
+	if d.err != nil {
+		if !d.skipped {
+			r.err = d.err
+			return false, false
+		}
+		return false, r.skipDamagedContent(d.err)
+	}
+	return !d.skipped, d.skipped
 }
 
 // Opens DBF file using r.filename + "dbf". This method
@@ -195,7 +415,11 @@ func (r *Reader) openDbf() (err error) {
 		return
 	}
 
-	r.dbf, err = os.Open(r.filename + ".dbf")
+	if r.mapped {
+		r.dbf, err = mapFile(r.filename + ".dbf")
+	} else {
+		r.dbf, err = os.Open(r.filename + ".dbf")
+	}
 	if err != nil {
 		return
 	}
@@ -206,13 +430,43 @@ func (r *Reader) openDbf() (err error) {
 	binary.Read(r.dbf, binary.LittleEndian, &r.dbfHeaderLength)
 	binary.Read(r.dbf, binary.LittleEndian, &r.dbfRecordLength)
 
-	r.dbf.Seek(20, io.SeekCurrent) // skip padding
+	var reserved [20]byte
+	io.ReadFull(r.dbf, reserved[:])
+	r.charset = r.opts.readCharset(readCompanion(r.filename, ".cpg"), reserved[17])
 	numFields := int(math.Floor(float64(r.dbfHeaderLength-33) / 32.0))
 	r.dbfFields = make([]Field, numFields)
 	binary.Read(r.dbf, binary.LittleEndian, &r.dbfFields)
+	r.dbfOffsets = fieldOffsets(r.dbfFields)
+
+	size, _ := r.dbf.Seek(0, io.SeekEnd)
+	r.checkRecordCount(dbfRowCount(size, r.dbfHeaderLength, r.dbfRecordLength))
 	return
 }
 
+// checkRecordCount compares the number of records in the DBF header with the
+// number of rows the file holds. The rows are counted instead if the header
+// says there are none, as left behind by exporters that crash before they
+// finalize the header, or if they disagree in lenient mode.
+func (r *Reader) checkRecordCount(rows int32) {
+	if rows == r.dbfNumRecords {
+		return
+	}
+	msg := fmt.Sprintf("DBF header declares %d records but the file holds %d rows", r.dbfNumRecords, rows)
+	if r.dbfNumRecords == 0 || r.opts.lenient {
+		msg += fmt.Sprintf(", using %d", rows)
+		r.dbfNumRecords = rows
+	}
+	r.warnings = r.opts.addWarning(r.warnings, Warning{Kind: WarnDBFRecordCount, Message: msg})
+}
+
+// dbfRowCount returns the number of complete rows in a DBF file of size bytes.
+func dbfRowCount(size int64, headerLength, recordLength int16) int32 {
+	if recordLength <= 0 || size <= int64(headerLength) {
+		return 0
+	}
+	return int32((size - int64(headerLength)) / int64(recordLength))
+}
+
 // Fields returns a slice of Fields that are present in the
 // DBF table.
 func (r *Reader) Fields() []Field {
@@ -220,6 +474,14 @@ func (r *Reader) Fields() []Field {
 	return r.dbfFields
 }
 
+// FieldOffsets returns the byte offset of every field within a DBF record.
+// The offsets include the deletion flag that starts every record, so the
+// first field is at offset 1.
+func (r *Reader) FieldOffsets() []int {
+	r.openDbf() // make sure we have a dbf file to read from
+	return append([]int(nil), r.dbfOffsets...)
+}
+
 // Err returns the last non-EOF error encountered.
 func (r *Reader) Err() error {
 	if r.err == io.EOF {
@@ -235,15 +497,100 @@ func (r *Reader) AttributeCount() int {
 }
 
 // ReadAttribute returns the attribute value at row for field in
-// the DBF table as a string. Both values starts at 0.
+// the DBF table as a string. Both values starts at 0. If the row
+// is malformed, the empty string is returned and the problem is
+// reported through RecordErrors.
 func (r *Reader) ReadAttribute(row int, field int) string {
 	r.openDbf() // make sure we have a dbf file to read from
-	seekTo := 1 + int64(r.dbfHeaderLength) + (int64(row) * int64(r.dbfRecordLength))
-	for n := 0; n < field; n++ {
-		seekTo += int64(r.dbfFields[n].Size)
-	}
-	r.dbf.Seek(seekTo, io.SeekStart)
-	buf := make([]byte, r.dbfFields[field].Size)
-	r.dbf.Read(buf)
-	return strings.Trim(string(buf[:]), " ")
+	if !r.readRow(row) || r.dbfRowBad {
+		return ""
+	}
+	start := r.dbfOffsets[field]
+	return decodeAttr(r.charset, strings.Trim(string(r.dbfRow[start:start+int(r.dbfFields[field].Size)]), " "))
+}
+
+// Attributes returns all attributes of the most recent feature, which are
+// decoded from its row after reading it once. They are empty if the row is
+// malformed.
+func (r *Reader) Attributes() []string {
+	r.openDbf() // make sure we have a dbf file to read from
+	row := r.count - 1
+	if !r.readRow(row) {
+		return make([]string, len(r.dbfFields))
+	}
+	return rowAttributes(r.charset, r.dbfFields, r.dbfOffsets, r.dbfRow, r.dbfRowBad)
+}
+
+// AttributesMap returns all attributes of the most recent feature keyed by the
+// names of their fields.
+func (r *Reader) AttributesMap() map[string]string {
+	return AttributesMap(r)
+}
+
+// readRow reads the given row of the DBF table into dbfRow with a single
+// read, unless it is the row that was read last. Rows are always read from
+// their computed offset, so a malformed row never affects the following
+// ones.
+func (r *Reader) readRow(row int) bool {
+	if r.dbf == nil {
+		return false
+	}
+	if row < 0 || row >= int(r.dbfNumRecords) {
+		return false
+	}
+	if r.dbfRow != nil && r.dbfRowNum == row {
+		return true
+	}
+	if len(r.dbfRow) != int(r.dbfRecordLength) {
+		r.dbfRow = make([]byte, r.dbfRecordLength)
+	}
+	offset := int64(r.dbfHeaderLength) + (int64(row) * int64(r.dbfRecordLength))
+	var err error
+	if ra, ok := r.dbf.(io.ReaderAt); ok {
+		_, err = ra.ReadAt(r.dbfRow, offset)
+	} else {
+		r.dbf.Seek(offset, io.SeekStart)
+		_, err = io.ReadFull(r.dbf, r.dbfRow)
+	}
+	if err != nil {
+		r.dbfRow = nil
+		return false
+	}
+	r.dbfRowNum = row
+	r.dbfRowBad = false
+	if err := checkRow(r.dbfRow); err != nil {
+		r.dbfRowBad = true
+		if !r.dbfBadRows[row] {
+			if r.dbfBadRows == nil {
+				r.dbfBadRows = make(map[int]bool)
+			}
+			r.dbfBadRows[row] = true
+			r.recordErrors = append(r.recordErrors, &RecordError{RecordNum: row + 1, Offset: offset, Err: err})
+		}
+	}
+	return true
+}
+
+// checkRow returns an error if row is not a well-formed DBF row: it must
+// start with a deletion indicator and must not contain the end-of-file
+// marker.
+func checkRow(row []byte) error {
+	if row[0] != 0x20 && row[0] != 0x2a {
+		return fmt.Errorf("attribute row starts with incorrect deletion indicator %#x", row[0])
+	}
+	if bytes.IndexByte(row, 0x1a) >= 0 {
+		return errors.New("attribute row contains end-of-file marker")
+	}
+	return nil
+}
+
+// rowBad reports whether the row of the current record is malformed.
+func (r *Reader) rowBad() bool {
+	return r.readRow(r.count-1) && r.dbfRowBad
+}
+
+// RecordErrors returns the problems with individual records that were
+// encountered so far without stopping the reader.
+func (r *Reader) RecordErrors() []*RecordError {
+	return r.recordErrors
 }
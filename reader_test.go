@@ -4,7 +4,12 @@ import (
 	"bytes"
 	"io"
 	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
 	"testing"
+	"time"
 )
 
 func pointsEqual(a, b []float64) bool {
@@ -525,3 +530,161 @@ func TestReadInvalidShapeType(t *testing.T) {
 		})
 	}
 }
+
+func TestReadPaddedRecords(t *testing.T) {
+	getters := map[string]shapeGetterFunc{
+		"reader":    getShapesFromFile,
+		"seqReader": getShapesSequentially,
+	}
+	for _, prefix := range []string{"test_files/point", "test_files/polyline"} {
+		for name, getter := range getters {
+			t.Run(name+"/"+prefix, func(t *testing.T) {
+				want := getter(prefix, t)
+				got := getter(prefix+"_padded", t)
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("padded shapes differ: got %+v, want %+v", got, want)
+				}
+			})
+		}
+	}
+}
+
+// countingFile counts the read calls that reach the file.
+type countingFile struct {
+	*os.File
+	reads int
+	delay time.Duration
+}
+
+func (f *countingFile) Read(p []byte) (int, error) {
+	f.reads++
+	time.Sleep(f.delay)
+	return f.File.Read(p)
+}
+
+func (f *countingFile) ReadAt(p []byte, off int64) (int, error) {
+	f.reads++
+	time.Sleep(f.delay)
+	return f.File.ReadAt(p, off)
+}
+
+func TestReaderReadsPerRecord(t *testing.T) {
+	for prefix, d := range dataForReadTests {
+		r, err := Open(prefix + ".shp")
+		if err != nil {
+			t.Fatal(err)
+		}
+		shp := &countingFile{File: r.shp.(*os.File)}
+		r.shp = shp
+		r.openDbf()
+		dbf := &countingFile{File: r.dbf.(*os.File)}
+		r.dbf = dbf
+		n := 0
+		for r.Next() {
+			n++
+			for i := range r.Fields() {
+				r.Attribute(i)
+				r.Attribute(i)
+			}
+		}
+		if r.Err() != nil {
+			t.Fatal(r.Err())
+		}
+		if n != d.count {
+			t.Errorf("%s: read %d records, want %d", prefix, n, d.count)
+		}
+		if shp.reads != 2*n {
+			t.Errorf("%s: %d reads from SHP for %d records, want %d", prefix, shp.reads, n, 2*n)
+		}
+		if dbf.reads != n {
+			t.Errorf("%s: %d reads from DBF for %d rows, want %d", prefix, dbf.reads, n, n)
+		}
+		r.Close()
+	}
+}
+
+// BenchmarkReaderSlowStorage reads a file from storage that has a high
+// latency for every read call, as on network filesystems.
+func BenchmarkReaderSlowStorage(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		r, err := Open("test_files/multipatch.shp")
+		if err != nil {
+			b.Fatal(err)
+		}
+		shp := &countingFile{File: r.shp.(*os.File), delay: 100 * time.Microsecond}
+		r.shp = shp
+		n := 0
+		for r.Next() {
+			r.Attribute(0)
+			n++
+		}
+		r.Close()
+		b.ReportMetric(float64(shp.reads)/float64(n), "reads/record")
+	}
+}
+
+func TestReaderFieldOffsets(t *testing.T) {
+	r, err := Open("test_files/polygon.shp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	// polygon_ID has 5 bytes and follows the deletion flag
+	if got := r.FieldOffsets(); !reflect.DeepEqual(got, []int{1, 6}) {
+		t.Errorf("got offsets %v, want [1 6]", got)
+	}
+}
+
+func TestReaderDBFRecordCount(t *testing.T) {
+	// the fixture is point with the number of records in the DBF header set
+	// to zero, as left behind by exporters that crash before finalizing it
+	r, err := Open("test_files/arcpy_zero_count.shp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if n := r.AttributeCount(); n != 3 {
+		t.Errorf("got %d attribute rows, want 3", n)
+	}
+	ws := r.Warnings()
+	if len(ws) != 1 || ws[0].Kind != WarnDBFRecordCount {
+		t.Errorf("got warnings %v", ws)
+	}
+	for r.Next() {
+		n, _ := r.Shape()
+		if got, exp := r.Attribute(0), strconv.Itoa(n+1); got != exp {
+			t.Errorf("record %d: got attribute %q, want %q", n, got, exp)
+		}
+	}
+}
+
+func TestReaderDBFRecordCountMismatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, ext := range []string{".shp", ".shx", ".dbf"} {
+		data, _ := ioutil.ReadFile("test_files/point" + ext)
+		if ext == ".dbf" {
+			data[4] = 5
+		}
+		ioutil.WriteFile(filepath.Join(dir, "point"+ext), data, 0644)
+	}
+	for _, c := range []struct {
+		opts []Option
+		want int
+	}{{nil, 5}, {[]Option{WithLenient()}, 3}} {
+		r, err := Open(filepath.Join(dir, "point.shp"), c.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := r.AttributeCount(); n != c.want {
+			t.Errorf("got %d attribute rows, want %d", n, c.want)
+		}
+		if ws := r.Warnings(); len(ws) != 1 || ws[0].Kind != WarnDBFRecordCount {
+			t.Errorf("got warnings %v", ws)
+		}
+		r.Close()
+	}
+}
@@ -0,0 +1,308 @@
+package shp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// RecoveryReport describes what OpenRecover could save from a damaged
+// shapefile.
+type RecoveryReport struct {
+	// Records is the number of records the file should contain. It is taken
+	// from the .shx if there is one. Without a .shx, the records in a
+	// damaged region are counted from the record numbers around it, and a
+	// damaged region at the end of the file counts as a single record.
+	Records int
+	// Recovered is the number of records the returned Reader yields.
+	Recovered int
+	// Lost is the number of records that could not be recovered.
+	Lost int
+	// MisparsedRows is the number of recovered records whose attribute row
+	// is malformed or missing from the .dbf.
+	MisparsedRows int
+	// MissingSidecars lists the extensions of the companion files (.shx,
+	// .dbf and .prj) that do not exist.
+	MissingSidecars []string
+	// Problems describes every record that was lost.
+	Problems []*RecordError
+}
+
+// Ratio returns the fraction of records that was recovered, or 1 if the file
+// contains no records.
+func (rep *RecoveryReport) Ratio() float64 {
+	if rep.Records == 0 {
+		return 1
+	}
+	return float64(rep.Recovered) / float64(rep.Records)
+}
+
+func (rep *RecoveryReport) String() string {
+	s := fmt.Sprintf("recovered %.1f%% of records (%d of %d, %d lost), %d attribute rows misparsed",
+		100*rep.Ratio(), rep.Recovered, rep.Records, rep.Lost, rep.MisparsedRows)
+	if len(rep.MissingSidecars) > 0 {
+		s += ", missing " + strings.Join(rep.MissingSidecars, ", ")
+	}
+	return s
+}
+
+// recoveredRecord is the position of a record that OpenRecover found to be
+// intact.
+type recoveredRecord struct {
+	// index is the position of the record in the file, which is also the
+	// row of its attributes.
+	index  int
+	offset int64
+	size   int64
+}
+
+// OpenRecover opens a damaged shapefile and saves as many records as
+// possible. It scans the whole file before returning, locating records
+// through the .shx if there is one and otherwise by following the record
+// headers and resynchronising on the next plausible header after a damaged
+// one. A record is recovered if its content decodes and is consistent with
+// the counts it contains; the content length in the record header is used
+// unless only the one in the .shx yields such a record, and a truncated last
+// record is dropped.
+// Attribute rows are read from their computed offsets, so a malformed row
+// only affects its own record.
+//
+// The returned Reader is lenient and yields exactly the recovered records,
+// paired with the attribute rows at their original positions. The report
+// describes what was recovered and what was lost.
+func OpenRecover(filename string) (*Reader, *RecoveryReport, error) {
+	r, err := Open(filename, WithLenient())
+	if err != nil {
+		return nil, nil, err
+	}
+	rep := &RecoveryReport{}
+	for _, ext := range []string{".shx", ".dbf", ".prj"} {
+		if _, err := os.Stat(r.filename + ext); err != nil {
+			rep.MissingSidecars = append(rep.MissingSidecars, ext)
+		}
+	}
+
+	if shx, err := ioutil.ReadFile(r.filename + ".shx"); err == nil && len(shx) >= 100 {
+		r.recoverFromIndex(shx, rep)
+	} else {
+		r.recoverSequential(rep)
+	}
+	if r.recovered == nil {
+		r.recovered = []recoveredRecord{}
+	}
+	rep.Recovered = len(r.recovered)
+	rep.Lost = rep.Records - rep.Recovered
+
+	if r.openDbf() == nil {
+		for _, rec := range r.recovered {
+			if !r.readRow(rec.index) || r.dbfRowBad || rec.index >= int(r.dbfNumRecords) {
+				rep.MisparsedRows++
+			}
+		}
+		r.dbfRow = nil
+	}
+	return r, rep, nil
+}
+
+// recoverFromIndex locates the records through the entries of the .shx.
+func (r *Reader) recoverFromIndex(shx []byte, rep *RecoveryReport) {
+	for i := 0; 100+8*i+8 <= len(shx); i++ {
+		entry := shx[100+8*i:]
+		offset := int64(int32(binary.BigEndian.Uint32(entry[0:4]))) * 2
+		size := int64(int32(binary.BigEndian.Uint32(entry[4:8]))) * 2
+		rep.Records++
+		if header, ok := r.headerAt(offset); ok && r.intactAt(offset, header) {
+			r.recovered = append(r.recovered, recoveredRecord{index: i, offset: offset, size: header})
+			continue
+		}
+		// the record header may be damaged where the index is right
+		if r.intactAt(offset, size) {
+			r.recovered = append(r.recovered, recoveredRecord{index: i, offset: offset, size: size})
+			continue
+		}
+		rep.Problems = append(rep.Problems, &RecordError{RecordNum: i + 1, Offset: offset, Err: fmt.Errorf("record is damaged")})
+	}
+}
+
+// recoverSequential locates the records by following the record headers.
+// After a damaged record, it continues at the next intact record with the
+// expected record number or a higher one, and counts the records whose
+// numbers were skipped as lost.
+func (r *Reader) recoverSequential(rep *RecoveryReport) {
+	offset := int64(100)
+	for offset+8 <= r.filelength {
+		index := rep.Records
+		rep.Records++
+		if size, ok := r.headerAt(offset); ok && r.intactAt(offset, size) {
+			r.recovered = append(r.recovered, recoveredRecord{index: index, offset: offset, size: size})
+			offset += 8 + size
+			continue
+		}
+		rep.Problems = append(rep.Problems, &RecordError{RecordNum: index + 1, Offset: offset, Err: fmt.Errorf("record is damaged")})
+		next, num, ok := r.nextIntactRecord(offset, int64(index+2))
+		if !ok {
+			return
+		}
+		for lost := int64(index + 2); lost < num; lost++ {
+			rep.Records++
+			rep.Problems = append(rep.Problems, &RecordError{RecordNum: int(lost), Offset: offset, Err: fmt.Errorf("record is damaged")})
+		}
+		offset = next
+	}
+}
+
+// nextIntactRecord returns the offset and number of the first intact record
+// after the damaged one at offset whose header carries the record number
+// want or a higher one.
+func (r *Reader) nextIntactRecord(damaged, want int64) (offset, num int64, ok bool) {
+	for o := damaged + 2; o+8 <= r.filelength; o += 2 {
+		// every record takes at least 12 bytes, which bounds the number of
+		// records that can have been lost before o
+		num := int64(r.numAt(o))
+		if num < want || (num-want+1)*12 > o-damaged {
+			continue
+		}
+		if size, ok := r.headerAt(o); ok && r.intactAt(o, size) {
+			return o, num, true
+		}
+	}
+	return 0, 0, false
+}
+
+// headerAt returns the content length declared in the record header at
+// offset.
+func (r *Reader) headerAt(offset int64) (int64, bool) {
+	var header [8]byte
+	if offset < 100 || offset+8 > r.filelength {
+		return 0, false
+	}
+	if _, err := r.shp.Seek(offset, io.SeekStart); err != nil {
+		return 0, false
+	}
+	if _, err := io.ReadFull(r.shp, header[:]); err != nil {
+		return 0, false
+	}
+	return int64(int32(binary.BigEndian.Uint32(header[4:8]))) * 2, true
+}
+
+// numAt returns the record number in the record header at offset.
+func (r *Reader) numAt(offset int64) int32 {
+	var num int32
+	r.shp.Seek(offset, io.SeekStart)
+	binary.Read(r.shp, binary.BigEndian, &num)
+	return num
+}
+
+// intactAt reports whether the record at offset with a content length of size
+// bytes lies within the file and decodes.
+func (r *Reader) intactAt(offset, size int64) bool {
+	if offset < 100 || size < 4 || offset+8+size > r.filelength {
+		return false
+	}
+	content, err := r.contentAt(offset, size)
+	if err != nil {
+		return false
+	}
+	t := ShapeType(binary.LittleEndian.Uint32(content[0:4]))
+	if !knownShapeType(t) {
+		return false
+	}
+	return decodes(t, content[4:])
+}
+
+// contentAt reads the content of size bytes of the record at offset into the
+// buffer of the reader.
+func (r *Reader) contentAt(offset, size int64) ([]byte, error) {
+	if int64(cap(r.buf)) < size {
+		r.buf = make([]byte, size)
+	}
+	content := r.buf[:size]
+	if _, err := r.shp.Seek(offset+8, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(r.shp, content); err != nil {
+		return nil, err
+	}
+	return content, nil
+}
+
+// decodes reports whether content decodes as a shape of type t.
+func decodes(t ShapeType, content []byte) bool {
+	_, err := readShape(t, content)
+	return err == nil
+}
+
+// plausibleContent reports whether content, the record content following the
+// shape type, is long enough for the shape of type t and the counts it
+// declares. readShape checks it before decoding, as damaged records could
+// otherwise declare counts that exhaust memory.
+func plausibleContent(t ShapeType, content []byte) bool {
+	count := func(offset int) int64 {
+		return int64(int32(binary.LittleEndian.Uint32(content[offset:])))
+	}
+	size := int64(len(content))
+	switch t {
+	case NULL:
+		return true
+	case POINT:
+		return size >= 16
+	case POINTM, POINTZ:
+		return size >= 24
+	case MULTIPOINT, MULTIPOINTM, MULTIPOINTZ:
+		if size < 36 {
+			return false
+		}
+		n := count(32)
+		need := 36 + 16*n
+		if t == MULTIPOINTZ {
+			need += 16 + 8*n
+		}
+		return n >= 0 && size >= need
+	case POLYLINE, POLYGON, POLYLINEM, POLYGONM, POLYLINEZ, POLYGONZ, MULTIPATCH:
+		if size < 40 {
+			return false
+		}
+		parts, n := count(32), count(36)
+		need := 40 + 4*parts + 16*n
+		if t == MULTIPATCH {
+			need += 4 * parts
+		}
+		if t == POLYLINEZ || t == POLYGONZ || t == MULTIPATCH {
+			need += 16 + 8*n
+		}
+		return parts >= 0 && n >= 0 && size >= need
+	}
+	// registered types are left to their decoder
+	return true
+}
+
+// nextRecovered reads the next of the records found by OpenRecover.
+func (r *Reader) nextRecovered() (ok, skipped bool) {
+	if !r.nextRecoveredContent() {
+		return false, false
+	}
+	return r.decode(r.raw)
+}
+
+// nextRecoveredContent reads the content of the next of the records found by
+// OpenRecover into r.raw.
+func (r *Reader) nextRecoveredContent() bool {
+	if len(r.recovered) == 0 {
+		r.err = io.EOF
+		return false
+	}
+	rec := r.recovered[0]
+	r.recovered = r.recovered[1:]
+	content, err := r.contentAt(rec.offset, rec.size)
+	if err != nil {
+		r.err = fmt.Errorf("Error while reading next shape: %v", err)
+		return false
+	}
+	r.num = r.numAt(rec.offset)
+	r.count = rec.index + 1
+	r.raw = content
+	return true
+}
@@ -0,0 +1,151 @@
+package shp
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestOpenRecover(t *testing.T) {
+	cases := []struct {
+		name      string
+		records   int
+		rows      []int // attribute rows of the recovered records
+		misparsed int
+		missing   []string
+	}{
+		{"point", 3, []int{0, 1, 2}, 0, []string{".prj"}},
+		{"damaged_truncated", 3, []int{0, 1}, 0, []string{".prj"}},
+		{"damaged_header", 3, []int{0, 2}, 0, []string{".prj"}},
+		{"damaged_noshx", 3, []int{0, 2}, 0, []string{".shx", ".prj"}},
+		{"damaged_length", 2, []int{0, 1}, 0, []string{".prj"}},
+		{"damaged_dbf", 3, []int{0, 1, 2}, 1, []string{".prj"}},
+	}
+	for _, c := range cases {
+		r, rep, err := OpenRecover("test_files/" + c.name + ".shp")
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if rep.Records != c.records || rep.Recovered != len(c.rows) || rep.Lost != c.records-len(c.rows) {
+			t.Errorf("%s: got %d records, %d recovered, %d lost; want %d, %d, %d",
+				c.name, rep.Records, rep.Recovered, rep.Lost, c.records, len(c.rows), c.records-len(c.rows))
+		}
+		if len(rep.Problems) != rep.Lost {
+			t.Errorf("%s: got %d problems for %d lost records", c.name, len(rep.Problems), rep.Lost)
+		}
+		if rep.MisparsedRows != c.misparsed {
+			t.Errorf("%s: got %d misparsed rows, want %d", c.name, rep.MisparsedRows, c.misparsed)
+		}
+		if !reflect.DeepEqual(rep.MissingSidecars, c.missing) {
+			t.Errorf("%s: got missing sidecars %v, want %v", c.name, rep.MissingSidecars, c.missing)
+		}
+
+		var rows []int
+		for r.Next() {
+			n, shape := r.Shape()
+			rows = append(rows, n)
+			if shape == nil {
+				t.Errorf("%s: record %d has no shape", c.name, n)
+			}
+		}
+		if err := r.Err(); err != nil {
+			t.Errorf("%s: %v", c.name, err)
+		}
+		if !reflect.DeepEqual(rows, c.rows) {
+			t.Errorf("%s: got rows %v, want %v", c.name, rows, c.rows)
+		}
+		r.Close()
+	}
+}
+
+func TestOpenRecoverPairsAttributes(t *testing.T) {
+	// the records after the damaged one keep their own attributes
+	want, err := Open("test_files/point.shp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer want.Close()
+	var shapes []Shape
+	for want.Next() {
+		_, shape := want.Shape()
+		shapes = append(shapes, shape)
+	}
+	r, _, err := OpenRecover("test_files/damaged_noshx.shp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	for r.Next() {
+		n, shape := r.Shape()
+		if got, exp := r.Attribute(0), want.ReadAttribute(n, 0); got != exp {
+			t.Errorf("record %d: got attribute %q, want %q", n, got, exp)
+		}
+		if !reflect.DeepEqual(shape, shapes[n]) {
+			t.Errorf("record %d: got shape %v, want %v", n, shape, shapes[n])
+		}
+	}
+}
+
+func TestOpenRecoverAdjacentDamage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "points")
+	w, err := Create(filename+".shp", POINT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		w.Write(&Point{float64(i), 0})
+	}
+	w.Close()
+	os.Remove(filename + ".shx")
+
+	shp, err := ioutil.ReadFile(filename + ".shp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// records 10 and 11 get an unknown shape type
+	for _, i := range []int{9, 10} {
+		binary.LittleEndian.PutUint32(shp[100+28*i+8:], 99)
+	}
+	if err := ioutil.WriteFile(filename+".shp", shp, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r, rep, err := OpenRecover(filename + ".shp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if rep.Records != 100 || rep.Recovered != 98 || rep.Lost != 2 {
+		t.Errorf("got report %v, want 98 of 100 records", rep)
+	}
+	if len(rep.Problems) != 2 || rep.Problems[0].RecordNum != 10 || rep.Problems[1].RecordNum != 11 {
+		t.Errorf("got problems %v, want records 10 and 11", rep.Problems)
+	}
+	read := 0
+	for r.Next() {
+		n, s := r.Shape()
+		if p := s.(*Point); p.X != float64(n) {
+			t.Errorf("record %d has shape %v", n, s)
+		}
+		read++
+	}
+	if read != 98 {
+		t.Errorf("read %d records, want 98", read)
+	}
+}
+
+func TestRecoveryReportString(t *testing.T) {
+	rep := &RecoveryReport{Records: 1000, Recovered: 997, Lost: 3, MissingSidecars: []string{".prj"}}
+	want := "recovered 99.7% of records (997 of 1000, 3 lost), 0 attribute rows misparsed, missing .prj"
+	if got := rep.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
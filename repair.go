@@ -0,0 +1,136 @@
+package shp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Repair fixes problems of the shapefile at filename in place and returns a
+// description of every fix it made. It corrects the number of records in the
+// DBF header to the number of rows the file holds. If Validate reports wrong
+// content lengths, records outside of the header extent, misoriented rings or
+// an index that does not match the records, the .shp and .shx are rewritten
+// from the decoded records with the rings reoriented. Coordinates that are not
+// a number cannot be fixed and are left as they are.
+func Repair(filename string) ([]string, error) {
+	base := strings.TrimSuffix(filename, filepath.Ext(filename))
+	var fixes []string
+	fix, err := repairDBFRecordCount(base + ".dbf")
+	if err != nil && !os.IsNotExist(err) {
+		return fixes, err
+	}
+	if fix != "" {
+		fixes = append(fixes, fix)
+	}
+	shapeFixes, err := repairShapes(base)
+	return append(fixes, shapeFixes...), err
+}
+
+// repairShapes rewrites the .shp and .shx of the shapefile with the given base
+// name if Validate reports issues that rewriting fixes.
+func repairShapes(base string) ([]string, error) {
+	r, err := Open(base+".shp", WithLenient())
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	issues, err := Validate(r)
+	if err != nil {
+		return nil, err
+	}
+	var fixes []string
+	extent := false
+	for _, issue := range issues {
+		switch issue.Kind {
+		case IssueRecordLength:
+			fixes = append(fixes, fmt.Sprintf("corrected content length of record %d", issue.RecordNum))
+		case IssueRingOrientation:
+			fixes = append(fixes, fmt.Sprintf("reoriented rings of record %d", issue.RecordNum))
+		case IssueOutsideExtent:
+			extent = true
+		case IssueIndexCount:
+			fixes = append(fixes, "rebuilt .shx")
+		}
+	}
+	if extent {
+		fixes = append(fixes, "recomputed extent in header")
+	}
+	if len(fixes) == 0 {
+		return nil, nil
+	}
+
+	w, err := Create(base+".repair.shp", r.GeometryType)
+	if err != nil {
+		return nil, err
+	}
+	for r.Next() {
+		n, s := r.Shape()
+		// records that cannot be decoded become Null shapes, so that the
+		// records stay aligned with their attribute rows
+		for int(w.num) < n {
+			w.Write(&Null{})
+		}
+		fixOrientation(s)
+		w.Write(s)
+	}
+	for int(w.num) < r.count {
+		w.Write(&Null{})
+	}
+	if err := r.Err(); err != nil {
+		w.Abort()
+		return nil, fmt.Errorf("Unable to rewrite %s.shp: %v", base, err)
+	}
+	w.Close()
+	if err := w.Err(); err != nil {
+		w.Abort()
+		return nil, err
+	}
+	os.Remove(base + ".repair.dbf")
+	// the files are replaced after closing them, as required on Windows
+	r.Close()
+	for _, ext := range []string{".shp", ".shx"} {
+		if err := os.Rename(base+".repair"+ext, base+ext); err != nil {
+			return nil, err
+		}
+	}
+	return fixes, nil
+}
+
+// repairDBFRecordCount writes the number of rows of the DBF file at filename
+// into its header if the header declares a different number of records.
+func repairDBFRecordCount(filename string) (string, error) {
+	f, err := os.OpenFile(filename, os.O_RDWR, 0)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	var header struct {
+		NumRecords   int32
+		HeaderLength int16
+		RecordLength int16
+	}
+	f.Seek(4, io.SeekStart)
+	if err := binary.Read(f, binary.LittleEndian, &header); err != nil {
+		return "", fmt.Errorf("Error when reading DBF header: %v", err)
+	}
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return "", err
+	}
+	rows := dbfRowCount(size, header.HeaderLength, header.RecordLength)
+	if rows == header.NumRecords {
+		return "", nil
+	}
+	f.Seek(4, io.SeekStart)
+	if err := binary.Write(f, binary.LittleEndian, rows); err != nil {
+		return "", fmt.Errorf("Error when writing DBF header: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("set number of records in DBF header from %d to %d", header.NumRecords, rows), nil
+}
@@ -0,0 +1,45 @@
+package shp
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRepairDBFRecordCount(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, ext := range []string{".shp", ".shx", ".dbf"} {
+		data, _ := ioutil.ReadFile("test_files/arcpy_zero_count" + ext)
+		ioutil.WriteFile(filepath.Join(dir, "broken"+ext), data, 0644)
+	}
+	name := filepath.Join(dir, "broken.shp")
+
+	fixes, err := Repair(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"set number of records in DBF header from 0 to 3"}; !reflect.DeepEqual(fixes, want) {
+		t.Errorf("got fixes %q, want %q", fixes, want)
+	}
+	r, err := Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := r.AttributeCount(); n != 3 {
+		t.Errorf("got %d attribute rows after repair, want 3", n)
+	}
+	if ws := r.Warnings(); len(ws) != 0 {
+		t.Errorf("got warnings %v after repair", ws)
+	}
+	r.Close()
+
+	if fixes, err := Repair(name); err != nil || len(fixes) != 0 {
+		t.Errorf("got fixes %q and error %v for a sound file", fixes, err)
+	}
+}
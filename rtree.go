@@ -0,0 +1,251 @@
+package shp
+
+import (
+	"container/heap"
+	"math"
+	"sort"
+)
+
+// rtreeNodeSize is the number of entries of a node of a SpatialIndex.
+const rtreeNodeSize = 16
+
+// SpatialIndex is an R-tree of the bounding boxes of the records of a
+// shapefile, which finds the records that may intersect a box without reading
+// the others. It is packed with the Sort-Tile-Recursive algorithm and cannot
+// be modified after it is built.
+type SpatialIndex struct {
+	// levels holds the entries of the tree from the leaves up to the root.
+	// The entries of the leaves refer to records and the entries of the other
+	// levels to the group of rtreeNodeSize consecutive entries of the level
+	// below with the index of the entry.
+	levels [][]rtreeEntry
+}
+
+type rtreeEntry struct {
+	box   Box
+	index int
+	// rings holds the rings of polygons in the leaves, for Contains.
+	rings [][]Point
+}
+
+// BuildIndex reads all records of r and returns a SpatialIndex of their
+// bounding boxes, keyed by the index of the record as returned by Shape,
+// which can be passed to IndexedReader.ShapeAt or Reader.ReadAttribute. Null
+// shapes are not indexed. The rings of polygons are kept in the index for
+// Contains.
+func BuildIndex(r SequentialReader) (*SpatialIndex, error) {
+	var entries []rtreeEntry
+	for r.Next() {
+		n, s := r.Shape()
+		if _, ok := s.(*Null); ok || s == nil {
+			continue
+		}
+		e := rtreeEntry{box: s.BBox(), index: n}
+		if parts, points := polygonRings(s); parts != nil {
+			e.rings = splitRings(parts, points)
+		}
+		entries = append(entries, e)
+	}
+	if err := r.Err(); err != nil {
+		return nil, err
+	}
+	return newSpatialIndex(entries), nil
+}
+
+// newSpatialIndex packs entries into an R-tree.
+func newSpatialIndex(entries []rtreeEntry) *SpatialIndex {
+	si := &SpatialIndex{}
+	for {
+		packEntries(entries)
+		si.levels = append(si.levels, entries)
+		if len(entries) <= rtreeNodeSize {
+			return si
+		}
+		var parents []rtreeEntry
+		for i := 0; i < len(entries); i += rtreeNodeSize {
+			end := i + rtreeNodeSize
+			if end > len(entries) {
+				end = len(entries)
+			}
+			box := entries[i].box
+			for _, e := range entries[i+1 : end] {
+				box.Extend(e.box)
+			}
+			parents = append(parents, rtreeEntry{box: box, index: i / rtreeNodeSize})
+		}
+		entries = parents
+	}
+}
+
+// packEntries sorts entries so that consecutive groups of rtreeNodeSize
+// entries are close to each other: they are sorted by the center of their
+// boxes along X, split into vertical slices and then sorted along Y within
+// every slice.
+func packEntries(entries []rtreeEntry) {
+	center := func(e rtreeEntry) (float64, float64) {
+		return (e.box.MinX + e.box.MaxX) / 2, (e.box.MinY + e.box.MaxY) / 2
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		xi, _ := center(entries[i])
+		xj, _ := center(entries[j])
+		return xi < xj
+	})
+	nodes := math.Ceil(float64(len(entries)) / rtreeNodeSize)
+	slice := int(math.Ceil(math.Sqrt(nodes))) * rtreeNodeSize
+	for i := 0; i < len(entries); i += slice {
+		end := i + slice
+		if end > len(entries) {
+			end = len(entries)
+		}
+		s := entries[i:end]
+		sort.SliceStable(s, func(i, j int) bool {
+			_, yi := center(s[i])
+			_, yj := center(s[j])
+			return yi < yj
+		})
+	}
+}
+
+// Len returns the number of records in the index.
+func (si *SpatialIndex) Len() int {
+	return len(si.levels[0])
+}
+
+// Search returns the indexes of the records whose bounding box intersects
+// box, in ascending order.
+func (si *SpatialIndex) Search(box Box) []int {
+	var found []int
+	for _, e := range si.leaves(box) {
+		found = append(found, e.index)
+	}
+	sort.Ints(found)
+	return found
+}
+
+// Contains returns the indexes of the polygons that contain the point x, y,
+// in ascending order, e.g. to look up the region of a location. Points in
+// holes are not contained. Records that are not polygons are never returned.
+func (si *SpatialIndex) Contains(x, y float64) []int {
+	var found []int
+	for _, e := range si.leaves(Box{x, y, x, y}) {
+		if e.rings != nil && ringsContain(e.rings, x, y) {
+			found = append(found, e.index)
+		}
+	}
+	sort.Ints(found)
+	return found
+}
+
+// leaves returns the leaf entries whose box intersects box.
+func (si *SpatialIndex) leaves(box Box) []rtreeEntry {
+	var found []rtreeEntry
+	var search func(level int, entries []rtreeEntry)
+	search = func(level int, entries []rtreeEntry) {
+		for _, e := range entries {
+			if !e.box.Intersects(box) {
+				continue
+			}
+			if level == 0 {
+				found = append(found, e)
+			} else {
+				search(level-1, si.children(level, e))
+			}
+		}
+	}
+	top := len(si.levels) - 1
+	search(top, si.levels[top])
+	return found
+}
+
+// children returns the entries of the level below level that e groups.
+func (si *SpatialIndex) children(level int, e rtreeEntry) []rtreeEntry {
+	children := si.levels[level-1]
+	start := e.index * rtreeNodeSize
+	end := start + rtreeNodeSize
+	if end > len(children) {
+		end = len(children)
+	}
+	return children[start:end]
+}
+
+// ringsContain reports whether the point x, y lies inside an odd number of
+// rings.
+func ringsContain(rings [][]Point, x, y float64) bool {
+	inside := false
+	for _, ring := range rings {
+		for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+			a, b := ring[i], ring[j]
+			if (a.Y > y) != (b.Y > y) && x < (b.X-a.X)*(y-a.Y)/(b.Y-a.Y)+a.X {
+				inside = !inside
+			}
+		}
+	}
+	return inside
+}
+
+// Nearest returns the indexes of the k records closest to the point x, y,
+// from the closest to the farthest, e.g. to find the nearest places of a
+// point layer. The distance of other shapes is that of their bounding box,
+// which is 0 for points inside of it. Records at the same distance are
+// ordered by their index.
+func (si *SpatialIndex) Nearest(x, y float64, k int) []int {
+	var found []int
+	top := len(si.levels) - 1
+	q := &rtreeQueue{}
+	for _, e := range si.levels[top] {
+		heap.Push(q, rtreeItem{boxDistance(e.box, x, y), top, e})
+	}
+	for q.Len() > 0 && len(found) < k {
+		item := heap.Pop(q).(rtreeItem)
+		if item.level == 0 {
+			found = append(found, item.entry.index)
+			continue
+		}
+		for _, e := range si.children(item.level, item.entry) {
+			heap.Push(q, rtreeItem{boxDistance(e.box, x, y), item.level - 1, e})
+		}
+	}
+	return found
+}
+
+// boxDistance returns the distance of the point x, y from b.
+func boxDistance(b Box, x, y float64) float64 {
+	dx := math.Max(0, math.Max(b.MinX-x, x-b.MaxX))
+	dy := math.Max(0, math.Max(b.MinY-y, y-b.MaxY))
+	return math.Hypot(dx, dy)
+}
+
+// rtreeItem is an entry of a SpatialIndex at the given level, queued by its
+// distance from the point that Nearest looks for.
+type rtreeItem struct {
+	dist  float64
+	level int
+	entry rtreeEntry
+}
+
+// rtreeQueue is a priority queue of rtreeItems. Leaves come before nodes at
+// the same distance, and leaves at the same distance are ordered by index.
+type rtreeQueue []rtreeItem
+
+func (q rtreeQueue) Len() int { return len(q) }
+
+func (q rtreeQueue) Less(i, j int) bool {
+	if q[i].dist != q[j].dist {
+		return q[i].dist < q[j].dist
+	}
+	if q[i].level != q[j].level {
+		return q[i].level < q[j].level
+	}
+	return q[i].entry.index < q[j].entry.index
+}
+
+func (q rtreeQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *rtreeQueue) Push(x interface{}) { *q = append(*q, x.(rtreeItem)) }
+
+func (q *rtreeQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
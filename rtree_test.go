@@ -0,0 +1,168 @@
+package shp
+
+import (
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestSpatialIndex(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "boxes.shp")
+	w, err := Create(filename, POLYGON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rnd := rand.New(rand.NewSource(1))
+	var boxes []Box
+	for i := 0; i < 1000; i++ {
+		if i%100 == 7 {
+			w.Write(&Null{})
+			boxes = append(boxes, Box{})
+			continue
+		}
+		x, y := rnd.Float64()*100, rnd.Float64()*100
+		b := Box{x, y, x + rnd.Float64()*5, y + rnd.Float64()*5}
+		p, err := NewPolygon([][]Point{{{b.MinX, b.MinY}, {b.MinX, b.MaxY}, {b.MaxX, b.MaxY}, {b.MaxX, b.MinY}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(p)
+		boxes = append(boxes, b)
+	}
+	w.Close()
+
+	r, err := Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	si, err := BuildIndex(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if si.Len() != 990 {
+		t.Errorf("got %d records in the index, want 990", si.Len())
+	}
+	for i := 0; i < 50; i++ {
+		x, y := rnd.Float64()*100, rnd.Float64()*100
+		query := Box{x, y, x + rnd.Float64()*20, y + rnd.Float64()*20}
+		var want []int
+		for n, b := range boxes {
+			if n%100 != 7 && b.Intersects(query) {
+				want = append(want, n)
+			}
+		}
+		if got := si.Search(query); !reflect.DeepEqual(got, want) {
+			t.Errorf("query %v: got %v, want %v", query, got, want)
+		}
+	}
+
+	ir, err := OpenIndexed(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ir.Close()
+	for _, n := range si.Search(Box{50, 50, 51, 51}) {
+		s, err := ir.ShapeAt(n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.BBox() != boxes[n] {
+			t.Errorf("record %d: got box %v, want %v", n, s.BBox(), boxes[n])
+		}
+	}
+
+	if got := newSpatialIndex(nil).Search(Box{0, 0, 1, 1}); got != nil {
+		t.Errorf("got %v from an empty index", got)
+	}
+}
+
+func TestSpatialIndexContains(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "regions.shp")
+	w, err := Create(filename, POLYGON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a square with a hole, a triangle overlapping it and a distant square
+	w.Write((*Polygon)(NewPolyLine([][]Point{
+		{{0, 0}, {0, 10}, {10, 10}, {10, 0}, {0, 0}},
+		{{4, 4}, {6, 4}, {6, 6}, {4, 6}, {4, 4}},
+	})))
+	w.Write((*Polygon)(NewPolyLine([][]Point{{{5, 5}, {15, 15}, {15, 5}, {5, 5}}})))
+	w.Write(&Null{})
+	w.Write((*Polygon)(NewPolyLine([][]Point{{{100, 100}, {100, 110}, {110, 110}, {110, 100}, {100, 100}}})))
+	w.Close()
+
+	r, err := Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	si, err := BuildIndex(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		x, y float64
+		want []int
+	}{
+		{1, 1, []int{0}},
+		{5, 5.5, nil},
+		{9, 8, []int{0, 1}},
+		{14, 6, []int{1}},
+		{6, 14, nil},
+		{105, 105, []int{3}},
+	}
+	for _, test := range tests {
+		if got := si.Contains(test.x, test.y); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Contains(%v, %v) = %v, want %v", test.x, test.y, got, test.want)
+		}
+	}
+}
+
+func TestSpatialIndexNearest(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	var entries []rtreeEntry
+	var points []Point
+	for i := 0; i < 500; i++ {
+		p := Point{rnd.Float64() * 100, rnd.Float64() * 100}
+		points = append(points, p)
+		entries = append(entries, rtreeEntry{box: p.BBox(), index: i})
+	}
+	si := newSpatialIndex(entries)
+	for i := 0; i < 20; i++ {
+		x, y := rnd.Float64()*100, rnd.Float64()*100
+		got := si.Nearest(x, y, 5)
+		want := make([]int, len(points))
+		for n := range want {
+			want[n] = n
+		}
+		dist := func(n int) float64 {
+			return (points[n].X-x)*(points[n].X-x) + (points[n].Y-y)*(points[n].Y-y)
+		}
+		sort.Slice(want, func(i, j int) bool { return dist(want[i]) < dist(want[j]) })
+		if !reflect.DeepEqual(got, want[:5]) {
+			t.Errorf("Nearest(%v, %v, 5) = %v, want %v", x, y, got, want[:5])
+		}
+	}
+	if got := si.Nearest(0, 0, 1000); len(got) != 500 {
+		t.Errorf("got %d records, want 500", len(got))
+	}
+	if got := newSpatialIndex(nil).Nearest(0, 0, 1); got != nil {
+		t.Errorf("got %v from an empty index", got)
+	}
+}
@@ -0,0 +1,160 @@
+package shp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"sort"
+)
+
+// SBN is the spatial index of a shapefile in the .sbn format of ESRI. The
+// format is not documented; it is read as reverse engineered by shapelib.
+// Readers load the .sbn next to a shapefile if there is no .qix, to skip the
+// records outside of the box set by SetFilterBBox without reading them. The
+// .sbx only holds the offsets of the bins of the .sbn and is not needed.
+type SBN struct {
+	// Count is the number of records of the shapefile.
+	Count int
+	// Box is the extent of the shapefile, onto which the boxes of the
+	// records are mapped as 256 by 256 cells.
+	Box      Box
+	features []sbnFeature
+}
+
+// sbnFeature is a record in a bin of an .sbn: its index and its bounding box
+// in cells of the extent.
+type sbnFeature struct {
+	id  int32
+	box [4]byte
+}
+
+// sbnBinSize is the maximum number of records in a bin. The records of a node
+// of the tree are spread over consecutive bins.
+const sbnBinSize = 100
+
+// OpenSBN reads the .sbn file at path.
+func OpenSBN(path string) (*SBN, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	be := binary.BigEndian
+	if len(b) < 108 || be.Uint32(b) != 0x270a && be.Uint32(b) != 0x270d || int32(be.Uint32(b[4:])) != -400 {
+		return nil, fmt.Errorf("Unable to read %s: not an .sbn file", path)
+	}
+	s := &SBN{Count: int(int32(be.Uint32(b[28:])))}
+	if s.Count < 0 {
+		return nil, fmt.Errorf("Unable to read %s: invalid number of shapes %d", path, s.Count)
+	}
+	if s.Count == 0 {
+		return s, nil
+	}
+	f := func(i int) float64 { return math.Float64frombits(binary.LittleEndian.Uint64(b[i:])) }
+	s.Box = Box{f(32), f(40), f(48), f(56)}
+
+	// the bin header is followed by a descriptor for every node of the
+	// tree, holding the offset of its first bin and its number of records
+	if be.Uint32(b[100:]) != 1 {
+		return nil, fmt.Errorf("Unable to read %s: invalid bin header", path)
+	}
+	size := int(int32(be.Uint32(b[104:]))) * 2
+	if size <= 0 || size%8 != 0 || 108+size > len(b) {
+		return nil, fmt.Errorf("Unable to read %s: invalid size of the bin header %d", path, size)
+	}
+	for node := 0; node < size/8; node++ {
+		d := b[108+8*node:]
+		start, count := int(int32(be.Uint32(d)))*2, int(int32(be.Uint32(d[4:])))
+		if count < 0 || count > s.Count || start > 0 && count == 0 {
+			return nil, fmt.Errorf("Unable to read %s: invalid descriptor of node %d", path, node+1)
+		}
+		for i := 0; i < count; i++ {
+			if i%sbnBinSize == 0 {
+				n := count - i
+				if n > sbnBinSize {
+					n = sbnBinSize
+				}
+				if start < 0 || start+8+8*n > len(b) {
+					return nil, fmt.Errorf("Unable to read %s: unexpected end of file", path)
+				}
+				if id, words := int(int32(be.Uint32(b[start:]))), int(int32(be.Uint32(b[start+4:]))); id != node+1 || words*2 != 8*n {
+					return nil, fmt.Errorf("Unable to read %s: invalid bin of node %d", path, node+1)
+				}
+				start += 8
+			}
+			var feature sbnFeature
+			copy(feature.box[:], b[start:start+4])
+			if feature.id = int32(be.Uint32(b[start+4:])) - 1; feature.id < 0 || int(feature.id) >= s.Count {
+				return nil, fmt.Errorf("Unable to read %s: invalid shape id %d", path, feature.id+1)
+			}
+			s.features = append(s.features, feature)
+			start += 8
+		}
+	}
+	return s, nil
+}
+
+// cells returns the cells of the extent of s that cover box, which are
+// widened by a little to make up for the rounding of the records' boxes.
+func (s *SBN) cells(box Box) (b [4]int) {
+	cell := func(v, min, max float64, round func(float64) float64, slack float64) int {
+		if max <= min {
+			return 0
+		}
+		c := int(round((v-min)/(max-min)*255 + slack))
+		if c < 0 {
+			return 0
+		}
+		if c > 255 {
+			return 255
+		}
+		return c
+	}
+	return [4]int{
+		cell(box.MinX, s.Box.MinX, s.Box.MaxX, math.Floor, -0.005),
+		cell(box.MinY, s.Box.MinY, s.Box.MaxY, math.Floor, -0.005),
+		cell(box.MaxX, s.Box.MinX, s.Box.MaxX, math.Ceil, 0.005),
+		cell(box.MaxY, s.Box.MinY, s.Box.MaxY, math.Ceil, 0.005),
+	}
+}
+
+// Search returns the indexes of the records that may intersect box, in
+// ascending order. The index only holds the boxes of the records in cells of
+// the extent, so the records still need to be checked.
+func (s *SBN) Search(box Box) []int {
+	if s.Count == 0 || !s.Box.Intersects(box) {
+		return nil
+	}
+	c := s.cells(box)
+	var found []int
+	for _, f := range s.features {
+		if int(f.box[0]) <= c[2] && int(f.box[2]) >= c[0] && int(f.box[1]) <= c[3] && int(f.box[3]) >= c[1] {
+			found = append(found, int(f.id))
+		}
+	}
+	sort.Ints(found)
+	return found
+}
+
+// spatialIndex is implemented by QIX and SBN.
+type spatialIndex interface {
+	Search(box Box) []int
+}
+
+// loadSpatialIndex returns the index of the shapefile of r if it has a .qix
+// or, failing that, an .sbn file.
+func (r *Reader) loadSpatialIndex() spatialIndex {
+	if q := r.loadQIX(); q != nil {
+		return q
+	}
+	for _, ext := range []string{".sbn", ".SBN"} {
+		if _, err := os.Stat(r.filename + ext); err != nil {
+			continue
+		}
+		if s, err := OpenSBN(r.filename + ext); err == nil {
+			return s
+		}
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package shp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeTestSBN writes an .sbn at path whose tree has three nodes: the records
+// before split are in the root, the others in its second child.
+func writeTestSBN(t *testing.T, path string, extent Box, boxes []Box, split int) {
+	cell := func(v, min, max float64, round func(float64) float64) byte {
+		return byte(math.Max(0, math.Min(255, round((v-min)/(max-min)*255))))
+	}
+	var bins bytes.Buffer
+	be := binary.BigEndian
+	descriptors := make([]byte, 3*8)
+	binsStart := 108 + len(descriptors)
+	for node, ids := range [][2]int{{0, split}, {0, 0}, {split, len(boxes)}} {
+		count := ids[1] - ids[0]
+		if count > 0 {
+			be.PutUint32(descriptors[8*node:], uint32((binsStart+bins.Len())/2))
+			be.PutUint32(descriptors[8*node+4:], uint32(count))
+		}
+		for i := ids[0]; i < ids[1]; i++ {
+			if (i-ids[0])%100 == 0 {
+				n := ids[1] - i
+				if n > 100 {
+					n = 100
+				}
+				binary.Write(&bins, be, [2]int32{int32(node + 1), int32(4 * n)})
+			}
+			b := boxes[i]
+			bins.Write([]byte{
+				cell(b.MinX, extent.MinX, extent.MaxX, math.Floor),
+				cell(b.MinY, extent.MinY, extent.MaxY, math.Floor),
+				cell(b.MaxX, extent.MinX, extent.MaxX, math.Ceil),
+				cell(b.MaxY, extent.MinY, extent.MaxY, math.Ceil),
+			})
+			binary.Write(&bins, be, int32(i+1))
+		}
+	}
+	header := make([]byte, 108)
+	be.PutUint32(header, 0x270a)
+	be.PutUint32(header[4:], uint32(0xfffffe70))
+	be.PutUint32(header[24:], uint32((binsStart+bins.Len())/2))
+	be.PutUint32(header[28:], uint32(len(boxes)))
+	for i, v := range []float64{extent.MinX, extent.MinY, extent.MaxX, extent.MaxY} {
+		binary.LittleEndian.PutUint64(header[32+8*i:], math.Float64bits(v))
+	}
+	be.PutUint32(header[100:], 1)
+	be.PutUint32(header[104:], uint32(len(descriptors)/2))
+	b := append(append(header, descriptors...), bins.Bytes()...)
+	if err := ioutil.WriteFile(path, b, 0666); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSBN(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "grid.shp")
+	w, err := Create(filename, POLYLINE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var boxes []Box
+	for i := 0; i < 400; i++ {
+		x, y := float64(i%20), float64(i/20)
+		line := NewPolyLine([][]Point{{{x, y}, {x + 0.5, y + 0.2}}})
+		w.Write(line)
+		boxes = append(boxes, line.Box)
+	}
+	w.Close()
+
+	sbnname := filepath.Join(dir, "grid.sbn")
+	writeTestSBN(t, sbnname, Box{0, 0, 19.5, 19.2}, boxes, 150)
+	s, err := OpenSBN(sbnname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Count != 400 {
+		t.Errorf("got count %d, want 400", s.Count)
+	}
+	filter := Box{MinX: 4.5, MinY: 3.1, MaxX: 5.2, MaxY: 4.2}
+	found := s.Search(filter)
+	if len(found) >= 400 {
+		t.Errorf("the index found %d records", len(found))
+	}
+	want := []int{64, 65, 84, 85}
+	for _, n := range want {
+		if !containsInt(found, n) {
+			t.Errorf("the index did not find record %d in %v", n, found)
+		}
+	}
+	if all := s.Search(Box{-1, -1, 100, 100}); len(all) != 400 {
+		t.Errorf("got %d records for the whole extent, want 400", len(all))
+	}
+	if none := s.Search(Box{30, 30, 40, 40}); len(none) != 0 {
+		t.Errorf("got %v outside of the extent", none)
+	}
+
+	r, err := Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	r.SetFilterBBox(filter)
+	if r.candidates == nil {
+		t.Fatal("the .sbn was not loaded")
+	}
+	var got []int
+	for r.Next() {
+		n, _ := r.Shape()
+		got = append(got, n)
+	}
+	if err := r.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got records %v, want %v", got, want)
+	}
+
+	b, err := ioutil.ReadFile(sbnname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(sbnname, b[:len(b)-8], 0666); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenSBN(sbnname); err == nil {
+		t.Error("expected an error for a truncated .sbn")
+	}
+}
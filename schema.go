@@ -0,0 +1,192 @@
+package shp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// CopySchema sets the fields of dst to those of src, keeping their names,
+// types, sizes and precisions exactly, so that attributes read from src can
+// be written to dst unchanged. Like SetFields it must be called before any
+// attributes are written to dst.
+func CopySchema(dst *Writer, src SequentialReader) error {
+	fields := make([]Field, len(src.Fields()))
+	copy(fields, src.Fields())
+	return dst.SetFields(fields)
+}
+
+// SetFieldsFrom sets the fields of the Writer to those of r, see CopySchema.
+func (w *Writer) SetFieldsFrom(r SequentialReader) error {
+	return CopySchema(w, r)
+}
+
+// Fields is a list of fields, as returned by the Fields method of readers,
+// e.g. Fields(r.Fields()).IndexOf("NAME").
+type Fields []Field
+
+// IndexOf returns the index of the field called name, ignoring case, or -1 if
+// there is none.
+func (fs Fields) IndexOf(name string) int {
+	return fieldIndex(fs, name)
+}
+
+// NewField returns a field of the given DBF type, e.g. 'C' for characters,
+// 'N' for numbers, 'F' for floating point numbers, 'L' for logical values or
+// 'D' for dates, after checking that the field is valid, see Field.Validate.
+func NewField(name string, fieldtype byte, length, decimals uint8) (Field, error) {
+	f := Field{Fieldtype: fieldtype, Size: length, Precision: decimals}
+	if len(name) > 10 {
+		return f, fmt.Errorf("Invalid field %s: name longer than 10 bytes", name)
+	}
+	copy(f.Name[:], name)
+	return f, f.Validate()
+}
+
+// NewStringField returns a character field like StringField, or an error if
+// the name or length is invalid, see Field.Validate.
+func NewStringField(name string, length uint8) (Field, error) {
+	return NewField(name, 'C', length, 0)
+}
+
+// NewNumberField returns a number field like NumberField, or an error if the
+// name or length is invalid, see Field.Validate.
+func NewNumberField(name string, length uint8) (Field, error) {
+	return NewField(name, 'N', length, 0)
+}
+
+// NewFloatField returns a floating point field like FloatField, or an error
+// if the name, length or precision is invalid, see Field.Validate.
+func NewFloatField(name string, length, precision uint8) (Field, error) {
+	return NewField(name, 'F', length, precision)
+}
+
+// NewLogicalField returns a logical field like LogicalField, or an error if
+// the name is invalid, see Field.Validate.
+func NewLogicalField(name string) (Field, error) {
+	return NewField(name, 'L', 1, 0)
+}
+
+// NewDateField returns a date field like DateField, or an error if the name
+// is invalid, see Field.Validate.
+func NewDateField(name string) (Field, error) {
+	return NewField(name, 'D', 8, 0)
+}
+
+// Validate checks that the field has a name of up to 10 bytes and a length
+// and number of decimal places that are valid for its type: characters take
+// 1 to 254 bytes, numbers 1 to 20 with room for the decimal point and sign,
+// logical values 1 byte and dates 8.
+func (f Field) Validate() error {
+	name := f.String()
+	switch {
+	case name == "":
+		return errors.New("Invalid field: empty name")
+	case f.Name[10] != 0 || strings.ContainsRune(name, 0):
+		return fmt.Errorf("Invalid field %s: name longer than 10 bytes", name)
+	}
+	switch f.Fieldtype {
+	case 'C':
+		if f.Size < 1 || f.Size > 254 {
+			return fmt.Errorf("Invalid field %s: length %d outside of 1 to 254", name, f.Size)
+		}
+	case 'N', 'F':
+		if f.Size < 1 || f.Size > 20 {
+			return fmt.Errorf("Invalid field %s: length %d outside of 1 to 20", name, f.Size)
+		}
+		if f.Precision > 0 && int(f.Precision) > int(f.Size)-2 {
+			return fmt.Errorf("Invalid field %s: %d decimals do not fit into length %d", name, f.Precision, f.Size)
+		}
+	case 'L':
+		if f.Size != 1 {
+			return fmt.Errorf("Invalid field %s: logical fields have length 1", name)
+		}
+	case 'D':
+		if f.Size != 8 {
+			return fmt.Errorf("Invalid field %s: date fields have length 8", name)
+		}
+	default:
+		return fmt.Errorf("Invalid field %s: unsupported type %q", name, f.Fieldtype)
+	}
+	if f.Fieldtype != 'N' && f.Fieldtype != 'F' && f.Precision != 0 {
+		return fmt.Errorf("Invalid field %s: decimals on a field of type %c", name, f.Fieldtype)
+	}
+	return nil
+}
+
+// AddField appends f to the fields of the Writer. The rows that were written
+// already are rewritten with an empty value for the new field. The DBF must
+// be readable for that, which it is unless the Writer was created by
+// NewWriterFrom.
+func (w *Writer) AddField(f Field) error {
+	if err := f.Validate(); err != nil {
+		return err
+	}
+	if fieldIndex(w.dbfFields, f.String()) >= 0 {
+		return fmt.Errorf("Cannot add field %s: a field of that name exists", f)
+	}
+	if w.dbf == nil {
+		return w.SetFields([]Field{f})
+	}
+	fields := append(append([]Field(nil), w.dbfFields...), f)
+	return w.rewriteDbf(fields, func(row []byte) []byte {
+		return append(row, bytes.Repeat([]byte{' '}, int(f.Size))...)
+	})
+}
+
+// DropField removes the field called name, ignoring case, from the fields of
+// the Writer along with its values in the rows that were written already,
+// see AddField.
+func (w *Writer) DropField(name string) error {
+	i := fieldIndex(w.dbfFields, name)
+	if i < 0 {
+		return fmt.Errorf("Cannot drop field %s: no such field", name)
+	}
+	offset := fieldOffsets(w.dbfFields)[i]
+	size := int(w.dbfFields[i].Size)
+	fields := append(append([]Field(nil), w.dbfFields[:i]...), w.dbfFields[i+1:]...)
+	return w.rewriteDbf(fields, func(row []byte) []byte {
+		return append(row[:offset], row[offset+size:]...)
+	})
+}
+
+// rewriteDbf changes the fields of the Writer to fields and rewrites every
+// row of the DBF with convert, which receives a copy of the old row.
+func (w *Writer) rewriteDbf(fields []Field, convert func(row []byte) []byte) error {
+	ra, ok := w.dbf.(io.ReaderAt)
+	if !ok {
+		return errors.New("Cannot change the fields of a DBF that cannot be read")
+	}
+	size := int64(w.dbfRecordLength) * int64(w.num)
+	rows := make([]byte, size)
+	if _, err := ra.ReadAt(rows, int64(w.dbfHeaderLength)); err != nil && !(err == io.EOF && size == 0) {
+		return fmt.Errorf("Unable to read DBF rows: %v", err)
+	}
+
+	recordLength := int16(1)
+	for _, f := range fields {
+		recordLength += int16(f.Size)
+	}
+	headerLength := int16(len(fields)*32 + 33)
+	var buf bytes.Buffer
+	buf.Write(make([]byte, headerLength))
+	for i := int64(0); i < int64(w.num); i++ {
+		row := append([]byte(nil), rows[i*int64(w.dbfRecordLength):(i+1)*int64(w.dbfRecordLength)]...)
+		buf.Write(convert(row))
+	}
+	if _, err := w.dbf.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := w.dbf.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("Unable to rewrite DBF: %v", err)
+	}
+	if t, ok := w.dbf.(interface{ Truncate(int64) error }); ok {
+		if err := t.Truncate(int64(buf.Len())); err != nil {
+			return fmt.Errorf("Unable to rewrite DBF: %v", err)
+		}
+	}
+	w.dbfFields, w.dbfRecordLength, w.dbfHeaderLength = fields, recordLength, headerLength
+	return nil
+}
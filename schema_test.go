@@ -0,0 +1,196 @@
+package shp
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCopySchema(t *testing.T) {
+	dir, err := ioutil.TempDir("", "shp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	src := filepath.Join(dir, "src.shp")
+	dst := filepath.Join(dir, "dst.shp")
+
+	w, err := Create(src, POINT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SetFields([]Field{
+		StringField("NAME", 33),
+		FloatField("VALUE", 19, 11),
+		NumberField("COUNT", 7),
+		DateField("DAY"),
+	})
+	w.Write(&Point{1, 2})
+	w.WriteAttribute(0, 1, 3.25)
+	w.Close()
+
+	r, err := Open(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	w, err = Create(dst, POINT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.SetFieldsFrom(r); err != nil {
+		t.Fatal(err)
+	}
+	for r.Next() {
+		n, s := r.Shape()
+		w.Write(s)
+		w.WriteAttribute(n, 1, r.ReadAttribute(n, 1))
+	}
+	w.Close()
+	if err := CopySchema(w, r); err == nil {
+		t.Error("CopySchema to a writer with fields succeeded")
+	}
+
+	copied, err := Open(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer copied.Close()
+	if !reflect.DeepEqual(copied.Fields(), r.Fields()) {
+		t.Errorf("fields = %v, want %v", copied.Fields(), r.Fields())
+	}
+	copied.Next()
+	if got, want := copied.ReadAttribute(0, 1), r.ReadAttribute(0, 1); got != want {
+		t.Errorf("attribute = %q, want %q", got, want)
+	}
+}
+
+func TestNewField(t *testing.T) {
+	tests := []struct {
+		name      string
+		fieldtype byte
+		length    uint8
+		decimals  uint8
+		valid     bool
+	}{
+		{"NAME", 'C', 254, 0, true},
+		{"NAME", 'C', 0, 0, false},
+		{"LONGERNAME1", 'C', 10, 0, false},
+		{"", 'C', 10, 0, false},
+		{"VALUE", 'N', 10, 3, true},
+		{"VALUE", 'N', 10, 9, false},
+		{"VALUE", 'F', 21, 0, false},
+		{"FLAG", 'L', 1, 0, true},
+		{"FLAG", 'L', 2, 0, false},
+		{"DAY", 'D', 8, 0, true},
+		{"DAY", 'D', 8, 2, false},
+		{"BLOB", 'B', 10, 0, false},
+	}
+	for _, test := range tests {
+		f, err := NewField(test.name, test.fieldtype, test.length, test.decimals)
+		if (err == nil) != test.valid {
+			t.Errorf("NewField(%q, %c, %d, %d): got error %v", test.name, test.fieldtype, test.length, test.decimals, err)
+		}
+		if err == nil && (f.String() != test.name || f.DecimalPlaces() != int(test.decimals)) {
+			t.Errorf("NewField(%q, ...) = %v", test.name, f)
+		}
+	}
+	if err := StringField("NAME", 10).Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+	constructors := []struct {
+		name  string
+		new   func(name string) (Field, error)
+		field Field
+	}{
+		{"NewStringField", func(n string) (Field, error) { return NewStringField(n, 10) }, StringField("NAME", 10)},
+		{"NewNumberField", func(n string) (Field, error) { return NewNumberField(n, 5) }, NumberField("NAME", 5)},
+		{"NewFloatField", func(n string) (Field, error) { return NewFloatField(n, 12, 4) }, FloatField("NAME", 12, 4)},
+		{"NewLogicalField", NewLogicalField, LogicalField("NAME")},
+		{"NewDateField", NewDateField, DateField("NAME")},
+	}
+	for _, c := range constructors {
+		if f, err := c.new("NAME"); err != nil || f != c.field {
+			t.Errorf("%s(NAME) = %v, %v, want %v", c.name, f, err, c.field)
+		}
+		if _, err := c.new("LONGERNAME1"); err == nil {
+			t.Errorf("%s accepted a name longer than 10 bytes", c.name)
+		}
+		if _, err := c.new(""); err == nil {
+			t.Errorf("%s accepted an empty name", c.name)
+		}
+	}
+	if _, err := NewStringField("NAME", 0); err == nil {
+		t.Error("NewStringField accepted length 0")
+	}
+	if _, err := NewNumberField("NAME", 21); err == nil {
+		t.Error("NewNumberField accepted length 21")
+	}
+	if _, err := NewFloatField("NAME", 5, 4); err == nil {
+		t.Error("NewFloatField accepted 4 decimals in length 5")
+	}
+	fields := Fields{StringField("NAME", 10), NumberField("Count", 5)}
+	if i := fields.IndexOf("count"); i != 1 {
+		t.Errorf("IndexOf(count) = %d, want 1", i)
+	}
+	if i := fields.IndexOf("missing"); i != -1 {
+		t.Errorf("IndexOf(missing) = %d, want -1", i)
+	}
+}
+
+func TestAddDropField(t *testing.T) {
+	dir, err := ioutil.TempDir("", "shp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "schema.shp")
+
+	w, err := Create(name, POINT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SetFields([]Field{StringField("NAME", 10), NumberField("ID", 5)})
+	for i, n := range []string{"a", "b"} {
+		w.Write(&Point{float64(i), 0})
+		w.WriteAttribute(i, 0, n)
+		w.WriteAttribute(i, 1, i+1)
+	}
+	if err := w.DropField("name"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.AddField(FloatField("VALUE", 8, 2)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.AddField(StringField("value", 3)); err == nil {
+		t.Error("AddField with a duplicate name succeeded")
+	}
+	if err := w.DropField("missing"); err == nil {
+		t.Error("DropField of a missing field succeeded")
+	}
+	w.Write(&Point{2, 0})
+	w.WriteAttribute(2, 0, 3)
+	w.WriteAttribute(2, 1, 1.5)
+	w.WriteAttribute(0, 1, 0.25)
+	w.Close()
+
+	r, err := Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	fields := r.Fields()
+	if len(fields) != 2 || fields[0].String() != "ID" || fields[1].String() != "VALUE" {
+		t.Fatalf("fields = %v", fields)
+	}
+	want := [][]string{{"1", "0.25"}, {"2", ""}, {"3", "1.50"}}
+	for n := 0; r.Next(); n++ {
+		for i, v := range want[n] {
+			if got := r.ReadAttribute(n, i); got != v {
+				t.Errorf("row %d, field %d = %q, want %q", n, i, got, v)
+			}
+		}
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"io/ioutil"
 	"math"
 	"strings"
+
+	"golang.org/x/text/encoding"
 )
 
 // SequentialReader is the interface that allows reading shapes and attributes one after another. It also embeds io.Closer.
@@ -37,10 +39,14 @@ type SequentialReader interface {
 }
 
 // Attributes returns all attributes of the shape that sr was last advanced to.
+// The readers of this package decode them from the row in one go.
 func Attributes(sr SequentialReader) []string {
 	if sr.Err() != nil {
 		return nil
 	}
+	if a, ok := sr.(interface{ Attributes() []string }); ok {
+		return a.Attributes()
+	}
 	s := make([]string, len(sr.Fields()))
 	for i := range s {
 		s[i] = sr.Attribute(i)
@@ -48,6 +54,20 @@ func Attributes(sr SequentialReader) []string {
 	return s
 }
 
+// AttributesMap returns all attributes of the shape that sr was last advanced
+// to, keyed by the names of their fields.
+func AttributesMap(sr SequentialReader) map[string]string {
+	values := Attributes(sr)
+	if values == nil {
+		return nil
+	}
+	m := make(map[string]string, len(values))
+	for i, f := range sr.Fields() {
+		m[f.String()] = values[i]
+	}
+	return m
+}
+
 // AttributeCount returns the number of fields of the database.
 func AttributeCount(sr SequentialReader) int {
 	return len(sr.Fields())
@@ -58,19 +78,36 @@ func AttributeCount(sr SequentialReader) int {
 type seqReader struct {
 	shp, dbf io.ReadCloser
 	err      error
+	opts     options
+	warnings []Warning
+	// filter is the box set by SetFilterBBox.
+	filter *Box
+	// skipGeometry is set by SetSkipGeometry.
+	skipGeometry bool
+	// transform is set by SetTransform.
+	transform Transform
 
 	geometryType ShapeType
 	bbox         Box
 
 	shape      Shape
-	num        int32
+	count      int
 	filelength int64
+	buf        []byte
+	// offset is the offset of the next record in the .shp.
+	offset int64
 
 	dbfFields       []Field
+	dbfFieldNumbers map[string]int
+	dbfOffsets      []int
 	dbfNumRecords   int32
 	dbfHeaderLength int16
 	dbfRecordLength int16
 	dbfRow          []byte
+	dbfRowBad       bool
+	dbfOffset       int64
+	recordErrors    []*RecordError
+	charset         encoding.Encoding
 }
 
 // Read and parse headers in the Shapefile. This will fill out GeometryType,
@@ -91,11 +128,15 @@ func (sr *seqReader) readHeaders() {
 	sr.bbox.MinY = readFloat64(er)
 	sr.bbox.MaxX = readFloat64(er)
 	sr.bbox.MaxY = readFloat64(er)
+	if sr.opts.swapXY {
+		sr.bbox = swapBox(sr.bbox)
+	}
 	io.CopyN(ioutil.Discard, er, 32) // skip four float64: Zmin, Zmax, Mmin, Max
 	if er.e != nil {
 		sr.err = fmt.Errorf("Error when reading SHP header: %v", er.e)
 		return
 	}
+	sr.offset = 100
 
 	// dbf header
 	er = &errReader{Reader: sr.dbf}
@@ -106,7 +147,8 @@ func (sr *seqReader) readHeaders() {
 	binary.Read(er, binary.LittleEndian, &sr.dbfNumRecords)
 	binary.Read(er, binary.LittleEndian, &sr.dbfHeaderLength)
 	binary.Read(er, binary.LittleEndian, &sr.dbfRecordLength)
-	io.CopyN(ioutil.Discard, er, 20) // skip padding
+	var reserved [20]byte
+	io.ReadFull(er, reserved[:])
 	numFields := int(math.Floor(float64(sr.dbfHeaderLength-33) / 32.0))
 	sr.dbfFields = make([]Field, numFields)
 	binary.Read(er, binary.LittleEndian, &sr.dbfFields)
@@ -120,86 +162,212 @@ func (sr *seqReader) readHeaders() {
 		sr.err = fmt.Errorf("Field descriptor array terminator not found")
 		return
 	}
+	if sr.dbfRecordLength <= 0 {
+		sr.err = fmt.Errorf("Invalid DBF record length %d", sr.dbfRecordLength)
+		return
+	}
 	sr.dbfRow = make([]byte, sr.dbfRecordLength)
+	sr.dbfOffsets = fieldOffsets(sr.dbfFields)
+	sr.charset = sr.opts.readCharset(sr.opts.cpg, reserved[17])
 }
 
 // Next implements a method of interface SequentialReader for seqReader.
 func (sr *seqReader) Next() bool {
-	if sr.err != nil {
-		return false
+	for {
+		ok, skipped := sr.next()
+		if !skipped {
+			return ok
+		}
 	}
-	var num, size int32
-	var shapetype ShapeType
+}
 
-	// read shape
-	er := &errReader{Reader: sr.shp}
-	binary.Read(er, binary.BigEndian, &num)
-	binary.Read(er, binary.BigEndian, &size)
-	binary.Read(er, binary.LittleEndian, &shapetype)
+// next reads the next record. It reports skipped = true if the record was
+// skipped in lenient mode.
+func (sr *seqReader) next() (ok, skipped bool) {
+	if sr.err != nil {
+		return false, false
+	}
+	if err := sr.opts.contextErr(); err != nil {
+		sr.err = err
+		return false, false
+	}
+	content, err := sr.advance(sr.skipGeometry)
+	if err != nil {
+		sr.err = err
+		return false, false
+	}
+	if sr.skipGeometry {
+		sr.shape = nil
+		return true, false
+	}
 
-	if er.e != nil {
-		if er.e != io.EOF {
-			sr.err = fmt.Errorf("Error when reading shapefile header: %v", er.e)
-		} else {
-			sr.err = io.EOF
+	shapetype := ShapeType(binary.LittleEndian.Uint32(content[0:4]))
+	if !knownShapeType(shapetype) {
+		if !sr.opts.lenient {
+			sr.err = sr.recordError(content, fmt.Errorf("Error decoding shape type: %w: %v", ErrUnsupportedShapeType, shapetype))
+			return false, false
 		}
-		return false
+		sr.warnings = sr.opts.addWarning(sr.warnings, Warning{
+			Kind:    WarnSkippedRecord,
+			Message: fmt.Sprintf("skipped record %d of unsupported shape type %v", sr.count, shapetype),
+		})
+		return true, true
 	}
-	sr.num = num
-	var err error
-	sr.shape, err = newShape(shapetype)
+	if filtered(sr.filter, shapetype, content[4:], sr.opts.swapXY) {
+		return true, true
+	}
+	sr.shape, err = readShape(shapetype, content[4:])
 	if err != nil {
-		sr.err = fmt.Errorf("Error decoding shape type: %v", err)
-		return false
-	}
-	sr.shape.read(er)
-	switch {
-	case er.e == io.EOF:
-		// io.EOF means end-of-file was reached gracefully after all
-		// shape-internal reads succeeded, so it's not a reason stop
-		// iterating over all shapes.
-		er.e = nil
-	case er.e != nil:
-		sr.err = fmt.Errorf("Error while reading next shape: %v", er.e)
-		return false
-	}
-	skipBytes := int64(size)*2 + 8 - er.n
-	_, ce := io.CopyN(ioutil.Discard, er, skipBytes)
-	if er.e != nil {
-		sr.err = er.e
-		return false
+		sr.err = sr.recordError(content, fmt.Errorf("Error while reading next shape: %v", err))
+		return false, false
 	}
-	if ce != nil {
-		sr.err = fmt.Errorf("Error when discarding bytes on sequential read: %v", ce)
-		return false
+	if sr.opts.swapXY {
+		transformPoints(sr.shape, swapPoint)
 	}
-	if _, err := io.ReadFull(sr.dbf, sr.dbfRow); err != nil {
-		sr.err = fmt.Errorf("Error when reading DBF row: %v", err)
-		return false
+	if sr.transform != nil {
+		transformShape(sr.shape, sr.transform)
 	}
-	if sr.dbfRow[0] != 0x20 && sr.dbfRow[0] != 0x2a {
-		sr.err = fmt.Errorf("Attribute row %d starts with incorrect deletion indicator", num)
+	return true, false
+}
+
+// recordError returns err for the record that was read last, whose content
+// is given.
+func (sr *seqReader) recordError(content []byte, err error) *RecordError {
+	return &RecordError{RecordNum: sr.count, Offset: sr.offset - 8 - int64(len(content)), Err: err}
+}
+
+// seqChunkSize is the size of the records up to which the buffer for their
+// content is allocated before reading it.
+const seqChunkSize = 1 << 20
+
+// advance moves both the shapefile and the DBF to the next record. It is the
+// only place where either stream is advanced, so that every record, whether
+// it is returned or skipped, consumes exactly one attribute row. The content
+// of the record is returned, starting with the shape type, unless discard is
+// set.
+func (sr *seqReader) advance(discard bool) ([]byte, error) {
+	var header [8]byte
+	if _, err := io.ReadFull(sr.shp, header[:]); err != nil {
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("Error when reading metadata of record %d: %v", sr.count+1, err)
+	}
+	size := int64(int32(binary.BigEndian.Uint32(header[4:8]))) * 2
+	if size < 4 {
+		return nil, &RecordError{RecordNum: sr.count + 1, Offset: sr.offset,
+			Err: fmt.Errorf("Error when reading shape type of record %d: invalid content length %d", sr.count+1, size)}
+	}
+	if sr.opts.maxRecordSize > 0 && size > sr.opts.maxRecordSize {
+		return nil, &RecordError{RecordNum: sr.count + 1, Offset: sr.offset,
+			Err: fmt.Errorf("Error when reading record %d: exceeds maximum record size of %d bytes", sr.count+1, sr.opts.maxRecordSize)}
+	}
+
+	// the whole content that is declared in the record header is read at
+	// once, so padding or over-long records never leak into the next read
+	var content []byte
+	if discard {
+		if _, err := io.CopyN(ioutil.Discard, sr.shp, size); err != nil {
+			return nil, fmt.Errorf("Error when skipping record %d: %v", sr.count+1, err)
+		}
+	} else {
+		if int64(cap(sr.buf)) >= size || size <= seqChunkSize {
+			if int64(cap(sr.buf)) < size {
+				sr.buf = make([]byte, size)
+			}
+			content = sr.buf[:size]
+			if _, err := io.ReadFull(sr.shp, content); err != nil {
+				return nil, fmt.Errorf("Error while reading next shape: %v", err)
+			}
+		} else {
+			// the length of a large record is only trusted as far as
+			// the stream has the data, so that a corrupt length does
+			// not allocate gigabytes up front
+			b, err := ioutil.ReadAll(io.LimitReader(sr.shp, size))
+			if err == nil && int64(len(b)) < size {
+				err = io.ErrUnexpectedEOF
+			}
+			if err != nil {
+				return nil, fmt.Errorf("Error while reading next shape: %v", err)
+			}
+			sr.buf, content = b, b
+		}
+	}
+	sr.count++
+	sr.offset += 8 + size
+
+	if sr.dbf != nil {
+		if _, err := io.ReadFull(sr.dbf, sr.dbfRow); err != nil {
+			return nil, fmt.Errorf("Error when reading DBF row %d: %v", sr.count, err)
+		}
+		sr.checkRow()
+		// the shapes and rows are read from separate streams, so make
+		// sure that both have advanced by the same number of records
+		if rows := sr.dbfOffset / int64(sr.dbfRecordLength); rows != int64(sr.count) {
+			return nil, fmt.Errorf("Unable to read DBF row %d: read %d records but %d attribute rows", sr.count, sr.count, rows)
+		}
+	}
+	return content, nil
+}
+
+// checkRow validates the DBF row that was just read. A malformed row is
+// reported as RecordError and its attributes are empty, but since rows have
+// a fixed width the following rows are unaffected.
+func (sr *seqReader) checkRow() {
+	offset := int64(sr.dbfHeaderLength) + sr.dbfOffset
+	sr.dbfOffset += int64(len(sr.dbfRow))
+	sr.dbfRowBad = false
+	if err := checkRow(sr.dbfRow); err != nil {
+		sr.dbfRowBad = true
+		sr.recordErrors = append(sr.recordErrors, &RecordError{RecordNum: sr.count, Offset: offset, Err: err})
 	}
-	return sr.err == nil
+}
+
+// skip advances by n records without decoding their shapes.
+func (sr *seqReader) skip(n int) error {
+	for i := 0; i < n && sr.err == nil; i++ {
+		if _, err := sr.advance(true); err != nil {
+			return err
+		}
+	}
+	return sr.err
 }
 
 // Shape implements a method of interface SequentialReader for seqReader.
 func (sr *seqReader) Shape() (int, Shape) {
-	return int(sr.num) - 1, sr.shape
+	return sr.count - 1, sr.shape
 }
 
 // Attribute implements a method of interface SequentialReader for seqReader.
 func (sr *seqReader) Attribute(n int) string {
-	if sr.err != nil {
+	if sr.err != nil || sr.dbfRowBad {
 		return ""
 	}
-	start := 1
-	f := 0
-	for ; f < n; f++ {
-		start += int(sr.dbfFields[f].Size)
+	start := sr.dbfOffsets[n]
+	s := string(sr.dbfRow[start : start+int(sr.dbfFields[n].Size)])
+	return decodeAttr(sr.charset, strings.Trim(s, " "))
+}
+
+// Attributes returns all attributes of the current row.
+func (sr *seqReader) Attributes() []string {
+	if sr.err != nil {
+		return nil
+	}
+	return rowAttributes(sr.charset, sr.dbfFields, sr.dbfOffsets, sr.dbfRow, sr.dbfRowBad)
+}
+
+// rowAttributes decodes the attributes of the given fields from row. All of
+// them are empty if the row is bad.
+func rowAttributes(charset encoding.Encoding, fields []Field, offsets []int, row []byte, bad bool) []string {
+	s := make([]string, len(fields))
+	if bad || row == nil {
+		return s
+	}
+	for i, f := range fields {
+		start := offsets[i]
+		s[i] = decodeAttr(charset, strings.Trim(string(row[start:start+int(f.Size)]), " "))
 	}
-	s := string(sr.dbfRow[start : start+int(sr.dbfFields[f].Size)])
-	return strings.Trim(s, " ")
+	return s
 }
 
 // Err returns the first non-EOF error that was encountered.
@@ -215,10 +383,10 @@ func (sr *seqReader) Close() error {
 	if err := sr.shp.Close(); err != nil {
 		return err
 	}
-	if err := sr.dbf.Close(); err != nil {
-		return err
+	if sr.dbf == nil {
+		return nil
 	}
-	return nil
+	return sr.dbf.Close()
 }
 
 // Fields returns a slice of the fields that are present in the DBF table.
@@ -226,10 +394,47 @@ func (sr *seqReader) Fields() []Field {
 	return sr.dbfFields
 }
 
+// BBox returns the bounding box of the shapefile from its header, like
+// Reader.BBox.
+func (sr *seqReader) BBox() Box {
+	if sr.transform != nil {
+		return transformBox(sr.bbox, sr.transform)
+	}
+	return sr.bbox
+}
+
+// Warnings returns the advisory warnings that were collected so far.
+func (sr *seqReader) Warnings() []Warning {
+	return sr.warnings
+}
+
+// RecordErrors returns the problems with individual records that were
+// encountered so far without stopping the reader.
+func (sr *seqReader) RecordErrors() []*RecordError {
+	return sr.recordErrors
+}
+
+func (sr *seqReader) rowBad() bool {
+	return sr.dbfRowBad
+}
+
 // SequentialReaderFromExt returns a new SequentialReader that interprets shp
-// as a source of shapes whose attributes can be retrieved from dbf.
-func SequentialReaderFromExt(shp, dbf io.ReadCloser) SequentialReader {
-	sr := &seqReader{shp: shp, dbf: dbf}
-	sr.readHeaders()
+// as a source of shapes whose attributes can be retrieved from dbf. Streams
+// that are compressed with gzip, such as those of .shp.gz and .dbf.gz files,
+// are decompressed.
+func SequentialReaderFromExt(shp, dbf io.ReadCloser, opts ...Option) SequentialReader {
+	sr := &seqReader{opts: newOptions(opts)}
+	var err error
+	if sr.shp, err = gunzipped(shp); err != nil {
+		sr.shp, sr.err = shp, fmt.Errorf("Error decompressing SHP: %v", err)
+	}
+	if dbf != nil {
+		if sr.dbf, err = gunzipped(dbf); err != nil && sr.err == nil {
+			sr.dbf, sr.err = dbf, fmt.Errorf("Error decompressing DBF: %v", err)
+		}
+	}
+	if sr.err == nil {
+		sr.readHeaders()
+	}
 	return sr
 }
@@ -1,6 +1,9 @@
 package shp
 
 import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
 	"os"
 	"testing"
 )
@@ -14,10 +17,14 @@ func openFile(name string, t *testing.T) *os.File {
 }
 
 func getShapesSequentially(prefix string, t *testing.T) (shapes []Shape) {
+	return getShapesSequentiallyWith(prefix, t)
+}
+
+func getShapesSequentiallyWith(prefix string, t *testing.T, opts ...Option) (shapes []Shape) {
 	shp := openFile(prefix+".shp", t)
 	dbf := openFile(prefix+".dbf", t)
 
-	sr := SequentialReaderFromExt(shp, dbf)
+	sr := SequentialReaderFromExt(shp, dbf, opts...)
 	if err := sr.Err(); err != nil {
 		t.Fatalf("Error when iterating over the shapefile header: %v", err)
 	}
@@ -41,3 +48,20 @@ func TestSequentialReader(t *testing.T) {
 		testshapeIdentity(t, prefix, getShapesSequentially)
 	}
 }
+
+func TestSequentialReaderCorruptLength(t *testing.T) {
+	b, err := ioutil.ReadFile("test_files/point.shp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the first record claims to be almost 4 GB long
+	binary.BigEndian.PutUint32(b[104:], 0x7fffffff)
+	sr := SequentialReaderFromExt(ioutil.NopCloser(bytes.NewReader(b)), nil)
+	defer sr.Close()
+	if sr.Next() {
+		t.Error("expected no record")
+	}
+	if sr.Err() == nil {
+		t.Error("expected an error")
+	}
+}
@@ -3,6 +3,7 @@ package shp
 import (
 	"encoding/binary"
 	"io"
+	"reflect"
 	"strings"
 )
 
@@ -43,6 +44,28 @@ func (b *Box) Extend(box Box) {
 	b.ExtendWithPoint(Point{box.MaxX, box.MaxY})
 }
 
+// Union returns the smallest box that contains both b and box.
+func (b Box) Union(box Box) Box {
+	b.Extend(box)
+	return b
+}
+
+// Intersects reports whether b and box share at least one point, including
+// points on their edges.
+func (b Box) Intersects(box Box) bool {
+	return b.MinX <= box.MaxX && box.MinX <= b.MaxX && b.MinY <= box.MaxY && box.MinY <= b.MaxY
+}
+
+// Contains reports whether box lies within b.
+func (b Box) Contains(box Box) bool {
+	return b.MinX <= box.MinX && box.MaxX <= b.MaxX && b.MinY <= box.MinY && box.MaxY <= b.MaxY
+}
+
+// ContainsPoint reports whether p lies within b or on its edges.
+func (b Box) ContainsPoint(p Point) bool {
+	return b.MinX <= p.X && p.X <= b.MaxX && b.MinY <= p.Y && p.Y <= b.MaxY
+}
+
 // ExtendWithPoint extends box with coordinates from point
 // if they are outside the range of the current box.
 func (b *Box) ExtendWithPoint(p Point) {
@@ -81,6 +104,31 @@ type Shape interface {
 	write(io.Writer)
 }
 
+// resizePoints returns s resliced to n points if it has the capacity, so that
+// decoding into a shape reuses its slices, and a new slice otherwise.
+func resizePoints(s []Point, n int32) []Point {
+	if s != nil && int(n) <= cap(s) {
+		return s[:n]
+	}
+	return make([]Point, n)
+}
+
+// resizeInt32s is resizePoints for part offsets and types.
+func resizeInt32s(s []int32, n int32) []int32 {
+	if s != nil && int(n) <= cap(s) {
+		return s[:n]
+	}
+	return make([]int32, n)
+}
+
+// resizeFloat64s is resizePoints for Z and M values.
+func resizeFloat64s(s []float64, n int32) []float64 {
+	if s != nil && int(n) <= cap(s) {
+		return s[:n]
+	}
+	return make([]float64, n)
+}
+
 // Null is an empty shape.
 type Null struct {
 }
@@ -146,19 +194,16 @@ type PolyLine struct {
 
 // NewPolyLine returns a pointer a new PolyLine created
 // with the provided points. The inner slice should be
-// the points that the parent part consists of.
+// the points that the parent part consists of. The parts
+// are not validated, use NewPolyLineChecked to reject
+// empty parts and parts of a single point.
 func NewPolyLine(parts [][]Point) *PolyLine {
 	points := flatten(parts)
 
 	p := &PolyLine{}
 	p.NumParts = int32(len(parts))
 	p.NumPoints = int32(len(points))
-	p.Parts = make([]int32, len(parts))
-	var marker int32
-	for i, part := range parts {
-		p.Parts[i] = marker
-		marker += int32(len(part))
-	}
+	p.Parts = partOffsets(parts)
 	p.Points = points
 	p.Box = p.BBox()
 
@@ -174,8 +219,8 @@ func (p *PolyLine) read(file io.Reader) {
 	binary.Read(file, binary.LittleEndian, &p.Box)
 	binary.Read(file, binary.LittleEndian, &p.NumParts)
 	binary.Read(file, binary.LittleEndian, &p.NumPoints)
-	p.Parts = make([]int32, p.NumParts)
-	p.Points = make([]Point, p.NumPoints)
+	p.Parts = resizeInt32s(p.Parts, p.NumParts)
+	p.Points = resizePoints(p.Points, p.NumPoints)
 	binary.Read(file, binary.LittleEndian, &p.Parts)
 	binary.Read(file, binary.LittleEndian, &p.Points)
 }
@@ -201,8 +246,8 @@ func (p *Polygon) read(file io.Reader) {
 	binary.Read(file, binary.LittleEndian, &p.Box)
 	binary.Read(file, binary.LittleEndian, &p.NumParts)
 	binary.Read(file, binary.LittleEndian, &p.NumPoints)
-	p.Parts = make([]int32, p.NumParts)
-	p.Points = make([]Point, p.NumPoints)
+	p.Parts = resizeInt32s(p.Parts, p.NumParts)
+	p.Points = resizePoints(p.Points, p.NumPoints)
 	binary.Read(file, binary.LittleEndian, &p.Parts)
 	binary.Read(file, binary.LittleEndian, &p.Points)
 }
@@ -230,7 +275,7 @@ func (p MultiPoint) BBox() Box {
 func (p *MultiPoint) read(file io.Reader) {
 	binary.Read(file, binary.LittleEndian, &p.Box)
 	binary.Read(file, binary.LittleEndian, &p.NumPoints)
-	p.Points = make([]Point, p.NumPoints)
+	p.Points = resizePoints(p.Points, p.NumPoints)
 	binary.Read(file, binary.LittleEndian, &p.Points)
 }
 
@@ -286,10 +331,10 @@ func (p *PolyLineZ) read(file io.Reader) {
 	binary.Read(file, binary.LittleEndian, &p.Box)
 	binary.Read(file, binary.LittleEndian, &p.NumParts)
 	binary.Read(file, binary.LittleEndian, &p.NumPoints)
-	p.Parts = make([]int32, p.NumParts)
-	p.Points = make([]Point, p.NumPoints)
-	p.ZArray = make([]float64, p.NumPoints)
-	p.MArray = make([]float64, p.NumPoints)
+	p.Parts = resizeInt32s(p.Parts, p.NumParts)
+	p.Points = resizePoints(p.Points, p.NumPoints)
+	p.ZArray = resizeFloat64s(p.ZArray, p.NumPoints)
+	p.MArray = resizeFloat64s(p.MArray, p.NumPoints)
 	binary.Read(file, binary.LittleEndian, &p.Parts)
 	binary.Read(file, binary.LittleEndian, &p.Points)
 	binary.Read(file, binary.LittleEndian, &p.ZRange)
@@ -322,10 +367,10 @@ func (p *PolygonZ) read(file io.Reader) {
 	binary.Read(file, binary.LittleEndian, &p.Box)
 	binary.Read(file, binary.LittleEndian, &p.NumParts)
 	binary.Read(file, binary.LittleEndian, &p.NumPoints)
-	p.Parts = make([]int32, p.NumParts)
-	p.Points = make([]Point, p.NumPoints)
-	p.ZArray = make([]float64, p.NumPoints)
-	p.MArray = make([]float64, p.NumPoints)
+	p.Parts = resizeInt32s(p.Parts, p.NumParts)
+	p.Points = resizePoints(p.Points, p.NumPoints)
+	p.ZArray = resizeFloat64s(p.ZArray, p.NumPoints)
+	p.MArray = resizeFloat64s(p.MArray, p.NumPoints)
 	binary.Read(file, binary.LittleEndian, &p.Parts)
 	binary.Read(file, binary.LittleEndian, &p.Points)
 	binary.Read(file, binary.LittleEndian, &p.ZRange)
@@ -365,9 +410,9 @@ func (p MultiPointZ) BBox() Box {
 func (p *MultiPointZ) read(file io.Reader) {
 	binary.Read(file, binary.LittleEndian, &p.Box)
 	binary.Read(file, binary.LittleEndian, &p.NumPoints)
-	p.Points = make([]Point, p.NumPoints)
-	p.ZArray = make([]float64, p.NumPoints)
-	p.MArray = make([]float64, p.NumPoints)
+	p.Points = resizePoints(p.Points, p.NumPoints)
+	p.ZArray = resizeFloat64s(p.ZArray, p.NumPoints)
+	p.MArray = resizeFloat64s(p.MArray, p.NumPoints)
 	binary.Read(file, binary.LittleEndian, &p.Points)
 	binary.Read(file, binary.LittleEndian, &p.ZRange)
 	binary.Read(file, binary.LittleEndian, &p.ZArray)
@@ -426,9 +471,9 @@ func (p *PolyLineM) read(file io.Reader) {
 	binary.Read(file, binary.LittleEndian, &p.Box)
 	binary.Read(file, binary.LittleEndian, &p.NumParts)
 	binary.Read(file, binary.LittleEndian, &p.NumPoints)
-	p.Parts = make([]int32, p.NumParts)
-	p.Points = make([]Point, p.NumPoints)
-	p.MArray = make([]float64, p.NumPoints)
+	p.Parts = resizeInt32s(p.Parts, p.NumParts)
+	p.Points = resizePoints(p.Points, p.NumPoints)
+	p.MArray = resizeFloat64s(p.MArray, p.NumPoints)
 	binary.Read(file, binary.LittleEndian, &p.Parts)
 	binary.Read(file, binary.LittleEndian, &p.Points)
 	binary.Read(file, binary.LittleEndian, &p.MRange)
@@ -457,9 +502,9 @@ func (p *PolygonM) read(file io.Reader) {
 	binary.Read(file, binary.LittleEndian, &p.Box)
 	binary.Read(file, binary.LittleEndian, &p.NumParts)
 	binary.Read(file, binary.LittleEndian, &p.NumPoints)
-	p.Parts = make([]int32, p.NumParts)
-	p.Points = make([]Point, p.NumPoints)
-	p.MArray = make([]float64, p.NumPoints)
+	p.Parts = resizeInt32s(p.Parts, p.NumParts)
+	p.Points = resizePoints(p.Points, p.NumPoints)
+	p.MArray = resizeFloat64s(p.MArray, p.NumPoints)
 	binary.Read(file, binary.LittleEndian, &p.Parts)
 	binary.Read(file, binary.LittleEndian, &p.Points)
 	binary.Read(file, binary.LittleEndian, &p.MRange)
@@ -493,8 +538,8 @@ func (p MultiPointM) BBox() Box {
 func (p *MultiPointM) read(file io.Reader) {
 	binary.Read(file, binary.LittleEndian, &p.Box)
 	binary.Read(file, binary.LittleEndian, &p.NumPoints)
-	p.Points = make([]Point, p.NumPoints)
-	p.MArray = make([]float64, p.NumPoints)
+	p.Points = resizePoints(p.Points, p.NumPoints)
+	p.MArray = resizeFloat64s(p.MArray, p.NumPoints)
 	binary.Read(file, binary.LittleEndian, &p.Points)
 	binary.Read(file, binary.LittleEndian, &p.MRange)
 	binary.Read(file, binary.LittleEndian, &p.MArray)
@@ -534,11 +579,11 @@ func (p *MultiPatch) read(file io.Reader) {
 	binary.Read(file, binary.LittleEndian, &p.Box)
 	binary.Read(file, binary.LittleEndian, &p.NumParts)
 	binary.Read(file, binary.LittleEndian, &p.NumPoints)
-	p.Parts = make([]int32, p.NumParts)
-	p.PartTypes = make([]int32, p.NumParts)
-	p.Points = make([]Point, p.NumPoints)
-	p.ZArray = make([]float64, p.NumPoints)
-	p.MArray = make([]float64, p.NumPoints)
+	p.Parts = resizeInt32s(p.Parts, p.NumParts)
+	p.PartTypes = resizeInt32s(p.PartTypes, p.NumParts)
+	p.Points = resizePoints(p.Points, p.NumPoints)
+	p.ZArray = resizeFloat64s(p.ZArray, p.NumPoints)
+	p.MArray = resizeFloat64s(p.MArray, p.NumPoints)
 	binary.Read(file, binary.LittleEndian, &p.Parts)
 	binary.Read(file, binary.LittleEndian, &p.PartTypes)
 	binary.Read(file, binary.LittleEndian, &p.Points)
@@ -577,17 +622,62 @@ func (f Field) String() string {
 	return strings.TrimRight(string(f.Name[:]), "\x00")
 }
 
+// Length returns the number of bytes the field takes up in a DBF row. It
+// cannot be called Size since that is the name of the struct member.
+func (f Field) Length() int {
+	return int(f.Size)
+}
+
+// DecimalPlaces returns the number of digits after the decimal point of
+// numeric fields.
+func (f Field) DecimalPlaces() int {
+	return int(f.Precision)
+}
+
+// GoKind returns the kind of the Go type that naturally holds the values of
+// the field: reflect.Int64 for numbers without decimal places,
+// reflect.Float64 for other numbers, reflect.Bool for logical fields,
+// reflect.Struct for dates, which are represented as time.Time, and
+// reflect.String for all other fields.
+func (f Field) GoKind() reflect.Kind {
+	switch f.Fieldtype {
+	case 'N':
+		if f.Precision == 0 {
+			return reflect.Int64
+		}
+		return reflect.Float64
+	case 'F':
+		return reflect.Float64
+	case 'L':
+		return reflect.Bool
+	case 'D':
+		return reflect.Struct
+	}
+	return reflect.String
+}
+
+// fieldOffsets returns the offset of every field within a DBF row. The
+// first field starts at 1, after the deletion flag.
+func fieldOffsets(fields []Field) []int {
+	offsets := make([]int, len(fields))
+	offset := 1
+	for i, f := range fields {
+		offsets[i] = offset
+		offset += int(f.Size)
+	}
+	return offsets
+}
+
 // StringField returns a Field that can be used in SetFields to initialize the
-// DBF file.
+// DBF file. It does not check the name and length, see NewStringField.
 func StringField(name string, length uint8) Field {
-	// TODO: Error checking
 	field := Field{Fieldtype: 'C', Size: length}
 	copy(field.Name[:], []byte(name))
 	return field
 }
 
 // NumberField returns a Field that can be used in SetFields to initialize the
-// DBF file.
+// DBF file. It does not check the name and length, see NewNumberField.
 func NumberField(name string, length uint8) Field {
 	field := Field{Fieldtype: 'N', Size: length}
 	copy(field.Name[:], []byte(name))
@@ -595,16 +685,27 @@ func NumberField(name string, length uint8) Field {
 }
 
 // FloatField returns a Field that can be used in SetFields to initialize the
-// DBF file. Used to store floating points with precision in the DBF.
+// DBF file. Used to store floating points with precision in the DBF. It does
+// not check the name, length and precision, see NewFloatField.
 func FloatField(name string, length uint8, precision uint8) Field {
 	field := Field{Fieldtype: 'F', Size: length, Precision: precision}
 	copy(field.Name[:], []byte(name))
 	return field
 }
 
-// DateField feturns a Field that can be used in SetFields to initialize the
+// LogicalField returns a Field that can be used in SetFields to initialize the
+// DBF file. Used to store booleans as T or F, or ? for NULL. It does not
+// check the name, see NewLogicalField.
+func LogicalField(name string) Field {
+	field := Field{Fieldtype: 'L', Size: 1}
+	copy(field.Name[:], []byte(name))
+	return field
+}
+
+// DateField returns a Field that can be used in SetFields to initialize the
 // DBF file. Used to store Date strings formatted as YYYYMMDD. Data wise this
-// is the same as a StringField with length 8.
+// is the same as a StringField with length 8. It does not check the name, see
+// NewDateField.
 func DateField(name string) Field {
 	field := Field{Fieldtype: 'D', Size: 8}
 	copy(field.Name[:], []byte(name))
@@ -1,6 +1,9 @@
 package shp
 
-import "testing"
+import (
+	"reflect"
+	"testing"
+)
 
 func TestBoxExtend(t *testing.T) {
 	a := Box{-124.763068, 45.543541, -116.915989, 49.002494}
@@ -20,3 +23,50 @@ func TestBoxExtend(t *testing.T) {
 		t.Errorf("a.MaxY = %v, want %v", a.MaxY, c.MaxY)
 	}
 }
+
+func TestFieldAccessors(t *testing.T) {
+	tests := []struct {
+		field    Field
+		length   int
+		decimals int
+		kind     reflect.Kind
+	}{
+		{StringField("NAME", 25), 25, 0, reflect.String},
+		{NumberField("COUNT", 10), 10, 0, reflect.Int64},
+		{Field{Fieldtype: 'N', Size: 12, Precision: 3}, 12, 3, reflect.Float64},
+		{FloatField("AREA", 15, 4), 15, 4, reflect.Float64},
+		{DateField("DAY"), 8, 0, reflect.Struct},
+		{Field{Fieldtype: 'L', Size: 1}, 1, 0, reflect.Bool},
+	}
+	for _, test := range tests {
+		if n := test.field.Length(); n != test.length {
+			t.Errorf("%s: got length %d, want %d", test.field, n, test.length)
+		}
+		if n := test.field.DecimalPlaces(); n != test.decimals {
+			t.Errorf("%s: got %d decimal places, want %d", test.field, n, test.decimals)
+		}
+		if k := test.field.GoKind(); k != test.kind {
+			t.Errorf("%s: got kind %v, want %v", test.field, k, test.kind)
+		}
+	}
+}
+
+func TestBoxMethods(t *testing.T) {
+	a := Box{0, 0, 2, 2}
+	b := Box{1, 1, 3, 4}
+	if got := a.Union(b); got != (Box{0, 0, 3, 4}) {
+		t.Errorf("got union %v", got)
+	}
+	if a != (Box{0, 0, 2, 2}) {
+		t.Error("Union modified the box")
+	}
+	if !a.Intersects(b) || !a.Intersects(Box{2, 2, 5, 5}) || a.Intersects(Box{2.1, 0, 3, 1}) {
+		t.Error("wrong result of Intersects")
+	}
+	if !a.Contains(Box{0, 1, 1, 2}) || a.Contains(b) {
+		t.Error("wrong result of Contains")
+	}
+	if !a.ContainsPoint(Point{2, 0}) || a.ContainsPoint(Point{-1, 1}) {
+		t.Error("wrong result of ContainsPoint")
+	}
+}
@@ -0,0 +1,93 @@
+package shp
+
+import "math"
+
+// Simplify returns a copy of shape with the vertices removed that lie within
+// tolerance of the line between the vertices that are kept, as determined by
+// the Douglas-Peucker algorithm. Every part is simplified on its own and
+// keeps its first and last vertex, so parts stay separate and rings stay
+// closed. Rings of polygons that would be left with fewer than 4 vertices are
+// kept as they are. Z values and measures of the remaining vertices are kept.
+// Points, multipoints, MultiPatches and Null shapes are returned unchanged.
+func Simplify(shape Shape, tolerance float64) Shape {
+	t := shapeTypeOf(shape, NULL)
+	d, ok := shapeDimensions[t]
+	if !ok || d.base != POLYLINE && d.base != POLYGON {
+		return shape
+	}
+	c := coordsOf(shape)
+	s := shapeCoords{parts: make([]int32, 0, len(c.parts))}
+	for _, pr := range partRanges(c.parts, len(c.points)) {
+		if pr[0] < 0 || pr[0] > pr[1] || pr[1] > len(c.points) {
+			return shape
+		}
+		kept := simplifyPart(c.points[pr[0]:pr[1]], tolerance)
+		if d.base == POLYGON && len(kept) < 4 {
+			kept = kept[:0]
+			for i := 0; i < pr[1]-pr[0]; i++ {
+				kept = append(kept, i)
+			}
+		}
+		s.parts = append(s.parts, int32(len(s.points)))
+		for _, i := range kept {
+			i += pr[0]
+			s.points = append(s.points, c.points[i])
+			if c.z != nil {
+				s.z = append(s.z, c.z[i])
+			}
+			if c.m != nil {
+				s.m = append(s.m, c.m[i])
+			}
+		}
+	}
+	s.box = BBoxFromPoints(s.points)
+	return s.shape(t)
+}
+
+// simplifyPart returns the indexes of the vertices of part that the
+// Douglas-Peucker algorithm keeps, in ascending order.
+func simplifyPart(part []Point, tolerance float64) []int {
+	if len(part) < 3 {
+		kept := make([]int, len(part))
+		for i := range kept {
+			kept[i] = i
+		}
+		return kept
+	}
+	keep := make([]bool, len(part))
+	keep[0], keep[len(part)-1] = true, true
+	// the ranges between kept vertices that are left to check
+	stack := [][2]int{{0, len(part) - 1}}
+	for len(stack) > 0 {
+		r := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		farthest, dist := -1, tolerance
+		for i := r[0] + 1; i < r[1]; i++ {
+			if d := segmentDistance(part[i], part[r[0]], part[r[1]]); d > dist {
+				farthest, dist = i, d
+			}
+		}
+		if farthest >= 0 {
+			keep[farthest] = true
+			stack = append(stack, [2]int{r[0], farthest}, [2]int{farthest, r[1]})
+		}
+	}
+	var kept []int
+	for i, k := range keep {
+		if k {
+			kept = append(kept, i)
+		}
+	}
+	return kept
+}
+
+// segmentDistance returns the distance of p from the segment from a to b.
+func segmentDistance(p, a, b Point) float64 {
+	dx, dy := b.X-a.X, b.Y-a.Y
+	if dx == 0 && dy == 0 {
+		return math.Hypot(p.X-a.X, p.Y-a.Y)
+	}
+	u := ((p.X-a.X)*dx + (p.Y-a.Y)*dy) / (dx*dx + dy*dy)
+	u = math.Max(0, math.Min(1, u))
+	return math.Hypot(p.X-(a.X+u*dx), p.Y-(a.Y+u*dy))
+}
@@ -0,0 +1,75 @@
+package shp
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSimplify(t *testing.T) {
+	line := NewPolyLine([][]Point{
+		{{0, 0}, {1, 0.1}, {2, -0.1}, {3, 5}, {4, 6}, {5, 7}, {6, 8.1}, {7, 9}},
+		{{10, 10}, {11, 10}},
+	})
+	s := Simplify(line, 0.5).(*PolyLine)
+	want := []Point{{0, 0}, {2, -0.1}, {3, 5}, {7, 9}, {10, 10}, {11, 10}}
+	if len(s.Points) != len(want) {
+		t.Fatalf("points = %v, want %v", s.Points, want)
+	}
+	for i := range want {
+		if s.Points[i] != want[i] {
+			t.Errorf("point %d = %v, want %v", i, s.Points[i], want[i])
+		}
+	}
+	if s.NumParts != 2 || s.Parts[1] != 4 {
+		t.Errorf("parts = %v", s.Parts)
+	}
+	if s.Box != (Box{0, -0.1, 11, 10}) {
+		t.Errorf("box = %v", s.Box)
+	}
+	if len(line.Points) != 10 {
+		t.Error("Simplify modified its input")
+	}
+
+	// a ring that would collapse is kept
+	ring := (*Polygon)(NewPolyLine([][]Point{{{0, 0}, {0, 0.1}, {5, 0.1}, {5, 0}, {0, 0}}}))
+	if p := Simplify(ring, 1).(*Polygon); len(p.Points) != 5 {
+		t.Errorf("ring has %d points, want 5", len(p.Points))
+	}
+
+	zs, err := NewPolyLineZ([][]Point{{{0, 0}, {1, 0}, {2, 0}}}, [][]float64{{1, 2, 3}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	z := Simplify(zs, 0.1).(*PolyLineZ)
+	if len(z.ZArray) != 2 || z.ZArray[1] != 3 || z.ZRange != [2]float64{1, 3} {
+		t.Errorf("Z values = %v, range %v", z.ZArray, z.ZRange)
+	}
+}
+
+func TestWithSimplify(t *testing.T) {
+	dir, err := ioutil.TempDir("", "shp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "simple.shp")
+	w, err := Create(name, POLYLINE, WithSimplify(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(NewPolyLine([][]Point{{{0, 0}, {1, 0.5}, {2, 0}, {3, 0.5}, {4, 0}}}))
+	w.Close()
+
+	r, err := Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	r.Next()
+	_, s := r.Shape()
+	if p := s.(*PolyLine); len(p.Points) != 2 {
+		t.Errorf("points = %v, want 2", p.Points)
+	}
+}
@@ -0,0 +1,46 @@
+package shp
+
+import "fmt"
+
+// SetSkipGeometry makes Next only advance to the next attribute row without
+// reading the .shp at all, which is much faster when only the attributes are
+// needed. Shape returns the index of the row and a nil shape. The rows of the
+// DBF table are iterated, so Next also returns the rows of a shapefile whose
+// .shp has fewer records, and the filter set by SetFilterBBox is ignored.
+func (r *Reader) SetSkipGeometry(skip bool) {
+	r.skipGeometry = skip
+}
+
+// nextRow advances to the next row of the DBF table.
+func (r *Reader) nextRow() bool {
+	if err := r.opts.contextErr(); err != nil {
+		r.err = err
+		return false
+	}
+	if err := r.openDbf(); err != nil {
+		r.err = fmt.Errorf("Unable to open DBF file: %v", err)
+		return false
+	}
+	if r.count >= int(r.dbfNumRecords) {
+		return false
+	}
+	r.count++
+	r.shape, r.raw = nil, nil
+	r.readRow(r.count - 1)
+	return true
+}
+
+// SetSkipGeometry makes Next skip over the content of the records without
+// decoding it, like Reader.SetSkipGeometry. Since the .shp is a stream, the
+// records are still read, but Shape returns a nil shape.
+func (sr *seqReader) SetSkipGeometry(skip bool) {
+	sr.skipGeometry = skip
+}
+
+// SetSkipGeometry makes Next skip over the content of the records without
+// decoding it, like Reader.SetSkipGeometry.
+func (zr *ZipReader) SetSkipGeometry(skip bool) {
+	if s, ok := zr.sr.(interface{ SetSkipGeometry(bool) }); ok {
+		s.SetSkipGeometry(skip)
+	}
+}
@@ -0,0 +1,64 @@
+package shp
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestSetSkipGeometry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "points.shp")
+	w, err := Create(filename, POINT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SetFields([]Field{NumberField("ID", 4)})
+	for i := 0; i < 10; i++ {
+		w.Write(&Point{float64(i), float64(i)})
+		w.WriteAttribute(i, 0, i)
+	}
+	w.Close()
+
+	r, err := Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	sr, err := OpenDataset(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sr.Close()
+
+	r.SetSkipGeometry(true)
+	sr.(*seqReader).SetSkipGeometry(true)
+	for _, test := range []struct {
+		name string
+		sr   SequentialReader
+	}{{"Reader", r}, {"seqReader", sr}} {
+		count := 0
+		for test.sr.Next() {
+			n, s := test.sr.Shape()
+			if s != nil {
+				t.Errorf("%s: got shape %#v for record %d, want nil", test.name, s, n)
+			}
+			if id := test.sr.Attribute(0); id != strconv.Itoa(n) || n != count {
+				t.Errorf("%s: record %d has attribute %s", test.name, n, id)
+			}
+			count++
+		}
+		if err := test.sr.Err(); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if count != 10 {
+			t.Errorf("%s: got %d records, want 10", test.name, count)
+		}
+	}
+}
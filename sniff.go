@@ -0,0 +1,88 @@
+package shp
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ErrUnsupportedFormat is returned when a file or archive that was opened as a
+// shapefile holds data of another format, such as a GeoPackage.
+type ErrUnsupportedFormat struct {
+	// Format is the name of the detected format.
+	Format string
+}
+
+func (e *ErrUnsupportedFormat) Error() string {
+	return fmt.Sprintf("Unsupported format: the input is a %s, only shapefiles are supported", e.Format)
+}
+
+// sniffLength is the number of leading bytes that are inspected to detect
+// the format of a file.
+const sniffLength = 512
+
+// sniffHeader returns the name of the format whose signature starts b, or
+// the empty string if the format is not recognized.
+func sniffHeader(b []byte) string {
+	if bytes.HasPrefix(b, []byte("SQLite format 3\x00")) {
+		return "GeoPackage"
+	}
+	text := bytes.TrimLeft(b, "\xef\xbb\xbf \t\r\n")
+	if (bytes.HasPrefix(text, []byte("<?xml")) || bytes.HasPrefix(text, []byte("<kml"))) && bytes.Contains(text, []byte("<kml")) {
+		return "KML file"
+	}
+	return ""
+}
+
+// sniffZip returns the name of the format of the data in z, judged by the
+// names of its entries, or the empty string if the format is not recognized.
+func sniffZip(z *zip.Reader) string {
+	for _, f := range z.File {
+		name := strings.ToLower(f.Name)
+		switch {
+		case strings.Contains(name, ".gdb/") || strings.HasSuffix(name, ".gdbtable") || strings.HasSuffix(name, ".gdbtablx"):
+			return "File Geodatabase"
+		case strings.HasSuffix(name, ".kml"):
+			return "KMZ archive"
+		case strings.HasSuffix(name, ".gpkg"):
+			return "GeoPackage"
+		}
+	}
+	return ""
+}
+
+// sniffReaderAt returns an ErrUnsupportedFormat if the data in r, which is
+// size bytes long, is of a format that is known not to be a shapefile.
+func sniffReaderAt(r io.ReaderAt, size int64) error {
+	head := make([]byte, sniffLength)
+	n, _ := r.ReadAt(head, 0)
+	head = head[:n]
+	if format := sniffHeader(head); format != "" {
+		return &ErrUnsupportedFormat{Format: format}
+	}
+	if bytes.HasPrefix(head, []byte("PK\x03\x04")) {
+		if z, err := zip.NewReader(r, size); err == nil && len(shapesInZip(z)) == 0 {
+			if format := sniffZip(z); format != "" {
+				return &ErrUnsupportedFormat{Format: format}
+			}
+		}
+	}
+	return nil
+}
+
+// sniffFile is sniffReaderAt for the file called name.
+func sniffFile(name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil
+	}
+	return sniffReaderAt(f, fi.Size())
+}
@@ -0,0 +1,135 @@
+package shp
+
+import (
+	"archive/zip"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeZip writes an archive called name with empty entries of the given
+// names.
+func writeZip(t *testing.T, name string, entries ...string) {
+	f, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	for _, e := range entries {
+		w, err := zw.Create(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(e))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUnsupportedFormat(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := func(name string) string { return filepath.Join(dir, name) }
+
+	sqlite := append([]byte("SQLite format 3\x00"), make([]byte, 84)...)
+	kml := []byte("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<kml xmlns=\"http://www.opengis.net/kml/2.2\"><Document/></kml>")
+	ioutil.WriteFile(path("roads.gpkg"), sqlite, 0644)
+	ioutil.WriteFile(path("renamed.shp"), sqlite, 0644)
+	ioutil.WriteFile(path("roads.kml"), kml, 0644)
+	ioutil.WriteFile(path("kml.zip"), kml, 0644)
+	writeZip(t, path("roads.kmz"), "doc.kml", "files/icon.png")
+	writeZip(t, path("roads.gdb.zip"), "roads.gdb/gdb", "roads.gdb/a00000001.gdbtable", "roads.gdb/a00000001.gdbtablx")
+
+	open := func(name string) error {
+		_, err := Open(name)
+		return err
+	}
+	openZip := func(name string) error {
+		_, err := OpenZip(name)
+		return err
+	}
+	openShapeFromZip := func(name string) error {
+		_, err := OpenShapeFromZip(name, "roads.shp")
+		return err
+	}
+	openZipReader := func(name string) error {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = OpenZipReader(f)
+		return err
+	}
+	tests := []struct {
+		name   string
+		open   func(string) error
+		format string
+	}{
+		{"roads.gpkg", open, "GeoPackage"},
+		{"renamed.shp", open, "GeoPackage"},
+		{"roads.kml", open, "KML file"},
+		{"roads.kmz", open, "KMZ archive"},
+		{"roads.gdb.zip", open, "File Geodatabase"},
+		{"roads.gpkg", openZip, "GeoPackage"},
+		{"kml.zip", openZip, "KML file"},
+		{"roads.kmz", openZip, "KMZ archive"},
+		{"roads.gdb.zip", openZip, "File Geodatabase"},
+		{"roads.gdb.zip", openShapeFromZip, "File Geodatabase"},
+		{"roads.kmz", openZipReader, "KMZ archive"},
+		{"roads.gpkg", openZipReader, "GeoPackage"},
+	}
+	for _, test := range tests {
+		err := test.open(path(test.name))
+		var uerr *ErrUnsupportedFormat
+		if !errors.As(err, &uerr) {
+			t.Errorf("%s: got error %v, want ErrUnsupportedFormat", test.name, err)
+			continue
+		}
+		if uerr.Format != test.format {
+			t.Errorf("%s: got format %q, want %q", test.name, uerr.Format, test.format)
+		}
+	}
+}
+
+func TestUnsupportedFormatShapefiles(t *testing.T) {
+	names, _ := filepath.Glob("test_files/*.shp")
+	for _, name := range names {
+		if r, err := Open(name); err == nil {
+			r.Close()
+		} else if _, ok := err.(*ErrUnsupportedFormat); ok {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// an archive with a shapefile next to a KML document is still read
+	name := filepath.Join(dir, "mixed.zip")
+	f, _ := os.Create(name)
+	zw := zip.NewWriter(f)
+	for _, src := range []string{"test_files/point.shp", "test_files/point.dbf", "test_files/point.shx"} {
+		w, _ := zw.Create(filepath.Base(src))
+		data, _ := ioutil.ReadFile(src)
+		w.Write(data)
+	}
+	w, _ := zw.Create("doc.kml")
+	w.Write([]byte("<kml/>"))
+	zw.Close()
+	f.Close()
+	zr, err := OpenZip(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zr.Close()
+}
@@ -0,0 +1,105 @@
+package shp
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// SplitByAttribute writes the records of r into one shapefile for every
+// distinct value of the field called name, ignoring case. The writer for a
+// value is created by calling create with the value, with blanks trimmed, the
+// first time it occurs, and the fields of r are set on it. To write ZIP
+// archives, create can return the Writer embedded in a ZipWriter, which the
+// caller closes afterwards. The writers are returned in a map from the values
+// and must be closed by the caller, also if an error is returned.
+func SplitByAttribute(r SequentialReader, name string, create func(value string) (*Writer, error)) (map[string]*Writer, error) {
+	field := fieldIndex(r.Fields(), name)
+	if field < 0 {
+		return nil, fmt.Errorf("Unable to split by attribute: no field called %s", name)
+	}
+	return split(r, func(Shape) (string, bool) {
+		return strings.TrimSpace(r.Attribute(field)), true
+	}, create)
+}
+
+// SplitByGrid writes the records of r into one shapefile for every cell of a
+// grid of rows by cols cells that covers the extent of r. r must have a
+// method BBox() Box that returns its extent, like Reader. Every shape is
+// assigned to the cell that contains the center of its bounding box, and Null
+// shapes are dropped. The writer for a cell is created by calling create with
+// the key "row_col" the first time a shape falls into it, where row 0 is at
+// the top, i.e. at the largest Y, and column 0 at the left. The writers are
+// returned by key and must be closed by the caller, also if an error is
+// returned.
+func SplitByGrid(r SequentialReader, rows, cols int, create func(key string) (*Writer, error)) (map[string]*Writer, error) {
+	if rows < 1 || cols < 1 {
+		return nil, fmt.Errorf("Unable to split by grid of %d by %d cells", rows, cols)
+	}
+	b, ok := r.(interface{ BBox() Box })
+	if !ok {
+		return nil, errors.New("Unable to split by grid: the extent of the reader is unknown")
+	}
+	extent := b.BBox()
+	cell := func(v, min, max float64, n int) int {
+		if max <= min {
+			return 0
+		}
+		i := int(math.Floor((v - min) / (max - min) * float64(n)))
+		if i < 0 {
+			return 0
+		}
+		if i >= n {
+			return n - 1
+		}
+		return i
+	}
+	return split(r, func(s Shape) (string, bool) {
+		if _, ok := s.(*Null); ok {
+			return "", false
+		}
+		box := s.BBox()
+		x, y := (box.MinX+box.MaxX)/2, (box.MinY+box.MaxY)/2
+		row := rows - 1 - cell(y, extent.MinY, extent.MaxY, rows)
+		col := cell(x, extent.MinX, extent.MaxX, cols)
+		return fmt.Sprintf("%d_%d", row, col), true
+	}, create)
+}
+
+// split writes every record of r for which key returns true to the writer for
+// its key, which is created by create when it is needed first.
+func split(r SequentialReader, key func(Shape) (string, bool), create func(string) (*Writer, error)) (map[string]*Writer, error) {
+	writers := make(map[string]*Writer)
+	fields := r.Fields()
+	for r.Next() {
+		n, s := r.Shape()
+		k, ok := key(s)
+		if !ok {
+			continue
+		}
+		w := writers[k]
+		if w == nil {
+			var err error
+			if w, err = create(k); err != nil {
+				return writers, err
+			}
+			writers[k] = w
+			if err := w.SetFields(fields); err != nil {
+				return writers, err
+			}
+		}
+		row := w.Write(s)
+		if row < 0 {
+			return writers, fmt.Errorf("Unable to write record %d: %v", n+1, w.Err())
+		}
+		for i := range fields {
+			if v := r.Attribute(i); v != "" {
+				if err := w.WriteAttribute(int(row), i, v); err != nil {
+					return writers, fmt.Errorf("Unable to write record %d: %v", n+1, err)
+				}
+			}
+		}
+	}
+	return writers, r.Err()
+}
@@ -0,0 +1,102 @@
+package shp
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "grid.shp")
+	w, err := Create(filename, POINT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SetFields([]Field{NumberField("ID", 4), StringField("KIND", 5)})
+	for i := 0; i < 16; i++ {
+		w.Write(&Point{float64(i % 4), float64(i / 4)})
+		w.WriteAttribute(i, 0, i)
+		w.WriteAttribute(i, 1, []string{"even", "odd"}[i%2])
+	}
+	w.Write(&Null{})
+	w.WriteAttribute(16, 1, "null")
+	w.Close()
+
+	create := func(key string) (*Writer, error) {
+		return Create(filepath.Join(dir, "part_"+key+".shp"), POINT)
+	}
+	ids := func(key string) []string {
+		var ids []string
+		r, err := Open(filepath.Join(dir, "part_"+key+".shp"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+		for r.Next() {
+			ids = append(ids, r.Attribute(0))
+		}
+		return ids
+	}
+	keys := func(writers map[string]*Writer) []string {
+		var keys []string
+		for k, w := range writers {
+			w.Close()
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		return keys
+	}
+
+	r, err := Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	writers, err := SplitByAttribute(r, "kind", create)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := keys(writers), []string{"even", "null", "odd"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got parts %v, want %v", got, want)
+	}
+	var odd []string
+	for i := 1; i < 16; i += 2 {
+		odd = append(odd, strconv.Itoa(i))
+	}
+	if got := ids("odd"); !reflect.DeepEqual(got, odd) {
+		t.Errorf("got odd records %v, want %v", got, odd)
+	}
+	if got := ids("null"); !reflect.DeepEqual(got, []string{""}) {
+		t.Errorf("got null records %v", got)
+	}
+
+	r, err = Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	writers, err = SplitByGrid(r, 2, 2, create)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := keys(writers), []string{"0_0", "0_1", "1_0", "1_1"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got cells %v, want %v", got, want)
+	}
+	// the top left cell holds the points with x < 1.5 and y >= 1.5
+	if got, want := ids("0_0"), []string{"8", "9", "12", "13"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v in the top left cell, want %v", got, want)
+	}
+
+	if _, err := SplitByAttribute(r, "missing", create); err == nil {
+		t.Error("expected an error for a missing field")
+	}
+}
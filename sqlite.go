@@ -0,0 +1,344 @@
+package shp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// sqlitePageSize is the size of the pages of the SQLite databases written by
+// sqliteWriter.
+const sqlitePageSize = 4096
+
+// sqliteHeaderSize is the size of the database header at the start of the
+// first page.
+const sqliteHeaderSize = 100
+
+// These are the types of b-tree pages.
+const (
+	sqliteIndexLeaf     = 0x0a
+	sqliteTableInterior = 0x05
+	sqliteTableLeaf     = 0x0d
+)
+
+// sqliteWriter writes a database in the file format of SQLite 3, which is
+// the container of GeoPackages. Tables are written as b-trees one after
+// another, so their rows must be added in ascending order of rowid. The
+// first page is reserved for the schema, which is written last by finish.
+type sqliteWriter struct {
+	w     io.WriterAt
+	pages uint32
+	err   error
+	// userVersion and applicationID are written to the header.
+	userVersion, applicationID uint32
+}
+
+func newSQLiteWriter(w io.WriterAt) *sqliteWriter {
+	return &sqliteWriter{w: w, pages: 1}
+}
+
+// allocate returns the number of a new page, starting at 1.
+func (sw *sqliteWriter) allocate() uint32 {
+	sw.pages++
+	return sw.pages
+}
+
+func (sw *sqliteWriter) writePage(n uint32, page []byte) {
+	if sw.err != nil {
+		return
+	}
+	_, sw.err = sw.w.WriteAt(page, int64(n-1)*sqlitePageSize)
+}
+
+// payload returns the part of payload that is stored in a cell, followed by
+// the number of the first overflow page if it does not fit. The rest is
+// written to overflow pages. maxLocal is the largest payload that is stored
+// in the cell completely.
+func (sw *sqliteWriter) payload(payload []byte, maxLocal int) []byte {
+	const usable = sqlitePageSize
+	if len(payload) <= maxLocal {
+		return payload
+	}
+	minLocal := (usable-12)*32/255 - 23
+	local := minLocal + (len(payload)-minLocal)%(usable-4)
+	if local > maxLocal {
+		local = minLocal
+	}
+	cell := append([]byte(nil), payload[:local]...)
+	rest := payload[local:]
+	next := sw.allocate()
+	cell = appendBigEndian32(cell, next)
+	for len(rest) > 0 {
+		page := make([]byte, sqlitePageSize)
+		n := copy(page[4:], rest)
+		rest = rest[n:]
+		current := next
+		if len(rest) > 0 {
+			next = sw.allocate()
+			binary.BigEndian.PutUint32(page, next)
+		}
+		sw.writePage(current, page)
+	}
+	return cell
+}
+
+// sqliteTable writes the b-tree of a table.
+type sqliteTable struct {
+	sw *sqliteWriter
+	// root is the page of the root, which is allocated by finish if it is 0.
+	// offset is the size of the database header if root is the first page.
+	root   uint32
+	offset int
+	// cells and key are the cells and the largest rowid of the leaf that is
+	// being filled.
+	cells [][]byte
+	size  int
+	key   int64
+	// children are the leaves that are written along with their largest
+	// rowids.
+	children []sqliteChild
+}
+
+type sqliteChild struct {
+	page uint32
+	key  int64
+}
+
+// fits reports whether a page of the b-tree with the given header size has
+// room for cells of size bytes in total plus one more cell.
+func (t *sqliteTable) fits(header, n, size, cell int) bool {
+	return t.offset+header+2*(n+1)+size+cell <= sqlitePageSize
+}
+
+// add adds a row with the given rowid, which must be larger than the rowids
+// of all rows added before, and the encoded record.
+func (t *sqliteTable) add(rowid int64, record []byte) {
+	cell := appendSQLiteVarint(nil, uint64(len(record)))
+	cell = appendSQLiteVarint(cell, uint64(rowid))
+	cell = append(cell, t.sw.payload(record, sqlitePageSize-35)...)
+	if len(t.cells) > 0 && !t.fits(8, len(t.cells), t.size, len(cell)) {
+		page := t.sw.allocate()
+		t.sw.writePage(page, sqlitePage(sqliteTableLeaf, t.cells, 0, 0))
+		t.children = append(t.children, sqliteChild{page, t.key})
+		t.cells, t.size = nil, 0
+	}
+	t.cells = append(t.cells, cell)
+	t.size += len(cell)
+	t.key = rowid
+}
+
+// finish writes the rest of the b-tree and returns the page of its root.
+func (t *sqliteTable) finish() uint32 {
+	if t.root == 0 {
+		t.root = t.sw.allocate()
+	}
+	if len(t.children) == 0 {
+		t.sw.writePage(t.root, sqlitePage(sqliteTableLeaf, t.cells, 0, t.offset))
+		return t.root
+	}
+	if len(t.cells) > 0 {
+		page := t.sw.allocate()
+		t.sw.writePage(page, sqlitePage(sqliteTableLeaf, t.cells, 0, 0))
+		t.children = append(t.children, sqliteChild{page, t.key})
+	}
+	// every level of interior pages points to the pages of the level below
+	// with a cell for all but the last, which is the right-most pointer
+	children := t.children
+	for {
+		var parents []sqliteChild
+		var cells [][]byte
+		size := 0
+		for i, child := range children {
+			cell := appendBigEndian32(nil, child.page)
+			cell = appendSQLiteVarint(cell, uint64(child.key))
+			last := i == len(children)-1
+			if !last && t.fits(12, len(cells), size, len(cell)) {
+				cells = append(cells, cell)
+				size += len(cell)
+				continue
+			}
+			page := uint32(0)
+			if last && len(parents) == 0 {
+				page = t.root
+			} else {
+				page = t.sw.allocate()
+			}
+			t.sw.writePage(page, sqlitePage(sqliteTableInterior, cells, child.page, t.offset))
+			parents = append(parents, sqliteChild{page, child.key})
+			cells, size = nil, 0
+		}
+		if len(parents) == 1 {
+			return t.root
+		}
+		children = parents
+	}
+}
+
+// writeIndex writes an index b-tree, whose records must be given in the
+// order of their keys, to a single page and returns its number.
+func (sw *sqliteWriter) writeIndex(records ...[]byte) (uint32, error) {
+	maxLocal := (sqlitePageSize-12)*64/255 - 23
+	var cells [][]byte
+	size := 0
+	for _, record := range records {
+		cell := appendSQLiteVarint(nil, uint64(len(record)))
+		cell = append(cell, sw.payload(record, maxLocal)...)
+		cells = append(cells, cell)
+		size += len(cell) + 2
+	}
+	if 8+size > sqlitePageSize {
+		return 0, errors.New("Unable to write index: keys exceed a page")
+	}
+	page := sw.allocate()
+	sw.writePage(page, sqlitePage(sqliteIndexLeaf, cells, 0, 0))
+	return page, nil
+}
+
+// sqlitePage returns a b-tree page of the given type with the cells, placed
+// after the database header if offset is not 0. right is the right-most
+// pointer of interior pages.
+func sqlitePage(kind byte, cells [][]byte, right uint32, offset int) []byte {
+	page := make([]byte, sqlitePageSize)
+	header := page[offset:]
+	header[0] = kind
+	pointers := 8
+	if kind == sqliteTableInterior {
+		binary.BigEndian.PutUint32(header[8:], right)
+		pointers = 12
+	}
+	binary.BigEndian.PutUint16(header[3:], uint16(len(cells)))
+	end := sqlitePageSize
+	for i, cell := range cells {
+		end -= len(cell)
+		copy(page[end:], cell)
+		binary.BigEndian.PutUint16(header[pointers+2*i:], uint16(end))
+	}
+	// a content area starting at 65536 is written as 0
+	binary.BigEndian.PutUint16(header[5:], uint16(end))
+	return page
+}
+
+// finish writes the schema, which are the rows of sqlite_master, to the
+// first page along with the database header.
+func (sw *sqliteWriter) finish(schema [][]byte) error {
+	t := &sqliteTable{sw: sw, root: 1, offset: sqliteHeaderSize}
+	for i, record := range schema {
+		t.add(int64(i+1), record)
+	}
+	t.finish()
+	if sw.err != nil {
+		return sw.err
+	}
+	header := make([]byte, sqliteHeaderSize)
+	copy(header, "SQLite format 3\x00")
+	binary.BigEndian.PutUint16(header[16:], sqlitePageSize)
+	header[18], header[19] = 1, 1 // legacy journal, not WAL
+	header[21], header[22], header[23] = 64, 32, 32
+	binary.BigEndian.PutUint32(header[24:], 1) // file change counter
+	binary.BigEndian.PutUint32(header[28:], sw.pages)
+	binary.BigEndian.PutUint32(header[40:], 1) // schema cookie
+	binary.BigEndian.PutUint32(header[44:], 4) // schema format
+	binary.BigEndian.PutUint32(header[56:], 1) // UTF-8
+	binary.BigEndian.PutUint32(header[60:], sw.userVersion)
+	binary.BigEndian.PutUint32(header[68:], sw.applicationID)
+	binary.BigEndian.PutUint32(header[92:], 1) // valid for change counter 1
+	binary.BigEndian.PutUint32(header[96:], 3031001)
+	_, err := sw.w.WriteAt(header, 0)
+	return err
+}
+
+// sqliteSchema returns the row of sqlite_master of a table or an index.
+func sqliteSchema(kind, name, table string, root uint32, sql interface{}) []byte {
+	return sqliteRecord(kind, name, table, int64(root), sql)
+}
+
+// sqliteRecord encodes values in the record format of SQLite. The values must
+// be nil, int64, float64, string or []byte.
+func sqliteRecord(values ...interface{}) []byte {
+	var types, body []byte
+	for _, v := range values {
+		switch v := v.(type) {
+		case nil:
+			types = appendSQLiteVarint(types, 0)
+		case int64:
+			switch {
+			case v == 0 || v == 1:
+				types = appendSQLiteVarint(types, uint64(8+v))
+			case v >= math.MinInt8 && v <= math.MaxInt8:
+				types = append(types, 1)
+				body = append(body, byte(v))
+			case v >= math.MinInt16 && v <= math.MaxInt16:
+				types = append(types, 2)
+				body = appendBigEndian16(body, uint16(v))
+			case v >= math.MinInt32 && v <= math.MaxInt32:
+				types = append(types, 4)
+				body = appendBigEndian32(body, uint32(v))
+			default:
+				types = append(types, 6)
+				body = appendBigEndian64(body, uint64(v))
+			}
+		case float64:
+			types = append(types, 7)
+			body = appendBigEndian64(body, math.Float64bits(v))
+		case string:
+			types = appendSQLiteVarint(types, uint64(13+2*len(v)))
+			body = append(body, v...)
+		case []byte:
+			types = appendSQLiteVarint(types, uint64(12+2*len(v)))
+			body = append(body, v...)
+		default:
+			panic(fmt.Sprintf("shp: unsupported SQLite value %T", v))
+		}
+	}
+	// the size of the header includes the varint of the size itself
+	size := len(types) + 1
+	for len(appendSQLiteVarint(nil, uint64(size))) != size-len(types) {
+		size = len(types) + len(appendSQLiteVarint(nil, uint64(size)))
+	}
+	record := appendSQLiteVarint(nil, uint64(size))
+	record = append(record, types...)
+	return append(record, body...)
+}
+
+// appendSQLiteVarint appends v as a big-endian varint of SQLite, which uses
+// all 8 bits of the ninth byte.
+func appendSQLiteVarint(b []byte, v uint64) []byte {
+	if v > 1<<56-1 {
+		var buf [9]byte
+		buf[8] = byte(v)
+		v >>= 8
+		for i := 7; i >= 0; i-- {
+			buf[i] = byte(v&0x7f) | 0x80
+			v >>= 7
+		}
+		return append(b, buf[:]...)
+	}
+	var buf [8]byte
+	n := 0
+	for {
+		buf[n] = byte(v&0x7f) | 0x80
+		n++
+		if v >>= 7; v == 0 {
+			break
+		}
+	}
+	buf[0] &^= 0x80
+	for i := n - 1; i >= 0; i-- {
+		b = append(b, buf[i])
+	}
+	return b
+}
+
+func appendBigEndian16(b []byte, v uint16) []byte {
+	return append(b, byte(v>>8), byte(v))
+}
+
+func appendBigEndian32(b []byte, v uint32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func appendBigEndian64(b []byte, v uint64) []byte {
+	return appendBigEndian32(appendBigEndian32(b, uint32(v>>32)), uint32(v))
+}
@@ -0,0 +1,137 @@
+package shp
+
+import (
+	"fmt"
+	"math"
+)
+
+// SetStrict makes Write reject shapes that violate the specification instead
+// of writing them as they are: counts that do not match the points, part
+// offsets that are out of range or not ascending, rings with fewer than 4
+// points or whose last point differs from the first, and coordinates, Z
+// values or measures that are NaN or infinite. Shapes are checked after the
+// transformations set up for the Writer. A rejected shape is not written,
+// Write returns -1 and Err describes the violation, which wraps
+// ErrInvalidShape. Attribute values that exceed the width of their field are
+// rejected by WriteAttribute regardless of this setting.
+func (w *Writer) SetStrict(strict bool) {
+	w.strict = strict
+}
+
+// shapeParts returns the declared counts, the part offsets and the points of
+// s. Shapes without parts have none, and ok is false for points.
+func shapeParts(s Shape) (numParts, numPoints int32, parts []int32, points []Point, ok bool) {
+	switch s := s.(type) {
+	case *MultiPoint:
+		return 0, s.NumPoints, nil, s.Points, true
+	case *MultiPointZ:
+		return 0, s.NumPoints, nil, s.Points, true
+	case *MultiPointM:
+		return 0, s.NumPoints, nil, s.Points, true
+	case *PolyLine:
+		return s.NumParts, s.NumPoints, s.Parts, s.Points, true
+	case *Polygon:
+		return s.NumParts, s.NumPoints, s.Parts, s.Points, true
+	case *PolyLineZ:
+		return s.NumParts, s.NumPoints, s.Parts, s.Points, true
+	case *PolygonZ:
+		return s.NumParts, s.NumPoints, s.Parts, s.Points, true
+	case *PolyLineM:
+		return s.NumParts, s.NumPoints, s.Parts, s.Points, true
+	case *PolygonM:
+		return s.NumParts, s.NumPoints, s.Parts, s.Points, true
+	case *MultiPatch:
+		return s.NumParts, s.NumPoints, s.Parts, s.Points, true
+	}
+	return 0, 0, nil, nil, false
+}
+
+// isFinite reports whether v is neither NaN nor infinite.
+func isFinite(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
+
+// checkShape returns an error wrapping ErrInvalidShape if s violates the
+// specification in one of the ways that SetStrict describes.
+func checkShape(s Shape) error {
+	if _, ok := s.(*Null); ok {
+		return nil
+	}
+	numParts, numPoints, parts, points, ok := shapeParts(s)
+	if ok {
+		if int(numPoints) != len(points) {
+			return fmt.Errorf("%w: %T declares %d points but has %d", ErrInvalidShape, s, numPoints, len(points))
+		}
+		if int(numParts) != len(parts) {
+			return fmt.Errorf("%w: %T declares %d parts but has %d", ErrInvalidShape, s, numParts, len(parts))
+		}
+		for i, p := range parts {
+			switch {
+			case i == 0 && p != 0:
+				return fmt.Errorf("%w: first part starts at point %d instead of 0", ErrInvalidShape, p)
+			case p < 0 || int(p) >= len(points):
+				return fmt.Errorf("%w: part %d starts at point %d, out of range [0, %d)", ErrInvalidShape, i, p, len(points))
+			case i > 0 && p <= parts[i-1]:
+				return fmt.Errorf("%w: part %d starts at point %d, not after part %d at point %d", ErrInvalidShape, i, p, i-1, parts[i-1])
+			}
+		}
+		if mp, ok := s.(*MultiPatch); ok && len(mp.PartTypes) != len(parts) {
+			return fmt.Errorf("%w: MultiPatch has %d parts but %d part types", ErrInvalidShape, len(parts), len(mp.PartTypes))
+		}
+	} else {
+		points = nil
+		transformPoints(s, func(p Point) Point {
+			points = append(points, p)
+			return p
+		})
+	}
+
+	for i, p := range points {
+		if !isFinite(p.X) || !isFinite(p.Y) {
+			return fmt.Errorf("%w: point %d is (%v, %v)", ErrInvalidShape, i, p.X, p.Y)
+		}
+	}
+	z, m, zr, _ := shapeValues(s)
+	if zr != nil && len(z) != len(points) {
+		return fmt.Errorf("%w: %T has %d Z values for %d points", ErrInvalidShape, s, len(z), len(points))
+	}
+	if len(m) != 0 && len(m) != len(points) {
+		return fmt.Errorf("%w: %T has %d measures for %d points", ErrInvalidShape, s, len(m), len(points))
+	}
+	for i, v := range z {
+		if !isFinite(v) {
+			return fmt.Errorf("%w: Z value of point %d is %v", ErrInvalidShape, i, v)
+		}
+	}
+	for i, v := range m {
+		if !isFinite(v) {
+			return fmt.Errorf("%w: measure of point %d is %v", ErrInvalidShape, i, v)
+		}
+	}
+
+	for i, pr := range partRanges(parts, len(points)) {
+		if !isRing(s, i) {
+			continue
+		}
+		ring := points[pr[0]:pr[1]]
+		if len(ring) < 4 {
+			return fmt.Errorf("%w: ring %d has %d points, fewer than 4", ErrInvalidShape, i, len(ring))
+		}
+		if ring[0] != ring[len(ring)-1] {
+			return fmt.Errorf("%w: ring %d is not closed, it starts at %v and ends at %v", ErrInvalidShape, i, ring[0], ring[len(ring)-1])
+		}
+	}
+	return nil
+}
+
+// isRing reports whether part i of s is a ring, which is every part of a
+// polygon and the parts of a MultiPatch that are not triangles.
+func isRing(s Shape, i int) bool {
+	switch s := s.(type) {
+	case *Polygon, *PolygonZ, *PolygonM:
+		return true
+	case *MultiPatch:
+		return s.PartTypes[i] != TriangleStrip && s.PartTypes[i] != TriangleFan
+	}
+	return false
+}
@@ -0,0 +1,92 @@
+package shp
+
+import (
+	"errors"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriterStrict(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// the shapes are built as struct literals, as the constructors do not
+	// build most of them
+	polygon := func(parts []int32, points []Point) *Polygon {
+		return &Polygon{Box: BBoxFromPoints(points), NumParts: int32(len(parts)), NumPoints: int32(len(points)), Parts: parts, Points: points}
+	}
+	square := []Point{{0, 0}, {0, 1}, {1, 1}, {1, 0}, {0, 0}}
+	miscounted := polygon([]int32{0}, square)
+	miscounted.NumPoints = 3
+	outOfRange := NewPolyLine([][]Point{{{0, 0}, {1, 1}}})
+	outOfRange.Parts[0] = 5
+	noZ := &PolyLineZ{NumParts: 1, NumPoints: 2, Parts: []int32{0}, Points: []Point{{0, 0}, {1, 1}}, ZArray: []float64{1}}
+
+	for _, test := range []struct {
+		name  string
+		t     ShapeType
+		shape Shape
+		err   string
+	}{
+		{"valid polygon", POLYGON, polygon([]int32{0}, square), ""},
+		{"null", POLYGON, &Null{}, ""},
+		{"unclosed ring", POLYGON, polygon([]int32{0}, square[:4]), "ring 0 is not closed"},
+		{"short ring", POLYGON, polygon([]int32{0}, []Point{{0, 0}, {1, 1}, {0, 0}}), "ring 0 has 3 points, fewer than 4"},
+		{"part out of range", POLYLINE, outOfRange, "first part starts at point 5"},
+		{"point count", POLYGON, miscounted, "declares 3 points but has 5"},
+		{"NaN", POINT, &Point{math.NaN(), 1}, "point 0 is (NaN, 1)"},
+		{"infinite Z", POINTZ, &PointZ{1, 1, math.Inf(1), 0}, "Z value of point 0 is +Inf"},
+		{"Z count", POLYLINEZ, noZ, "has 1 Z values for 2 points"},
+	} {
+		for _, strict := range []bool{false, true} {
+			w, err := Create(filepath.Join(dir, "strict.shp"), test.t)
+			if err != nil {
+				t.Fatal(err)
+			}
+			w.SetStrict(strict)
+			n := w.Write(test.shape)
+			w.Close()
+			if !strict || test.err == "" {
+				if n != 0 || w.Err() != nil {
+					t.Errorf("%s: strict=%v: got %d and error %v, want shape written", test.name, strict, n, w.Err())
+				}
+				continue
+			}
+			if n != -1 || !errors.Is(w.Err(), ErrInvalidShape) || !strings.Contains(w.Err().Error(), test.err) {
+				t.Errorf("%s: got %d and error %v, want error containing %q", test.name, n, w.Err(), test.err)
+			}
+		}
+	}
+}
+
+func TestWriterStrictAttributeWidth(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	w, err := Create(filepath.Join(dir, "strict.shp"), POINT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	w.SetStrict(true)
+	w.SetFields([]Field{StringField("NAME", 4), NumberField("ID", 3)})
+	w.Write(&Point{1, 1})
+	if err := w.WriteAttribute(0, 0, "toolong"); err == nil {
+		t.Errorf("wrote string that exceeds the field width")
+	}
+	if err := w.WriteAttribute(0, 1, 12345); err == nil {
+		t.Errorf("wrote number that exceeds the field width")
+	}
+	if err := w.WriteAttribute(0, 0, "fits"); err != nil {
+		t.Errorf("got error %v for value that fits", err)
+	}
+}
@@ -0,0 +1,175 @@
+package shp
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+)
+
+// TarReader reads a shapefile from a tar archive, which may be compressed
+// with gzip, like ZipReader does from ZIP archives. Since the files of a tar
+// archive can only be read in the order in which they are stored, the files
+// of the shapefile are held in memory.
+type TarReader struct {
+	sr SequentialReader
+	// prj is the content of the .prj file, if there is one.
+	prj string
+}
+
+// tarCompanions are the extensions of the files that are read from tar
+// archives.
+var tarCompanions = map[string]bool{".shp": true, ".dbf": true, ".prj": true, ".cpg": true}
+
+// OpenTarGz opens the tar archive at path, which usually has the extension
+// .tar.gz or .tgz and contains a single shapefile.
+func OpenTarGz(path string, opts ...Option) (*TarReader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return OpenTarGzReader(f, opts...)
+}
+
+// OpenTarGzReader reads a tar archive that contains a single shapefile from
+// r. The archive is decompressed if it is compressed with gzip. The .shp is
+// paired with its companions regardless of case and path separators.
+func OpenTarGzReader(r io.Reader, opts ...Option) (*TarReader, error) {
+	rc, err := gunzipped(ioutil.NopCloser(r))
+	if err != nil {
+		return nil, fmt.Errorf("Error decompressing archive: %v", err)
+	}
+	defer rc.Close()
+	files := make(map[string][]byte)
+	var shapes []string
+	tr := tar.NewReader(rc)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Error reading archive: %v", err)
+		}
+		ext := strings.ToLower(path.Ext(h.Name))
+		if h.Typeflag != tar.TypeReg || !tarCompanions[ext] {
+			continue
+		}
+		b, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading %s from archive: %v", h.Name, err)
+		}
+		files[zipPath(h.Name)] = b
+		if ext == ".shp" {
+			shapes = append(shapes, zipPath(h.Name))
+		}
+	}
+	if len(shapes) == 0 {
+		return nil, ErrNoSHPInArchive
+	}
+	if len(shapes) > 1 {
+		return nil, fmt.Errorf("archive contains %w", ErrMultipleSHP)
+	}
+
+	prefix := strings.TrimSuffix(shapes[0], path.Ext(shapes[0]))
+	companion := func(ext string) []byte {
+		for name, b := range files {
+			if strings.EqualFold(name, prefix+ext) {
+				return b
+			}
+		}
+		return nil
+	}
+	if cpg := companion(".cpg"); cpg != nil {
+		opts = append(opts[:len(opts):len(opts)], withCPG(string(cpg)))
+	}
+	var dbf io.ReadCloser
+	if b := companion(".dbf"); b != nil {
+		dbf = ioutil.NopCloser(bytes.NewReader(b))
+	}
+	shp := ioutil.NopCloser(bytes.NewReader(files[shapes[0]]))
+	return &TarReader{
+		sr:  SequentialReaderFromExt(shp, dbf, opts...),
+		prj: string(companion(".prj")),
+	}, nil
+}
+
+// Next implements a method of interface SequentialReader for TarReader.
+func (tr *TarReader) Next() bool {
+	return tr.sr.Next()
+}
+
+// Shape implements a method of interface SequentialReader for TarReader.
+func (tr *TarReader) Shape() (int, Shape) {
+	return tr.sr.Shape()
+}
+
+// Attribute implements a method of interface SequentialReader for TarReader.
+func (tr *TarReader) Attribute(n int) string {
+	return tr.sr.Attribute(n)
+}
+
+// Fields returns a slice of Fields that are present in the DBF table.
+func (tr *TarReader) Fields() []Field {
+	return tr.sr.Fields()
+}
+
+// Err returns the last non-EOF error that was encountered by this TarReader.
+func (tr *TarReader) Err() error {
+	return tr.sr.Err()
+}
+
+// Close releases the shapefile that is held in memory.
+func (tr *TarReader) Close() error {
+	return tr.sr.Close()
+}
+
+// BBox returns the bounding box of the shapefile from its header.
+func (tr *TarReader) BBox() Box {
+	if b, ok := tr.sr.(interface{ BBox() Box }); ok {
+		return b.BBox()
+	}
+	return Box{}
+}
+
+// Projection returns the content of the .prj file in the archive, or the
+// empty string if there is none.
+func (tr *TarReader) Projection() string {
+	return tr.prj
+}
+
+// gunzipped returns rc, decompressed if it starts with the magic number of
+// gzip. Closing the result closes rc.
+func gunzipped(rc io.ReadCloser) (io.ReadCloser, error) {
+	br := bufio.NewReader(rc)
+	if magic, err := br.Peek(2); err != nil || magic[0] != 0x1f || magic[1] != 0x8b {
+		return &gzipReadCloser{Reader: br, rc: rc}, nil
+	}
+	zr, err := gzip.NewReader(br)
+	if err != nil {
+		return nil, err
+	}
+	return &gzipReadCloser{Reader: zr, zr: zr, rc: rc}, nil
+}
+
+// gzipReadCloser reads from a stream that may be decompressed by zr and
+// closes both.
+type gzipReadCloser struct {
+	io.Reader
+	zr *gzip.Reader
+	rc io.ReadCloser
+}
+
+func (g *gzipReadCloser) Close() error {
+	if g.zr != nil {
+		g.zr.Close()
+	}
+	return g.rc.Close()
+}
@@ -0,0 +1,115 @@
+package shp
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// tarGz returns a gzip-compressed tar archive of the given files.
+func tarGz(t *testing.T, files map[string][]byte) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(zw)
+	for name, b := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(b)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write(b)
+	}
+	tw.Close()
+	zw.Close()
+	return buf.Bytes()
+}
+
+func readTestFile(t *testing.T, name string) []byte {
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestOpenTarGz(t *testing.T) {
+	_, want := readAll(t, "test_files/point.shp")
+	archive := tarGz(t, map[string][]byte{
+		"data/POINT.DBF": readTestFile(t, "test_files/point.dbf"),
+		"data/point.shp": readTestFile(t, "test_files/point.shp"),
+		"data/point.prj": []byte(`GEOGCS["WGS 84"]`),
+		"README":         []byte("points"),
+	})
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "point.tar.gz")
+	ioutil.WriteFile(filename, archive, 0644)
+
+	tr, err := OpenTarGz(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tr.Close()
+	var shapes []Shape
+	for tr.Next() {
+		_, s := tr.Shape()
+		shapes = append(shapes, s)
+	}
+	if err := tr.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(shapes, want) {
+		t.Errorf("got %v, want %v", shapes, want)
+	}
+	if len(tr.Fields()) != 1 {
+		t.Errorf("got fields %v from the DBF", tr.Fields())
+	}
+	if tr.Projection() != `GEOGCS["WGS 84"]` {
+		t.Errorf("got projection %q", tr.Projection())
+	}
+
+	multiple := tarGz(t, map[string][]byte{"a.shp": nil, "b.shp": nil})
+	if _, err := OpenTarGzReader(bytes.NewReader(multiple)); !errors.Is(err, ErrMultipleSHP) {
+		t.Errorf("got %v, want ErrMultipleSHP", err)
+	}
+	if _, err := OpenTarGzReader(bytes.NewReader(tarGz(t, nil))); !errors.Is(err, ErrNoSHPInArchive) {
+		t.Errorf("got %v, want ErrNoSHPInArchive", err)
+	}
+}
+
+func TestSequentialReaderGzip(t *testing.T) {
+	_, want := readAll(t, "test_files/point.shp")
+	gz := func(name string) *bytes.Buffer {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write(readTestFile(t, name))
+		zw.Close()
+		return &buf
+	}
+	sr := SequentialReaderFromExt(ioutil.NopCloser(gz("test_files/point.shp")), ioutil.NopCloser(gz("test_files/point.dbf")))
+	defer sr.Close()
+	var shapes []Shape
+	for sr.Next() {
+		_, s := sr.Shape()
+		shapes = append(shapes, s)
+	}
+	if err := sr.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(shapes, want) || len(sr.Fields()) == 0 {
+		t.Errorf("got %v with %d fields, want %v", shapes, len(sr.Fields()), want)
+	}
+
+	corrupt := ioutil.NopCloser(bytes.NewReader([]byte{0x1f, 0x8b, 0}))
+	sr = SequentialReaderFromExt(corrupt, nil)
+	if sr.Next() || sr.Err() == nil {
+		t.Error("expected an error for a corrupt gzip stream")
+	}
+}
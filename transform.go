@@ -0,0 +1,169 @@
+package shp
+
+// Transform transforms coordinates, e.g. from one coordinate reference system
+// into another. It can be installed on readers and writers with
+// SetTransform.
+type Transform interface {
+	Transform(x, y float64) (float64, float64)
+}
+
+// ZTransform is a Transform that also transforms Z values. If the Transform
+// installed with SetTransform implements it, it is used for the points of Z
+// shapes and MultiPatches.
+type ZTransform interface {
+	Transform
+	TransformZ(x, y, z float64) (float64, float64, float64)
+}
+
+// TransformFunc is a function that implements Transform.
+type TransformFunc func(x, y float64) (float64, float64)
+
+// Transform calls f.
+func (f TransformFunc) Transform(x, y float64) (float64, float64) {
+	return f(x, y)
+}
+
+// TransformShape returns a copy of s whose points are transformed by t. The
+// bounding box and the range of Z values are recomputed. Shapes of registered
+// types are returned unchanged.
+func TransformShape(s Shape, t Transform) Shape {
+	c := cloneShape(s)
+	transformShape(c, t)
+	return c
+}
+
+// transformShape transforms the points of s by t in place.
+func transformShape(s Shape, t Transform) {
+	if zt, ok := t.(ZTransform); ok {
+		if transformZ(s, zt) {
+			return
+		}
+	}
+	transformPoints(s, func(p Point) Point {
+		x, y := t.Transform(p.X, p.Y)
+		return Point{x, y}
+	})
+}
+
+// transformZ transforms the points of s along with their Z values by t in
+// place. It returns false if s has no Z values.
+func transformZ(s Shape, t ZTransform) bool {
+	apply := func(box *Box, points []Point, z []float64, zrange *[2]float64) {
+		for i := range points {
+			var v float64
+			if i < len(z) {
+				v = z[i]
+			}
+			points[i].X, points[i].Y, v = t.TransformZ(points[i].X, points[i].Y, v)
+			if i < len(z) {
+				z[i] = v
+			}
+		}
+		*box = BBoxFromPoints(points)
+		*zrange = valueRange(z)
+	}
+	switch s := s.(type) {
+	case *PointZ:
+		s.X, s.Y, s.Z = t.TransformZ(s.X, s.Y, s.Z)
+	case *PolyLineZ:
+		apply(&s.Box, s.Points, s.ZArray, &s.ZRange)
+	case *PolygonZ:
+		apply(&s.Box, s.Points, s.ZArray, &s.ZRange)
+	case *MultiPointZ:
+		apply(&s.Box, s.Points, s.ZArray, &s.ZRange)
+	case *MultiPatch:
+		apply(&s.Box, s.Points, s.ZArray, &s.ZRange)
+	default:
+		return false
+	}
+	return true
+}
+
+// transformBox returns the bounding box of b transformed by t. Since t need
+// not be linear, points along the edges of b are transformed besides its
+// corners.
+func transformBox(b Box, t Transform) Box {
+	const steps = 8
+	var points []Point
+	for i := 0; i <= steps; i++ {
+		f := float64(i) / steps
+		x := b.MinX + f*(b.MaxX-b.MinX)
+		y := b.MinY + f*(b.MaxY-b.MinY)
+		points = append(points, Point{x, b.MinY}, Point{x, b.MaxY}, Point{b.MinX, y}, Point{b.MaxX, y})
+	}
+	for i, p := range points {
+		points[i].X, points[i].Y = t.Transform(p.X, p.Y)
+	}
+	return BBoxFromPoints(points)
+}
+
+// SetTransform makes the reader transform the points of every shape it
+// returns by t, after swapping X and Y if WithSwapXY is used, and recompute
+// their bounding boxes. BBox returns the extent transformed by t. The box set
+// by SetFilterBBox is in the coordinates before the transformation. A nil t
+// removes the transformation.
+func (r *Reader) SetTransform(t Transform) {
+	r.transform = t
+}
+
+// SetTransform makes the reader transform the points of every shape it
+// returns by t, like Reader.SetTransform.
+func (sr *seqReader) SetTransform(t Transform) {
+	sr.transform = t
+}
+
+// SetTransform makes the reader transform the points of every shape it
+// returns by t, like Reader.SetTransform.
+func (zr *ZipReader) SetTransform(t Transform) {
+	if s, ok := zr.sr.(interface{ SetTransform(Transform) }); ok {
+		s.SetTransform(t)
+	}
+}
+
+// transformHolder is implemented by the readers that have SetTransform.
+type transformHolder interface {
+	SetTransform(t Transform)
+	transformOf() Transform
+}
+
+func (r *Reader) transformOf() Transform          { return r.transform }
+func (sr *seqReader) transformOf() Transform      { return sr.transform }
+func (pr *ParallelReader) transformOf() Transform { return pr.r.transform }
+
+func (zr *ZipReader) transformOf() Transform {
+	if h, ok := zr.sr.(transformHolder); ok {
+		return h.transformOf()
+	}
+	return nil
+}
+
+// swapThenTransform is used by the exporters that swap X and Y themselves.
+// It takes the transform set on sr off the reader and returns a function that
+// swaps a shape and then applies that transform, so the swap happens first
+// like with WithSwapXY. restore sets the transform on sr again.
+func swapThenTransform(sr SequentialReader) (swap func(Shape) Shape, restore func()) {
+	var t Transform
+	restore = func() {}
+	if h, ok := sr.(transformHolder); ok {
+		if t = h.transformOf(); t != nil {
+			h.SetTransform(nil)
+			restore = func() { h.SetTransform(t) }
+		}
+	}
+	swap = func(s Shape) Shape {
+		s = SwapXY(s)
+		if t != nil {
+			transformShape(s, t)
+		}
+		return s
+	}
+	return swap, restore
+}
+
+// SetTransform makes the writer transform the points of every shape by t
+// before it is written. The shapes that are passed to Write are not modified,
+// and the extent of the file is computed from the transformed shapes. A nil t
+// removes the transformation.
+func (w *Writer) SetTransform(t Transform) {
+	w.transform = t
+}
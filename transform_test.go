@@ -0,0 +1,166 @@
+package shp
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// liftZ shifts points and raises their Z values.
+type liftZ struct{}
+
+func (liftZ) Transform(x, y float64) (float64, float64) {
+	return x + 100, y * 2
+}
+
+func (liftZ) TransformZ(x, y, z float64) (float64, float64, float64) {
+	return x + 100, y * 2, z + 1
+}
+
+func TestTransform(t *testing.T) {
+	shift := TransformFunc(func(x, y float64) (float64, float64) {
+		return x + 100, y * 2
+	})
+	line := NewPolyLine([][]Point{{{0, 0}, {1, 1}}})
+	got := TransformShape(line, shift)
+	want := NewPolyLine([][]Point{{{100, 0}, {101, 2}}})
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+	if line.Points[1] != (Point{1, 1}) {
+		t.Error("TransformShape modified its argument")
+	}
+
+	z, err := NewPolyLineZ([][]Point{{{0, 0}, {1, 1}}}, [][]float64{{5, 6}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = TransformShape(z, liftZ{})
+	wantZ, _ := NewPolyLineZ([][]Point{{{100, 0}, {101, 2}}}, [][]float64{{6, 7}}, nil)
+	if !reflect.DeepEqual(got, wantZ) {
+		t.Errorf("got %#v, want %#v", got, wantZ)
+	}
+
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "shifted.shp")
+	w, err := Create(filename, POLYLINE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SetTransform(shift)
+	w.Write(line)
+	w.Close()
+	if line.Points[1] != (Point{1, 1}) {
+		t.Error("Write modified the shape")
+	}
+
+	r, err := Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if box := r.BBox(); box != want.Box {
+		t.Errorf("got extent %v, want %v", box, want.Box)
+	}
+	inverse := TransformFunc(func(x, y float64) (float64, float64) {
+		return x - 100, y / 2
+	})
+	r.SetTransform(inverse)
+	if box := r.BBox(); box != line.Box {
+		t.Errorf("got transformed extent %v, want %v", box, line.Box)
+	}
+	if !r.Next() {
+		t.Fatal(r.Err())
+	}
+	if _, s := r.Shape(); !reflect.DeepEqual(s, line) {
+		t.Errorf("got %#v, want %#v", s, line)
+	}
+
+	sr, err := OpenDataset(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sr.Close()
+	sr.(*seqReader).SetTransform(inverse)
+	if !sr.Next() {
+		t.Fatal(sr.Err())
+	}
+	if _, s := sr.Shape(); !reflect.DeepEqual(s, line) {
+		t.Errorf("seqReader: got %#v, want %#v", s, line)
+	}
+}
+
+func TestSwapThenTransform(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "latlon.shp")
+	w, err := Create(filename, POINT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(&Point{1, 2})
+	w.Close()
+
+	// Swapping after the transformation would give (2, 101).
+	shift := TransformFunc(func(x, y float64) (float64, float64) {
+		return x + 100, y
+	})
+	want := &Point{102, 1}
+
+	r, err := Open(filename, WithSwapXY())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	r.SetTransform(shift)
+	if !r.Next() {
+		t.Fatal(r.Err())
+	}
+	if _, s := r.Shape(); !reflect.DeepEqual(s, want) {
+		t.Errorf("WithSwapXY: got %#v, want %#v", s, want)
+	}
+
+	r, err = Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	r.SetTransform(shift)
+	var buf bytes.Buffer
+	e := NewGeoJSONEncoder(&buf)
+	e.SetSwapXY(true)
+	if err := e.Encode(r); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"coordinates":[102,1]`) {
+		t.Errorf("GeoJSONEncoder: got %s", buf.Bytes())
+	}
+	if r.transform == nil {
+		t.Error("GeoJSONEncoder did not restore the transform of the reader")
+	}
+
+	r, err = Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	r.SetTransform(shift)
+	buf.Reset()
+	if err := WriteKML(&buf, r, KMLOptions{SwapXY: true}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "<coordinates>102,1</coordinates>") {
+		t.Errorf("WriteKML: got %s", buf.Bytes())
+	}
+}
@@ -0,0 +1,154 @@
+package shp
+
+import (
+	"fmt"
+	"math"
+)
+
+// WithAddZ makes Convert write 2D shapes as Z shapes: points, polylines,
+// polygons and multipoints become PointZ, PolyLineZ, PolygonZ and
+// MultiPointZ. The Z value of every vertex is obtained by calling sample with
+// its coordinates, e.g. to drape lines over an elevation model. Measures are
+// NoData unless WithAddM is given as well.
+func WithAddZ(sample func(x, y float64) (float64, error)) Option {
+	return func(o *options) {
+		o.addZ = sample
+	}
+}
+
+// WithAddM makes Convert write 2D shapes with measures for linear
+// referencing. The measure of a vertex is the length of the shape up to that
+// vertex, which restarts at 0 for every shape but continues across its parts.
+// Without WithAddZ, points, polylines, polygons and multipoints become PointM,
+// PolyLineM, PolygonM and MultiPointM.
+func WithAddM() Option {
+	return func(o *options) {
+		o.addM = true
+	}
+}
+
+// upgradedType returns the shape type that 2D shapes of type t are written as
+// when Z values and/or measures are added.
+func upgradedType(t ShapeType, z, m bool) (ShapeType, error) {
+	types := map[ShapeType][2]ShapeType{
+		POINT:      {POINTZ, POINTM},
+		POLYLINE:   {POLYLINEZ, POLYLINEM},
+		POLYGON:    {POLYGONZ, POLYGONM},
+		MULTIPOINT: {MULTIPOINTZ, MULTIPOINTM},
+	}
+	u, ok := types[t]
+	if !ok {
+		return t, fmt.Errorf("Unable to add Z or measures to shapes of type %v", t)
+	}
+	if z {
+		return u[0], nil
+	}
+	return u[1], nil
+}
+
+// upgrader adds Z values and/or measures to the 2D shapes that are converted.
+type upgrader struct {
+	sample func(x, y float64) (float64, error)
+	addM   bool
+}
+
+// upgrade returns the shape s of the record with the given index with Z
+// values and/or measures.
+func (u *upgrader) upgrade(index int, s Shape) (Shape, error) {
+	var parts []int32
+	var points []Point
+	switch s := s.(type) {
+	case *Null:
+		return s, nil
+	case *Point:
+		points = []Point{*s}
+	case *PolyLine:
+		parts, points = s.Parts, s.Points
+	case *Polygon:
+		parts, points = s.Parts, s.Points
+	case *MultiPoint:
+		points = s.Points
+	default:
+		return nil, fmt.Errorf("Unable to add Z or measures to record %d: not a 2D shape", index)
+	}
+
+	z, zr, err := u.zValues(index, points)
+	if err != nil {
+		return nil, err
+	}
+	m := nodataArray(len(points))
+	if u.addM {
+		m = measures(parts, points)
+	}
+	mr := measureRange(m)
+
+	box := BBoxFromPoints(points)
+	switch s := s.(type) {
+	case *Point:
+		if u.sample == nil {
+			return &PointM{X: s.X, Y: s.Y, M: m[0]}, nil
+		}
+		return &PointZ{X: s.X, Y: s.Y, Z: z[0], M: m[0]}, nil
+	case *MultiPoint:
+		if u.sample == nil {
+			return &MultiPointM{Box: box, NumPoints: s.NumPoints, Points: s.Points, MRange: mr, MArray: m}, nil
+		}
+		return &MultiPointZ{Box: box, NumPoints: s.NumPoints, Points: s.Points, ZRange: zr, ZArray: z, MRange: mr, MArray: m}, nil
+	}
+	numParts, numPoints := int32(len(parts)), int32(len(points))
+	if u.sample == nil {
+		if _, ok := s.(*Polygon); ok {
+			return &PolygonM{Box: box, NumParts: numParts, NumPoints: numPoints, Parts: parts, Points: points, MRange: mr, MArray: m}, nil
+		}
+		return &PolyLineM{Box: box, NumParts: numParts, NumPoints: numPoints, Parts: parts, Points: points, MRange: mr, MArray: m}, nil
+	}
+	pz := PolyLineZ{Box: box, NumParts: numParts, NumPoints: numPoints, Parts: parts, Points: points, ZRange: zr, ZArray: z, MRange: mr, MArray: m}
+	if _, ok := s.(*Polygon); ok {
+		p := PolygonZ(pz)
+		return &p, nil
+	}
+	return &pz, nil
+}
+
+// zValues samples the Z value of every point and returns them with their
+// range.
+func (u *upgrader) zValues(index int, points []Point) ([]float64, [2]float64, error) {
+	if u.sample == nil {
+		return nil, [2]float64{}, nil
+	}
+	z := make([]float64, len(points))
+	for i, p := range points {
+		v, err := u.sample(p.X, p.Y)
+		if err != nil {
+			return nil, [2]float64{}, fmt.Errorf("Error sampling Z of vertex %d of record %d: %v", i, index, err)
+		}
+		z[i] = v
+	}
+	return z, valueRange(z), nil
+}
+
+// measures returns the length of the shape up to every point. Parts start
+// where the previous part ended.
+func measures(parts []int32, points []Point) []float64 {
+	m := make([]float64, len(points))
+	start := make(map[int]bool, len(parts))
+	for _, p := range parts {
+		start[int(p)] = true
+	}
+	for i := 1; i < len(points); i++ {
+		m[i] = m[i-1]
+		if !start[i] {
+			m[i] += math.Hypot(points[i].X-points[i-1].X, points[i].Y-points[i-1].Y)
+		}
+	}
+	return m
+}
+
+// nodataArray returns n NoData measures.
+func nodataArray(n int) []float64 {
+	m := make([]float64, n)
+	for i := range m {
+		m[i] = NoData
+	}
+	return m
+}
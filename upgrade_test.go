@@ -0,0 +1,149 @@
+package shp
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// readAll returns the geometry type and all shapes of the shapefile name.
+func readAll(t *testing.T, name string) (ShapeType, []Shape) {
+	r, err := Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	var shapes []Shape
+	for r.Next() {
+		_, s := r.Shape()
+		shapes = append(shapes, s)
+	}
+	if err := r.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return r.GeometryType, shapes
+}
+
+func TestConvertAddZ(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sample := func(x, y float64) (float64, error) { return x + 10*y, nil }
+
+	dst := filepath.Join(dir, "polylinez.shp")
+	if err := Convert("test_files/polyline.shp", dst, WithAddZ(sample)); err != nil {
+		t.Fatal(err)
+	}
+	_, src := readAll(t, "test_files/polyline.shp")
+	typ, shapes := readAll(t, dst)
+	if typ != POLYLINEZ {
+		t.Fatalf("got shape type %v, want %v", typ, POLYLINEZ)
+	}
+	for i, s := range shapes {
+		p, orig := s.(*PolyLineZ), src[i].(*PolyLine)
+		if !reflect.DeepEqual(p.Points, orig.Points) || !reflect.DeepEqual(p.Parts, orig.Parts) {
+			t.Errorf("record %d: geometry changed", i)
+		}
+		var want []float64
+		for _, pt := range orig.Points {
+			want = append(want, pt.X+10*pt.Y)
+		}
+		if !reflect.DeepEqual(p.ZArray, want) {
+			t.Errorf("record %d: got Z %v, want %v", i, p.ZArray, want)
+		}
+		if p.ZRange != valueRange(want) {
+			t.Errorf("record %d: got Z range %v, want %v", i, p.ZRange, valueRange(want))
+		}
+		for _, m := range p.MArray {
+			if m != NoData {
+				t.Errorf("record %d: got measure %v, want NoData", i, m)
+			}
+		}
+	}
+
+	dst = filepath.Join(dir, "pointz.shp")
+	if err := Convert("test_files/point.shp", dst, WithAddZ(sample), WithAddM()); err != nil {
+		t.Fatal(err)
+	}
+	typ, shapes = readAll(t, dst)
+	if p, ok := shapes[1].(*PointZ); typ != POINTZ || !ok || p.Z != p.X+10*p.Y || p.M != 0 {
+		t.Errorf("got %v %#v", typ, shapes[1])
+	}
+}
+
+func TestConvertAddM(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	src := filepath.Join(dir, "src.shp")
+	w, err := Create(src, POLYLINE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(NewPolyLine([][]Point{
+		{{0, 0}, {3, 4}, {3, 5}},
+		{{10, 10}, {10, 12}},
+	}))
+	w.Close()
+
+	dst := filepath.Join(dir, "dst.shp")
+	if err := Convert(src, dst, WithAddM()); err != nil {
+		t.Fatal(err)
+	}
+	typ, shapes := readAll(t, dst)
+	if typ != POLYLINEM {
+		t.Fatalf("got shape type %v, want %v", typ, POLYLINEM)
+	}
+	p := shapes[0].(*PolyLineM)
+	// the second part continues where the first one ended
+	if want := []float64{0, 5, 6, 6, 8}; !reflect.DeepEqual(p.MArray, want) {
+		t.Errorf("got measures %v, want %v", p.MArray, want)
+	}
+	if p.MRange != [2]float64{0, 8} {
+		t.Errorf("got measure range %v", p.MRange)
+	}
+}
+
+func TestConvertAddZErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fail := func(x, y float64) (float64, error) {
+		if x < 5 {
+			return 0, errors.New("outside of the elevation model")
+		}
+		return 0, nil
+	}
+	dst := filepath.Join(dir, "dst.shp")
+	err = Convert("test_files/point.shp", dst, WithAddZ(fail))
+	if err == nil || !strings.Contains(err.Error(), "vertex 0 of record 2") {
+		t.Errorf("got error %v, want one naming the vertex and record", err)
+	}
+	if _, err := os.Stat(dst); !os.IsNotExist(err) {
+		t.Error("expected output of failed Convert to be removed")
+	}
+
+	if err := Convert("test_files/pointz.shp", dst, WithAddM()); err == nil {
+		t.Error("expected an error for a source that is not 2D")
+	}
+
+	// the writer must have been created with the upgraded type
+	r, _ := Open("test_files/point.shp", WithAddM())
+	defer r.Close()
+	w, _ := Create(filepath.Join(dir, "wrong.shp"), POINT)
+	defer w.Close()
+	if err := convert(r, w); err == nil {
+		t.Error("expected an error for a writer of the 2D type")
+	}
+}
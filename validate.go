@@ -0,0 +1,229 @@
+package shp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// IssueKind identifies the kind of an Issue.
+type IssueKind int
+
+// These are the kinds of issues that Validate reports.
+const (
+	// IssueRecordLength means the content length in a record header does not
+	// match the content that its shape type and counts call for.
+	IssueRecordLength IssueKind = iota + 1
+	// IssueOutsideExtent means the bounding box of a record is not contained
+	// in the extent of the main file header.
+	IssueOutsideExtent
+	// IssueRingOrientation means an outer ring of a polygon is
+	// counterclockwise or a hole is clockwise.
+	IssueRingOrientation
+	// IssueNaN means a record has coordinates that are not a number.
+	IssueNaN
+	// IssueIndexCount means the number of entries in the .shx differs from
+	// the number of records in the .shp.
+	IssueIndexCount
+)
+
+// Issue is a problem that Validate found in a shapefile.
+type Issue struct {
+	Kind IssueKind
+	// RecordNum is the number of the record, starting at 1, or 0 for issues
+	// of the whole file.
+	RecordNum int
+	Message   string
+}
+
+func (i Issue) String() string {
+	return i.Message
+}
+
+// Validate checks every record of the shapefile read by r and returns the
+// issues it finds. The records are read from the file independently of Next,
+// which continues where it left off. An error is only returned if the file
+// cannot be read; damaged records are reported as issues, and validation stops
+// at a record whose content length exceeds the file.
+func Validate(r *Reader) ([]Issue, error) {
+	if r.recovered != nil {
+		return nil, fmt.Errorf("Unable to validate a recovered shapefile")
+	}
+	defer r.shp.Seek(r.offset, io.SeekStart)
+	extent := r.bbox
+	if r.opts.swapXY {
+		extent = swapBox(extent)
+	}
+
+	var issues []Issue
+	add := func(kind IssueKind, num int, format string, args ...interface{}) {
+		issues = append(issues, Issue{Kind: kind, RecordNum: num, Message: fmt.Sprintf(format, args...)})
+	}
+	records := 0
+	for offset := int64(100); offset+8 <= r.filelength; {
+		records++
+		size, ok := r.headerAt(offset)
+		if !ok {
+			return issues, fmt.Errorf("Unable to read header of record %d", records)
+		}
+		if size < 4 || offset+8+size > r.filelength {
+			add(IssueRecordLength, records, "record %d declares a content length of %d bytes but %d remain in the file", records, size, r.filelength-offset-8)
+			break
+		}
+		content, err := r.contentAt(offset, size)
+		if err != nil {
+			return issues, fmt.Errorf("Unable to read record %d: %v", records, err)
+		}
+		offset += 8 + size
+
+		t := ShapeType(binary.LittleEndian.Uint32(content))
+		content = content[4:]
+		if min, max, ok := contentLengths(t, content); ok && (int64(len(content)) < min || int64(len(content)) > max) {
+			need := fmt.Sprint(min)
+			if max > min {
+				need = fmt.Sprintf("%d or %d", min, max)
+			}
+			add(IssueRecordLength, records, "record %d has %d bytes of content but its %v needs %s", records, len(content), t, need)
+			continue
+		}
+		if !decodes(t, content) {
+			continue
+		}
+		s, _ := readShape(t, content)
+		if t == NULL {
+			continue
+		}
+		nan := false
+		transformPoints(s, func(p Point) Point {
+			nan = nan || math.IsNaN(p.X) || math.IsNaN(p.Y)
+			return p
+		})
+		if nan {
+			add(IssueNaN, records, "record %d has coordinates that are not a number", records)
+		} else if b := s.BBox(); !extent.Contains(b) {
+			add(IssueOutsideExtent, records, "record %d with box %v lies outside of the extent %v in the header", records, b, extent)
+		}
+		if parts, points := polygonRings(s); parts != nil && misorientedRings(parts, points) > 0 {
+			add(IssueRingOrientation, records, "record %d has rings with the wrong orientation", records)
+		}
+	}
+
+	if shx, err := os.Stat(r.filename + ".shx"); err == nil {
+		if entries := int((shx.Size() - 100) / 8); entries != records {
+			add(IssueIndexCount, 0, ".shx has %d entries but .shp has %d records", entries, records)
+		}
+	}
+	return issues, nil
+}
+
+// contentLengths returns the smallest and largest length of the record
+// content following the shape type for a shape of type t with the counts in
+// content. They differ by the optional measures. ok is false for registered
+// types and if content is too short to hold the counts.
+func contentLengths(t ShapeType, content []byte) (min, max int64, ok bool) {
+	count := func(offset int) int64 {
+		return int64(int32(binary.LittleEndian.Uint32(content[offset:])))
+	}
+	var parts, n, partSize int64
+	switch t {
+	case NULL:
+		return 0, 0, true
+	case POINT:
+		return 16, 16, true
+	case POINTM:
+		return 24, 24, true
+	case POINTZ:
+		return 24, 32, true
+	case MULTIPOINT, MULTIPOINTM, MULTIPOINTZ:
+		if len(content) < 36 {
+			return 0, 0, false
+		}
+		min, n = 36, count(32)
+	case POLYLINE, POLYGON, POLYLINEM, POLYGONM, POLYLINEZ, POLYGONZ, MULTIPATCH:
+		if len(content) < 40 {
+			return 0, 0, false
+		}
+		parts, n, partSize = count(32), count(36), 4
+		if t == MULTIPATCH {
+			partSize = 8
+		}
+		min = 40
+	default:
+		return 0, 0, false
+	}
+	min += partSize*parts + 16*n
+	switch t {
+	case MULTIPOINTZ, POLYLINEZ, POLYGONZ, MULTIPATCH:
+		min += 16 + 8*n
+	case MULTIPOINT, POLYLINE, POLYGON:
+		return min, min, true
+	}
+	return min, min + 16 + 8*n, true
+}
+
+// polygonRings returns the parts and points of s if it is a polygon.
+func polygonRings(s Shape) ([]int32, []Point) {
+	switch p := s.(type) {
+	case *Polygon:
+		return p.Parts, p.Points
+	case *PolygonZ:
+		return p.Parts, p.Points
+	case *PolygonM:
+		return p.Parts, p.Points
+	}
+	return nil, nil
+}
+
+// misorientedRings returns the number of rings of a polygon whose orientation
+// does not match their nesting: outer rings must be clockwise and holes,
+// which lie inside an odd number of other rings, counterclockwise.
+func misorientedRings(parts []int32, points []Point) int {
+	rings := splitRings(parts, points)
+	n := 0
+	for i := range rings {
+		if (ringArea(rings[i]) > 0) != isHole(rings, i) {
+			n++
+		}
+	}
+	return n
+}
+
+// splitRings returns the rings of a polygon as slices of its points.
+func splitRings(parts []int32, points []Point) [][]Point {
+	var rings [][]Point
+	for _, pr := range partRanges(parts, len(points)) {
+		if pr[0] < 0 || pr[0] > pr[1] || pr[1] > len(points) {
+			return nil
+		}
+		rings = append(rings, points[pr[0]:pr[1]])
+	}
+	return rings
+}
+
+// orientRings reverses the rings of a polygon whose orientation does not
+// match their nesting, along with the Z and M values of their points, and
+// returns how many it reversed.
+func orientRings(parts []int32, points []Point, values ...[]float64) int {
+	rings := splitRings(parts, points)
+	ranges := partRanges(parts, len(points))
+	reverse := make([]bool, len(rings))
+	for i := range rings {
+		reverse[i] = (ringArea(rings[i]) > 0) != isHole(rings, i)
+	}
+	n := 0
+	for i, pr := range ranges[:len(rings)] {
+		if !reverse[i] {
+			continue
+		}
+		n++
+		reversePoints(rings[i])
+		for _, v := range values {
+			if len(v) >= pr[1] {
+				reverseFloats(v[pr[0]:pr[1]])
+			}
+		}
+	}
+	return n
+}
@@ -0,0 +1,110 @@
+package shp
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func issueKinds(issues []Issue) []IssueKind {
+	var kinds []IssueKind
+	for _, issue := range issues {
+		kinds = append(kinds, issue.Kind)
+	}
+	return kinds
+}
+
+func validateFile(t *testing.T, filename string) []Issue {
+	r, err := Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	issues, err := Validate(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return issues
+}
+
+func TestValidate(t *testing.T) {
+	if issues := validateFile(t, "test_files/polygon.shp"); len(issues) != 0 {
+		t.Errorf("got issues %v for a sound file", issues)
+	}
+	issues := validateFile(t, "test_files/point_padded.shp")
+	if len(issues) != 3 || issues[0].Kind != IssueRecordLength || issues[0].RecordNum != 1 {
+		t.Errorf("got issues %v for padded records", issues)
+	}
+
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "broken.shp")
+	w, err := Create(filename, POLYGON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a counterclockwise outer ring
+	ccw := []Point{{0, 0}, {1, 0}, {1, 1}, {0, 1}, {0, 0}}
+	w.Write(&Polygon{Box: BBoxFromPoints(ccw), NumParts: 1, NumPoints: 5, Parts: []int32{0}, Points: ccw})
+	w.Write(&Polygon{Box: Box{0, 0, 1, 1}, NumParts: 1, NumPoints: 4, Parts: []int32{0},
+		Points: []Point{{0, 0}, {math.NaN(), 1}, {1, 0}, {0, 0}}})
+	w.Close()
+
+	// drop the last entry of the index
+	shx := filepath.Join(dir, "broken.shx")
+	fi, _ := os.Stat(shx)
+	os.Truncate(shx, fi.Size()-8)
+
+	issues = validateFile(t, filename)
+	want := []IssueKind{IssueRingOrientation, IssueNaN, IssueIndexCount}
+	if got := issueKinds(issues); len(got) != len(want) || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] {
+		t.Errorf("got issues %v, want kinds %v", issues, want)
+	}
+}
+
+func TestRepairShapes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "broken.shp")
+	w, err := Create(filename, POLYGON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ccw := []Point{{0, 0}, {10, 0}, {10, 10}, {0, 10}, {0, 0}}
+	w.Write(&Polygon{Box: BBoxFromPoints(ccw), NumParts: 1, NumPoints: 5, Parts: []int32{0}, Points: ccw})
+	w.Close()
+
+	fixes, err := Repair(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixes) != 1 || fixes[0] != "reoriented rings of record 1" {
+		t.Errorf("got fixes %q", fixes)
+	}
+	if issues := validateFile(t, filename); len(issues) != 0 {
+		t.Errorf("got issues %v after repair", issues)
+	}
+	r, err := Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if r.AttributeCount() != 1 || !r.Next() {
+		t.Fatalf("got %d rows after repair", r.AttributeCount())
+	}
+	if _, s := r.Shape(); ringArea(s.(*Polygon).Points) >= 0 {
+		t.Errorf("got %v, want a clockwise ring", s)
+	}
+
+	if fixes, err := Repair(filename); err != nil || len(fixes) != 0 {
+		t.Errorf("got fixes %q and error %v for a repaired file", fixes, err)
+	}
+}
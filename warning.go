@@ -0,0 +1,31 @@
+package shp
+
+// WarningKind identifies the kind of a Warning.
+type WarningKind int
+
+// These are the kinds of warnings that are reported.
+const (
+	// WarnCoordinatesOutOfRange means the extent exceeds the range that is
+	// valid for the units of the declared coordinate system.
+	WarnCoordinatesOutOfRange WarningKind = iota + 1
+	// WarnLooksLikeDegrees means a projected coordinate system is declared
+	// but the coordinates look like decimal degrees.
+	WarnLooksLikeDegrees
+	// WarnSkippedRecord means a record could not be decoded and was skipped
+	// in lenient mode.
+	WarnSkippedRecord
+	// WarnDBFRecordCount means the number of records in the DBF header
+	// differs from the number of rows in the file.
+	WarnDBFRecordCount
+)
+
+// Warning is an advisory finding about a shapefile that does not prevent it
+// from being read.
+type Warning struct {
+	Kind    WarningKind
+	Message string
+}
+
+func (w Warning) String() string {
+	return w.Message
+}
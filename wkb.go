@@ -0,0 +1,493 @@
+package shp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// WKB geometry types without the dimension offset.
+const (
+	wkbPoint              = 1
+	wkbLineString         = 2
+	wkbPolygon            = 3
+	wkbMultiPoint         = 4
+	wkbMultiLineString    = 5
+	wkbMultiPolygon       = 6
+	wkbGeometryCollection = 7
+)
+
+// EWKB, as written by PostGIS, flags the dimensions and an embedded SRID in
+// the high bits of the geometry type.
+const (
+	ewkbZ    = 0x80000000
+	ewkbM    = 0x40000000
+	ewkbSRID = 0x20000000
+)
+
+// hasMeasures reports whether any of the measures m is not NoData.
+func hasMeasures(m []float64) bool {
+	_, _, ok := measureBounds(m)
+	return ok
+}
+
+// hasShapeMeasures reports whether s has measures that are not NoData.
+func hasShapeMeasures(s Shape) bool {
+	m, ok := s.(Measured)
+	return ok && hasMeasures(m.Measures())
+}
+
+// wkbEncoder writes WKB in little-endian byte order.
+type wkbEncoder struct {
+	buf  bytes.Buffer
+	z, m []float64
+	// dims is the offset of the geometry types for the dimensions, 1000 for
+	// Z, 2000 for M and 3000 for both.
+	dims uint32
+	// ewkb writes EWKB with Z shapes always carrying measures, and srid is
+	// embedded in the first geometry if it is not 0.
+	ewkb bool
+	srid uint32
+}
+
+func (e *wkbEncoder) header(t uint32, count int) {
+	e.buf.WriteByte(1)
+	if e.ewkb {
+		if e.dims == 1000 || e.dims == 3000 {
+			t |= ewkbZ
+		}
+		if e.dims >= 2000 {
+			t |= ewkbM
+		}
+		if e.srid != 0 {
+			binary.Write(&e.buf, binary.LittleEndian, t|ewkbSRID)
+			binary.Write(&e.buf, binary.LittleEndian, e.srid)
+			e.srid = 0
+		} else {
+			binary.Write(&e.buf, binary.LittleEndian, t)
+		}
+	} else {
+		binary.Write(&e.buf, binary.LittleEndian, t+e.dims)
+	}
+	if t != wkbPoint {
+		binary.Write(&e.buf, binary.LittleEndian, uint32(count))
+	}
+}
+
+// coords writes the coordinates of the i-th point.
+func (e *wkbEncoder) coords(points []Point, i int) {
+	binary.Write(&e.buf, binary.LittleEndian, points[i])
+	if e.dims == 1000 || e.dims == 3000 {
+		binary.Write(&e.buf, binary.LittleEndian, e.z[i])
+	}
+	if e.dims >= 2000 {
+		binary.Write(&e.buf, binary.LittleEndian, e.m[i])
+	}
+}
+
+func (e *wkbEncoder) lineString(points []Point, pr [2]int) {
+	e.header(wkbLineString, pr[1]-pr[0])
+	for i := pr[0]; i < pr[1]; i++ {
+		e.coords(points, i)
+	}
+}
+
+// polygonGroups returns the [start, end) index of the rings of every polygon
+// of a shapefile polygon. Like in GeoJSON, clockwise rings start a new
+// polygon and counterclockwise rings are holes of the preceding polygon.
+func polygonGroups(parts []int32, points []Point) [][][2]int {
+	var polygons [][][2]int
+	for _, pr := range partRanges(parts, len(points)) {
+		ring := make([][]float64, 0, pr[1]-pr[0])
+		for i := pr[0]; i < pr[1]; i++ {
+			ring = append(ring, []float64{points[i].X, points[i].Y})
+		}
+		if signedArea(ring) > 0 && len(polygons) > 0 {
+			polygons[len(polygons)-1] = append(polygons[len(polygons)-1], pr)
+		} else {
+			polygons = append(polygons, [][2]int{pr})
+		}
+	}
+	return polygons
+}
+
+// polygons writes the rings of a shapefile polygon as a Polygon, or as a
+// MultiPolygon if it has several outer rings, as grouped by polygonGroups.
+// The rings keep their orientation.
+func (e *wkbEncoder) polygons(parts []int32, points []Point) {
+	polygons := polygonGroups(parts, points)
+	if len(polygons) != 1 {
+		e.header(wkbMultiPolygon, len(polygons))
+	}
+	for _, rings := range polygons {
+		e.header(wkbPolygon, len(rings))
+		for _, pr := range rings {
+			binary.Write(&e.buf, binary.LittleEndian, uint32(pr[1]-pr[0]))
+			for i := pr[0]; i < pr[1]; i++ {
+				e.coords(points, i)
+			}
+		}
+	}
+}
+
+// MarshalWKB returns the Well-Known Binary representation of s in
+// little-endian byte order. Points become Points, MultiPoints MultiPoints,
+// PolyLines LineStrings or MultiLineStrings and Polygons Polygons or
+// MultiPolygons, depending on their number of parts. Z shapes are written
+// with Z values, and with measures as well unless all of them are NoData. M
+// shapes are written with measures. Null shapes are written as an empty
+// GeometryCollection. MultiPatches are not supported.
+func MarshalWKB(s Shape) ([]byte, error) {
+	return marshalWKB(s, &wkbEncoder{})
+}
+
+// MarshalEWKB returns the Extended Well-Known Binary representation of s, as
+// used by PostGIS, in little-endian byte order with the SRID embedded unless
+// it is 0. The geometries are those of MarshalWKB, except that Z shapes are
+// always written with measures, using NoData for missing ones, so that all
+// shapes of a shapefile have the same dimensions.
+func MarshalEWKB(s Shape, srid int) ([]byte, error) {
+	return marshalWKB(s, &wkbEncoder{ewkb: true, srid: uint32(srid)})
+}
+
+func marshalWKB(s Shape, e *wkbEncoder) ([]byte, error) {
+	withZ := func(n int, z, m []float64) error {
+		e.z, e.m, e.dims = z, m, 1000
+		if e.ewkb && len(m) < n {
+			e.m = make([]float64, n)
+			copy(e.m, m)
+			for i := len(m); i < n; i++ {
+				e.m[i] = NoData
+			}
+		}
+		if e.ewkb || hasMeasures(m) {
+			e.dims = 3000
+		}
+		if len(z) < n || e.dims == 3000 && len(e.m) < n {
+			return errors.New("Unable to encode WKB: shape has fewer Z values or measures than points")
+		}
+		return nil
+	}
+	withM := func(n int, m []float64) error {
+		e.m, e.dims = m, 2000
+		if len(m) < n {
+			return errors.New("Unable to encode WKB: shape has fewer measures than points")
+		}
+		return nil
+	}
+	lines := func(parts []int32, points []Point) {
+		ranges := partRanges(parts, len(points))
+		if len(ranges) != 1 {
+			e.header(wkbMultiLineString, len(ranges))
+		}
+		for _, pr := range ranges {
+			e.lineString(points, pr)
+		}
+	}
+	multiPoint := func(points []Point) {
+		e.header(wkbMultiPoint, len(points))
+		for i := range points {
+			e.header(wkbPoint, 1)
+			e.coords(points, i)
+		}
+	}
+
+	switch s := s.(type) {
+	case nil, *Null:
+		e.header(wkbGeometryCollection, 0)
+	case *Point:
+		e.header(wkbPoint, 1)
+		e.coords([]Point{*s}, 0)
+	case *PointZ:
+		withZ(1, []float64{s.Z}, []float64{s.M})
+		e.header(wkbPoint, 1)
+		e.coords([]Point{{s.X, s.Y}}, 0)
+	case *PointM:
+		withM(1, []float64{s.M})
+		e.header(wkbPoint, 1)
+		e.coords([]Point{{s.X, s.Y}}, 0)
+	case *MultiPoint:
+		multiPoint(s.Points)
+	case *MultiPointZ:
+		if err := withZ(len(s.Points), s.ZArray, s.MArray); err != nil {
+			return nil, err
+		}
+		multiPoint(s.Points)
+	case *MultiPointM:
+		if err := withM(len(s.Points), s.MArray); err != nil {
+			return nil, err
+		}
+		multiPoint(s.Points)
+	case *PolyLine:
+		lines(s.Parts, s.Points)
+	case *PolyLineZ:
+		if err := withZ(len(s.Points), s.ZArray, s.MArray); err != nil {
+			return nil, err
+		}
+		lines(s.Parts, s.Points)
+	case *PolyLineM:
+		if err := withM(len(s.Points), s.MArray); err != nil {
+			return nil, err
+		}
+		lines(s.Parts, s.Points)
+	case *Polygon:
+		e.polygons(s.Parts, s.Points)
+	case *PolygonZ:
+		if err := withZ(len(s.Points), s.ZArray, s.MArray); err != nil {
+			return nil, err
+		}
+		e.polygons(s.Parts, s.Points)
+	case *PolygonM:
+		if err := withM(len(s.Points), s.MArray); err != nil {
+			return nil, err
+		}
+		e.polygons(s.Parts, s.Points)
+	default:
+		return nil, fmt.Errorf("Unsupported shape for WKB: %T", s)
+	}
+	return e.buf.Bytes(), nil
+}
+
+// wkbDecoder reads WKB and EWKB in either byte order.
+type wkbDecoder struct {
+	b     []byte
+	order binary.ByteOrder
+	err   error
+}
+
+func (d *wkbDecoder) uint32() uint32 {
+	if d.err != nil {
+		return 0
+	}
+	if len(d.b) < 4 {
+		d.err = errors.New("Unable to decode WKB: unexpected end of data")
+		return 0
+	}
+	v := d.order.Uint32(d.b)
+	d.b = d.b[4:]
+	return v
+}
+
+func (d *wkbDecoder) float64() float64 {
+	if d.err != nil {
+		return 0
+	}
+	if len(d.b) < 8 {
+		d.err = errors.New("Unable to decode WKB: unexpected end of data")
+		return 0
+	}
+	v := math.Float64frombits(d.order.Uint64(d.b))
+	d.b = d.b[8:]
+	return v
+}
+
+// count reads the number of elements that follow, each of which takes at
+// least size bytes.
+func (d *wkbDecoder) count(size int) int {
+	n := int(d.uint32())
+	if d.err == nil && n > len(d.b)/size {
+		d.err = fmt.Errorf("Unable to decode WKB: %d elements exceed the data", n)
+		return 0
+	}
+	return n
+}
+
+// header reads the byte order and the type of the next geometry and returns
+// the type without dimensions and whether it has Z values and measures.
+func (d *wkbDecoder) header() (t uint32, hasZ, hasM bool) {
+	if d.err != nil {
+		return 0, false, false
+	}
+	if len(d.b) < 1 {
+		d.err = errors.New("Unable to decode WKB: unexpected end of data")
+		return 0, false, false
+	}
+	switch d.b[0] {
+	case 0:
+		d.order = binary.BigEndian
+	case 1:
+		d.order = binary.LittleEndian
+	default:
+		d.err = fmt.Errorf("Unable to decode WKB: invalid byte order %d", d.b[0])
+		return 0, false, false
+	}
+	d.b = d.b[1:]
+	t = d.uint32()
+	hasZ, hasM = t&ewkbZ != 0, t&ewkbM != 0
+	if t&ewkbSRID != 0 {
+		d.uint32()
+	}
+	t &^= ewkbZ | ewkbM | ewkbSRID
+	switch t / 1000 {
+	case 1:
+		hasZ = true
+	case 2:
+		hasM = true
+	case 3:
+		hasZ, hasM = true, true
+	}
+	return t % 1000, hasZ, hasM
+}
+
+// wkbCoords holds the coordinates of a geometry, grouped into parts.
+type wkbCoords struct {
+	hasZ, hasM bool
+	parts      [][]Point
+	z, m       [][]float64
+}
+
+func (c *wkbCoords) addPart() {
+	c.parts = append(c.parts, nil)
+	c.z = append(c.z, nil)
+	c.m = append(c.m, nil)
+}
+
+// point reads the coordinates of a point into the last part.
+func (d *wkbDecoder) point(c *wkbCoords, hasZ, hasM bool) {
+	n := len(c.parts) - 1
+	c.parts[n] = append(c.parts[n], Point{d.float64(), d.float64()})
+	z, m := 0.0, NoData
+	if hasZ {
+		z = d.float64()
+	}
+	if hasM {
+		m = d.float64()
+	}
+	c.z[n] = append(c.z[n], z)
+	c.m[n] = append(c.m[n], m)
+}
+
+func (d *wkbDecoder) points(c *wkbCoords, hasZ, hasM bool) {
+	size := 16
+	if hasZ {
+		size += 8
+	}
+	if hasM {
+		size += 8
+	}
+	c.addPart()
+	for i, n := 0, d.count(size); i < n; i++ {
+		d.point(c, hasZ, hasM)
+	}
+}
+
+// geometry reads a geometry of type want, or of any of the types in want if
+// it is a collection, and adds its coordinates to c. It returns the type of
+// the geometry that was read.
+func (d *wkbDecoder) geometry(c *wkbCoords, want ...uint32) uint32 {
+	t, hasZ, hasM := d.header()
+	if d.err != nil {
+		return 0
+	}
+	ok := len(want) == 0
+	for _, w := range want {
+		ok = ok || w == t
+	}
+	if !ok {
+		d.err = fmt.Errorf("Unable to decode WKB: unexpected geometry type %d", t)
+		return 0
+	}
+	c.hasZ, c.hasM = c.hasZ || hasZ, c.hasM || hasM
+	switch t {
+	case wkbPoint:
+		c.addPart()
+		d.point(c, hasZ, hasM)
+	case wkbLineString:
+		d.points(c, hasZ, hasM)
+	case wkbPolygon:
+		for i, n := 0, d.count(4); i < n; i++ {
+			d.points(c, hasZ, hasM)
+		}
+	case wkbMultiPoint:
+		for i, n := 0, d.count(21); i < n; i++ {
+			d.geometry(c, wkbPoint)
+		}
+	case wkbMultiLineString:
+		for i, n := 0, d.count(9); i < n; i++ {
+			d.geometry(c, wkbLineString)
+		}
+	case wkbMultiPolygon:
+		for i, n := 0, d.count(9); i < n; i++ {
+			d.geometry(c, wkbPolygon)
+		}
+	case wkbGeometryCollection:
+		if d.count(9) > 0 {
+			d.err = errors.New("Unable to decode WKB: GeometryCollections are not supported")
+		}
+	default:
+		d.err = fmt.Errorf("Unable to decode WKB: unsupported geometry type %d", t)
+	}
+	return t
+}
+
+// ShapeFromWKB decodes a geometry in Well-Known Binary, or in the Extended WKB
+// of PostGIS, into a shape. LineStrings and MultiLineStrings become
+// PolyLines, Polygons and MultiPolygons become Polygons whose rings are
+// closed and oriented like in NewPolygon, and Points and MultiPoints become
+// Points and MultiPoints. Geometries with Z values become Z shapes, with
+// NoData measures if they have none, and geometries with only measures
+// become M shapes. Empty geometries become Null shapes.
+func ShapeFromWKB(b []byte) (Shape, error) {
+	d := &wkbDecoder{b: b}
+	c := &wkbCoords{}
+	t := d.geometry(c)
+	if d.err != nil {
+		return nil, d.err
+	}
+	return c.shape(t)
+}
+
+// shape returns the shape for the coordinates of a geometry of type t.
+func (c *wkbCoords) shape(t uint32) (Shape, error) {
+	var points []Point
+	var z, m []float64
+	for i := range c.parts {
+		points = append(points, c.parts[i]...)
+		z = append(z, c.z[i]...)
+		m = append(m, c.m[i]...)
+	}
+	if len(points) == 0 || t == wkbPoint && math.IsNaN(points[0].X) {
+		return &Null{}, nil
+	}
+
+	switch t {
+	case wkbPoint:
+		p := points[0]
+		switch {
+		case c.hasZ:
+			return &PointZ{p.X, p.Y, z[0], m[0]}, nil
+		case c.hasM:
+			return &PointM{p.X, p.Y, m[0]}, nil
+		}
+		return &p, nil
+	case wkbMultiPoint:
+		switch {
+		case c.hasZ:
+			return NewMultiPointZ(points, z, m)
+		case c.hasM:
+			return NewMultiPointM(points, m)
+		}
+		return NewMultiPoint(points)
+	case wkbLineString, wkbMultiLineString:
+		switch {
+		case c.hasZ:
+			return NewPolyLineZ(c.parts, c.z, c.m)
+		case c.hasM:
+			return NewPolyLineM(c.parts, c.m)
+		}
+		if err := checkParts(c.parts, 2, "PolyLine"); err != nil {
+			return nil, err
+		}
+		return NewPolyLine(c.parts), nil
+	default:
+		switch {
+		case c.hasZ:
+			return NewPolygonZ(c.parts, c.z, c.m)
+		case c.hasM:
+			return NewPolygonM(c.parts, c.m)
+		}
+		return NewPolygon(c.parts)
+	}
+}
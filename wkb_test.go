@@ -0,0 +1,155 @@
+package shp
+
+import (
+	"encoding/hex"
+	"reflect"
+	"testing"
+)
+
+func TestMarshalWKB(t *testing.T) {
+	b, err := MarshalWKB(&Point{1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := hex.EncodeToString(b), "0101000000000000000000f03f0000000000000040"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	// a square with a hole and a second square become a MultiPolygon
+	square := func(x, y, size float64) []Point {
+		return []Point{{x, y}, {x + size, y}, {x + size, y + size}, {x, y + size}}
+	}
+	polygon, err := NewPolygon([][]Point{square(0, 0, 10), square(2, 2, 2), square(20, 20, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err = MarshalWKB(polygon)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := b[1]; got != wkbMultiPolygon {
+		t.Errorf("got geometry type %d, want MultiPolygon", got)
+	}
+
+	if _, err := MarshalWKB(&MultiPatch{}); err == nil {
+		t.Error("expected an error for a MultiPatch")
+	}
+	if _, err := MarshalWKB(&PolyLineZ{NumParts: 1, Parts: []int32{0}, Points: []Point{{}, {}}}); err == nil {
+		t.Error("expected an error for missing Z values")
+	}
+}
+
+// geometryShapes returns a shape of every type that can be represented as a
+// WKB or WKT geometry.
+func geometryShapes() []Shape {
+	line := [][]Point{{{0, 0}, {1, 1}}, {{2, 2}, {3, 3}, {4, 2}}}
+	zs := [][]float64{{1, 2}, {3, 4, 5}}
+	ms := [][]float64{{6, 7}, {8, 9, 10}}
+	ring := [][]Point{{{0, 0}, {0, 1}, {1, 1}, {1, 0}, {0, 0}}}
+	rz := [][]float64{{1, 2, 3, 4, 1}}
+	nodata := [][]float64{{NoData, NoData, NoData, NoData, NoData}}
+
+	polygon, _ := NewPolygon(ring)
+	polygonZ, _ := NewPolygonZ(ring, rz, nodata)
+	polygonM, _ := NewPolygonM(ring, rz)
+	polyLineZ, _ := NewPolyLineZ(line, zs, ms)
+	polyLineM, _ := NewPolyLineM(line[:1], ms[:1])
+	multiPoint, _ := NewMultiPoint(line[1])
+	multiPointZ, _ := NewMultiPointZ(line[1], zs[1], ms[1])
+	multiPointM, _ := NewMultiPointM(line[1], ms[1])
+	return []Shape{
+		&Null{},
+		&Point{1, 2},
+		&PointZ{1, 2, 3, 4},
+		&PointZ{1, 2, 3, NoData},
+		&PointM{1, 2, 4},
+		NewPolyLine(line),
+		NewPolyLine(line[:1]),
+		polyLineZ,
+		polyLineM,
+		polygon,
+		polygonZ,
+		polygonM,
+		multiPoint,
+		multiPointZ,
+		multiPointM,
+	}
+}
+
+func TestWKBRoundTrip(t *testing.T) {
+	for _, s := range geometryShapes() {
+		b, err := MarshalWKB(s)
+		if err != nil {
+			t.Errorf("%T: %v", s, err)
+			continue
+		}
+		got, err := ShapeFromWKB(b)
+		if err != nil {
+			t.Errorf("%T: %v", s, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, s) {
+			t.Errorf("got %#v, want %#v", got, s)
+		}
+	}
+}
+
+func TestShapeFromWKB(t *testing.T) {
+	// EWKB of SRID=4326;POINT(1 2 3) in big-endian byte order
+	b, _ := hex.DecodeString("00a0000001000010e63ff000000000000040000000000000004008000000000000")
+	s, err := ShapeFromWKB(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (&PointZ{1, 2, 3, NoData}); !reflect.DeepEqual(s, want) {
+		t.Errorf("got %#v, want %#v", s, want)
+	}
+
+	for name, in := range map[string]string{
+		"truncated":            "0101000000000000000000f03f",
+		"byte order":           "0201000000000000000000f03f0000000000000040",
+		"count":                "010200000000ffffff",
+		"type":                 "0111000000",
+		"collection":           "0107000000010000000101000000000000000000f03f0000000000000040",
+		"member of MultiPoint": "01040000000100000001020000000000000000",
+	} {
+		b, _ := hex.DecodeString(in)
+		if _, err := ShapeFromWKB(b); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestMarshalEWKB(t *testing.T) {
+	b, err := MarshalEWKB(&Point{1, 2}, 4326)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := hex.EncodeToString(b), "0101000020e6100000000000000000f03f0000000000000040"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	// Z shapes without measures are written with NoData measures
+	s := &PolyLineZ{
+		Box:       Box{0, 0, 1, 1},
+		NumParts:  1,
+		NumPoints: 2,
+		Parts:     []int32{0},
+		Points:    []Point{{0, 0}, {1, 1}},
+		ZArray:    []float64{5, 6},
+	}
+	b, err = MarshalEWKB(s, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := hex.EncodeToString(b[:5]); got != "01020000c0" {
+		t.Errorf("type = %s, want LineString with Z and M", got)
+	}
+	got, err := ShapeFromWKB(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := got.(*PolyLineZ).MArray; len(m) != 2 || !IsNoData(m[0]) || !IsNoData(m[1]) {
+		t.Errorf("measures = %v, want NoData", m)
+	}
+}
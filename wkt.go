@@ -0,0 +1,309 @@
+package shp
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// wktNames are the WKT keywords of the geometry types.
+var wktNames = map[uint32]string{
+	wkbPoint:              "POINT",
+	wkbLineString:         "LINESTRING",
+	wkbPolygon:            "POLYGON",
+	wkbMultiPoint:         "MULTIPOINT",
+	wkbMultiLineString:    "MULTILINESTRING",
+	wkbMultiPolygon:       "MULTIPOLYGON",
+	wkbGeometryCollection: "GEOMETRYCOLLECTION",
+}
+
+// MarshalWKT returns the Well-Known Text representation of s, e.g.
+// "POINT (1 2)" or "LINESTRING Z (0 0 1, 1 1 2)". The shapes are mapped to
+// geometries like in MarshalWKB.
+func MarshalWKT(s Shape) (string, error) {
+	b, err := MarshalWKB(s)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	d := &wkbDecoder{b: b}
+	d.wkt(&sb, true)
+	return sb.String(), d.err
+}
+
+// wkt writes the next geometry as WKT. Members of collections are written
+// without their keyword.
+func (d *wkbDecoder) wkt(sb *strings.Builder, keyword bool) {
+	t, hasZ, hasM := d.header()
+	if d.err != nil {
+		return
+	}
+	if keyword {
+		sb.WriteString(wktNames[t])
+		switch {
+		case hasZ && hasM:
+			sb.WriteString(" ZM")
+		case hasZ:
+			sb.WriteString(" Z")
+		case hasM:
+			sb.WriteString(" M")
+		}
+		sb.WriteByte(' ')
+	}
+	dims := 2
+	if hasZ {
+		dims++
+	}
+	if hasM {
+		dims++
+	}
+	position := func() {
+		for i := 0; i < dims; i++ {
+			if i > 0 {
+				sb.WriteByte(' ')
+			}
+			sb.WriteString(strconv.FormatFloat(d.float64(), 'f', -1, 64))
+		}
+	}
+	list := func(n int, item func()) {
+		if n == 0 {
+			sb.WriteString("EMPTY")
+			return
+		}
+		sb.WriteByte('(')
+		for i := 0; i < n; i++ {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			item()
+		}
+		sb.WriteByte(')')
+	}
+	positions := func() {
+		list(d.count(8*dims), position)
+	}
+	switch t {
+	case wkbPoint:
+		list(1, position)
+	case wkbLineString:
+		positions()
+	case wkbPolygon:
+		list(d.count(4), positions)
+	case wkbMultiPoint, wkbMultiLineString, wkbMultiPolygon:
+		list(d.count(9), func() { d.wkt(sb, false) })
+	case wkbGeometryCollection:
+		list(d.count(9), func() { d.wkt(sb, true) })
+	default:
+		d.err = fmt.Errorf("Unable to encode WKT: unsupported geometry type %d", t)
+	}
+}
+
+// wktGeometryParser parses geometries in Well-Known Text.
+type wktGeometryParser struct {
+	s   string
+	pos int
+}
+
+func (p *wktGeometryParser) skipSpace() {
+	for p.pos < len(p.s) && strings.IndexByte(" \t\r\n", p.s[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+// peek returns the next character that is not white space, or 0 at the end.
+func (p *wktGeometryParser) peek() byte {
+	p.skipSpace()
+	if p.pos == len(p.s) {
+		return 0
+	}
+	return p.s[p.pos]
+}
+
+// word returns the next keyword in upper case.
+func (p *wktGeometryParser) word() string {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.s) {
+		c := p.s[p.pos] | 0x20
+		if (c < 'a' || c > 'z') && (p.pos == start || c < '0' || c > '9') {
+			break
+		}
+		p.pos++
+	}
+	return strings.ToUpper(p.s[start:p.pos])
+}
+
+func (p *wktGeometryParser) expect(c byte) error {
+	if p.peek() != c {
+		return p.errorf("expected %q", c)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *wktGeometryParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("Unable to parse WKT at offset %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+// number parses the next number.
+func (p *wktGeometryParser) number() (float64, bool) {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.s) && strings.IndexByte("+-.0123456789eE", p.s[p.pos]) >= 0 {
+		p.pos++
+	}
+	f, err := strconv.ParseFloat(p.s[start:p.pos], 64)
+	if err != nil {
+		p.pos = start
+		return 0, false
+	}
+	return f, true
+}
+
+// position parses a position with the given number of ordinates, or with 2
+// to 4 ordinates if dims is 0, which then determine whether the geometry has
+// Z values and measures, and adds it to the last part of c.
+func (p *wktGeometryParser) position(c *wkbCoords, dims *int, hasZ, hasM *bool) error {
+	var v []float64
+	for len(v) < 4 {
+		f, ok := p.number()
+		if !ok {
+			break
+		}
+		v = append(v, f)
+	}
+	if *dims == 0 && len(v) >= 2 {
+		*dims = len(v)
+		*hasZ, *hasM = len(v) > 2, len(v) > 3
+	}
+	if len(v) != *dims {
+		return p.errorf("got %d ordinates instead of %d", len(v), *dims)
+	}
+	z, m := 0.0, NoData
+	switch {
+	case *hasZ && *hasM:
+		z, m = v[2], v[3]
+	case *hasZ:
+		z = v[2]
+	case *hasM:
+		m = v[2]
+	}
+	n := len(c.parts) - 1
+	c.parts[n] = append(c.parts[n], Point{v[0], v[1]})
+	c.z[n] = append(c.z[n], z)
+	c.m[n] = append(c.m[n], m)
+	return nil
+}
+
+// list parses EMPTY or a parenthesized, comma-separated list of items.
+func (p *wktGeometryParser) list(item func() error) error {
+	if p.peek() != '(' {
+		if p.word() != "EMPTY" {
+			return p.errorf("expected ( or EMPTY")
+		}
+		return nil
+	}
+	p.pos++
+	for {
+		if err := item(); err != nil {
+			return err
+		}
+		if p.peek() != ',' {
+			return p.expect(')')
+		}
+		p.pos++
+	}
+}
+
+// geometry parses a geometry and adds its coordinates to c. It returns the
+// type of the geometry.
+func (p *wktGeometryParser) geometry(c *wkbCoords) (uint32, error) {
+	name := p.word()
+	var t uint32
+	for typ, n := range wktNames {
+		if strings.HasPrefix(name, n) && len(n) > len(wktNames[t]) {
+			t = typ
+		}
+	}
+	if t == 0 {
+		return 0, p.errorf("unknown geometry type %q", name)
+	}
+	tag := strings.TrimPrefix(name, wktNames[t])
+	if tag == "" && p.peek() != '(' {
+		start := p.pos
+		if tag = p.word(); tag == "EMPTY" {
+			tag, p.pos = "", start
+		}
+	}
+	dims := 0
+	switch tag {
+	case "":
+	case "Z", "M":
+		dims = 3
+	case "ZM":
+		dims = 4
+	default:
+		return 0, p.errorf("unknown dimensions %q", tag)
+	}
+	hasZ, hasM := strings.Contains(tag, "Z"), strings.Contains(tag, "M")
+
+	position := func() error {
+		return p.position(c, &dims, &hasZ, &hasM)
+	}
+	positions := func() error {
+		c.addPart()
+		return p.list(position)
+	}
+	var err error
+	switch t {
+	case wkbPoint:
+		err = p.list(func() error {
+			c.addPart()
+			return position()
+		})
+	case wkbLineString:
+		err = positions()
+	case wkbPolygon:
+		err = p.list(positions)
+	case wkbMultiPoint:
+		// the positions of MultiPoints may or may not be parenthesized
+		err = p.list(func() error {
+			c.addPart()
+			if p.peek() != '(' {
+				return position()
+			}
+			return p.list(position)
+		})
+	case wkbMultiLineString:
+		err = p.list(positions)
+	case wkbMultiPolygon:
+		err = p.list(func() error { return p.list(positions) })
+	case wkbGeometryCollection:
+		err = p.list(func() error { return p.errorf("GeometryCollections are not supported") })
+	}
+	c.hasZ, c.hasM = hasZ, hasM
+	return t, err
+}
+
+// ShapeFromWKT parses a geometry in Well-Known Text, or in the Extended WKT of
+// PostGIS with a leading SRID, into a shape. Geometries are mapped to shapes
+// like in ShapeFromWKB. The dimensions are taken from the Z, M or ZM tag, or
+// from the number of ordinates if there is none.
+func ShapeFromWKT(s string) (Shape, error) {
+	p := &wktGeometryParser{s: s}
+	if strings.HasPrefix(strings.ToUpper(strings.TrimSpace(s)), "SRID=") {
+		p.pos = strings.IndexByte(s, ';') + 1
+		if p.pos == 0 {
+			return nil, p.errorf("expected ; after SRID")
+		}
+	}
+	c := &wkbCoords{}
+	t, err := p.geometry(c)
+	if err != nil {
+		return nil, err
+	}
+	if p.peek() != 0 {
+		return nil, p.errorf("unexpected text after geometry")
+	}
+	return c.shape(t)
+}
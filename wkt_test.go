@@ -0,0 +1,84 @@
+package shp
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMarshalWKT(t *testing.T) {
+	polyLineZ, _ := NewPolyLineZ([][]Point{{{0, 0}, {1, 1.5}}}, [][]float64{{1, 2}}, [][]float64{{NoData, NoData}})
+	multiPointM, _ := NewMultiPointM([]Point{{1, 2}, {3, 4}}, []float64{5, 6})
+	for _, test := range []struct {
+		s    Shape
+		want string
+	}{
+		{&Null{}, "GEOMETRYCOLLECTION EMPTY"},
+		{&Point{1, -2}, "POINT (1 -2)"},
+		{&PointZ{1, 2, 3, 4}, "POINT ZM (1 2 3 4)"},
+		{polyLineZ, "LINESTRING Z (0 0 1, 1 1.5 2)"},
+		{multiPointM, "MULTIPOINT M ((1 2 5), (3 4 6))"},
+	} {
+		got, err := MarshalWKT(test.s)
+		if err != nil {
+			t.Errorf("%T: %v", test.s, err)
+		} else if got != test.want {
+			t.Errorf("got %s, want %s", got, test.want)
+		}
+	}
+}
+
+func TestWKTRoundTrip(t *testing.T) {
+	for _, s := range geometryShapes() {
+		wkt, err := MarshalWKT(s)
+		if err != nil {
+			t.Errorf("%T: %v", s, err)
+			continue
+		}
+		got, err := ShapeFromWKT(wkt)
+		if err != nil {
+			t.Errorf("%s: %v", wkt, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, s) {
+			t.Errorf("%s: got %#v, want %#v", wkt, got, s)
+		}
+	}
+}
+
+func TestShapeFromWKT(t *testing.T) {
+	multiPoint, _ := NewMultiPoint([]Point{{1, 2}, {3, 4}})
+	for wkt, want := range map[string]Shape{
+		"POINT(1 2)":                                 &Point{1, 2},
+		"point z (1 2 3)":                            &PointZ{1, 2, 3, NoData},
+		"POINTM(1 2 3)":                              &PointM{1, 2, 3},
+		"POINT (1 2 3)":                              &PointZ{1, 2, 3, NoData},
+		"SRID=4326;POINT(1e3 -2.5)":                  &Point{1000, -2.5},
+		"MULTIPOINT (1 2, 3 4)":                      multiPoint,
+		"MULTIPOINT ((1 2), (3 4))":                  multiPoint,
+		"POINT EMPTY":                                &Null{},
+		"  LINESTRING Z EMPTY  ":                     &Null{},
+		"MULTIPOLYGON (((0 0, 0 1, 1 1, 1 0, 0 0)))": geometryShapes()[9],
+	} {
+		got, err := ShapeFromWKT(wkt)
+		if err != nil {
+			t.Errorf("%s: %v", wkt, err)
+		} else if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %#v, want %#v", wkt, got, want)
+		}
+	}
+
+	for _, wkt := range []string{
+		"POINT (1)",
+		"CIRCLE (1 2)",
+		"POINT (1 2) x",
+		"POINT Q (1 2)",
+		"LINESTRING (1 2, 3 4 5)",
+		"LINESTRING (1 2, 3 4",
+		"GEOMETRYCOLLECTION (POINT (1 2))",
+		"SRID=4326 POINT (1 2)",
+	} {
+		if _, err := ShapeFromWKT(wkt); err == nil {
+			t.Errorf("%s: expected an error", wkt)
+		}
+	}
+}
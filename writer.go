@@ -1,15 +1,21 @@
 package shp
 
 import (
+	"bytes"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"math"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/unicode"
 )
 
 // Writer is the type that is used to write a new shapefile.
@@ -20,11 +26,37 @@ type Writer struct {
 	GeometryType ShapeType
 	num          int32
 	bbox         Box
+	err          error
+	opts         options
+	// boxed is set once bbox holds the box of a shape other than Null.
+	boxed bool
+	// zRange and mRange are the ranges of the Z values and measures, which
+	// are set once zRanged and mRanged are.
+	zRange, mRange   [2]float64
+	zRanged, mRanged bool
+	// transform is set by SetTransform.
+	transform Transform
+	// round is set by SetPrecision.
+	round func(float64) float64
+	// strict is set by SetStrict.
+	strict bool
+	// parts holds the base names of the parts that were completed by
+	// WithAutoSplit, starting with the original one.
+	parts []string
+	// created holds the files the Writer created, which Abort removes.
+	created []string
+	// files creates the file with the given extension instead of the file
+	// system if it is set.
+	files func(ext string) (writeSeekCloser, error)
+	// sidecars holds the .prj and .cpg of writers without targets for them.
+	sidecars map[string]*memFile
 
 	dbf             writeSeekCloser
 	dbfFields       []Field
 	dbfHeaderLength int16
 	dbfRecordLength int16
+	// charset is the encoding that string attributes are converted to.
+	charset encoding.Encoding
 }
 
 type writeSeekCloser interface {
@@ -40,50 +72,102 @@ type writeSeekCloser interface {
 // and DBF).
 // If filename does not end on ".shp" already, it will be treated as the basename
 // for the file and the ".shp" extension will be appended to that name.
-func Create(filename string, t ShapeType) (*Writer, error) {
-	if strings.HasSuffix(strings.ToLower(filename), ".shp") {
-		filename = filename[0 : len(filename)-4]
+func Create(filename string, t ShapeType, opts ...Option) (*Writer, error) {
+	return newWriter(trimExt(filename, ".shp"), t, nil, opts)
+}
+
+// newWriter returns a Writer for a new shapefile whose files are created by
+// files, or in the file system at filename if files is nil.
+func newWriter(filename string, t ShapeType, files func(string) (writeSeekCloser, error), opts []Option) (*Writer, error) {
+	w := &Writer{
+		filename:     filename,
+		GeometryType: t,
+		opts:         newOptions(opts),
+		files:        files,
 	}
-	shp, err := os.Create(filename + ".shp")
-	if err != nil {
+	var err error
+	if w.shp, err = w.createFile(".shp"); err != nil {
 		return nil, err
 	}
-	shx, err := os.Create(filename + ".shx")
-	if err != nil {
+	if w.shx, err = w.createFile(".shx"); err != nil {
+		w.Abort()
 		return nil, err
 	}
-	shp.Seek(100, io.SeekStart)
-	shx.Seek(100, io.SeekStart)
-	w := &Writer{
-		filename:     filename,
-		shp:          shp,
-		shx:          shx,
-		GeometryType: t,
+	if w.opts.charset != nil {
+		if err := w.SetCharset(w.opts.charset); err != nil {
+			w.Abort()
+			return nil, err
+		}
 	}
+	w.shp.Seek(100, io.SeekStart)
+	w.shx.Seek(100, io.SeekStart)
 	return w, nil
 }
 
+// createFile creates the file of the shapefile with the given extension.
+func (w *Writer) createFile(ext string) (writeSeekCloser, error) {
+	if w.files != nil {
+		return w.files(ext)
+	}
+	f, err := os.Create(w.filename + ext)
+	if err != nil {
+		return nil, err
+	}
+	w.created = append(w.created, w.filename+ext)
+	return f, nil
+}
+
 // Append returns a Writer pointer that will append to the given shapefile and
 // the first error that was encounted during creation of that Writer. The
-// shapefile must have a valid index file.
-func Append(filename string) (*Writer, error) {
+// shapefile must have a valid index file. The shape type must be known and
+// the DBF, if there is one, must have a row for every shape and rows as long
+// as its fields, so that records appended to the files stay aligned. Close
+// updates the headers and extents of all files.
+func Append(filename string, opts ...Option) (*Writer, error) {
 	shp, err := os.OpenFile(filename, os.O_RDWR, 0666)
 	if err != nil {
 		return nil, err
 	}
+	// the files are closed again unless a Writer is returned for them
+	var shx, dbf *os.File
+	ok := false
+	defer func() {
+		if ok {
+			return
+		}
+		shp.Close()
+		if shx != nil {
+			shx.Close()
+		}
+		if dbf != nil {
+			dbf.Close()
+		}
+	}()
 	ext := filepath.Ext(filename)
 	basename := filename[:len(filename)-len(ext)]
 	w := &Writer{
 		filename: basename,
 		shp:      shp,
+		opts:     newOptions(opts),
+	}
+	var code int32
+	err = binary.Read(shp, binary.BigEndian, &code)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot read SHP file code: %v", err)
+	}
+	if code != 9994 {
+		return nil, fmt.Errorf("Cannot append to %s: not a shapefile", filename)
 	}
 	_, err = shp.Seek(32, io.SeekStart)
 	if err != nil {
-		return nil, fmt.Errorf("cannot seek to SHP geometry type: %v", err)
+		return nil, fmt.Errorf("Cannot seek to SHP geometry type: %v", err)
 	}
 	err = binary.Read(shp, binary.LittleEndian, &w.GeometryType)
 	if err != nil {
-		return nil, fmt.Errorf("cannot read geometry type: %v", err)
+		return nil, fmt.Errorf("Cannot read geometry type: %v", err)
+	}
+	if !knownShapeType(w.GeometryType) {
+		return nil, fmt.Errorf("Cannot append to shapefile of unsupported shape type %v", w.GeometryType)
 	}
 	er := &errReader{Reader: shp}
 	w.bbox.MinX = readFloat64(er)
@@ -91,103 +175,193 @@ func Append(filename string) (*Writer, error) {
 	w.bbox.MaxX = readFloat64(er)
 	w.bbox.MaxY = readFloat64(er)
 	if er.e != nil {
-		return nil, fmt.Errorf("cannot read bounding box: %v", er.e)
+		return nil, fmt.Errorf("Cannot read bounding box: %v", er.e)
+	}
+	w.zRange = [2]float64{readFloat64(er), readFloat64(er)}
+	w.mRange = [2]float64{readFloat64(er), readFloat64(er)}
+	if er.e != nil {
+		return nil, fmt.Errorf("Cannot read Z and measure ranges: %v", er.e)
 	}
 
-	shx, err := os.OpenFile(basename+".shx", os.O_RDWR, 0666)
+	shx, err = os.OpenFile(basename+".shx", os.O_RDWR, 0666)
 	if os.IsNotExist(err) {
 		// TODO allow index file to not exist, in that case just
 		// read through all the shapes and create it on the fly
 	}
 	if err != nil {
-		return nil, fmt.Errorf("cannot open shapefile index: %v", err)
-	}
-	_, err = shx.Seek(-8, io.SeekEnd)
-	if err != nil {
-		return nil, fmt.Errorf("cannot seek to last shape index: %v", err)
-	}
-	var offset int32
-	err = binary.Read(shx, binary.BigEndian, &offset)
-	if err != nil {
-		return nil, fmt.Errorf("cannot read last shape index: %v", err)
+		return nil, fmt.Errorf("Cannot open shapefile index: %v", err)
 	}
-	offset = offset * 2
-	_, err = shp.Seek(int64(offset), io.SeekStart)
+	// the index has an entry of 8 bytes for every shape
+	size, err := shx.Seek(0, io.SeekEnd)
 	if err != nil {
-		return nil, fmt.Errorf("cannot seek to last shape: %v", err)
+		return nil, fmt.Errorf("Cannot seek to SHX end: %v", err)
 	}
-	err = binary.Read(shp, binary.BigEndian, &w.num)
-	if err != nil {
-		return nil, fmt.Errorf("cannot read number of last shape: %v", err)
+	if size < 100 || (size-100)%8 != 0 {
+		return nil, fmt.Errorf("Cannot append to shapefile with invalid index of %d bytes", size)
 	}
+	w.num = int32((size - 100) / 8)
+	w.boxed = w.num > 0
+	// files of older writers have empty ranges, which are not extended
+	w.zRanged = w.num > 0 && w.zRange != [2]float64{}
+	w.mRanged = w.num > 0 && w.mRange != [2]float64{}
 	_, err = shp.Seek(0, io.SeekEnd)
 	if err != nil {
-		return nil, fmt.Errorf("cannot seek to SHP end: %v", err)
-	}
-	_, err = shx.Seek(0, io.SeekEnd)
-	if err != nil {
-		return nil, fmt.Errorf("cannot seek to SHX end: %v", err)
+		return nil, fmt.Errorf("Cannot seek to SHP end: %v", err)
 	}
 	w.shx = shx
 
-	dbf, err := os.Open(basename + ".dbf")
+	dbf, err = os.OpenFile(basename+".dbf", os.O_RDWR, 0666)
 	if os.IsNotExist(err) {
+		ok = true
 		return w, nil // it's okay if the DBF does not exist
 	}
 	if err != nil {
-		return nil, fmt.Errorf("cannot open DBF: %v", err)
+		return nil, fmt.Errorf("Cannot open DBF: %v", err)
 	}
 
-	_, err = dbf.Seek(8, io.SeekStart)
+	_, err = dbf.Seek(4, io.SeekStart)
 	if err != nil {
-		return nil, fmt.Errorf("cannot seek in DBF: %v", err)
+		return nil, fmt.Errorf("Cannot seek in DBF: %v", err)
+	}
+	var numRecords int32
+	err = binary.Read(dbf, binary.LittleEndian, &numRecords)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot read number of records from DBF: %v", err)
 	}
 	err = binary.Read(dbf, binary.LittleEndian, &w.dbfHeaderLength)
 	if err != nil {
-		return nil, fmt.Errorf("cannot read header length from DBF: %v", err)
+		return nil, fmt.Errorf("Cannot read header length from DBF: %v", err)
 	}
 	err = binary.Read(dbf, binary.LittleEndian, &w.dbfRecordLength)
 	if err != nil {
-		return nil, fmt.Errorf("cannot read record length from DBF: %v", err)
+		return nil, fmt.Errorf("Cannot read record length from DBF: %v", err)
 	}
 
-	_, err = dbf.Seek(20, io.SeekCurrent) // skip padding
+	var reserved [20]byte
+	_, err = io.ReadFull(dbf, reserved[:])
 	if err != nil {
-		return nil, fmt.Errorf("cannot seek in DBF: %v", err)
+		return nil, fmt.Errorf("Cannot read DBF header: %v", err)
 	}
+	w.charset = w.opts.readCharset(readCompanion(basename, ".cpg"), reserved[17])
 	numFields := int(math.Floor(float64(w.dbfHeaderLength-33) / 32.0))
 	w.dbfFields = make([]Field, numFields)
 	err = binary.Read(dbf, binary.LittleEndian, &w.dbfFields)
 	if err != nil {
-		return nil, fmt.Errorf("cannot read number of fields from DBF: %v", err)
+		return nil, fmt.Errorf("Cannot read number of fields from DBF: %v", err)
+	}
+	recordLength := 1
+	for _, f := range w.dbfFields {
+		recordLength += int(f.Size)
+	}
+	if recordLength != int(w.dbfRecordLength) {
+		return nil, fmt.Errorf("Cannot append to DBF whose record length %d does not match its fields of %d bytes", w.dbfRecordLength, recordLength)
 	}
-	_, err = dbf.Seek(0, io.SeekEnd) // skip padding
+	if numRecords != w.num {
+		return nil, fmt.Errorf("Cannot append to DBF with %d records for %d shapes", numRecords, w.num)
+	}
+	// drop the end-of-file marker, if any, which would otherwise end up
+	// between the rows
+	end := int64(w.dbfHeaderLength) + int64(w.num)*int64(w.dbfRecordLength)
+	if err := dbf.Truncate(end); err != nil {
+		return nil, fmt.Errorf("Cannot truncate DBF: %v", err)
+	}
+	_, err = dbf.Seek(0, io.SeekEnd)
 	if err != nil {
-		return nil, fmt.Errorf("cannot seek to DBF end: %v", err)
+		return nil, fmt.Errorf("Cannot seek to DBF end: %v", err)
 	}
 	w.dbf = dbf
 
+	ok = true
 	return w, nil
 }
 
 // Write shape to the Shapefile. This also creates
 // a record in the SHX file and DBF file (if it is
 // initialized). Returns the index of the written object
-// which can be used in WriteAttribute.
+// which can be used in WriteAttribute. Shapes should be
+// built with constructors such as NewPolygon or
+// NewPolyLineChecked rather than struct literals, as these
+// keep counts, part offsets, boxes and ranges consistent. If the shape is not of the
+// type of the Writer or Null, or cannot be encoded, nothing
+// is written, -1 is returned and the error is available
+// through Err.
 func (w *Writer) Write(shape Shape) int32 {
-	// increate bbox
-	if w.num == 0 {
-		w.bbox = shape.BBox()
-	} else {
-		w.bbox.Extend(shape.BBox())
+	if w.opts.swapXY {
+		shape = SwapXY(shape)
+	}
+	if w.opts.orient {
+		if parts, points := polygonRings(shape); parts != nil && misorientedRings(parts, points) > 0 {
+			shape = cloneShape(shape)
+			fixOrientation(shape)
+		}
+	}
+	if w.transform != nil {
+		shape = TransformShape(shape, w.transform)
+	}
+	if w.opts.simplify > 0 {
+		shape = Simplify(shape, w.opts.simplify)
+	}
+	if round := w.rounding(); round != nil {
+		shape = cloneShape(shape)
+		transformPoints(shape, func(p Point) Point {
+			return Point{round(p.X), round(p.Y)}
+		})
+	}
+
+	if t := shapeTypeOf(shape, w.GeometryType); t != NULL && t != w.GeometryType {
+		w.err = fmt.Errorf("Unable to write shape of type %v to shapefile of type %v: %w", t, w.GeometryType, ErrShapeTypeMismatch)
+		return -1
+	}
+	if w.strict {
+		if err := checkShape(shape); err != nil {
+			w.err = fmt.Errorf("Unable to write record %d: %w", w.num+1, err)
+			return -1
+		}
+	}
+	shape = withRanges(shape)
+	content, err := encodeShape(w.GeometryType, shape)
+	if err != nil {
+		w.err = err
+		return -1
+	}
+	stripped := w.opts.omitM || w.opts.omitNoDataM && !hasShapeMeasures(shape)
+	if stripped {
+		content = stripMeasures(w.GeometryType, content)
+	}
+	if w.exceedsMaxSize(int64(len(content))) {
+		if !w.opts.autoSplit || w.files != nil {
+			w.err = fmt.Errorf("Unable to write record %d: %w", w.num+1, ErrFileTooLarge)
+			return -1
+		}
+		if err := w.nextPart(); err != nil {
+			w.err = fmt.Errorf("Unable to start new part for record %d: %v", w.num+1, err)
+			return -1
+		}
+	}
+
+	// increase bbox, which Null shapes do not count towards
+	if _, null := shape.(*Null); !null {
+		if w.boxed {
+			w.bbox.Extend(shape.BBox())
+		} else {
+			w.bbox = shape.BBox()
+			w.boxed = true
+		}
+		w.extendRanges(shape, !stripped)
 	}
 
 	w.num++
 	binary.Write(w.shp, binary.BigEndian, w.num)
 	w.shp.Seek(4, io.SeekCurrent)
 	start, _ := w.shp.Seek(0, io.SeekCurrent)
-	binary.Write(w.shp, binary.LittleEndian, w.GeometryType)
-	shape.write(w.shp)
+	// Null shapes are allowed in files of any type and have no content
+	// besides their type
+	if _, ok := shape.(*Null); ok {
+		binary.Write(w.shp, binary.LittleEndian, NULL)
+	} else {
+		binary.Write(w.shp, binary.LittleEndian, w.GeometryType)
+	}
+	w.shp.Write(content)
 	finish, _ := w.shp.Seek(0, io.SeekCurrent)
 	length := int32(math.Floor((float64(finish) - float64(start)) / 2.0))
 	w.shp.Seek(start-4, io.SeekStart)
@@ -203,6 +377,9 @@ func (w *Writer) Write(shape Shape) int32 {
 		w.writeEmptyRecord()
 	}
 
+	if w.opts.progress != nil {
+		w.opts.progress(int64(w.num), -1)
+	}
 	return w.num - 1
 }
 
@@ -210,6 +387,14 @@ func (w *Writer) Write(shape Shape) int32 {
 // the transaction because it writes the correct headers
 // to the SHP/SHX and DBF files before closing.
 func (w *Writer) Close() {
+	w.finish()
+	if w.opts.progress != nil {
+		w.opts.progress(int64(w.num), int64(w.num))
+	}
+}
+
+// finish writes the headers and closes the files.
+func (w *Writer) finish() {
 	w.writeHeader(w.shx)
 	w.writeHeader(w.shp)
 	w.shp.Close()
@@ -218,8 +403,128 @@ func (w *Writer) Close() {
 	if w.dbf == nil {
 		w.SetFields([]Field{})
 	}
-	w.writeDbfHeader(w.dbf)
-	w.dbf.Close()
+	// a writer without a target for the DBF has none
+	if w.dbf != nil {
+		w.writeDbfHeader(w.dbf)
+		w.dbf.Close()
+	}
+}
+
+// exceedsMaxSize reports whether writing a record with content of the given
+// length, not counting the shape type, would make the .shp or .dbf larger
+// than maxFileSize.
+func (w *Writer) exceedsMaxSize(content int64) bool {
+	end, _ := w.shp.Seek(0, io.SeekCurrent)
+	if end+8+4+content > maxFileSize {
+		return true
+	}
+	if w.dbf != nil {
+		rows := int64(w.dbfHeaderLength) + int64(w.num+1)*int64(w.dbfRecordLength)
+		// the end-of-file marker some readers expect
+		return rows+1 > maxFileSize
+	}
+	return false
+}
+
+// nextPart completes the current shapefile and continues in the next part,
+// which has the same type, fields, charset and projection. The bounding box
+// and the record numbers start over.
+func (w *Writer) nextPart() error {
+	w.finish()
+	base := w.filename
+	if len(w.parts) > 0 {
+		base = w.parts[0]
+	}
+	parts := append(w.parts, w.filename)
+	next, err := newWriter(fmt.Sprintf("%s_%d", base, len(parts)+1), w.GeometryType, nil, nil)
+	if err != nil {
+		return err
+	}
+	next.opts, next.transform, next.round, next.parts = w.opts, w.transform, w.round, parts
+	next.created = append(w.created, next.created...)
+	if w.charset != nil {
+		if err := next.SetCharset(w.charset); err != nil {
+			next.Abort()
+			return err
+		}
+	}
+	if w.dbf != nil {
+		if err := next.SetFields(w.dbfFields); err != nil {
+			next.Abort()
+			return err
+		}
+	}
+	if prj := readCompanion(w.filename, ".prj"); prj != "" {
+		if err := ioutil.WriteFile(next.filename+".prj", []byte(prj), 0666); err != nil {
+			next.Abort()
+			return err
+		}
+		next.created = append(next.created, next.filename+".prj")
+	}
+	*w = *next
+	return nil
+}
+
+// Parts returns the names of the .shp files that the Writer has written to,
+// in order. Unless the Writer was created WithAutoSplit and reached the
+// maximum file size, it is only the file passed to Create. The row indexes
+// returned by Write start at 0 in every part.
+func (w *Writer) Parts() []string {
+	var names []string
+	for _, base := range append(w.parts, w.filename) {
+		names = append(names, base+".shp")
+	}
+	return names
+}
+
+// Abort closes the Writer without writing the headers and removes all files
+// it created, so that no incomplete shapefile is left behind when writing
+// fails. Files that existed before, such as those of a shapefile opened with
+// Append, are closed but not removed. The files are closed before they are
+// removed, as required on Windows. Abort returns the first error that
+// occurred while removing the files.
+func (w *Writer) Abort() error {
+	for _, f := range []writeSeekCloser{w.shp, w.shx, w.dbf} {
+		if f != nil {
+			f.Close()
+		}
+	}
+	var first error
+	for _, name := range w.created {
+		if err := os.Remove(name); err != nil && !os.IsNotExist(err) && first == nil {
+			first = err
+		}
+	}
+	w.created = nil
+	return first
+}
+
+// SetProjection writes wkt, the Well-Known Text of the coordinate reference
+// system of the shapefile, to its .prj file. Without a .prj file, GIS tools
+// have to guess how to interpret the coordinates.
+func (w *Writer) SetProjection(wkt string) error {
+	if _, err := ParseCRS(wkt); err != nil {
+		return err
+	}
+	f, err := w.createFile(".prj")
+	if err != nil {
+		return fmt.Errorf("Unable to create projection file: %v", err)
+	}
+	_, err = io.WriteString(f, wkt)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// SetProjectionEPSG writes the .prj file for the coordinate reference system
+// with the given EPSG code. See EPSGToWKT for the known codes.
+func (w *Writer) SetProjectionEPSG(code int) error {
+	wkt, err := EPSGToWKT(code)
+	if err != nil {
+		return err
+	}
+	return w.SetProjection(wkt)
 }
 
 // writeHeader wrires SHP/SHX headers to ws.
@@ -238,7 +543,7 @@ func (w *Writer) writeHeader(ws io.WriteSeeker) {
 	// bounding box
 	binary.Write(ws, binary.LittleEndian, w.bbox)
 	// elevation, measure
-	binary.Write(ws, binary.LittleEndian, []float64{0.0, 0.0, 0.0, 0.0})
+	binary.Write(ws, binary.LittleEndian, []float64{w.zRange[0], w.zRange[1], w.mRange[0], w.mRange[1]})
 }
 
 // writeDbfHeader writes a DBF header to ws.
@@ -250,8 +555,10 @@ func (w *Writer) writeDbfHeader(ws io.WriteSeeker) {
 	binary.Write(ws, binary.LittleEndian, w.num)
 	// header length, record length
 	binary.Write(ws, binary.LittleEndian, []int16{w.dbfHeaderLength, w.dbfRecordLength})
-	// padding
-	binary.Write(ws, binary.LittleEndian, make([]byte, 20))
+	// padding with the language driver ID at offset 29
+	reserved := make([]byte, 20)
+	reserved[17] = ldidFor(w.charset)
+	binary.Write(ws, binary.LittleEndian, reserved)
 
 	for _, field := range w.dbfFields {
 		binary.Write(ws, binary.LittleEndian, field)
@@ -269,7 +576,7 @@ func (w *Writer) SetFields(fields []Field) error {
 	}
 
 	var err error
-	w.dbf, err = os.Create(w.filename + ".dbf")
+	w.dbf, err = w.createFile(".dbf")
 	if err != nil {
 		return fmt.Errorf("Failed to open %s.dbf: %v", w.filename, err)
 	}
@@ -297,12 +604,11 @@ func (w *Writer) SetFields(fields []Field) error {
 
 // Writes an empty record to the end of the DBF. This
 // works by seeking to the end of the file and writing
-// dbfRecordLength number of bytes. The first byte is a
-// space that indicates a new record.
+// dbfRecordLength number of spaces. The first space
+// indicates a new record, the others are blank values.
 func (w *Writer) writeEmptyRecord() {
 	w.dbf.Seek(0, io.SeekEnd)
-	buf := make([]byte, w.dbfRecordLength)
-	buf[0] = ' '
+	buf := bytes.Repeat([]byte{' '}, int(w.dbfRecordLength))
 	binary.Write(w.dbf, binary.LittleEndian, buf)
 }
 
@@ -310,16 +616,53 @@ func (w *Writer) writeEmptyRecord() {
 // number should be the same as the order the Shape was written to the
 // Shapefile. The field value corresponds to the field in the slice used in
 // SetFields.
+//
+// Values of type time.Time can only be written to fields created with
+// DateField. The date is the calendar date of the value in its own location,
+// so a time in a location other than UTC is not converted to UTC first, which
+// could move it to the previous or next day. The zero time is written as a
+// NULL date, and other values must be in the years 1 to 9999. Strings written
+// to date fields must be blank or a valid date in the form YYYYMMDD. A nil
+// value is written as NULL, see WriteAttributeNull.
+//
+// Values of type bool can only be written to fields created with
+// LogicalField, which only accept a single T, F, Y, N or ?.
 func (w *Writer) WriteAttribute(row int, field int, value interface{}) error {
 	var buf []byte
 	switch v := value.(type) {
+	case time.Time:
+		if !v.IsZero() {
+			y, m, d := v.Date()
+			return w.WriteAttributeDate(row, field, y, int(m), d)
+		}
+		if err := w.checkDateField(field); err != nil {
+			return err
+		}
+		buf = []byte(nullDate)
+	case nil:
+		return w.WriteAttributeNull(row, field)
 	case int:
 		buf = []byte(strconv.Itoa(v))
 	case float64:
 		precision := w.dbfFields[field].Precision
 		buf = []byte(strconv.FormatFloat(v, 'f', int(precision), 64))
+	case float32:
+		precision := w.dbfFields[field].Precision
+		buf = []byte(strconv.FormatFloat(float64(v), 'f', int(precision), 32))
+	case bool:
+		buf = []byte("F")
+		if v {
+			buf = []byte("T")
+		}
 	case string:
 		buf = []byte(v)
+		if w.charset != nil && w.charset != unicode.UTF8 {
+			b, err := w.charset.NewEncoder().Bytes(buf)
+			if err != nil {
+				return fmt.Errorf("Unable to write field %v: %q cannot be encoded: %v", field, v, err)
+			}
+			buf = b
+		}
 	default:
 		return fmt.Errorf("Unsupported value type: %T", v)
 	}
@@ -327,18 +670,96 @@ func (w *Writer) WriteAttribute(row int, field int, value interface{}) error {
 	if w.dbf == nil {
 		return errors.New("Initialize DBF by using SetFields first")
 	}
+	switch ft := w.dbfFields[field].Fieldtype; {
+	case ft == 'D':
+		if _, ok := value.(string); ok {
+			if err := checkDateValue(string(buf)); err != nil {
+				return fmt.Errorf("Unable to write field %v: %v", field, err)
+			}
+		}
+	case ft == 'L':
+		if len(buf) > 1 || (len(buf) == 1 && !strings.ContainsRune("TtFfYyNn? ", rune(buf[0]))) {
+			return fmt.Errorf("Unable to write field %v: %q is not a logical value", field, buf)
+		}
+	case value == true || value == false:
+		return fmt.Errorf("Unable to write field %v: booleans can only be written to logical fields", field)
+	}
 	if sz := int(w.dbfFields[field].Size); len(buf) > sz {
 		return fmt.Errorf("Unable to write field %v: %q exceeds field length %v", field, buf, sz)
 	}
 
-	seekTo := 1 + int64(w.dbfHeaderLength) + (int64(row) * int64(w.dbfRecordLength))
-	for n := 0; n < field; n++ {
-		seekTo += int64(w.dbfFields[n].Size)
+	seekTo := int64(w.dbfHeaderLength) + (int64(row) * int64(w.dbfRecordLength)) + int64(fieldOffsets(w.dbfFields)[field])
+	w.dbf.Seek(seekTo, io.SeekStart)
+	return binary.Write(w.dbf, binary.LittleEndian, buf)
+}
+
+// WriteAttributeNull writes NULL into the given field of the given row in the
+// DBF, which is the whole field filled with blanks, or '?' for logical fields.
+// Writing nil with WriteAttribute does the same. Unlike writing a zero value,
+// this lets readers tell a missing value apart from 0 or false.
+func (w *Writer) WriteAttributeNull(row int, field int) error {
+	if w.dbf == nil {
+		return errors.New("Initialize DBF by using SetFields first")
+	}
+	if field < 0 || field >= len(w.dbfFields) {
+		return fmt.Errorf("Unable to write field %v: out of range [0, %d)", field, len(w.dbfFields))
 	}
+	f := w.dbfFields[field]
+	buf := bytes.Repeat([]byte{' '}, int(f.Size))
+	if f.Fieldtype == 'L' && len(buf) > 0 {
+		buf[0] = '?'
+	}
+	seekTo := int64(w.dbfHeaderLength) + (int64(row) * int64(w.dbfRecordLength)) + int64(fieldOffsets(w.dbfFields)[field])
 	w.dbf.Seek(seekTo, io.SeekStart)
 	return binary.Write(w.dbf, binary.LittleEndian, buf)
 }
 
+// nullDate is the value of a date field that holds no date.
+const nullDate = "        "
+
+// checkDateValue returns an error if v, which is written to a date field, is
+// neither blank nor a valid date in the form YYYYMMDD.
+func checkDateValue(v string) error {
+	if strings.TrimSpace(v) == "" {
+		return nil
+	}
+	if _, err := time.Parse(dbfDateLayout, v); err != nil {
+		return fmt.Errorf("%q is not a date in the form YYYYMMDD", v)
+	}
+	return nil
+}
+
+// WriteAttributeDate writes the calendar date y-m-d into the date field of the
+// given row in the DBF. The date must be valid and in the years 1 to 9999.
+func (w *Writer) WriteAttributeDate(row int, field int, y, m, d int) error {
+	if err := w.checkDateField(field); err != nil {
+		return err
+	}
+	if y < 1 || y > 9999 {
+		return fmt.Errorf("Unable to write field %v: year %d is out of range 1 to 9999", field, y)
+	}
+	t := time.Date(y, time.Month(m), d, 0, 0, 0, 0, time.UTC)
+	if t.Month() != time.Month(m) || t.Day() != d {
+		return fmt.Errorf("Unable to write field %v: invalid date %04d-%02d-%02d", field, y, m, d)
+	}
+	return w.WriteAttribute(row, field, fmt.Sprintf("%04d%02d%02d", y, m, d))
+}
+
+func (w *Writer) checkDateField(field int) error {
+	if w.dbf == nil {
+		return errors.New("Initialize DBF by using SetFields first")
+	}
+	if w.dbfFields[field].Fieldtype != 'D' {
+		return fmt.Errorf("Unable to write field %v: not a date field", field)
+	}
+	return nil
+}
+
+// Err returns the last error that was encountered while writing shapes.
+func (w *Writer) Err() error {
+	return w.err
+}
+
 // BBox returns the bounding box of the Writer.
 func (w *Writer) BBox() Box {
 	return w.bbox
@@ -2,10 +2,15 @@ package shp
 
 import (
 	"bytes"
+	"errors"
 	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"reflect"
+	"strconv"
 	"testing"
+	"time"
 )
 
 var filenamePrefix = "test_files/write_"
@@ -207,3 +212,454 @@ func TestWriteAttribute(t *testing.T) {
 		})
 	}
 }
+
+func TestCreateCleansUpOnError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	base := filepath.Join(dir, "broken")
+	// a directory in place of the SHX makes its creation fail
+	if err := os.Mkdir(base+".shx", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Create(base+".shp", POINT); err == nil {
+		t.Fatal("expected an error")
+	}
+	if _, err := os.Stat(base + ".shp"); !os.IsNotExist(err) {
+		t.Errorf("expected SHP to be removed, got %v", err)
+	}
+}
+
+func TestAbort(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	base := filepath.Join(dir, "aborted")
+	w, err := Create(base+".shp", POINT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.SetFields([]Field{StringField("NAME", 10)}); err != nil {
+		t.Fatal(err)
+	}
+	w.Write(&Point{1, 2})
+	w.WriteAttribute(0, 0, "a")
+	if err := w.Abort(); err != nil {
+		t.Fatal(err)
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 0 {
+		t.Errorf("expected no files after Abort, got %d", len(files))
+	}
+}
+
+func TestAppendAttributes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// the DBF ends with an end-of-file marker
+	name := copyShapefile(t, "test_files/point", dir)
+
+	w, err := Append(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := w.Write(&Point{30, 30})
+	if err := w.WriteAttribute(int(n), 0, 42); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	r, err := Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if got := r.AttributeCount(); got != 4 {
+		t.Fatalf("got %d rows, want 4", got)
+	}
+	if got := r.ReadAttribute(3, 0); got != "42" {
+		t.Errorf("got attribute %q, want 42", got)
+	}
+	if bbox := r.BBox(); bbox.MinX != 0 || bbox.MaxX != 30 {
+		t.Errorf("got extent %v", bbox)
+	}
+	if len(r.RecordErrors()) != 0 {
+		t.Errorf("got record errors %v", r.RecordErrors())
+	}
+}
+
+func TestAppendInvalid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := copyShapefile(t, "test_files/point", dir)
+	// the index has one record less than the DBF
+	shx := name[:len(name)-4] + ".shx"
+	b, err := ioutil.ReadFile(shx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(shx, b[:len(b)-8], 0644); err != nil {
+		t.Fatal(err)
+	}
+	fds := openFiles()
+	if _, err := Append(name); err == nil {
+		t.Error("expected an error for a DBF with more rows than shapes")
+	}
+	if n := openFiles(); n != fds {
+		t.Errorf("%d files were left open by Append", n-fds)
+	}
+
+	ioutil.WriteFile(name, bytes.Repeat([]byte{1}, 100), 0644)
+	if _, err := Append(name); err == nil {
+		t.Error("expected an error for a file that is not a shapefile")
+	}
+}
+
+// openFiles returns the number of files the process has open, or 0 where this
+// cannot be told.
+func openFiles() int {
+	fds, err := ioutil.ReadDir("/proc/self/fd")
+	if err != nil {
+		return 0
+	}
+	return len(fds)
+}
+
+func TestAbortAppendKeepsFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := copyShapefile(t, "test_files/point", dir)
+	w, err := Append(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(&Point{1, 2})
+	if err := w.Abort(); err != nil {
+		t.Fatal(err)
+	}
+	for _, ext := range []string{".shp", ".shx", ".dbf"} {
+		if _, err := os.Stat(name[:len(name)-4] + ext); err != nil {
+			t.Errorf("expected %s to be kept: %v", ext, err)
+		}
+	}
+}
+
+func TestWriteAttributeDate(t *testing.T) {
+	buf := new(bytes.Buffer)
+	s := &seekTracker{Writer: buf}
+	w := Writer{
+		dbf: s,
+		dbfFields: []Field{
+			DateField("A_DATE"),
+			StringField("A_STRING", 8),
+		},
+		dbfRecordLength: 100,
+	}
+	tokyo := time.FixedZone("UTC+9", 9*60*60)
+	honolulu := time.FixedZone("UTC-10", -10*60*60)
+
+	tests := []struct {
+		name     string
+		field    int
+		data     interface{}
+		wantData string
+	}{
+		{"utc", 0, time.Date(2019, 3, 14, 12, 0, 0, 0, time.UTC), "20190314"},
+		// both are 2019-03-14 in UTC, but the calendar date in their own
+		// location is written
+		{"after-midnight-east", 0, time.Date(2019, 3, 15, 0, 30, 0, 0, tokyo), "20190315"},
+		{"before-midnight-west", 0, time.Date(2019, 3, 13, 23, 30, 0, 0, honolulu), "20190313"},
+		{"last-instant-of-day", 0, time.Date(2019, 12, 31, 23, 59, 59, 999999999, time.UTC), "20191231"},
+		{"year-1", 0, time.Date(1, 1, 1, 0, 0, 0, 1, time.UTC), "00010101"},
+		{"zero", 0, time.Time{}, "        "},
+		{"year-10000", 0, time.Date(10000, 1, 1, 0, 0, 0, 0, time.UTC), ""},
+		{"year-0", 0, time.Date(0, 12, 31, 0, 0, 0, 0, time.UTC), ""},
+		{"not-a-date-field", 1, time.Date(2019, 3, 14, 0, 0, 0, 0, time.UTC), ""},
+		{"string", 0, "20190314", "20190314"},
+		{"string-with-dashes", 0, "2019-03-14", ""},
+		{"string-invalid-day", 0, "20190230", ""},
+		{"string-not-a-date-field", 1, "2019-3-1", "2019-3-1"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			buf.Reset()
+			err := w.WriteAttribute(0, test.field, test.data)
+			if buf.String() != test.wantData {
+				t.Errorf("got data: %q, want: %q", buf.String(), test.wantData)
+			}
+			if (err != nil) != (test.wantData == "") {
+				t.Errorf("got error %v", err)
+			}
+		})
+	}
+
+	for _, d := range [][3]int{{2020, 2, 29}, {2000, 2, 29}, {9999, 12, 31}} {
+		buf.Reset()
+		if err := w.WriteAttributeDate(0, 0, d[0], d[1], d[2]); err != nil {
+			t.Errorf("%v: %v", d, err)
+		}
+	}
+	for _, d := range [][3]int{{2019, 2, 29}, {1900, 2, 29}, {2019, 13, 1}, {2019, 4, 31}, {2019, 1, 0}, {0, 1, 1}, {10000, 1, 1}} {
+		buf.Reset()
+		if err := w.WriteAttributeDate(0, 0, d[0], d[1], d[2]); err == nil {
+			t.Errorf("%v: expected an error", d)
+		}
+		if buf.Len() != 0 {
+			t.Errorf("%v: wrote %q", d, buf.String())
+		}
+	}
+}
+
+func TestSetProjection(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for code, want := range map[int]string{4326: wgs84WKT, 32632: utm32WKT} {
+		filename := filepath.Join(dir, "epsg"+strconv.Itoa(code)+".shp")
+		w, err := Create(filename, POINT)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := w.SetProjectionEPSG(code); err != nil {
+			t.Fatal(err)
+		}
+		w.Write(&Point{1, 2})
+		w.Close()
+		r, err := Open(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := r.Projection(); got != want {
+			t.Errorf("EPSG %d: got %s, want %s", code, got, want)
+		}
+		r.Close()
+	}
+
+	w, err := Create(filepath.Join(dir, "bad.shp"), POINT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if err := w.SetProjectionEPSG(1234); err == nil {
+		t.Error("expected an error for an unknown EPSG code")
+	}
+	if err := w.SetProjection("not wkt"); err == nil {
+		t.Error("expected an error for invalid WKT")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "bad.prj")); !os.IsNotExist(err) {
+		t.Error("expected no .prj file for invalid projections")
+	}
+}
+
+func TestWriteNull(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "null.shp")
+	w, err := Create(filename, POLYLINE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := NewPolyLine([][]Point{{{1, 1}, {2, 2}}})
+	w.Write(&Null{})
+	w.Write(line)
+	w.Write(&Null{})
+	w.Write(line)
+	w.Close()
+
+	typ, shapes := readAll(t, filename)
+	want := []Shape{&Null{}, line, &Null{}, line}
+	if typ != POLYLINE || !reflect.DeepEqual(shapes, want) {
+		t.Errorf("got %v %v, want %v", typ, shapes, want)
+	}
+
+	// Null shapes do not extend the extent to the origin
+	r, err := Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if box := r.BBox(); box != line.Box {
+		t.Errorf("got extent %v, want %v", box, line.Box)
+	}
+
+	sr, err := OpenDataset(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sr.Close()
+	pr, err := OpenParallel(filename, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pr.Close()
+	for _, test := range []struct {
+		name string
+		sr   SequentialReader
+	}{{"seqReader", sr}, {"ParallelReader", pr}} {
+		var got []Shape
+		for test.sr.Next() {
+			_, s := test.sr.Shape()
+			got = append(got, s)
+		}
+		if err := test.sr.Err(); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, want)
+		}
+	}
+
+	ir, err := OpenIndexed(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ir.Close()
+	if s, err := ir.ShapeAt(2); err != nil || !reflect.DeepEqual(s, &Null{}) {
+		t.Errorf("IndexedReader: got %v, %v, want a Null shape", s, err)
+	}
+}
+
+func TestWriteAttributeLogicalAndFloat(t *testing.T) {
+	buf := new(bytes.Buffer)
+	s := &seekTracker{Writer: buf}
+	w := Writer{
+		dbf: s,
+		dbfFields: []Field{
+			LogicalField("A_BOOL"),
+			FloatField("A_FLOAT", 10, 3),
+			NumberField("A_NUMBER", 5),
+		},
+		dbfRecordLength: 100,
+	}
+	tests := []struct {
+		name     string
+		field    int
+		data     interface{}
+		wantData string
+	}{
+		{"true", 0, true, "T"},
+		{"false", 0, false, "F"},
+		{"string", 0, "Y", "Y"},
+		{"unknown", 0, "?", "?"},
+		{"invalid", 0, "yes", ""},
+		{"float64", 1, 3.14159, "3.142"},
+		{"float32", 1, float32(2.5), "2.500"},
+		{"bool-into-number", 2, true, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			buf.Reset()
+			err := w.WriteAttribute(0, test.field, test.data)
+			if buf.String() != test.wantData {
+				t.Errorf("got data: %q, want: %q", buf.String(), test.wantData)
+			}
+			if (err != nil) != (test.wantData == "") {
+				t.Errorf("got error %v", err)
+			}
+		})
+	}
+}
+
+func TestWriteFileTooLarge(t *testing.T) {
+	defer func(size int64) { maxFileSize = size }(maxFileSize)
+	// room for the header and three points of 28 bytes each
+	maxFileSize = 100 + 3*28
+
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "points.shp")
+	w, err := Create(filename, POINT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if w.Write(&Point{float64(i), 0}) < 0 {
+			t.Fatal(w.Err())
+		}
+	}
+	if n := w.Write(&Point{3, 0}); n != -1 || !errors.Is(w.Err(), ErrFileTooLarge) {
+		t.Errorf("got %d and error %v, want -1 and ErrFileTooLarge", n, w.Err())
+	}
+	w.Close()
+	if _, shapes := readAll(t, filename); len(shapes) != 3 {
+		t.Errorf("got %d shapes, want 3", len(shapes))
+	}
+}
+
+func TestWriteAutoSplit(t *testing.T) {
+	defer func(size int64) { maxFileSize = size }(maxFileSize)
+	maxFileSize = 100 + 3*28
+
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	base := filepath.Join(dir, "points")
+	w, err := Create(base+".shp", POINT, WithAutoSplit())
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SetFields([]Field{NumberField("ID", 4)})
+	if err := w.SetProjectionEPSG(4326); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 7; i++ {
+		row := w.Write(&Point{float64(i), 0})
+		if row != int32(i%3) {
+			t.Fatalf("point %d: got row %d: %v", i, row, w.Err())
+		}
+		w.WriteAttribute(int(row), 0, i)
+	}
+	w.Close()
+
+	want := []string{base + ".shp", base + "_2.shp", base + "_3.shp"}
+	if !reflect.DeepEqual(w.Parts(), want) {
+		t.Fatalf("got parts %v, want %v", w.Parts(), want)
+	}
+	id := 0
+	for _, part := range want {
+		r, err := Open(part)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for r.Next() {
+			_, s := r.Shape()
+			if p := s.(*Point); p.X != float64(id) || r.Attribute(0) != strconv.Itoa(id) {
+				t.Errorf("%s: got %v with ID %s, want %d", part, p, r.Attribute(0), id)
+			}
+			id++
+		}
+		if r.Projection() == "" {
+			t.Errorf("%s: missing projection", part)
+		}
+		r.Close()
+	}
+	if id != 7 {
+		t.Errorf("got %d points, want 7", id)
+	}
+}
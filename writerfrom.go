@@ -0,0 +1,118 @@
+package shp
+
+import (
+	"fmt"
+	"io"
+)
+
+// NewWriterFrom returns a Writer that writes a shapefile of type t to shp, shx
+// and dbf instead of files, e.g. to files of a virtual file system or buffers
+// that support seeking. dbf may be nil if the shapefile has no attributes, in
+// which case SetFields fails. The targets are not closed by Close. The .prj
+// and .cpg written by SetProjection and SetCharset are kept in memory, see
+// Sidecar.
+func NewWriterFrom(shp, shx, dbf io.WriteSeeker, t ShapeType, opts ...Option) (*Writer, error) {
+	targets := map[string]io.WriteSeeker{".shp": shp, ".shx": shx}
+	if dbf != nil {
+		targets[".dbf"] = dbf
+	}
+	sidecars := make(map[string]*memFile)
+	w, err := newWriter("", t, func(ext string) (writeSeekCloser, error) {
+		if f := sidecar(sidecars, ext); f != nil {
+			return f, nil
+		}
+		if targets[ext] == nil {
+			return nil, fmt.Errorf("Unable to create %s: the writer has no target for it", ext)
+		}
+		return nopCloser{targets[ext]}, nil
+	}, opts)
+	if err != nil {
+		return nil, err
+	}
+	w.sidecars = sidecars
+	return w, nil
+}
+
+// StreamWriter writes a shapefile to writers that cannot seek, such as HTTP
+// responses, uploads or pipes. Since the headers at the start of the files
+// depend on all records, it embeds a Writer whose files are kept in memory
+// until Close writes them out in one go.
+type StreamWriter struct {
+	*Writer
+
+	shp, shx, dbf io.Writer
+	files         map[string]*memFile
+}
+
+// NewStreamWriter returns a StreamWriter that writes a shapefile of type t to
+// shp, shx and dbf when it is closed. dbf may be nil if the shapefile has no
+// attributes. The .prj and .cpg are available through Sidecar. Close does not
+// close the writers.
+func NewStreamWriter(shp, shx, dbf io.Writer, t ShapeType, opts ...Option) (*StreamWriter, error) {
+	sw := &StreamWriter{shp: shp, shx: shx, dbf: dbf, files: make(map[string]*memFile)}
+	sidecars := make(map[string]*memFile)
+	w, err := newWriter("", t, func(ext string) (writeSeekCloser, error) {
+		if f := sidecar(sidecars, ext); f != nil {
+			return f, nil
+		}
+		if ext == ".dbf" && dbf == nil {
+			return nil, fmt.Errorf("Unable to create %s: the writer has no target for it", ext)
+		}
+		f := &memFile{}
+		sw.files[ext] = f
+		return f, nil
+	}, opts)
+	if err != nil {
+		return nil, err
+	}
+	w.sidecars = sidecars
+	sw.Writer = w
+	return sw, nil
+}
+
+// Close writes the headers of the shapefile and then the .shp, .shx and .dbf
+// to their writers. It returns the first error of the writers.
+func (sw *StreamWriter) Close() error {
+	sw.Writer.Close()
+	for _, t := range []struct {
+		ext string
+		w   io.Writer
+	}{{".shp", sw.shp}, {".shx", sw.shx}, {".dbf", sw.dbf}} {
+		if f := sw.files[t.ext]; f != nil && t.w != nil {
+			if _, err := t.w.Write(f.buf); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Sidecar returns the content of the .prj or .cpg file, given by ext, that a
+// Writer created by NewWriterFrom or NewStreamWriter keeps in memory since it
+// has no target for it, or nil if it was not written.
+func (w *Writer) Sidecar(ext string) []byte {
+	if f := w.sidecars[ext]; f != nil {
+		return f.buf
+	}
+	return nil
+}
+
+// sidecar adds a new file in memory to sidecars and returns it if ext is the
+// extension of the .prj or .cpg, or returns nil otherwise.
+func sidecar(sidecars map[string]*memFile, ext string) *memFile {
+	if ext != ".prj" && ext != ".cpg" {
+		return nil
+	}
+	f := &memFile{}
+	sidecars[ext] = f
+	return f
+}
+
+// nopCloser is a writeSeekCloser whose Close does nothing.
+type nopCloser struct {
+	io.WriteSeeker
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
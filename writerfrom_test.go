@@ -0,0 +1,124 @@
+package shp
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewWriterFrom(t *testing.T) {
+	dir, err := ioutil.TempDir("", "shp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	base := filepath.Join(dir, "from")
+
+	var files []*os.File
+	for _, ext := range []string{".shp", ".shx", ".dbf"} {
+		f, err := os.Create(base + ext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		files = append(files, f)
+	}
+	w, err := NewWriterFrom(files[0], files[1], files[2], POINT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.SetFields([]Field{StringField("NAME", 10)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.SetProjection("GEOGCS[\"WGS 84\"]"); err != nil {
+		t.Fatal(err)
+	}
+	w.Write(&Point{1, 2})
+	w.WriteAttribute(0, 0, "one")
+	w.Close()
+	if got := string(w.Sidecar(".prj")); got != "GEOGCS[\"WGS 84\"]" {
+		t.Errorf("Sidecar(.prj) = %q", got)
+	}
+
+	r, err := Open(base + ".shp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if !r.Next() {
+		t.Fatal("no record")
+	}
+	_, s := r.Shape()
+	if p := s.(*Point); p.X != 1 || p.Y != 2 {
+		t.Errorf("shape = %v", p)
+	}
+	if got := r.ReadAttribute(0, 0); got != "one" {
+		t.Errorf("attribute = %q", got)
+	}
+}
+
+func TestStreamWriter(t *testing.T) {
+	var shp, shx, dbf bytes.Buffer
+	w, err := NewStreamWriter(&shp, &shx, &dbf, POINT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SetFields([]Field{NumberField("ID", 5)})
+	for i := 0; i < 3; i++ {
+		w.Write(&Point{float64(i), float64(i)})
+		w.WriteAttribute(i, 0, i)
+	}
+	if shp.Len() != 0 {
+		t.Errorf("wrote %d bytes before Close", shp.Len())
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "shp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	base := filepath.Join(dir, "stream")
+	for ext, b := range map[string][]byte{".shp": shp.Bytes(), ".shx": shx.Bytes(), ".dbf": dbf.Bytes()} {
+		if err := ioutil.WriteFile(base+ext, b, 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r, err := Open(base + ".shp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	n := 0
+	for r.Next() {
+		if got := r.ReadAttribute(n, 0); got != string(rune('0'+n)) {
+			t.Errorf("attribute %d = %q", n, got)
+		}
+		n++
+	}
+	if n != 3 {
+		t.Errorf("read %d records, want 3", n)
+	}
+}
+
+func TestStreamWriterWithoutDBF(t *testing.T) {
+	var shp, shx bytes.Buffer
+	w, err := NewStreamWriter(&shp, &shx, nil, POINT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.SetFields([]Field{NumberField("ID", 5)}); err == nil {
+		t.Error("SetFields without a DBF target succeeded")
+	}
+	w.Write(&Point{1, 1})
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if shp.Len() != 100+8+20 {
+		t.Errorf("len(shp) = %d", shp.Len())
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"io/ioutil"
 	"path"
 	"strings"
+	"time"
 )
 
 // ZipReader provides an interface for reading Shapefiles that are compressed in a ZIP archive.
@@ -17,75 +18,355 @@ type ZipReader struct {
 
 	// file is only set if OpenZip from file
 	file *zip.ReadCloser
+
+	entries map[string]*ZipEntry
+	// name is the name of the .shp in the archive.
+	name string
+	// exactNames is set by WithExactZipNames.
+	exactNames bool
+	// prj and cpg are the content of the .prj and .cpg files, if there are
+	// any.
+	prj string
+	cpg string
+	// memo reads the .dbt file, if there is one.
+	memo *memoFile
+	// consumed is the number of uncompressed bytes read from the .shp and
+	// .dbf entries.
+	consumed int64
+	// progress is set by WithProgress and SetProgress.
+	progress func(done, total int64)
 }
 
-// openFromZIP is convenience function for opening the file called name that is
-// compressed in z for reading.
-func openFromZIP(z *zip.Reader, name string) (io.ReadCloser, error) {
+// ZipEntry holds the metadata from the ZIP directory for one of the files that
+// make up the shapefile in the archive.
+type ZipEntry struct {
+	Name             string
+	CompressedSize   uint64
+	UncompressedSize uint64
+	CRC32            uint32
+	Modified         time.Time
+}
+
+// zipCompanions are the extensions of the files whose metadata is recorded in
+// ZipReader.Entries.
+var zipCompanions = []string{".shp", ".shx", ".dbf", ".prj", ".cpg", ".qix", ".sbn", ".sbx"}
+
+// findInZIP returns the file called name in z or nil if there is no such file.
+// Unless exact is set, a file whose name only differs in case, path
+// separators or a leading ./ is found if there is none with the exact name.
+func findInZIP(z *zip.Reader, name string, exact bool) *zip.File {
 	for _, f := range z.File {
 		if f.Name == name {
-			return f.Open()
+			return f
 		}
 	}
-	return nil, fmt.Errorf("No such file in archive: %s", name)
+	if exact {
+		return nil
+	}
+	want := zipPath(name)
+	for _, f := range z.File {
+		if strings.EqualFold(zipPath(f.Name), want) {
+			return f
+		}
+	}
+	return nil
+}
+
+// zipPath returns the name of an entry of a ZIP archive with forward
+// slashes, which some archivers on Windows replace by backslashes, and
+// without leading ./ or / and redundant elements.
+func zipPath(name string) string {
+	p := path.Clean(strings.Replace(name, "\\", "/", -1))
+	return strings.TrimLeft(p, "/")
+}
+
+// findCompanionInZIP returns the file in z whose name is prefix followed by
+// ext, ignoring case and the kind of path separators, or nil if there is no
+// such file.
+func findCompanionInZIP(z *zip.Reader, prefix, ext string) *zip.File {
+	want := zipPath(prefix) + ext
+	for _, f := range z.File {
+		if strings.EqualFold(zipPath(f.Name), want) {
+			return f
+		}
+	}
+	return nil
+}
+
+// companion returns the file of the shapefile in the archive with the
+// extension ext, or nil if there is none. Companions are paired regardless of
+// case, path separators and leading ./, unless the reader was opened
+// WithExactZipNames, in which case the name must be the one of the .shp with
+// its extension replaced by ext in the case of the extension of the .shp.
+func (zr *ZipReader) companion(ext string) *zip.File {
+	shpExt := path.Ext(zr.name)
+	if zr.exactNames {
+		if shpExt == strings.ToUpper(shpExt) {
+			ext = strings.ToUpper(ext)
+		}
+		return findInZIP(zr.z, strings.TrimSuffix(zr.name, shpExt)+ext, true)
+	}
+	return findCompanionInZIP(zr.z, strings.TrimSuffix(zipPath(zr.name), shpExt), ext)
+}
+
+// openFromZIP is convenience function for opening the file called name that is
+// compressed in z for reading.
+func openFromZIP(z *zip.Reader, name string) (io.ReadCloser, error) {
+	if f := findInZIP(z, name, false); f != nil {
+		return f.Open()
+	}
+	return nil, fmt.Errorf("%w: %s", ErrFileNotInArchive, name)
 }
 
 // OpenZip opens a ZIP file that contains a single shapefile.
-func OpenZip(zipFilePath string) (*ZipReader, error) {
+func OpenZip(zipFilePath string, opts ...Option) (*ZipReader, error) {
 	z, err := zip.OpenReader(zipFilePath)
 	if err != nil {
+		if serr := sniffFile(zipFilePath); serr != nil {
+			return nil, serr
+		}
 		return nil, err
 	}
 	zr := &ZipReader{
 		z:    &z.Reader,
 		file: z,
 	}
-	if err := zr.loadSHPAndMaybeDBF(); err != nil {
+	if err := zr.loadSHPAndMaybeDBF(opts); err != nil {
+		z.Close()
 		return nil, err
 	}
 	return zr, nil
 }
 
-// OpenZipReader opens a ZIP file that contains a single shapefile from a stream
-func OpenZipReader(zipFileStream io.Reader) (*ZipReader, error) {
+// OpenZipReader opens a ZIP file that contains a single shapefile from a
+// stream. Since the ZIP directory is at the end of the archive, the whole
+// stream is read into memory, unless it is also an io.ReaderAt and io.Seeker
+// such as an *os.File, which is read directly instead. See OpenZipReaderAt for
+// large archives.
+func OpenZipReader(zipFileStream io.Reader, opts ...Option) (*ZipReader, error) {
+	if ra, ok := zipFileStream.(interface {
+		io.ReaderAt
+		io.Seeker
+	}); ok {
+		// the archive starts at the current position of the stream
+		start, err := ra.Seek(0, io.SeekCurrent)
+		if err == nil {
+			var end int64
+			if end, err = ra.Seek(0, io.SeekEnd); err == nil {
+				return OpenZipReaderAt(io.NewSectionReader(ra, start, end-start), end-start, opts...)
+			}
+		}
+	}
 	byteData, err := ioutil.ReadAll(zipFileStream)
 	if err != nil {
 		return nil, err
 	}
 	buf := bytes.NewReader(byteData)
-	z, err := zip.NewReader(buf, int64(buf.Len()))
+	return OpenZipReaderAt(buf, int64(buf.Len()), opts...)
+}
+
+// OpenZipReaderAt opens a ZIP file of the given size that contains a single
+// shapefile from r, e.g. an object in remote storage that supports range
+// requests. Only the ZIP directory is read up front, the shapefile is
+// decompressed incrementally as it is read, so the archive is never held in
+// memory as a whole.
+func OpenZipReaderAt(r io.ReaderAt, size int64, opts ...Option) (*ZipReader, error) {
+	z, err := zip.NewReader(r, size)
 	if err != nil {
+		if serr := sniffReaderAt(r, size); serr != nil {
+			return nil, serr
+		}
 		return nil, err
 	}
 	zr := &ZipReader{
 		z: z,
 	}
-	if err := zr.loadSHPAndMaybeDBF(); err != nil {
+	if err := zr.loadSHPAndMaybeDBF(opts); err != nil {
 		return nil, err
 	}
 	return zr, nil
 }
 
-func (zr *ZipReader) loadSHPAndMaybeDBF() error {
+func (zr *ZipReader) loadSHPAndMaybeDBF(opts []Option) error {
 	shapeFiles := shapesInZip(zr.z)
 	if len(shapeFiles) == 0 {
-		return fmt.Errorf("archive does not contain a .shp file")
+		if format := sniffZip(zr.z); format != "" {
+			return &ErrUnsupportedFormat{Format: format}
+		}
+		return ErrNoSHPInArchive
 	}
 	if len(shapeFiles) > 1 {
-		return fmt.Errorf("archive does contain multiple .shp files")
+		return fmt.Errorf("archive contains %w", ErrMultipleSHP)
 	}
 
-	shp, err := openFromZIP(zr.z, shapeFiles[0].Name)
+	return zr.openShape(shapeFiles[0].Name, opts)
+}
+
+// openShape opens the shapefile called name and its DBF from the archive and
+// records the metadata of all companion files.
+func (zr *ZipReader) openShape(name string, opts []Option) error {
+	o := newOptions(opts)
+	zr.exactNames = o.exactZipNames
+	f := findInZIP(zr.z, name, zr.exactNames)
+	if f == nil {
+		return fmt.Errorf("%w: %s", ErrFileNotInArchive, name)
+	}
+	shp, err := f.Open()
 	if err != nil {
 		return err
 	}
-	withoutExt := strings.TrimSuffix(shapeFiles[0].Name, ".shp")
+	zr.name = f.Name
 	// dbf is optional, so no error checking here
-	dbf, _ := openFromZIP(zr.z, withoutExt+".dbf")
-	zr.sr = SequentialReaderFromExt(shp, dbf)
+	var dbf io.ReadCloser
+	if f := zr.companion(".dbf"); f != nil {
+		dbf, _ = f.Open()
+	}
+
+	zr.entries = make(map[string]*ZipEntry, len(zipCompanions))
+	for _, ext := range zipCompanions {
+		f := zr.companion(ext)
+		if ext == ".shp" {
+			f = findInZIP(zr.z, zr.name, true)
+		}
+		if f == nil {
+			zr.entries[ext] = nil
+			continue
+		}
+		zr.entries[ext] = &ZipEntry{
+			Name:             f.Name,
+			CompressedSize:   f.CompressedSize64,
+			UncompressedSize: f.UncompressedSize64,
+			CRC32:            f.CRC32,
+			Modified:         f.Modified,
+		}
+	}
+
+	if f := zr.companion(".prj"); f != nil {
+		if rc, err := f.Open(); err == nil {
+			b, _ := ioutil.ReadAll(rc)
+			rc.Close()
+			zr.prj = string(b)
+		}
+	}
+
+	if f := zr.companion(".cpg"); f != nil {
+		if rc, err := f.Open(); err == nil {
+			b, _ := ioutil.ReadAll(rc)
+			rc.Close()
+			zr.cpg = string(b)
+			opts = append(opts[:len(opts):len(opts)], withCPG(zr.cpg))
+		}
+	}
+
+	if f := zr.companion(".dbt"); f != nil {
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		b, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return fmt.Errorf("Error reading memo file: %v", err)
+		}
+		if zr.memo, err = newMemoFile(bytes.NewReader(b)); err != nil {
+			return err
+		}
+	}
+
+	zr.progress = o.progress
+	shpSize, dbfSize := zr.UncompressedSize()
+	shp = zr.countEntry(shp, zr.entries[".shp"], shpSize+dbfSize, o)
+	if dbf != nil {
+		dbf = zr.countEntry(dbf, zr.entries[".dbf"], shpSize+dbfSize, o)
+	}
+	zr.sr = SequentialReaderFromExt(shp, dbf, opts...)
 	return nil
 }
 
+// zipEntryReader counts the uncompressed bytes read from an entry of a ZIP
+// archive.
+type zipEntryReader struct {
+	io.ReadCloser
+	zr       *ZipReader
+	name     string
+	n, limit int64
+	total    int64
+}
+
+func (r *zipEntryReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n += int64(n)
+	r.zr.consumed += int64(n)
+	if r.limit >= 0 && r.n > r.limit {
+		return n, fmt.Errorf("%s yields more than %d bytes, the limit for its declared size", r.name, r.limit)
+	}
+	if r.zr.progress != nil && n > 0 {
+		r.zr.progress(r.zr.consumed, r.total)
+	}
+	return n, err
+}
+
+// countEntry wraps the reader of the entry e so that the bytes read from it
+// are counted, reported and limited as configured by o.
+func (zr *ZipReader) countEntry(rc io.ReadCloser, e *ZipEntry, total int64, o options) io.ReadCloser {
+	r := &zipEntryReader{ReadCloser: rc, zr: zr, name: e.Name, limit: -1, total: total}
+	if o.sizeFactor > 0 {
+		r.limit = int64(float64(e.UncompressedSize) * o.sizeFactor)
+	}
+	return r
+}
+
+// UncompressedSize returns the uncompressed sizes of the .shp and .dbf files
+// as declared in the ZIP directory. The size of a missing DBF is 0.
+func (zr *ZipReader) UncompressedSize() (shp, dbf int64) {
+	if e := zr.entries[".shp"]; e != nil {
+		shp = int64(e.UncompressedSize)
+	}
+	if e := zr.entries[".dbf"]; e != nil {
+		dbf = int64(e.UncompressedSize)
+	}
+	return shp, dbf
+}
+
+// Entries returns the ZIP directory metadata of the files that make up the
+// shapefile, keyed by their lower-case extension (".shp", ".shx", ".dbf",
+// ".prj", ".cpg" and the spatial indexes ".qix", ".sbn" and ".sbx").
+// Companion files that were not found in the archive are present in the map
+// with a nil value.
+func (zr *ZipReader) Entries() map[string]*ZipEntry {
+	return zr.entries
+}
+
+// Codepage returns the content of the .cpg file in the archive, which names
+// the encoding of the attributes, with surrounding blanks trimmed, or the
+// empty string if there is none. See Charset for the encoding in use.
+func (zr *ZipReader) Codepage() string {
+	return strings.TrimSpace(zr.cpg)
+}
+
+// HasIndex reports whether the archive contains the .shx index of the
+// shapefile. See Entries for the spatial indexes.
+func (zr *ZipReader) HasIndex() bool {
+	return zr.entries[".shx"] != nil
+}
+
+// ReadSidecar returns the content of the file in the archive that belongs to
+// the shapefile and has the extension ext, such as ".shx", ".sbn" or
+// ".shp.xml". The extension is matched regardless of case. It returns an error
+// wrapping ErrFileNotInArchive if there is no such file.
+func (zr *ZipReader) ReadSidecar(ext string) ([]byte, error) {
+	f := zr.companion(ext)
+	if f == nil {
+		return nil, fmt.Errorf("%w: %s%s", ErrFileNotInArchive, strings.TrimSuffix(zr.name, path.Ext(zr.name)), ext)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return ioutil.ReadAll(rc)
+}
+
 // ShapesInZip returns a string-slice with the names (i.e. relatives paths in
 // archive file tree) of all shapes that are in the ZIP archive at zipFilePath.
 func ShapesInZip(zipFilePath string) ([]string, error) {
@@ -94,6 +375,7 @@ func ShapesInZip(zipFilePath string) ([]string, error) {
 	if err != nil {
 		return nil, err
 	}
+	defer z.Close()
 	shapeFiles := shapesInZip(&z.Reader)
 	for i := range shapeFiles {
 		names = append(names, shapeFiles[i].Name)
@@ -104,7 +386,7 @@ func ShapesInZip(zipFilePath string) ([]string, error) {
 func shapesInZip(z *zip.Reader) []*zip.File {
 	var shapeFiles []*zip.File
 	for _, f := range z.File {
-		if strings.HasSuffix(f.Name, ".shp") {
+		if strings.EqualFold(path.Ext(f.Name), ".shp") {
 			shapeFiles = append(shapeFiles, f)
 		}
 	}
@@ -117,9 +399,12 @@ func shapesInZip(z *zip.Reader) []*zip.File {
 // drive letter (e.g. C:) or leading slash, and only forward slashes are
 // allowed. These rules are the same as in
 // https://golang.org/pkg/archive/zip/#FileHeader.
-func OpenShapeFromZip(zipFilePath string, name string) (*ZipReader, error) {
+func OpenShapeFromZip(zipFilePath string, name string, opts ...Option) (*ZipReader, error) {
 	z, err := zip.OpenReader(zipFilePath)
 	if err != nil {
+		if serr := sniffFile(zipFilePath); serr != nil {
+			return nil, serr
+		}
 		return nil, err
 	}
 	zr := &ZipReader{
@@ -127,17 +412,55 @@ func OpenShapeFromZip(zipFilePath string, name string) (*ZipReader, error) {
 		file: z,
 	}
 
-	shp, err := openFromZIP(zr.z, name)
-	if err != nil {
+	if findInZIP(zr.z, name, false) == nil && len(shapesInZip(zr.z)) == 0 {
+		if format := sniffZip(zr.z); format != "" {
+			z.Close()
+			return nil, &ErrUnsupportedFormat{Format: format}
+		}
+	}
+	if err := zr.openShape(name, opts); err != nil {
+		z.Close()
 		return nil, err
 	}
-	// dbf is optional, so no error checking here
-	prefix := strings.TrimSuffix(name, path.Ext(name))
-	dbf, _ := openFromZIP(zr.z, prefix+".dbf")
-	zr.sr = SequentialReaderFromExt(shp, dbf)
 	return zr, nil
 }
 
+// OpenAllShapesFromZip opens every shapefile in the ZIP archive at
+// zipFilePath, e.g. the layers of a bundle, in the order in which they are
+// stored in the archive. Every ZipReader has its own handle on the archive
+// and must be closed separately.
+func OpenAllShapesFromZip(zipFilePath string, opts ...Option) ([]*ZipReader, error) {
+	z, err := zip.OpenReader(zipFilePath)
+	if err != nil {
+		if serr := sniffFile(zipFilePath); serr != nil {
+			return nil, serr
+		}
+		return nil, err
+	}
+	shapeFiles := shapesInZip(&z.Reader)
+	format := sniffZip(&z.Reader)
+	z.Close()
+	if len(shapeFiles) == 0 {
+		if format != "" {
+			return nil, &ErrUnsupportedFormat{Format: format}
+		}
+		return nil, ErrNoSHPInArchive
+	}
+
+	zrs := make([]*ZipReader, 0, len(shapeFiles))
+	for _, f := range shapeFiles {
+		zr, err := OpenShapeFromZip(zipFilePath, f.Name, opts...)
+		if err != nil {
+			for _, zr := range zrs {
+				zr.Close()
+			}
+			return nil, err
+		}
+		zrs = append(zrs, zr)
+	}
+	return zrs, nil
+}
+
 // Close closes the ZipReader and frees the allocated resources.
 func (zr *ZipReader) Close() error {
 	s := ""
@@ -179,7 +502,40 @@ func (zr *ZipReader) Fields() []Field {
 	return zr.sr.Fields()
 }
 
+// Attributes returns all attributes of the current shape.
+func (zr *ZipReader) Attributes() []string {
+	return Attributes(zr.sr)
+}
+
+// AttributesMap returns all attributes of the current shape keyed by the
+// names of their fields.
+func (zr *ZipReader) AttributesMap() map[string]string {
+	return AttributesMap(zr)
+}
+
+// BBox returns the bounding box of the shapefile from its header.
+func (zr *ZipReader) BBox() Box {
+	if b, ok := zr.sr.(interface{ BBox() Box }); ok {
+		return b.BBox()
+	}
+	return Box{}
+}
+
 // Err returns the last non-EOF error that was encountered by this ZipReader.
 func (zr *ZipReader) Err() error {
 	return zr.sr.Err()
 }
+
+// RecordErrors returns the problems with individual records that were
+// encountered so far without stopping the reader.
+func (zr *ZipReader) RecordErrors() []*RecordError {
+	if sr, ok := zr.sr.(*seqReader); ok {
+		return sr.recordErrors
+	}
+	return nil
+}
+
+func (zr *ZipReader) rowBad() bool {
+	sr, ok := zr.sr.(*seqReader)
+	return ok && sr.dbfRowBad
+}
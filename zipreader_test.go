@@ -2,12 +2,14 @@ package shp
 
 import (
 	"archive/zip"
+	"errors"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"os"
 	"path"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -29,6 +31,12 @@ func compressFileToZIP(zw *zip.Writer, src, tgt string, t *testing.T) {
 // createTempZIP packs the SHP, SHX, and DBF into a ZIP in a temporary
 // directory
 func createTempZIP(prefix string, t *testing.T) (dir, filename string) {
+	return createTempZIPWith(prefix, []string{".shp", ".shx", ".dbf"}, t)
+}
+
+// createTempZIPWith packs the files with the given suffixes into a ZIP in a
+// temporary directory
+func createTempZIPWith(prefix string, suffixes []string, t *testing.T) (dir, filename string) {
 	dir, err := ioutil.TempDir("", "go-shp-test")
 	if err != nil {
 		t.Fatalf("Could not create temporary directory: %v", err)
@@ -40,12 +48,15 @@ func createTempZIP(prefix string, t *testing.T) (dir, filename string) {
 		t.Fatalf("Could not create temporary zip file: %v", err)
 	}
 	zw := zip.NewWriter(w)
-	for _, suffix := range []string{".shp", ".shx", ".dbf"} {
+	for _, suffix := range suffixes {
 		compressFileToZIP(zw, prefix+suffix, base+suffix, t)
 	}
 	if err := zw.Close(); err != nil {
 		t.Fatalf("Could not close the written zip: %v", err)
 	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Could not close the written zip: %v", err)
+	}
 	return dir, zipName
 }
 
@@ -77,6 +88,152 @@ func TestZipReader(t *testing.T) {
 	}
 }
 
+// readerAtOnly hides all methods but ReadAt, so that nothing but ranges of
+// the archive can be read.
+type readerAtOnly struct {
+	io.ReaderAt
+}
+
+func getShapesZippedAt(prefix string, t *testing.T) (shapes []Shape) {
+	dir, filename := createTempZIP(prefix, t)
+	defer os.RemoveAll(dir)
+	f, err := os.Open(filepath.Join(dir, filename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	zr, err := OpenZipReaderAt(readerAtOnly{f}, fi.Size())
+	if err != nil {
+		t.Fatalf("Error when opening zip file: %v", err)
+	}
+	for zr.Next() {
+		_, shape := zr.Shape()
+		shapes = append(shapes, shape)
+	}
+	if err := zr.Err(); err != nil {
+		t.Errorf("Error when iterating over the shapes: %v", err)
+	}
+	if err := zr.Close(); err != nil {
+		t.Errorf("Could not close zipreader: %v", err)
+	}
+	return shapes
+}
+
+func TestZipReaderAt(t *testing.T) {
+	for prefix := range dataForReadTests {
+		t.Logf("Testing zipped reading with ReadAt for %s", prefix)
+		testshapeIdentity(t, prefix, getShapesZippedAt)
+	}
+}
+
+func TestZipReaderStreamOffset(t *testing.T) {
+	dir, filename := createTempZIP("test_files/point", t)
+	defer os.RemoveAll(dir)
+	archive, err := ioutil.ReadFile(filepath.Join(dir, filename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the archive follows other data in the stream
+	p := filepath.Join(dir, "prefixed")
+	if err := ioutil.WriteFile(p, append([]byte("other data"), archive...), 0644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	f.Seek(int64(len("other data")), io.SeekStart)
+	zr, err := OpenZipReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+	n := 0
+	for zr.Next() {
+		n++
+	}
+	if zr.Err() != nil || n != 3 {
+		t.Errorf("read %d shapes with error %v, want 3", n, zr.Err())
+	}
+}
+
+func TestZipReaderEntries(t *testing.T) {
+	tests := []struct {
+		name     string
+		suffixes []string
+	}{
+		{"all", []string{".shp", ".shx", ".dbf"}},
+		{"shp-only", []string{".shp"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir, filename := createTempZIPWith("test_files/point", test.suffixes, t)
+			defer os.RemoveAll(dir)
+			p := filepath.Join(dir, filename)
+
+			zr, err := OpenZip(p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer zr.Close()
+			f, err := os.Open(p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			zs, err := OpenZipReader(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer zs.Close()
+
+			for _, entries := range []map[string]*ZipEntry{zr.Entries(), zs.Entries()} {
+				for _, ext := range []string{".shp", ".shx", ".dbf", ".prj"} {
+					e, ok := entries[ext]
+					if !ok {
+						t.Fatalf("no entry for %s", ext)
+					}
+					want := false
+					for _, s := range test.suffixes {
+						want = want || s == ext
+					}
+					if (e != nil) != want {
+						t.Fatalf("entry for %s = %+v, want present: %v", ext, e, want)
+					}
+					if e == nil {
+						continue
+					}
+					fi, err := os.Stat("test_files/point" + ext)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if e.Name != "point"+ext {
+						t.Errorf("got name %q, want %q", e.Name, "point"+ext)
+					}
+					if e.UncompressedSize != uint64(fi.Size()) {
+						t.Errorf("got size %d, want %d", e.UncompressedSize, fi.Size())
+					}
+					if e.CRC32 == 0 {
+						t.Errorf("got no CRC32 for %s", ext)
+					}
+				}
+			}
+			n := 0
+			for zr.Next() {
+				n++
+			}
+			if zr.Err() != nil || n != 3 {
+				t.Errorf("read %d shapes with error %v, want 3", n, zr.Err())
+			}
+		})
+	}
+}
+
 func unzipToTempDir(t *testing.T, p string) string {
 	td, err := ioutil.TempDir("", "")
 	if err != nil {
@@ -234,3 +391,262 @@ func TestNaturalEarthZip(t *testing.T) {
 		t.Log(m.Attributes["name"])
 	}
 }
+
+func TestZipReaderSizeAndProgress(t *testing.T) {
+	dir, filename := createTempZIP("test_files/polygon", t)
+	defer os.RemoveAll(dir)
+	p := filepath.Join(dir, filename)
+	shpInfo, _ := os.Stat("test_files/polygon.shp")
+	dbfInfo, _ := os.Stat("test_files/polygon.dbf")
+
+	var done, total int64
+	zr, err := OpenZip(p, WithProgress(func(d, t int64) { done, total = d, t }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+	shp, dbf := zr.UncompressedSize()
+	if shp != shpInfo.Size() || dbf != dbfInfo.Size() {
+		t.Errorf("got sizes %d and %d, want %d and %d", shp, dbf, shpInfo.Size(), dbfInfo.Size())
+	}
+	for zr.Next() {
+	}
+	if err := zr.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if total != shp+dbf {
+		t.Errorf("got progress total %d, want %d", total, shp+dbf)
+	}
+	// the DBF end-of-file marker is never read
+	if done < shp || done > total {
+		t.Errorf("got %d bytes done of %d", done, total)
+	}
+}
+
+func TestZipReaderSizeFactor(t *testing.T) {
+	dir, filename := createTempZIP("test_files/polygon", t)
+	defer os.RemoveAll(dir)
+	p := filepath.Join(dir, filename)
+
+	zr, err := OpenZip(p, WithZipSizeFactor(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for zr.Next() {
+	}
+	if err := zr.Err(); err != nil {
+		t.Errorf("unexpected error for honest sizes: %v", err)
+	}
+	zr.Close()
+
+	// a factor below 1 behaves like an archive that declares too little
+	zr, err = OpenZip(p, WithZipSizeFactor(0.5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+	for zr.Next() {
+	}
+	if err := zr.Err(); err == nil || !strings.Contains(err.Error(), "declared size") {
+		t.Errorf("expected size limit error, got %v", err)
+	}
+}
+
+func TestOpenAllShapesFromZip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "layers.zip")
+	f, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for _, layer := range []string{"polyline", "point"} {
+		for _, suffix := range []string{".shp", ".shx", ".dbf"} {
+			compressFileToZIP(zw, "test_files/"+layer+suffix, "layers/"+layer+suffix, t)
+		}
+	}
+	zw.Close()
+	f.Close()
+
+	zrs, err := OpenAllShapesFromZip(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(zrs) != 2 {
+		t.Fatalf("got %d readers, want 2", len(zrs))
+	}
+	// closing one reader does not affect the others
+	zrs[0].Close()
+	for i, want := range []struct {
+		name  string
+		count int
+	}{{"polyline", 2}, {"point", 3}} {
+		if got := zrs[i].Entries()[".shp"].Name; got != "layers/"+want.name+".shp" {
+			t.Errorf("reader %d: got %s", i, got)
+		}
+		if i == 0 {
+			continue
+		}
+		n := 0
+		for zrs[i].Next() {
+			n++
+		}
+		if err := zrs[i].Err(); err != nil || n != want.count {
+			t.Errorf("%s: got %d shapes and error %v, want %d", want.name, n, err, want.count)
+		}
+		zrs[i].Close()
+	}
+
+	empty := filepath.Join(dir, "empty.zip")
+	writeZip(t, empty, "readme.txt")
+	if _, err := OpenAllShapesFromZip(empty); err == nil {
+		t.Error("expected an error for an archive without shapefiles")
+	}
+}
+
+func TestZipReaderBBox(t *testing.T) {
+	dir, filename := createTempZIP("test_files/polygon", t)
+	defer os.RemoveAll(dir)
+	zr, err := OpenZip(filepath.Join(dir, filename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+	r, err := Open("test_files/polygon.shp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if got, want := zr.BBox(), r.BBox(); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestZipReaderSidecars(t *testing.T) {
+	src, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(src)
+	prefix := filepath.Join(src, "point")
+	for _, ext := range []string{".shp", ".dbf"} {
+		b, err := ioutil.ReadFile("test_files/point" + ext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ioutil.WriteFile(prefix+ext, b, 0644)
+	}
+	ioutil.WriteFile(prefix+".cpg", []byte("UTF-8\r\n"), 0644)
+	ioutil.WriteFile(prefix+".prj", []byte(`GEOGCS["WGS 84"]`), 0644)
+	ioutil.WriteFile(prefix+".sbn", []byte("index"), 0644)
+
+	dir, filename := createTempZIPWith(prefix, []string{".shp", ".dbf", ".cpg", ".prj", ".sbn"}, t)
+	defer os.RemoveAll(dir)
+	zr, err := OpenZip(filepath.Join(dir, filename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+	if got := zr.Codepage(); got != "UTF-8" {
+		t.Errorf("got codepage %q, want UTF-8", got)
+	}
+	if got := zr.Projection(); got != `GEOGCS["WGS 84"]` {
+		t.Errorf("got projection %q", got)
+	}
+	if zr.HasIndex() {
+		t.Error("got an index for an archive without .shx")
+	}
+	if e := zr.Entries()[".sbn"]; e == nil || e.Name != "point.sbn" {
+		t.Errorf("got .sbn entry %+v", e)
+	}
+	if b, err := zr.ReadSidecar(".SBN"); err != nil || string(b) != "index" {
+		t.Errorf("got %q, %v", b, err)
+	}
+	if _, err := zr.ReadSidecar(".shx"); !errors.Is(err, ErrFileNotInArchive) {
+		t.Errorf("got %v, want ErrFileNotInArchive", err)
+	}
+
+	dir2, filename2 := createTempZIP("test_files/point", t)
+	defer os.RemoveAll(dir2)
+	zr2, err := OpenZip(filepath.Join(dir2, filename2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr2.Close()
+	if !zr2.HasIndex() || zr2.Codepage() != "" {
+		t.Errorf("got index %v and codepage %q", zr2.HasIndex(), zr2.Codepage())
+	}
+}
+
+func TestZipReaderInconsistentNames(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	archive := filepath.Join(dir, "roads.zip")
+	out, err := os.Create(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(out)
+	for name, src := range map[string]string{
+		"./Data/roads.shp": "test_files/point.shp",
+		"data\\ROADS.DBF":  "test_files/point.dbf",
+		"DATA/roads.PRJ":   "",
+	} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if src == "" {
+			io.WriteString(w, `GEOGCS["WGS 84"]`)
+			continue
+		}
+		b, err := ioutil.ReadFile(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(b)
+	}
+	zw.Close()
+	out.Close()
+
+	open := map[string]func(opts ...Option) (*ZipReader, error){
+		"OpenZip": func(opts ...Option) (*ZipReader, error) {
+			return OpenZip(archive, opts...)
+		},
+		"OpenShapeFromZip": func(opts ...Option) (*ZipReader, error) {
+			return OpenShapeFromZip(archive, "data/roads.shp", opts...)
+		},
+	}
+	for name, open := range open {
+		zr, err := open()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(zr.Fields()) == 0 || zr.Projection() == "" {
+			t.Errorf("%s: got %d fields and projection %q", name, len(zr.Fields()), zr.Projection())
+		}
+		if e := zr.Entries()[".dbf"]; e == nil || e.Name != "data\\ROADS.DBF" {
+			t.Errorf("%s: got .dbf entry %+v", name, e)
+		}
+		zr.Close()
+	}
+
+	zr, err := OpenZip(archive, WithExactZipNames())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+	if len(zr.Fields()) != 0 || zr.Projection() != "" {
+		t.Errorf("got %d fields and projection %q with exact names", len(zr.Fields()), zr.Projection())
+	}
+	if _, err := OpenShapeFromZip(archive, "data/roads.shp", WithExactZipNames()); !errors.Is(err, ErrFileNotInArchive) {
+		t.Errorf("got %v, want ErrFileNotInArchive", err)
+	}
+}
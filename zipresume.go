@@ -0,0 +1,56 @@
+package shp
+
+import "fmt"
+
+// ZipFingerprint identifies the content of the SHP in a ZIP archive.
+type ZipFingerprint struct {
+	Size  uint64
+	CRC32 uint32
+}
+
+// Position is a checkpoint in the iteration over a shapefile in a ZIP archive
+// that can be used to resume reading with ResumeZip, e.g. after a restart of
+// the process.
+type Position struct {
+	// Record is the number of records that were read. Resuming continues
+	// with the record at this index.
+	Record int
+	// Entry is the name of the SHP in the archive.
+	Entry string
+	// Fingerprint identifies the SHP the position refers to.
+	Fingerprint ZipFingerprint
+}
+
+// Position returns the current position of the ZipReader.
+func (zr *ZipReader) Position() Position {
+	pos := Position{}
+	if sr, ok := zr.sr.(*seqReader); ok {
+		pos.Record = sr.count
+	}
+	if e := zr.entries[".shp"]; e != nil {
+		pos.Entry = e.Name
+		pos.Fingerprint = ZipFingerprint{Size: e.UncompressedSize, CRC32: e.CRC32}
+	}
+	return pos
+}
+
+// ResumeZip opens the shapefile of pos in the ZIP archive at zipFilePath and
+// skips the records that were read before pos was taken. The skipped records
+// are not decoded. It returns an error if the fingerprint of the SHP in the
+// archive does not match the one of pos, i.e. if the archive has changed.
+func ResumeZip(zipFilePath string, pos Position, opts ...Option) (*ZipReader, error) {
+	zr, err := OpenShapeFromZip(zipFilePath, pos.Entry, opts...)
+	if err != nil {
+		return nil, err
+	}
+	e := zr.entries[".shp"]
+	if e == nil || (ZipFingerprint{Size: e.UncompressedSize, CRC32: e.CRC32}) != pos.Fingerprint {
+		zr.Close()
+		return nil, fmt.Errorf("Cannot resume %s in %s: the archive has changed", pos.Entry, zipFilePath)
+	}
+	if err := zr.sr.(*seqReader).skip(pos.Record); err != nil {
+		zr.Close()
+		return nil, err
+	}
+	return zr, nil
+}
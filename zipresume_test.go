@@ -0,0 +1,67 @@
+package shp
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestResumeZip(t *testing.T) {
+	dir, filename := createTempZIP("test_files/point", t)
+	defer os.RemoveAll(dir)
+	p := filepath.Join(dir, filename)
+	want := getShapesFromFile("test_files/point", t)
+
+	zr, err := OpenZip(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !zr.Next() {
+		t.Fatal(zr.Err())
+	}
+	pos := zr.Position()
+	zr.Close()
+	if pos.Record != 1 || pos.Entry != "point.shp" || pos.Fingerprint.CRC32 == 0 {
+		t.Fatalf("got position %+v", pos)
+	}
+
+	zr, err = ResumeZip(p, pos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []Shape
+	for zr.Next() {
+		n, s := zr.Shape()
+		if n != len(got)+1 {
+			t.Errorf("got index %d after resuming, want %d", n, len(got)+1)
+		}
+		if zr.Attribute(0) != "" {
+			t.Errorf("got attribute %q", zr.Attribute(0))
+		}
+		got = append(got, s)
+	}
+	if zr.Err() != nil {
+		t.Fatal(zr.Err())
+	}
+	zr.Close()
+	if !reflect.DeepEqual(got, want[1:]) {
+		t.Errorf("got %v after resuming, want %v", got, want[1:])
+	}
+
+	// replace the archive with a different shapefile of the same name
+	w, err := os.Create(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(w)
+	for _, ext := range []string{".shp", ".shx", ".dbf"} {
+		compressFileToZIP(zw, "test_files/point_padded"+ext, "point"+ext, t)
+	}
+	zw.Close()
+	w.Close()
+	if _, err := ResumeZip(p, pos); err == nil {
+		t.Error("resumed against a changed archive without error")
+	}
+}
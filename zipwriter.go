@@ -0,0 +1,167 @@
+package shp
+
+import (
+	"archive/zip"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// ZipWriter writes a shapefile into a ZIP archive. It embeds a Writer whose
+// files are kept in memory until Close writes them to the archive, so no
+// temporary files are created.
+type ZipWriter struct {
+	*Writer
+
+	zw    *zip.Writer
+	name  string
+	files map[string]*memFile
+
+	// file is only set if CreateZip created the archive
+	file     *os.File
+	filePath string
+}
+
+// CreateZip creates a ZIP archive at zipFilePath that contains a single
+// shapefile called name, e.g. "roads" or "roads.shp". It is important to use
+// Close when done, as the shapefile is written to the archive only then.
+func CreateZip(zipFilePath string, name string, t ShapeType, opts ...Option) (*ZipWriter, error) {
+	f, err := os.Create(zipFilePath)
+	if err != nil {
+		return nil, err
+	}
+	zw, err := CreateZipWriter(f, name, t, opts...)
+	if err != nil {
+		f.Close()
+		os.Remove(zipFilePath)
+		return nil, err
+	}
+	zw.file, zw.filePath = f, zipFilePath
+	return zw, nil
+}
+
+// CreateZipWriter returns a ZipWriter that writes a ZIP archive containing a
+// single shapefile called name to out. Close does not close out.
+func CreateZipWriter(out io.Writer, name string, t ShapeType, opts ...Option) (*ZipWriter, error) {
+	if strings.HasSuffix(strings.ToLower(name), ".shp") {
+		name = name[0 : len(name)-4]
+	}
+	if name == "" || path.IsAbs(name) || strings.Contains(name, "\\") {
+		return nil, errors.New("Invalid name for shapefile in archive: " + name)
+	}
+	zw := &ZipWriter{
+		zw:    zip.NewWriter(out),
+		name:  name,
+		files: make(map[string]*memFile),
+	}
+	w, err := newWriter(name, t, func(ext string) (writeSeekCloser, error) {
+		f := &memFile{}
+		zw.files[ext] = f
+		return f, nil
+	}, opts)
+	if err != nil {
+		return nil, err
+	}
+	zw.Writer = w
+	return zw, nil
+}
+
+// Close writes the headers of the shapefile and adds its .shp, .shx and .dbf,
+// as well as the .prj and .cpg if a projection or charset was set, to the
+// archive, which is then finalized.
+func (zw *ZipWriter) Close() error {
+	zw.Writer.Close()
+	err := zw.writeEntries()
+	if zw.file != nil {
+		if cerr := zw.file.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+func (zw *ZipWriter) writeEntries() error {
+	for _, ext := range []string{".shp", ".shx", ".dbf", ".prj", ".cpg"} {
+		if zw.files[ext] == nil {
+			continue
+		}
+		w, err := zw.zw.Create(zw.name + ext)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(zw.files[ext].buf); err != nil {
+			return err
+		}
+	}
+	return zw.zw.Close()
+}
+
+// Abort discards the shapefile. If the archive was created by CreateZip, it
+// is removed, otherwise nothing more is written to the output.
+func (zw *ZipWriter) Abort() error {
+	zw.Writer.Abort()
+	if zw.file == nil {
+		return nil
+	}
+	zw.file.Close()
+	return os.Remove(zw.filePath)
+}
+
+// memFile is a file in memory.
+type memFile struct {
+	buf []byte
+	pos int64
+}
+
+func (f *memFile) Write(p []byte) (int, error) {
+	end := f.pos + int64(len(p))
+	if end > int64(len(f.buf)) {
+		if end > int64(cap(f.buf)) {
+			buf := make([]byte, end, 2*end)
+			copy(buf, f.buf)
+			f.buf = buf
+		}
+		f.buf = f.buf[:end]
+	}
+	copy(f.buf[f.pos:], p)
+	f.pos = end
+	return len(p), nil
+}
+
+func (f *memFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.pos
+	case io.SeekEnd:
+		offset += int64(len(f.buf))
+	}
+	if offset < 0 {
+		return f.pos, errors.New("Seek to negative offset")
+	}
+	f.pos = offset
+	return offset, nil
+}
+
+func (f *memFile) ReadAt(p []byte, off int64) (int, error) {
+	if off >= int64(len(f.buf)) {
+		return 0, io.EOF
+	}
+	n := copy(p, f.buf[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (f *memFile) Truncate(size int64) error {
+	if size < int64(len(f.buf)) {
+		f.buf = f.buf[:size]
+	}
+	return nil
+}
+
+func (f *memFile) Close() error {
+	return nil
+}
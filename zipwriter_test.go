@@ -0,0 +1,116 @@
+package shp
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeRoads writes three points with attributes to w.
+func writeRoads(w *Writer) {
+	w.SetFields([]Field{StringField("NAME", 10), NumberField("LANES", 2)})
+	for i, name := range []string{"Main", "High", "Mill"} {
+		n := w.Write(&Point{float64(i), float64(-i)})
+		w.WriteAttribute(int(n), 0, name)
+		w.WriteAttribute(int(n), 1, i+1)
+	}
+}
+
+func TestZipWriter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// the archive holds the same files as a shapefile written to disk
+	w, err := Create(filepath.Join(dir, "roads.shp"), POINT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeRoads(w)
+	w.Close()
+
+	var buf bytes.Buffer
+	zw, err := CreateZipWriter(&buf, "roads.shp", POINT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeRoads(zw.Writer)
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	z, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(z.File) != 3 {
+		t.Errorf("got %d entries, want 3", len(z.File))
+	}
+	for _, ext := range []string{".shp", ".shx", ".dbf"} {
+		rc, err := openFromZIP(z, "roads"+ext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, _ := ioutil.ReadAll(rc)
+		rc.Close()
+		want, _ := ioutil.ReadFile(filepath.Join(dir, "roads"+ext))
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s differs from the file written by Create", ext)
+		}
+	}
+
+	name := filepath.Join(dir, "roads.zip")
+	zw, err = CreateZip(name, "roads", POINT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.SetProjectionEPSG(4326); err != nil {
+		t.Fatal(err)
+	}
+	writeRoads(zw.Writer)
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := OpenZip(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+	if zr.Projection() != wgs84WKT {
+		t.Errorf("got projection %q", zr.Projection())
+	}
+	var names []string
+	for zr.Next() {
+		names = append(names, zr.Attribute(0))
+	}
+	if len(names) != 3 || names[2] != "Mill" {
+		t.Errorf("got attributes %q", names)
+	}
+}
+
+func TestZipWriterAbort(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-shp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "roads.zip")
+	zw, err := CreateZip(name, "roads", POINT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeRoads(zw.Writer)
+	if err := zw.Abort(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(name); !os.IsNotExist(err) {
+		t.Error("expected aborted archive to be removed")
+	}
+	if _, err := CreateZipWriter(ioutil.Discard, "/abs", POINT); err == nil {
+		t.Error("expected an error for an absolute name")
+	}
+}